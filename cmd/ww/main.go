@@ -14,9 +14,13 @@ import (
 
 	"github.com/wetware/ww/internal/cmd/boot"
 	"github.com/wetware/ww/internal/cmd/client"
+	"github.com/wetware/ww/internal/cmd/completion"
+	"github.com/wetware/ww/internal/cmd/dev"
 	"github.com/wetware/ww/internal/cmd/keygen"
+	"github.com/wetware/ww/internal/cmd/lint"
 	"github.com/wetware/ww/internal/cmd/shell"
 	"github.com/wetware/ww/internal/cmd/start"
+	printutil "github.com/wetware/ww/internal/util/print"
 )
 
 const version = "0.0.0"
@@ -46,9 +50,12 @@ var flags = []cli.Flag{
 var commands = []*cli.Command{
 	start.Command(),
 	shell.Command(),
+	dev.Command(),
 	client.Command(),
 	keygen.Command(),
+	lint.Command(),
 	boot.Command(),
+	completion.Command(),
 }
 
 func main() {
@@ -59,7 +66,7 @@ func main() {
 		Copyright:            "2020 The Wetware Project",
 		Version:              version,
 		EnableBashCompletion: true,
-		Flags:                flags,
+		Flags:                append(flags, printutil.Flags...),
 		Commands:             commands,
 	})
 }
@@ -24,8 +24,10 @@ require (
 	github.com/libp2p/go-libp2p-core v0.7.0
 	github.com/libp2p/go-libp2p-discovery v0.5.0
 	github.com/libp2p/go-libp2p-kad-dht v0.11.0
+	github.com/libp2p/go-libp2p-noise v0.1.1
 	github.com/libp2p/go-libp2p-peerstore v0.2.6
 	github.com/libp2p/go-libp2p-pubsub v0.4.0
+	github.com/libp2p/go-libp2p-tls v0.1.3
 	github.com/libp2p/go-sockaddr v0.1.0 // indirect
 	github.com/lthibault/jitterbug v0.0.0-20200313035244-37ff5f417161
 	github.com/lthibault/log v1.0.2
@@ -33,7 +35,9 @@ require (
 	github.com/mr-tron/base58 v1.2.0
 	github.com/multiformats/go-multiaddr v0.3.1
 	github.com/multiformats/go-multihash v0.0.14
+	github.com/multiformats/go-multistream v0.2.0
 	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.6.0
 	github.com/sirupsen/logrus v1.7.0
 	github.com/spy16/slurp v0.2.2
 	github.com/stretchr/testify v1.6.1
@@ -42,8 +46,10 @@ require (
 	github.com/whyrusleeping/mdns v0.0.0-20190826153040-b9b60ed33aa9
 	go.uber.org/fx v1.13.1
 	go.uber.org/multierr v1.6.0
+	go.uber.org/zap v1.16.0
 	golang.org/x/lint v0.0.0-20200302205851-738671d3881b // indirect
 	golang.org/x/net v0.0.0-20201110031124-69a78807bb2b // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 	zombiezen.com/go/capnproto2 v2.17.1-0.20200824221555-5246e512e430+incompatible
 )
@@ -94,8 +94,10 @@ func run() cli.ActionFunc {
 		}
 
 		peers, err := d.DiscoverPeers(ctx,
-			// discover.WithLogger(logger),
-			boot.WithLimit(c.Int("n")))
+			boot.WithLimit(c.Int("n")),
+			boot.WithErrorHandler(func(err error) {
+				logger.WithError(err).Error("peer discovery failed")
+			}))
 		if err != nil {
 			return err
 		}
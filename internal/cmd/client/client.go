@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/urfave/cli/v2"
@@ -17,6 +18,23 @@ var (
 	ctx  = ctxutil.WithDefaultSignals(context.Background())
 
 	flags = []cli.Flag{
+		outputFlag,
+		&cli.StringFlag{
+			Name:    "profile",
+			Usage:   "apply the named profile from the config file",
+			EnvVars: []string{"WW_PROFILE"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "addr",
+			Aliases: []string{"a"},
+			Usage:   "dial the host at `ADDR`, falling back on the next -addr if it fails",
+			EnvVars: []string{"WW_ADDRS"},
+		},
+		&cli.DurationFlag{
+			Name:  "dial-timeout",
+			Usage: "timeout for each -addr attempt",
+			Value: time.Second * 3,
+		},
 		&cli.StringSliceFlag{
 			Name:    "join",
 			Aliases: []string{"j"},
@@ -37,11 +55,40 @@ var (
 			Value:   "ww",
 			EnvVars: []string{"WW_NAMESPACE"},
 		},
+		&cli.StringFlag{
+			Name:    "token",
+			Usage:   "authenticate to the host with `TOKEN`",
+			EnvVars: []string{"WW_TOKEN"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "security",
+			Usage:   "secure connections with `PROTO` (noise, tls), in order of preference",
+			EnvVars: []string{"WW_SECURITY"},
+		},
 		&cli.DurationFlag{
 			Name:  "timeout",
 			Usage: "timeout for -dial",
 			Value: time.Second * 10,
 		},
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "log dials, streams and the duration of each RPC on stderr",
+		},
+		&cli.BoolFlag{
+			Name:  "vv",
+			Usage: "as -v, also logging the anchor path and value size of each RPC",
+		},
+		&cli.IntFlag{
+			Name:  "retries",
+			Usage: "number of attempts to reach a host before giving up",
+			Value: 3,
+		},
+		&cli.DurationFlag{
+			Name:  "retry-backoff",
+			Usage: "delay before the first retry; doubles with each attempt",
+			Value: time.Millisecond * 100,
+		},
 	}
 )
 
@@ -54,30 +101,91 @@ func Command() *cli.Command {
 		Before:      before(),
 		After:       after(),
 		Subcommands: subcommands(),
+		Description: `Errors are printed on stderr, as JSON objects with --output json, e.g.
+
+    {"error":"...","kind":"not_found","code":2}
+
+and commands exit with a status that classifies the error:
+
+    1  other errors
+    2  not found, e.g. an anchor that holds no value
+    3  permission denied
+    4  connection failure`,
 	}
 }
 
 // before the wetware client
 func before() cli.BeforeFunc {
 	return func(c *cli.Context) (err error) {
+		warnings, err := clientutil.ApplyProfile(c)
+		if c.Args().First() == completePathCmd {
+			return nil // dials with its own timeout, and fails silently
+		}
+
+		for _, w := range warnings {
+			_, _ = fmt.Fprintf(c.App.ErrWriter, "warning: %s\n", w)
+		}
+
+		if err != nil {
+			return fail(c, err)
+		}
+
+		if err = validateOutput(c); err != nil {
+			return fail(c, err)
+		}
+
+		if offline(c) {
+			return nil
+		}
+
 		ctx, cancel := context.WithTimeout(ctx, c.Duration("timeout"))
 		defer cancel()
 
-		root, err = clientutil.Dial(ctx, c)
-		return
+		if root, err = clientutil.Dial(ctx, c); err != nil {
+			return fail(c, fmt.Errorf("%w: %v", errConnection, err))
+		}
+
+		return nil
 	}
 }
 
 func after() cli.AfterFunc {
 	return func(c *cli.Context) error {
+		if offline(c) {
+			return nil
+		}
+
 		return root.Close()
 	}
 }
 
+// offline returns true if the subcommand does not use root, either because it needs no
+// connection to the cluster, or because it dials the cluster itself.
+func offline(c *cli.Context) bool {
+	switch c.Args().First() {
+	case completePathCmd, configCmd:
+		return true
+
+	case runCmd:
+		return checkOnly(c.Args().Tail())
+	}
+
+	return false
+}
+
 func subcommands() []*cli.Command {
 	return []*cli.Command{
-		ls(),
-		subscribe(),
-		publish(),
+		rendered(ls()),
+		rendered(get()),
+		rendered(set()),
+		rendered(cp()),
+		rendered(tree()),
+		rendered(peers()),
+		rendered(repl()),
+		rendered(run()),
+		rendered(subscribe()),
+		rendered(publish()),
+		config(),
+		completePath(),
 	}
 }
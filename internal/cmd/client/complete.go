@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	clientutil "github.com/wetware/ww/internal/util/client"
+	"github.com/wetware/ww/pkg/client"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
+	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
+)
+
+const (
+	// completePathCmd is called by the scripts of 'ww completion'.  It dials the
+	// cluster itself, so that the dial is bounded by completionDialTimeout rather
+	// than --timeout.
+	completePathCmd = "__complete-path"
+
+	completionDialTimeout = time.Second
+	completionTimeout     = time.Millisecond * 300 // bounds the Ls
+)
+
+func completePath() *cli.Command {
+	return &cli.Command{
+		Name:      completePathCmd,
+		Usage:     "complete an anchor path",
+		ArgsUsage: "word",
+		Hidden:    true,
+		Action:    completePathAction(),
+	}
+}
+
+// completePathAction prints the paths of the children of the word's parent that begin
+// with its last segment, one per line.  Failures print nothing, so that completion
+// degrades silently when the cluster is unreachable.
+func completePathAction() cli.ActionFunc {
+	return func(c *cli.Context) error {
+		word := c.Args().First()
+		if !strings.HasPrefix(word, "/") {
+			word = "/" + word
+		}
+
+		i := strings.LastIndex(word, "/")
+		dir, prefix := word[:i+1], word[i+1:]
+
+		root, err := dial(c)
+		if err != nil {
+			return nil
+		}
+		defer root.Close()
+
+		ctx, cancel := context.WithTimeout(ctx, completionTimeout)
+		defer cancel()
+
+		a := root.Walk(ctx, anchorpath.Unescape(anchorpath.Parts(dir)))
+		defer a.Release()
+
+		prefixes := anchorpath.Unescape([]string{prefix})
+		cs, err := anchorutil.List(ctx, a, anchorutil.ListOptions{Prefix: prefixes[0]})
+		defer anchorutil.Release(cs)
+
+		if err != nil {
+			return nil
+		}
+
+		for _, child := range cs {
+			_, _ = fmt.Fprintln(c.App.Writer, dir+anchorpath.Escape(child.Name()))
+		}
+
+		return nil
+	}
+}
+
+func dial(c *cli.Context) (client.Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, completionDialTimeout)
+	defer cancel()
+
+	return clientutil.Dial(ctx, c)
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+
+	clientutil "github.com/wetware/ww/internal/util/client"
+)
+
+// configCmd does not dial the cluster.
+const configCmd = "config"
+
+func config() *cli.Command {
+	return &cli.Command{
+		Name:  configCmd,
+		Usage: "view and edit the client configuration",
+		Description: `The client configuration is read from $XDG_CONFIG_HOME/ww/config.yaml,
+which defines named profiles of default values for the client flags, e.g.
+
+    profile: dev        # applied when --profile is not set
+    profiles:
+      dev:
+        discover: /mdns
+      staging:
+        addrs: [/dns4/staging.example.com/tcp/2020/p2p/Qm...]
+        namespace: staging
+        token: s3cr3t       # or $WW_TOKEN
+        timeout: 30s
+        dial-timeout: 5s
+        output: json
+
+A profile is selected with --profile or $WW_PROFILE.  Flags that are given on the
+command line, or by their environment variables, override the profile.  If any of
+--addr, --join or --discover is given, the profile's addrs, join and discover are
+all ignored.  A missing file is not an error, and unknown keys are reported as
+warnings.`,
+		Subcommands: []*cli.Command{
+			rendered(configView()),
+			rendered(configSetProfile()),
+		},
+	}
+}
+
+func configView() *cli.Command {
+	return &cli.Command{
+		Name:  "view",
+		Usage: "print the configuration file",
+		Action: func(c *cli.Context) error {
+			cfg, _, err := clientutil.LoadConfig() // warnings printed by before()
+			if err != nil {
+				return err
+			}
+
+			if jsonOutput(c) {
+				return jsonEncoder(c.App.Writer, c.Bool("prettyprint")).Encode(cfg)
+			}
+
+			if path, ok := clientutil.ConfigPath(); ok {
+				_, _ = fmt.Fprintf(c.App.Writer, "# %s\n", path)
+			}
+
+			enc := yaml.NewEncoder(c.App.Writer)
+			enc.SetIndent(2)
+			return enc.Encode(cfg)
+		},
+	}
+}
+
+func configSetProfile() *cli.Command {
+	return &cli.Command{
+		Name:      "set-profile",
+		Usage:     "set the profile that is applied when --profile is not set",
+		ArgsUsage: "name",
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 1 {
+				return errors.New("expected a profile name")
+			}
+
+			return clientutil.SetDefaultProfile(c.Args().First())
+		},
+	}
+}
@@ -0,0 +1,277 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/multiformats/go-multiaddr"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/wetware/ww/internal/mem"
+	clientutil "github.com/wetware/ww/internal/util/client"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
+	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
+)
+
+func cp() *cli.Command {
+	return &cli.Command{
+		Name:      "cp",
+		Usage:     "copy values between anchors",
+		ArgsUsage: "src dst",
+		Description: `Copy the value stored at the anchor at src to the anchor at dst.  With -r,
+copy each value stored in the subtree of src to the same relative path below dst,
+merging them with the anchors already there.  Processes are not copied.
+
+The source and destination may be in different clusters.  --from-addr and --to-addr
+dial the hosts of the source and the destination, respectively, in place of the host
+given by the client flags.
+
+Values are never overwritten: copying a value fails if its destination holds one.
+With -r, the other values are still copied, and the paths that could not be copied
+are listed on stderr, or in the JSON report with --output json.  While copying to a
+terminal, progress is shown on stderr.`,
+		Flags:  cpFlags(),
+		Action: cpAction(),
+	}
+}
+
+func cpFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "recursive",
+			Aliases: []string{"r"},
+			Usage:   "copy the subtree of src",
+		},
+		&cli.StringSliceFlag{
+			Name:  "from-addr",
+			Usage: "dial the source cluster through the host at `ADDR`",
+		},
+		&cli.StringSliceFlag{
+			Name:  "to-addr",
+			Usage: "dial the destination cluster through the host at `ADDR`",
+		},
+	}
+}
+
+func cpAction() cli.ActionFunc {
+	return func(c *cli.Context) error {
+		if c.NArg() != 2 {
+			return errors.New("expected a source and a destination path")
+		}
+
+		src, err := literalPath(c.Args().Get(0))
+		if err != nil {
+			return errors.Wrap(err, "invalid source")
+		}
+
+		dst, err := literalPath(c.Args().Get(1))
+		if err != nil {
+			return errors.Wrap(err, "invalid destination")
+		}
+
+		from, err := cluster(c, "from-addr")
+		if err != nil {
+			return err
+		}
+		defer from.Close()
+
+		to, err := cluster(c, "to-addr")
+		if err != nil {
+			return err
+		}
+		defer to.Close()
+
+		a := from.Walk(ctx, src)
+		defer a.Release()
+
+		b := to.Walk(ctx, dst)
+		defer b.Release()
+
+		if !c.Bool("recursive") {
+			v, err := a.Load(ctx)
+			if err != nil {
+				return errors.Wrap(err, "error loading source")
+			}
+
+			if core.IsNil(v) {
+				return errors.Wrap(errNotFound, anchorpath.Join(src))
+			}
+
+			return errors.Wrap(store(b, v), "error storing value")
+		}
+
+		cp := copier{report: copyReport{Failed: []copyFailure{}}}
+		if c.App.ErrWriter == os.Stderr && isTerminal(os.Stderr) {
+			cp.progress = c.App.ErrWriter
+		}
+
+		cp.copy(a, b)
+		if cp.progress != nil {
+			_, _ = fmt.Fprint(cp.progress, "\r") // overwritten by the report
+		}
+
+		return cp.report.print(c)
+	}
+}
+
+// literalPath returns the parts of the cleaned, absolute path, which must not be a
+// glob pattern.
+func literalPath(path string) ([]string, error) {
+	path, err := cleanPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := anchorpath.Parts(path)
+	if anchorpath.IsGlob(parts) {
+		return nil, errors.New("cannot copy a glob pattern")
+	}
+
+	return anchorpath.Unescape(parts), nil
+}
+
+// closer is an anchor whose connection must be closed.
+type closer interface {
+	ww.Anchor
+	Close() error
+}
+
+type nopCloser struct{ ww.Anchor }
+
+func (nopCloser) Close() error { return nil }
+
+// cluster dials the hosts given by the named flag, or returns the root anchor if the
+// flag is not set.
+func cluster(c *cli.Context, flag string) (closer, error) {
+	if !c.IsSet(flag) {
+		return nopCloser{root}, nil
+	}
+
+	as := make([]multiaddr.Multiaddr, len(c.StringSlice(flag)))
+	for i, s := range c.StringSlice(flag) {
+		var err error
+		if as[i], err = multiaddr.NewMultiaddr(s); err != nil {
+			return nil, errors.Wrapf(err, "%s %s", flag, s)
+		}
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, c.Duration("timeout"))
+	defer cancel()
+
+	cl, err := clientutil.DialAddrs(dialCtx, c, as)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errConnection, flag, err)
+	}
+
+	return cl, nil
+}
+
+// store v at a, unless a holds a value.
+func store(a ww.Anchor, v ww.Any) error {
+	if v.Value().Which() == mem.Any_Which_proc {
+		return errors.New("cannot copy a process")
+	}
+
+	old, err := a.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !core.IsNil(old) {
+		return ww.ErrAnchorNotEmpty
+	}
+
+	return a.Store(ctx, v)
+}
+
+type copyReport struct {
+	Copied int           `json:"copied"`
+	Failed []copyFailure `json:"failed"`
+}
+
+type copyFailure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// print the report on stdout with --output json, and on stderr otherwise.  Returns an
+// error if any path failed.
+func (r copyReport) print(c *cli.Context) error {
+	if jsonOutput(c) {
+		if err := jsonEncoder(c.App.Writer, c.Bool("prettyprint")).Encode(r); err != nil {
+			return err
+		}
+	} else {
+		for _, f := range r.Failed {
+			_, _ = fmt.Fprintf(c.App.ErrWriter, "%s: %s\n", f.Path, f.Error)
+		}
+
+		_, _ = fmt.Fprintf(c.App.ErrWriter, "%d anchors copied\n", r.Copied)
+	}
+
+	if len(r.Failed) > 0 {
+		return errors.Errorf("%d of %d anchors not copied", len(r.Failed), len(r.Failed)+r.Copied)
+	}
+
+	return nil
+}
+
+// copier copies a subtree depth-first, recording the paths that fail.
+type copier struct {
+	report   copyReport
+	progress io.Writer // nil unless stderr is a terminal
+}
+
+func (cp *copier) copy(src, dst ww.Anchor) {
+	// hosts, i.e. the children of the root, hold no value
+	if len(src.Path()) >= 2 {
+		cp.copyValue(src, dst)
+	}
+
+	cs, err := anchorutil.List(ctx, src, anchorutil.ListOptions{})
+	defer anchorutil.Release(cs)
+
+	if err != nil {
+		cp.fail(src, err)
+		return
+	}
+
+	for _, child := range cs {
+		d := dst.Walk(ctx, []string{child.Name()})
+		cp.copy(child, d)
+		d.Release()
+	}
+}
+
+func (cp *copier) copyValue(src, dst ww.Anchor) {
+	v, err := src.Load(ctx)
+	if err == nil && core.IsNil(v) {
+		return
+	}
+
+	if err == nil {
+		err = store(dst, v)
+	}
+
+	if err != nil {
+		cp.fail(src, err)
+		return
+	}
+
+	cp.report.Copied++
+	if cp.progress != nil {
+		_, _ = fmt.Fprintf(cp.progress, "\r%d anchors copied", cp.report.Copied)
+	}
+}
+
+func (cp *copier) fail(a ww.Anchor, err error) {
+	cp.report.Failed = append(cp.report.Failed, copyFailure{
+		Path:  anchorpath.Join(a.Path()),
+		Error: err.Error(),
+	})
+}
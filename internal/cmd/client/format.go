@@ -0,0 +1,105 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+	capnp "zombiezen.com/go/capnproto2"
+
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	"github.com/wetware/ww/pkg/lang/core/codec"
+	"github.com/wetware/ww/pkg/lang/reader"
+)
+
+// jsonCodec converts values to and from JSON, as set by the --tagged and
+// --prettyprint flags.
+func jsonCodec(c *cli.Context) codec.JSON {
+	j := codec.JSON{Tagged: c.Bool("tagged")}
+	if c.Bool("prettyprint") {
+		j.Indent = "  "
+	}
+
+	return j
+}
+
+// isTerminal returns true if f is a character device, e.g. a TTY.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// parse exactly one form from r.
+func parse(r io.Reader) (ww.Any, error) {
+	rd, err := reader.New(r)
+	if err != nil {
+		return nil, err
+	}
+
+	forms, err := rd.All()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(forms) != 1 {
+		return nil, errors.Errorf("expected one value, got %d", len(forms))
+	}
+
+	v, ok := forms[0].(ww.Any)
+	if !ok {
+		return nil, errors.Errorf("cannot store %T", forms[0])
+	}
+
+	return v, nil
+}
+
+// readRaw stdin into a string.  Fails if more than max bytes are read, unless max is
+// zero.
+func readRaw(r io.Reader, max int64) (ww.Any, error) {
+	if max > 0 {
+		r = io.LimitReader(r, max+1)
+	}
+
+	var b bytes.Buffer
+	if _, err := b.ReadFrom(r); err != nil {
+		return nil, err
+	}
+
+	if max > 0 && int64(b.Len()) > max {
+		return nil, errors.Errorf("value exceeds %d bytes (see --max-size)", max)
+	}
+
+	return core.NewString(capnp.SingleSegment(nil), b.String())
+}
+
+// rawBytes returns the contents of a string, or the canonical encoding of any other
+// value.
+func rawBytes(v ww.Any) ([]byte, error) {
+	if v.Value().Which() == mem.Any_Which_str {
+		return v.Value().StrBytes()
+	}
+
+	return core.Canonical(v)
+}
+
+// printable returns true if b is text that can be written to a terminal without
+// disrupting it.
+func printable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+
+	for _, r := range string(b) {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' && r != '\r' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	printutil "github.com/wetware/ww/internal/util/print"
+	"github.com/wetware/ww/pkg/lang/core"
+	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
+)
+
+func get() *cli.Command {
+	return &cli.Command{
+		Name:      "get",
+		Usage:     "load the value at an anchor",
+		ArgsUsage: "path",
+		Description: `Load the value stored at the anchor at path, and print it.  By
+default, values are printed in their human-readable form, which is summarized and
+laid out across lines when printing to a terminal; see the --print-* flags.  With --format json, or
+--output json, the value is printed as JSON.  Keywords, symbols and other values
+that JSON lacks are printed as strings, unless --tagged is given, in which case they
+are printed as objects that name their type, e.g. {"~keyword": "foo"}, so that
+'set --stdin-format json --tagged' can store them again.
+
+With --raw, the value's bytes are written to stdout as they are, without a trailing
+newline, so that 'set --stdin-format raw' can store them again.  The bytes of a
+string are its contents; other values are written in their canonical, packed Cap'n
+Proto encoding.  Binary data is not written to a terminal unless --force is given.
+
+Exits with status 2 if the anchor holds no value.`,
+		Flags:  getFlags(),
+		Action: getAction(),
+	}
+}
+
+func getFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "value format (text, json); defaults to --output",
+		},
+		&cli.BoolFlag{
+			Name:  "tagged",
+			Usage: "annotate JSON values with their types, so that they round-trip",
+		},
+		&cli.BoolFlag{
+			Name:  "raw",
+			Usage: "write the bytes of a string, or the canonical capnp encoding of other values",
+		},
+		&cli.BoolFlag{
+			Name:  "force",
+			Usage: "write binary data to a terminal with --raw",
+		},
+	}
+}
+
+func getAction() cli.ActionFunc {
+	return func(c *cli.Context) error {
+		path, err := cleanPath(c.Args().First())
+		if err != nil {
+			return errors.Wrap(err, "invalid path")
+		}
+
+		parts := anchorpath.Parts(path)
+		if anchorpath.IsGlob(parts) {
+			return errors.New("cannot get a glob pattern")
+		}
+
+		format := c.String("format")
+		if format == "" {
+			format = c.String("output")
+		}
+
+		if format != "text" && format != "json" {
+			return errors.Errorf("invalid format '%s'", format)
+		}
+
+		a := root.Walk(ctx, anchorpath.Unescape(parts))
+		defer a.Release()
+
+		v, err := a.Load(ctx)
+		if err != nil {
+			return errors.Wrap(err, "error loading anchor")
+		}
+
+		if core.IsNil(v) {
+			return errors.Wrap(errNotFound, path)
+		}
+
+		switch {
+		case c.Bool("raw"):
+			b, err := rawBytes(v)
+			if err != nil {
+				return err
+			}
+
+			if c.App.Writer == os.Stdout && isTerminal(os.Stdout) && !c.Bool("force") && !printable(b) {
+				return errors.New("refusing to write binary data to a terminal (use --force)")
+			}
+
+			_, err = c.App.Writer.Write(b)
+			return err
+
+		case format == "json":
+			return jsonCodec(c).Encode(c.App.Writer, v)
+		}
+
+		render := core.Render
+		if c.App.Writer == os.Stdout && isTerminal(os.Stdout) {
+			render = printutil.Printer(c).Render
+		}
+
+		s, err := render(v)
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(c.App.Writer, s)
+		return err
+	}
+}
@@ -2,21 +2,70 @@ package client
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/urfave/cli/v2"
 
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
 	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
 )
 
-const emsg = "error listing anchor, likely due to missing host or misspelled anchor"
-
 func ls() *cli.Command {
 	return &cli.Command{
 		Name:      "ls",
 		Usage:     "list cluster elements",
 		ArgsUsage: "path",
-		Action:    lsAction(),
+		Description: `List the children of the anchor at path.  If path is a glob
+pattern, list the anchors that match it instead.  '*' matches within a path
+segment, and a '**' segment matches any number of segments.  Use '\*' for a
+literal '*'.  Children are listed in order of name, unless --sort says otherwise.
+With --output json, the paths are printed as a JSON array.
+
+With -l, each anchor is listed with the size of its value, in bytes, the time it
+was modified, and its number of children, followed by its path.  Paths of anchors
+with children end in '/', and anchors that hold a process are marked [proc].  Hosts
+do not report modification times yet, so that column is '-', as is any other
+detail that is not available.  With --output json, the anchors are printed as an
+array of objects, e.g.
+
+    [{"path":"/host/a","size":12,"modified":null,"children":2,"proc":false}]
+
+The details are gathered by loading each anchor and listing its children, so -l,
+and --sort size, take two calls per anchor.`,
+		Flags:  lsFlags(),
+		Action: lsAction(),
+	}
+}
+
+func lsFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "long",
+			Aliases: []string{"l"},
+			Usage:   "list the size, modification time and children of each anchor",
+		},
+		&cli.StringFlag{
+			Name:  "sort",
+			Usage: "sort by `KEY` (name, mtime, size)",
+			Value: "name",
+		},
+		&cli.BoolFlag{
+			Name:    "reverse",
+			Aliases: []string{"r"},
+			Usage:   "reverse the sort order",
+		},
+		&cli.StringFlag{
+			Name:  "prefix",
+			Usage: "list only children whose names begin with `PREFIX`",
+		},
 	}
 }
 
@@ -24,13 +73,68 @@ func lsAction() cli.ActionFunc {
 	return func(c *cli.Context) error {
 		path := c.Args().First()
 
-		if err := validatePath(path); err != nil {
+		path, err := cleanPath(path)
+		if err != nil {
 			return errors.Wrap(err, "invalid path")
 		}
 
-		cs, err := root.Walk(ctx, anchorpath.Parts(path)).Ls(ctx)
+		opts, err := listOptions(c)
+		if err != nil {
+			return err
+		}
+
+		if opts.key == "mtime" {
+			_, _ = fmt.Fprintln(c.App.ErrWriter,
+				"warning: modification times are not available; sorting by name")
+			opts.key = "name"
+		}
+
+		var (
+			cs    []ww.Anchor
+			parts = anchorpath.Parts(path)
+		)
+
+		if anchorpath.IsGlob(parts) {
+			cs, err = anchorutil.Glob(ctx, root, parts)
+		} else {
+			a := root.Walk(ctx, anchorpath.Unescape(parts))
+			cs, err = anchorutil.List(ctx, a, opts.ListOptions)
+			a.Release()
+		}
+		defer anchorutil.Release(cs)
+
 		if err != nil {
-			return errors.Wrap(err, emsg)
+			return errors.Wrap(err, "error listing anchor")
+		}
+
+		if c.Bool("long") || opts.key == "size" {
+			es, err := stat(cs)
+			if err != nil {
+				return errors.Wrap(err, "error listing anchor")
+			}
+
+			opts.sort(es)
+			if c.Bool("long") {
+				return printLong(c, es)
+			}
+
+			for i, e := range es {
+				cs[i] = e.anchor
+			}
+		} else if opts.Desc != c.Bool("reverse") {
+			// children are listed in ascending order; glob matches in traversal order
+			sort.SliceStable(cs, func(i, j int) bool {
+				return anchorpath.Join(cs[i].Path()) > anchorpath.Join(cs[j].Path())
+			})
+		}
+
+		if jsonOutput(c) {
+			paths := make([]string, len(cs))
+			for i, anchor := range cs {
+				paths[i] = anchorpath.Join(anchor.Path())
+			}
+
+			return jsonEncoder(c.App.Writer, c.Bool("prettyprint")).Encode(paths)
 		}
 
 		for _, anchor := range cs {
@@ -41,14 +145,193 @@ func lsAction() cli.ActionFunc {
 	}
 }
 
-func validatePath(path string) error {
+type lsOptions struct {
+	anchorutil.ListOptions
+	key string // name, mtime or size
+}
+
+func listOptions(c *cli.Context) (lsOptions, error) {
+	opts := lsOptions{
+		ListOptions: anchorutil.ListOptions{Prefix: c.String("prefix")},
+		key:         c.String("sort"),
+	}
+
+	switch opts.key {
+	case "name", "mtime", "size":
+	case "asc": // deprecated
+		opts.key = "name"
+	case "desc": // deprecated
+		opts.key = "name"
+		opts.Desc = true
+	default:
+		return opts, errors.Errorf("invalid sort key '%s'", opts.key)
+	}
+
+	opts.Desc = opts.Desc != c.Bool("reverse")
+	return opts, nil
+}
+
+// sort the entries by key.  Sizes sort in descending order, as in ls -S, and unknown
+// sizes sort last.  Desc reverses the order.
+func (opts lsOptions) sort(es []lsEntry) {
+	less := func(i, j int) bool { return es[i].Path < es[j].Path }
+	if opts.key == "size" {
+		less = func(i, j int) bool {
+			switch a, b := es[i].Size, es[j].Size; {
+			case a == nil || b == nil:
+				return a != nil
+			case *a != *b:
+				return *a > *b
+			}
+
+			return es[i].Path < es[j].Path
+		}
+	}
+
+	sort.SliceStable(es, func(i, j int) bool {
+		if opts.Desc {
+			return less(j, i)
+		}
+
+		return less(i, j)
+	})
+}
+
+// lsEntry is an anchor listed with -l.  Details that are not available are nil.
+type lsEntry struct {
+	Path     string     `json:"path"`
+	Size     *int       `json:"size"`
+	Modified *time.Time `json:"modified"` // not reported by hosts yet
+	Children *int       `json:"children"`
+	Proc     bool       `json:"proc"`
+
+	anchor ww.Anchor
+}
+
+// statConcurrency bounds the number of anchors that stat queries at once.
+const statConcurrency = 8
+
+// stat gathers the details of each anchor, concurrently.
+func stat(as []ww.Anchor) ([]lsEntry, error) {
+	var (
+		es   = make([]lsEntry, len(as))
+		errs = make([]error, len(as))
+		sem  = make(chan struct{}, statConcurrency)
+		wg   sync.WaitGroup
+	)
+
+	for i, a := range as {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int, a ww.Anchor) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			es[i], errs[i] = statOne(a)
+		}(i, a)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return es, nil
+}
+
+func statOne(a ww.Anchor) (e lsEntry, err error) {
+	e = lsEntry{Path: anchorpath.Join(a.Path()), anchor: a}
+
+	// children are counted as they are listed, without holding them
+	var n int
+	it := a.Iter(ctx)
+	for it.Next() {
+		it.Anchor().Release()
+		n++
+	}
+	it.Release()
+
+	if err = it.Err(); err != nil {
+		return
+	}
+
+	e.Children = &n
+
+	// hosts, i.e. the children of the root, hold no value
+	if len(a.Path()) < 2 {
+		return
+	}
+
+	v, err := a.Load(ctx)
+	if err != nil || core.IsNil(v) {
+		return
+	}
+
+	e.Proc = v.Value().Which() == mem.Any_Which_proc
+	if !e.Proc {
+		b, err := core.Canonical(v)
+		if err != nil {
+			return e, err
+		}
+
+		size := len(b)
+		e.Size = &size
+	}
+
+	return
+}
+
+func printLong(c *cli.Context, es []lsEntry) error {
+	if jsonOutput(c) {
+		return jsonEncoder(c.App.Writer, c.Bool("prettyprint")).Encode(es)
+	}
+
+	// every cell but the path is right-aligned, so the path is padded by hand
+	tw := tabwriter.NewWriter(c.App.Writer, 0, 4, 2, ' ', tabwriter.AlignRight)
+	for _, e := range es {
+		path := e.Path
+		if e.Children != nil && *e.Children > 0 {
+			path += "/"
+		}
+
+		if e.Proc {
+			path += marker("proc")
+		}
+
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t  %s\n",
+			optional(e.Size), "-", optional(e.Children), path)
+	}
+
+	return tw.Flush()
+}
+
+// optional formats n, or '-' if it is nil.
+func optional(n *int) string {
+	if n == nil {
+		return "-"
+	}
+
+	return strconv.Itoa(*n)
+}
+
+// cleanPath returns the cleaned, absolute path.
+func cleanPath(path string) (string, error) {
 	if path == "" {
-		return errors.New("must be a glob argument")
+		return "", errors.New("must specify a path or glob pattern")
 	}
 
 	if path[0] != '/' {
-		return errors.New("must specify absolute path")
+		return "", errors.New("must specify absolute path")
+	}
+
+	path, err := anchorpath.Clean(path)
+	if err != nil {
+		return "", err
 	}
 
-	return nil
+	return path, anchorpath.Validate(path)
 }
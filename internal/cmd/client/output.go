@@ -0,0 +1,108 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+	capnp "zombiezen.com/go/capnproto2"
+
+	ww "github.com/wetware/ww/pkg"
+)
+
+// exit codes that let scripts distinguish failures without parsing messages.
+const (
+	exitFailure      = 1
+	exitNotFound     = 2
+	exitNotPermitted = 3
+	exitConnection   = 4
+)
+
+var (
+	// errNotFound is returned when an anchor holds no value.
+	errNotFound = errors.New("not found")
+
+	// errConnection is returned when the cluster cannot be reached.
+	errConnection = errors.New("connection failed")
+)
+
+var outputFlag = &cli.StringFlag{
+	Name:    "output",
+	Aliases: []string{"o"},
+	Usage:   "output format (text, json)",
+	Value:   "text",
+	EnvVars: []string{"WW_OUTPUT"},
+}
+
+// jsonOutput returns true if the command should write JSON.
+func jsonOutput(c *cli.Context) bool { return c.String("output") == "json" }
+
+func validateOutput(c *cli.Context) error {
+	switch c.String("output") {
+	case "text", "json":
+		return nil
+	}
+
+	return errors.Errorf("invalid output format '%s'", c.String("output"))
+}
+
+// failure is the rendering of an error on stderr.
+type failure struct {
+	Message string `json:"error"`
+	Kind    string `json:"kind"`
+	Code    int    `json:"code"`
+}
+
+func classify(err error) failure {
+	f := failure{Message: err.Error(), Kind: "error", Code: exitFailure}
+
+	switch {
+	case errors.Is(err, errNotFound):
+		f.Kind, f.Code = "not_found", exitNotFound
+	case errors.Is(err, ww.ErrNotPermitted):
+		f.Kind, f.Code = "permission_denied", exitNotPermitted
+	case errors.Is(err, errConnection), disconnected(err):
+		f.Kind, f.Code = "connection", exitConnection
+	}
+
+	return f
+}
+
+// fail renders err on stderr, in the format given by --output, and returns an error
+// that exits with a status that classifies it.  It returns nil if err is nil.
+func fail(c *cli.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	f := classify(err)
+	if jsonOutput(c) {
+		_ = jsonEncoder(c.App.ErrWriter, false).Encode(f)
+	} else {
+		_, _ = fmt.Fprintf(c.App.ErrWriter, "error: %s\n", f.Message)
+	}
+
+	return cli.Exit("", f.Code) // already rendered
+}
+
+// rendered wraps the command's action so that its errors are rendered by fail.
+func rendered(cmd *cli.Command) *cli.Command {
+	action := cmd.Action
+	cmd.Action = func(c *cli.Context) error {
+		return fail(c, action(c))
+	}
+
+	return cmd
+}
+
+// disconnected reports whether err, or any error it wraps, indicates the loss of the
+// connection to the cluster.
+func disconnected(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if capnp.IsDisconnected(err) {
+			return true
+		}
+	}
+
+	return false
+}
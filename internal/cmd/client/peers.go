@@ -0,0 +1,276 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	"github.com/wetware/ww/pkg/runtime/svc/heartbeat"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
+)
+
+func peers() *cli.Command {
+	return &cli.Command{
+		Name:  "peers",
+		Usage: "list the members of the cluster",
+		Description: `List the hosts in the cluster, as reported by their heartbeats, with their
+addresses, the time since their last heartbeat, their uptime, and the number of peers
+to which they are connected (K).  Members are listed by last-seen, most recent first.
+With --output json, the snapshot is printed as a JSON array.
+
+Heartbeats are observed by the client, which waits up to --listen for one from each
+host known to the connected host.  If none is received, e.g. because the hosts do not
+publish heartbeats, the hosts known to the connected host are listed instead, without
+details, and a note that the view is partial is printed on stderr.
+
+With --watch, the list is redrawn every --interval until interrupted.`,
+		Flags:  peersFlags(),
+		Action: peersAction(),
+	}
+}
+
+func peersFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "full",
+			Usage: "print complete peer IDs",
+		},
+		&cli.DurationFlag{
+			Name:  "listen",
+			Usage: "maximum time to wait for the hosts' heartbeats",
+			Value: time.Second * 6,
+		},
+		&cli.BoolFlag{
+			Name:    "watch",
+			Aliases: []string{"w"},
+			Usage:   "redraw the list periodically",
+		},
+		&cli.DurationFlag{
+			Name:  "interval",
+			Usage: "time between redraws with --watch",
+			Value: time.Second * 2,
+		},
+	}
+}
+
+func peersAction() cli.ActionFunc {
+	return func(c *cli.Context) error {
+		hosts, err := knownHosts()
+		if err != nil {
+			return errors.Wrap(err, "error listing hosts")
+		}
+
+		view, err := root.Members(ctx)
+		if err != nil {
+			return err
+		}
+
+		p := peerPrinter{
+			c:     c,
+			full:  c.Bool("full"),
+			hosts: hosts,
+			view:  view,
+		}
+
+		p.wait(c.Duration("listen"))
+		if !c.Bool("watch") {
+			return p.print(c.App.Writer)
+		}
+
+		ticker := time.NewTicker(c.Duration("interval"))
+		defer ticker.Stop()
+
+		for {
+			if err = p.redraw(); err != nil {
+				return err
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// knownHosts returns the IDs of the hosts that the connected host knows to be in the
+// cluster.
+func knownHosts() ([]peer.ID, error) {
+	as, err := root.Ls(ctx)
+	defer anchorutil.Release(as)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]peer.ID, 0, len(as))
+	for _, a := range as {
+		if id, err := peer.Decode(a.Name()); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+type peerPrinter struct {
+	c     *cli.Context
+	full  bool
+	hosts []peer.ID
+	view  *heartbeat.View
+}
+
+// wait until a heartbeat has been received from each known host, or for d.
+func (p peerPrinter) wait(d time.Duration) {
+	deadline := time.After(d)
+	ticker := time.NewTicker(time.Millisecond * 100)
+	defer ticker.Stop()
+
+	for !p.seenAll() {
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p peerPrinter) seenAll() bool {
+	for _, id := range p.hosts {
+		if _, ok := p.view.Lookup(id); !ok {
+			return false
+		}
+	}
+
+	return len(p.hosts) > 0
+}
+
+// peerInfo is the rendering of a member.
+type peerInfo struct {
+	heartbeat.Member
+	Addrs []string `json:"addrs"`
+}
+
+// snapshot returns the members in order of last-seen, most recent first.  If no
+// heartbeat has been received, it returns the known hosts, and false.
+func (p peerPrinter) snapshot() ([]peerInfo, bool) {
+	ms := p.view.Members()
+
+	complete := len(ms) > 0
+	if !complete {
+		for _, id := range p.hosts {
+			ms = append(ms, heartbeat.Member{Heartbeat: heartbeat.Heartbeat{ID: id}})
+		}
+	}
+
+	sort.SliceStable(ms, func(i, j int) bool {
+		return ms[i].LastSeen.After(ms[j].LastSeen)
+	})
+
+	ps := make([]peerInfo, len(ms))
+	for i, m := range ms {
+		ps[i] = peerInfo{Member: m, Addrs: []string{}}
+		for _, a := range root.Addrs(m.ID) {
+			ps[i].Addrs = append(ps[i].Addrs, a.String())
+		}
+	}
+
+	return ps, complete
+}
+
+func (p peerPrinter) print(w io.Writer) error {
+	ps, complete := p.snapshot()
+	if !complete {
+		_, _ = fmt.Fprintln(p.c.App.ErrWriter,
+			"note: no heartbeats received; listing the hosts known to the connected host (partial view)")
+	}
+
+	if jsonOutput(p.c) {
+		return jsonEncoder(w, p.c.Bool("prettyprint")).Encode(ps)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ID\tADDRS\tLAST SEEN\tUPTIME\tK")
+
+	now := time.Now()
+	for _, info := range ps {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			p.id(info.ID),
+			dash(strings.Join(info.Addrs, ",")),
+			p.lastSeen(info.Member, now),
+			dash(duration(info.Uptime)),
+			dash(k(info.Member)))
+	}
+
+	return tw.Flush()
+}
+
+// redraw the list, clearing the screen first if stdout is a terminal, as watch(1)
+// does.  JSON snapshots are printed one per line instead.
+func (p peerPrinter) redraw() error {
+	w := p.c.App.Writer
+	if !jsonOutput(p.c) && w == os.Stdout && isTerminal(os.Stdout) {
+		_, _ = fmt.Fprintf(w, "\033[H\033[2JEvery %s: %s client peers\t%s\n\n",
+			p.c.Duration("interval"), p.c.App.HelpName, time.Now().Format(time.Stamp))
+	}
+
+	return p.print(w)
+}
+
+func (p peerPrinter) id(id peer.ID) string {
+	s := id.Pretty()
+	if p.full || len(s) <= 10 {
+		return s
+	}
+
+	return s[:2] + "*" + s[len(s)-6:] // as in peer.ID.ShortString
+}
+
+func (p peerPrinter) lastSeen(m heartbeat.Member, now time.Time) string {
+	if m.LastSeen.IsZero() {
+		return "-"
+	}
+
+	s := duration(now.Sub(m.LastSeen)) + " ago"
+	if m.Stale {
+		s += " (stale)"
+	}
+
+	return s
+}
+
+func duration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+
+	return d.Round(time.Second).String()
+}
+
+func k(m heartbeat.Member) string {
+	if m.LastSeen.IsZero() {
+		return ""
+	}
+
+	return fmt.Sprint(m.K)
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
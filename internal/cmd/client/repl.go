@@ -0,0 +1,87 @@
+package client
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/wetware/ww/internal/cmd/shell"
+)
+
+func repl() *cli.Command {
+	return &cli.Command{
+		Name:      "repl",
+		Usage:     "start a REPL session bound to the cluster",
+		ArgsUsage: "[file]",
+		Description: `Start an interactive read-eval-print loop, in which the root anchor
+is bound to the cluster.  Incomplete forms continue on the next line, and Ctrl-C
+interrupts the evaluation in progress, or discards the incomplete form.  History is
+saved in $XDG_DATA_HOME/ww/history.
+
+With --eval, the forms in the expression are evaluated and their results printed,
+without prompting.  If a file is given, its forms are evaluated without prompting,
+and their results are not printed.  In either case, evaluation stops at the first
+error, which is reported with its position.`,
+		Flags:  replFlags(),
+		Action: replAction(),
+	}
+}
+
+func replFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "eval",
+			Aliases: []string{"e"},
+			Usage:   "evaluate `EXPR` and exit",
+		},
+		&cli.BoolFlag{
+			Name:    "quiet",
+			Aliases: []string{"q"},
+			Usage:   "suppress banner message on interactive startup",
+			EnvVars: []string{"WW_QUIET"},
+		},
+		&cli.DurationFlag{
+			Name:    "eval-timeout",
+			Usage:   "timeout for each evaluation (0 = none)",
+			EnvVars: []string{"WW_EVAL_TIMEOUT"},
+		},
+		shell.PathFlag(),
+
+		// debug flags (hidden)
+		&cli.BoolFlag{
+			Name:   "log-fx",
+			Usage:  "output fx dependency injection logs",
+			Hidden: true,
+		},
+	}
+}
+
+func replAction() cli.ActionFunc {
+	return func(c *cli.Context) error {
+		if c.NArg() > 1 || (c.NArg() == 1 && c.IsSet("eval")) {
+			return errors.New("expected either --eval or at most one file")
+		}
+
+		switch {
+		case c.IsSet("eval"):
+			return shell.Run(c, root, shell.Script{
+				File: "<eval>",
+				Src:  strings.NewReader(c.String("eval")),
+				Echo: true,
+			})
+
+		case c.NArg() == 1:
+			f, err := os.Open(c.Args().First())
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			return shell.Run(c, root, shell.Script{File: f.Name(), Src: f})
+		}
+
+		return shell.Serve(c, root)
+	}
+}
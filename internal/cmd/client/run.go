@@ -0,0 +1,133 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/wetware/ww/internal/cmd/shell"
+	"github.com/wetware/ww/pkg/lang"
+)
+
+// runCmd does not dial the cluster when passed --check.
+const runCmd = "run"
+
+func run() *cli.Command {
+	return &cli.Command{
+		Name:      runCmd,
+		Usage:     "execute a wetware script against the cluster",
+		ArgsUsage: "script [args...]",
+		Description: `Read the script, and evaluate its forms in order, with the root anchor
+bound to the cluster.  The remaining arguments are bound to *args*, as a vector of
+strings.  Scripts may read forms from stdin with (read), and write to stdout with
+(print) and (println), so that they compose in shell pipelines.
+
+The script is read in full before it is evaluated, so a syntax error is reported
+before any form has been evaluated.  Evaluation stops at the first error, which is
+reported as file:line:col, and the command exits with a non-zero status.
+
+With --check, the script is checked for undefined and unused symbols instead, without
+connecting to the cluster.  Each problem is reported as file:line:col, and the
+command exits with a non-zero status if there are any.`,
+		Flags:  runFlags(),
+		Action: runAction(),
+	}
+}
+
+func runFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "timeout for the evaluation of the whole script (0 = none)",
+		},
+		&cli.DurationFlag{
+			Name:    "eval-timeout",
+			Usage:   "timeout for each top-level form (0 = none)",
+			EnvVars: []string{"WW_EVAL_TIMEOUT"},
+		},
+		&cli.Int64Flag{
+			Name:  "max-steps",
+			Usage: "maximum number of function calls in each top-level form (0 = unlimited)",
+		},
+		&cli.Int64Flag{
+			Name:  "max-bytes",
+			Usage: "estimated maximum bytes allocated by each top-level form (0 = unlimited)",
+		},
+		shell.PathFlag(),
+		&cli.BoolFlag{
+			Name:  "check",
+			Usage: "report undefined and unused symbols without evaluating the script",
+		},
+	}
+}
+
+func runAction() cli.ActionFunc {
+	return func(c *cli.Context) error {
+		if c.NArg() == 0 {
+			return errors.New("must specify a script")
+		}
+
+		f, err := os.Open(c.Args().First())
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if c.Bool("check") {
+			return check(c, f)
+		}
+
+		return shell.Run(c, root, shell.Script{
+			File:    f.Name(),
+			Src:     f,
+			Args:    c.Args().Tail(),
+			Timeout: c.Duration("timeout"),
+			Budget: &lang.EvalBudget{
+				MaxSteps: c.Int64("max-steps"),
+				MaxBytes: c.Int64("max-bytes"),
+			},
+		})
+	}
+}
+
+func check(c *cli.Context, f *os.File) error {
+	ws, err := shell.Check(c, shell.Script{
+		File: f.Name(),
+		Src:  f,
+		Args: c.Args().Tail(),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, w := range ws {
+		if _, err = fmt.Fprintln(c.App.Writer, w); err != nil {
+			return err
+		}
+	}
+
+	if len(ws) > 0 {
+		return fmt.Errorf("%d warning(s)", len(ws))
+	}
+
+	return nil
+}
+
+// checkOnly returns true if the arguments to the run command include --check.
+func checkOnly(args []string) bool {
+	for _, arg := range args {
+		switch arg {
+		case "--check", "-check", "--check=true", "-check=true":
+			return true
+		}
+
+		if !strings.HasPrefix(arg, "-") {
+			break // script name; the remaining args are bound to *args*
+		}
+	}
+
+	return false
+}
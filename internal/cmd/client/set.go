@@ -0,0 +1,103 @@
+package client
+
+import (
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+	capnp "zombiezen.com/go/capnproto2"
+
+	ww "github.com/wetware/ww/pkg"
+	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
+)
+
+func set() *cli.Command {
+	return &cli.Command{
+		Name:      "set",
+		Usage:     "store a value at an anchor",
+		ArgsUsage: "path [value]",
+		Description: `Store a value at the anchor at path.  The value is read with the
+wetware reader, so strings, numbers, keywords, vectors and lists are supported,
+e.g. '[1 :two "three"]'.  If the value is omitted, it is read from stdin in the
+format given by --stdin-format:
+
+    edn   a single form, as for the value argument (default)
+    json  a JSON document; arrays become vectors, null becomes nil, and
+          objects are not supported, except for the typed values written
+          by 'get --format json --tagged', with --tagged
+    raw   the bytes of stdin, up to --max-size, stored as a string; see
+          'get --raw'
+
+The value is parsed before the anchor is contacted.  Fails if the anchor already
+holds a value.`,
+		Flags:  setFlags(),
+		Action: setAction(),
+	}
+}
+
+func setFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "stdin-format",
+			Usage: "format of the value read from stdin (edn, json, raw)",
+			Value: "edn",
+		},
+		&cli.BoolFlag{
+			Name:  "tagged",
+			Usage: "read typed values from JSON on stdin; see 'get --tagged'",
+		},
+		&cli.Int64Flag{
+			Name:  "max-size",
+			Usage: "maximum size of a raw value read from stdin, in bytes (0 = none)",
+			Value: 64 << 20, // capnp's default traversal limit
+		},
+	}
+}
+
+func setAction() cli.ActionFunc {
+	return func(c *cli.Context) error {
+		path, err := cleanPath(c.Args().First())
+		if err != nil {
+			return errors.Wrap(err, "invalid path")
+		}
+
+		parts := anchorpath.Parts(path)
+		if anchorpath.IsGlob(parts) {
+			return errors.New("cannot set a glob pattern")
+		}
+
+		v, err := value(c)
+		if err != nil {
+			return errors.Wrap(err, "invalid value")
+		}
+
+		a := root.Walk(ctx, anchorpath.Unescape(parts))
+		defer a.Release()
+
+		return errors.Wrap(a.Store(ctx, v), "error storing value")
+	}
+}
+
+// value from the command's second argument, or from stdin.
+func value(c *cli.Context) (ww.Any, error) {
+	switch c.NArg() {
+	case 1:
+	case 2:
+		return parse(strings.NewReader(c.Args().Get(1)))
+	default:
+		return nil, errors.New("expected a path and at most one value")
+	}
+
+	var stdin io.Reader = c.App.Reader
+	switch format := c.String("stdin-format"); format {
+	case "edn":
+		return parse(stdin)
+	case "json":
+		return jsonCodec(c).Decode(stdin, capnp.SingleSegment(nil))
+	case "raw":
+		return readRaw(stdin, c.Int64("max-size"))
+	default:
+		return nil, errors.Errorf("invalid stdin format '%s'", format)
+	}
+}
@@ -0,0 +1,194 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
+	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
+)
+
+func tree() *cli.Command {
+	return &cli.Command{
+		Name:      "tree",
+		Usage:     "list an anchor's subtree",
+		ArgsUsage: "path",
+		Description: `List the anchors below path as an indented tree, in order of name.
+Anchors that hold a value are marked [value], and anchors that hold a process are
+marked [proc].  With --flat, the absolute path of each anchor is printed on its
+own line, without markers, e.g. for use with xargs.  With --output json, each
+anchor is printed as a JSON object on its own line, e.g.
+
+    {"path":"/host/a","holds":"value"}
+
+where holds is omitted if the anchor is empty.
+
+The subtree is listed one level at a time, and each anchor is printed as soon as
+it is listed, so output begins before large subtrees have been traversed.`,
+		Flags:  treeFlags(),
+		Action: treeAction(),
+	}
+}
+
+func treeFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "flat",
+			Usage: "print one absolute path per line",
+		},
+		&cli.IntFlag{
+			Name:  "depth",
+			Usage: "maximum depth below path (0 = unlimited)",
+		},
+	}
+}
+
+func treeAction() cli.ActionFunc {
+	return func(c *cli.Context) error {
+		path, err := cleanPath(c.Args().First())
+		if err != nil {
+			return errors.Wrap(err, "invalid path")
+		}
+
+		parts := anchorpath.Parts(path)
+		if anchorpath.IsGlob(parts) {
+			return errors.New("cannot list the subtree of a glob pattern")
+		}
+
+		if c.Int("depth") < 0 {
+			return errors.New("depth must not be negative")
+		}
+
+		a := root.Walk(ctx, anchorpath.Unescape(parts))
+		defer a.Release()
+
+		t := treePrinter{w: c.App.Writer, flat: c.Bool("flat"), depth: c.Int("depth")}
+		if jsonOutput(c) {
+			t.enc = jsonEncoder(c.App.Writer, false)
+		}
+
+		if err = t.root(a, path); err == nil {
+			err = t.print(a, "", 1)
+		}
+
+		return errors.Wrap(err, "error listing anchor")
+	}
+}
+
+type treePrinter struct {
+	w     io.Writer
+	enc   *json.Encoder // nil unless --output json
+	flat  bool
+	depth int
+}
+
+type treeEntry struct {
+	Path  string `json:"path"`
+	Holds string `json:"holds,omitempty"`
+}
+
+// root prints the anchor at the root of the tree.
+func (t treePrinter) root(a ww.Anchor, path string) error {
+	if t.enc != nil {
+		return t.entry(a)
+	}
+
+	if !t.flat {
+		holds, err := t.holds(a)
+		if err != nil {
+			return err
+		}
+
+		_, _ = fmt.Fprintln(t.w, path+marker(holds))
+	}
+
+	return nil
+}
+
+func (t treePrinter) entry(a ww.Anchor) error {
+	holds, err := t.holds(a)
+	if err != nil {
+		return err
+	}
+
+	return t.enc.Encode(treeEntry{Path: anchorpath.Join(a.Path()), Holds: holds})
+}
+
+// print the children of a at the given level, and their subtrees, depth-first.
+func (t treePrinter) print(a ww.Anchor, indent string, level int) error {
+	if t.depth > 0 && level > t.depth {
+		return nil
+	}
+
+	cs, err := anchorutil.List(ctx, a, anchorutil.ListOptions{})
+	if err != nil {
+		return err
+	}
+	defer anchorutil.Release(cs)
+
+	for i, child := range cs {
+		branch, next := "├── ", "│   "
+		if i == len(cs)-1 {
+			branch, next = "└── ", "    "
+		}
+
+		switch {
+		case t.enc != nil:
+			if err = t.entry(child); err != nil {
+				return err
+			}
+
+		case t.flat:
+			_, _ = fmt.Fprintln(t.w, anchorpath.Join(child.Path()))
+
+		default:
+			holds, err := t.holds(child)
+			if err != nil {
+				return err
+			}
+
+			_, _ = fmt.Fprintln(t.w, indent+branch+child.Name()+marker(holds))
+		}
+
+		if err = t.print(child, indent+next, level+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// holds describes the contents of the anchor: "value", "proc", or the empty string if
+// the anchor is empty.  Hosts, i.e. the children of the root, hold no value.
+func (t treePrinter) holds(a ww.Anchor) (string, error) {
+	if len(a.Path()) < 2 {
+		return "", nil
+	}
+
+	v, err := a.Load(ctx)
+	switch {
+	case err != nil:
+		return "", err
+	case core.IsNil(v):
+		return "", nil
+	case v.Value().Which() == mem.Any_Which_proc:
+		return "proc", nil
+	default:
+		return "value", nil
+	}
+}
+
+func marker(holds string) string {
+	if holds == "" {
+		return ""
+	}
+
+	return " [" + holds + "]"
+}
@@ -0,0 +1,164 @@
+// Package completion contains the `ww completion` command implementation.
+package completion
+
+import (
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+var descr = `Print a script that completes commands and flags in the given shell,
+e.g. for bash:
+
+	source <(ww completion bash)
+
+The anchor path arguments of 'ww client ls', 'get', 'set' and 'tree' are completed
+with the children of the parent path, which are listed by a host.  The host is
+reached with the client flags on the command line, or their environment variables.
+If the host cannot be reached within a second, or does not list the parent within
+300ms, no paths are suggested.`
+
+// scripts call the hidden 'ww client __complete-path' command to list anchors.  The
+// words up to the client subcommand are passed on, so that the client flags apply.
+var scripts = map[string]*template.Template{
+	"bash": template.Must(template.New("bash").Parse(bash)),
+	"zsh":  template.Must(template.New("zsh").Parse(zsh)),
+	"fish": template.Must(template.New("fish").Parse(fish)),
+}
+
+// Command constructor
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:        "completion",
+		Usage:       "print a shell completion script",
+		ArgsUsage:   "bash|zsh|fish",
+		Description: descr,
+		Action:      run(),
+	}
+}
+
+func run() cli.ActionFunc {
+	return func(c *cli.Context) error {
+		shell := c.Args().First()
+
+		t, ok := scripts[shell]
+		if !ok {
+			return errors.Errorf("unsupported shell '%s'", shell)
+		}
+
+		prog := c.App.HelpName
+		data := struct{ Prog, Func, Commands string }{
+			Prog: prog,
+			Func: regexp.MustCompile(`\W`).ReplaceAllString(prog, "_"),
+		}
+
+		if shell == "fish" {
+			// The generated completions are named after the app; name them after the
+			// program instead.
+			app := *c.App
+			app.Name = prog
+
+			var err error
+			if data.Commands, err = app.ToFishCompletion(); err != nil {
+				return err
+			}
+		}
+
+		var b strings.Builder
+		if err := t.Execute(&b, data); err != nil {
+			return err
+		}
+
+		_, err := c.App.Writer.Write([]byte(b.String()))
+		return err
+	}
+}
+
+const bash = `# bash completion for {{.Prog}}
+
+_{{.Func}}_complete() {
+	local cur="${COMP_WORDS[COMP_CWORD]}" i client=0 sub=0
+	COMPREPLY=()
+
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		case "${COMP_WORDS[i]}" in
+		client) ((client)) || client=$i ;;
+		ls|get|set|tree) ((client && !sub)) && sub=$i ;;
+		esac
+	done
+
+	if ((sub)) && [[ "$cur" != -* ]]; then
+		local path
+		while IFS= read -r path; do
+			COMPREPLY+=("$(printf '%q' "$path")")
+		done < <("${COMP_WORDS[@]:0:sub}" __complete-path "$cur" 2>/dev/null)
+		return 0
+	fi
+
+	local opts
+	if [[ "$cur" == -* ]]; then
+		opts=$("${COMP_WORDS[@]:0:COMP_CWORD}" "$cur" --generate-bash-completion 2>/dev/null)
+	else
+		opts=$("${COMP_WORDS[@]:0:COMP_CWORD}" --generate-bash-completion 2>/dev/null)
+	fi
+	COMPREPLY=($(compgen -W "$opts" -- "$cur"))
+}
+
+complete -o bashdefault -o default -F _{{.Func}}_complete {{.Prog}}
+`
+
+const zsh = `#compdef {{.Prog}}
+
+_{{.Func}}_complete() {
+	local -a opts
+	local cur=${(Q)words[CURRENT]} i client=0 sub=0
+
+	for ((i = 2; i < CURRENT; i++)); do
+		case ${words[i]} in
+		client) ((client)) || client=$i ;;
+		ls|get|set|tree) ((client && !sub)) && sub=$i ;;
+		esac
+	done
+
+	if ((sub)) && [[ $cur != -* ]]; then
+		opts=("${(@f)$(${(Q)words[1,sub-1]} __complete-path "$cur" 2>/dev/null)}")
+		compadd -- ${opts:#}
+		return
+	fi
+
+	if [[ $cur == -* ]]; then
+		opts=("${(@f)$(_CLI_ZSH_AUTOCOMPLETE_HACK=1 ${(Q)words[1,CURRENT-1]} $cur --generate-bash-completion 2>/dev/null)}")
+	else
+		opts=("${(@f)$(_CLI_ZSH_AUTOCOMPLETE_HACK=1 ${(Q)words[1,CURRENT-1]} --generate-bash-completion 2>/dev/null)}")
+	fi
+
+	if [[ -n ${opts[1]} ]]; then
+		_describe 'values' opts
+	else
+		_files
+	fi
+}
+
+compdef _{{.Func}}_complete {{.Prog}}
+`
+
+const fish = `# fish completion for {{.Prog}}
+
+{{.Commands}}
+
+function __{{.Func}}_complete_path
+	set -l tokens (commandline -opc)
+	set -l client (contains -i -- client $tokens); or return
+	for i in (seq (math $client + 1) (count $tokens))
+		if contains -- $tokens[$i] ls get set tree
+			command $tokens[1..(math $i - 1)] __complete-path (commandline -ct | string unescape) 2>/dev/null
+			return
+		end
+	end
+end
+
+complete -c {{.Prog}} -n '__fish_seen_subcommand_from ls get set tree' -f -a '(__{{.Func}}_complete_path)'
+`
@@ -0,0 +1,76 @@
+// Package dev contains the `ww dev` command implementation.
+package dev
+
+import (
+	"context"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/wetware/ww/internal/cmd/shell"
+	ctxutil "github.com/wetware/ww/internal/util/ctx"
+	logutil "github.com/wetware/ww/internal/util/log"
+	"github.com/wetware/ww/pkg/client"
+)
+
+var flags = []cli.Flag{
+	&cli.BoolFlag{
+		Name:    "quiet",
+		Aliases: []string{"q"},
+		Usage:   "suppress banner message on interactive startup",
+		EnvVars: []string{"WW_QUIET"},
+	},
+	&cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "timeout for host startup",
+		Value: time.Second * 10,
+	},
+	&cli.StringSliceFlag{
+		Name:    "listen",
+		Aliases: []string{"l"},
+		Usage:   "host listen address",
+		Value:   cli.NewStringSlice("/ip4/127.0.0.1/tcp/0"),
+		EnvVars: []string{"WW_LISTEN"},
+	},
+	&cli.DurationFlag{
+		Name:    "eval-timeout",
+		Usage:   "timeout for each evaluation (0 = none)",
+		EnvVars: []string{"WW_EVAL_TIMEOUT"},
+	},
+	shell.PathFlag(),
+
+	// debug flags (hidden)
+	&cli.BoolFlag{
+		Name:   "log-fx",
+		Usage:  "output fx dependency injection logs",
+		Hidden: true,
+	},
+}
+
+// Command constructor
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:   "dev",
+		Usage:  "start a single-node cluster and attach a REPL session",
+		Flags:  flags,
+		Action: run(),
+	}
+}
+
+func run() cli.ActionFunc {
+	return func(c *cli.Context) error {
+		ctx := ctxutil.WithDefaultSignals(context.Background())
+		ctx, cancel := context.WithTimeout(ctx, c.Duration("timeout"))
+		defer cancel()
+
+		root, err := client.Embedded(ctx,
+			client.WithLogger(logutil.New(c)),
+			client.WithEmbeddedListenAddr(c.StringSlice("listen")...))
+		if err != nil {
+			return err
+		}
+		defer root.Close()
+
+		return shell.Serve(c, root)
+	}
+}
@@ -0,0 +1,70 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/wetware/ww/internal/cmd/shell"
+	"github.com/wetware/ww/pkg/lang"
+)
+
+var flags = []cli.Flag{
+	shell.PathFlag(),
+}
+
+// Command constructor
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:      "lint",
+		Usage:     "report undefined and unused symbols in wetware scripts",
+		ArgsUsage: "script...",
+		Description: `Check each script for references to undefined symbols, and for function
+parameters and if-let bindings that are never used.  Scripts are not evaluated, and
+no connection to the cluster is needed.  Each problem is reported on stdout as
+file:line:col, and the command exits with a non-zero status if there are any.`,
+		Flags:  flags,
+		Action: run(),
+	}
+}
+
+func run() cli.ActionFunc {
+	return func(c *cli.Context) error {
+		if c.NArg() == 0 {
+			return cli.Exit("must specify at least one script", 1)
+		}
+
+		var n int
+		for _, path := range c.Args().Slice() {
+			ws, err := lint(c, path)
+			if err != nil {
+				return err
+			}
+
+			for _, w := range ws {
+				if _, err = fmt.Fprintln(c.App.Writer, w); err != nil {
+					return err
+				}
+			}
+
+			n += len(ws)
+		}
+
+		if n > 0 {
+			return cli.Exit("", 1)
+		}
+
+		return nil
+	}
+}
+
+func lint(c *cli.Context, path string) ([]lang.Warning, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return shell.Check(c, shell.Script{File: f.Name(), Src: f})
+}
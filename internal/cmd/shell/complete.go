@@ -0,0 +1,93 @@
+package shell
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/chzyer/readline"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
+	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
+)
+
+// completionTimeout bounds the listing of an anchor's children, so that tab completion
+// stays responsive when the cluster is slow or unreachable.
+const completionTimeout = time.Millisecond * 300
+
+// delimiters end the word being completed.
+const delimiters = " \t\n()[]{}'`~@\","
+
+func newCompleter(eval evaluator, root ww.Anchor) readline.AutoCompleter {
+	return completer{vm: eval.vm, root: root}
+}
+
+// completer completes the word before the cursor.  Paths are completed against the
+// children of the anchor they name, and other words against the symbols bound in the
+// VM's env.
+type completer struct {
+	vm   *lang.VM
+	root ww.Anchor
+}
+
+// Do returns the suffixes that complete the word before pos, and the word's length.
+func (c completer) Do(line []rune, pos int) ([][]rune, int) {
+	start := pos
+	for start > 0 && !strings.ContainsRune(delimiters, line[start-1]) {
+		start--
+	}
+
+	word := string(line[start:pos])
+	if word == "" {
+		return nil, 0
+	}
+
+	var cs []string
+	if strings.HasPrefix(word, "/") {
+		cs = c.paths(word)
+	} else {
+		for _, b := range lang.Complete(c.vm.Env(), word) {
+			cs = append(cs, b.Name)
+		}
+	}
+
+	suffixes := make([][]rune, 0, len(cs))
+	for _, s := range cs {
+		if strings.HasPrefix(s, word) {
+			suffixes = append(suffixes, []rune(s[len(word):]))
+		}
+	}
+
+	return suffixes, len([]rune(word))
+}
+
+// paths returns the paths of the children of the word's parent that begin with its
+// last segment.  Failures return nothing, so that completion degrades silently when
+// the cluster is unreachable.
+func (c completer) paths(word string) []string {
+	i := strings.LastIndex(word, "/")
+	dir, prefix := word[:i+1], word[i+1:]
+
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+
+	a := c.root.Walk(ctx, anchorpath.Unescape(anchorpath.Parts(dir)))
+	defer a.Release()
+
+	prefixes := anchorpath.Unescape([]string{prefix})
+	children, err := anchorutil.List(ctx, a, anchorutil.ListOptions{Prefix: prefixes[0]})
+	defer anchorutil.Release(children)
+
+	if err != nil {
+		return nil
+	}
+
+	ps := make([]string, len(children))
+	for i, child := range children {
+		ps[i] = dir + anchorpath.Escape(child.Name())
+	}
+
+	return ps
+}
@@ -0,0 +1,134 @@
+package shell
+
+import (
+	"context"
+	"io"
+	"time"
+
+	score "github.com/spy16/slurp/core"
+	"github.com/urfave/cli/v2"
+	capnp "zombiezen.com/go/capnproto2"
+
+	logutil "github.com/wetware/ww/internal/util/log"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang"
+	"github.com/wetware/ww/pkg/lang/core"
+)
+
+// Script is evaluated without prompting.
+type Script struct {
+	File string
+	Src  io.Reader
+
+	// Args are bound to *args*, as a vector of strings.
+	Args []string
+
+	// Echo prints the result of each form.
+	Echo bool
+
+	// Timeout bounds the evaluation of the whole script.  Zero means no timeout.
+	Timeout time.Duration
+
+	// Budget bounds the evaluation of each top-level form.  Nil means unlimited.
+	Budget *lang.EvalBudget
+}
+
+// Run the script against the supplied root anchor.  The script is read in full before
+// it is evaluated, so that syntax errors are reported before any form has been
+// evaluated.  Evaluation stops at the first error, which is reported at the position
+// of the top-level form that raised it.  The CLI context must define the 'path' and
+// 'eval-timeout' flags.
+func Run(c *cli.Context, root ww.Anchor, s Script) error {
+	forms, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	paths, err := newPaths(c, logutil.New(c))
+	if err != nil {
+		return err
+	}
+
+	eval, err := newVM(c, root, paths)
+	if err != nil {
+		return err
+	}
+
+	if err = s.bindArgs(eval); err != nil {
+		return err
+	}
+
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		eval.ctx, cancel = context.WithTimeout(eval.ctx, s.Timeout)
+		defer cancel()
+	}
+
+	if s.Budget != nil {
+		eval.ctx = lang.WithBudget(eval.ctx, s.Budget)
+	}
+
+	p := newPrinter(c)
+	for _, f := range forms {
+		res, err := eval.EvalSource(f)
+		if err != nil {
+			return err // reports the form's position
+		}
+
+		if s.Echo {
+			if err = p.Fprintln(c.App.Writer, res); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s Script) read() ([]lang.SourceForm, error) {
+	return lang.ReadSource(s.File, s.Src)
+}
+
+// Check the script for undefined and unused symbols, without evaluating it.  See
+// lang.Check.  The script is checked against a VM that is not connected to a cluster,
+// so that checking does not require a connection.  The CLI context must define the
+// 'path' and 'eval-timeout' flags.
+func Check(c *cli.Context, s Script) ([]lang.Warning, error) {
+	forms, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := newPaths(c, logutil.New(c))
+	if err != nil {
+		return nil, err
+	}
+
+	eval, err := newVM(c, nil, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.bindArgs(eval); err != nil {
+		return nil, err
+	}
+
+	return lang.Check(eval.vm.Env(), forms), nil
+}
+
+func (s Script) bindArgs(eval evaluator) error {
+	args := make([]ww.Any, len(s.Args))
+	for i, arg := range s.Args {
+		var err error
+		if args[i], err = core.NewString(capnp.SingleSegment(nil), arg); err != nil {
+			return err
+		}
+	}
+
+	vec, err := core.NewVector(capnp.SingleSegment(nil), args...)
+	if err != nil {
+		return err
+	}
+
+	return eval.vm.Bind(map[string]score.Any{"*args*": vec})
+}
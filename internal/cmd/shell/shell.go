@@ -7,6 +7,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"os/signal"
 	"os/user"
 	"path/filepath"
 	"runtime"
@@ -15,6 +17,7 @@ import (
 	"time"
 
 	"github.com/chzyer/readline"
+	score "github.com/spy16/slurp/core"
 	"github.com/spy16/slurp/repl"
 	"github.com/urfave/cli/v2"
 	"go.uber.org/fx"
@@ -22,10 +25,13 @@ import (
 	clientutil "github.com/wetware/ww/internal/util/client"
 	ctxutil "github.com/wetware/ww/internal/util/ctx"
 	logutil "github.com/wetware/ww/internal/util/log"
+	printutil "github.com/wetware/ww/internal/util/print"
 	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/client"
 	"github.com/wetware/ww/pkg/lang"
 	"github.com/wetware/ww/pkg/lang/core"
 	"github.com/wetware/ww/pkg/lang/reader"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
 	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
 )
 
@@ -56,6 +62,11 @@ var (
 			Value:   "ww",
 			EnvVars: []string{"WW_NAMESPACE"},
 		},
+		&cli.StringFlag{
+			Name:    "token",
+			Usage:   "authenticate to the host with `TOKEN`",
+			EnvVars: []string{"WW_TOKEN"},
+		},
 		&cli.BoolFlag{
 			Name:    "quiet",
 			Aliases: []string{"q"},
@@ -72,12 +83,12 @@ var (
 			Usage: "timeout for -dial",
 			Value: time.Second * 10,
 		},
-		&cli.StringSliceFlag{
-			Name:    "path",
-			Usage:   "location of ww source files",
-			Value:   cli.NewStringSlice("~/.ww"),
-			EnvVars: []string{"WW_PATH"},
+		&cli.DurationFlag{
+			Name:    "eval-timeout",
+			Usage:   "timeout for each evaluation (0 = none)",
+			EnvVars: []string{"WW_EVAL_TIMEOUT"},
 		},
+		PathFlag(),
 
 		// debug flags (hidden)
 		&cli.BoolFlag{
@@ -100,30 +111,43 @@ func Command() *cli.Command {
 
 func run() cli.ActionFunc {
 	return func(c *cli.Context) error {
-		ctx, cancel := context.WithCancel(context.Background())
-		defer cancel()
-
-		app := fx.New(fxLogger(c),
-			fx.Supply(c,
-				prompt{Standard: "ww »", Multiline: "   ›"}),
-			fx.Provide(
-				newPaths,
-				newInput,
-				newBanner,
-				newWriter,
-				newPrinter,
-				logutil.New,
-				newEvaluator,
-				newRootAnchor,
-				newReaderFactory),
-			fx.Invoke(loop))
-
-		if err := app.Start(ctx); err != nil {
-			return err
-		}
+		return serve(c, fx.Provide(newRootAnchor))
+	}
+}
+
+// Serve an interactive REPL session that evaluates forms against the supplied root
+// anchor.  The CLI context must define the 'quiet', 'path', 'eval-timeout' and
+// 'log-fx' flags.
+func Serve(c *cli.Context, root ww.Anchor) error {
+	return serve(c, fx.Provide(func() ww.Anchor { return root }))
+}
+
+func serve(c *cli.Context, root fx.Option) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-		return app.Stop(ctx)
+	app := fx.New(fxLogger(c),
+		fx.Supply(c,
+			prompt{Standard: "ww »", Multiline: "   ›"}),
+		fx.Provide(
+			newPaths,
+			newInput,
+			newBanner,
+			newWriter,
+			newPrinter,
+			logutil.New,
+			newVM,
+			newEvaluator,
+			newCompleter,
+			newStream),
+		root,
+		fx.Invoke(loop))
+
+	if err := app.Start(ctx); err != nil {
+		return err
 	}
+
+	return app.Stop(ctx)
 }
 
 func loop(f replFactory) error {
@@ -141,22 +165,124 @@ type replFactory struct {
 	Prompt    string `name:"prompt"`
 	Multiline string `name:"multiline"`
 	Stdout    io.Writer
-	NewReader repl.ReaderFactory
+	Stream    *reader.Stream
 	Input     repl.Input
 	Printer   repl.Printer
 }
 
-func (f replFactory) New() *repl.REPL {
-	return repl.New(f.Eval,
-		repl.WithBanner(f.Banner),
-		repl.WithReaderFactory(f.NewReader),
-		repl.WithPrompts(f.Prompt, f.Multiline),
-		repl.WithInput(f.Input, nil),
-		repl.WithOutput(f.Stdout),
-		repl.WithPrinter(f.Printer),
-	)
+func (f replFactory) New() *shell {
+	return &shell{
+		eval:      f.Eval,
+		banner:    f.Banner,
+		prompt:    f.Prompt,
+		multiline: f.Multiline,
+		out:       f.Stdout,
+		stream:    f.Stream,
+		in:        f.Input,
+		printer:   f.Printer,
+	}
+}
+
+// shell is the read-eval-print loop.  Each line of input is fed to a reader stream,
+// and the complete forms are evaluated.  While a form is incomplete, the shell shows
+// the multiline prompt and keeps reading, so that multi-line forms can be typed or
+// pasted.  Pressing Ctrl-C discards the incomplete form.
+type shell struct {
+	eval              repl.Evaluator
+	banner            string
+	prompt, multiline string
+	out               io.Writer
+	stream            *reader.Stream
+	in                repl.Input
+	printer           repl.Printer
+}
+
+// Loop runs until the context expires or the input is exhausted.
+func (sh *shell) Loop(ctx context.Context) error {
+	if sh.banner != "" {
+		fmt.Fprintln(sh.out, sh.banner)
+	}
+
+	var incomplete bool
+	for ctx.Err() == nil {
+		sh.setPrompt(incomplete)
+
+		line, err := sh.in.Readline()
+		if err == readline.ErrInterrupt {
+			sh.stream.Reset()
+			incomplete = false
+			continue
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		sh.stream.Feed(line + "\n")
+		if incomplete, err = sh.evalAll(); err != nil {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
+
+// evalAll evaluates the complete forms read from the stream, and reports whether
+// the input ends inside a form.  Evaluation stops at the first error, and the rest
+// of the input is discarded.
+func (sh *shell) evalAll() (bool, error) {
+	for {
+		form, err := sh.stream.One()
+		if err == io.EOF {
+			return false, nil
+		}
+
+		if errors.Is(err, reader.ErrIncomplete) {
+			return true, nil
+		}
+
+		var res score.Any
+		if err == nil {
+			res, err = sh.eval.Eval(form)
+		}
+
+		if err != nil {
+			sh.stream.Reset()
+			return false, sh.printer.Fprintln(sh.out, err)
+		}
+
+		if err = sh.printer.Fprintln(sh.out, res); err != nil {
+			return false, err
+		}
+	}
+}
+
+func (sh *shell) setPrompt(multiline bool) {
+	if multiline {
+		sh.in.SetPrompt(sh.multiline + " ")
+	} else {
+		sh.in.SetPrompt(sh.prompt + " ")
+	}
+}
+
+// PathFlag returns the 'path' flag, which lists the directories that are searched for
+// source files.  Commands that evaluate or check source files share it.
+func PathFlag() cli.Flag {
+	return &cli.StringSliceFlag{
+		Name:    "path",
+		Usage:   "location of ww source files",
+		Value:   cli.NewStringSlice(defaultPath),
+		EnvVars: []string{"WW_PATH"},
+	}
 }
 
+// defaultPath is ignored if it does not exist, unless it is set explicitly.
+const defaultPath = "~/.ww"
+
 func newPaths(c *cli.Context, log ww.Logger) ([]string, error) {
 	paths := c.StringSlice("path")
 	log.WithField("paths", paths).Debug("resolving source paths")
@@ -166,13 +292,21 @@ func newPaths(c *cli.Context, log ww.Logger) ([]string, error) {
 		return nil, err
 	}
 
-	ps := make([]string, len(paths))
-	for i, p := range paths {
+	ps := make([]string, 0, len(paths))
+	for _, p := range paths {
+		explicit := c.IsSet("path") || p != defaultPath
+
 		if p[0] == '~' {
 			p = strings.Replace(p, "~", usr.HomeDir, 1)
 		}
+		p = filepath.Clean(p)
 
-		ps[i] = filepath.Clean(p)
+		if _, err := os.Stat(p); os.IsNotExist(err) && !explicit {
+			log.WithField("path", p).Debug("default source path does not exist")
+			continue
+		}
+
+		ps = append(ps, p)
 	}
 
 	return ps, nil
@@ -196,51 +330,150 @@ func newRootAnchor(c *cli.Context, lx fx.Lifecycle) (ww.Anchor, error) {
 	return root, err
 }
 
-func newReaderFactory() repl.ReaderFactory {
-	return repl.ReaderFactoryFunc(reader.New)
+func newStream(lx fx.Lifecycle) (*reader.Stream, error) {
+	t, err := reader.NewTable()
+	if err != nil {
+		return nil, err
+	}
+
+	s := t.NewStream("REPL")
+	lx.Append(closehook(s))
+	return s, nil
 }
 
 func newWriter(c *cli.Context) io.Writer { return c.App.Writer }
 
-func newPrinter() repl.Printer { return printer{} }
+func newPrinter(c *cli.Context) repl.Printer {
+	return printer{p: printutil.Printer(c)}
+}
+
+func newEvaluator(eval evaluator) repl.Evaluator { return eval }
+
+func newVM(c *cli.Context, root ww.Anchor, paths []string) (evaluator, error) {
+	vm, err := lang.New(root, paths...)
+	if err != nil {
+		return evaluator{}, err
+	}
+
+	if err = bindPubSub(vm, root); err != nil {
+		return evaluator{}, err
+	}
+
+	return evaluator{
+		ctx:     context.Background(),
+		vm:      vm,
+		timeout: c.Duration("eval-timeout"),
+	}, nil
+}
+
+// bindPubSub binds the 'pubsub' symbol to a capability for joining the cluster's
+// pubsub topics, if root is connected to a cluster.
+func bindPubSub(vm *lang.VM, root ww.Anchor) error {
+	c, ok := root.(client.Client)
+	if !ok {
+		return nil
+	}
+
+	ps, err := core.NewPubSub(func(topic string) (core.TopicHandle, error) {
+		return c.Join(topic)
+	})
+	if err != nil {
+		return err
+	}
+
+	return vm.Bind(map[string]score.Any{"pubsub": ps})
+}
 
-func newEvaluator(root ww.Anchor, paths []string) (repl.Evaluator, error) {
-	return lang.New(root, paths...)
+// evaluator binds each evaluation to a context that is canceled when the user presses
+// Ctrl-C, or when the timeout expires.  An interrupted evaluation returns an error,
+// and the REPL returns to the prompt.
+type evaluator struct {
+	ctx     context.Context // parent of each evaluation's context
+	vm      *lang.VM
+	timeout time.Duration
 }
 
-func newInput(c *cli.Context, lx fx.Lifecycle) (repl.Input, error) {
+func (e evaluator) Eval(form score.Any) (score.Any, error) {
+	return e.eval(func(ctx context.Context) (score.Any, error) {
+		return e.vm.EvalContext(ctx, form)
+	})
+}
+
+// EvalSource evaluates a form read from a script.  See lang.VM.EvalSource.
+func (e evaluator) EvalSource(f lang.SourceForm) (score.Any, error) {
+	return e.eval(func(ctx context.Context) (score.Any, error) {
+		return e.vm.EvalSource(ctx, f)
+	})
+}
+
+// eval calls f with the context of a single evaluation, which is canceled by an
+// interrupt or the evaluation timeout.
+func (e evaluator) eval(f func(context.Context) (score.Any, error)) (score.Any, error) {
+	ctx, cancel := context.WithCancel(e.ctx)
+	defer cancel()
+
+	if e.timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, e.timeout)
+		defer cancelTimeout()
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
+
+	go func() {
+		select {
+		case <-sig:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return f(ctx)
+}
+
+func newInput(c *cli.Context, log ww.Logger, lx fx.Lifecycle, complete readline.AutoCompleter) (repl.Input, error) {
 	r, err := readline.NewEx(&readline.Config{
-		HistoryFile: "/tmp/ww.tmp", // TODO(enhancement): ~/.ww/history.ww
+		HistoryFile: historyFile(log),
 		Stdout:      c.App.Writer,
 		Stderr:      c.App.ErrWriter,
 
 		InterruptPrompt: "⏎",
 		EOFPrompt:       "(exit)",
 
-		/*
-			TODO(enhancemenbt):  pass in the lang.Ww and configure autocomplete.
-								 The lang.Ww instance will need to supply completions.
-		*/
-		// AutoComplete: completer(ww),
+		AutoComplete: complete,
 	})
 
 	if err == nil {
 		lx.Append(closehook(r))
 	}
 
-	return linereader{r}, err
+	return r, err
 }
 
-type linereader struct{ *readline.Instance }
-
-func (l linereader) Readline() (line string, err error) {
-	for {
-		if line, err = l.Instance.Readline(); err == readline.ErrInterrupt {
-			return "", nil
+// historyFile returns the location of the REPL history, $XDG_DATA_HOME/ww/history,
+// creating its directory if needed.  If the directory cannot be created, history is
+// not saved.
+func historyFile(log ww.Logger) string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			log.WithError(err).Debug("history disabled")
+			return ""
 		}
 
-		return
+		dir = filepath.Join(home, ".local", "share")
+	}
+
+	dir = filepath.Join(dir, "ww")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.WithError(err).Debug("history disabled")
+		return ""
 	}
+
+	return filepath.Join(dir, "history")
 }
 
 type prompt struct {
@@ -250,26 +483,29 @@ type prompt struct {
 	Multiline string `name:"multiline"`
 }
 
-type printer struct{}
+// printer lays out values and renders errors, subject to the width and limits set by
+// the --print-* flags.
+type printer struct{ p core.Printer }
 
-func (printer) Fprintln(w io.Writer, val interface{}) (err error) {
+func (p printer) Fprintln(w io.Writer, val interface{}) (err error) {
 	if val == nil {
 		return
 	}
 
-	if val, err = (printer{}).render(val); err == nil {
-		_, err = fmt.Fprintln(w, val)
+	var s string
+	if s, err = p.render(val); err == nil {
+		_, err = fmt.Fprintln(w, s)
 	}
 
 	return
 }
 
-func (printer) render(val interface{}) (interface{}, error) {
+func (p printer) render(val interface{}) (string, error) {
 	if any, ok := val.(ww.Any); ok {
-		return core.Render(any)
+		return p.p.Render(any)
 	}
 
-	return val, nil
+	return p.p.Limits.Elide(fmt.Sprint(val)), nil
 }
 
 type banner struct {
@@ -315,6 +551,10 @@ func (nopAnchor) Ls(context.Context) ([]ww.Anchor, error) {
 	return []ww.Anchor{}, nil
 }
 
+func (nopAnchor) Iter(context.Context) ww.AnchorIterator {
+	return anchorutil.SliceIter(nil)
+}
+
 func (a nopAnchor) Walk(_ context.Context, path []string) ww.Anchor {
 	return append(a, path...)
 }
@@ -337,6 +577,8 @@ func (a nopAnchor) Go(context.Context, ...ww.Any) (ww.Any, error) {
 	return nil, errors.New("not implemented")
 }
 
+func (nopAnchor) Release() {}
+
 func fxLogger(c *cli.Context) fx.Option {
 	if c.Bool("log-fx") {
 		return fx.Options()
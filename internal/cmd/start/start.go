@@ -2,14 +2,19 @@ package start
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
+	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/urfave/cli/v2"
 
 	ctxutil "github.com/wetware/ww/internal/util/ctx"
 	logutil "github.com/wetware/ww/internal/util/log"
+	printutil "github.com/wetware/ww/internal/util/print"
 	ww "github.com/wetware/ww/pkg"
 
 	"github.com/wetware/ww/pkg/host"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
 )
 
 var (
@@ -17,11 +22,40 @@ var (
 	logger ww.Logger
 )
 
+var flags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "metrics",
+		Usage:   "serve prometheus metrics on `ADDR`",
+		EnvVars: []string{"WW_METRICS"},
+	},
+	&cli.StringFlag{
+		Name:    "health",
+		Usage:   "serve liveness and readiness probes on `ADDR`",
+		EnvVars: []string{"WW_HEALTH"},
+	},
+	&cli.StringSliceFlag{
+		Name:    "token",
+		Usage:   "admit clients presenting `TOKEN[=OP,...]`, granting OPs (default all)",
+		EnvVars: []string{"WW_TOKENS"},
+	},
+	&cli.StringSliceFlag{
+		Name:    "allow-peer",
+		Usage:   "admit clients and hosts with peer `ID[=OP,...]`, granting OPs (default all)",
+		EnvVars: []string{"WW_ALLOW_PEERS"},
+	},
+	&cli.StringSliceFlag{
+		Name:    "security",
+		Usage:   "secure connections with `PROTO` (noise, tls), in order of preference",
+		EnvVars: []string{"WW_SECURITY"},
+	},
+}
+
 // Command constructor
 func Command() *cli.Command {
 	return &cli.Command{
 		Name:   "start",
 		Usage:  "start a host process",
+		Flags:  flags,
 		Before: setUp(),
 		After:  tearDown(),
 		Action: run(),
@@ -32,14 +66,61 @@ func setUp() cli.BeforeFunc {
 	return func(c *cli.Context) (err error) {
 		logger = logutil.New(c)
 
-		if h, err = host.New(host.WithLogger(logger)); err == nil {
+		opt := []host.Option{
+			host.WithLogger(logger),
+			host.WithPrintLimits(printutil.Limits(c)),
+			host.WithMetrics(c.String("metrics")),
+			host.WithHealth(c.String("health")),
+			host.WithSecurity(c.StringSlice("security")...),
+		}
+
+		for _, arg := range c.StringSlice("token") {
+			token, ops, err := parseGrant(arg)
+			if err != nil {
+				return fmt.Errorf("-token: %w", err)
+			}
+
+			opt = append(opt, host.WithToken(token, ops))
+		}
+
+		for _, arg := range c.StringSlice("allow-peer") {
+			s, ops, err := parseGrant(arg)
+			if err != nil {
+				return fmt.Errorf("-allow-peer: %w", err)
+			}
+
+			id, err := peer.Decode(s)
+			if err != nil {
+				return fmt.Errorf("-allow-peer: %w", err)
+			}
 
+			opt = append(opt, host.WithAllowPeer(id, ops))
 		}
 
+		h, err = host.New(opt...)
 		return
 	}
 }
 
+// parseGrant parses KEY[=OP,...].  If no operations are listed, all are granted.
+func parseGrant(arg string) (key string, ops anchorutil.Op, err error) {
+	ss := strings.SplitN(arg, "=", 2)
+	if key = ss[0]; len(ss) == 1 {
+		return key, anchorutil.AllOps, nil
+	}
+
+	for _, name := range strings.Split(ss[1], ",") {
+		var op anchorutil.Op
+		if op, err = anchorutil.ParseOp(strings.TrimSpace(name)); err != nil {
+			return
+		}
+
+		ops |= op
+	}
+
+	return
+}
+
 func tearDown() cli.AfterFunc {
 	return func(c *cli.Context) error {
 		return h.Close()
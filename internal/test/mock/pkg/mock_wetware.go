@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/wetware/ww/pkg (interfaces: Logger,Loggable,Any,Anchor)
+// Source: github.com/wetware/ww/pkg (interfaces: Logger,Loggable,Any,Anchor,AnchorIterator)
 
 // Package mock_ww is a generated GoMock package.
 package mock_ww
@@ -503,6 +503,20 @@ func (mr *MockAnchorMockRecorder) Go(arg0 interface{}, arg1 ...interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Go", reflect.TypeOf((*MockAnchor)(nil).Go), varargs...)
 }
 
+// Iter mocks base method
+func (m *MockAnchor) Iter(arg0 context.Context) ww.AnchorIterator {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Iter", arg0)
+	ret0, _ := ret[0].(ww.AnchorIterator)
+	return ret0
+}
+
+// Iter indicates an expected call of Iter
+func (mr *MockAnchorMockRecorder) Iter(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Iter", reflect.TypeOf((*MockAnchor)(nil).Iter), arg0)
+}
+
 // Load mocks base method
 func (m *MockAnchor) Load(arg0 context.Context) (ww.Any, error) {
 	m.ctrl.T.Helper()
@@ -561,6 +575,18 @@ func (mr *MockAnchorMockRecorder) Path() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Path", reflect.TypeOf((*MockAnchor)(nil).Path))
 }
 
+// Release mocks base method
+func (m *MockAnchor) Release() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Release")
+}
+
+// Release indicates an expected call of Release
+func (mr *MockAnchorMockRecorder) Release() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Release", reflect.TypeOf((*MockAnchor)(nil).Release))
+}
+
 // Store mocks base method
 func (m *MockAnchor) Store(arg0 context.Context, arg1 ww.Any) error {
 	m.ctrl.T.Helper()
@@ -588,3 +614,80 @@ func (mr *MockAnchorMockRecorder) Walk(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Walk", reflect.TypeOf((*MockAnchor)(nil).Walk), arg0, arg1)
 }
+
+// MockAnchorIterator is a mock of AnchorIterator interface
+type MockAnchorIterator struct {
+	ctrl     *gomock.Controller
+	recorder *MockAnchorIteratorMockRecorder
+}
+
+// MockAnchorIteratorMockRecorder is the mock recorder for MockAnchorIterator
+type MockAnchorIteratorMockRecorder struct {
+	mock *MockAnchorIterator
+}
+
+// NewMockAnchorIterator creates a new mock instance
+func NewMockAnchorIterator(ctrl *gomock.Controller) *MockAnchorIterator {
+	mock := &MockAnchorIterator{ctrl: ctrl}
+	mock.recorder = &MockAnchorIteratorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockAnchorIterator) EXPECT() *MockAnchorIteratorMockRecorder {
+	return m.recorder
+}
+
+// Anchor mocks base method
+func (m *MockAnchorIterator) Anchor() ww.Anchor {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Anchor")
+	ret0, _ := ret[0].(ww.Anchor)
+	return ret0
+}
+
+// Anchor indicates an expected call of Anchor
+func (mr *MockAnchorIteratorMockRecorder) Anchor() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Anchor", reflect.TypeOf((*MockAnchorIterator)(nil).Anchor))
+}
+
+// Err mocks base method
+func (m *MockAnchorIterator) Err() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Err")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Err indicates an expected call of Err
+func (mr *MockAnchorIteratorMockRecorder) Err() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Err", reflect.TypeOf((*MockAnchorIterator)(nil).Err))
+}
+
+// Next mocks base method
+func (m *MockAnchorIterator) Next() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Next")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Next indicates an expected call of Next
+func (mr *MockAnchorIteratorMockRecorder) Next() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Next", reflect.TypeOf((*MockAnchorIterator)(nil).Next))
+}
+
+// Release mocks base method
+func (m *MockAnchorIterator) Release() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Release")
+}
+
+// Release indicates an expected call of Release
+func (mr *MockAnchorIteratorMockRecorder) Release() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Release", reflect.TypeOf((*MockAnchorIterator)(nil).Release))
+}
@@ -1,25 +1,53 @@
 package clientutil
 
 import (
+	"bufio"
 	"context"
+	"fmt"
 	"net"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/lthibault/log"
 	"github.com/multiformats/go-multiaddr"
 	"github.com/pkg/errors"
 	"github.com/urfave/cli/v2"
+
+	logutil "github.com/wetware/ww/internal/util/log"
+	ww "github.com/wetware/ww/pkg"
 	"github.com/wetware/ww/pkg/boot"
 	"github.com/wetware/ww/pkg/client"
 )
 
 // Dial into a cluster with CLI args
 //
-// c must contain either a -join or -discover flag.
+// c must contain either a -join or -discover flag.  If it defines an -addr flag, or
+// the addresses are listed in the config file (see Addrs), the hosts are dialed one
+// at a time, in order, until one of them is reached.
 func Dial(ctx context.Context, c *cli.Context) (root client.Client, err error) {
-	var d boot.Strategy
+	var (
+		d       boot.Strategy
+		trusted bool
+	)
+
+	as, err := Addrs(c)
+	if err != nil {
+		return root, err
+	}
+
 	switch {
+	case len(as) > 0:
+		if c.StringSlice("join") != nil {
+			return root, errors.New("-join and -addr are mutually exclusive")
+		}
+
+		return DialAddrs(ctx, c, as)
 	case c.StringSlice("join") != nil:
+		// peers passed explicitly are trusted, and not handshaked
 		d, err = Join(c)
+		trusted = true
 	case c.String("discover") != "":
 		d, err = Bootstrap(c)
 	default:
@@ -27,12 +55,172 @@ func Dial(ctx context.Context, c *cli.Context) (root client.Client, err error) {
 	}
 
 	if err == nil {
-		root, err = client.Dial(ctx, client.WithStrategy(d))
+		vlog := Verbose(c).WithField("strategy", fmt.Sprintf("%T", d))
+		vlog.Info("dialing")
+
+		start := time.Now()
+		if root, err = client.Dial(ctx, options(c, d, trusted)...); err != nil {
+			vlog = vlog.WithError(err)
+		}
+
+		vlog.WithField("duration", time.Since(start)).Info("dialed")
 	}
 
 	return
 }
 
+// Verbosity returns 2 if -vv is set, 1 if -v is set, and 0 otherwise.
+func Verbosity(c *cli.Context) int {
+	switch {
+	case c.Bool("vv"):
+		return 2
+	case c.Bool("verbose"):
+		return 1
+	}
+
+	return 0
+}
+
+// Verbose returns the logger for the output of -v and -vv.  It writes to stderr at
+// info level, regardless of -loglvl, and stamps each entry with the time elapsed
+// since the command started.  If neither flag is set, its output is discarded.
+func Verbose(c *cli.Context) ww.Logger {
+	if Verbosity(c) == 0 {
+		return logutil.Nop()
+	}
+
+	return log.New(log.WithLevel(log.InfoLevel), logutil.WithElapsed(c))
+}
+
+func options(c *cli.Context, d boot.Strategy, trusted bool) []client.Option {
+	opt := []client.Option{
+		client.WithLogger(logutil.New(c)),
+		client.WithStrategy(d),
+		client.WithHandshake(!trusted),
+		client.WithDialRetry(c.Int("retries"), c.Duration("retry-backoff")),
+		client.WithToken(c.String("token")),
+		client.WithSecurity(c.StringSlice("security")...),
+	}
+
+	if ns := c.String("namespace"); ns != "" {
+		opt = append(opt, client.WithNamespace(ns))
+	}
+
+	if v := Verbosity(c); v > 0 {
+		opt = append(opt, client.WithRPCTrace(Verbose(c), v > 1))
+	}
+
+	return opt
+}
+
+// DialAddrs dials each address in turn, allotting -dial-timeout to each attempt, and
+// returns the first client that connects.  If every attempt fails, the error lists
+// each address and the reason it failed.
+func DialAddrs(ctx context.Context, c *cli.Context, as []multiaddr.Multiaddr) (client.Client, error) {
+	log := logutil.New(c)
+	vlog := Verbose(c)
+
+	var b strings.Builder
+	for _, a := range as {
+		vlog.WithField("addr", a).Info("dialing")
+
+		start := time.Now()
+		root, err := dialOne(ctx, c, a)
+		if err == nil {
+			log.WithField("addr", a).Debug("connected")
+			vlog.WithField("addr", a).WithField("duration", time.Since(start)).Info("dialed")
+			return root, nil
+		}
+
+		log.WithError(err).WithField("addr", a).Debug("dial failed")
+		vlog.WithError(err).WithField("addr", a).WithField("duration", time.Since(start)).
+			Info("dialed")
+		fmt.Fprintf(&b, "\n    %s: %v", a, err)
+	}
+
+	return client.Client{}, errors.Errorf("no host reachable:%s", b.String())
+}
+
+func dialOne(ctx context.Context, c *cli.Context, a multiaddr.Multiaddr) (client.Client, error) {
+	if err := ctx.Err(); err != nil {
+		return client.Client{}, err // not attempted
+	}
+
+	if d := c.Duration("dial-timeout"); d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	return client.Dial(ctx, options(c, boot.StaticAddrs{a}, true)...)
+}
+
+// Addrs returns the host addresses given by the -addr flag.  If the flag is not set,
+// and neither -join nor -discover is, the addresses are read from the config file
+// $XDG_CONFIG_HOME/ww/addrs, which lists one multiaddr per line.  Blank lines and
+// lines beginning with '#' are ignored.  A missing config file lists no addresses.
+func Addrs(c *cli.Context) ([]multiaddr.Multiaddr, error) {
+	ss := c.StringSlice("addr")
+	if len(ss) == 0 && c.StringSlice("join") == nil && !c.IsSet("discover") {
+		var err error
+		if ss, err = readConfigAddrs(); err != nil {
+			return nil, err
+		}
+	}
+
+	as := make([]multiaddr.Multiaddr, len(ss))
+	for i, s := range ss {
+		var err error
+		if as[i], err = multiaddr.NewMultiaddr(s); err != nil {
+			return nil, errors.Wrapf(err, "addr %s", s)
+		}
+	}
+
+	return as, nil
+}
+
+func readConfigAddrs() ([]string, error) {
+	dir, ok := configDir()
+	if !ok {
+		return nil, nil // no config
+	}
+
+	f, err := os.Open(filepath.Join(dir, "addrs"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		ss      []string
+		scanner = bufio.NewScanner(f)
+	)
+
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && line[0] != '#' {
+			ss = append(ss, line)
+		}
+	}
+
+	return ss, scanner.Err()
+}
+
+// configDir returns $XDG_CONFIG_HOME/ww, or its platform-specific equivalent.  It
+// returns false if there is no config directory.
+func configDir() (string, bool) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		var err error
+		if dir, err = os.UserConfigDir(); err != nil {
+			return "", false
+		}
+	}
+
+	return filepath.Join(dir, "ww"), true
+}
+
 // Join addrs from CLI context.
 func Join(c *cli.Context) (as boot.StaticAddrs, err error) {
 	as = make(boot.StaticAddrs, len(c.StringSlice("join")))
@@ -66,6 +254,18 @@ func Bootstrap(c *cli.Context) (boot.Strategy, error) {
 
 			return mdns, nil
 		}
+	case "dnsaddr":
+		if param == "" {
+			return nil, errors.New("discover dnsaddr: missing domain")
+		}
+
+		return &boot.DNS{Domain: param}, nil
+	case "https":
+		if param == "" {
+			return nil, errors.New("discover https: missing url")
+		}
+
+		return &boot.HTTP{URL: "https://" + param}, nil
 	default:
 		return nil, errors.Errorf("unknown discovery protocol %s", proto)
 	}
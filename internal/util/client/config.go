@@ -0,0 +1,204 @@
+package clientutil
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the client's configuration file, $XDG_CONFIG_HOME/ww/config.yaml, e.g.
+//
+//	profile: dev
+//	profiles:
+//	  dev:
+//	    discover: /mdns
+//	  staging:
+//	    addrs: [/dns4/staging.example.com/tcp/2020/p2p/Qm...]
+//	    namespace: staging
+//	    token: s3cr3t
+//	    timeout: 30s
+//
+// Profile names the profile that is applied when -profile is not set.
+type Config struct {
+	Profile  string             `yaml:"profile,omitempty" json:"profile,omitempty"`
+	Profiles map[string]Profile `yaml:"profiles,omitempty" json:"profiles,omitempty"`
+}
+
+// Profile holds default values for the client flags of the same names.
+type Profile struct {
+	Addrs       []string `yaml:"addrs,omitempty" json:"addrs,omitempty"`
+	Join        []string `yaml:"join,omitempty" json:"join,omitempty"`
+	Discover    string   `yaml:"discover,omitempty" json:"discover,omitempty"`
+	Namespace   string   `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	Token       string   `yaml:"token,omitempty" json:"token,omitempty"`
+	Timeout     string   `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	DialTimeout string   `yaml:"dial-timeout,omitempty" json:"dial-timeout,omitempty"`
+	Output      string   `yaml:"output,omitempty" json:"output,omitempty"`
+}
+
+// ConfigPath returns the path of the config file.  It returns false if there is no
+// config directory.
+func ConfigPath() (string, bool) {
+	dir, ok := configDir()
+	return filepath.Join(dir, "config.yaml"), ok
+}
+
+// LoadConfig reads the config file.  A missing file yields the zero Config.  Keys that
+// are not recognized are reported as warnings rather than errors, so that a file that
+// is shared with a newer version remains usable.
+func LoadConfig() (cfg Config, warnings []string, err error) {
+	path, ok := ConfigPath()
+	if !ok {
+		return
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil, nil
+	} else if err != nil {
+		return
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+
+	err = dec.Decode(&cfg)
+	if e, ok := err.(*yaml.TypeError); ok {
+		// unknown keys are type errors; decode again to learn if there are others
+		cfg = Config{}
+		if err = yaml.Unmarshal(b, &cfg); err == nil {
+			for _, msg := range e.Errors {
+				warnings = append(warnings, path+": "+msg)
+			}
+		}
+	} else if err == io.EOF {
+		err = nil // empty file
+	}
+
+	return cfg, warnings, errors.Wrap(err, path)
+}
+
+// ApplyProfile sets each client flag that was not set on the command line, or by its
+// environment variable, to its value in the profile given by -profile, or else by the
+// config file.  The connection flags, -addr, -join and -discover, are applied only if
+// none of them is set, since they select alternative ways of reaching the cluster.
+// It returns the warnings from LoadConfig.
+func ApplyProfile(c *cli.Context) ([]string, error) {
+	cfg, warnings, err := LoadConfig()
+	if err != nil {
+		return warnings, err
+	}
+
+	name := c.String("profile")
+	if name == "" {
+		if name = cfg.Profile; name == "" {
+			return warnings, nil
+		}
+	}
+
+	p, ok := cfg.Profiles[name]
+	if !ok {
+		return warnings, errors.Errorf("unknown profile '%s'", name)
+	}
+
+	set := func(flag string, vs ...string) {
+		if err != nil || c.IsSet(flag) {
+			return
+		}
+
+		for _, v := range vs {
+			if v != "" {
+				err = errors.Wrapf(c.Set(flag, v), "profile %s: %s", name, flag)
+			}
+		}
+	}
+
+	if !c.IsSet("addr") && !c.IsSet("join") && !c.IsSet("discover") {
+		set("addr", p.Addrs...)
+		set("join", p.Join...)
+		set("discover", p.Discover)
+	}
+
+	set("namespace", p.Namespace)
+	set("token", p.Token)
+	set("timeout", p.Timeout)
+	set("dial-timeout", p.DialTimeout)
+	set("output", p.Output)
+
+	return warnings, err
+}
+
+// SetDefaultProfile sets the profile that is applied when -profile is not set.  The
+// rest of the config file, including its comments, is preserved.
+func SetDefaultProfile(name string) error {
+	cfg, _, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := cfg.Profiles[name]; !ok {
+		return errors.Errorf("unknown profile '%s'", name)
+	}
+
+	path, _ := ConfigPath()
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	if err = yaml.Unmarshal(b, &doc); err != nil {
+		return errors.Wrap(err, path)
+	}
+
+	if err = setKey(&doc, "profile", name); err != nil {
+		return errors.Wrap(err, path)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err = enc.Encode(&doc); err != nil {
+		return err
+	}
+
+	// replace the file atomically, so that it is never left half-written
+	tmp := path + ".tmp"
+	if err = ioutil.WriteFile(tmp, buf.Bytes(), info.Mode()); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// setKey sets the value of key in the top-level mapping of doc.
+func setKey(doc *yaml.Node, key, value string) error {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return errors.New("not a mapping")
+	}
+
+	m := doc.Content[0]
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content[i+1].SetString(value)
+			return nil
+		}
+	}
+
+	var k, v yaml.Node
+	k.SetString(key)
+	v.SetString(value)
+	m.Content = append([]*yaml.Node{&k, &v}, m.Content...)
+	return nil
+}
@@ -1,8 +1,12 @@
 package hostutil
 
 import (
+	"fmt"
+
 	p2p "github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p-core/pnet"
+	noise "github.com/libp2p/go-libp2p-noise"
+	tls "github.com/libp2p/go-libp2p-tls"
 )
 
 // MaybePrivate sets the p2p.PrivateNetwork option if the PSK is not nil.
@@ -13,3 +17,22 @@ func MaybePrivate(psk pnet.PSK) p2p.Option {
 
 	return p2p.PrivateNetwork(psk)
 }
+
+// Security restricts the security transports to those named, i.e. "noise" or "tls",
+// in order of preference.  If none are named, libp2p's defaults are used, which
+// include both.
+func Security(names ...string) (p2p.Option, error) {
+	opts := make([]p2p.Option, len(names))
+	for i, name := range names {
+		switch name {
+		case "noise":
+			opts[i] = p2p.Security(noise.ID, noise.New)
+		case "tls":
+			opts[i] = p2p.Security(tls.ID, tls.New)
+		default:
+			return nil, fmt.Errorf("unknown security transport '%s'", name)
+		}
+	}
+
+	return p2p.ChainOptions(opts...), nil
+}
@@ -2,6 +2,9 @@
 package logutil
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/lthibault/log"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
@@ -13,6 +16,9 @@ func New(c *cli.Context) ww.Logger {
 	return log.New(WithLevel(c), WithFormat(c))
 }
 
+// Nop returns a logger that discards all output.
+func Nop() ww.Logger { return ww.NopLogger() }
+
 // WithLevel returns a log.Option that configures a logger's level.
 func WithLevel(c *cli.Context) (opt log.Option) {
 	var level = log.FatalLevel
@@ -64,6 +70,37 @@ func WithFormat(c *cli.Context) log.Option {
 	return log.WithFormatter(fmt)
 }
 
+// start of the command, from which WithElapsed measures time.
+var start = time.Now()
+
+// WithElapsed returns an option that stamps each entry with the time elapsed since the
+// command started, rather than the time of day.  The format is given by -logfmt, as
+// for WithFormat, except that "none" is treated as "text".
+func WithElapsed(c *cli.Context) log.Option {
+	if c.String("logfmt") == "json" {
+		return log.WithFormatter(elapsedFormatter{&logrus.JSONFormatter{
+			DisableTimestamp: true,
+			PrettyPrint:      c.Bool("prettyprint"),
+		}})
+	}
+
+	return log.WithFormatter(elapsedFormatter{&logrus.TextFormatter{DisableTimestamp: true}})
+}
+
+type elapsedFormatter struct{ logrus.Formatter }
+
+func (f elapsedFormatter) Format(e *logrus.Entry) ([]byte, error) {
+	elapsed := e.Time.Sub(start)
+
+	if _, ok := f.Formatter.(*logrus.JSONFormatter); ok {
+		e.Data["elapsed"] = elapsed.Seconds()
+		return f.Formatter.Format(e)
+	}
+
+	b, err := f.Formatter.Format(e)
+	return append([]byte(fmt.Sprintf("[%8.3fs] ", elapsed.Seconds())), b...), err
+}
+
 // JoinFields returns a new map[string]interface{} that is the union of all field maps.
 func JoinFields(ms ...map[string]interface{}) (res map[string]interface{}) {
 	res = make(map[string]interface{}, len(ms)*5) // best effort pre-allocation
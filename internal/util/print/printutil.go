@@ -0,0 +1,62 @@
+// Package printutil contains shared utilities for configuring value rendering from a
+// cli context.
+package printutil
+
+import (
+	"github.com/urfave/cli/v2"
+
+	"github.com/wetware/ww/pkg/lang/core"
+)
+
+// Flags that configure the limits returned by Limits, and the printer returned by
+// Printer.
+var Flags = []cli.Flag{
+	&cli.IntFlag{
+		Name:    "print-width",
+		Usage:   "target line width of printed values (0 = one line)",
+		Value:   80,
+		EnvVars: []string{"WW_PRINT_WIDTH"},
+	},
+	&cli.IntFlag{
+		Name:    "print-max-string",
+		Usage:   "maximum bytes printed per string (0 = unlimited)",
+		Value:   core.DefaultLimits.MaxString,
+		EnvVars: []string{"WW_PRINT_MAX_STRING"},
+	},
+	&cli.IntFlag{
+		Name:    "print-max-items",
+		Usage:   "maximum elements printed per collection (0 = unlimited)",
+		Value:   core.DefaultLimits.MaxItems,
+		EnvVars: []string{"WW_PRINT_MAX_ITEMS"},
+	},
+	&cli.IntFlag{
+		Name:    "print-max-depth",
+		Usage:   "maximum nesting depth of printed collections (0 = unlimited)",
+		Value:   core.DefaultLimits.MaxDepth,
+		EnvVars: []string{"WW_PRINT_MAX_DEPTH"},
+	},
+	&cli.IntFlag{
+		Name:    "print-max-bytes",
+		Usage:   "maximum bytes printed per value (0 = unlimited)",
+		Value:   core.DefaultLimits.MaxBytes,
+		EnvVars: []string{"WW_PRINT_MAX_BYTES"},
+	},
+}
+
+// Limits from a cli context.
+func Limits(c *cli.Context) core.Limits {
+	return core.Limits{
+		MaxString: c.Int("print-max-string"),
+		MaxItems:  c.Int("print-max-items"),
+		MaxDepth:  c.Int("print-max-depth"),
+		MaxBytes:  c.Int("print-max-bytes"),
+	}
+}
+
+// Printer from a cli context.
+func Printer(c *cli.Context) core.Printer {
+	return core.Printer{
+		Width:  c.Int("print-width"),
+		Limits: Limits(c),
+	}
+}
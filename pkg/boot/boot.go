@@ -39,28 +39,18 @@ func (as StaticAddrs) Loggable() map[string]interface{} {
 }
 
 // DiscoverPeers converts the static addresses into AddrInfos
-func (as StaticAddrs) DiscoverPeers(_ context.Context, opt ...Option) (<-chan peer.AddrInfo, error) {
+func (as StaticAddrs) DiscoverPeers(ctx context.Context, opt ...Option) (<-chan peer.AddrInfo, error) {
 	var p Param
 	if err := p.Apply(opt); err != nil {
 		return nil, err
 	}
 
-	if p.isLimited() && len(as) > p.Limit {
-		as = as[:p.Limit]
-	}
-
 	ps, err := peer.AddrInfosFromP2pAddrs(as...)
 	if err != nil {
 		return nil, err
 	}
 
-	ch := make(chan peer.AddrInfo, len(ps))
-	for _, p := range ps {
-		ch <- p
-	}
-	close(ch)
-
-	return ch, err
+	return p.emit(ctx, ps), nil
 }
 
 /*
@@ -72,3 +62,65 @@ func (as StaticAddrs) DiscoverPeers(_ context.Context, opt ...Option) (<-chan pe
 func (p *Param) isLimited() bool {
 	return p.Limit > 0
 }
+
+// emit the peers in order, up to the limit.  If the peers must be verified, this
+// happens in the background.
+func (p *Param) emit(ctx context.Context, ps []peer.AddrInfo) <-chan peer.AddrInfo {
+	if p.Verify == nil {
+		if p.isLimited() && len(ps) > p.Limit {
+			ps = ps[:p.Limit]
+		}
+
+		ch := make(chan peer.AddrInfo, len(ps))
+		for _, info := range ps {
+			ch <- info
+		}
+		close(ch)
+
+		return ch
+	}
+
+	ch := make(chan peer.AddrInfo, 1)
+	go func() {
+		defer close(ch)
+
+		var n int
+		for _, info := range ps {
+			if !p.verify(ctx, info) {
+				continue
+			}
+
+			select {
+			case ch <- info:
+			case <-ctx.Done():
+				return
+			}
+
+			if n++; p.isLimited() && n == p.Limit {
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// verify the peer, reporting the error if it fails.
+func (p *Param) verify(ctx context.Context, info peer.AddrInfo) bool {
+	if p.Verify == nil {
+		return true
+	}
+
+	if err := p.Verify(ctx, info); err != nil {
+		p.report(err)
+		return false
+	}
+
+	return true
+}
+
+func (p *Param) report(err error) {
+	if p.OnError != nil {
+		p.OnError(err)
+	}
+}
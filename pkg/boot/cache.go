@@ -0,0 +1,254 @@
+package boot
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultCacheTTL is the time after which an unseen peer is evicted from a Cache.
+	DefaultCacheTTL = time.Hour * 24
+
+	// DefaultCacheSize is the default maximum number of peers held by a Cache.
+	DefaultCacheSize = 32
+)
+
+var _ Strategy = (*Cache)(nil)
+
+// Cache is a boot strategy that remembers peers to which the host has connected, so
+// that it can rejoin the cluster after a restart.  Peers are recorded with Mark, and
+// persisted to a JSON file at Path.
+//
+// DiscoverPeers yields cached peers first, most recently seen first, before falling
+// through to the wrapped Strategy.
+//
+// The file is replaced atomically, so hosts that share it never observe a partial
+// write.  A file that cannot be decoded is ignored and rewritten, and the error is
+// reported through the error handler passed to DiscoverPeers.
+type Cache struct {
+	Path     string
+	Strategy Strategy
+
+	// TTL after which peers that have not been marked are evicted.  If zero,
+	// DefaultCacheTTL is used.
+	TTL time.Duration
+
+	// MaxEntries bounds the number of cached peers.  The least recently seen peers are
+	// evicted first.  If zero, DefaultCacheSize is used.
+	MaxEntries int
+
+	mu sync.Mutex
+}
+
+// NewCache returns a Cache that persists peers to path, and falls back on s.
+func NewCache(path string, s Strategy) *Cache {
+	return &Cache{Path: path, Strategy: s}
+}
+
+type cacheEntry struct {
+	Info     peer.AddrInfo `json:"info"`
+	LastSeen time.Time     `json:"last_seen"`
+}
+
+// Loggable representation
+func (c *Cache) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"boot_strategy": "cache",
+		"boot_cache":    c.Path,
+		"boot_fallback": c.Strategy.Loggable(),
+	}
+}
+
+// Mark the peer as seen, persisting it to the cache.
+func (c *Cache) Mark(info peer.AddrInfo) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	es, _ := c.load() // a corrupt file is overwritten
+
+	for i, e := range es {
+		if e.Info.ID == info.ID {
+			es = append(es[:i], es[i+1:]...)
+			break
+		}
+	}
+
+	es = append(es, cacheEntry{Info: info, LastSeen: time.Now()})
+	return c.store(c.prune(es))
+}
+
+// DiscoverPeers yields unexpired cached peers, followed by the peers discovered by the
+// wrapped strategy.  Each peer is reported at most once, and the limit applies to the
+// combined output.  Errors from the wrapped strategy are reported through the error
+// handler.
+func (c *Cache) DiscoverPeers(ctx context.Context, opt ...Option) (<-chan peer.AddrInfo, error) {
+	var p Param
+	if err := p.Apply(opt); err != nil {
+		return nil, err
+	}
+
+	es, err := c.cached()
+	if err != nil {
+		p.report(errors.Wrap(err, "peer cache"))
+	}
+
+	out := make(chan peer.AddrInfo, len(es))
+	f := newFilter(p.Limit)
+
+	go func() {
+		defer close(out)
+
+		for _, e := range es {
+			if p.verify(ctx, e.Info) && f.Accept(e.Info) {
+				out <- e.Info
+			}
+		}
+
+		if f.Done() {
+			return
+		}
+
+		// peers from the wrapped strategy are verified by the strategy itself
+		ch, err := c.Strategy.DiscoverPeers(ctx, opt...)
+		if err != nil {
+			p.report(errors.Wrapf(err, "%s", name(c.Strategy)))
+			return
+		}
+
+		for info := range ch {
+			if !f.Accept(info) {
+				continue
+			}
+
+			select {
+			case out <- info:
+			case <-ctx.Done():
+				return
+			}
+
+			if f.Done() {
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// cached returns the unexpired entries, most recent first.  If entries were pruned, or
+// if the file was corrupt, the file is rewritten.  Entries are returned even if an error
+// occurs.
+func (c *Cache) cached() ([]cacheEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	es, err := c.load()
+	pruned := c.prune(append(es[:0:0], es...))
+	if err == nil && len(pruned) == len(es) {
+		return pruned, nil
+	}
+
+	if err != nil {
+		err = errors.Wrap(err, "discarded unreadable cache")
+	}
+
+	if serr := c.store(pruned); err == nil {
+		err = serr
+	}
+
+	return pruned, err
+}
+
+// load the cache file.  A missing file is an empty cache.  The entries of a corrupt
+// file are discarded, and an error is returned.
+func (c *Cache) load() ([]cacheEntry, error) {
+	b, err := ioutil.ReadFile(c.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var es []cacheEntry
+	if err = json.Unmarshal(b, &es); err != nil {
+		return nil, err
+	}
+
+	return es, nil
+}
+
+// store entries by writing them to a temporary file in the same directory, and
+// renaming it over the cache file.
+func (c *Cache) store(es []cacheEntry) error {
+	b, err := json.Marshal(es)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(c.Path), filepath.Base(c.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // fails harmlessly once renamed
+
+	if _, err = f.Write(b); err == nil {
+		err = f.Sync()
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), c.Path)
+}
+
+// prune expired entries, and sort the rest by recency, truncating to MaxEntries.
+func (c *Cache) prune(es []cacheEntry) []cacheEntry {
+	deadline := time.Now().Add(-c.ttl())
+
+	live := es[:0]
+	for _, e := range es {
+		if e.LastSeen.After(deadline) {
+			live = append(live, e)
+		}
+	}
+
+	sort.SliceStable(live, func(i, j int) bool {
+		return live[i].LastSeen.After(live[j].LastSeen)
+	})
+
+	if len(live) > c.maxEntries() {
+		live = live[:c.maxEntries()]
+	}
+
+	return live
+}
+
+func (c *Cache) ttl() time.Duration {
+	if c.TTL == 0 {
+		return DefaultCacheTTL
+	}
+
+	return c.TTL
+}
+
+func (c *Cache) maxEntries() int {
+	if c.MaxEntries == 0 {
+		return DefaultCacheSize
+	}
+
+	return c.MaxEntries
+}
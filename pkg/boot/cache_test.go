@@ -0,0 +1,148 @@
+package boot_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	testutil "github.com/wetware/ww/internal/test/util"
+	"github.com/wetware/ww/pkg/boot"
+)
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "ww-boot-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	infoA := info(t, idA)
+	infoB := info(t, idB)
+
+	t.Run("Empty", func(t *testing.T) {
+		c := boot.NewCache(filepath.Join(dir, "empty.json"), static(idA))
+
+		ps := collect(t, c)
+		assert.Equal(t, []string{idA}, ids(ps), "should fall through to the wrapped strategy")
+	})
+
+	t.Run("FreshestFirst", func(t *testing.T) {
+		c := boot.NewCache(filepath.Join(dir, "fresh.json"), static(idA, idB))
+		require.NoError(t, c.Mark(infoA))
+		require.NoError(t, c.Mark(infoB))
+
+		ps := collect(t, c)
+		assert.Equal(t, []string{idB, idA}, ids(ps), "cached peers should not be repeated")
+
+		ps = collect(t, c, boot.WithLimit(1))
+		assert.Equal(t, []string{idB}, ids(ps))
+
+		// another cache sharing the file sees the same peers
+		ps = collect(t, boot.NewCache(c.Path, silent{}), boot.WithLimit(2))
+		assert.Equal(t, []string{idB, idA}, ids(ps))
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		path := filepath.Join(dir, "expired.json")
+		writeJSON(t, path, []map[string]interface{}{
+			{"info": infoA, "last_seen": time.Now().Add(-time.Hour)},
+			{"info": infoB, "last_seen": time.Now()},
+		})
+
+		c := boot.NewCache(path, static())
+		c.TTL = time.Minute
+
+		ps := collect(t, c)
+		assert.Equal(t, []string{idB}, ids(ps))
+
+		var es []interface{}
+		readJSON(t, path, &es)
+		assert.Len(t, es, 1, "expired entry should be pruned from the file")
+	})
+
+	t.Run("MaxEntries", func(t *testing.T) {
+		c := boot.NewCache(filepath.Join(dir, "max.json"), static())
+		c.MaxEntries = 3
+
+		for i := 0; i < 10; i++ {
+			require.NoError(t, c.Mark(peer.AddrInfo{ID: testutil.RandID()}))
+		}
+
+		var es []interface{}
+		readJSON(t, c.Path, &es)
+		assert.Len(t, es, 3)
+		assert.Len(t, collect(t, c), 3)
+	})
+
+	t.Run("Corrupt", func(t *testing.T) {
+		path := filepath.Join(dir, "corrupt.json")
+		require.NoError(t, ioutil.WriteFile(path, []byte("{not json"), 0644))
+
+		var errs errorLog
+		c := boot.NewCache(path, static(idA))
+
+		ps := collect(t, c, boot.WithErrorHandler(errs.Report))
+		assert.Equal(t, []string{idA}, ids(ps))
+		assert.Len(t, errs.Errors(), 1)
+
+		var es []interface{}
+		readJSON(t, path, &es)
+		assert.Empty(t, es, "corrupt file should be rewritten")
+
+		require.NoError(t, c.Mark(infoB))
+		assert.Equal(t, []string{idB, idA}, ids(collect(t, c)))
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		path := filepath.Join(dir, "concurrent.json")
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				// separate instances, as if from separate hosts
+				c := boot.NewCache(path, static())
+				for j := 0; j < 10; j++ {
+					assert.NoError(t, c.Mark(peer.AddrInfo{ID: testutil.RandID()}))
+				}
+			}()
+		}
+		wg.Wait()
+
+		var es []interface{}
+		readJSON(t, path, &es)
+		assert.NotEmpty(t, es)
+
+		matches, err := filepath.Glob(path + ".*")
+		require.NoError(t, err)
+		assert.Empty(t, matches, "temporary files should be removed")
+	})
+}
+
+func info(t *testing.T, id string) peer.AddrInfo {
+	ps, err := peer.AddrInfosFromP2pAddrs(static(id)...)
+	require.NoError(t, err)
+	return ps[0]
+}
+
+func writeJSON(t *testing.T, path string, v interface{}) {
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, b, 0644))
+}
+
+func readJSON(t *testing.T, path string, v interface{}) {
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, v), string(b))
+}
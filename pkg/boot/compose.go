@@ -0,0 +1,229 @@
+package boot
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/pkg/errors"
+)
+
+var (
+	_ Strategy = (*Sequence)(nil)
+	_ Strategy = (*Union)(nil)
+)
+
+// Sequence is a boot strategy that tries each of its strategies in order, moving on to
+// the next when one yields no peers before its timeout.
+type Sequence struct {
+	Strategies []Strategy
+
+	// Timeout bounds the time spent on each strategy.  If zero, each strategy is given
+	// two seconds.  The deadline of the context passed to DiscoverPeers always applies.
+	Timeout time.Duration
+}
+
+// Sequential returns a strategy that falls back on each of ss in order.
+func Sequential(ss ...Strategy) *Sequence {
+	return &Sequence{Strategies: ss}
+}
+
+// Loggable representation
+func (s Sequence) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"boot_strategy":   "sequential",
+		"boot_strategies": loggables(s.Strategies),
+	}
+}
+
+// DiscoverPeers from the first strategy to yield any.  Strategies that fail are
+// reported through the error handler, and skipped.
+func (s Sequence) DiscoverPeers(ctx context.Context, opt ...Option) (<-chan peer.AddrInfo, error) {
+	var p Param
+	if err := p.Apply(opt); err != nil {
+		return nil, err
+	}
+
+	out := make(chan peer.AddrInfo, 1)
+	go func() {
+		defer close(out)
+
+		f := newFilter(p.Limit)
+		for _, d := range s.Strategies {
+			if s.step(ctx, d, &p, opt, f, out) > 0 || ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+
+	return out, ctx.Err()
+}
+
+// step runs a single strategy to completion, and returns the number of peers it
+// yielded.
+func (s Sequence) step(ctx context.Context, d Strategy, p *Param, opt []Option, f *filter, out chan<- peer.AddrInfo) (n int) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+
+	ch, err := d.DiscoverPeers(ctx, opt...)
+	if err != nil {
+		p.report(errors.Wrapf(err, "%s", name(d)))
+		return
+	}
+
+	for info := range ch {
+		if !f.Accept(info) {
+			continue
+		}
+
+		select {
+		case out <- info:
+			n++
+		case <-ctx.Done():
+			return
+		}
+
+		if f.Done() {
+			return
+		}
+	}
+
+	return
+}
+
+func (s Sequence) timeout() time.Duration {
+	if s.Timeout == 0 {
+		return defaultTimeout
+	}
+
+	return s.Timeout
+}
+
+// Union is a boot strategy that runs all of its strategies concurrently.
+type Union struct {
+	Strategies []Strategy
+}
+
+// Merge returns a strategy that combines the peers discovered by each of ss.
+func Merge(ss ...Strategy) *Union {
+	return &Union{Strategies: ss}
+}
+
+// Loggable representation
+func (u Union) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"boot_strategy":   "merge",
+		"boot_strategies": loggables(u.Strategies),
+	}
+}
+
+// DiscoverPeers from all strategies.  Each peer is reported at most once, and the limit
+// applies to the combined output.  Strategies that fail are reported through the error
+// handler, and do not affect the others.
+func (u Union) DiscoverPeers(ctx context.Context, opt ...Option) (<-chan peer.AddrInfo, error) {
+	var p Param
+	if err := p.Apply(opt); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	var (
+		wg  sync.WaitGroup
+		f   = newFilter(p.Limit)
+		out = make(chan peer.AddrInfo, 1)
+	)
+
+	for _, d := range u.Strategies {
+		ch, err := d.DiscoverPeers(ctx, opt...)
+		if err != nil {
+			p.report(errors.Wrapf(err, "%s", name(d)))
+			continue
+		}
+
+		wg.Add(1)
+		go func(ch <-chan peer.AddrInfo) {
+			defer wg.Done()
+
+			for info := range ch {
+				if !f.Accept(info) {
+					continue
+				}
+
+				select {
+				case out <- info:
+				case <-ctx.Done():
+					return
+				}
+
+				if f.Done() {
+					cancel()
+					return
+				}
+			}
+		}(ch)
+	}
+
+	go func() {
+		defer close(out)
+		defer cancel()
+		wg.Wait()
+	}()
+
+	return out, nil
+}
+
+// filter deduplicates peers across strategies, and enforces the combined limit.
+type filter struct {
+	mu    sync.Mutex
+	limit int
+	seen  map[peer.ID]struct{}
+}
+
+func newFilter(limit int) *filter {
+	return &filter{limit: limit, seen: make(map[peer.ID]struct{})}
+}
+
+// Accept reports whether info should be emitted.  It returns false for peers that were
+// already accepted, or once the limit has been reached.
+func (f *filter) Accept(info peer.AddrInfo) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.done() {
+		return false
+	}
+
+	if _, ok := f.seen[info.ID]; ok {
+		return false
+	}
+
+	f.seen[info.ID] = struct{}{}
+	return true
+}
+
+// Done reports whether the limit has been reached.
+func (f *filter) Done() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.done()
+}
+
+func (f *filter) done() bool {
+	return f.limit > 0 && len(f.seen) >= f.limit
+}
+
+func loggables(ss []Strategy) []map[string]interface{} {
+	ms := make([]map[string]interface{}, len(ss))
+	for i, s := range ss {
+		ms[i] = s.Loggable()
+	}
+
+	return ms
+}
+
+func name(s Strategy) interface{} {
+	return s.Loggable()["boot_strategy"]
+}
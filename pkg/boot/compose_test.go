@@ -0,0 +1,157 @@
+package boot_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wetware/ww/pkg/boot"
+)
+
+func TestSequential(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Fallback", func(t *testing.T) {
+		var errs errorLog
+		d := boot.Sequential(failing{}, silent{}, static(idA), static(idB))
+		d.Timeout = time.Millisecond * 10
+
+		ps := collect(t, d, boot.WithErrorHandler(errs.Report))
+		assert.Equal(t, []string{idA}, ids(ps), "should stop at the first fruitful strategy")
+		assert.Len(t, errs.Errors(), 1)
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		ps := collect(t, boot.Sequential(static(idA, idB)), boot.WithLimit(1))
+		assert.Equal(t, []string{idA}, ids(ps))
+	})
+
+	t.Run("Loggable", func(t *testing.T) {
+		m := boot.Sequential(static(idA), silent{}).Loggable()
+		assert.Equal(t, "sequential", m["boot_strategy"])
+		assert.Len(t, m["boot_strategies"], 2)
+	})
+}
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Dedupe", func(t *testing.T) {
+		var errs errorLog
+		d := boot.Merge(static(idA), failing{}, static(idA, idB))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		ch, err := d.DiscoverPeers(ctx, boot.WithErrorHandler(errs.Report))
+		require.NoError(t, err)
+
+		var ps []peer.AddrInfo
+		for info := range ch {
+			ps = append(ps, info)
+		}
+
+		assert.ElementsMatch(t, []string{idA, idB}, ids(ps))
+		assert.Len(t, errs.Errors(), 1)
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		// the limit ends discovery, even though silent{} never closes its channel
+		ps := collect(t, boot.Merge(silent{}, static(idA, idB)), boot.WithLimit(2))
+		assert.ElementsMatch(t, []string{idA, idB}, ids(ps))
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		d := boot.Merge(boot.Sequential(static(idA)), static(idB))
+		ps := collect(t, d, boot.WithLimit(2))
+		assert.ElementsMatch(t, []string{idA, idB}, ids(ps))
+
+		m := d.Loggable()
+		assert.Equal(t, "merge", m["boot_strategy"])
+	})
+}
+
+// collect peers, failing the test if the channel is not closed promptly.
+func collect(t *testing.T, d boot.Strategy, opt ...boot.Option) (ps []peer.AddrInfo) {
+	ch, err := d.DiscoverPeers(context.Background(), opt...)
+	require.NoError(t, err)
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case info, ok := <-ch:
+			if !ok {
+				return
+			}
+			ps = append(ps, info)
+		case <-timeout:
+			t.Fatal("discovery did not terminate")
+		}
+	}
+}
+
+func static(ids ...string) boot.StaticAddrs {
+	as := make(boot.StaticAddrs, len(ids))
+	for i, id := range ids {
+		as[i] = multiaddr.StringCast("/ip4/10.0.0.1/tcp/2020/p2p/" + id)
+	}
+	return as
+}
+
+func ids(ps []peer.AddrInfo) []string {
+	ss := make([]string, len(ps))
+	for i, info := range ps {
+		ss[i] = info.ID.String()
+	}
+	return ss
+}
+
+// silent yields no peers, and closes its channel when the context expires.
+type silent struct{}
+
+func (silent) Loggable() map[string]interface{} {
+	return map[string]interface{}{"boot_strategy": "silent"}
+}
+
+func (silent) DiscoverPeers(ctx context.Context, _ ...boot.Option) (<-chan peer.AddrInfo, error) {
+	ch := make(chan peer.AddrInfo)
+	go func() {
+		<-ctx.Done()
+		close(ch)
+	}()
+	return ch, nil
+}
+
+type failing struct{}
+
+func (failing) Loggable() map[string]interface{} {
+	return map[string]interface{}{"boot_strategy": "failing"}
+}
+
+func (failing) DiscoverPeers(context.Context, ...boot.Option) (<-chan peer.AddrInfo, error) {
+	return nil, errors.New("failed")
+}
+
+type errorLog struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (l *errorLog) Report(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errs = append(l.errs, err)
+}
+
+func (l *errorLog) Errors() []error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.errs
+}
@@ -0,0 +1,189 @@
+package boot
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/discovery"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	ww "github.com/wetware/ww/pkg"
+)
+
+// DefaultAdvertiseTTL is the interval at which Discovery refreshes its beacon, unless
+// Advertise is passed a TTL.
+const DefaultAdvertiseTTL = time.Hour
+
+var (
+	_ discovery.Discovery = (*Discovery)(nil)
+	_ Strategy            = (*Discoverer)(nil)
+)
+
+// Discovery adapts a boot strategy and beacon to libp2p's discovery.Discovery, so that
+// they can be used by libp2p components such as pubsub's WithDiscovery.
+//
+// Boot strategies are scoped to a cluster rather than a namespace, so the namespace
+// passed to Advertise and FindPeers is ignored:  every peer in the cluster is a
+// candidate for every namespace.
+type Discovery struct {
+	Host     host.Host
+	Strategy Strategy
+
+	// Beacon is signalled by Advertise.  If nil, Advertise is a no-op, which is
+	// suitable for strategies such as StaticAddrs.
+	Beacon Beacon
+
+	mu      sync.Mutex
+	active  bool
+	expires time.Time
+}
+
+// NewDiscovery returns a Discovery that finds peers with s, and advertises h with b.
+func NewDiscovery(h host.Host, s Strategy, b Beacon) *Discovery {
+	return &Discovery{Host: h, Strategy: s, Beacon: b}
+}
+
+// Advertise signals the beacon.  The beacon is refreshed by restarting it, at most once
+// per TTL.  The returned duration is the time until the next refresh is due, so that
+// callers that re-advertise when it elapses keep the beacon fresh.  The TTL defaults
+// to DefaultAdvertiseTTL.
+func (d *Discovery) Advertise(ctx context.Context, _ string, opt ...discovery.Option) (time.Duration, error) {
+	var opts discovery.Options
+	if err := opts.Apply(opt...); err != nil {
+		return 0, err
+	}
+
+	ttl := opts.Ttl
+	if ttl == 0 {
+		ttl = DefaultAdvertiseTTL
+	}
+
+	if d.Beacon == nil {
+		return ttl, nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if remaining := time.Until(d.expires); d.active && remaining > 0 {
+		return remaining, nil
+	}
+
+	if d.active {
+		if err := d.Beacon.Stop(ctx); err != nil {
+			return 0, err
+		}
+
+		d.active = false
+	}
+
+	if err := d.Beacon.Signal(ctx, d.Host); err != nil {
+		return 0, err
+	}
+
+	d.active, d.expires = true, time.Now().Add(ttl)
+	return ttl, nil
+}
+
+// FindPeers discovers peers with the strategy.  The Limit option is passed on to the
+// strategy, and other options are made available through Param.Custom.  The TTL
+// option is ignored.
+func (d *Discovery) FindPeers(ctx context.Context, _ string, opt ...discovery.Option) (<-chan peer.AddrInfo, error) {
+	var opts discovery.Options
+	if err := opts.Apply(opt...); err != nil {
+		return nil, err
+	}
+
+	return d.Strategy.DiscoverPeers(ctx, func(p *Param) error {
+		p.Limit = opts.Limit
+		p.Custom = opts.Other
+		return nil
+	})
+}
+
+// Close stops the beacon, if it was signalled.
+func (d *Discovery) Close(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.active {
+		return nil
+	}
+
+	d.active = false
+	return d.Beacon.Stop(ctx)
+}
+
+// Discoverer is a boot strategy that finds peers through a libp2p discovery
+// implementation, such as a rendezvous client or the DHT.
+type Discoverer struct {
+	// Namespace under which peers advertise themselves.  If empty,
+	// ww.DefaultNamespace is used.
+	Namespace  string
+	Discoverer discovery.Discoverer
+}
+
+// Loggable representation
+func (d Discoverer) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"boot_strategy":  "discovery",
+		"boot_namespace": d.namespace(),
+	}
+}
+
+// DiscoverPeers finds peers in the namespace.  The limit is passed on to the
+// discoverer, unless the peers must be verified, in which case it is enforced here so
+// that rejected peers do not count against it.  Param.Custom is passed on as
+// discovery.Options.Other.  Each peer is reported at most once.
+func (d Discoverer) DiscoverPeers(ctx context.Context, opt ...Option) (<-chan peer.AddrInfo, error) {
+	var p Param
+	if err := p.Apply(opt); err != nil {
+		return nil, err
+	}
+
+	ch, err := d.Discoverer.FindPeers(ctx, d.namespace(), func(opts *discovery.Options) error {
+		if p.Verify == nil {
+			opts.Limit = p.Limit
+		}
+
+		opts.Other = p.Custom
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan peer.AddrInfo, 1)
+	go func() {
+		defer close(out)
+
+		f := newFilter(p.Limit)
+		for info := range ch {
+			if !p.verify(ctx, info) || !f.Accept(info) {
+				continue
+			}
+
+			select {
+			case out <- info:
+			case <-ctx.Done():
+				return
+			}
+
+			if f.Done() {
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (d Discoverer) namespace() string {
+	if d.Namespace != "" {
+		return d.Namespace
+	}
+
+	return ww.DefaultNamespace
+}
@@ -0,0 +1,159 @@
+package boot_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/libp2p/go-libp2p-core/discovery"
+	"github.com/libp2p/go-libp2p-core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mock_boot "github.com/wetware/ww/internal/test/mock/pkg/boot"
+	"github.com/wetware/ww/pkg/boot"
+)
+
+func TestDiscovery(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.FullMeshLinked(ctx, 3)
+	require.NoError(t, err)
+
+	hs := mn.Hosts()
+	local, h1, h2 := hs[0], hs[1], hs[2]
+	h1.SetStreamHandler(boot.HelloProtocol, boot.HelloHandler("ww"))
+
+	d := boot.NewDiscovery(local, boot.StaticAddrs{p2pAddr(t, h1), p2pAddr(t, h2)}, nil)
+
+	t.Run("FindPeers", func(t *testing.T) {
+		ch, err := d.FindPeers(ctx, "ignored", discovery.Limit(1))
+		require.NoError(t, err)
+
+		var ps []peer.AddrInfo
+		for info := range ch {
+			ps = append(ps, info)
+		}
+
+		assert.Len(t, ps, 1, "limit should be respected")
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		ps := collect(t, boot.Discoverer{Discoverer: d})
+		assert.ElementsMatch(t, []peer.ID{h1.ID(), h2.ID()}, peerIDs(ps))
+
+		ps = collect(t, boot.Discoverer{Discoverer: d}, boot.WithLimit(1))
+		assert.Len(t, ps, 1)
+	})
+
+	t.Run("Handshake", func(t *testing.T) {
+		var errs errorLog
+		ps := collect(t, boot.Discoverer{Discoverer: d},
+			boot.WithLimit(1),
+			boot.WithHandshake(local, "ww"),
+			boot.WithErrorHandler(errs.Report))
+
+		// h2 does not speak the hello protocol, so only h1 is reported
+		assert.Equal(t, []peer.ID{h1.ID()}, peerIDs(ps))
+		assert.True(t, len(errs.Errors()) <= 1, "unexpected errors %v", errs.Errors())
+	})
+
+	t.Run("Advertise", func(t *testing.T) {
+		// no beacon
+		ttl, err := d.Advertise(ctx, "ignored")
+		require.NoError(t, err)
+		assert.Equal(t, boot.DefaultAdvertiseTTL, ttl)
+	})
+}
+
+func TestDiscoveryAdvertise(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.WithNPeers(ctx, 1)
+	require.NoError(t, err)
+	h := mn.Hosts()[0]
+
+	b := mock_boot.NewMockBeacon(ctrl)
+	b.EXPECT().Signal(gomock.Any(), h).Return(nil).Times(2)
+	b.EXPECT().Stop(gomock.Any()).Return(nil).Times(2)
+
+	d := boot.NewDiscovery(h, boot.StaticAddrs{}, b)
+
+	const ttl = time.Millisecond * 100
+
+	got, err := d.Advertise(ctx, "a", discovery.TTL(ttl))
+	require.NoError(t, err)
+	assert.Equal(t, ttl, got)
+
+	// the beacon is fresh, so it is not signalled again
+	got, err = d.Advertise(ctx, "b", discovery.TTL(ttl))
+	require.NoError(t, err)
+	assert.True(t, got > 0 && got <= ttl, "unexpected ttl %s", got)
+
+	// once the TTL has elapsed, the beacon is restarted
+	time.Sleep(ttl)
+	_, err = d.Advertise(ctx, "a", discovery.TTL(ttl))
+	require.NoError(t, err)
+
+	require.NoError(t, d.Close(ctx))
+	require.NoError(t, d.Close(ctx), "close should be idempotent")
+}
+
+func TestDiscoverer(t *testing.T) {
+	t.Parallel()
+
+	type key struct{}
+
+	r := &rendezvous{ps: []peer.AddrInfo{
+		{ID: peer.ID(idA)},
+		{ID: peer.ID(idA)},
+		{ID: peer.ID(idB)},
+	}}
+
+	d := boot.Discoverer{Namespace: "test", Discoverer: r}
+	assert.Equal(t, "discovery", d.Loggable()["boot_strategy"])
+
+	ps := collect(t, d, boot.WithLimit(2), func(p *boot.Param) error {
+		p.Custom = map[interface{}]interface{}{key{}: "value"}
+		return nil
+	})
+
+	assert.Len(t, ps, 2, "duplicate peers should be dropped")
+	assert.Equal(t, "test", r.ns)
+	assert.Equal(t, 2, r.opts.Limit)
+	assert.Equal(t, "value", r.opts.Other[key{}])
+}
+
+// rendezvous is a discovery.Discoverer that returns a fixed list of peers, and records
+// the last query.
+type rendezvous struct {
+	ps   []peer.AddrInfo
+	ns   string
+	opts discovery.Options
+}
+
+func (r *rendezvous) FindPeers(_ context.Context, ns string, opt ...discovery.Option) (<-chan peer.AddrInfo, error) {
+	r.ns = ns
+	if err := r.opts.Apply(opt...); err != nil {
+		return nil, err
+	}
+
+	ch := make(chan peer.AddrInfo, len(r.ps))
+	for _, info := range r.ps {
+		ch <- info
+	}
+	close(ch)
+
+	return ch, nil
+}
@@ -0,0 +1,191 @@
+package boot
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/pkg/errors"
+
+	ww "github.com/wetware/ww/pkg"
+)
+
+const (
+	dnsaddrPrefix = "_dnsaddr."
+	dnsaddrKey    = "dnsaddr="
+)
+
+// Resolver looks up DNS TXT records.  It is satisfied by *net.Resolver.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DNS discovers bootstrap peers from the dnsaddr TXT records published under
+// _dnsaddr.<Domain>, as done by IPFS.  Records of the form
+// "dnsaddr=/dnsaddr/<domain>/..." are resolved one level deep; further indirection is
+// ignored.
+type DNS struct {
+	Domain string
+
+	// Resolver performs TXT lookups.  If nil, net.DefaultResolver is used.
+	Resolver Resolver
+
+	// Log reports malformed records, which are skipped.  If nil, nothing is logged.
+	Log ww.Logger
+}
+
+// Loggable representation
+func (d DNS) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"boot_strategy": "dnsaddr",
+		"boot_domain":   d.Domain,
+	}
+}
+
+// DiscoverPeers resolves the domain's dnsaddr records.  Peers that appear in several
+// records are merged.  The lookup respects the context's deadline.
+func (d DNS) DiscoverPeers(ctx context.Context, opt ...Option) (<-chan peer.AddrInfo, error) {
+	var p Param
+	if err := p.Apply(opt); err != nil {
+		return nil, err
+	}
+
+	as, err := d.resolve(ctx, d.Domain, 1)
+	if err != nil {
+		return nil, errors.Wrapf(err, "resolve %s", d.Domain)
+	}
+
+	return p.emit(ctx, merge(as)), nil
+}
+
+// resolve the dnsaddr records for domain, following up to depth levels of indirection.
+// Failure to resolve an indirect record is logged rather than returned.
+func (d DNS) resolve(ctx context.Context, domain string, depth int) ([]multiaddr.Multiaddr, error) {
+	txts, err := d.resolver().LookupTXT(ctx, dnsaddrPrefix+domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var as []multiaddr.Multiaddr
+	for _, txt := range txts {
+		if !strings.HasPrefix(txt, dnsaddrKey) {
+			continue // unrelated record
+		}
+
+		a, err := multiaddr.NewMultiaddr(strings.TrimPrefix(txt, dnsaddrKey))
+		if err != nil {
+			d.logger().With(d).WithError(err).
+				WithField("record", txt).
+				Warn("skipping malformed dnsaddr record")
+			continue
+		}
+
+		target, err := a.ValueForProtocol(multiaddr.P_DNSADDR)
+		if err != nil { // not an indirection
+			if _, err = a.ValueForProtocol(multiaddr.P_P2P); err != nil {
+				d.logger().With(d).WithError(err).
+					WithField("record", txt).
+					Warn("skipping dnsaddr record without peer ID")
+				continue
+			}
+
+			as = append(as, a)
+			continue
+		}
+
+		if depth == 0 {
+			continue
+		}
+
+		nested, err := d.resolve(ctx, target, depth-1)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			d.logger().With(d).WithError(err).
+				WithField("record", txt).
+				Warn("skipping unresolvable dnsaddr record")
+			continue
+		}
+
+		as = append(as, matchPeer(a, nested)...)
+	}
+
+	return as, nil
+}
+
+func (d DNS) resolver() Resolver {
+	if d.Resolver == nil {
+		return net.DefaultResolver
+	}
+
+	return d.Resolver
+}
+
+func (d DNS) logger() ww.Logger {
+	if d.Log == nil {
+		return ww.NopLogger()
+	}
+
+	return d.Log
+}
+
+// matchPeer returns the addresses in as that belong to the peer named by the p2p
+// component of a.  If a does not name a peer, all addresses are returned.
+func matchPeer(a multiaddr.Multiaddr, as []multiaddr.Multiaddr) []multiaddr.Multiaddr {
+	id, err := a.ValueForProtocol(multiaddr.P_P2P)
+	if err != nil {
+		return as
+	}
+
+	out := as[:0]
+	for _, x := range as {
+		if v, err := x.ValueForProtocol(multiaddr.P_P2P); err == nil && v == id {
+			out = append(out, x)
+		}
+	}
+
+	return out
+}
+
+// merge p2p addresses into one AddrInfo per peer, in order of first appearance.
+// Duplicate addresses are dropped.
+func merge(as []multiaddr.Multiaddr) []peer.AddrInfo {
+	type key struct {
+		id   peer.ID
+		addr string
+	}
+
+	var (
+		ps   []peer.AddrInfo
+		idx  = make(map[peer.ID]int)
+		seen = make(map[key]struct{})
+	)
+
+	for _, a := range as {
+		info, err := peer.AddrInfoFromP2pAddr(a)
+		if err != nil {
+			continue
+		}
+
+		i, ok := idx[info.ID]
+		if !ok {
+			i = len(ps)
+			idx[info.ID] = i
+			ps = append(ps, peer.AddrInfo{ID: info.ID})
+		}
+
+		for _, addr := range info.Addrs {
+			k := key{id: info.ID, addr: addr.String()}
+			if _, ok := seen[k]; !ok {
+				seen[k] = struct{}{}
+				ps[i].Addrs = append(ps[i].Addrs, addr)
+			}
+		}
+	}
+
+	return ps
+}
@@ -0,0 +1,117 @@
+package boot_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wetware/ww/pkg/boot"
+)
+
+const (
+	idA = "QmNnooDu7bfjPFoTZYxMNLWUQJyrVwtbZg5gBMjTezGAJN"
+	idB = "QmQCU2EcMqAqQPR2i9bChDtGNJchTbq5TbXJJ16u19uLTa"
+)
+
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if txts, ok := r[name]; ok {
+		return txts, nil
+	}
+
+	if name == "_dnsaddr.slow.example.com" {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	return nil, errors.New("no such host")
+}
+
+func TestDNS(t *testing.T) {
+	t.Parallel()
+
+	r := fakeResolver{
+		"_dnsaddr.example.com": {
+			"v=spf1 -all", // unrelated
+			"dnsaddr=/ip4/10.0.0.1/tcp/2020/p2p/" + idA,
+			"dnsaddr=/ip4/10.0.0.2/tcp/2020/p2p/" + idA,
+			"dnsaddr=/ip4/10.0.0.1/tcp/2020/p2p/" + idA, // duplicate
+			"dnsaddr=/dnsaddr/b.example.com/p2p/" + idB,
+			"dnsaddr=/dnsaddr/missing.example.com", // unresolvable
+			"dnsaddr=/ip4/10.0.0.4/tcp/2020",       // no peer ID
+			"dnsaddr=garbage",                      // malformed
+		},
+		"_dnsaddr.b.example.com": {
+			"dnsaddr=/ip4/10.0.0.3/tcp/2020/p2p/" + idB,
+			"dnsaddr=/ip4/10.0.0.5/tcp/2020/p2p/" + idA, // not the peer in the parent record
+			"dnsaddr=/dnsaddr/c.example.com",            // too deep
+		},
+		"_dnsaddr.c.example.com": {
+			"dnsaddr=/ip4/10.0.0.6/tcp/2020/p2p/" + idA,
+		},
+	}
+
+	t.Run("Discover", func(t *testing.T) {
+		ps := discover(t, boot.DNS{Domain: "example.com", Resolver: r})
+		require.Len(t, ps, 2)
+
+		assert.Equal(t, idA, ps[0].ID.String())
+		assert.Equal(t, []string{
+			"/ip4/10.0.0.1/tcp/2020",
+			"/ip4/10.0.0.2/tcp/2020",
+		}, addrs(ps[0]))
+
+		assert.Equal(t, idB, ps[1].ID.String())
+		assert.Equal(t, []string{"/ip4/10.0.0.3/tcp/2020"}, addrs(ps[1]))
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		ps := discover(t, boot.DNS{Domain: "example.com", Resolver: r}, boot.WithLimit(1))
+		require.Len(t, ps, 1)
+		assert.Equal(t, idA, ps[0].ID.String())
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := boot.DNS{Domain: "missing.example.com", Resolver: r}.
+			DiscoverPeers(context.Background())
+		assert.Error(t, err)
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+
+		_, err := boot.DNS{Domain: "slow.example.com", Resolver: r}.DiscoverPeers(ctx)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error %v", err)
+	})
+
+	t.Run("Loggable", func(t *testing.T) {
+		m := boot.DNS{Domain: "example.com"}.Loggable()
+		assert.Equal(t, "example.com", m["boot_domain"])
+	})
+}
+
+func discover(t *testing.T, d boot.DNS, opt ...boot.Option) (ps []peer.AddrInfo) {
+	ch, err := d.DiscoverPeers(context.Background(), opt...)
+	require.NoError(t, err)
+
+	for info := range ch {
+		ps = append(ps, info)
+	}
+
+	return
+}
+
+func addrs(info peer.AddrInfo) []string {
+	ss := make([]string, len(info.Addrs))
+	for i, a := range info.Addrs {
+		ss[i] = a.String()
+	}
+	return ss
+}
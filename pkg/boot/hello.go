@@ -0,0 +1,195 @@
+package boot
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/pkg/errors"
+
+	ww "github.com/wetware/ww/pkg"
+)
+
+const (
+	// HelloProtocol is used to check that a discovered peer belongs to the same cluster,
+	// and speaks a compatible version of the wetware protocol.  It is deliberately
+	// unversioned.
+	HelloProtocol = protocol.ID("/ww/hello")
+
+	// DefaultHandshakeTimeout bounds the handshake with each discovered peer.
+	DefaultHandshakeTimeout = time.Second * 2
+
+	maxHelloSize = 1024
+)
+
+// ErrIncompatible is returned when a discovered peer belongs to another namespace, or
+// speaks an incompatible protocol version.
+var ErrIncompatible = errors.New("incompatible peer")
+
+// Hello is the message exchanged by HelloProtocol.
+type Hello struct {
+	Namespace string `json:"ns"`
+	Version   string `json:"version"`
+}
+
+// Compatible returns nil if the remote peer's hello matches h.  Versions are compatible
+// if they have the same major version, or, for versions below 1.0.0, the same minor
+// version.
+func (h Hello) Compatible(remote Hello) error {
+	if remote.Namespace == "" {
+		return errors.Wrapf(ErrIncompatible, "namespace %s not served", h.Namespace)
+	}
+
+	if h.Namespace != remote.Namespace {
+		return errors.Wrapf(ErrIncompatible, "namespace %s, expected %s",
+			remote.Namespace, h.Namespace)
+	}
+
+	local, err := parseSemver(h.Version)
+	if err != nil {
+		return err
+	}
+
+	other, err := parseSemver(remote.Version)
+	if err != nil {
+		return errors.Wrapf(ErrIncompatible, "version %q: %s", remote.Version, err)
+	}
+
+	if local[0] != other[0] || (local[0] == 0 && local[1] != other[1]) {
+		return errors.Wrapf(ErrIncompatible, "version %s, expected %s",
+			remote.Version, h.Version)
+	}
+
+	return nil
+}
+
+// HelloHandler answers handshakes for namespace ns.  Hosts register it so that they
+// can be checked by peers that discover them.  The namespace is only disclosed to
+// peers that name it, so that unauthenticated callers cannot probe for it.
+func HelloHandler(ns string) network.StreamHandler {
+	local := Hello{Namespace: ns, Version: ww.Version}
+
+	return func(s network.Stream) {
+		defer s.Close()
+
+		// best effort; not all transports support deadlines
+		_ = s.SetDeadline(time.Now().Add(DefaultHandshakeTimeout))
+
+		var remote Hello
+		if err := readHello(s, &remote); err != nil {
+			s.Reset()
+			return
+		}
+
+		reply := local
+		if remote.Namespace != ns {
+			reply.Namespace = ""
+		}
+
+		if err := json.NewEncoder(s).Encode(reply); err != nil {
+			s.Reset()
+		}
+	}
+}
+
+// Handshake opens a HelloProtocol stream to the peer, and checks that its reply is
+// compatible with local.
+func Handshake(ctx context.Context, h host.Host, info peer.AddrInfo, local Hello) error {
+	h.Peerstore().AddAddrs(info.ID, info.Addrs, time.Minute)
+
+	s, err := h.NewStream(ctx, info.ID, HelloProtocol)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	// Not all transports support deadlines, so the stream is also reset when the
+	// context expires.  Resetting a closed stream is a no-op.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Reset()
+		case <-done:
+		}
+	}()
+
+	if err = json.NewEncoder(s).Encode(local); err != nil {
+		s.Reset()
+		return err
+	}
+
+	var remote Hello
+	if err = readHello(s, &remote); err != nil {
+		s.Reset()
+		return err
+	}
+
+	return local.Compatible(remote)
+}
+
+// WithHandshake checks each discovered peer with HelloProtocol, using h to open
+// streams.  Peers that do not belong to namespace ns, or that speak an incompatible
+// protocol version, are dropped and reported through the error handler.  The
+// handshake with each peer is bounded by DefaultHandshakeTimeout.
+func WithHandshake(h host.Host, ns string) Option {
+	local := Hello{Namespace: ns, Version: ww.Version}
+
+	return func(p *Param) error {
+		p.Verify = func(ctx context.Context, info peer.AddrInfo) error {
+			// Strategies such as MDNS may discover the local host, which callers
+			// are expected to skip.  There is nothing to check.
+			if info.ID == h.ID() {
+				return nil
+			}
+
+			ctx, cancel := context.WithTimeout(ctx, DefaultHandshakeTimeout)
+			defer cancel()
+
+			return errors.Wrapf(Handshake(ctx, h, info, local), "handshake with %s", info.ID)
+		}
+
+		return nil
+	}
+}
+
+// WithoutHandshake disables the checks enabled by WithHandshake, e.g. for trusted
+// static lists.
+func WithoutHandshake() Option {
+	return func(p *Param) error {
+		p.Verify = nil
+		return nil
+	}
+}
+
+func readHello(s network.Stream, msg *Hello) error {
+	return json.NewDecoder(io.LimitReader(s, maxHelloSize)).Decode(msg)
+}
+
+func parseSemver(v string) (ns [3]int, err error) {
+	ss := strings.SplitN(strings.TrimPrefix(v, "v"), ".", 3)
+	if len(ss) != 3 {
+		return ns, errors.Errorf("invalid semver %q", v)
+	}
+
+	// ignore pre-release and build metadata
+	if i := strings.IndexAny(ss[2], "-+"); i >= 0 {
+		ss[2] = ss[2][:i]
+	}
+
+	for i, s := range ss {
+		if ns[i], err = strconv.Atoi(s); err != nil {
+			return ns, errors.Errorf("invalid semver %q", v)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,125 @@
+package boot_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/boot"
+)
+
+func TestHelloCompatible(t *testing.T) {
+	t.Parallel()
+
+	local := boot.Hello{Namespace: "ww", Version: "0.3.1"}
+	for _, tt := range []struct {
+		remote boot.Hello
+		ok     bool
+	}{
+		{boot.Hello{Namespace: "ww", Version: "0.3.1"}, true},
+		{boot.Hello{Namespace: "ww", Version: "0.3.7-rc1"}, true},
+		{boot.Hello{Namespace: "ww", Version: "0.4.0"}, false},
+		{boot.Hello{Namespace: "ww", Version: "1.3.1"}, false},
+		{boot.Hello{Namespace: "ww", Version: "garbage"}, false},
+		{boot.Hello{Namespace: "other", Version: "0.3.1"}, false},
+	} {
+		err := local.Compatible(tt.remote)
+		if tt.ok {
+			assert.NoError(t, err, "%v", tt.remote)
+		} else {
+			assert.True(t, errors.Is(err, boot.ErrIncompatible), "unexpected error %v", err)
+		}
+	}
+
+	err := boot.Hello{Namespace: "ww", Version: "1.2.0"}.
+		Compatible(boot.Hello{Namespace: "ww", Version: "1.9.3"})
+	assert.NoError(t, err, "minor versions are compatible after 1.0.0")
+}
+
+func TestHandshake(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.FullMeshLinked(ctx, 4)
+	require.NoError(t, err)
+
+	hs := mn.Hosts()
+	local, same, other, silent := hs[0], hs[1], hs[2], hs[3]
+	same.SetStreamHandler(boot.HelloProtocol, boot.HelloHandler("ww"))
+	other.SetStreamHandler(boot.HelloProtocol, boot.HelloHandler("other"))
+	// silent does not speak the hello protocol
+
+	d := boot.StaticAddrs{p2pAddr(t, other), p2pAddr(t, same), p2pAddr(t, silent)}
+
+	t.Run("Verify", func(t *testing.T) {
+		var errs errorLog
+		ps := collect(t, d,
+			boot.WithHandshake(local, "ww"),
+			boot.WithErrorHandler(errs.Report))
+
+		assert.Equal(t, []peer.ID{same.ID()}, peerIDs(ps))
+		require.Len(t, errs.Errors(), 2)
+
+		var incompatible int
+		for _, err := range errs.Errors() {
+			if errors.Is(err, boot.ErrIncompatible) {
+				incompatible++
+			}
+		}
+		assert.Equal(t, 1, incompatible, "unexpected errors %v", errs.Errors())
+	})
+
+	t.Run("Undisclosed", func(t *testing.T) {
+		// hosts do not reveal their namespace to peers that name another
+		err := boot.Handshake(ctx, local, peer.AddrInfo{ID: other.ID(), Addrs: other.Addrs()},
+			boot.Hello{Namespace: "ww", Version: ww.Version})
+		require.True(t, errors.Is(err, boot.ErrIncompatible), "unexpected error %v", err)
+		assert.NotContains(t, err.Error(), "other")
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		// rejected peers do not count against the limit
+		ps := collect(t, d, boot.WithLimit(1), boot.WithHandshake(local, "ww"))
+		assert.Equal(t, []peer.ID{same.ID()}, peerIDs(ps))
+	})
+
+	t.Run("Self", func(t *testing.T) {
+		// the local host is passed through, for the caller to skip
+		var errs errorLog
+		ps := collect(t, boot.StaticAddrs{p2pAddr(t, local)},
+			boot.WithHandshake(local, "ww"),
+			boot.WithErrorHandler(errs.Report))
+
+		assert.Equal(t, []peer.ID{local.ID()}, peerIDs(ps))
+		assert.Empty(t, errs.Errors())
+	})
+
+	t.Run("Skip", func(t *testing.T) {
+		ps := collect(t, d, boot.WithHandshake(local, "ww"), boot.WithoutHandshake())
+		assert.Len(t, ps, 3)
+	})
+}
+
+func p2pAddr(t *testing.T, h host.Host) multiaddr.Multiaddr {
+	as, err := peer.AddrInfoToP2pAddrs(&peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()})
+	require.NoError(t, err)
+	return as[0]
+}
+
+func peerIDs(ps []peer.AddrInfo) []peer.ID {
+	ids := make([]peer.ID, len(ps))
+	for i, info := range ps {
+		ids[i] = info.ID
+	}
+	return ids
+}
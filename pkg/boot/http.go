@@ -0,0 +1,241 @@
+package boot
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/pkg/errors"
+)
+
+// DefaultHTTPMaxSize is the default cap on the size of a response body.
+const DefaultHTTPMaxSize = 1 << 20 // 1MiB
+
+var _ Strategy = (*HTTP)(nil)
+
+// HTTP discovers bootstrap peers by fetching a list of p2p multiaddrs from a URL.  The
+// response is either a JSON array of strings (Content-Type application/json), or one
+// multiaddr per line.  Each multiaddr must contain a peer ID.  Malformed entries are
+// skipped, and reported through the error handler.
+//
+// Responses are cached, and revalidated with If-None-Match if the server provided an
+// ETag, so that polling is cheap.
+//
+// HTTPHandler implements the server half.
+type HTTP struct {
+	URL string
+
+	// Client performs requests.  If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// MaxSize caps the size of the response body, in bytes.  If zero,
+	// DefaultHTTPMaxSize is used.
+	MaxSize int64
+
+	// AllowInsecure permits plain-text http URLs.  By default, only https is used.
+	AllowInsecure bool
+
+	mu    sync.Mutex
+	etag  string
+	addrs []multiaddr.Multiaddr
+}
+
+// Loggable representation
+func (h *HTTP) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"boot_strategy": "http",
+		"boot_url":      h.URL,
+	}
+}
+
+// DiscoverPeers fetches the peer list.  Peers that appear several times are merged.
+// The request respects the context's deadline.
+func (h *HTTP) DiscoverPeers(ctx context.Context, opt ...Option) (<-chan peer.AddrInfo, error) {
+	var p Param
+	if err := p.Apply(opt); err != nil {
+		return nil, err
+	}
+
+	as, err := h.fetch(ctx, &p)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fetch %s", h.URL)
+	}
+
+	return p.emit(ctx, merge(as)), nil
+}
+
+func (h *HTTP) fetch(ctx context.Context, p *Param) ([]multiaddr.Multiaddr, error) {
+	u, err := url.Parse(h.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "https" && !(u.Scheme == "http" && h.AllowInsecure) {
+		return nil, errors.Errorf("refusing %s scheme; https required", u.Scheme)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json, text/plain")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.etag != "" {
+		req.Header.Set("If-None-Match", h.etag)
+	}
+
+	res, err := h.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusNotModified:
+		return h.addrs, nil
+	case http.StatusOK:
+	default:
+		return nil, errors.Errorf("unexpected status %s", res.Status)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, h.maxSize()+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(body)) > h.maxSize() {
+		return nil, errors.Errorf("response exceeds %d bytes", h.maxSize())
+	}
+
+	ss, err := decodePeerList(res.Header.Get("Content-Type"), body)
+	if err != nil {
+		return nil, err
+	}
+
+	var as []multiaddr.Multiaddr
+	for _, s := range ss {
+		a, err := multiaddr.NewMultiaddr(s)
+		if err == nil {
+			_, err = a.ValueForProtocol(multiaddr.P_P2P)
+		}
+
+		if err != nil {
+			p.report(errors.Wrapf(err, "skipping %q", s))
+			continue
+		}
+
+		as = append(as, a)
+	}
+
+	h.etag, h.addrs = res.Header.Get("ETag"), as
+	return as, nil
+}
+
+func (h *HTTP) client() *http.Client {
+	if h.Client == nil {
+		return http.DefaultClient
+	}
+
+	return h.Client
+}
+
+func (h *HTTP) maxSize() int64 {
+	if h.MaxSize == 0 {
+		return DefaultHTTPMaxSize
+	}
+
+	return h.MaxSize
+}
+
+func decodePeerList(contentType string, body []byte) (ss []string, err error) {
+	if mt, _, _ := mime.ParseMediaType(contentType); mt == "application/json" {
+		err = json.Unmarshal(body, &ss)
+		return
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			ss = append(ss, line)
+		}
+	}
+
+	return ss, scanner.Err()
+}
+
+// HTTPHandler serves the peers returned by peers as a JSON array of p2p multiaddrs, for
+// consumption by the HTTP strategy.  Responses carry an ETag derived from their
+// content, and requests whose If-None-Match header matches it receive 304 Not
+// Modified.
+func HTTPHandler(peers func() []peer.AddrInfo) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		ss := []string{}
+		for _, info := range peers() {
+			as, err := peer.AddrInfoToP2pAddrs(&info)
+			if err != nil {
+				continue
+			}
+
+			for _, a := range as {
+				ss = append(ss, a.String())
+			}
+		}
+
+		body, err := json.Marshal(ss)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		sum := sha256.Sum256(body)
+		etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			w.Write(body)
+		}
+	})
+}
+
+// HostPeers returns a function that reports h and the peers to which it is connected,
+// suitable for HTTPHandler.
+func HostPeers(h host.Host) func() []peer.AddrInfo {
+	return func() []peer.AddrInfo {
+		ps := []peer.AddrInfo{{ID: h.ID(), Addrs: h.Addrs()}}
+		for _, id := range h.Network().Peers() {
+			ps = append(ps, h.Peerstore().PeerInfo(id))
+		}
+
+		return ps
+	}
+}
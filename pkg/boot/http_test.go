@@ -0,0 +1,120 @@
+package boot_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wetware/ww/pkg/boot"
+)
+
+func TestHTTP(t *testing.T) {
+	t.Parallel()
+
+	var (
+		requests, revalidations int32
+		peers                   = []peer.AddrInfo{info(t, idA), info(t, idB)}
+	)
+
+	gateway := boot.HTTPHandler(func() []peer.AddrInfo { return peers })
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get("If-None-Match") != "" {
+			atomic.AddInt32(&revalidations, 1)
+		}
+
+		gateway.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	t.Run("Discover", func(t *testing.T) {
+		d := &boot.HTTP{URL: srv.URL, Client: srv.Client()}
+
+		ps := collect(t, d)
+		assert.Equal(t, []string{idA, idB}, ids(ps))
+
+		// the response is unchanged, so the gateway returns an empty 304, and
+		// peers are served from the cache
+		ps = collect(t, d, boot.WithLimit(1))
+		assert.Equal(t, []string{idA}, ids(ps))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&revalidations))
+	})
+
+	t.Run("Insecure", func(t *testing.T) {
+		_, err := (&boot.HTTP{URL: strings.Replace(srv.URL, "https", "http", 1)}).
+			DiscoverPeers(context.Background())
+		assert.Error(t, err, "plain-text http should be refused by default")
+	})
+}
+
+func TestHTTPResponses(t *testing.T) {
+	t.Parallel()
+
+	serve := func(contentType, body string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			fmt.Fprint(w, body)
+		}))
+	}
+
+	t.Run("Lines", func(t *testing.T) {
+		srv := serve("text/plain", strings.Join([]string{
+			"/ip4/10.0.0.1/tcp/2020/p2p/" + idA,
+			"",
+			"/ip4/10.0.0.2/tcp/2020/p2p/" + idA, // merged
+			"/ip4/10.0.0.3/tcp/2020",            // no peer ID
+			"garbage",
+			"/ip4/10.0.0.4/tcp/2020/p2p/" + idB,
+		}, "\n"))
+		defer srv.Close()
+
+		var errs errorLog
+		ps := collect(t, &boot.HTTP{URL: srv.URL, AllowInsecure: true},
+			boot.WithErrorHandler(errs.Report))
+
+		require.Equal(t, []string{idA, idB}, ids(ps))
+		assert.Len(t, ps[0].Addrs, 2)
+		assert.Len(t, errs.Errors(), 2)
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		srv := serve("text/plain", strings.Repeat("/ip4/10.0.0.1/tcp/2020/p2p/"+idA+"\n", 100))
+		defer srv.Close()
+
+		_, err := (&boot.HTTP{URL: srv.URL, AllowInsecure: true, MaxSize: 1024}).
+			DiscoverPeers(context.Background())
+		assert.Error(t, err)
+	})
+
+	t.Run("Status", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		defer srv.Close()
+
+		_, err := (&boot.HTTP{URL: srv.URL, AllowInsecure: true}).
+			DiscoverPeers(context.Background())
+		assert.Error(t, err)
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+		defer srv.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+
+		_, err := (&boot.HTTP{URL: srv.URL, AllowInsecure: true}).DiscoverPeers(ctx)
+		assert.Error(t, err)
+	})
+}
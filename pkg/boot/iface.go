@@ -0,0 +1,170 @@
+package boot
+
+import (
+	"errors"
+	"net"
+	"path"
+	"strings"
+)
+
+// ErrNoMulticastInterface is returned when no network interface is suitable for
+// multicast announcements.
+var ErrNoMulticastInterface = errors.New("no multicast-capable interface")
+
+// Interface describes a network interface for the purpose of selection.
+type Interface struct {
+	Name  string
+	Flags net.Flags
+	Addrs []net.IP
+}
+
+// Interfaces returns the system's network interfaces.  Interfaces whose addresses
+// cannot be read are returned without addresses, rather than failing the call.  This
+// happens on Windows for some virtual adapters.
+func Interfaces() ([]Interface, error) {
+	ifs, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Interface, len(ifs))
+	for i, ifi := range ifs {
+		out[i] = Interface{Name: ifi.Name, Flags: ifi.Flags}
+
+		as, err := ifi.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, a := range as {
+			if ipn, ok := a.(*net.IPNet); ok {
+				out[i].Addrs = append(out[i].Addrs, ipn.IP)
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// virtual name prefixes of bridges, tunnels and adapters created by hypervisors and
+// container runtimes.  Their addresses are rarely dialable from other hosts.
+var virtual = []string{
+	"docker", "br-", "veth", "virbr", "vEthernet", "vmnet", "vboxnet",
+	"cni", "flannel", "cali", "weave", "tun", "tap", "utun",
+}
+
+// InterfacePolicy scores network interfaces by their suitability for multicast
+// announcements.  Patterns use the syntax of path.Match, e.g. "eth*".
+type InterfacePolicy struct {
+	// Allow, if non-empty, restricts selection to interfaces whose names match one
+	// of the patterns.  Allowed interfaces are not penalized for being virtual.
+	Allow []string
+
+	// Deny excludes interfaces whose names match one of the patterns.
+	Deny []string
+}
+
+// Loggable representation
+func (p InterfacePolicy) Loggable() map[string]interface{} {
+	m := make(map[string]interface{})
+	if len(p.Allow) > 0 {
+		m["if_allow"] = p.Allow
+	}
+
+	if len(p.Deny) > 0 {
+		m["if_deny"] = p.Deny
+	}
+
+	return m
+}
+
+// Score an interface.  Interfaces that are down, loopback, not multicast-capable,
+// denied, or that have no routable address score zero.  Otherwise, physical
+// interfaces score higher than virtual ones, and IPv4 scores higher than IPv6.
+func (p InterfacePolicy) Score(ifi Interface) int {
+	if ifi.Flags&net.FlagUp == 0 ||
+		ifi.Flags&net.FlagMulticast == 0 ||
+		ifi.Flags&net.FlagLoopback != 0 {
+		return 0
+	}
+
+	if match(p.Deny, ifi.Name) {
+		return 0
+	}
+
+	allowed := match(p.Allow, ifi.Name)
+	if len(p.Allow) > 0 && !allowed {
+		return 0
+	}
+
+	score := 0
+	for _, ip := range ifi.Addrs {
+		switch {
+		case !routable(ip):
+			continue
+		case ip.To4() != nil:
+			score = 2
+		case score == 0:
+			score = 1
+		}
+	}
+
+	if score == 0 {
+		return 0
+	}
+
+	if allowed || !isVirtual(ifi.Name) {
+		score += 4
+	}
+
+	return score
+}
+
+// Select returns the highest-scoring interface.  Ties are broken in favor of the
+// interface that appears first.  It returns ErrNoMulticastInterface if no interface
+// has a positive score.
+func (p InterfacePolicy) Select(ifs []Interface) (Interface, error) {
+	var (
+		best  Interface
+		score int
+	)
+
+	for _, ifi := range ifs {
+		if s := p.Score(ifi); s > score {
+			best, score = ifi, s
+		}
+	}
+
+	if score == 0 {
+		return Interface{}, ErrNoMulticastInterface
+	}
+
+	return best, nil
+}
+
+func match(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isVirtual(name string) bool {
+	for _, prefix := range virtual {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func routable(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast()
+}
@@ -0,0 +1,97 @@
+package boot_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wetware/ww/pkg/boot"
+)
+
+const (
+	up        = net.FlagUp | net.FlagMulticast | net.FlagBroadcast
+	loopback  = net.FlagUp | net.FlagLoopback
+	noMcast   = net.FlagUp | net.FlagBroadcast
+	downMcast = net.FlagMulticast
+)
+
+func ips(ss ...string) []net.IP {
+	out := make([]net.IP, len(ss))
+	for i, s := range ss {
+		out[i] = net.ParseIP(s)
+	}
+	return out
+}
+
+func TestInterfacePolicy(t *testing.T) {
+	t.Parallel()
+
+	windows := []boot.Interface{
+		{Name: "Loopback Pseudo-Interface 1", Flags: loopback, Addrs: ips("127.0.0.1", "::1")},
+		{Name: "vEthernet (WSL)", Flags: up, Addrs: ips("172.29.144.1", "fe80::1")},
+		{Name: "vEthernet (Default Switch)", Flags: up, Addrs: ips("172.17.80.1")},
+		{Name: "Wi-Fi", Flags: up, Addrs: ips("fe80::2", "192.168.1.23")},
+		{Name: "Bluetooth Network Connection", Flags: downMcast},
+	}
+
+	docker := []boot.Interface{
+		{Name: "lo", Flags: loopback, Addrs: ips("127.0.0.1")},
+		{Name: "docker0", Flags: up, Addrs: ips("172.17.0.1")},
+		{Name: "br-4f1c2a", Flags: up, Addrs: ips("172.18.0.1")},
+		{Name: "veth12ab", Flags: up, Addrs: ips("fe80::3")},
+		{Name: "eth0", Flags: up, Addrs: ips("10.0.0.5")},
+	}
+
+	container := []boot.Interface{
+		{Name: "lo", Flags: loopback, Addrs: ips("127.0.0.1")},
+		{Name: "eth0", Flags: noMcast, Addrs: ips("10.244.1.7")},
+	}
+
+	for _, tt := range []struct {
+		desc   string
+		policy boot.InterfacePolicy
+		ifs    []boot.Interface
+		want   string
+	}{
+		{"Windows", boot.InterfacePolicy{}, windows, "Wi-Fi"},
+		{"Docker", boot.InterfacePolicy{}, docker, "eth0"},
+		{"Deny", boot.InterfacePolicy{Deny: []string{"eth*"}}, docker, "docker0"},
+		{"Allow", boot.InterfacePolicy{Allow: []string{"br-*"}}, docker, "br-4f1c2a"},
+		{"VirtualOnly", boot.InterfacePolicy{}, docker[:4], "docker0"},
+		{"IPv6Only", boot.InterfacePolicy{}, []boot.Interface{
+			{Name: "en0", Flags: up, Addrs: ips("fe80::4", "2001:db8::1")},
+		}, "en0"},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ifi, err := tt.policy.Select(tt.ifs)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ifi.Name)
+		})
+	}
+
+	for _, tt := range []struct {
+		desc   string
+		policy boot.InterfacePolicy
+		ifs    []boot.Interface
+	}{
+		{"Empty", boot.InterfacePolicy{}, nil},
+		{"NoMulticast", boot.InterfacePolicy{}, container},
+		{"LinkLocalOnly", boot.InterfacePolicy{}, []boot.Interface{
+			{Name: "eth0", Flags: up, Addrs: ips("fe80::5")},
+		}},
+		{"AllowNone", boot.InterfacePolicy{Allow: []string{"wlan*"}}, docker},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := tt.policy.Select(tt.ifs)
+			assert.Equal(t, boot.ErrNoMulticastInterface, err)
+		})
+	}
+}
@@ -3,6 +3,8 @@ package boot
 import (
 	"context"
 	"net"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -15,7 +17,25 @@ import (
 	"github.com/whyrusleeping/mdns"
 )
 
-const defaultTimeout = time.Second * 2
+const (
+	defaultTimeout = time.Second * 2
+
+	// DefaultMDNSMaxEntries is the default cap on the number of entries processed by
+	// each MDNS query.
+	DefaultMDNSMaxEntries = 128
+
+	// maxTXTString is the maximum length of a character-string in a TXT record
+	// (RFC 1035, section 3.3).
+	maxTXTString = 255
+
+	// maxTXTSize bounds the encoded size of the TXT record, so that the response fits
+	// in a single packet alongside the other records.
+	maxTXTSize = 1024
+
+	// txtContinuation prefixes the strings that continue a value too long for a single
+	// character-string.  Neither peer IDs nor multiaddrs begin with it.
+	txtContinuation = "+"
+)
 
 func init() {
 	// logs produce false-positive errors.
@@ -25,10 +45,24 @@ func init() {
 // MDNS discovers bootstrap peers through multicast DNS (RFC 6762)
 type MDNS struct {
 	Namespace string
+
+	// Interface restricts queries and the beacon to a single network interface.  If
+	// nil, MDNS uses all multicast-capable interfaces, and announces the addresses of
+	// the interface selected by Policy.
 	Interface *net.Interface
+	Policy    InterfacePolicy
+
+	// MaxEntries caps the number of entries processed by each query, including
+	// duplicates.  Further entries are dropped without being decoded.  If zero,
+	// DefaultMDNSMaxEntries is used.
+	MaxEntries int
+
+	// Log reports interface selection.  If nil, nothing is logged.
+	Log ww.Logger
 
 	// Beacon stuff.  Will be uninitialized until a call to Start.
-	server interface{ Shutdown() error }
+	server   interface{ Shutdown() error }
+	selected string // name of the interface whose addresses are announced
 }
 
 // Loggable representation
@@ -38,14 +72,25 @@ func (d MDNS) Loggable() map[string]interface{} {
 		"boot_namespace": d.Namespace,
 	}
 
+	for k, v := range d.Policy.Loggable() {
+		m[k] = v
+	}
+
 	if d.Interface != nil {
 		m["interface"] = d.Interface.Name
+	} else if d.selected != "" {
+		m["interface"] = d.selected
 	}
 
 	return m
 }
 
-// DiscoverPeers queries MDNS.
+// DiscoverPeers queries MDNS.  The channel is closed when the query times out.  Errors
+// that occur during the query, such as failing to bind a multicast socket, and entries
+// that could not be decoded, are reported through the error handler.
+//
+// Each peer is reported at most once per query.  Peers that answer several times
+// before being reported are merged, and the limit counts distinct peers.
 func (d MDNS) DiscoverPeers(ctx context.Context, opt ...Option) (<-chan peer.AddrInfo, error) {
 	var p Param
 	if err := p.Apply(opt); err != nil {
@@ -53,9 +98,24 @@ func (d MDNS) DiscoverPeers(ctx context.Context, opt ...Option) (<-chan peer.Add
 	}
 
 	out := make(chan peer.AddrInfo, 1)
-	entries := make(chan *mdns.ServiceEntry, 8)
+
+	if d.Interface == nil {
+		if _, err := d.selectInterface(); errors.Is(err, ErrNoMulticastInterface) {
+			d.logger().WithFields(d.Loggable()).
+				Warn("no multicast-capable interface; mdns discovery disabled")
+			close(out)
+			return out, nil
+		}
+	}
+
+	var (
+		entries = make(chan *mdns.ServiceEntry, 8)
+		done    = make(chan struct{})
+	)
 
 	go func() {
+		defer close(done)
+
 		if err := mdns.Query(&mdns.QueryParam{
 			Timeout:             getTimeout(ctx),
 			Service:             d.namespace(),
@@ -63,113 +123,264 @@ func (d MDNS) DiscoverPeers(ctx context.Context, opt ...Option) (<-chan peer.Add
 			Interface:           d.Interface,
 			WantUnicastResponse: true,
 		}); err != nil {
-			panic(err) // TODO(enhancement):  propagate errors somehow
+			p.report(errors.Wrapf(err, "mdns query for %s", d.namespace()))
 		}
 	}()
 
-	go func() {
-		defer close(out)
+	go d.process(ctx, &p, entries, done, out)
+
+	return out, ctx.Err()
+}
+
+// process decodes the entries of a single query, and emits each distinct peer to out.
+// Duplicate entries are merged into the pending peer until it is emitted, and dropped
+// afterwards.
+func (d MDNS) process(ctx context.Context, p *Param, entries <-chan *mdns.ServiceEntry, done <-chan struct{}, out chan<- peer.AddrInfo) {
+	defer close(out)
 
-		remaining := p.Limit
+	q := newEntryQueue(d.maxEntries())
+	defer q.Report(p)
 
-		for {
+	var (
+		head *peer.AddrInfo // verified peer, awaiting delivery
+		sent int
+	)
+
+	for {
+		// Decode available entries first, so that duplicates are merged before the
+		// peer is emitted.
+		for drained := false; !drained && entries != nil; {
 			select {
 			case entry := <-entries:
-				info, err := d.handleEntry(entry)
-				if err != nil {
-					// TODO(enhancement):  report errors somehow
-					continue
-				}
+				q.Add(d, entry)
+			default:
+				drained = true
+			}
+		}
+
+		if head == nil {
+			if head = q.Front(); head != nil && !p.verify(ctx, *head) {
+				q.Pop()
+				head = nil
+				continue
+			}
+		}
+
+		var (
+			send chan<- peer.AddrInfo // nil unless a peer is pending
+			next peer.AddrInfo
+		)
+
+		if head != nil {
+			send, next = out, *head
+		} else if entries == nil {
+			return // the query has ended, and all peers were delivered
+		}
 
+		select {
+		case entry := <-entries:
+			q.Add(d, entry)
+		case <-done:
+			// the query has ended; entries that were already received are still
+			// delivered.
+			for drained := false; !drained; {
 				select {
-				case out <- info:
-					if p.isLimited() {
-						if remaining--; remaining == 0 {
-							return
-						}
-					}
-				case <-ctx.Done():
+				case entry := <-entries:
+					q.Add(d, entry)
+				default:
+					drained = true
 				}
-			case <-ctx.Done():
+			}
+
+			entries, done = nil, nil
+		case send <- next:
+			q.Pop()
+			head = nil
+
+			if sent++; p.isLimited() && sent == p.Limit {
 				return
 			}
+		case <-ctx.Done():
+			return
 		}
-	}()
-
-	return out, ctx.Err()
+	}
 }
 
-// Signal presence to other peers.
+// Signal presence to other peers.  If no interface is suitable for multicast, the
+// beacon is disabled and Signal returns nil, so that other strategies can be used.
 func (d *MDNS) Signal(_ context.Context, h host.Host) error {
-	p, err := getDialableListenAddrs(h)
+	var prefer []net.IP
+	if d.Interface == nil {
+		ifi, err := d.selectInterface()
+		if errors.Is(err, ErrNoMulticastInterface) {
+			d.logger().WithFields(d.Loggable()).
+				Warn("no multicast-capable interface; mdns beacon disabled")
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		d.selected, prefer = ifi.Name, ifi.Addrs
+		d.logger().WithFields(d.Loggable()).Debug("selected mdns interface")
+	}
+
+	p, err := getDialableListenAddrs(h, prefer)
 	if err != nil {
 		return err
 	}
 
+	txt, n, err := p.TXT(h.ID()) // peer.ID and multiaddrs are stored here
+	if err != nil {
+		return errors.Wrap(err, "encode mdns record")
+	}
+
+	if n < len(p) {
+		d.logger().WithFields(d.Loggable()).
+			WithField("dropped", len(p)-n).
+			Warn("mdns record is full; lowest-ranked addresses not announced")
+	}
+
 	zone, err := mdns.NewMDNSService(h.ID().Pretty(),
 		d.namespace(),
 		"", "",
 		p.Port(), p.IPs(), // these fields are required by MDNS but ignored by ww
-		marshalTxtRecord(h)) // peer.ID and multiaddrs are stored here
+		txt)
 	if err != nil {
 		return err
 	}
 
-	d.server, err = mdns.NewServer(&mdns.Config{
+	if d.server, err = mdns.NewServer(&mdns.Config{
 		Zone:  zone,
 		Iface: d.Interface,
-	})
+	}); err != nil {
+		return errors.Wrapf(err, "start mdns beacon for %s", d.namespace())
+	}
 
-	return err
+	return nil
 }
 
-// Stop the server.  Panics if ListenAndServe was not previously called.
+// Stop the server.  It is a nop if the beacon was disabled.
 func (d MDNS) Stop(context.Context) error {
+	if d.server == nil {
+		return nil
+	}
+
 	return d.server.Shutdown()
 }
 
+func (d MDNS) selectInterface() (Interface, error) {
+	ifs, err := Interfaces()
+	if err != nil {
+		return Interface{}, err
+	}
+
+	return d.Policy.Select(ifs)
+}
+
+func (d MDNS) logger() ww.Logger {
+	if d.Log == nil {
+		return ww.NopLogger()
+	}
+
+	return d.Log
+}
+
+// handleEntry decodes the peer.ID and multiaddrs from the entry's TXT record.
+// Addresses that cannot be parsed are skipped; the entry is rejected only if none
+// remain.
 func (d MDNS) handleEntry(e *mdns.ServiceEntry) (info peer.AddrInfo, err error) {
-	if info.ID, err = peer.IDB58Decode(e.InfoFields[0]); err != nil {
+	fields := joinTXT(e.InfoFields)
+	if len(fields) == 0 {
+		err = errors.Errorf("entry %s has no TXT record", e.Name)
+		return
+	}
+
+	if info.ID, err = peer.IDB58Decode(fields[0]); err != nil {
 		return
 	}
 
-	info.Addrs = make([]multiaddr.Multiaddr, len(e.InfoFields)-1) // 0th item is peer.ID
-	for i, s := range e.InfoFields[1:] {
-		if info.Addrs[i], err = multiaddr.NewMultiaddr(s); err != nil {
-			break
+	for _, s := range fields[1:] { // 0th item is peer.ID
+		if addr, err := multiaddr.NewMultiaddr(s); err == nil {
+			info.Addrs = append(info.Addrs, addr)
 		}
 	}
 
+	if len(info.Addrs) == 0 {
+		err = errors.Errorf("entry %s has no valid address", e.Name)
+	}
+
 	return
 }
 
-func getDialableListenAddrs(h host.Host) (p payload, err error) {
-	var as []multiaddr.Multiaddr
-	if as, err = h.Network().InterfaceListenAddresses(); err != nil {
+// getDialableListenAddrs returns the host's dialable listen addresses.  See newPayload.
+func getDialableListenAddrs(h host.Host, prefer []net.IP) (payload, error) {
+	as, err := h.Network().InterfaceListenAddresses()
+	if err != nil {
 		return nil, err
 	}
 
+	return newPayload(as, prefer)
+}
+
+// newPayload returns the TCP and UDP addresses in as, ordered such that the first
+// address is the one most likely to be dialable by other hosts.  Addresses in prefer
+// come first, followed by other routable addresses.  IPv4 is preferred over IPv6.
+// Loopback and IPv4 link-local addresses are used only as a last resort.  IPv6
+// link-local addresses are dropped, since they are meaningless without a zone, which
+// is local to the announcing host.
+func newPayload(as []multiaddr.Multiaddr, prefer []net.IP) (p payload, err error) {
 	for _, addr := range as {
-		na, err := manet.ToNetAddr(addr)
-		if err != nil {
+		a, ok := newAddress(addr)
+		if !ok {
 			continue
 		}
 
-		switch a := na.(type) {
-		case *net.TCPAddr:
-			p = append(p, address{IP: a.IP, Port: a.Port})
-		case *net.UDPAddr:
-			p = append(p, address{IP: a.IP, Port: a.Port})
+		if a.IP.To4() == nil && (a.IP.IsLinkLocalUnicast() || a.IP.IsLinkLocalMulticast()) {
+			continue
 		}
+
+		p = append(p, a)
 	}
 
 	if len(p) == 0 {
 		return nil, errors.New("failed to resolve external addr from service")
 	}
 
+	rank := func(a address) int {
+		switch {
+		case containsIP(prefer, a.IP):
+			return 0
+		case routable(a.IP) && a.IP.To4() != nil:
+			return 1
+		case routable(a.IP):
+			return 2
+		default:
+			return 3
+		}
+	}
+
+	sort.SliceStable(p, func(i, j int) bool { return rank(p[i]) < rank(p[j]) })
 	return p, nil
 }
 
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, x := range ips {
+		if x.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d MDNS) maxEntries() int {
+	if d.MaxEntries == 0 {
+		return DefaultMDNSMaxEntries
+	}
+
+	return d.MaxEntries
+}
+
 func (d MDNS) namespace() string {
 	if d.Namespace != "" {
 		return d.Namespace
@@ -179,30 +390,222 @@ func (d MDNS) namespace() string {
 }
 
 type address struct {
+	Addr multiaddr.Multiaddr
 	IP   net.IP
 	Port int
 }
 
+// newAddress extracts the IP and port from a multiaddr's thin waist, so that
+// transports layered on top of UDP, such as QUIC, are retained.
+func newAddress(addr multiaddr.Multiaddr) (a address, ok bool) {
+	ip, rest := multiaddr.SplitFirst(addr)
+	if ip == nil || rest == nil {
+		return
+	}
+
+	port, _ := multiaddr.SplitFirst(rest)
+	if port == nil {
+		return
+	}
+
+	na, err := manet.ToNetAddr(ip.Encapsulate(port))
+	if err != nil {
+		return
+	}
+
+	switch na := na.(type) {
+	case *net.TCPAddr:
+		return address{Addr: addr, IP: na.IP, Port: na.Port}, true
+	case *net.UDPAddr:
+		return address{Addr: addr, IP: na.IP, Port: na.Port}, true
+	}
+
+	return
+}
+
 type payload []address
 
+// Port of the preferred address, for the SRV record.
 func (p payload) Port() int {
 	return p[0].Port
 }
 
+// IPs returns the distinct IPs of all addresses, in order, for the A and AAAA records.
 func (p payload) IPs() []net.IP {
-	return []net.IP{p[0].IP}
+	var ips []net.IP
+	for _, a := range p {
+		if !containsIP(ips, a.IP) {
+			ips = append(ips, a.IP)
+		}
+	}
+
+	return ips
+}
+
+// TXT record containing the peer.ID, followed by addresses in order, until the record
+// is full.  Since addresses are ranked, the least dialable are dropped first.
+// Values longer than a character-string are split; see joinTXT.  TXT returns the
+// number of addresses encoded, and fails if there is no room for the peer.ID and at
+// least one address.
+func (p payload) TXT(id peer.ID) (txt []string, n int, err error) {
+	txt = splitTXT(id.String())
+	size := txtSize(txt)
+	if size > maxTXTSize {
+		return nil, 0, errors.Errorf("peer ID exceeds the %d-byte TXT record", maxTXTSize)
+	}
+
+	for _, a := range p {
+		ss := splitTXT(a.Addr.String())
+		if size+txtSize(ss) > maxTXTSize {
+			break // lower-ranked addresses must not displace higher-ranked ones
+		}
+
+		txt = append(txt, ss...)
+		size += txtSize(ss)
+		n++
+	}
+
+	if n == 0 {
+		return nil, 0, errors.Errorf("no address fits in the %d-byte TXT record", maxTXTSize)
+	}
+
+	return txt, n, nil
+}
+
+// splitTXT splits s into character-strings.  Strings after the first are prefixed with
+// txtContinuation.
+func splitTXT(s string) []string {
+	if len(s) <= maxTXTString {
+		return []string{s}
+	}
+
+	ss := []string{s[:maxTXTString]}
+	for s = s[maxTXTString:]; len(s) > 0; {
+		n := maxTXTString - len(txtContinuation)
+		if len(s) < n {
+			n = len(s)
+		}
+
+		ss = append(ss, txtContinuation+s[:n])
+		s = s[n:]
+	}
+
+	return ss
 }
 
-func marshalTxtRecord(h host.Host) []string {
-	out := []string{h.ID().String()}
+// joinTXT reassembles the values split by splitTXT.
+func joinTXT(txt []string) []string {
+	var out []string
+	for _, s := range txt {
+		if len(out) > 0 && strings.HasPrefix(s, txtContinuation) {
+			out[len(out)-1] += strings.TrimPrefix(s, txtContinuation)
+			continue
+		}
 
-	for _, addr := range h.Network().ListenAddresses() {
-		out = append(out, addr.String())
+		out = append(out, s)
 	}
 
 	return out
 }
 
+// txtSize returns the encoded size of the character-strings, each of which has a
+// one-byte length prefix.
+func txtSize(ss []string) (n int) {
+	for _, s := range ss {
+		n += len(s) + 1
+	}
+
+	return
+}
+
+// entryQueue holds the peers decoded from a query's entries, in order of arrival, until
+// they are emitted.
+type entryQueue struct {
+	max, n  int // cap on processed entries, and number processed
+	dropped int
+
+	invalid int
+	lastErr error
+
+	pending []*peer.AddrInfo
+	seen    map[peer.ID]*peer.AddrInfo // nil once the peer has been popped
+}
+
+func newEntryQueue(max int) *entryQueue {
+	return &entryQueue{max: max, seen: make(map[peer.ID]*peer.AddrInfo)}
+}
+
+// Add decodes the entry.  If its peer is pending, the addresses are merged.
+func (q *entryQueue) Add(d MDNS, entry *mdns.ServiceEntry) {
+	if q.n++; q.n > q.max {
+		q.dropped++
+		return
+	}
+
+	info, err := d.handleEntry(entry)
+	if err != nil {
+		q.invalid++
+		q.lastErr = err
+		return
+	}
+
+	pending, ok := q.seen[info.ID]
+	switch {
+	case !ok:
+		q.seen[info.ID] = &info
+		q.pending = append(q.pending, &info)
+	case pending != nil:
+		pending.Addrs = unionAddrs(pending.Addrs, info.Addrs)
+	}
+}
+
+// Front returns the oldest pending peer, or nil if there is none.  Addresses may be
+// merged into it until it is popped.
+func (q *entryQueue) Front() *peer.AddrInfo {
+	if len(q.pending) == 0 {
+		return nil
+	}
+
+	return q.pending[0]
+}
+
+// Pop the oldest pending peer.  Subsequent entries for the peer are ignored.
+func (q *entryQueue) Pop() {
+	q.seen[q.pending[0].ID] = nil
+	q.pending = q.pending[1:]
+}
+
+// Report malformed and dropped entries through the error handler.
+func (q *entryQueue) Report(p *Param) {
+	if q.invalid > 0 {
+		p.report(errors.Wrapf(q.lastErr, "mdns: skipped %d malformed entries", q.invalid))
+	}
+
+	if q.dropped > 0 {
+		p.report(errors.Errorf("mdns: dropped %d entries in excess of %d", q.dropped, q.max))
+	}
+}
+
+func unionAddrs(as, bs []multiaddr.Multiaddr) []multiaddr.Multiaddr {
+	for _, b := range bs {
+		if !containsAddr(as, b) {
+			as = append(as, b)
+		}
+	}
+
+	return as
+}
+
+func containsAddr(as []multiaddr.Multiaddr, addr multiaddr.Multiaddr) bool {
+	for _, a := range as {
+		if a.Equal(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func getTimeout(ctx context.Context) time.Duration {
 	if t, ok := ctx.Deadline(); ok {
 		return t.Sub(time.Now())
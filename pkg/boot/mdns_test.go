@@ -0,0 +1,254 @@
+package boot
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/whyrusleeping/mdns"
+)
+
+const (
+	testID  = "QmNnooDu7bfjPFoTZYxMNLWUQJyrVwtbZg5gBMjTezGAJN"
+	otherID = "QmcgpsyWgH8Y8ajJz1Cu72KnS5uo2Aa2LpzU7kinSupNKC"
+)
+
+func TestPayload(t *testing.T) {
+	t.Parallel()
+
+	as := []multiaddr.Multiaddr{
+		multiaddr.StringCast("/ip4/127.0.0.1/tcp/2020"),
+		multiaddr.StringCast("/ip6/fe80::1/tcp/2020"),
+		multiaddr.StringCast("/ip6/2001:db8::1/tcp/2020"),
+		multiaddr.StringCast("/ip4/192.168.1.23/tcp/2020"),
+		multiaddr.StringCast("/ip4/192.168.1.23/udp/2021/quic"),
+		multiaddr.StringCast("/ip4/10.0.0.5/tcp/2022"),
+	}
+
+	p, err := newPayload(as, []net.IP{net.ParseIP("10.0.0.5")})
+	require.NoError(t, err)
+
+	id, err := peer.Decode(testID)
+	require.NoError(t, err)
+
+	txt, n, err := p.TXT(id)
+	require.NoError(t, err)
+	assert.Equal(t, len(p), n)
+
+	assert.Equal(t, 2022, p.Port(), "SRV record should use the preferred address")
+	assert.Equal(t, []string{
+		testID,
+		"/ip4/10.0.0.5/tcp/2022",
+		"/ip4/192.168.1.23/tcp/2020",
+		"/ip4/192.168.1.23/udp/2021/quic",
+		"/ip6/2001:db8::1/tcp/2020",
+		"/ip4/127.0.0.1/tcp/2020",
+	}, txt, "link-local IPv6 address should be dropped")
+
+	var ips []string
+	for _, ip := range p.IPs() {
+		ips = append(ips, ip.String())
+	}
+	assert.Equal(t, []string{"10.0.0.5", "192.168.1.23", "2001:db8::1", "127.0.0.1"}, ips)
+
+	// round trip
+	info, err := MDNS{}.handleEntry(&mdns.ServiceEntry{Name: "test", InfoFields: txt})
+	require.NoError(t, err)
+	assert.Equal(t, id, info.ID)
+	assert.Len(t, info.Addrs, len(p))
+}
+
+func TestTXT(t *testing.T) {
+	t.Parallel()
+
+	id, err := peer.Decode(testID)
+	require.NoError(t, err)
+
+	t.Run("Budget", func(t *testing.T) {
+		var as []multiaddr.Multiaddr
+		for i := 0; i < 24; i++ {
+			as = append(as,
+				multiaddr.StringCast(fmt.Sprintf("/ip4/127.0.0.%d/tcp/2020", i+1)),
+				multiaddr.StringCast(fmt.Sprintf("/ip6/2001:db8::%d/udp/2020/quic", i+1)),
+				multiaddr.StringCast(fmt.Sprintf("/ip4/203.0.113.%d/tcp/2020", i+1)))
+		}
+
+		p, err := newPayload(as, nil)
+		require.NoError(t, err)
+		require.Len(t, p, 72)
+
+		txt, n, err := p.TXT(id)
+		require.NoError(t, err)
+		assert.True(t, n < len(p), "some addresses should be dropped")
+		assert.True(t, txtSize(txt) <= maxTXTSize, "record exceeds budget")
+
+		for _, s := range txt {
+			assert.True(t, len(s) <= maxTXTString, "string exceeds %d bytes", maxTXTString)
+		}
+
+		info, err := MDNS{}.handleEntry(&mdns.ServiceEntry{Name: "test", InfoFields: txt})
+		require.NoError(t, err)
+		require.Len(t, info.Addrs, n)
+
+		for i, a := range info.Addrs {
+			assert.True(t, p[i].Addr.Equal(a), "addresses should be encoded in order")
+			assert.False(t, p[i].IP.IsLoopback(), "loopback should be dropped first")
+		}
+	})
+
+	t.Run("Split", func(t *testing.T) {
+		long := multiaddr.StringCast("/dns4/" + strings.Repeat("a", 600) + "/tcp/2020")
+		p := payload{{Addr: long}}
+
+		txt, n, err := p.TXT(id)
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+		assert.Len(t, txt, 4, "address should be split across three strings")
+
+		info, err := MDNS{}.handleEntry(&mdns.ServiceEntry{Name: "test", InfoFields: txt})
+		require.NoError(t, err)
+		require.Len(t, info.Addrs, 1)
+		assert.True(t, long.Equal(info.Addrs[0]), "address should be reassembled")
+	})
+
+	t.Run("Overflow", func(t *testing.T) {
+		p := payload{{Addr: multiaddr.StringCast("/ip4/10.0.0.1/tcp/2020")}}
+
+		_, _, err := p.TXT(peer.ID(strings.Repeat("x", maxTXTSize)))
+		assert.Error(t, err, "peer ID should not fit")
+
+		huge := multiaddr.StringCast("/dns4/" + strings.Repeat("a", maxTXTSize) + "/tcp/2020")
+		_, _, err = payload{{Addr: huge}}.TXT(id)
+		assert.Error(t, err, "no address should fit")
+	})
+}
+
+func TestHandleEntry(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		desc   string
+		fields []string
+		addrs  int
+		fail   bool
+	}{
+		{"Empty", nil, 0, true},
+		{"BadID", []string{"garbage", "/ip4/10.0.0.1/tcp/2020"}, 0, true},
+		{"NoAddrs", []string{testID, "garbage"}, 0, true},
+		{"SkipInvalid", []string{testID, "garbage", "/ip4/10.0.0.1/tcp/2020", "/ip6/2001:db8::1/tcp/2020"}, 2, false},
+	} {
+		info, err := MDNS{}.handleEntry(&mdns.ServiceEntry{Name: tt.desc, InfoFields: tt.fields})
+		if tt.fail {
+			assert.Error(t, err, tt.desc)
+			continue
+		}
+
+		require.NoError(t, err, tt.desc)
+		assert.Len(t, info.Addrs, tt.addrs, tt.desc)
+	}
+}
+
+func TestProcessEntries(t *testing.T) {
+	t.Parallel()
+
+	entries := []*mdns.ServiceEntry{
+		{Name: "a", InfoFields: []string{testID, "/ip4/10.0.0.1/tcp/2020"}},
+		{Name: "a", InfoFields: []string{testID, "/ip4/10.0.0.1/tcp/2020"}},
+		{Name: "b", InfoFields: []string{otherID, "/ip4/10.0.0.2/tcp/2020"}},
+		{Name: "a", InfoFields: []string{testID, "/ip4/10.0.0.1/tcp/2020", "/ip4/10.0.0.1/udp/2020/quic"}},
+		{Name: "bad", InfoFields: []string{"garbage"}},
+	}
+
+	t.Run("Dedup", func(t *testing.T) {
+		var errs []error
+		ps := process(t, MDNS{}, entries, WithErrorHandler(func(err error) {
+			errs = append(errs, err)
+		}))
+
+		require.Len(t, ps, 2, "duplicate entries should be merged")
+		assert.Equal(t, testID, ps[0].ID.String())
+		assert.Equal(t, []multiaddr.Multiaddr{
+			multiaddr.StringCast("/ip4/10.0.0.1/tcp/2020"),
+			multiaddr.StringCast("/ip4/10.0.0.1/udp/2020/quic"),
+		}, ps[0].Addrs, "addresses should be merged")
+		assert.Equal(t, otherID, ps[1].ID.String())
+
+		require.Len(t, errs, 1)
+		assert.Contains(t, errs[0].Error(), "skipped 1 malformed entries")
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		ps := process(t, MDNS{}, entries, WithLimit(2))
+		assert.Len(t, ps, 2, "limit should count distinct peers")
+
+		ps = process(t, MDNS{}, entries, WithLimit(1))
+		require.Len(t, ps, 1)
+		assert.Len(t, ps[0].Addrs, 2)
+	})
+
+	t.Run("MaxEntries", func(t *testing.T) {
+		var errs []error
+		ps := process(t, MDNS{MaxEntries: 2}, entries, WithErrorHandler(func(err error) {
+			errs = append(errs, err)
+		}))
+
+		require.Len(t, ps, 1, "entries in excess of the cap should be dropped")
+		assert.Len(t, ps[0].Addrs, 1)
+
+		require.Len(t, errs, 1)
+		assert.Contains(t, errs[0].Error(), "dropped 3 entries")
+	})
+
+	t.Run("AfterDelivery", func(t *testing.T) {
+		var (
+			ctx, cancel = context.WithCancel(context.Background())
+			ch          = make(chan *mdns.ServiceEntry)
+			done        = make(chan struct{})
+			out         = make(chan peer.AddrInfo)
+		)
+		defer cancel()
+
+		var p Param
+		require.NoError(t, p.Apply(nil))
+		go MDNS{}.process(ctx, &p, ch, done, out)
+
+		ch <- entries[0]
+		info := <-out
+		assert.Equal(t, testID, info.ID.String())
+
+		// the peer has already been delivered
+		ch <- entries[3]
+		close(done)
+
+		_, ok := <-out
+		assert.False(t, ok, "peer should be reported at most once")
+	})
+}
+
+func process(t *testing.T, d MDNS, es []*mdns.ServiceEntry, opt ...Option) (ps []peer.AddrInfo) {
+	var p Param
+	require.NoError(t, p.Apply(opt))
+
+	entries := make(chan *mdns.ServiceEntry, len(es))
+	for _, e := range es {
+		entries <- e
+	}
+
+	done := make(chan struct{})
+	close(done)
+
+	out := make(chan peer.AddrInfo)
+	go d.process(context.Background(), &p, entries, done, out)
+
+	for info := range out {
+		ps = append(ps, info)
+	}
+
+	return
+}
@@ -1,11 +1,26 @@
 package boot
 
+import (
+	"context"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
 // Param contains options for discovery queries.  Options passed to DiscoverPeers
 // first populate a Param struct.  Fields are exported for the sake of 3rd-party
 // discovery implementations.
 type Param struct {
 	Limit int
 
+	// OnError, if non-nil, is called with errors that do not prevent discovery from
+	// proceeding, e.g. the failure of a single strategy in a composition.
+	OnError func(error)
+
+	// Verify, if non-nil, is called on each discovered peer before it is emitted.
+	// Peers that fail verification are dropped, and the error is reported through
+	// OnError.  Dropped peers do not count against Limit.
+	Verify func(context.Context, peer.AddrInfo) error
+
 	// Custom provides a place for 3rd-party Strategies to set implementation-specific
 	// options.  As with context.context, developers SHOULD use unexported types as keys
 	// to avoid collisions.
@@ -36,3 +51,12 @@ func WithLimit(lim int) Option {
 		return nil
 	}
 }
+
+// WithErrorHandler sets a callback for errors that occur without aborting discovery.
+// The callback may be called from multiple goroutines.
+func WithErrorHandler(f func(error)) Option {
+	return func(p *Param) error {
+		p.OnError = f
+		return nil
+	}
+}
@@ -0,0 +1,115 @@
+package client_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	capnp "zombiezen.com/go/capnproto2"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/boot"
+	"github.com/wetware/ww/pkg/client"
+	"github.com/wetware/ww/pkg/host"
+	"github.com/wetware/ww/pkg/lang/core"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
+)
+
+func TestToken(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	h, err := host.New(
+		host.WithNamespace("ww.test.token"),
+		host.WithListenAddrString("/ip4/127.0.0.1/tcp/0"),
+		host.WithBootStrategy(boot.StaticAddrs{}),
+		host.WithToken("admin", anchorutil.AllOps),
+		host.WithToken("reader", anchorutil.ReadOnly))
+	require.NoError(t, err)
+	defer h.Close()
+
+	as, err := peer.AddrInfoToP2pAddrs(&peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()})
+	require.NoError(t, err)
+
+	dial := func(t *testing.T, opt ...client.Option) client.Client {
+		c, err := client.Dial(ctx, append([]client.Option{
+			client.WithNamespace("ww.test.token"),
+			client.WithStrategy(boot.StaticAddrs(as)),
+		}, opt...)...)
+		require.NoError(t, err)
+		return c
+	}
+
+	v, err := core.NewString(capnp.SingleSegment(nil), "bar")
+	require.NoError(t, err)
+
+	t.Run("Admin", func(t *testing.T) {
+		c := dial(t, client.WithToken("admin"))
+		defer c.Close()
+
+		a := c.Walk(ctx, []string{h.ID().String(), "foo"})
+		defer a.Release()
+
+		require.NoError(t, a.Store(ctx, v))
+	})
+
+	t.Run("Reader", func(t *testing.T) {
+		c := dial(t, client.WithToken("reader"))
+		defer c.Close()
+
+		as, err := c.Ls(ctx)
+		require.NoError(t, err)
+		require.Len(t, as, 1)
+
+		a := c.Walk(ctx, []string{h.ID().String(), "foo"})
+		defer a.Release()
+
+		err = a.Store(ctx, v)
+		assert.True(t, errors.Is(err, ww.ErrNotPermitted), "unexpected error %v", err)
+	})
+
+	t.Run("Denied", func(t *testing.T) {
+		for _, token := range []string{"", "guess"} {
+			c := dial(t, client.WithToken(token))
+
+			_, err := c.Ls(ctx)
+			assert.True(t, errors.Is(err, ww.ErrNotPermitted), "unexpected error %v", err)
+
+			c.Close()
+		}
+	})
+
+	t.Run("Member", func(t *testing.T) {
+		// any peer can join the cluster by publishing heartbeats on its topic, so
+		// membership must not confer access.
+		c := dial(t)
+		defer c.Close()
+
+		topic, err := c.Join("") // the namespace topic itself
+		require.NoError(t, err)
+
+		b := make([]byte, binary.MaxVarintLen64)
+		b = b[:binary.PutUvarint(b, uint64(time.Minute))]
+
+		id := c.Loggable()["id"].(peer.ID)
+		require.Eventually(t, func() bool {
+			require.NoError(t, topic.Publish(ctx, b))
+			for _, member := range h.Peers() {
+				if member == id {
+					return true
+				}
+			}
+			return false
+		}, time.Second*10, time.Millisecond*100, "client did not join the cluster")
+
+		_, err = c.Ls(ctx)
+		assert.True(t, errors.Is(err, ww.ErrNotPermitted), "unexpected error %v", err)
+	})
+}
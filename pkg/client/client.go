@@ -10,10 +10,12 @@ import (
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/peer"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/multiformats/go-multiaddr"
 
 	ww "github.com/wetware/ww/pkg"
 	"github.com/wetware/ww/pkg/internal/rpc"
 	"github.com/wetware/ww/pkg/internal/rpc/anchor"
+	"github.com/wetware/ww/pkg/runtime/svc/heartbeat"
 	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
 )
 
@@ -21,6 +23,7 @@ import (
 type Client struct {
 	app *fx.App
 
+	h  host.Host
 	id peer.ID
 	ns string
 
@@ -63,6 +66,18 @@ func (c Client) Join(topic string) (Topic, error) {
 	return c.ps.Join(topic)
 }
 
+// Members returns a view of the cluster's members, which is maintained from the hosts'
+// heartbeats until ctx expires.  The view is initially empty; each host appears when
+// its next heartbeat is received.
+func (c Client) Members(ctx context.Context) (*heartbeat.View, error) {
+	return heartbeat.Watch(ctx, c.ps.ps, c.ns)
+}
+
+// Addrs returns the addresses at which the client knows the peer to be reachable.
+func (c Client) Addrs(id peer.ID) []multiaddr.Multiaddr {
+	return c.h.Peerstore().Addrs(id)
+}
+
 // Name of the anchor.  Clients represent the global anchor, so are always named with
 // an empty string.
 func (c Client) Name() string { return "" }
@@ -77,6 +92,12 @@ func (c Client) Ls(ctx context.Context) ([]ww.Anchor, error) {
 	return anchor.Ls(ctx, c.term, rpc.AutoDial{})
 }
 
+// Iter lists the hosts in the cluster, as Ls does.  A host is dialed when the iterator
+// is first advanced.
+func (c Client) Iter(ctx context.Context) ww.AnchorIterator {
+	return anchor.Iter(ctx, c.term, rpc.AutoDial{})
+}
+
 // Walk the Anchor hierarchy.
 func (c Client) Walk(ctx context.Context, path []string) ww.Anchor {
 	if anchorpath.Root(path) {
@@ -101,6 +122,9 @@ func (c Client) Go(context.Context, ...ww.Any) (ww.Any, error) {
 	return nil, errors.New("not implemented")
 }
 
+// Release is a nop.  Use Close to disconnect the client.
+func (c Client) Release() {}
+
 /*
 	go.uber.org/fx
 */
@@ -108,16 +132,23 @@ func (c Client) Go(context.Context, ...ww.Any) (ww.Any, error) {
 type clientParams struct {
 	fx.In
 
-	Host      host.Host
-	Namespace string `name:"ns"`
-	PubSub    *pubsub.PubSub
+	Host       host.Host
+	Namespace  string `name:"ns"`
+	PubSub     *pubsub.PubSub
+	Retry      rpc.RetryPolicy
+	Instrument rpc.Instrument `optional:"true"`
+	Token      string         `name:"token"`
 }
 
 func newClient(ctx context.Context, lx fx.Lifecycle, ps clientParams) Client {
 	return Client{
-		ns:   ps.Namespace,
-		id:   ps.Host.ID(),
-		term: rpc.NewTerminal(ps.Host),
-		ps:   newTopicSet(ps.Namespace, ps.PubSub),
+		h:  ps.Host,
+		ns: ps.Namespace,
+		id: ps.Host.ID(),
+		term: rpc.NewTerminal(ps.Host).
+			WithRetry(ps.Retry).
+			WithInstrument(ps.Instrument).
+			WithCredential(rpc.Credential{Namespace: ps.Namespace, Token: ps.Token}),
+		ps: newTopicSet(ps.Namespace, ps.PubSub),
 	}
 }
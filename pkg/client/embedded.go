@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/pkg/errors"
+
+	"github.com/wetware/ww/pkg/boot"
+	"github.com/wetware/ww/pkg/host"
+)
+
+// EmbeddedClient is a Client that is connected to a single, in-process host.
+type EmbeddedClient struct {
+	Client
+	Host host.Host
+}
+
+// Embedded starts a single-node cluster in the current process, and returns a client
+// that is connected to it.  It is intended for local development and for testing code
+// that is written against the client API.
+//
+// By default, the host listens on a random TCP port on the IPv4 loopback interface,
+// and performs no peer discovery.  Client and host are separate libp2p peers, so they
+// need a transport to reach one another; there is no in-process transport.  Use
+// WithEmbeddedListenAddr to choose a different address.
+//
+// Unless WithNamespace is specified, the cluster is assigned a random namespace so that
+// it does not interfere with other clusters running on the same machine.  Client and
+// host communicate through the same capabilities that are used by a multi-node
+// cluster.  The client's logger and namespace are shared with the host.  Closing the
+// client also stops the host.
+func Embedded(ctx context.Context, opt ...Option) (c EmbeddedClient, err error) {
+	var nonce [8]byte
+	if _, err = rand.Read(nonce[:]); err != nil {
+		return
+	}
+
+	var cfg Config
+	for _, f := range withDefault(append([]Option{
+		WithNamespace("ww.embedded." + hex.EncodeToString(nonce[:])),
+	}, opt...)) {
+		if err = f(&cfg); err != nil {
+			return
+		}
+	}
+
+	if c.Host, err = host.New(
+		host.WithLogger(cfg.log),
+		host.WithNamespace(cfg.ns),
+		host.WithListenAddrString(cfg.embedAddrs...),
+		host.WithBootStrategy(boot.StaticAddrs{}),
+	); err != nil {
+		return c, errors.Wrap(err, "start embedded host")
+	}
+
+	as, err := peer.AddrInfoToP2pAddrs(&peer.AddrInfo{
+		ID:    c.Host.ID(),
+		Addrs: c.Host.Addrs(),
+	})
+	if err != nil {
+		c.Host.Close()
+		return c, err
+	}
+
+	if c.Client, err = Dial(ctx, append(opt,
+		WithNamespace(cfg.ns),
+		WithStrategy(boot.StaticAddrs(as)))...); err != nil {
+		c.Host.Close()
+	}
+
+	return
+}
+
+// Close the client, and stop the embedded host.
+func (c EmbeddedClient) Close() error {
+	if err := c.Client.Close(); err != nil {
+		c.Host.Close()
+		return err
+	}
+
+	return c.Host.Close()
+}
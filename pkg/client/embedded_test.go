@@ -0,0 +1,66 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	capnp "zombiezen.com/go/capnproto2"
+
+	"github.com/wetware/ww/pkg/client"
+	"github.com/wetware/ww/pkg/lang/core"
+)
+
+func TestEmbedded(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	c, err := client.Embedded(ctx)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		as, err := c.Ls(ctx)
+		return err == nil && len(as) == 1 && as[0].Name() == c.Host.ID().String()
+	}, time.Second*5, time.Millisecond*50, "embedded host should be visible to client")
+
+	start := time.Now()
+	require.NoError(t, c.Close())
+	assert.Less(t, int64(time.Since(start)), int64(time.Second*5), "shutdown should be fast")
+}
+
+func TestEmbeddedListenAddr(t *testing.T) {
+	t.Parallel()
+
+	_, err := client.Embedded(context.Background(),
+		client.WithEmbeddedListenAddr("not a multiaddr"))
+	assert.Error(t, err)
+}
+
+func TestEmbeddedStore(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	c, err := client.Embedded(ctx)
+	require.NoError(t, err)
+	defer c.Close()
+
+	a := c.Walk(ctx, []string{c.Host.ID().String(), "foo"})
+	defer a.Release()
+
+	v, err := core.NewString(capnp.SingleSegment(nil), "bar\x00\xff")
+	require.NoError(t, err)
+	require.NoError(t, a.Store(ctx, v))
+
+	got, err := a.Load(ctx)
+	require.NoError(t, err)
+
+	s, err := got.Value().Str()
+	require.NoError(t, err)
+	assert.Equal(t, "bar\x00\xff", s, "stored value should be loaded intact")
+}
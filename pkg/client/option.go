@@ -1,13 +1,17 @@
 package client
 
 import (
+	"time"
+
 	"github.com/lthibault/log"
 
 	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/sync"
+	"github.com/multiformats/go-multiaddr"
 
 	ww "github.com/wetware/ww/pkg"
 	"github.com/wetware/ww/pkg/boot"
+	"github.com/wetware/ww/pkg/internal/rpc"
 )
 
 // Option type for Client
@@ -45,6 +49,93 @@ func WithStrategy(d boot.Strategy) Option {
 	}
 }
 
+// WithEmbeddedListenAddr sets the listen address(es) of the host started by Embedded.
+// It has no effect on Dial.  The default is "/ip4/127.0.0.1/tcp/0", i.e. a random TCP
+// port on the IPv4 loopback interface.
+func WithEmbeddedListenAddr(addrs ...string) Option {
+	return func(c *Config) (err error) {
+		for _, s := range addrs {
+			if _, err = multiaddr.NewMultiaddr(s); err != nil {
+				return
+			}
+		}
+
+		c.embedAddrs = addrs
+		return
+	}
+}
+
+// WithDialRetry sets how many times the client attempts to open a stream to a host,
+// and the delay before the first retry.  Subsequent delays double, up to a ceiling,
+// and are randomized.  Attempts < 1 disables retries.
+func WithDialRetry(attempts int, backoff time.Duration) Option {
+	return func(c *Config) (err error) {
+		c.retry = rpc.RetryPolicy{
+			Attempts: attempts,
+			Base:     backoff,
+			Jitter:   .2,
+		}
+		return
+	}
+}
+
+// WithRPCMetrics enables the recording of RPC latency, in-flight calls and errors.  The
+// metrics are shared by all hosts and clients in the process, and are exported as the
+// expvar "ww_rpc".
+func WithRPCMetrics(enable bool) Option {
+	return func(c *Config) (err error) {
+		c.instrument = nil
+		if enable {
+			c.instrument = rpc.DefaultMetrics()
+		}
+		return
+	}
+}
+
+// WithRPCTrace logs each stream opened and each call made by the client to log, with
+// its duration.  If detail is true, the anchor path of each call, and the size of the
+// value it carries, are logged as well.  Entries are logged at info level.  Nil
+// disables tracing, which is the default.
+func WithRPCTrace(log ww.Logger, detail bool) Option {
+	return func(c *Config) (err error) {
+		c.trace = nil
+		if log != nil {
+			c.trace = rpc.Trace{Log: log, Detail: detail}
+		}
+		return
+	}
+}
+
+// WithHandshake enables the hello handshake with discovered peers, which drops peers
+// from other namespaces or with incompatible protocol versions.  It is enabled by
+// default, and can be disabled for trusted static peer lists.
+func WithHandshake(enable bool) Option {
+	return func(c *Config) (err error) {
+		c.skipHandshake = !enable
+		return
+	}
+}
+
+// WithToken sets the token that the client presents to hosts that require
+// authentication.  The host grants the client the operations associated with the
+// token.  By default, no token is presented.
+func WithToken(token string) Option {
+	return func(c *Config) (err error) {
+		c.token = token
+		return
+	}
+}
+
+// WithSecurity restricts the transports that secure the client's connections to those
+// named, i.e. "noise" or "tls", in order of preference.  By default, both are
+// supported.
+func WithSecurity(names ...string) Option {
+	return func(c *Config) (err error) {
+		c.security = names
+		return
+	}
+}
+
 func withCardinality(k, highwater int) Option {
 	return func(c *Config) (err error) {
 		c.kmin = k
@@ -69,7 +160,9 @@ func withDefault(opt []Option) []Option {
 		WithLogger(nil),
 		WithNamespace("ww"),
 		WithStrategy(nil),
+		WithDialRetry(3, rpc.DefaultRetryBase),
 		withCardinality(3, 64),
 		withDataStore(nil),
+		WithEmbeddedListenAddr("/ip4/127.0.0.1/tcp/0"),
 	}, opt...)
 }
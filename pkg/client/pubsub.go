@@ -95,6 +95,30 @@ func (t Topic) Subscribe(ctx context.Context) (Subscription, error) {
 	return subscribe(ctx, t.name, t.t)
 }
 
+// Messages returns the data of each message published to the topic, until ctx
+// expires.  Topic thus satisfies core.TopicHandle, so that scripts can use it.
+func (t Topic) Messages(ctx context.Context) (<-chan []byte, error) {
+	sub, err := t.Subscribe(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan []byte)
+	go func() {
+		defer close(ch)
+
+		for msg := range sub.C {
+			select {
+			case ch <- msg.Data:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
 func subscribe(ctx context.Context, name string, t *pubsub.Topic) (s Subscription, err error) {
 	s.topic = name
 	if s.sub, err = t.Subscribe(); err != nil {
@@ -22,6 +22,7 @@ import (
 	ctxutil "github.com/wetware/ww/internal/util/ctx"
 	hostutil "github.com/wetware/ww/internal/util/host"
 	"github.com/wetware/ww/pkg/internal/p2p"
+	"github.com/wetware/ww/pkg/internal/rpc"
 
 	// wetware public
 	ww "github.com/wetware/ww/pkg"
@@ -57,6 +58,15 @@ type Config struct {
 	ds         datastore.Batching
 	d          boot.Strategy
 	kmin, kmax int
+	retry      rpc.RetryPolicy
+	instrument rpc.Instrument
+	trace      rpc.Instrument
+	token      string
+	security   []string
+
+	skipHandshake bool
+
+	embedAddrs []string // listen addrs for the embedded host
 }
 
 func (cfg Config) export(ctx context.Context) fx.Option {
@@ -83,10 +93,20 @@ func (cfg Config) options(lx fx.Lifecycle) (mod module, err error) {
 	mod.Boot = cfg.d
 	mod.KMin = cfg.kmin
 	mod.KMax = cfg.kmax
+	mod.Retry = cfg.retry
+	mod.Instrument = rpc.Tee(cfg.instrument, cfg.trace)
+	mod.SkipHandshake = cfg.skipHandshake
+	mod.Token = cfg.token
+
+	var security libp2p.Option
+	if security, err = hostutil.Security(cfg.security...); err != nil {
+		return
+	}
 
 	// options for host.Host
 	mod.HostOpt = []config.Option{
 		hostutil.MaybePrivate(cfg.psk),
+		security,
 		libp2p.Ping(false),
 		libp2p.NoListenAddrs, // also disables relay
 		libp2p.UserAgent("ww-client"),
@@ -110,8 +130,13 @@ type module struct {
 	KMin      int    `name:"kmin"`
 	KMax      int    `name:"kmax"`
 
-	Datastore datastore.Batching
-	Boot      boot.Strategy
+	Datastore  datastore.Batching
+	Boot       boot.Strategy
+	Retry      rpc.RetryPolicy
+	Instrument rpc.Instrument
+
+	SkipHandshake bool   `name:"skip_handshake"`
+	Token         string `name:"token"`
 
 	HostOpt []config.Option
 	DHTOpt  []dual.Option
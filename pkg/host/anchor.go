@@ -2,6 +2,7 @@ package host
 
 import (
 	"context"
+	"sort"
 
 	"github.com/pkg/errors"
 	"go.uber.org/fx"
@@ -9,7 +10,10 @@ import (
 	capnp "zombiezen.com/go/capnproto2"
 	"zombiezen.com/go/capnproto2/server"
 
+	"github.com/libp2p/go-libp2p-core/event"
 	host "github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/protocol"
 
 	"github.com/wetware/ww/internal/mem"
@@ -19,8 +23,10 @@ import (
 	"github.com/wetware/ww/pkg/internal/rpc/anchor"
 	"github.com/wetware/ww/pkg/internal/tree"
 	"github.com/wetware/ww/pkg/lang/core"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
 	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
 	memutil "github.com/wetware/ww/pkg/util/mem"
+	"github.com/wetware/ww/pkg/util/redact"
 )
 
 /*
@@ -32,6 +38,7 @@ var (
 	_ ww.Anchor = (*localAnchor)(nil)
 
 	_ rpc.Capability = (*rootAnchorCap)(nil)
+	_ rpc.Attenuator = (*rootAnchorCap)(nil)
 
 	_ mem.Anchor_Server = (*rootAnchorCap)(nil)
 	_ mem.Anchor_Server = (*anchorCap)(nil)
@@ -40,9 +47,15 @@ var (
 type anchorParams struct {
 	fx.In
 
-	Log     ww.Logger
-	Host    host.Host
-	Cluster cluster.PeerSet
+	Log        ww.Logger
+	Namespace  string `name:"ns"`
+	Host       host.Host
+	Cluster    cluster.PeerSet
+	Redactor   *redact.Redactor
+	LogValues  bool `name:"log_values"`
+	Quotas     *quotas
+	Retry      rpc.RetryPolicy
+	Instrument rpc.Instrument `optional:"true"`
 }
 
 type anchorOut struct {
@@ -51,32 +64,74 @@ type anchorOut struct {
 	Handler rpc.Capability `group:"rpc"`
 }
 
-func newAnchor(ps anchorParams) (out anchorOut) {
-	out.Handler = rootAnchorCap{
-		root: newRootAnchor(ps.Log, ps.Cluster, ps.Host),
-	}
+func newAnchor(lx fx.Lifecycle, ps anchorParams) (out anchorOut) {
+	root := newRootAnchor(ps.Log, ps.Redactor, ps.Cluster, ps.Host)
+	root.logValues = ps.LogValues
+	root.quotas = ps.Quotas
+	root.term = root.term.
+		WithRetry(ps.Retry).
+		WithInstrument(ps.Instrument).
+		WithCredential(rpc.Credential{Namespace: ps.Namespace})
+	root.proxies = anchor.NewProxies(root.term, root.Contains) // dial through the configured terminal
+
+	lx.Append(invalidator(ps.Host, root.proxies))
+
+	out.Handler = rootAnchorCap{root: root}
 
 	return
 }
 
+// invalidator drops the connection to a remote host's anchor tree when the host
+// disconnects.
+func invalidator(h host.Host, ps *anchor.Proxies) fx.Hook {
+	var sub event.Subscription
+	return fx.Hook{
+		OnStart: func(context.Context) (err error) {
+			if sub, err = h.EventBus().Subscribe(new(event.EvtPeerConnectednessChanged)); err == nil {
+				go func() {
+					for v := range sub.Out() {
+						if ev := v.(event.EvtPeerConnectednessChanged); ev.Connectedness == network.NotConnected {
+							ps.Invalidate(ev.Peer)
+						}
+					}
+				}()
+			}
+
+			return
+		},
+		OnStop: func(context.Context) error {
+			defer ps.Close()
+			return sub.Close()
+		},
+	}
+}
+
 type rootAnchor struct {
-	log ww.Logger
+	log       ww.Logger
+	redact    *redact.Redactor
+	logValues bool
+	quotas    *quotas
 	// env core.Env
 	peerProvider
 
+	host      host.Host
 	localPath string
 	node      tree.Node
 	term      rpc.Terminal
+	proxies   *anchor.Proxies
 }
 
-func newRootAnchor(log ww.Logger, ps peerProvider, h host.Host) *rootAnchor {
+func newRootAnchor(log ww.Logger, r *redact.Redactor, ps peerProvider, h host.Host) *rootAnchor {
 	root := &rootAnchor{
 		log:          log.WithField("path", "/"),
+		redact:       r,
 		peerProvider: ps,
+		host:         h,
 		localPath:    h.ID().String(),
 		node:         tree.New(),
 		term:         rpc.NewTerminal(h),
 	}
+	root.proxies = anchor.NewProxies(root.term, ps.Contains)
 
 	// root.env = lang.New(root)
 	return root
@@ -85,15 +140,41 @@ func newRootAnchor(log ww.Logger, ps peerProvider, h host.Host) *rootAnchor {
 func (rootAnchor) Name() string        { return "" }
 func (root rootAnchor) Path() []string { return []string{} } // TODO: return nil
 
+// Ls returns an anchor for each member of the cluster, including the local host.
+// Connections to hosts that have left the cluster are dropped.
 func (root rootAnchor) Ls(ctx context.Context) ([]ww.Anchor, error) {
-	peers := root.Peers()
+	return anchorutil.Collect(root.Iter(ctx))
+}
+
+// Iter lists the members of the cluster, as Ls does.
+func (root rootAnchor) Iter(context.Context) ww.AnchorIterator {
+	peers := root.members()
+	root.proxies.Retain(peers)
 
 	as := make([]ww.Anchor, len(peers))
 	for i, p := range peers {
 		as[i] = anchor.NewHost(root.term, p)
 	}
 
-	return as, nil
+	return anchorutil.SliceIter(as)
+}
+
+// members of the cluster, sorted by ID.  Members are the local host, and those hosts
+// whose heartbeats have been received.
+func (root rootAnchor) members() peer.IDSlice {
+	ids := root.Peers()
+
+	seen := make(map[peer.ID]struct{}, len(ids)+1)
+	members := make(peer.IDSlice, 0, len(ids)+1)
+	for _, id := range append(ids, root.host.ID()) {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			members = append(members, id)
+		}
+	}
+
+	sort.Sort(members)
+	return members
 }
 
 func (root rootAnchor) Walk(ctx context.Context, path []string) ww.Anchor {
@@ -103,14 +184,17 @@ func (root rootAnchor) Walk(ctx context.Context, path []string) ww.Anchor {
 
 	if root.isLocal(path) {
 		return localAnchor{
-			log: root.log.WithField("path", anchorpath.Join(path)),
+			log:       root.log.WithField("path", anchorpath.Join(path)),
+			redact:    root.redact,
+			logValues: root.logValues,
+			quotas:    root.quotas,
 			// env:  root.env,
 			root: path[0],
 			node: root.node.Walk(path[1:]),
 		}
 	}
 
-	return anchor.Walk(ctx, root.term, rpc.DialString(path[0]), path)
+	return root.proxies.Walk(ctx, path)
 }
 
 func (root rootAnchor) Load(context.Context) (ww.Any, error) {
@@ -126,14 +210,20 @@ func (root rootAnchor) Go(context.Context, ...ww.Any) (ww.Any, error) {
 	return nil, errors.New("not implemented")
 }
 
+// Release is a nop.  The root anchor is owned by the host.
+func (rootAnchor) Release() {}
+
 func (root rootAnchor) isLocal(path []string) bool {
 	return !anchorpath.Root(path) && path[0] == root.localPath
 }
 
 type localAnchor struct {
-	log  ww.Logger
-	root string
-	node tree.Node
+	log       ww.Logger
+	redact    *redact.Redactor
+	logValues bool
+	quotas    *quotas
+	root      string
+	node      tree.Node
 	// env  core.Env
 }
 
@@ -147,24 +237,39 @@ func (a localAnchor) Path() []string {
 
 func (a localAnchor) Name() string { return a.node.Name }
 
-func (a localAnchor) Ls(context.Context) ([]ww.Anchor, error) {
-	ns := a.node.List()
-	as := make([]ww.Anchor, len(ns))
-	for i, n := range ns {
-		as[i] = localAnchor{root: a.root, node: n}
-	}
+func (a localAnchor) Ls(ctx context.Context) ([]ww.Anchor, error) {
+	return anchorutil.Collect(a.Iter(ctx))
+}
 
-	return as, nil
+// Iter lists the children of the anchor at the time of the call.  Each child is
+// constructed as it is returned.
+func (a localAnchor) Iter(context.Context) ww.AnchorIterator {
+	return &localIter{parent: a, ns: a.node.List()}
 }
 
 func (a localAnchor) Walk(_ context.Context, path []string) ww.Anchor {
-	return localAnchor{
-		root: a.root,
-		node: a.node.Walk(path),
+	return a.child(a.node.Walk(path))
+}
+
+func (a localAnchor) child(n tree.Node) localAnchor {
+	child := localAnchor{
+		redact:    a.redact,
+		logValues: a.logValues,
+		quotas:    a.quotas,
+		root:      a.root,
+		node:      n,
 	}
+	child.log = a.log.WithField("path", child.String())
+	return child
 }
 
-func (a localAnchor) Load(context.Context) (ww.Any, error) {
+// Load the value.  If the context has expired, e.g. because a remote caller gave up,
+// Load returns the context's error instead.
+func (a localAnchor) Load(ctx context.Context) (ww.Any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	if val := a.node.Load(); !memutil.IsNil(val) {
 		return core.AsAny(val)
 	}
@@ -172,14 +277,31 @@ func (a localAnchor) Load(context.Context) (ww.Any, error) {
 	return core.Nil{}, nil
 }
 
-func (a localAnchor) Store(_ context.Context, any ww.Any) error {
-	if v := any.Value(); a.node.Store(v) {
+// Store the value, unless the context has expired.
+func (a localAnchor) Store(ctx context.Context, any ww.Any) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ok, err := a.quotas.Store(a.node, any.Value())
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		if a.logValues {
+			a.log.WithField("value", a.redact.Value(a.node.Path(), any)).Debug("value stored")
+		}
+
 		return nil
 	}
 
 	return ww.ErrAnchorNotEmpty
 }
 
+// Release is a nop.  Local anchors are backed by the host's tree, which owns them.
+func (localAnchor) Release() {}
+
 func (a localAnchor) Go(_ context.Context, args ...ww.Any) (p ww.Any, err error) {
 	return nil, errors.New("Host Interpreter NOT IMPLEMENTED (pkg/host/anchor.go")
 	// a.node.Txn(func(t tree.Transaction) {
@@ -194,6 +316,27 @@ func (a localAnchor) Go(_ context.Context, args ...ww.Any) (p ww.Any, err error)
 	// return
 }
 
+type localIter struct {
+	parent localAnchor
+	ns     []tree.Node
+	cur    ww.Anchor
+}
+
+func (it *localIter) Next() bool {
+	if it.cur = nil; len(it.ns) == 0 {
+		return false
+	}
+
+	it.cur, it.ns = it.parent.child(it.ns[0]), it.ns[1:]
+	return true
+}
+
+func (it *localIter) Anchor() ww.Anchor { return it.cur }
+func (it *localIter) Err() error        { return nil }
+
+// Release drops the remaining children.  Local anchors hold no resources.
+func (it *localIter) Release() { it.ns = nil }
+
 type rootAnchorCap struct{ root *rootAnchor }
 
 func (rootAnchorCap) Loggable() map[string]interface{} {
@@ -208,11 +351,19 @@ func (a rootAnchorCap) Client() *capnp.Client {
 	return mem.Anchor_ServerToClient(a, &server.Policy{}).Client
 }
 
+// Attenuate exports the root anchor with the given operations.  Its subanchors are
+// listed with their capabilities, which are attenuated in the same way.
+func (a rootAnchorCap) Attenuate(ops anchorutil.Op) *capnp.Client {
+	root := anchorutil.Attenuate(a.root, ops)
+	return mem.Anchor_ServerToClient(anchorCap{root}, &server.Policy{}).Client
+}
+
 func (a rootAnchorCap) Ls(ctx context.Context, call mem.Anchor_ls) error {
 	hosts, err := a.root.Ls(ctx)
 	if err != nil {
 		return err
 	}
+	defer anchorutil.Release(hosts)
 
 	res, err := call.AllocResults()
 	if err != nil {
@@ -255,13 +406,9 @@ func (a rootAnchorCap) Walk(ctx context.Context, call mem.Anchor_walk) error {
 		return errmem(err)
 	}
 
-	parts := anchorpath.Parts(path)
-
-	// belt-and-suspenders
-	if !a.root.isLocal(parts) {
-		return errors.Errorf("misrouted RPC: host %s received RPC at path %s",
-			a.root.localPath,
-			parts[0])
+	parts, err := walkPath(path)
+	if err != nil {
+		return err
 	}
 
 	res, err := call.AllocResults()
@@ -321,8 +468,12 @@ func (a rootAnchorCap) Go(ctx context.Context, call mem.Anchor_go) error {
 	return res.SetProc(p.Value().Proc())
 }
 
+// anchorCap exports an anchor over RPC.  The anchor is released when the capability
+// is shut down, i.e. when the remote client drops its last reference.
 type anchorCap struct{ anchor ww.Anchor }
 
+func (a anchorCap) Shutdown() { a.anchor.Release() }
+
 func (a anchorCap) Ls(ctx context.Context, call mem.Anchor_ls) error {
 	as, err := a.anchor.Ls(ctx)
 	if err != nil {
@@ -372,7 +523,13 @@ func (a anchorCap) Walk(ctx context.Context, call mem.Anchor_walk) error {
 		return errmem(err)
 	}
 
-	sub := a.anchor.Walk(nil, anchorpath.Parts(path))
+	parts, err := walkPath(path)
+	if err != nil {
+		return err
+	}
+
+	// The walked anchor outlives the call, so it must not be bound to the call's context.
+	sub := a.anchor.Walk(context.Background(), parts)
 
 	res, err := call.AllocResults()
 	if err != nil {
@@ -399,7 +556,12 @@ func (a anchorCap) Load(ctx context.Context, call mem.Anchor_load) error {
 
 func (a anchorCap) Store(ctx context.Context, call mem.Anchor_store) error {
 	raw, err := call.Args().Value()
-	if err == nil {
+	if err != nil {
+		return err
+	}
+
+	// the arguments are reclaimed when the call returns
+	if raw, err = memutil.Copy(capnp.SingleSegment(nil), raw); err != nil {
 		return err
 	}
 
@@ -437,6 +599,17 @@ func (a anchorCap) Go(ctx context.Context, call mem.Anchor_go) error {
 	return res.SetProc(p.Value().Proc())
 }
 
+// walkPath cleans and validates a path received over RPC.  Paths are resolved relative
+// to the anchor being walked, so '..' cannot ascend above it.
+func walkPath(path string) ([]string, error) {
+	path, err := anchorpath.Clean(path)
+	if err == nil {
+		err = anchorpath.Validate(path)
+	}
+
+	return anchorpath.Parts(path), err
+}
+
 func errmem(err error) error {
 	return errors.Wrap(err, "remote memory error")
 }
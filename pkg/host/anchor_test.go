@@ -0,0 +1,169 @@
+package host
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/lthibault/log"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"zombiezen.com/go/capnproto2/server"
+
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/internal/rpc"
+	"github.com/wetware/ww/pkg/internal/rpc/anchor"
+	"github.com/wetware/ww/pkg/internal/tree"
+	"github.com/wetware/ww/pkg/lang/core"
+	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
+	"github.com/wetware/ww/pkg/util/redact"
+)
+
+func TestLocalAnchorContext(t *testing.T) {
+	t.Parallel()
+
+	a := localAnchor{log: log.New(), node: tree.New().Walk([]string{"foo"})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := a.Store(ctx, core.True)
+	assert.True(t, errors.Is(err, context.Canceled), "unexpected error %v", err)
+
+	_, err = a.Load(ctx)
+	assert.True(t, errors.Is(err, context.Canceled), "unexpected error %v", err)
+
+	// nothing was stored
+	v, err := a.Load(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, core.Nil{}, v)
+}
+
+func TestRootAnchorMembers(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	mn := mocknet.New(ctx)
+	a, b := newTestRoot(ctx, t, mn, 3010), newTestRoot(ctx, t, mn, 3011)
+	require.NoError(t, mn.LinkAll())
+	require.NoError(t, mn.ConnectAllButSelf())
+
+	t.Run("NoHeartbeats", func(t *testing.T) {
+		// connected peers are not members until their heartbeats are received
+		as, err := a.Ls(ctx)
+		require.NoError(t, err)
+		require.Len(t, as, 1)
+		assert.Equal(t, a.host.ID().String(), as[0].Name())
+	})
+
+	t.Run("NotMember", func(t *testing.T) {
+		_, err := a.Walk(ctx, []string{b.host.ID().String(), "foo"}).Load(ctx)
+
+		var unreachable ww.HostUnreachableError
+		require.True(t, errors.As(err, &unreachable), "unexpected error %v", err)
+		assert.Equal(t, b.host.ID(), unreachable.Peer)
+		assert.True(t, errors.Is(err, anchor.ErrNotMember), "unexpected error %v", err)
+
+		assert.Zero(t, a.proxies.Len(), "non-members should not be dialed")
+	})
+
+	// a and b receive each other's heartbeats
+	*a.peerProvider.(*staticPeers) = staticPeers{b.host.ID()}
+	*b.peerProvider.(*staticPeers) = staticPeers{a.host.ID()}
+
+	t.Run("Heartbeats", func(t *testing.T) {
+		c := &rootAnchor{peerProvider: staticPeers{b.host.ID()}, host: a.host}
+		assert.Equal(t, sorted(peer.IDSlice{a.host.ID(), b.host.ID()}), c.members())
+	})
+
+	t.Run("Proxy", func(t *testing.T) {
+		// walk a path beneath b, over a's RPC interface
+		client := mem.Anchor_ServerToClient(rootAnchorCap{root: a}, &server.Policy{})
+		defer client.Client.Release()
+
+		f, done := client.Walk(ctx, func(ps mem.Anchor_walk_Params) error {
+			return ps.SetPath(anchorpath.Join([]string{b.host.ID().String(), "foo"}))
+		})
+		defer done()
+
+		sf, free := f.Anchor().Store(ctx, func(ps mem.Anchor_store_Params) error {
+			return ps.SetValue(core.True.Value())
+		})
+		defer free()
+
+		_, err := sf.Struct()
+		require.NoError(t, err)
+
+		v, err := b.Walk(ctx, []string{b.host.ID().String(), "foo"}).Load(ctx)
+		require.NoError(t, err)
+		assert.True(t, v.Value().Bool(), "value should be stored at the remote host")
+
+		assert.Equal(t, 1, a.proxies.Len())
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		require.NoError(t, mn.UnlinkPeers(a.host.ID(), b.host.ID()))
+		require.NoError(t, mn.DisconnectPeers(a.host.ID(), b.host.ID()))
+		a.proxies.Invalidate(b.host.ID())
+
+		_, err := a.Walk(ctx, []string{b.host.ID().String(), "foo"}).Load(ctx)
+
+		var unreachable ww.HostUnreachableError
+		require.True(t, errors.As(err, &unreachable), "unexpected error %v", err)
+		assert.Equal(t, b.host.ID(), unreachable.Peer)
+	})
+}
+
+// newTestRoot returns the root anchor of a host on mn, which serves it over RPC.
+func newTestRoot(ctx context.Context, t *testing.T, mn mocknet.Mocknet, port int) *rootAnchor {
+	sk, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+
+	h, err := mn.AddPeer(sk, multiaddr.StringCast(fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port)))
+	require.NoError(t, err)
+
+	root := newRootAnchor(log.New(), redact.New(), &staticPeers{}, h)
+	root.quotas = newQuotas(nil)
+
+	h.SetStreamHandler(ww.AnchorProtocol, func(s network.Stream) {
+		defer s.Reset()
+
+		cap, err := rpc.Admit(s, nil, rootAnchorCap{root: root})
+		if err == nil {
+			_ = rpc.Handle(ctx, root.log, cap, s)
+		}
+	})
+
+	return root
+}
+
+type staticPeers peer.IDSlice
+
+func (ps staticPeers) Peers() peer.IDSlice { return peer.IDSlice(ps) }
+
+func (ps staticPeers) Contains(id peer.ID) bool {
+	for _, p := range ps {
+		if p == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sorted(ids peer.IDSlice) peer.IDSlice {
+	sort.Sort(ids)
+	return ids
+}
@@ -14,8 +14,11 @@ import (
 	"github.com/pkg/errors"
 
 	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/boot"
 	"github.com/wetware/ww/pkg/cluster"
 	"github.com/wetware/ww/pkg/internal/rpc"
+	"github.com/wetware/ww/pkg/runtime"
+	neighborhood_service "github.com/wetware/ww/pkg/runtime/svc/neighborhood"
 )
 
 // Host .
@@ -23,6 +26,10 @@ type Host struct {
 	ns   string
 	ps   peerProvider
 	host host.Host
+	g    runtime.EventGraph
+	reg  *runtime.Registry
+	tap  *runtime.Tap
+	wm   *neighborhood_service.WaterMarks
 
 	runtime interface {
 		Start(context.Context) error
@@ -52,6 +59,9 @@ func (h Host) Loggable() map[string]interface{} {
 	}
 }
 
+// Namespace of the cluster to which the Host belongs.
+func (h Host) Namespace() string { return h.ns }
+
 // ID of the Host
 func (h Host) ID() peer.ID {
 	return h.host.ID()
@@ -82,6 +92,37 @@ func (h Host) Join(ctx context.Context, info peer.AddrInfo) error {
 	return h.host.Connect(ctx, info)
 }
 
+// EventGraph describes the events produced and consumed by the host's runtime services.
+func (h Host) EventGraph() runtime.EventGraph {
+	return h.g
+}
+
+// Services reports the status of each of the host's runtime services.  It does not
+// block while a service is starting or stopping.
+func (h Host) Services() []runtime.Status {
+	return h.reg.Status()
+}
+
+// Snapshot reports the current state of each running service that implements
+// runtime.Snapshotter.  Services are not blocked while their state is read.
+func (h Host) Snapshot() []runtime.Snapshot {
+	return h.reg.Snapshot()
+}
+
+// Tap returns the recorder of the events emitted by the host's runtime services, or
+// nil if it was not enabled with WithEventTap.
+func (h Host) Tap() *runtime.Tap {
+	return h.tap
+}
+
+// SetWaterMarks changes the neighborhood's kmin and kmax, which must satisfy
+// 0 < kmin <= kmax.  The change is persisted in the host's datastore.  It applies to
+// the neighborhood, prune and repair services, but not to the libp2p connection
+// manager, whose watermarks are fixed when the host starts.
+func (h Host) SetWaterMarks(kmin, kmax int) error {
+	return h.wm.Set(kmin, kmax)
+}
+
 // EventBus provides asynchronous notifications of changes in the host's internal state,
 // or the state of the environment.
 func (h Host) EventBus() event.Bus {
@@ -111,6 +152,7 @@ func start(r interface{ Start(context.Context) error }) error {
 
 type peerProvider interface {
 	Peers() peer.IDSlice
+	Contains(peer.ID) bool
 }
 
 type hostParams struct {
@@ -122,24 +164,46 @@ type hostParams struct {
 
 	Host     host.Host
 	Cluster  cluster.PeerSet
+	Stats    *rpc.StreamStats
+	Guard    *rpc.Guard
 	Handlers []rpc.Capability `group:"rpc"`
+	Runtime  runtime.Config
+
+	WaterMarks *neighborhood_service.WaterMarks
 }
 
 func newHost(ctx context.Context, lx fx.Lifecycle, ps hostParams) Host {
-	h := Host{ns: ps.Namespace, host: ps.Host, ps: ps.Cluster}
+	h := Host{ns: ps.Namespace, host: ps.Host, ps: ps.Cluster, g: ps.Runtime.Graph(), reg: ps.Runtime.Registry, tap: ps.Runtime.Tap, wm: ps.WaterMarks}
+
+	h.host.SetStreamHandler(boot.HelloProtocol, boot.HelloHandler(ps.Namespace))
 
 	for _, cap := range ps.Handlers {
-		h.host.SetStreamHandler(cap.Protocol(), h.handler(ctx, ps.Log, cap))
+		h.host.SetStreamHandler(cap.Protocol(), h.handler(ctx, ps.Log, ps.Stats, ps.Guard, cap))
 	}
 
 	return h
 }
 
-func (h Host) handler(ctx context.Context, log ww.Logger, cap rpc.Capability) network.StreamHandler {
+func (h Host) handler(ctx context.Context, log ww.Logger, stats *rpc.StreamStats, g *rpc.Guard, cap rpc.Capability) network.StreamHandler {
 	return func(s network.Stream) {
-		defer s.Reset()
+		s = stats.Track(s)
+
+		cap, err := rpc.Admit(s, g, cap)
+		if errors.Is(err, rpc.ErrUnauthorized) {
+			// close rather than reset, so that the caller receives the denial
+			defer s.Close()
+		} else {
+			defer s.Reset()
+		}
+
+		if err != nil {
+			stats.Fail(s)
+			log.WithError(err).WithField("peer", s.Conn().RemotePeer()).Debug("caller not admitted")
+			return
+		}
 
 		if err := rpc.Handle(ctx, log.With(h), cap, s); err != nil {
+			stats.Fail(s)
 			log.WithError(err).Debug("failed to terminate connection gracefully")
 		}
 	}
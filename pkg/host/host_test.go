@@ -0,0 +1,28 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wetware/ww/pkg/boot"
+)
+
+func TestStrictEvents(t *testing.T) {
+	t.Parallel()
+
+	// the default services declare a consumer for each event they produce, or
+	// declare it public.
+	h, err := New(
+		WithNamespace("ww.test.strict"),
+		WithListenAddrString("/ip4/127.0.0.1/tcp/0"),
+		WithBootStrategy(boot.StaticAddrs{}),
+		WithStrictEvents(true))
+	require.NoError(t, err)
+	defer h.Close()
+
+	warnings, err := h.EventGraph().Validate()
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+}
@@ -1,16 +1,24 @@
 package host
 
 import (
+	"errors"
 	"time"
 
 	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/sync"
 	"github.com/lthibault/log"
 
+	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/multiformats/go-multiaddr"
 
 	ww "github.com/wetware/ww/pkg"
 	"github.com/wetware/ww/pkg/boot"
+	"github.com/wetware/ww/pkg/internal/rpc"
+	"github.com/wetware/ww/pkg/lang/core"
+	"github.com/wetware/ww/pkg/runtime"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
+	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
+	"github.com/wetware/ww/pkg/util/redact"
 )
 
 // Option type for Host
@@ -65,7 +73,7 @@ func WithListenAddr(addrs ...multiaddr.Multiaddr) Option {
 func WithBootStrategy(b boot.Strategy) Option {
 	return func(c *Config) (err error) {
 		if b == nil {
-			b = &boot.MDNS{Namespace: c.ns}
+			b = &boot.MDNS{Namespace: c.ns, Log: c.log}
 		}
 
 		c.boot = b
@@ -89,6 +97,190 @@ func WithTTL(ttl time.Duration) Option {
 	}
 }
 
+// WithRedaction sets the redactor that is applied to values before they are written
+// to logs (see WithValueLogging).  Rules are matched against paths relative to the host's anchor, and can be
+// updated at any time by calling r.SetRules.  Nil disables redaction.
+func WithRedaction(r *redact.Redactor) Option {
+	if r == nil {
+		r = redact.New()
+	}
+
+	return func(c *Config) (err error) {
+		c.redact = r
+		return
+	}
+}
+
+// WithValueLogging causes stored values to be logged at debug level, after passing
+// through the host's redactor.  Disabled by default.
+func WithValueLogging(enable bool) Option {
+	return func(c *Config) (err error) {
+		c.logValues = enable
+		return
+	}
+}
+
+// WithPrintLimits bounds the size of values that are rendered into logs.  The limits
+// are applied to the host's redactor.
+func WithPrintLimits(l core.Limits) Option {
+	return func(c *Config) (err error) {
+		c.limits = l
+		return
+	}
+}
+
+// WithQuota bounds the storage used by the subtree at path.  As with redaction rules,
+// the path is relative to the host's anchor.  Stores that would exceed the quota fail
+// with ww.ErrQuotaExceeded.  Quotas may be nested; a store must satisfy all of them.
+func WithQuota(path string, q Quota) Option {
+	return func(c *Config) (err error) {
+		if path, err = anchorpath.Clean(path); err != nil {
+			return
+		}
+
+		if c.quotas == nil {
+			c.quotas = make(map[string]Quota)
+		}
+
+		c.quotas[path] = q
+		return
+	}
+}
+
+// WithDialRetry sets how many times the host attempts to open a stream to a remote
+// peer, e.g. when traversing the anchor tree, and the delay before the first retry.
+// Subsequent delays double, up to a ceiling, and are randomized to avoid synchronized
+// retries across the cluster.  Attempts < 1 disables retries.
+func WithDialRetry(attempts int, backoff time.Duration) Option {
+	return func(c *Config) (err error) {
+		c.retry = rpc.RetryPolicy{
+			Attempts: attempts,
+			Base:     backoff,
+			Jitter:   .2,
+		}
+		return
+	}
+}
+
+// WithRPCMetrics enables the recording of RPC latency, in-flight calls and errors.  The
+// metrics are shared by all hosts and clients in the process, and are exported as the
+// expvar "ww_rpc".
+func WithRPCMetrics(enable bool) Option {
+	return func(c *Config) (err error) {
+		c.instrument = nil
+		if enable {
+			c.instrument = rpc.DefaultMetrics()
+		}
+		return
+	}
+}
+
+// WithHandshake enables the hello handshake with discovered peers, which drops peers
+// from other namespaces or with incompatible protocol versions.  It is enabled by
+// default, and can be disabled for trusted static peer lists.
+func WithHandshake(enable bool) Option {
+	return func(c *Config) (err error) {
+		c.skipHandshake = !enable
+		return
+	}
+}
+
+// WithMetrics serves Prometheus metrics at /metrics on addr, e.g. ":9090".  RPC calls,
+// including anchor operations, are instrumented in addition to any instrument set by
+// WithRPCMetrics.  Metrics are disabled by default.
+func WithMetrics(addr string) Option {
+	return func(c *Config) (err error) {
+		c.metricsAddr = addr
+		return
+	}
+}
+
+// WithHealth serves liveness and readiness probes at /healthz and /readyz on addr,
+// e.g. ":8080".  Probes are disabled by default.
+func WithHealth(addr string) Option {
+	return func(c *Config) (err error) {
+		c.healthAddr = addr
+		return
+	}
+}
+
+// WithStrictEvents causes the host to fail on startup if a runtime service produces an
+// event that no service consumes, and that is not public.  By default, such events are
+// logged at debug level.
+func WithStrictEvents(enable bool) Option {
+	return func(c *Config) (err error) {
+		c.strictEvents = enable
+		return
+	}
+}
+
+// WithStopTimeout bounds the time allotted to stopping each runtime service during
+// shutdown, after which the service is abandoned.  Defaults to
+// runtime.DefaultStopTimeout.
+func WithStopTimeout(d time.Duration) Option {
+	return func(c *Config) (err error) {
+		c.stopTimeout = d
+		return
+	}
+}
+
+// WithEventTap records the last size events emitted by the host's runtime services,
+// for debugging.  If size is zero, runtime.DefaultTapSize is used.  The tap is
+// disabled by default, in which case it has no cost.
+func WithEventTap(size int) Option {
+	if size <= 0 {
+		size = runtime.DefaultTapSize
+	}
+
+	return func(c *Config) (err error) {
+		c.tapSize = size
+		return
+	}
+}
+
+// WithToken requires clients to present a credential, and grants ops to those that
+// present token.  It may be given more than once.  The root anchor is attenuated to
+// the operations granted, and other callers are denied.  Other hosts are callers like
+// any other; grant them operations with WithAllowPeer, so that they can serve each
+// other's anchors.
+func WithToken(token string, ops anchorutil.Op) Option {
+	return func(c *Config) (err error) {
+		if token == "" {
+			return errors.New("empty token")
+		}
+
+		if c.tokens == nil {
+			c.tokens = make(map[string]anchorutil.Op)
+		}
+
+		c.tokens[token] |= ops
+		return
+	}
+}
+
+// WithAllowPeer requires clients to present a credential, as with WithToken, and
+// grants ops to the client with the given peer ID, without a token.
+func WithAllowPeer(id peer.ID, ops anchorutil.Op) Option {
+	return func(c *Config) (err error) {
+		if c.peers == nil {
+			c.peers = make(map[peer.ID]anchorutil.Op)
+		}
+
+		c.peers[id] |= ops
+		return
+	}
+}
+
+// WithSecurity restricts the transports that secure the host's connections to those
+// named, i.e. "noise" or "tls", in order of preference.  By default, both are
+// supported.
+func WithSecurity(names ...string) Option {
+	return func(c *Config) (err error) {
+		c.security = names
+		return
+	}
+}
+
 func withCardinality(k, highwater int) Option {
 	return func(c *Config) (err error) {
 		c.kmin = k
@@ -117,6 +309,10 @@ func withDefault(opt []Option) []Option {
 		),
 		WithBootStrategy(nil),
 		WithTTL(0),
+		WithRedaction(nil),
+		WithValueLogging(false),
+		WithPrintLimits(core.DefaultLimits),
+		WithDialRetry(5, 50*time.Millisecond),
 		withCardinality(8, 32),
 		withDataStore(nil),
 	}, opt...)
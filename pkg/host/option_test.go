@@ -23,7 +23,7 @@ func TestDefaultOpt(t *testing.T) {
 	t.Run("Discover", func(t *testing.T) {
 		assert.NotNil(t, cfg.boot,
 			"no discovery service supplied by default")
-		assert.Equal(t, &boot.MDNS{Namespace: cfg.ns}, cfg.boot,
+		assert.Equal(t, &boot.MDNS{Namespace: cfg.ns, Log: cfg.log}, cfg.boot,
 			"unexpected default discovery service")
 	})
 }
@@ -0,0 +1,184 @@
+package host
+
+import (
+	"fmt"
+	"sync"
+
+	capnp "zombiezen.com/go/capnproto2"
+
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/internal/tree"
+	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
+	memutil "github.com/wetware/ww/pkg/util/mem"
+)
+
+// Quota bounds the storage used by a subtree of the host's anchor.  Zero fields are
+// unlimited.
+type Quota struct {
+	// MaxBytes bounds the total size of the values stored in the subtree, measured
+	// in canonical encoding.
+	MaxBytes int
+
+	// MaxAnchors bounds the number of anchors in the subtree that hold a value.
+	MaxAnchors int
+}
+
+// QuotaUsage is the storage used by a subtree.
+type QuotaUsage struct {
+	Bytes, Anchors int
+}
+
+// quotas enforces the quotas configured for the host.  Stores at paths that are not
+// covered by a quota do not contend for its lock.
+type quotas struct {
+	mu       sync.Mutex
+	subtrees []*subtree // immutable after construction
+}
+
+type subtree struct {
+	path []string
+	Quota
+	usage QuotaUsage // guarded by quotas.mu
+}
+
+func newQuotas(qs map[string]Quota) *quotas {
+	var q quotas
+	for path, quota := range qs {
+		q.subtrees = append(q.subtrees, &subtree{
+			path:  anchorpath.Parts(path),
+			Quota: quota,
+		})
+	}
+
+	return &q
+}
+
+// Usage returns the storage used by the subtree at path, which must have a quota.
+func (q *quotas) Usage(path []string) (QuotaUsage, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, s := range q.subtrees {
+		if equalPath(s.path, path) {
+			return s.usage, true
+		}
+	}
+
+	return QuotaUsage{}, false
+}
+
+// Store the value in the node, after checking that it fits within the quota of every
+// subtree containing the node.  Returns false if the node is not empty.
+func (q *quotas) Store(n tree.Node, v mem.Any) (bool, error) {
+	ss := q.covering(n.Path())
+	if len(ss) == 0 {
+		return n.Store(v), nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	old := n.Load()
+	oldSize, err := size(old)
+	if err != nil {
+		return false, err
+	}
+
+	// Storing nil clears the anchor, releasing its usage.
+	if memutil.IsNil(v) {
+		if !n.Store(v) {
+			return false, nil
+		}
+
+		if !memutil.IsNil(old) {
+			for _, s := range ss {
+				s.usage.Bytes -= oldSize
+				s.usage.Anchors--
+			}
+		}
+
+		return true, nil
+	}
+
+	if !memutil.IsNil(old) {
+		return false, nil
+	}
+
+	newSize, err := size(v)
+	if err != nil {
+		return false, err
+	}
+
+	for _, s := range ss {
+		if err = s.check(newSize); err != nil {
+			return false, err
+		}
+	}
+
+	if !n.Store(v) {
+		return false, nil
+	}
+
+	for _, s := range ss {
+		s.usage.Bytes += newSize
+		s.usage.Anchors++
+	}
+
+	return true, nil
+}
+
+func (q *quotas) covering(path []string) (ss []*subtree) {
+	if q == nil {
+		return nil
+	}
+
+	for _, s := range q.subtrees {
+		if hasPrefix(path, s.path) {
+			ss = append(ss, s)
+		}
+	}
+
+	return
+}
+
+func (s *subtree) check(size int) error {
+	if s.MaxBytes > 0 && s.usage.Bytes+size > s.MaxBytes {
+		return fmt.Errorf("%w: %s: %d of %d bytes used, value is %d bytes",
+			ww.ErrQuotaExceeded, anchorpath.Join(s.path), s.usage.Bytes, s.MaxBytes, size)
+	}
+
+	if s.MaxAnchors > 0 && s.usage.Anchors+1 > s.MaxAnchors {
+		return fmt.Errorf("%w: %s: %d of %d anchors used",
+			ww.ErrQuotaExceeded, anchorpath.Join(s.path), s.usage.Anchors, s.MaxAnchors)
+	}
+
+	return nil
+}
+
+func size(v mem.Any) (int, error) {
+	if memutil.IsNil(v) {
+		return 0, nil
+	}
+
+	b, err := capnp.Canonicalize(v.Struct)
+	return len(b), err
+}
+
+func hasPrefix(path, prefix []string) bool {
+	return len(path) >= len(prefix) && equalPath(path[:len(prefix)], prefix)
+}
+
+func equalPath(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,80 @@
+package host
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	capnp "zombiezen.com/go/capnproto2"
+
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/internal/tree"
+	"github.com/wetware/ww/pkg/lang/core"
+)
+
+func TestQuota(t *testing.T) {
+	t.Parallel()
+
+	str := func(s string) mem.Any {
+		v, err := core.NewString(capnp.SingleSegment(nil), s)
+		require.NoError(t, err)
+		return v.Value()
+	}
+
+	strSize, err := size(str("x"))
+	require.NoError(t, err)
+
+	var (
+		root = tree.New()
+		q    = newQuotas(map[string]Quota{
+			"/tenants/acme":     {MaxAnchors: 2},
+			"/tenants/acme/big": {MaxBytes: strSize},
+		})
+		acme = []string{"tenants", "acme"}
+	)
+
+	store := func(v mem.Any, path ...string) error {
+		ok, err := q.Store(root.Walk(path), v)
+		if err == nil && !ok {
+			err = ww.ErrAnchorNotEmpty
+		}
+		return err
+	}
+
+	require.NoError(t, store(str("x"), "tenants", "acme", "a"))
+	require.NoError(t, store(str("x"), "tenants", "acme", "big"))
+
+	u, ok := q.Usage(acme)
+	require.True(t, ok)
+	assert.Equal(t, QuotaUsage{Bytes: 2 * strSize, Anchors: 2}, u)
+
+	// anchor count
+	err = store(str("x"), "tenants", "acme", "c")
+	assert.True(t, errors.Is(err, ww.ErrQuotaExceeded), "unexpected error %v", err)
+
+	// nested byte limit
+	err = store(mem.Any{}, "tenants", "acme", "a")
+	require.NoError(t, err)
+	err = store(str("x"), "tenants", "acme", "big", "child")
+	assert.True(t, errors.Is(err, ww.ErrQuotaExceeded), "unexpected error %v", err)
+
+	// clearing released the usage
+	u, _ = q.Usage(acme)
+	assert.Equal(t, QuotaUsage{Bytes: strSize, Anchors: 1}, u)
+	require.NoError(t, store(str("x"), "tenants", "acme", "c"))
+
+	// rejected stores don't consume quota
+	assert.Equal(t, ww.ErrAnchorNotEmpty, store(str("y"), "tenants", "acme", "c"))
+	u, _ = q.Usage(acme)
+	assert.Equal(t, QuotaUsage{Bytes: 2 * strSize, Anchors: 2}, u)
+
+	// paths outside quotas are unlimited
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, store(str("x"), "tenants", "other", name))
+	}
+
+	_, ok = q.Usage([]string{"tenants", "other"})
+	assert.False(t, ok)
+}
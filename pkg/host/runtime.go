@@ -15,6 +15,7 @@ import (
 	// libp2p core interfaces
 
 	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/peerstore"
 	"github.com/libp2p/go-libp2p-core/pnet"
 
@@ -33,20 +34,31 @@ import (
 
 	// wetware internal deps
 	"github.com/wetware/ww/pkg/internal/p2p"
+	"github.com/wetware/ww/pkg/internal/rpc"
 
 	// wetware public APIs
 	"github.com/wetware/ww/pkg/boot"
 	"github.com/wetware/ww/pkg/cluster"
+	"github.com/wetware/ww/pkg/lang/core"
 	"github.com/wetware/ww/pkg/runtime"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
+	"github.com/wetware/ww/pkg/util/redact"
 
 	// runtime services
 	announcer_service "github.com/wetware/ww/pkg/runtime/svc/announcer"
 	beacon_service "github.com/wetware/ww/pkg/runtime/svc/beacon"
-	boot_service "github.com/wetware/ww/pkg/runtime/svc/boot"
+	bootstrap_service "github.com/wetware/ww/pkg/runtime/svc/bootstrap"
+	crawler_service "github.com/wetware/ww/pkg/runtime/svc/crawler"
 	epoch_service "github.com/wetware/ww/pkg/runtime/svc/epoch"
 	graph_service "github.com/wetware/ww/pkg/runtime/svc/graph"
-	join_service "github.com/wetware/ww/pkg/runtime/svc/join"
+	health_service "github.com/wetware/ww/pkg/runtime/svc/health"
+	heartbeat_service "github.com/wetware/ww/pkg/runtime/svc/heartbeat"
+	metrics_service "github.com/wetware/ww/pkg/runtime/svc/metrics"
 	neighborhood_service "github.com/wetware/ww/pkg/runtime/svc/neighborhood"
+	prune_service "github.com/wetware/ww/pkg/runtime/svc/prune"
+	quality_service "github.com/wetware/ww/pkg/runtime/svc/quality"
+	repair_service "github.com/wetware/ww/pkg/runtime/svc/repair"
+	streams_service "github.com/wetware/ww/pkg/runtime/svc/streams"
 	tick_service "github.com/wetware/ww/pkg/runtime/svc/ticker"
 	tracker_service "github.com/wetware/ww/pkg/runtime/svc/tracker"
 )
@@ -58,13 +70,20 @@ func services() fx.Option {
 		tick_service.New,
 		epoch_service.New,
 		tracker_service.New,
+		streams_service.New,
 		neighborhood_service.New,
-		boot_service.New,
+		bootstrap_service.New,
 		beacon_service.New,
+		crawler_service.New,
 		// discover_service.New,
 		graph_service.New,
+		quality_service.New,
+		prune_service.New,
+		repair_service.New,
 		announcer_service.New,
-		join_service.New,
+		heartbeat_service.New,
+		metrics_service.New,
+		health_service.New,
 	)
 }
 
@@ -76,10 +95,26 @@ type Config struct {
 	ttl        time.Duration
 	kmin, kmax int
 
-	psk   pnet.PSK
-	addrs []multiaddr.Multiaddr
-	ds    datastore.Batching
-	boot  boot.Strategy
+	psk        pnet.PSK
+	addrs      []multiaddr.Multiaddr
+	ds         datastore.Batching
+	boot       boot.Strategy
+	redact     *redact.Redactor
+	limits     core.Limits
+	logValues  bool
+	quotas     map[string]Quota
+	retry      rpc.RetryPolicy
+	instrument rpc.Instrument
+	tokens     map[string]anchorutil.Op
+	peers      map[peer.ID]anchorutil.Op
+	security   []string
+
+	skipHandshake bool
+	strictEvents  bool
+	stopTimeout   time.Duration
+	tapSize       int // zero if the event tap is disabled
+	metricsAddr   string
+	healthAddr    string
 }
 
 func (cfg Config) export() fx.Option {
@@ -89,6 +124,8 @@ func (cfg Config) export() fx.Option {
 			cfg.options,
 			p2p.New,
 			cluster.New,
+			rpc.NewStreamStats,
+			runtime.NewRegistry,
 			// block.New,
 			newAnchor,
 			newHost,
@@ -110,6 +147,36 @@ func (cfg Config) options(lx fx.Lifecycle) (mod module, err error) {
 	mod.ListenAddrs = cfg.addrs
 	mod.KMin = cfg.kmin
 	mod.KMax = cfg.kmax
+	mod.Redactor = cfg.redact
+	mod.Redactor.SetLimits(cfg.limits)
+	mod.LogValues = cfg.logValues
+	mod.Quotas = newQuotas(cfg.quotas)
+	mod.Retry = cfg.retry
+	mod.Instrument = cfg.instrument
+	mod.SkipHandshake = cfg.skipHandshake
+	mod.StrictEvents = cfg.strictEvents
+	mod.StopTimeout = cfg.stopTimeout
+
+	if cfg.tapSize > 0 {
+		mod.Tap = runtime.NewTap(cfg.tapSize)
+	}
+
+	mod.HealthAddr = cfg.healthAddr
+
+	if len(cfg.tokens) > 0 || len(cfg.peers) > 0 {
+		mod.Guard = &rpc.Guard{Namespace: cfg.ns, Tokens: cfg.tokens, Peers: cfg.peers}
+	}
+
+	var security libp2p.Option
+	if security, err = hostutil.Security(cfg.security...); err != nil {
+		return
+	}
+
+	if mod.MetricsAddr = cfg.metricsAddr; mod.MetricsAddr != "" {
+		m := metrics_service.NewRPC()
+		mod.Instrument = rpc.Tee(cfg.instrument, m)
+		mod.Metrics = []metrics_service.Source{m}
+	}
 
 	var ps peerstore.Peerstore
 	if ps, err = pstoreds.NewPeerstore(mod.Ctx, cfg.ds, pstoreds.DefaultOpts()); err != nil {
@@ -121,6 +188,7 @@ func (cfg Config) options(lx fx.Lifecycle) (mod module, err error) {
 	mod.HostOpt = []config.Option{
 		libp2p.DisableRelay(),
 		hostutil.MaybePrivate(cfg.psk),
+		security,
 		libp2p.NoListenAddrs, // defer listening until setup is complete
 		libp2p.UserAgent("ww-host"),
 		libp2p.Peerstore(ps),
@@ -148,6 +216,22 @@ type module struct {
 
 	ListenAddrs []multiaddr.Multiaddr
 	Boot        boot.Strategy
+	Redactor    *redact.Redactor
+	LogValues   bool `name:"log_values"`
+	Quotas      *quotas
+	Retry       rpc.RetryPolicy
+	Instrument  rpc.Instrument
+	Guard       *rpc.Guard // nil if clients are not authenticated
+
+	SkipHandshake bool          `name:"skip_handshake"`
+	StrictEvents  bool          `name:"strict_events"`
+	StopTimeout   time.Duration `name:"stop_timeout"`
+	Tap           *runtime.Tap  // nil if disabled
+
+	MetricsAddr string                   `name:"metrics_addr"`
+	Metrics     []metrics_service.Source `group:"metrics,flatten"`
+
+	HealthAddr string `name:"health_addr"`
 
 	HostOpt []config.Option
 	DHTOpt  []dual.Option
@@ -46,7 +46,10 @@ func (h adaptHostAnchor) Adapt(a mem.Anchor_SubAnchor) (ww.Anchor, error) {
 	return NewHost(rpc.Terminal(h), id), nil
 }
 
-type adaptSubanchor []string
+type adaptSubanchor struct {
+	path path
+	inst rpc.Instrument
+}
 
 func (h adaptSubanchor) Adapt(a mem.Anchor_SubAnchor) (ww.Anchor, error) {
 	subpath, err := a.Path()
@@ -54,8 +57,13 @@ func (h adaptSubanchor) Adapt(a mem.Anchor_SubAnchor) (ww.Anchor, error) {
 		return nil, err
 	}
 
+	// The capability belongs to the ls results, which are released when ls returns.
+	c := a.Anchor().Client.AddRef()
+
 	return anchor{
-		path:           append(path(h), subpath),
-		anchorProvider: a,
+		path:    append(append(path{}, h.path...), subpath),
+		client:  mem.Anchor{Client: c},
+		release: c.Release,
+		inst:    h.inst,
 	}, nil
 }
@@ -3,44 +3,62 @@ package anchor
 import (
 	"context"
 	"errors"
-	"runtime"
 
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/wetware/ww/internal/mem"
 	ww "github.com/wetware/ww/pkg"
 	"github.com/wetware/ww/pkg/internal/rpc"
 	"github.com/wetware/ww/pkg/lang/core"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
 	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
 	memutil "github.com/wetware/ww/pkg/util/mem"
 	capnp "zombiezen.com/go/capnproto2"
 )
 
+// anchor is a remote anchor.  It holds a reference to the remote capability, which is
+// dropped by Release.
 type anchor struct {
 	path
-	anchorProvider
+	client  mem.Anchor
+	release capnp.ReleaseFunc
+	inst    rpc.Instrument // may be nil
 }
 
+// Release the remote capability.
+func (a anchor) Release() { a.release() }
+
 func (a anchor) Ls(ctx context.Context) ([]ww.Anchor, error) {
-	return ls(ctx, a.Anchor(), adaptSubanchor(a.path))
+	return anchorutil.Collect(a.Iter(ctx))
+}
+
+// Iter lists the children of the anchor.  The ls call is sent when the iterator is
+// first advanced.
+func (a anchor) Iter(ctx context.Context) ww.AnchorIterator {
+	return &iterator{
+		ctx:  ctx,
+		inst: a.inst,
+		ad:   adaptSubanchor{path: a.path, inst: a.inst},
+		open: func() (mem.Anchor, func()) { return a.client, func() {} },
+		listed: func() {
+			rpc.ReportDetail(a.inst, ww.AnchorProtocol, "ls", func() rpc.CallDetail {
+				return rpc.CallDetail{Path: a.path}
+			})
+		},
+	}
 }
 
 func (a anchor) Walk(ctx context.Context, path []string) ww.Anchor {
-	f, done := a.Anchor().Walk(ctx, func(p mem.Anchor_walk_Params) error {
-		return p.SetPath(anchorpath.Join(path))
-	})
-
-	runtime.SetFinalizer(&f, func(*mem.Anchor_walk_Results_Future) {
-		done()
-	})
-
-	return anchor{
-		path:           append(a.path, path...),
-		anchorProvider: f,
-	}
+	return walk(ctx, a.client, a.inst, append(append([]string{}, a.path...), path...), path)
 }
 
-func (a anchor) Load(ctx context.Context) (ww.Any, error) {
-	f, done := a.Anchor().Load(ctx, nil)
+func (a anchor) Load(ctx context.Context) (_ ww.Any, err error) {
+	observe := rpc.StartCall(a.inst, ww.AnchorProtocol, "load")
+	defer func() {
+		err = remoteErr(err)
+		observe(err)
+	}()
+
+	f, done := a.client.Load(ctx, nil)
 	defer done()
 
 	select {
@@ -59,11 +77,26 @@ func (a anchor) Load(ctx context.Context) (ww.Any, error) {
 		return nil, err
 	}
 
+	// the results are reclaimed when done is called
+	if v, err = memutil.Copy(capnp.SingleSegment(nil), v); err != nil {
+		return nil, err
+	}
+
+	rpc.ReportDetail(a.inst, ww.AnchorProtocol, "load", func() rpc.CallDetail {
+		return rpc.CallDetail{Path: a.path, Size: msgSize(v)}
+	})
+
 	return core.AsAny(v)
 }
 
-func (a anchor) Store(ctx context.Context, any ww.Any) error {
-	f, done := a.Anchor().Store(ctx, func(p mem.Anchor_store_Params) error {
+func (a anchor) Store(ctx context.Context, any ww.Any) (err error) {
+	observe := rpc.StartCall(a.inst, ww.AnchorProtocol, "store")
+	defer func() {
+		err = remoteErr(err)
+		observe(err)
+	}()
+
+	f, done := a.client.Store(ctx, func(p mem.Anchor_store_Params) error {
 		return p.SetValue(any.Value())
 	})
 	defer done()
@@ -74,19 +107,27 @@ func (a anchor) Store(ctx context.Context, any ww.Any) error {
 		return ctx.Err()
 	}
 
-	if _, err := f.Struct(); err != nil {
-		return err
+	if _, err = f.Struct(); err == nil {
+		rpc.ReportDetail(a.inst, ww.AnchorProtocol, "store", func() rpc.CallDetail {
+			return rpc.CallDetail{Path: a.path, Size: msgSize(any.Value())}
+		})
 	}
 
-	return nil
+	return
 }
 
-func (a anchor) Go(ctx context.Context, args ...ww.Any) (ww.Any, error) {
+func (a anchor) Go(ctx context.Context, args ...ww.Any) (_ ww.Any, err error) {
 	if len(args) == 0 {
 		return nil, errors.New("expected at least one argument, got 0")
 	}
 
-	f, done := a.Anchor().Go(ctx, procArgs(args).Set)
+	observe := rpc.StartCall(a.inst, ww.AnchorProtocol, "go")
+	defer func() {
+		err = remoteErr(err)
+		observe(err)
+	}()
+
+	f, done := a.client.Go(ctx, procArgs(args).Set)
 	defer done()
 
 	select {
@@ -128,7 +169,21 @@ func (h hostAnchor) Name() string   { return h.id.String() }
 func (h hostAnchor) Path() []string { return []string{h.Name()} }
 
 func (h hostAnchor) Ls(ctx context.Context) ([]ww.Anchor, error) {
-	return h.Walk(ctx, h.Path()).Ls(ctx)
+	return anchorutil.Collect(h.Iter(ctx))
+}
+
+// Iter lists the children of the host.  The host is dialed when the iterator is first
+// advanced.
+func (h hostAnchor) Iter(ctx context.Context) ww.AnchorIterator {
+	return &iterator{
+		ctx:  ctx,
+		inst: h.t.Instrument,
+		ad:   adaptSubanchor{path: h.Path(), inst: h.t.Instrument},
+		open: func() (mem.Anchor, func()) {
+			a := h.Walk(ctx, h.Path()).(anchor)
+			return a.client, a.Release
+		},
+	}
 }
 
 func (h hostAnchor) Walk(ctx context.Context, path []string) ww.Anchor {
@@ -149,10 +204,13 @@ func (hostAnchor) Go(context.Context, ...ww.Any) (ww.Any, error) {
 	return nil, errors.New("hostAnchor.Go NOT IMPLEMENTED")
 }
 
+// Release is a nop.  Host anchors dial the host for each call.
+func (hostAnchor) Release() {}
+
 type path []string
 
 func (p path) Name() string {
-	if anchorpath.Root(nil) {
+	if anchorpath.Root(p) {
 		return ""
 	}
 
@@ -161,8 +219,16 @@ func (p path) Name() string {
 
 func (p path) Path() []string { return p }
 
-type anchorProvider interface {
-	Anchor() mem.Anchor
+// msgSize returns the size of the message holding v, in bytes.
+func msgSize(v mem.Any) (n int64) {
+	msg := v.Segment().Message()
+	for i := int64(0); i < msg.NumSegments(); i++ {
+		if seg, err := msg.Segment(capnp.SegmentID(i)); err == nil {
+			n += int64(len(seg.Data()))
+		}
+	}
+
+	return
 }
 
 type procArgs []ww.Any
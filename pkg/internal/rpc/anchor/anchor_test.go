@@ -0,0 +1,304 @@
+package anchor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/internal/rpc"
+	"github.com/wetware/ww/pkg/lang/core"
+	capnprpc "zombiezen.com/go/capnproto2/rpc"
+	"zombiezen.com/go/capnproto2/server"
+)
+
+func TestRelease(t *testing.T) {
+	t.Parallel()
+
+	const n = 10000
+
+	t.Run("Walk", func(t *testing.T) {
+		var live int64
+		root := mem.Anchor_ServerToClient(&countingServer{live: &live}, &server.Policy{})
+		defer root.Client.Release()
+
+		ctx := context.Background()
+		for i := 0; i < n; i++ {
+			a := walk(ctx, root, nil, path{"foo"}, []string{"foo"})
+
+			// the walked anchor is usable until released
+			v, err := a.Load(ctx)
+			require.NoError(t, err)
+			requireTrue(t, v)
+
+			a.Release()
+		}
+
+		assert.Eventually(t, func() bool { return atomic.LoadInt64(&live) == 0 },
+			time.Second, time.Millisecond*10,
+			"%d anchors still referenced", atomic.LoadInt64(&live))
+	})
+
+	t.Run("Ls", func(t *testing.T) {
+		var live int64
+		root := mem.Anchor_ServerToClient(&countingServer{live: &live}, &server.Policy{})
+		defer root.Client.Release()
+
+		ctx := context.Background()
+		for i := 0; i < n/childCount; i++ {
+			as, err := anchor{client: root, release: func() {}}.Ls(ctx)
+			require.NoError(t, err)
+			require.Len(t, as, childCount)
+
+			// children outlive the ls results
+			v, err := as[0].Load(ctx)
+			require.NoError(t, err)
+			requireTrue(t, v)
+
+			for _, a := range as {
+				a.Release()
+			}
+		}
+
+		assert.Eventually(t, func() bool { return atomic.LoadInt64(&live) == 0 },
+			time.Second, time.Millisecond*10,
+			"%d anchors still referenced", atomic.LoadInt64(&live))
+	})
+}
+
+func TestPathName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "", path{}.Name())
+	assert.Equal(t, "bar", path{"foo", "bar"}.Name())
+}
+
+const childCount = 10
+
+// countingServer tracks the number of live anchor capabilities it has handed out.
+type countingServer struct {
+	live  *int64
+	calls int64 // ls calls; atomic
+}
+
+func (s *countingServer) child() mem.Anchor {
+	atomic.AddInt64(s.live, 1)
+	return mem.Anchor_ServerToClient(&countingChild{s}, &server.Policy{})
+}
+
+func (s *countingServer) Ls(_ context.Context, call mem.Anchor_ls) error {
+	atomic.AddInt64(&s.calls, 1)
+
+	res, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	cs, err := res.NewChildren(childCount)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < cs.Len(); i++ {
+		if err = cs.At(i).SetPath("child"); err != nil {
+			return err
+		}
+
+		if err = cs.At(i).SetAnchor(s.child()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *countingServer) Walk(_ context.Context, call mem.Anchor_walk) error {
+	res, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	return res.SetAnchor(s.child())
+}
+
+func (s *countingServer) Load(_ context.Context, call mem.Anchor_load) error {
+	res, err := call.AllocResults()
+	if err != nil {
+		return err
+	}
+
+	return res.SetValue(core.True.Value())
+}
+
+func (s *countingServer) Store(context.Context, mem.Anchor_store) error { return nil }
+func (s *countingServer) Go(context.Context, mem.Anchor_go) error       { return nil }
+
+type countingChild struct{ *countingServer }
+
+func (c *countingChild) Shutdown() { atomic.AddInt64(c.live, -1) }
+
+func requireTrue(t *testing.T, v ww.Any) {
+	s, err := core.Render(v)
+	require.NoError(t, err)
+	require.Equal(t, "true", s)
+}
+
+func TestIter(t *testing.T) {
+	t.Parallel()
+
+	var live int64
+	srv := &countingServer{live: &live}
+
+	c := mem.Anchor_ServerToClient(srv, &server.Policy{}).Client
+	a := anchor{path: path{"foo"}, client: mem.Anchor{Client: c}, release: c.Release}
+	defer a.Release()
+
+	ctx := context.Background()
+
+	t.Run("Lazy", func(t *testing.T) {
+		it := a.Iter(ctx)
+		it.Release()
+
+		assert.False(t, it.Next(), "released iterator should be exhausted")
+		assert.NoError(t, it.Err())
+		assert.Zero(t, atomic.LoadInt64(&srv.calls), "ls should not be called")
+	})
+
+	t.Run("Abandon", func(t *testing.T) {
+		it := a.Iter(ctx)
+		require.True(t, it.Next())
+
+		child := it.Anchor()
+		assert.Equal(t, []string{"foo", "child"}, child.Path())
+
+		// abandon the listing; the children that were not returned are released
+		it.Release()
+		assert.False(t, it.Next())
+
+		assert.Eventually(t, func() bool { return atomic.LoadInt64(&live) == 1 },
+			time.Second, time.Millisecond*10,
+			"%d anchors still referenced", atomic.LoadInt64(&live))
+
+		// the returned child outlives the iterator
+		v, err := child.Load(ctx)
+		require.NoError(t, err)
+		requireTrue(t, v)
+
+		child.Release()
+		assert.Eventually(t, func() bool { return atomic.LoadInt64(&live) == 0 },
+			time.Second, time.Millisecond*10,
+			"%d anchors still referenced", atomic.LoadInt64(&live))
+	})
+
+	t.Run("Exhaust", func(t *testing.T) {
+		var n int
+		it := a.Iter(ctx)
+		for it.Next() {
+			it.Anchor().Release()
+			n++
+		}
+
+		assert.NoError(t, it.Err())
+		assert.Equal(t, childCount, n)
+		assert.Equal(t, int64(2), atomic.LoadInt64(&srv.calls))
+	})
+}
+
+func TestDeadline(t *testing.T) {
+	t.Parallel()
+
+	srv := &slowServer{cancelled: make(chan struct{})}
+
+	p1, p2 := net.Pipe()
+	host := capnprpc.NewConn(capnprpc.NewStreamTransport(p1), &capnprpc.Options{
+		BootstrapClient: mem.Anchor_ServerToClient(srv, &server.Policy{}).Client,
+	})
+	defer host.Close()
+
+	conn := capnprpc.NewConn(capnprpc.NewStreamTransport(p2), nil)
+	defer conn.Close()
+
+	c := conn.Bootstrap(context.Background())
+	a := anchor{client: mem.Anchor{Client: c}, release: c.Release}
+	defer a.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	start := time.Now()
+	_, err := a.Load(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error %v", err)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second), "client should return promptly")
+
+	select {
+	case <-srv.cancelled:
+	case <-time.After(time.Second):
+		t.Error("host did not abandon the call")
+	}
+}
+
+// slowServer blocks in Load until the call is canceled.
+type slowServer struct {
+	countingServer
+	cancelled chan struct{}
+}
+
+func (s *slowServer) Load(ctx context.Context, call mem.Anchor_load) error {
+	call.Ack()
+
+	select {
+	case <-ctx.Done():
+		close(s.cancelled)
+		return ctx.Err()
+	case <-time.After(time.Second * 10):
+		return errors.New("call was not canceled")
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	t.Parallel()
+
+	var live int64
+	root := mem.Anchor_ServerToClient(&countingServer{live: &live}, &server.Policy{})
+	defer root.Client.Release()
+
+	var (
+		ctx = context.Background()
+		m   = rpc.NewMetrics()
+	)
+
+	a := walk(ctx, root, m, path{"foo"}, []string{"foo"})
+	defer a.Release()
+
+	_, err := a.Load(ctx)
+	require.NoError(t, err)
+
+	as, err := a.Ls(ctx)
+	require.NoError(t, err)
+	for _, child := range as {
+		require.NoError(t, child.Store(ctx, core.True))
+		child.Release()
+	}
+
+	calls := make(map[string]uint64)
+	for _, s := range m.Methods() {
+		assert.Equal(t, ww.AnchorProtocol, s.Protocol)
+		assert.Zero(t, s.InFlight, s.Method)
+		assert.Zero(t, s.Errors, s.Method)
+		calls[s.Method] = s.Calls
+	}
+
+	assert.Equal(t, map[string]uint64{
+		"walk":  1,
+		"load":  1,
+		"ls":    1,
+		"store": childCount,
+	}, calls)
+}
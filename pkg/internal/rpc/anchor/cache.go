@@ -0,0 +1,177 @@
+package anchor
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ww "github.com/wetware/ww/pkg"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
+	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
+)
+
+// DefaultCacheTTL is the default lifetime of a cached value.
+const DefaultCacheTTL = time.Second
+
+var _ ww.Anchor = (*CachedAnchor)(nil)
+
+// CacheOption configures a CachedAnchor.
+type CacheOption func(*cache)
+
+// WithTTL sets the duration for which a loaded value is served from the cache.
+func WithTTL(d time.Duration) CacheOption {
+	return func(c *cache) { c.ttl = d }
+}
+
+// WithClock sets the time source used to expire entries.  It is intended for tests.
+func WithClock(now func() time.Time) CacheOption {
+	return func(c *cache) { c.now = now }
+}
+
+// CacheStats counts cache lookups.
+type CacheStats struct {
+	Hits, Misses uint64
+}
+
+// CachedAnchor serves repeated Loads from a local cache.  A loaded value is reused
+// until its TTL expires, or until it is invalidated by a Store or Go through any
+// anchor derived from the same CachedAnchor.  Writes made by other clients are not
+// observed until the entry expires.
+//
+// Anchors obtained by walking or listing a CachedAnchor share its cache, which is
+// keyed by the cleaned anchor path.
+type CachedAnchor struct {
+	ww.Anchor
+	c *cache
+}
+
+type cache struct {
+	hits, misses uint64 // atomic; first for 64-bit alignment
+
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	gen     uint64 // incremented by each invalidation
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	val     ww.Any
+	expires time.Time
+}
+
+// Cached wraps the anchor in a read-through cache.
+func Cached(a ww.Anchor, opt ...CacheOption) CachedAnchor {
+	c := &cache{
+		ttl:     DefaultCacheTTL,
+		now:     time.Now,
+		entries: make(map[string]cacheEntry),
+	}
+
+	for _, option := range opt {
+		option(c)
+	}
+
+	return CachedAnchor{Anchor: a, c: c}
+}
+
+// Stats returns the number of cache hits and misses, across all anchors sharing
+// the cache.
+func (a CachedAnchor) Stats() CacheStats {
+	return CacheStats{
+		Hits:   atomic.LoadUint64(&a.c.hits),
+		Misses: atomic.LoadUint64(&a.c.misses),
+	}
+}
+
+// Ls returns the children of the anchor, which share its cache.
+func (a CachedAnchor) Ls(ctx context.Context) ([]ww.Anchor, error) {
+	return anchorutil.Collect(a.Iter(ctx))
+}
+
+// Iter lists the children of the anchor, which share its cache.
+func (a CachedAnchor) Iter(ctx context.Context) ww.AnchorIterator {
+	return anchorutil.MapIter(a.Anchor.Iter(ctx), func(child ww.Anchor) ww.Anchor {
+		return CachedAnchor{Anchor: child, c: a.c}
+	})
+}
+
+// Walk returns the anchor at path, which shares the cache.
+func (a CachedAnchor) Walk(ctx context.Context, path []string) ww.Anchor {
+	return CachedAnchor{Anchor: a.Anchor.Walk(ctx, path), c: a.c}
+}
+
+// Load returns the cached value if it has not expired.  Otherwise, it loads the
+// value from the underlying anchor and caches it.  Errors are not cached.
+func (a CachedAnchor) Load(ctx context.Context) (ww.Any, error) {
+	key := a.key()
+	v, gen, ok := a.c.get(key)
+	if ok {
+		atomic.AddUint64(&a.c.hits, 1)
+		return v, nil
+	}
+
+	atomic.AddUint64(&a.c.misses, 1)
+
+	v, err := a.Anchor.Load(ctx)
+	if err == nil {
+		a.c.put(key, v, gen)
+	}
+
+	return v, err
+}
+
+// Store the value in the underlying anchor, invalidating the cached value.
+func (a CachedAnchor) Store(ctx context.Context, any ww.Any) error {
+	defer a.c.invalidate(a.key())
+	return a.Anchor.Store(ctx, any)
+}
+
+// Go spawns a process at the underlying anchor, invalidating the cached value.
+func (a CachedAnchor) Go(ctx context.Context, args ...ww.Any) (ww.Any, error) {
+	defer a.c.invalidate(a.key())
+	return a.Anchor.Go(ctx, args...)
+}
+
+func (a CachedAnchor) key() string {
+	return anchorpath.Join(a.Path())
+}
+
+// get returns the cached value, if any, and the current generation.
+func (c *cache) get(key string) (ww.Any, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, c.gen, false
+	}
+
+	if !c.now().Before(e.expires) {
+		delete(c.entries, key)
+		return nil, c.gen, false
+	}
+
+	return e.val, c.gen, true
+}
+
+// put caches the value, unless an invalidation has occurred since generation gen.
+// This prevents a Load that races with a Store from caching the stale value.
+func (c *cache) put(key string, val ww.Any, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen == c.gen {
+		c.entries[key] = cacheEntry{val: val, expires: c.now().Add(c.ttl)}
+	}
+}
+
+func (c *cache) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	delete(c.entries, key)
+}
@@ -0,0 +1,118 @@
+package anchor_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/internal/rpc/anchor"
+	"github.com/wetware/ww/pkg/lang/core"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
+)
+
+func TestCached(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx   = context.Background()
+		now   = time.Unix(0, 0)
+		clock = func() time.Time { return now }
+		a     = &countingAnchor{val: core.True}
+		c     = anchor.Cached(a, anchor.WithTTL(time.Second), anchor.WithClock(clock))
+	)
+
+	for i := 0; i < 3; i++ {
+		v, err := c.Load(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, core.True, v)
+	}
+
+	assert.Equal(t, 1, a.loads, "should load once")
+	assert.Equal(t, anchor.CacheStats{Hits: 2, Misses: 1}, c.Stats())
+
+	// walking to the same path shares the entry
+	_, err := c.Walk(ctx, []string{"foo"}).Load(ctx)
+	require.NoError(t, err)
+	_, err = c.Walk(ctx, []string{"foo"}).Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, a.loads, "should load foo once")
+
+	// expiry
+	now = now.Add(time.Second)
+	_, err = c.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, a.loads, "should reload after TTL")
+
+	// invalidation
+	require.NoError(t, c.Store(ctx, core.False))
+	v, err := c.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, core.False, v)
+	assert.Equal(t, 4, a.loads, "should reload after store")
+}
+
+func BenchmarkLoad(b *testing.B) {
+	ctx := context.Background()
+
+	// each uncached load simulates a network round trip
+	a := &countingAnchor{val: core.True, latency: 100 * time.Microsecond}
+
+	b.Run("Uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = a.Load(ctx)
+		}
+	})
+
+	b.Run("Cached", func(b *testing.B) {
+		c := anchor.Cached(a, anchor.WithTTL(time.Minute))
+		for i := 0; i < b.N; i++ {
+			_, _ = c.Load(ctx)
+		}
+	})
+}
+
+// countingAnchor is an in-memory anchor that counts loads.  Walking it returns an
+// anchor sharing the same value and counter.
+type countingAnchor struct {
+	path    []string
+	val     ww.Any
+	loads   int
+	latency time.Duration
+}
+
+func (a *countingAnchor) Name() string   { return "" }
+func (a *countingAnchor) Path() []string { return a.path }
+
+func (a *countingAnchor) Ls(context.Context) ([]ww.Anchor, error) { return nil, nil }
+func (a *countingAnchor) Iter(context.Context) ww.AnchorIterator {
+	return anchorutil.SliceIter(nil)
+}
+
+func (a *countingAnchor) Walk(_ context.Context, path []string) ww.Anchor {
+	return &walked{countingAnchor: a, path: append(append([]string{}, a.path...), path...)}
+}
+
+func (a *countingAnchor) Load(context.Context) (ww.Any, error) {
+	time.Sleep(a.latency)
+	a.loads++
+	return a.val, nil
+}
+
+func (a *countingAnchor) Store(_ context.Context, any ww.Any) error {
+	a.val = any
+	return nil
+}
+
+func (a *countingAnchor) Go(context.Context, ...ww.Any) (ww.Any, error) { return nil, nil }
+func (a *countingAnchor) Release()                                      {}
+
+type walked struct {
+	*countingAnchor
+	path []string
+}
+
+func (w *walked) Path() []string { return w.path }
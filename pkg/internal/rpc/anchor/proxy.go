@@ -0,0 +1,286 @@
+package anchor
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	capnp "zombiezen.com/go/capnproto2"
+	capnprpc "zombiezen.com/go/capnproto2/rpc"
+
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/internal/rpc"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
+)
+
+// ErrNotMember is wrapped by the ww.HostUnreachableError returned when walking a path
+// beneath a peer that is not a member of the cluster.
+var ErrNotMember = errors.New("not a member of the cluster")
+
+// Proxies connects to the root anchors of remote hosts, so that a host can serve the
+// anchor trees of its cluster.  A host is dialed when a path beneath it is first
+// walked, and the connection is reused until the host is invalidated, or until the
+// connection fails.
+type Proxies struct {
+	t        rpc.Terminal
+	isMember func(peer.ID) bool
+
+	mu    sync.Mutex
+	conns map[peer.ID]*proxy
+}
+
+// proxy is a connection to a remote host.  Its fields are set once ready is closed.
+type proxy struct {
+	ready chan struct{}
+	conn  *capnprpc.Conn
+	root  mem.Anchor
+	err   error
+}
+
+// NewProxies returns an empty set of proxies, which dials hosts through t.  Only the
+// peers for which isMember returns true are dialed, since the paths walked are chosen
+// by remote callers.
+func NewProxies(t rpc.Terminal, isMember func(peer.ID) bool) *Proxies {
+	return &Proxies{t: t, isMember: isMember, conns: make(map[peer.ID]*proxy)}
+}
+
+// Walk returns the anchor at path, whose first element is the ID of a remote host.  If
+// the host cannot be reached, or is not a member of the cluster, the anchor's methods
+// return a ww.HostUnreachableError.
+//
+// The context bounds the dial.  The anchor is typically exported to a remote caller,
+// so it outlives the context.
+func (ps *Proxies) Walk(ctx context.Context, path []string) ww.Anchor {
+	id, err := peer.Decode(path[0])
+	if err != nil {
+		return errAnchor{path: path, err: err}
+	}
+
+	if !ps.isMember(id) {
+		return errAnchor{path: path, err: ww.HostUnreachableError{Peer: id, Err: ErrNotMember}}
+	}
+
+	p, err := ps.get(ctx, id)
+	if err != nil {
+		return errAnchor{path: path, err: err}
+	}
+
+	a := walk(context.Background(), p.root, ps.t.Instrument, path, path)
+	return proxyAnchor{Anchor: a, ps: ps, id: id, p: p}
+}
+
+// Invalidate closes the connection to the host, if any.  Anchors obtained through it
+// return a ww.HostUnreachableError, and the host is dialed again when next walked.
+func (ps *Proxies) Invalidate(id peer.ID) {
+	ps.mu.Lock()
+	p, ok := ps.conns[id]
+	delete(ps.conns, id)
+	ps.mu.Unlock()
+
+	if ok {
+		go p.close()
+	}
+}
+
+// Retain invalidates the hosts that are not in ids.
+func (ps *Proxies) Retain(ids peer.IDSlice) {
+	keep := make(map[peer.ID]struct{}, len(ids))
+	for _, id := range ids {
+		keep[id] = struct{}{}
+	}
+
+	ps.mu.Lock()
+	var stale peer.IDSlice
+	for id := range ps.conns {
+		if _, ok := keep[id]; !ok {
+			stale = append(stale, id)
+		}
+	}
+	ps.mu.Unlock()
+
+	for _, id := range stale {
+		ps.Invalidate(id)
+	}
+}
+
+// Close all connections.
+func (ps *Proxies) Close() error {
+	ps.mu.Lock()
+	conns := ps.conns
+	ps.conns = make(map[peer.ID]*proxy)
+	ps.mu.Unlock()
+
+	for _, p := range conns {
+		p.close()
+	}
+
+	return nil
+}
+
+// Len returns the number of cached connections, including those being dialed.
+func (ps *Proxies) Len() int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	return len(ps.conns)
+}
+
+// get returns the connection to the host, dialing it if necessary.  Concurrent callers
+// share a single dial.  Failed dials are not cached.
+func (ps *Proxies) get(ctx context.Context, id peer.ID) (*proxy, error) {
+	ps.mu.Lock()
+	p, ok := ps.conns[id]
+	if !ok {
+		p = &proxy{ready: make(chan struct{})}
+		ps.conns[id] = p
+	}
+	ps.mu.Unlock()
+
+	if !ok {
+		ps.dial(ctx, id, p)
+	}
+
+	select {
+	case <-p.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	return p, nil
+}
+
+func (ps *Proxies) dial(ctx context.Context, id peer.ID, p *proxy) {
+	defer close(p.ready)
+
+	if p.conn, p.err = ps.t.Connect(ctx, id, ww.AnchorProtocol); p.err != nil {
+		if ctx.Err() == nil {
+			p.err = ww.HostUnreachableError{Peer: id, Err: p.err}
+		}
+
+		ps.drop(id, p)
+		return
+	}
+
+	p.root = mem.Anchor{Client: p.conn.Bootstrap(context.Background())}
+
+	// drop the connection as soon as it fails, so that the host is dialed again
+	go func() {
+		<-p.conn.Done()
+		ps.drop(id, p)
+	}()
+}
+
+// drop p, unless it has already been replaced.
+func (ps *Proxies) drop(id peer.ID, p *proxy) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.conns[id] == p {
+		delete(ps.conns, id)
+	}
+}
+
+func (p *proxy) close() {
+	<-p.ready
+
+	if p.conn != nil {
+		p.root.Client.Release()
+		p.conn.Close()
+	}
+}
+
+// failed returns true if p's connection has failed.
+func (p *proxy) failed() bool {
+	select {
+	case <-p.conn.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// proxyAnchor is an anchor beneath a remote host.  Errors caused by the loss of the
+// connection are reported as a ww.HostUnreachableError, and invalidate the connection.
+type proxyAnchor struct {
+	ww.Anchor
+	ps *Proxies
+	id peer.ID
+	p  *proxy
+}
+
+func (a proxyAnchor) Ls(ctx context.Context) ([]ww.Anchor, error) {
+	return anchorutil.Collect(a.Iter(ctx))
+}
+
+func (a proxyAnchor) Iter(ctx context.Context) ww.AnchorIterator {
+	return proxyIter{
+		AnchorIterator: a.Anchor.Iter(ctx),
+		a:              a,
+	}
+}
+
+func (a proxyAnchor) Walk(_ context.Context, path []string) ww.Anchor {
+	return proxyAnchor{Anchor: a.Anchor.Walk(context.Background(), path), ps: a.ps, id: a.id, p: a.p}
+}
+
+func (a proxyAnchor) Load(ctx context.Context) (ww.Any, error) {
+	v, err := a.Anchor.Load(ctx)
+	return v, a.unreachable(err)
+}
+
+func (a proxyAnchor) Store(ctx context.Context, any ww.Any) error {
+	return a.unreachable(a.Anchor.Store(ctx, any))
+}
+
+func (a proxyAnchor) Go(ctx context.Context, args ...ww.Any) (ww.Any, error) {
+	v, err := a.Anchor.Go(ctx, args...)
+	return v, a.unreachable(err)
+}
+
+// unreachable wraps err in a ww.HostUnreachableError if the connection was lost.
+func (a proxyAnchor) unreachable(err error) error {
+	if err == nil || !(capnp.IsDisconnected(err) || a.p.failed()) {
+		return err
+	}
+
+	a.ps.drop(a.id, a.p)
+	return ww.HostUnreachableError{Peer: a.id, Err: err}
+}
+
+// proxyIter lists the children of a proxyAnchor.
+type proxyIter struct {
+	ww.AnchorIterator
+	a proxyAnchor
+}
+
+func (it proxyIter) Anchor() ww.Anchor {
+	return proxyAnchor{Anchor: it.AnchorIterator.Anchor(), ps: it.a.ps, id: it.a.id, p: it.a.p}
+}
+
+func (it proxyIter) Err() error { return it.a.unreachable(it.AnchorIterator.Err()) }
+
+// errAnchor is an anchor whose methods fail with err.
+type errAnchor struct {
+	path
+	err error
+}
+
+func (a errAnchor) Ls(context.Context) ([]ww.Anchor, error) { return nil, a.err }
+func (a errAnchor) Iter(context.Context) ww.AnchorIterator  { return anchorutil.ErrIter(a.err) }
+
+func (a errAnchor) Walk(_ context.Context, path []string) ww.Anchor {
+	return errAnchor{path: append(append([]string{}, a.path...), path...), err: a.err}
+}
+
+func (a errAnchor) Load(context.Context) (ww.Any, error)          { return nil, a.err }
+func (a errAnchor) Store(context.Context, ww.Any) error           { return a.err }
+func (a errAnchor) Go(context.Context, ...ww.Any) (ww.Any, error) { return nil, a.err }
+
+// Release is a nop.  The anchor holds no resources.
+func (errAnchor) Release() {}
@@ -0,0 +1,165 @@
+package anchor
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	capnprpc "zombiezen.com/go/capnproto2/rpc"
+	"zombiezen.com/go/capnproto2/server"
+
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/internal/rpc"
+)
+
+func TestProxies(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	mn := mocknet.New(ctx)
+	local, remote, absent := newPeer(t, mn, 3000), newPeer(t, mn, 3001), newPeer(t, mn, 3002)
+	_, err := mn.LinkPeers(local.ID(), remote.ID())
+	require.NoError(t, err)
+
+	var live, streams int64
+	remote.SetStreamHandler(ww.AnchorProtocol, func(s network.Stream) {
+		atomic.AddInt64(&streams, 1)
+
+		if _, err := rpc.Admit(s, nil, nil); err != nil {
+			s.Reset()
+			return
+		}
+
+		conn := capnprpc.NewConn(capnprpc.NewStreamTransport(s), &capnprpc.Options{
+			BootstrapClient: mem.Anchor_ServerToClient(&countingServer{live: &live}, &server.Policy{}).Client,
+		})
+		<-conn.Done()
+	})
+
+	ps := NewProxies(rpc.NewTerminal(local), func(peer.ID) bool { return true })
+	defer ps.Close()
+
+	t.Run("Lazy", func(t *testing.T) {
+		assert.Zero(t, ps.Len(), "no host should be dialed before it is walked")
+
+		a := ps.Walk(ctx, []string{remote.ID().String(), "foo"})
+		defer a.Release()
+
+		assert.Equal(t, []string{remote.ID().String(), "foo"}, a.Path())
+
+		v, err := a.Load(ctx)
+		require.NoError(t, err)
+		requireTrue(t, v)
+
+		as, err := a.Walk(ctx, []string{"bar"}).Ls(ctx)
+		require.NoError(t, err)
+		require.Len(t, as, childCount)
+		for _, child := range as {
+			child.Release()
+		}
+
+		b := ps.Walk(ctx, []string{remote.ID().String(), "baz"})
+		defer b.Release()
+
+		_, err = b.Load(ctx)
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, ps.Len())
+		assert.Equal(t, int64(1), atomic.LoadInt64(&streams), "connection should be reused")
+	})
+
+	t.Run("Invalidate", func(t *testing.T) {
+		a := ps.Walk(ctx, []string{remote.ID().String(), "foo"})
+		defer a.Release()
+
+		ps.Invalidate(remote.ID())
+		assert.Zero(t, ps.Len())
+
+		var err error
+		require.Eventually(t, func() bool {
+			_, err = a.Load(ctx)
+			return err != nil
+		}, time.Second, time.Millisecond*10, "anchor should fail once invalidated")
+		requireUnreachable(t, err, remote.ID())
+
+		// the host is dialed again
+		b := ps.Walk(ctx, []string{remote.ID().String(), "foo"})
+		defer b.Release()
+
+		_, err = b.Load(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), atomic.LoadInt64(&streams))
+	})
+
+	t.Run("Retain", func(t *testing.T) {
+		ps.Retain(peer.IDSlice{remote.ID()})
+		assert.Equal(t, 1, ps.Len())
+
+		ps.Retain(nil)
+		assert.Zero(t, ps.Len())
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		a := ps.Walk(ctx, []string{absent.ID().String(), "foo"})
+		defer a.Release()
+
+		_, err := a.Load(ctx)
+		requireUnreachable(t, err, absent.ID())
+
+		_, err = a.Walk(ctx, []string{"bar"}).Ls(ctx)
+		requireUnreachable(t, err, absent.ID())
+
+		assert.Zero(t, ps.Len(), "failed dials should not be cached")
+	})
+
+	t.Run("NotMember", func(t *testing.T) {
+		strict := NewProxies(rpc.NewTerminal(local), func(peer.ID) bool { return false })
+		defer strict.Close()
+
+		dialed := atomic.LoadInt64(&streams)
+
+		_, err := strict.Walk(ctx, []string{remote.ID().String(), "foo"}).Load(ctx)
+		requireUnreachable(t, err, remote.ID())
+		assert.True(t, errors.Is(err, ErrNotMember), "unexpected error %v", err)
+
+		assert.Zero(t, strict.Len())
+		assert.Equal(t, dialed, atomic.LoadInt64(&streams), "non-members should not be dialed")
+	})
+
+	t.Run("InvalidID", func(t *testing.T) {
+		_, err := ps.Walk(ctx, []string{"foo"}).Load(ctx)
+		assert.Error(t, err)
+	})
+}
+
+func newPeer(t *testing.T, mn mocknet.Mocknet, port int) host.Host {
+	sk, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+
+	h, err := mn.AddPeer(sk, multiaddr.StringCast(fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port)))
+	require.NoError(t, err)
+	return h
+}
+
+func requireUnreachable(t *testing.T, err error, id peer.ID) {
+	t.Helper()
+
+	var unreachable ww.HostUnreachableError
+	require.True(t, errors.As(err, &unreachable), "unexpected error %v", err)
+	assert.Equal(t, id, unreachable.Peer)
+}
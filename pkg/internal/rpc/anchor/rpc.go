@@ -2,43 +2,131 @@ package anchor
 
 import (
 	"context"
-	"runtime"
+	"errors"
+	"strings"
+
+	capnp "zombiezen.com/go/capnproto2"
 
 	"github.com/wetware/ww/internal/mem"
 	ww "github.com/wetware/ww/pkg"
 	"github.com/wetware/ww/pkg/internal/rpc"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
 	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
 )
 
 // Ls .
 func Ls(ctx context.Context, t rpc.Terminal, d rpc.Dialer) ([]ww.Anchor, error) {
-	c := t.Dial(ctx, d, ww.AnchorProtocol)
-	defer t.HangUp(c)
+	return anchorutil.Collect(Iter(ctx, t, d))
+}
+
+// Iter lists the hosts in the cluster.  The host is dialed when the iterator is first
+// advanced.
+func Iter(ctx context.Context, t rpc.Terminal, d rpc.Dialer) ww.AnchorIterator {
+	return &iterator{
+		ctx:  ctx,
+		inst: t.Instrument,
+		ad:   adaptHostAnchor(t),
+		open: func() (mem.Anchor, func()) {
+			c := t.Dial(ctx, d, ww.AnchorProtocol)
+			return mem.Anchor{Client: c.Client}, func() { t.HangUp(c) }
+		},
+	}
+}
+
+// iterator lists the children of a remote anchor.  The ls call is sent when the
+// iterator is first advanced, and each child is adapted as it is returned.  The
+// results, which hold the capabilities of the children that were not returned, are
+// released once the iterator is exhausted or released.
+//
+// TODO(performance):  the results arrive in a single message, so abandoning the
+// iterator saves only the adaptation of the remaining children; the remote host still
+// produces the whole listing.  Paging needs cursor parameters on the ls call, which
+// the anchor schema lacks, and is deferred along with paginated Ls.
+type iterator struct {
+	ctx    context.Context
+	open   func() (mem.Anchor, func()) // returns the anchor to list, and its release func
+	inst   rpc.Instrument              // may be nil
+	ad     adapter
+	listed func() // called when the results arrive; may be nil
 
-	return ls(ctx, mem.Anchor{Client: c.Client}, adaptHostAnchor(t))
+	started, finished bool
+	close             func()
+	done              capnp.ReleaseFunc
+	cs                mem.Anchor_SubAnchor_List
+	i                 int
+	cur               ww.Anchor
+	err               error
 }
 
-func ls(ctx context.Context, a mem.Anchor, ad adapter) ([]ww.Anchor, error) {
-	f, done := a.Ls(ctx, nil)
-	defer done()
+func (it *iterator) Next() bool {
+	if it.cur = nil; !it.started {
+		it.started = true
+		it.err = it.ls()
+	}
+
+	if it.finished || it.err != nil || it.i >= it.cs.Len() {
+		it.Release()
+		return false
+	}
+
+	if it.cur, it.err = it.ad.Adapt(it.cs.At(it.i)); it.err != nil {
+		it.Release()
+		return false
+	}
+
+	it.i++
+	return true
+}
+
+func (it *iterator) Anchor() ww.Anchor { return it.cur }
+func (it *iterator) Err() error        { return it.err }
+
+// Release the results.  If the ls call is pending, it is canceled.
+func (it *iterator) Release() {
+	if it.started = true; it.finished {
+		return
+	}
+
+	it.finished = true
+
+	if it.done != nil {
+		it.done()
+	}
+
+	if it.close != nil {
+		it.close()
+	}
+}
+
+func (it *iterator) ls() (err error) {
+	observe := rpc.StartCall(it.inst, ww.AnchorProtocol, "ls")
+	defer func() {
+		err = remoteErr(err)
+		observe(err)
+	}()
+
+	var a mem.Anchor
+	a, it.close = it.open()
+
+	var f mem.Anchor_ls_Results_Future
+	f, it.done = a.Ls(it.ctx, nil)
 
 	select {
 	case <-f.Done(): // promise has resolved
-	case <-ctx.Done():
-		return nil, ctx.Err()
+	case <-it.ctx.Done():
+		return it.ctx.Err()
 	}
 
 	res, err := f.Struct()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	cs, err := res.Children()
-	if err != nil {
-		return nil, err
+	if it.cs, err = res.Children(); err == nil && it.listed != nil {
+		it.listed()
 	}
 
-	return subanchors(cs, ad)
+	return
 }
 
 // Walk returns the anchor at the specified path.
@@ -46,20 +134,44 @@ func Walk(ctx context.Context, t rpc.Terminal, d rpc.Dialer, path []string) ww.A
 	c := t.Dial(ctx, d, ww.AnchorProtocol)
 	defer t.HangUp(c)
 
-	return walk(ctx, mem.Anchor{Client: c.Client}, path)
+	return walk(ctx, mem.Anchor{Client: c.Client}, t.Instrument, path, path)
 }
 
-func walk(ctx context.Context, a mem.Anchor, p path) ww.Anchor {
-	f, done := a.Walk(ctx, func(ps mem.Anchor_walk_Params) error {
-		return ps.SetPath(anchorpath.Join(p.Path()))
+// walk a to the subpath, returning an anchor at p.  The anchor is pipelined on the
+// pending answer, which it holds until released.  Since walk does not wait for the
+// answer, the latency reported to inst is that of sending the call.
+func walk(ctx context.Context, a mem.Anchor, inst rpc.Instrument, p path, subpath []string) ww.Anchor {
+	observe := rpc.StartCall(inst, ww.AnchorProtocol, "walk")
+	defer observe(nil)
+
+	rpc.ReportDetail(inst, ww.AnchorProtocol, "walk", func() rpc.CallDetail {
+		return rpc.CallDetail{Path: p}
 	})
 
-	runtime.SetFinalizer(&f, func(*mem.Anchor_walk_Results_Future) {
-		done()
+	f, done := a.Walk(ctx, func(ps mem.Anchor_walk_Params) error {
+		return ps.SetPath(anchorpath.Join(subpath))
 	})
 
 	return anchor{
-		path:           p,
-		anchorProvider: f,
+		path:    p,
+		client:  f.Anchor(),
+		release: done,
+		inst:    inst,
 	}
 }
+
+// remoteErr restores ww.ErrNotPermitted on errors returned by the remote host, which
+// reports them by message only.
+func remoteErr(err error) error {
+	if err == nil || errors.Is(err, ww.ErrNotPermitted) ||
+		!strings.Contains(err.Error(), ww.ErrNotPermitted.Error()) {
+		return err
+	}
+
+	return notPermitted{err}
+}
+
+type notPermitted struct{ error }
+
+func (notPermitted) Is(target error) bool { return target == ww.ErrNotPermitted }
+func (e notPermitted) Unwrap() error      { return e.error }
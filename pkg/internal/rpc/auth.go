@@ -0,0 +1,192 @@
+package rpc
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	capnp "zombiezen.com/go/capnproto2"
+
+	ww "github.com/wetware/ww/pkg"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
+)
+
+/*
+	auth.go contains the credential exchange that precedes the capnp bootstrap on each
+	RPC stream.  The caller presents a Credential, and the host replies with a single
+	status byte.  If the caller is admitted, the stream carries capnp RPC, and the
+	bootstrap capability is attenuated according to the grant.
+*/
+
+const (
+	// DefaultAuthTimeout bounds the credential exchange.
+	DefaultAuthTimeout = time.Second * 5
+
+	maxCredentialSize = 1024
+
+	statusAdmitted byte = 0
+	statusDenied   byte = 1
+)
+
+// ErrUnauthorized is returned when a host denies a credential.  It wraps
+// ww.ErrNotPermitted.  The host does not report why the credential was denied, so
+// that callers cannot learn which namespaces it serves.
+var ErrUnauthorized = fmt.Errorf("%w: unauthorized", ww.ErrNotPermitted)
+
+// Credential is presented by the caller upon opening an RPC stream.
+type Credential struct {
+	Namespace string `json:"ns"`
+	Token     string `json:"token,omitempty"`
+}
+
+// Guard decides which operations a caller is granted.  A nil Guard admits every caller
+// with all operations, regardless of its credential.
+type Guard struct {
+	Namespace string
+
+	// Tokens grants operations to callers that present a token.
+	Tokens map[string]anchorutil.Op
+
+	// Peers grants operations to the callers with the given peer IDs.  Peer IDs are
+	// authenticated by the libp2p security transport.  Hosts present credentials to
+	// each other like any other caller, so the peers of a guarded host are admitted
+	// only if they are listed here, or present a token.  Cluster membership confers
+	// no trust, since any peer can join the cluster's topic.
+	Peers map[peer.ID]anchorutil.Op
+}
+
+// Authorize returns the operations granted to the caller.  Grants from its token and
+// its peer ID are combined.  It returns false if the credential is for another
+// namespace, or grants no operations.
+func (g *Guard) Authorize(id peer.ID, c Credential) (anchorutil.Op, bool) {
+	if g == nil {
+		return anchorutil.AllOps, true
+	}
+
+	if c.Namespace != g.Namespace {
+		return 0, false
+	}
+
+	ops := g.Peers[id]
+
+	// compare every token in constant time, so that timing reveals nothing about them
+	for token, grant := range g.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(c.Token)) == 1 {
+			ops |= grant
+		}
+	}
+
+	return ops, ops != 0
+}
+
+// Login presents the credential on a newly opened stream, and waits for the host to
+// admit it.  It returns ErrUnauthorized if the credential is denied.
+func Login(rw io.ReadWriter, c Credential) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	if len(b) > maxCredentialSize {
+		return fmt.Errorf("credential of %d bytes exceeds %d", len(b), maxCredentialSize)
+	}
+
+	frame := make([]byte, 2+len(b))
+	binary.BigEndian.PutUint16(frame, uint16(len(b)))
+	copy(frame[2:], b)
+
+	if _, err = rw.Write(frame); err != nil {
+		return err
+	}
+
+	var status [1]byte
+	if _, err = io.ReadFull(rw, status[:]); err != nil {
+		return err
+	}
+
+	if status[0] != statusAdmitted {
+		return ErrUnauthorized
+	}
+
+	return nil
+}
+
+// Admit reads the caller's credential from s, and returns cap, attenuated to the
+// operations granted by g.  Capabilities that do not implement Attenuator are only
+// exported to callers that are granted all operations.  Callers that are denied
+// receive the same reply, whatever the reason.
+func Admit(s network.Stream, g *Guard, cap Capability) (Capability, error) {
+	// best effort; not all transports support deadlines
+	_ = s.SetReadDeadline(time.Now().Add(DefaultAuthTimeout))
+	defer s.SetReadDeadline(time.Time{})
+
+	c, err := readCredential(s)
+	if err != nil {
+		return nil, err
+	}
+
+	ops, ok := g.Authorize(s.Conn().RemotePeer(), c)
+	if ok {
+		cap, ok = attenuate(cap, ops)
+	}
+
+	if !ok {
+		_, _ = s.Write([]byte{statusDenied})
+		return nil, ErrUnauthorized
+	}
+
+	if _, err = s.Write([]byte{statusAdmitted}); err != nil {
+		return nil, err
+	}
+
+	return cap, nil
+}
+
+func readCredential(r io.Reader) (c Credential, err error) {
+	var size [2]byte
+	if _, err = io.ReadFull(r, size[:]); err != nil {
+		return
+	}
+
+	n := binary.BigEndian.Uint16(size[:])
+	if n > maxCredentialSize {
+		return c, fmt.Errorf("credential of %d bytes exceeds %d", n, maxCredentialSize)
+	}
+
+	b := make([]byte, n)
+	if _, err = io.ReadFull(r, b); err == nil {
+		err = json.Unmarshal(b, &c)
+	}
+
+	return
+}
+
+// Attenuator is implemented by capabilities that can be exported with a subset of
+// their operations.
+type Attenuator interface {
+	Attenuate(anchorutil.Op) *capnp.Client
+}
+
+func attenuate(cap Capability, ops anchorutil.Op) (Capability, bool) {
+	if ops == anchorutil.AllOps {
+		return cap, true
+	}
+
+	if a, ok := cap.(Attenuator); ok {
+		return attenuated{Capability: cap, client: a.Attenuate(ops)}, true
+	}
+
+	return nil, false
+}
+
+type attenuated struct {
+	Capability
+	client *capnp.Client
+}
+
+func (a attenuated) Client() *capnp.Client { return a.client }
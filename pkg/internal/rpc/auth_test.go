@@ -0,0 +1,159 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	capnp "zombiezen.com/go/capnproto2"
+
+	ww "github.com/wetware/ww/pkg"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
+)
+
+func TestGuard(t *testing.T) {
+	t.Parallel()
+
+	const (
+		alice = peer.ID("alice")
+		bob   = peer.ID("bob")
+	)
+
+	g := &Guard{
+		Namespace: "ww",
+		Tokens:    map[string]anchorutil.Op{"secret": anchorutil.ReadOnly},
+		Peers:     map[peer.ID]anchorutil.Op{alice: anchorutil.OpStore},
+	}
+
+	for _, tt := range []struct {
+		name string
+		g    *Guard
+		id   peer.ID
+		c    Credential
+		ops  anchorutil.Op
+		ok   bool
+	}{
+		{name: "Nil", g: nil, c: Credential{}, ops: anchorutil.AllOps, ok: true},
+		{name: "Token", g: g, c: Credential{Namespace: "ww", Token: "secret"}, ops: anchorutil.ReadOnly, ok: true},
+		{name: "Peer", g: g, id: alice, c: Credential{Namespace: "ww"}, ops: anchorutil.OpStore, ok: true},
+		{name: "Combined", g: g, id: alice, c: Credential{Namespace: "ww", Token: "secret"}, ops: anchorutil.ReadOnly | anchorutil.OpStore, ok: true},
+		{name: "Unlisted", g: g, id: bob, c: Credential{Namespace: "ww"}},
+		{name: "WrongToken", g: g, c: Credential{Namespace: "ww", Token: "guess"}},
+		{name: "NoToken", g: g, c: Credential{Namespace: "ww"}},
+		{name: "WrongNamespace", g: g, id: bob, c: Credential{Namespace: "other", Token: "secret"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ops, ok := tt.g.Authorize(tt.id, tt.c)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.ops, ops)
+		})
+	}
+}
+
+func TestAdmit(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	mn, err := mocknet.FullMeshConnected(ctx, 2)
+	require.NoError(t, err)
+
+	local, remote := mn.Hosts()[0], mn.Hosts()[1]
+
+	g := &Guard{
+		Namespace: "ww",
+		Tokens: map[string]anchorutil.Op{
+			"admin":  anchorutil.AllOps,
+			"reader": anchorutil.ReadOnly,
+		},
+	}
+
+	// admit returns the capability exported to a caller presenting c, or the error
+	// returned by Login.
+	admit := func(t *testing.T, cap Capability, c Credential) (Capability, error) {
+		const proto = protocol.ID("/ww/test/auth")
+
+		ch := make(chan Capability, 1)
+		remote.SetStreamHandler(proto, func(s network.Stream) {
+			defer s.Close()
+
+			cap, _ := Admit(s, g, cap)
+			ch <- cap
+		})
+
+		s, err := local.NewStream(ctx, remote.ID(), proto)
+		require.NoError(t, err)
+		defer s.Close()
+
+		if err = Login(s, c); err != nil {
+			return nil, err
+		}
+
+		select {
+		case cap = <-ch:
+			return cap, nil
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+			return nil, nil
+		}
+	}
+
+	t.Run("Admitted", func(t *testing.T) {
+		cap := fakeCap{}
+
+		got, err := admit(t, cap, Credential{Namespace: "ww", Token: "admin"})
+		require.NoError(t, err)
+		assert.Equal(t, cap, got, "capability should not be attenuated")
+	})
+
+	t.Run("Attenuated", func(t *testing.T) {
+		cap := &attenuatorCap{}
+
+		got, err := admit(t, cap, Credential{Namespace: "ww", Token: "reader"})
+		require.NoError(t, err)
+		assert.Equal(t, anchorutil.ReadOnly, cap.ops)
+		assert.Equal(t, cap.client, got.Client())
+	})
+
+	t.Run("NotAttenuable", func(t *testing.T) {
+		// callers that are not granted all operations cannot receive a capability
+		// that cannot be attenuated
+		_, err := admit(t, fakeCap{}, Credential{Namespace: "ww", Token: "reader"})
+		assert.Equal(t, ErrUnauthorized, err)
+	})
+
+	t.Run("Denied", func(t *testing.T) {
+		_, wrongToken := admit(t, fakeCap{}, Credential{Namespace: "ww", Token: "guess"})
+		_, wrongNamespace := admit(t, fakeCap{}, Credential{Namespace: "other", Token: "admin"})
+
+		assert.True(t, errors.Is(wrongToken, ww.ErrNotPermitted), "unexpected error %v", wrongToken)
+		assert.Equal(t, wrongToken, wrongNamespace,
+			"denial should not reveal whether the namespace is served")
+	})
+}
+
+type fakeCap struct{}
+
+func (fakeCap) Loggable() map[string]interface{} { return nil }
+func (fakeCap) Protocol() protocol.ID            { return "/ww/test" }
+func (fakeCap) Client() *capnp.Client            { return nil }
+
+type attenuatorCap struct {
+	fakeCap
+	ops    anchorutil.Op
+	client *capnp.Client
+}
+
+func (c *attenuatorCap) Attenuate(ops anchorutil.Op) *capnp.Client {
+	c.ops = ops
+	c.client = capnp.ErrorClient(errors.New("attenuated"))
+	return c.client
+}
@@ -0,0 +1,270 @@
+package rpc
+
+import (
+	"expvar"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/protocol"
+)
+
+// Instrument observes RPC activity.  Implementations MUST be safe for concurrent use,
+// and SHOULD return quickly, since they are called inline.
+type Instrument interface {
+	// OnDialStart is called before opening a stream for protocol pid.
+	OnDialStart(pid protocol.ID)
+	// OnDialDone is called after the stream was opened, or failed to open.
+	OnDialDone(pid protocol.ID, d time.Duration, err error)
+
+	// OnCallStart is called before calling the named method.
+	OnCallStart(pid protocol.ID, method string)
+	// OnCallDone is called after the call has returned.
+	OnCallDone(pid protocol.ID, method string, d time.Duration, err error)
+}
+
+// StartCall reports the start of a call to i, and returns a function that reports its
+// completion.  If i is nil, both are nops.
+func StartCall(i Instrument, pid protocol.ID, method string) func(error) {
+	if i == nil {
+		return nopDone
+	}
+
+	i.OnCallStart(pid, method)
+	start := time.Now()
+
+	return func(err error) {
+		i.OnCallDone(pid, method, time.Since(start), err)
+	}
+}
+
+func nopDone(error) {}
+
+// CallDetail describes a call in more detail than is reported to an Instrument.
+type CallDetail struct {
+	Path []string // anchor path at which the call was made
+	Size int64    // size of the message holding the value sent or received, if any
+}
+
+// CallObserver is an Instrument that also observes the details of calls.  Details are
+// reported only to instruments that implement it, since they cost more to gather.
+type CallObserver interface {
+	Instrument
+
+	// OnCallDetail is called after the call has returned successfully.
+	OnCallDetail(pid protocol.ID, method string, d CallDetail)
+}
+
+// ReportDetail reports the details of a call to i, if it is a CallObserver.  The
+// details are gathered by calling f, which is not called otherwise.
+func ReportDetail(i Instrument, pid protocol.ID, method string, f func() CallDetail) {
+	if o, ok := i.(CallObserver); ok {
+		o.OnCallDetail(pid, method, f())
+	}
+}
+
+// Tee returns an Instrument that reports to each of the non-nil instruments.  It
+// returns nil if there are none, and the instrument itself if there is only one.
+func Tee(is ...Instrument) Instrument {
+	var t tee
+	for _, i := range is {
+		if i != nil {
+			t = append(t, i)
+		}
+	}
+
+	switch len(t) {
+	case 0:
+		return nil
+	case 1:
+		return t[0]
+	}
+
+	return t
+}
+
+type tee []Instrument
+
+func (t tee) OnDialStart(pid protocol.ID) {
+	for _, i := range t {
+		i.OnDialStart(pid)
+	}
+}
+
+func (t tee) OnDialDone(pid protocol.ID, d time.Duration, err error) {
+	for _, i := range t {
+		i.OnDialDone(pid, d, err)
+	}
+}
+
+func (t tee) OnCallStart(pid protocol.ID, method string) {
+	for _, i := range t {
+		i.OnCallStart(pid, method)
+	}
+}
+
+func (t tee) OnCallDone(pid protocol.ID, method string, d time.Duration, err error) {
+	for _, i := range t {
+		i.OnCallDone(pid, method, d, err)
+	}
+}
+
+func (t tee) OnCallDetail(pid protocol.ID, method string, d CallDetail) {
+	for _, i := range t {
+		ReportDetail(i, pid, method, func() CallDetail { return d })
+	}
+}
+
+// LatencyBuckets are the upper bounds of the latency histogram buckets recorded by
+// Metrics.  Latencies above the last bound are counted in an additional bucket.
+var LatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+var (
+	defaultOnce    sync.Once
+	defaultMetrics *Metrics
+)
+
+// DefaultMetrics returns the process-wide metrics, which are published as the expvar
+// "ww_rpc" when first requested.
+func DefaultMetrics() *Metrics {
+	defaultOnce.Do(func() {
+		defaultMetrics = NewMetrics()
+		defaultMetrics.Publish("ww_rpc")
+	})
+
+	return defaultMetrics
+}
+
+// Metrics is an Instrument that records per-protocol latency histograms, in-flight
+// gauges and error counts.  Dials are recorded under the method name "dial".
+type Metrics struct {
+	mu      sync.RWMutex
+	methods map[methodKey]*methodMetrics
+}
+
+// NewMetrics returns an empty set of metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{methods: make(map[methodKey]*methodMetrics)}
+}
+
+// MethodStats is a snapshot of the metrics for a single method.
+type MethodStats struct {
+	Protocol protocol.ID
+	Method   string
+
+	Calls, Errors uint64
+	InFlight      int64
+
+	// Latency holds the number of calls in each of LatencyBuckets, followed by the
+	// number of calls that exceeded the last bucket.
+	Latency []uint64
+}
+
+type methodKey struct {
+	pid    protocol.ID
+	method string
+}
+
+type methodMetrics struct {
+	calls, errors uint64
+	inflight      int64
+	latency       []uint64
+}
+
+// OnDialStart increments the in-flight gauge for dials.
+func (m *Metrics) OnDialStart(pid protocol.ID) { m.OnCallStart(pid, "dial") }
+
+// OnDialDone records the dial.
+func (m *Metrics) OnDialDone(pid protocol.ID, d time.Duration, err error) {
+	m.OnCallDone(pid, "dial", d, err)
+}
+
+// OnCallStart increments the in-flight gauge for the method.
+func (m *Metrics) OnCallStart(pid protocol.ID, method string) {
+	atomic.AddInt64(&m.method(pid, method).inflight, 1)
+}
+
+// OnCallDone records the call's latency and outcome.
+func (m *Metrics) OnCallDone(pid protocol.ID, method string, d time.Duration, err error) {
+	mm := m.method(pid, method)
+	atomic.AddInt64(&mm.inflight, -1)
+	atomic.AddUint64(&mm.calls, 1)
+	if err != nil {
+		atomic.AddUint64(&mm.errors, 1)
+	}
+
+	i := sort.Search(len(LatencyBuckets), func(i int) bool { return d <= LatencyBuckets[i] })
+	atomic.AddUint64(&mm.latency[i], 1)
+}
+
+// Methods returns a snapshot of the metrics, sorted by protocol and method.
+func (m *Metrics) Methods() []MethodStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ms := make([]MethodStats, 0, len(m.methods))
+	for k, mm := range m.methods {
+		s := MethodStats{
+			Protocol: k.pid,
+			Method:   k.method,
+			Calls:    atomic.LoadUint64(&mm.calls),
+			Errors:   atomic.LoadUint64(&mm.errors),
+			InFlight: atomic.LoadInt64(&mm.inflight),
+			Latency:  make([]uint64, len(mm.latency)),
+		}
+
+		for i := range mm.latency {
+			s.Latency[i] = atomic.LoadUint64(&mm.latency[i])
+		}
+
+		ms = append(ms, s)
+	}
+
+	sort.Slice(ms, func(i, j int) bool {
+		if ms[i].Protocol != ms[j].Protocol {
+			return ms[i].Protocol < ms[j].Protocol
+		}
+
+		return ms[i].Method < ms[j].Method
+	})
+
+	return ms
+}
+
+// Publish the metrics as an expvar with the given name.  Like expvar.Publish, it
+// panics if the name is already in use.
+func (m *Metrics) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} { return m.Methods() }))
+}
+
+func (m *Metrics) method(pid protocol.ID, method string) *methodMetrics {
+	k := methodKey{pid: pid, method: method}
+
+	m.mu.RLock()
+	mm, ok := m.methods[k]
+	m.mu.RUnlock()
+
+	if ok {
+		return mm
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if mm, ok = m.methods[k]; !ok {
+		mm = &methodMetrics{latency: make([]uint64, len(LatencyBuckets)+1)}
+		m.methods[k] = mm
+	}
+
+	return mm
+}
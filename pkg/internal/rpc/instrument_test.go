@@ -0,0 +1,88 @@
+package rpc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	m := NewMetrics()
+
+	m.OnDialStart("/test")
+	m.OnDialDone("/test", time.Millisecond*3, nil)
+
+	m.OnCallStart("/test", "load")
+	m.OnCallStart("/test", "load")
+	m.OnCallDone("/test", "load", time.Hour, errors.New("test"))
+
+	ms := m.Methods()
+	require.Len(t, ms, 2)
+
+	assert.Equal(t, "dial", ms[0].Method)
+	assert.Equal(t, uint64(1), ms[0].Calls)
+	assert.Zero(t, ms[0].InFlight)
+	assert.Equal(t, uint64(1), ms[0].Latency[1], "3ms should fall in the 5ms bucket")
+
+	assert.Equal(t, "load", ms[1].Method)
+	assert.Equal(t, uint64(1), ms[1].Calls)
+	assert.Equal(t, uint64(1), ms[1].Errors)
+	assert.Equal(t, int64(1), ms[1].InFlight)
+	assert.Equal(t, uint64(1), ms[1].Latency[len(LatencyBuckets)], "should overflow")
+}
+
+func TestStartCallNil(t *testing.T) {
+	assert.Zero(t, testing.AllocsPerRun(100, func() {
+		StartCall(nil, "/test", "load")(nil)
+	}), "unset instrument should not allocate")
+}
+
+func TestTee(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, Tee(nil, nil))
+
+	a := NewMetrics()
+	assert.Equal(t, a, Tee(nil, a), "single instrument should not be wrapped")
+
+	b := NewMetrics()
+	StartCall(Tee(a, nil, b), "/test", "load")(nil)
+
+	for _, m := range []*Metrics{a, b} {
+		ms := m.Methods()
+		require.Len(t, ms, 1)
+		assert.Equal(t, uint64(1), ms[0].Calls)
+	}
+}
+
+func TestReportDetail(t *testing.T) {
+	t.Parallel()
+
+	ReportDetail(nil, "/test", "load", func() CallDetail {
+		t.Fatal("details should not be gathered without an observer")
+		return CallDetail{}
+	})
+
+	var o detailRecorder
+	ReportDetail(Tee(NewMetrics(), &o), "/test", "load", func() CallDetail {
+		return CallDetail{Path: []string{"foo"}, Size: 42}
+	})
+
+	require.Len(t, o, 1)
+	assert.Equal(t, []string{"foo"}, o[0].Path)
+	assert.Equal(t, int64(42), o[0].Size)
+}
+
+type detailRecorder []CallDetail
+
+func (detailRecorder) OnDialStart(protocol.ID)                               {}
+func (detailRecorder) OnDialDone(protocol.ID, time.Duration, error)          {}
+func (detailRecorder) OnCallStart(protocol.ID, string)                       {}
+func (detailRecorder) OnCallDone(protocol.ID, string, time.Duration, error)  {}
+func (r *detailRecorder) OnCallDetail(_ protocol.ID, _ string, d CallDetail) { *r = append(*r, d) }
@@ -0,0 +1,113 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	multistream "github.com/multiformats/go-multistream"
+
+	ww "github.com/wetware/ww/pkg"
+)
+
+const (
+	// DefaultRetryBase is the delay before the first retry.
+	DefaultRetryBase = 100 * time.Millisecond
+
+	// DefaultRetryCeiling bounds the delay between retries.
+	DefaultRetryCeiling = 5 * time.Second
+)
+
+// RetryPolicy determines how often, and how quickly, a failed attempt to open a stream
+// to a remote host is repeated.  The delay before the nth retry is Base * 2^(n-1),
+// capped at Ceiling, of which a random fraction Jitter is subtracted.
+//
+// The zero value makes a single attempt.
+type RetryPolicy struct {
+	Attempts      int           // maximum number of attempts; values < 1 mean one
+	Base, Ceiling time.Duration // zero values are replaced with the defaults
+	Jitter        float64       // fraction of each delay that is randomized, in [0, 1]
+
+	// Retryable reports whether the attempt that returned err should be repeated.  If
+	// nil, Retryable is used.
+	Retryable func(err error) bool
+}
+
+// Retryable reports whether err is transient.  Cancelled and expired contexts, remote
+// hosts that do not speak any of the requested protocols, and denied credentials are
+// permanent failures.  Other errors, such as refused connections and reset streams, are worth
+// retrying.
+func Retryable(err error) bool {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.Is(err, multistream.ErrNotSupported):
+		return false
+	case errors.Is(err, ww.ErrNotPermitted):
+		return false
+	}
+
+	return true
+}
+
+// Do calls f until it succeeds, fails permanently, or the attempts are exhausted.  It
+// returns the error from the last call to f.  Do stops early if the context expires
+// while waiting to retry; the last error from f is returned rather than the context's.
+func (p RetryPolicy) Do(ctx context.Context, f func() error) (err error) {
+	for n := 1; ; n++ {
+		if err = f(); err == nil || n >= p.Attempts || !p.retryable(err) {
+			return
+		}
+
+		d := p.delay(n)
+		if dl, ok := ctx.Deadline(); ok && time.Until(dl) < d {
+			return // retrying would overrun the deadline
+		}
+
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return
+		}
+	}
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable == nil {
+		return Retryable(err)
+	}
+
+	return p.Retryable(err)
+}
+
+// delay before the nth retry, for n > 0.
+func (p RetryPolicy) delay(n int) time.Duration {
+	base, ceil := p.Base, p.Ceiling
+	if base <= 0 {
+		base = DefaultRetryBase
+	}
+	if ceil <= 0 {
+		ceil = DefaultRetryCeiling
+	}
+
+	d := base
+	for i := 1; i < n && d < ceil; i++ {
+		d *= 2
+	}
+	if d > ceil {
+		d = ceil
+	}
+
+	if j := p.Jitter; j > 0 {
+		if j > 1 {
+			j = 1
+		}
+
+		d -= time.Duration(j * rand.Float64() * float64(d))
+	}
+
+	return d
+}
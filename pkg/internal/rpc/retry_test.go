@@ -0,0 +1,125 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	multistream "github.com/multiformats/go-multistream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	errTransient := errors.New("connection refused")
+
+	t.Run("ZeroValue", func(t *testing.T) {
+		var calls int
+		err := RetryPolicy{}.Do(context.Background(), func() error {
+			calls++
+			return errTransient
+		})
+
+		assert.Equal(t, errTransient, err)
+		assert.Equal(t, 1, calls, "zero value should make a single attempt")
+	})
+
+	t.Run("Succeed", func(t *testing.T) {
+		var calls int
+		err := RetryPolicy{Attempts: 5, Base: time.Millisecond}.Do(context.Background(), func() error {
+			if calls++; calls < 3 {
+				return errTransient
+			}
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("Exhausted", func(t *testing.T) {
+		var calls int
+		err := RetryPolicy{Attempts: 3, Base: time.Millisecond}.Do(context.Background(), func() error {
+			calls++
+			return fmt.Errorf("attempt %d", calls)
+		})
+
+		assert.EqualError(t, err, "attempt 3", "should surface the last error")
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("Permanent", func(t *testing.T) {
+		var calls int
+		err := RetryPolicy{Attempts: 3, Base: time.Millisecond}.Do(context.Background(), func() error {
+			calls++
+			return multistream.ErrNotSupported
+		})
+
+		assert.True(t, errors.Is(err, multistream.ErrNotSupported), "unexpected error %v", err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+
+		var calls int
+		err := RetryPolicy{Attempts: 10, Base: time.Second}.Do(ctx, func() error {
+			calls++
+			return errTransient
+		})
+
+		assert.Equal(t, errTransient, err)
+		assert.Equal(t, 1, calls, "should not wait past the deadline")
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		var calls int
+		err := RetryPolicy{Attempts: 10, Base: time.Hour}.Do(ctx, func() error {
+			if calls++; calls == 1 {
+				cancel()
+			}
+			return errTransient
+		})
+
+		assert.Equal(t, errTransient, err)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestRetryable(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("connection refused"), true},
+		{context.Canceled, false},
+		{fmt.Errorf("dial: %w", context.DeadlineExceeded), false},
+		{fmt.Errorf("negotiate: %w", multistream.ErrNotSupported), false},
+	} {
+		assert.Equal(t, tt.want, Retryable(tt.err), "%v", tt.err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	t.Parallel()
+
+	p := RetryPolicy{Base: time.Millisecond, Ceiling: time.Millisecond * 5}
+	for n, want := range []time.Duration{1, 2, 4, 5, 5} {
+		assert.Equal(t, want*time.Millisecond, p.delay(n+1), "retry %d", n+1)
+	}
+
+	p.Jitter = .5
+	for i := 0; i < 100; i++ {
+		d := p.delay(3)
+		assert.True(t, d > time.Millisecond*2 && d <= time.Millisecond*4,
+			"delay %s outside jitter bounds", d)
+	}
+}
@@ -0,0 +1,199 @@
+package rpc
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+)
+
+// ProtocolStats is a snapshot of the stream activity for a single protocol.
+type ProtocolStats struct {
+	Protocol protocol.ID
+
+	Opened, Closed, Active uint64
+	BytesIn, BytesOut      uint64
+
+	// HandshakeFailures counts streams that terminated with an error before the
+	// remote peer sent any data.
+	HandshakeFailures uint64
+}
+
+// StreamInfo is a snapshot of a single tracked stream.
+type StreamInfo struct {
+	ID       uint64
+	Peer     peer.ID
+	Protocol protocol.ID
+
+	// QueueDepth is the number of bytes that have been submitted for writing, but
+	// not yet accepted by the underlying transport.  A persistently high value
+	// indicates a slow consumer.
+	QueueDepth int64
+}
+
+// StreamStats tracks inbound stream activity for RPC handlers.  It is safe for
+// concurrent use.
+type StreamStats struct {
+	mu      sync.Mutex
+	next    uint64
+	protos  map[protocol.ID]*ProtocolStats
+	streams map[uint64]*meteredStream
+}
+
+// NewStreamStats returns an empty stats table.
+func NewStreamStats() *StreamStats {
+	return &StreamStats{
+		protos:  make(map[protocol.ID]*ProtocolStats),
+		streams: make(map[uint64]*meteredStream),
+	}
+}
+
+// Track a stream.  The returned stream updates the table as it is used, and MUST be
+// used in place of s.
+func (ss *StreamStats) Track(s network.Stream) network.Stream {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ss.next++
+	ps := ss.protocol(s.Protocol())
+	ps.Opened++
+	ps.Active++
+
+	ms := &meteredStream{Stream: s, id: ss.next, stats: ss, proto: ps}
+	ss.streams[ms.id] = ms
+
+	return ms
+}
+
+// Protocols returns a snapshot of per-protocol statistics, sorted by protocol ID.
+func (ss *StreamStats) Protocols() []ProtocolStats {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ps := make([]ProtocolStats, 0, len(ss.protos))
+	for _, p := range ss.protos {
+		ps = append(ps, ProtocolStats{
+			Protocol:          p.Protocol,
+			Opened:            p.Opened,
+			Closed:            p.Closed,
+			Active:            p.Active,
+			BytesIn:           atomic.LoadUint64(&p.BytesIn),
+			BytesOut:          atomic.LoadUint64(&p.BytesOut),
+			HandshakeFailures: p.HandshakeFailures,
+		})
+	}
+
+	sort.Slice(ps, func(i, j int) bool { return ps[i].Protocol < ps[j].Protocol })
+	return ps
+}
+
+// Streams returns a snapshot of active streams, sorted by ID.
+func (ss *StreamStats) Streams() []StreamInfo {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	si := make([]StreamInfo, 0, len(ss.streams))
+	for _, s := range ss.streams {
+		si = append(si, s.Info())
+	}
+
+	sort.Slice(si, func(i, j int) bool { return si[i].ID < si[j].ID })
+	return si
+}
+
+// Reset the stream with the specified ID, if it is still active.
+func (ss *StreamStats) Reset(id uint64) error {
+	ss.mu.Lock()
+	s, ok := ss.streams[id]
+	ss.mu.Unlock()
+
+	if !ok {
+		return errors.New("stream not found")
+	}
+
+	return s.Reset()
+}
+
+// Fail records that the stream terminated with an error.
+func (ss *StreamStats) Fail(s network.Stream) {
+	ms, ok := s.(*meteredStream)
+	if !ok || atomic.LoadUint64(&ms.in) > 0 {
+		return
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ms.proto.HandshakeFailures++
+}
+
+// protocol returns the stats for protocol id.  Callers MUST hold mu.
+func (ss *StreamStats) protocol(id protocol.ID) *ProtocolStats {
+	ps, ok := ss.protos[id]
+	if !ok {
+		ps = &ProtocolStats{Protocol: id}
+		ss.protos[id] = ps
+	}
+
+	return ps
+}
+
+func (ss *StreamStats) release(ms *meteredStream) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	delete(ss.streams, ms.id)
+
+	ms.proto.Closed++
+	ms.proto.Active--
+}
+
+type meteredStream struct {
+	network.Stream
+	id    uint64
+	stats *StreamStats
+	proto *ProtocolStats
+
+	once    sync.Once
+	in      uint64
+	pending int64
+}
+
+func (s *meteredStream) Info() StreamInfo {
+	return StreamInfo{
+		ID:         s.id,
+		Peer:       s.Conn().RemotePeer(),
+		Protocol:   s.Protocol(),
+		QueueDepth: atomic.LoadInt64(&s.pending),
+	}
+}
+
+func (s *meteredStream) Read(b []byte) (n int, err error) {
+	n, err = s.Stream.Read(b)
+	atomic.AddUint64(&s.in, uint64(n))
+	atomic.AddUint64(&s.proto.BytesIn, uint64(n))
+	return
+}
+
+func (s *meteredStream) Write(b []byte) (n int, err error) {
+	atomic.AddInt64(&s.pending, int64(len(b)))
+	defer atomic.AddInt64(&s.pending, -int64(len(b)))
+
+	n, err = s.Stream.Write(b)
+	atomic.AddUint64(&s.proto.BytesOut, uint64(n))
+	return
+}
+
+func (s *meteredStream) Close() error {
+	defer s.once.Do(func() { s.stats.release(s) })
+	return s.Stream.Close()
+}
+
+func (s *meteredStream) Reset() error {
+	defer s.once.Do(func() { s.stats.release(s) })
+	return s.Stream.Reset()
+}
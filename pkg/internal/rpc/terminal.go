@@ -2,6 +2,7 @@ package rpc
 
 import (
 	"context"
+	"time"
 
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/network"
@@ -9,6 +10,7 @@ import (
 	"github.com/libp2p/go-libp2p-core/protocol"
 
 	capnp "zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/rpc"
 )
 
 // Client tags a capnp.Client with the remote endpoint's peer.ID.
@@ -26,6 +28,16 @@ type Client struct {
 // TODO(performance):  Resource cacheing is in-scope and will be added in the future.
 type Terminal struct {
 	host.Host
+
+	// Retry governs attempts to open streams to remote hosts.  The zero value makes a
+	// single attempt.
+	Retry RetryPolicy
+
+	// Instrument, if non-nil, observes dials and calls made through the terminal.
+	Instrument Instrument
+
+	// Credential is presented to the remote host on each stream.
+	Credential Credential
 }
 
 // NewTerminal .
@@ -35,11 +47,41 @@ func NewTerminal(h host.Host) Terminal {
 	}
 }
 
+// WithRetry returns a copy of the terminal that opens streams according to p.
+func (t Terminal) WithRetry(p RetryPolicy) Terminal {
+	t.Retry = p
+	return t
+}
+
+// WithInstrument returns a copy of the terminal that reports its activity to i.
+func (t Terminal) WithInstrument(i Instrument) Terminal {
+	t.Instrument = i
+	return t
+}
+
+// WithCredential returns a copy of the terminal that presents c to remote hosts.
+func (t Terminal) WithCredential(c Credential) Terminal {
+	t.Credential = c
+	return t
+}
+
 // Dial a method on a remote host
 func (t Terminal) Dial(ctx context.Context, d Dialer, pids ...protocol.ID) Client {
 	return d.Dial(ctx, streamCachingHost(t), pids)
 }
 
+// Connect opens an RPC connection to the specified peer.  Unlike Dial, it reports a
+// failure to open the stream, and the caller owns the connection, which remains open
+// until it is closed, or until the stream fails.
+func (t Terminal) Connect(ctx context.Context, id peer.ID, pids ...protocol.ID) (*rpc.Conn, error) {
+	s, err := streamCachingHost(t).NewStream(ctx, id, pids...)
+	if err != nil {
+		return nil, err
+	}
+
+	return rpc.NewConn(rpc.NewStreamTransport(s), &rpc.Options{}), nil
+}
+
 // HangUp the client, freeing its resources for reuse.
 func (t Terminal) HangUp(c Client) {
 	/*
@@ -57,10 +99,50 @@ type Session struct {
 
 type streamCachingHost Terminal
 
-// NewStream overrides Host.NewStream, using cached results
-func (h streamCachingHost) NewStream(ctx context.Context, id peer.ID, pids ...protocol.ID) (network.Stream, error) {
+// NewStream overrides Host.NewStream, using cached results.  Failed attempts are
+// retried according to the terminal's retry policy.  The terminal's credential is
+// presented on the stream before it is returned.
+func (h streamCachingHost) NewStream(ctx context.Context, id peer.ID, pids ...protocol.ID) (s network.Stream, err error) {
 	/*
 		TODO(performance) caching goes here
 	*/
-	return h.Host.NewStream(ctx, id, pids...)
+	if h.Instrument != nil && len(pids) > 0 {
+		h.Instrument.OnDialStart(pids[0])
+		defer func(start time.Time) {
+			h.Instrument.OnDialDone(pids[0], time.Since(start), err)
+		}(time.Now())
+	}
+
+	err = h.Retry.Do(ctx, func() (err error) {
+		if s, err = h.Host.NewStream(ctx, id, pids...); err == nil {
+			if err = login(ctx, s, h.Credential); err != nil {
+				s.Reset()
+			}
+		}
+
+		return
+	})
+
+	return
+}
+
+// login presents the credential on s, resetting the stream if ctx expires first.
+func login(ctx context.Context, s network.Stream, c Credential) error {
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Reset()
+		case <-done:
+		}
+	}()
+
+	err := Login(s, c)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
 }
@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/protocol"
+
+	ww "github.com/wetware/ww/pkg"
+	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
+)
+
+// Trace is an Instrument that logs each stream and call, with its duration.  If Detail
+// is true, it also logs the anchor path of each call, and the size of the value it
+// carries.  Entries are logged at info level, so that the verbosity is chosen by the
+// logger that is passed in.
+type Trace struct {
+	Log    ww.Logger
+	Detail bool
+}
+
+// OnDialStart satisfies Instrument.
+func (t Trace) OnDialStart(pid protocol.ID) {
+	t.Log.WithField("protocol", pid).Info("negotiating protocol")
+}
+
+// OnDialDone satisfies Instrument.
+func (t Trace) OnDialDone(pid protocol.ID, d time.Duration, err error) {
+	log := t.Log.WithField("protocol", pid).WithField("duration", d)
+	if err != nil {
+		log.WithError(err).Info("stream failed")
+		return
+	}
+
+	log.Info("stream opened")
+}
+
+// OnCallStart satisfies Instrument.  Calls are logged when they return.
+func (t Trace) OnCallStart(protocol.ID, string) {}
+
+// OnCallDone satisfies Instrument.
+func (t Trace) OnCallDone(pid protocol.ID, method string, d time.Duration, err error) {
+	log := t.Log.WithField("method", method).WithField("duration", d)
+	if err != nil {
+		log = log.WithError(err)
+	}
+
+	log.Info("call")
+}
+
+// OnCallDetail satisfies CallObserver.  It logs nothing unless Detail is true.
+func (t Trace) OnCallDetail(pid protocol.ID, method string, d CallDetail) {
+	if !t.Detail {
+		return
+	}
+
+	log := t.Log.WithField("method", method).WithField("path", anchorpath.Join(d.Path))
+	if d.Size > 0 {
+		log = log.WithField("size", d.Size)
+	}
+
+	log.Info("call detail")
+}
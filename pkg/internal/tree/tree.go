@@ -3,6 +3,7 @@ package tree
 
 import (
 	"runtime"
+	"sort"
 	"sync"
 
 	"github.com/wetware/ww/internal/mem"
@@ -47,7 +48,7 @@ func (n Node) Walk(path []string) Node {
 	return Node{n.nodeRef.Walk(path)}
 }
 
-// List the anchor's children
+// List the anchor's children, sorted by name.
 func (n Node) List() []Node {
 	// N.B.:  hard-lock because the List() operation may co-occur with a sub-anchor
 	//		  creation/deletion.
@@ -59,6 +60,10 @@ func (n Node) List() []Node {
 		children = append(children, Node{child.ref()})
 	}
 
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Name < children[j].Name
+	})
+
 	return children
 }
 
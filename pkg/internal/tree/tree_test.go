@@ -1,6 +1,7 @@
 package tree_test
 
 import (
+	"runtime"
 	"sync"
 	"testing"
 
@@ -22,6 +23,23 @@ func TestTree(t *testing.T) {
 		assert.NotEmpty(t, root.List())
 	})
 
+	t.Run("ListSorted", func(t *testing.T) {
+		root := tree.New()
+
+		var children []tree.Node
+		for _, name := range []string{"c", "a", "b"} {
+			children = append(children, root.Walk([]string{name}))
+		}
+
+		var names []string
+		for _, n := range root.List() {
+			names = append(names, n.Name)
+		}
+
+		assert.Equal(t, []string{"a", "b", "c"}, names)
+		runtime.KeepAlive(children)
+	})
+
 	t.Run("Walk", func(t *testing.T) {
 		t.Run("test", func(t *testing.T) {
 			root := tree.New()
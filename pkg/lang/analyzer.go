@@ -10,10 +10,14 @@ import (
 	"github.com/wetware/ww/internal/mem"
 	ww "github.com/wetware/ww/pkg"
 	"github.com/wetware/ww/pkg/lang/core"
+	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
 )
 
 var _ core.Analyzer = (*analyzer)(nil)
 
+// ErrNoCluster is returned when a path is analyzed by a VM that has no root anchor.
+var ErrNoCluster = errors.New("path operations require a cluster connection")
+
 // SpecialParser defines a special form.
 type SpecialParser func(core.Analyzer, core.Env, core.Seq) (core.Expr, error)
 
@@ -22,24 +26,30 @@ type analyzer struct {
 	special map[string]SpecialParser
 }
 
-func newAnalyzer(root ww.Anchor, paths []string) (core.Analyzer, error) {
-	if root == nil {
-		return nil, errors.New("nil anchor")
-	}
-
+func newAnalyzer(root ww.Anchor, procs *procTable, loaders *loaderTable, paths []string) (core.Analyzer, error) {
 	return analyzer{
 		root: root,
 		special: map[string]SpecialParser{
-			"do":    parseDo,
-			"if":    parseIf,
-			"def":   parseDef,
-			"fn":    parseFn,
-			"macro": parseMacro,
-			"quote": parseQuote,
-			// "go": c.Go,
-			"ls":     lsParser(root),
-			"eval":   parseEval,
-			"import": importer(paths).Parse,
+			"do":          parseDo,
+			"if":          parseIf,
+			"if-let":      parseIfLet,
+			"when":        whenParser("when", false),
+			"when-not":    whenParser("when-not", true),
+			"def":         parseDef,
+			"defn":        parseDefn,
+			"meta":        parseMeta,
+			"defprotocol": parseDefProtocol,
+			"extend-type": parseExtendType,
+			"fn":          parseFn,
+			"delay":       parseDelay,
+			"macro":       parseMacro,
+			"quote":       parseQuote,
+			"comment":     parseComment,
+			"go":          goParser(root, procs),
+			"select":      parseSelect,
+			"ls":          lsParser(root),
+			"eval":        parseEval,
+			"import":      importer{paths: paths, root: root, loaders: loaders}.Parse,
 		},
 	}, nil
 }
@@ -71,6 +81,10 @@ func (a analyzer) analyze(env core.Env, any ww.Any) (core.Expr, error) {
 		return ResolveExpr{f}, nil
 
 	case core.Path:
+		if err := checkPath(a.root, f); err != nil {
+			return nil, err
+		}
+
 		return PathExpr{
 			Root: a.root,
 			Path: f,
@@ -153,8 +167,9 @@ func (a analyzer) analyzeSeq(env core.Env, seq core.Seq) (core.Expr, error) {
 
 	case core.Invokable:
 		return InvokeExpr{
-			Target: t,
-			Args:   as,
+			Analyzer: a,
+			Target:   t,
+			Args:     as,
 		}, nil
 
 	}
@@ -327,3 +342,26 @@ func resolve(env core.Env, symbol string) (any ww.Any, err error) {
 
 	return
 }
+
+// checkPath reports an error if p is not a valid absolute path, or if there is no
+// root anchor to resolve it against.  Relative paths are rejected, since there is
+// no notion of a current anchor.
+func checkPath(root ww.Anchor, p core.Path) error {
+	s, err := p.Path()
+	if err != nil {
+		return err
+	}
+
+	if err = anchorpath.Validate(s); err == nil && root == nil {
+		err = ErrNoCluster
+	}
+
+	if err != nil {
+		return core.Error{
+			Cause:   err,
+			Message: s,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package lang
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	score "github.com/spy16/slurp/core"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	memutil "github.com/wetware/ww/pkg/util/mem"
+)
+
+// ErrBudget is returned when an evaluation exceeds its EvalBudget.  The error is a
+// BudgetExceeded, which reports the limit that tripped.
+var ErrBudget = errors.New("budget exceeded")
+
+// EvalBudget bounds the resources consumed by an evaluation, so that untrusted forms
+// cannot exhaust the host before a deadline fires.  Zero fields are unlimited.
+type EvalBudget struct {
+	// MaxSteps bounds the number of function calls.  Each iteration of a tail call
+	// counts as a call.
+	MaxSteps int64
+
+	// MaxBytes bounds an estimate of the bytes allocated for values.  The estimate
+	// is the size of each new value returned by a native function or built by a
+	// vector literal.  Temporary allocations are not counted.
+	MaxBytes int64
+}
+
+// BudgetExceeded is returned when an evaluation exceeds its budget.
+type BudgetExceeded struct {
+	Limit string // "steps" or "bytes"
+	Max   int64
+
+	// Fn is the name of the function that was being called when the limit tripped.
+	Fn string
+
+	// File, Line and Col locate the top-level form whose evaluation exceeded the
+	// budget.  They are set by VM.EvalSource, and are zero otherwise.
+	File      string
+	Line, Col int
+}
+
+// Is returns true if err is ErrBudget.
+func (e BudgetExceeded) Is(err error) bool { return err == ErrBudget }
+
+func (e BudgetExceeded) Error() string {
+	msg := fmt.Sprintf("%s: %s (max %d)", ErrBudget, e.Limit, e.Max)
+	if e.Fn != "" {
+		msg += fmt.Sprintf(" in '%s'", e.Fn)
+	}
+
+	if e.Line > 0 {
+		file := e.File
+		if file == "" {
+			file = "<unknown>"
+		}
+
+		msg = fmt.Sprintf("%s:%d:%d: %s", file, e.Line, e.Col, msg)
+	}
+
+	return msg
+}
+
+type keyBudget struct{}
+
+// WithBudget returns a context that binds evaluations to the budget.  Each call to
+// VM.EvalContext with the context is given its own copy of the budget.  A nil budget
+// is unlimited, which is the default.
+func WithBudget(ctx context.Context, b *EvalBudget) context.Context {
+	return context.WithValue(ctx, keyBudget{}, b)
+}
+
+// budget is the remainder of an EvalBudget.  It is shared by the processes spawned
+// by the evaluation, and by no other evaluation.  A nil budget is unlimited.
+type budget struct {
+	EvalBudget
+	steps, bytes int64
+}
+
+// newBudget returns the budget bound to ctx, or nil.
+func newBudget(ctx context.Context) *budget {
+	eb, _ := ctx.Value(keyBudget{}).(*EvalBudget)
+	if eb == nil || (eb.MaxSteps <= 0 && eb.MaxBytes <= 0) {
+		return nil
+	}
+
+	return &budget{EvalBudget: *eb}
+}
+
+// step charges one step to the budget, and returns a BudgetExceeded error if either
+// limit is exceeded.  The caller sets the Fn field.
+func (b *budget) step() error {
+	if b == nil {
+		return nil
+	}
+
+	if b.MaxSteps > 0 && atomic.AddInt64(&b.steps, 1) > b.MaxSteps {
+		return BudgetExceeded{Limit: "steps", Max: b.MaxSteps}
+	}
+
+	return b.checkBytes()
+}
+
+// alloc charges the size of v to the budget, unless v belongs to the same message as
+// one of the values from which it was derived.  It returns a BudgetExceeded error if
+// the bytes limit is exceeded.  The caller sets the Fn field.
+func (b *budget) alloc(v score.Any, from []ww.Any) error {
+	if b == nil || b.MaxBytes <= 0 {
+		return nil
+	}
+
+	any, ok := v.(ww.Any)
+	if !ok {
+		return nil
+	}
+
+	seg := any.Value().Segment()
+	if seg == nil {
+		return nil
+	}
+
+	for _, f := range from {
+		if s := f.Value().Segment(); s != nil && s.Message() == seg.Message() {
+			return nil
+		}
+	}
+
+	atomic.AddInt64(&b.bytes, memutil.Size(any.Value()))
+	return b.checkBytes()
+}
+
+func (b *budget) checkBytes() error {
+	if b.MaxBytes > 0 && atomic.LoadInt64(&b.bytes) > b.MaxBytes {
+		return BudgetExceeded{Limit: "bytes", Max: b.MaxBytes}
+	}
+
+	return nil
+}
+
+// checkStep returns an error if the evaluation bound to env was canceled or has
+// exhausted its budget.  It is called once per function call, so it must be cheap
+// when the budget is unlimited.  The name of fn is resolved only if the budget was
+// exceeded.
+func checkStep(env core.Env, fn interface{}) error {
+	e, ok := env.(ctxEnv)
+	if !ok {
+		return nil
+	}
+
+	if err := e.ctx.Err(); err != nil {
+		return err
+	}
+
+	return withFn(e.budget.step(), fn)
+}
+
+// checkAlloc charges v to the budget of the evaluation bound to env.  See budget.alloc.
+func checkAlloc(env core.Env, fn interface{}, v score.Any, from []ww.Any) error {
+	if e, ok := env.(ctxEnv); ok {
+		return withFn(e.budget.alloc(v, from), fn)
+	}
+
+	return nil
+}
+
+func withFn(err error, fn interface{}) error {
+	if be, ok := err.(BudgetExceeded); ok {
+		be.Fn = nameOf(fn)
+		return be
+	}
+
+	return err
+}
+
+func nameOf(fn interface{}) string {
+	switch f := fn.(type) {
+	case core.Fn:
+		name, _ := f.Name()
+		return name
+
+	case *funcWrapper:
+		name, _ := f.sym.Symbol()
+		return name
+	}
+
+	return ""
+}
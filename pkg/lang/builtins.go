@@ -3,29 +3,79 @@ package lang
 import (
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	slurpreader "github.com/spy16/slurp/reader"
 
 	ww "github.com/wetware/ww/pkg"
 	"github.com/wetware/ww/pkg/lang/core"
+	"github.com/wetware/ww/pkg/lang/reader"
 	capnp "zombiezen.com/go/capnproto2"
 )
 
-func loadBuiltins(env core.Env, a core.Analyzer) error {
+func loadBuiltins(env core.Env, a core.Analyzer, procs *procTable) error {
 	return bindAll(env,
 		comparison(),
+		processes(procs),
+		channels(),
+		pubsubs(),
+		collections(),
+		regexes(),
+		completions(env),
 		function("nil?", "__isnil__", core.IsNil),
 		function("not", "__not__", fnNot),
 		function("read", "__read__", fnRead),
 		function("render", "__render__", core.Render),
+		function("str", "__str__", fnStr),
 		function("print", "__print__", fnPrint),
+		function("println", "__println__", fnPrintln),
 		function("len", "__len__", fnLen),
-		function("pop", "__pop__", core.Pop),
-		function("conj", "__conj__", core.Conj),
 		function("type", "__type__", fnTypeOf),
-		function("next", "__next__", fnNext))
+		function("next", "__next__", fnNext),
+		function("memoize", "__memoize__", fnMemoize),
+		function("force", "__force__", fnForce))
 }
 
-func fnRead(any ww.Any) (core.List, error) {
-	return nil, errors.New("NOT IMPLEMENTED")
+// stdin is read by (read).  It is shared by all VMs, so that input buffered by one
+// call is not lost to the next.
+var stdin struct {
+	sync.Mutex
+	rd *slurpreader.Reader
+}
+
+// (read) reads the next form from the process' stdin, and returns nil at the end of
+// the input.
+func fnRead() (ww.Any, error) {
+	stdin.Lock()
+	defer stdin.Unlock()
+
+	if stdin.rd == nil {
+		rd, err := reader.New(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+
+		stdin.rd = rd
+	}
+
+	form, err := stdin.rd.One()
+	if errors.Is(err, io.EOF) {
+		return core.Nil{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := form.(ww.Any)
+	if !ok {
+		return nil, fmt.Errorf("cannot read %T", form)
+	}
+
+	return v, nil
 }
 
 func fnNot(any ww.Any) (bool, error) {
@@ -41,6 +91,51 @@ func fnPrint(any ww.Any) (int, error) {
 	return fmt.Print(s)
 }
 
+// (println x*) writes its arguments to the process' stdout, separated by spaces and
+// followed by a newline.  Arguments are printed as by str.
+func fnPrintln(args ...ww.Any) (core.Nil, error) {
+	ss := make([]string, len(args))
+	for i, arg := range args {
+		var err error
+		if ss[i], err = fnStr(arg); err != nil {
+			return core.Nil{}, err
+		}
+	}
+
+	_, err := fmt.Println(strings.Join(ss, " "))
+	return core.Nil{}, err
+}
+
+// (str x*) concatenates its arguments.  Strings and characters contribute their
+// contents and nil contributes nothing; other values are rendered.
+func fnStr(args ...ww.Any) (string, error) {
+	var b strings.Builder
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case core.String:
+			s, err := v.Value().Str()
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(s)
+
+		case core.Char:
+			b.WriteRune(v.Char())
+
+		case core.Nil:
+
+		default:
+			s, err := core.Render(arg)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(s)
+		}
+	}
+
+	return b.String(), nil
+}
+
 func fnLen(c core.Countable) (int, error) { return c.Count() }
 
 func fnTypeOf(a ww.Any) (core.Symbol, error) {
@@ -49,6 +144,151 @@ func fnTypeOf(a ww.Any) (core.Symbol, error) {
 
 func fnNext(seq core.Seq) (core.Seq, error) { return seq.Next() }
 
+func collections() bindFunc {
+	return func(env core.Env) error {
+		return bindAll(env,
+			function("count", "__count__", fnLen),
+			function("pop", "__pop__", core.Pop),
+			function("peek", "__peek__", core.Peek),
+			function("conj", "__conj__", core.Conj),
+			function("first", "__first__", core.First),
+			function("rest", "__rest__", core.Rest),
+			function("nth", "__nth__", fnNth),
+			function("subvec", "__subvec__", fnSubvec),
+			function("into", "__into__", core.Into),
+			function("concat", "__concat__", core.Concat),
+			function("sort", "__sort__", fnSort),
+			function("sort-by", "__sort_by__", fnSortBy))
+	}
+}
+
+// (nth coll i default?)
+func fnNth(coll ww.Any, i core.Int64, def ...ww.Any) (v ww.Any, err error) {
+	if len(def) > 1 {
+		return nil, fmt.Errorf("%w: got %d, want at-most 3", core.ErrArity, len(def)+2)
+	}
+
+	switch c := coll.(type) {
+	case core.String:
+		v, err = c.EntryAt(int(i.Int64()))
+	case core.Container:
+		v, err = core.Nth(c, int(i.Int64()))
+	default:
+		return nil, fmt.Errorf("cannot index '%s'", coll.Value().Which())
+	}
+
+	if len(def) == 1 && errors.Is(err, core.ErrIndexOutOfBounds) {
+		return def[0], nil
+	}
+
+	return v, err
+}
+
+// (subvec v start end?)
+func fnSubvec(v core.Vector, start core.Int64, end ...core.Int64) (core.Vector, error) {
+	if len(end) > 1 {
+		return nil, fmt.Errorf("%w: got %d, want at-most 3", core.ErrArity, len(end)+2)
+	}
+
+	cnt, err := v.Count()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(end) == 1 {
+		cnt = int(end[0].Int64())
+	}
+
+	return core.NewSubVector(v, int(start.Int64()), cnt)
+}
+
+// (sort comp? coll)
+func fnSort(args ...ww.Any) (core.Vector, error) {
+	switch len(args) {
+	case 1:
+		return core.Sort(args[0])
+	case 2:
+		cmp, err := comparator(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		return core.SortBy(args[1], nil, cmp)
+	}
+
+	return nil, fmt.Errorf("%w: got %d, want 1 or 2", core.ErrArity, len(args))
+}
+
+// (sort-by keyfn comp? coll)
+func fnSortBy(keyfn core.Invokable, args ...ww.Any) (core.Vector, error) {
+	key := func(item ww.Any) (ww.Any, error) { return keyfn.Invoke(item) }
+
+	switch len(args) {
+	case 1:
+		return core.SortBy(args[0], key, nil)
+	case 2:
+		cmp, err := comparator(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		return core.SortBy(args[1], key, cmp)
+	}
+
+	return nil, fmt.Errorf("%w: got %d, want 2 or 3", core.ErrArity, len(args)+1)
+}
+
+// comparator adapts an invokable to a core.Comparator.  The invokable returns either
+// a number whose sign gives the ordering, or a boolean that is true iff a < b.
+func comparator(any ww.Any) (core.Comparator, error) {
+	fn, ok := any.(core.Invokable)
+	if !ok {
+		return nil, fmt.Errorf("comparator must be invokable, not '%s'", any.Value().Which())
+	}
+
+	return func(a, b ww.Any) (int, error) {
+		res, err := fn.Invoke(a, b)
+		if err != nil {
+			return 0, err
+		}
+
+		switch v := res.(type) {
+		case core.Int64:
+			return sign(v.Int64()), nil
+
+		case core.Bool:
+			if v.Bool() {
+				return -1, nil
+			}
+
+			// a >= b; check whether b < a to distinguish equal items
+			if res, err = fn.Invoke(b, a); err != nil {
+				return 0, err
+			}
+
+			if ok, err := core.IsTruthy(res); ok || err != nil {
+				return 1, err
+			}
+
+			return 0, nil
+		}
+
+		return 0, fmt.Errorf("comparator must return int or bool, not '%s'",
+			res.Value().Which())
+	}, nil
+}
+
+func sign(i int64) int {
+	switch {
+	case i < 0:
+		return -1
+	case i > 0:
+		return 1
+	}
+
+	return 0
+}
+
 func comparison() bindFunc {
 	return func(env core.Env) error {
 		return bindAll(env,
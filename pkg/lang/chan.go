@@ -0,0 +1,39 @@
+package lang
+
+import (
+	"context"
+	"fmt"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+)
+
+func channels() bindFunc {
+	return func(env core.Env) error {
+		return bindAll(env,
+			function("chan", "__chan__", func(n ...core.Int64) (core.Chan, error) {
+				switch len(n) {
+				case 0:
+					return core.NewChan(0)
+				case 1:
+					return core.NewChan(int(n[0].Int64()))
+				}
+
+				return core.Chan{}, fmt.Errorf("%w: expected at most 1 argument, got %d",
+					core.ErrArity, len(n))
+			}),
+			function("send!", "__send__", func(ctx context.Context, ch core.Chan, v ww.Any) error {
+				return ch.Send(ctx, v)
+			}),
+			function("recv!", "__recv__", func(ctx context.Context, ch core.Chan) (ww.Any, error) {
+				v, ok, err := ch.Recv(ctx)
+				if err == nil && !ok {
+					v = core.Nil{}
+				}
+				return v, err
+			}),
+			function("close!", "__close__", func(ch core.Chan) error {
+				return ch.Close()
+			}))
+	}
+}
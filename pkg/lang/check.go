@@ -0,0 +1,636 @@
+package lang
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	score "github.com/spy16/slurp/core"
+
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	"github.com/wetware/ww/pkg/lang/reader"
+)
+
+// SourceForm is a top-level form, and the position at which it begins.  Lines and
+// columns are 1-based, as in reader.Error.
+type SourceForm struct {
+	Form      score.Any
+	File      string
+	Line, Col int
+
+	syms []symbolPos // symbols in the form, in the order in which they were read
+}
+
+// annotate reports err at the position of f.  A BudgetExceeded error keeps the
+// position of the innermost form, e.g. that of a form in an imported module.  Other
+// errors are wrapped in a reader.Error.
+func (f SourceForm) annotate(err error) error {
+	var be BudgetExceeded
+	if errors.As(err, &be) {
+		if be.Line == 0 {
+			be.File, be.Line, be.Col = f.File, f.Line, f.Col
+		}
+
+		return be
+	}
+
+	return reader.Error{File: f.File, Line: f.Line, Col: f.Col, Cause: err}
+}
+
+// symbolPos is the position of a symbol read by ReadSource.
+type symbolPos struct {
+	name      string
+	line, col int
+}
+
+// ReadSource reads every form in src, recording the position of each, and that of
+// the symbols it contains.  The file name is used for positions and errors only.
+func ReadSource(file string, src io.Reader) ([]SourceForm, error) {
+	var syms []symbolPos
+	rd, err := reader.New(src, reader.WithSymbolHook(func(name string, line, col int) {
+		syms = append(syms, symbolPos{name: name, line: line, col: col})
+	}))
+	if err != nil {
+		return nil, err
+	}
+	rd.File = file
+
+	var forms []SourceForm
+	for {
+		if err = rd.SkipSpaces(); err == io.EOF {
+			return forms, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		pos := rd.Position()
+		f := SourceForm{File: file, Line: pos.Ln, Col: pos.Col + 1}
+
+		syms = nil
+		if f.Form, err = rd.One(); err == io.EOF { // trailing comment
+			return forms, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		f.syms = syms
+		forms = append(forms, f)
+	}
+}
+
+// Warning is a diagnostic reported by Check.  Warnings are reported at the position
+// of the offending symbol, if the form was read by ReadSource, and otherwise at that
+// of the enclosing top-level form.
+type Warning struct {
+	File      string
+	Line, Col int
+	Message   string
+}
+
+func (w Warning) String() string {
+	file := w.File
+	if file == "" {
+		file = "<unknown>"
+	}
+
+	return fmt.Sprintf("%s:%d:%d: warning: %s", file, w.Line, w.Col, w.Message)
+}
+
+// Check reports references to symbols that are neither bound in env nor defined by
+// any of the forms, and fn and if-let bindings that are never referenced.  Forms are
+// not evaluated.  Definitions may follow their use, since function bodies are not
+// evaluated until they are called.
+//
+// Function bodies are only analyzed when called, so Check walks the forms themselves,
+// following the syntax of the special forms.  Names bound by eval and import are not
+// known until runtime, so undefined symbols are not reported in the scope enclosing
+// either form.  Likewise, the arguments to macros are not checked for undefined
+// symbols, since the expansion may bind them.  Bindings whose names begin with '_'
+// are not reported as unused.
+func Check(env core.Env, forms []SourceForm) []Warning {
+	c := checker{env: env, defs: make(map[string]bool)}
+	for _, f := range forms {
+		c.define(f.Form.(ww.Any))
+	}
+
+	root := &scope{}
+	for _, f := range forms {
+		c.form(root, newNode(f))
+	}
+
+	var ws []Warning
+	for _, ref := range c.undefined {
+		if !ref.scope.isDynamic() {
+			ws = append(ws, ref.Warning)
+		}
+	}
+
+	ws = append(ws, c.unused...)
+	sort.SliceStable(ws, func(i, j int) bool {
+		if ws[i].File != ws[j].File {
+			return ws[i].File < ws[j].File
+		}
+
+		if ws[i].Line != ws[j].Line {
+			return ws[i].Line < ws[j].Line
+		}
+
+		return ws[i].Col < ws[j].Col
+	})
+
+	return ws
+}
+
+type checker struct {
+	env  core.Env
+	defs map[string]bool // names defined anywhere in the forms
+
+	undefined []undefinedRef
+	unused    []Warning
+}
+
+// undefinedRef is reported unless its scope turns out to be dynamic.
+type undefinedRef struct {
+	Warning
+	scope *scope
+}
+
+type scope struct {
+	parent   *scope
+	bindings []*binding
+	dynamic  bool // contains eval or import
+}
+
+type binding struct {
+	name       string
+	used, self bool // self is the name of the enclosing fn
+	pos        position
+}
+
+func (s *scope) child() *scope { return &scope{parent: s} }
+
+func (s *scope) bind(name string, pos position) {
+	s.bindings = append(s.bindings, &binding{name: name, pos: pos})
+}
+
+func (s *scope) lookup(name string) *binding {
+	for ; s != nil; s = s.parent {
+		for i := len(s.bindings) - 1; i >= 0; i-- {
+			if s.bindings[i].name == name {
+				return s.bindings[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *scope) isDynamic() bool {
+	for ; s != nil; s = s.parent {
+		if s.dynamic {
+			return true
+		}
+	}
+
+	return false
+}
+
+type position struct {
+	file      string
+	line, col int
+}
+
+func warn(pos position, format string, args ...interface{}) Warning {
+	return Warning{
+		File:    pos.file,
+		Line:    pos.line,
+		Col:     pos.col,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// node is a form and, if the form is a collection, its items.  Forms do not record
+// their positions, so the checker walks nodes, which carry the position of each
+// symbol.
+type node struct {
+	ww.Any
+	pos   position
+	items []*node // nil unless the form is a vector or seq
+}
+
+// newNode returns the tree of nodes for f.  Symbols are matched, in order, with the
+// positions of the symbols of the same name that were read.  Other forms, and symbols
+// that were not read, e.g. those produced by a data reader, have the position of f.
+func newNode(f SourceForm) *node {
+	top := position{file: f.File, line: f.Line, col: f.Col}
+	syms := f.syms
+
+	var build func(ww.Any) *node
+	build = func(any ww.Any) *node {
+		n := &node{Any: any, pos: top}
+
+		switch v := any.(type) {
+		case core.Symbol:
+			name, _ := v.Symbol()
+			for i, sym := range syms {
+				if sym.name == name {
+					n.pos.line, n.pos.col = sym.line, sym.col
+					syms = syms[i+1:]
+					break
+				}
+			}
+
+		case core.Vector:
+			n.items = nodes(vectorItems(v), build)
+
+		case core.Seq:
+			items, _ := core.ToSlice(v)
+			n.items = nodes(items, build)
+		}
+
+		return n
+	}
+
+	return build(f.Form.(ww.Any))
+}
+
+func nodes(forms []ww.Any, build func(ww.Any) *node) []*node {
+	ns := make([]*node, len(forms))
+	for i, f := range forms {
+		ns[i] = build(f)
+	}
+
+	return ns
+}
+
+// forms returns the forms of the nodes.
+func forms(ns []*node) []ww.Any {
+	fs := make([]ww.Any, len(ns))
+	for i, n := range ns {
+		fs[i] = n.Any
+	}
+
+	return fs
+}
+
+// close reports the unused bindings in s.
+func (c *checker) close(s *scope) {
+	for _, b := range s.bindings {
+		if !b.used && !b.self && !strings.HasPrefix(b.name, "_") {
+			c.unused = append(c.unused, warn(b.pos, "unused binding '%s'", b.name))
+		}
+	}
+}
+
+func (c *checker) ref(s *scope, name string, pos position) {
+	if b := s.lookup(name); b != nil {
+		b.used = true
+		return
+	}
+
+	if c.defs[name] || c.bound(name) {
+		return
+	}
+
+	if _, special := checkSpecial[name]; special {
+		return
+	}
+
+	c.undefined = append(c.undefined, undefinedRef{
+		Warning: warn(pos, "undefined symbol '%s'", name),
+		scope:   s,
+	})
+}
+
+// bound returns true if the name is bound in env or one of its parents.
+func (c *checker) bound(name string) bool {
+	_, ok := c.lookupEnv(name)
+	return ok
+}
+
+func (c *checker) lookupEnv(name string) (score.Any, bool) {
+	for env := c.env; env != nil; env = env.Parent() {
+		if v, err := env.Resolve(name); !errors.Is(err, core.ErrNotFound) {
+			return v, err == nil
+		}
+	}
+
+	return nil, false
+}
+
+// macro returns true if name refers to a macro, either in env or in the forms.
+func (c *checker) macro(s *scope, name string) bool {
+	if s.lookup(name) != nil {
+		return false
+	}
+
+	v, ok := c.lookupEnv(name)
+	if !ok {
+		return c.defs[name] && c.defs[macroDef(name)]
+	}
+
+	fn, ok := v.(core.Fn)
+	return ok && fn.Macro()
+}
+
+func (c *checker) form(s *scope, n *node) {
+	switch f := n.Any.(type) {
+	case core.Symbol:
+		if name, err := f.Symbol(); err == nil {
+			c.ref(s, name, n.pos)
+		}
+
+	case core.Vector:
+		c.forms(s, n.items)
+
+	case core.Seq:
+		c.seq(s, n)
+	}
+}
+
+func (c *checker) forms(s *scope, ns []*node) {
+	for _, n := range ns {
+		c.form(s, n)
+	}
+}
+
+func (c *checker) seq(s *scope, n *node) {
+	if len(n.items) == 0 {
+		return
+	}
+
+	head, args := n.items[0], n.items[1:]
+	name, err := symbolName(head.Any)
+	if err != nil {
+		c.form(s, head)
+		c.args(s, args)
+		return
+	}
+
+	if special, ok := checkSpecial[name]; ok {
+		special(c, s, args)
+		return
+	}
+
+	c.ref(s, name, head.pos)
+
+	if c.macro(s, name) {
+		// The expansion may bind any of the symbols in the arguments.  Record the
+		// references without reporting undefined ones.
+		ms := s.child()
+		ms.dynamic = true
+		c.forms(ms, args)
+		return
+	}
+
+	c.args(s, args)
+}
+
+// args checks the arguments to a call, including unpacked varargs (xs...).
+func (c *checker) args(s *scope, args []*node) {
+	for _, arg := range args {
+		if name, err := symbolName(arg.Any); err == nil && strings.HasSuffix(name, "...") {
+			if name != "..." {
+				c.ref(s, strings.TrimSuffix(name, "..."), arg.pos)
+			}
+			continue
+		}
+
+		c.form(s, arg)
+	}
+}
+
+// fn checks the (fn name? [params*] body*) and (fn name? ([params*] body*)+) forms.
+func (c *checker) fn(s *scope, args []*node) {
+	if len(args) == 0 {
+		return
+	}
+
+	fs := s.child()
+	if name, err := symbolName(args[0].Any); err == nil {
+		fs.bind(name, args[0].pos)
+		fs.bindings[0].self = true
+		args = args[1:]
+	}
+
+	if len(args) == 0 {
+		return
+	}
+
+	if _, ok := args[0].Any.(core.Vector); ok {
+		c.arity(fs, args[0], args[1:])
+	} else {
+		for _, arg := range args {
+			if _, ok := arg.Any.(core.Seq); ok && len(arg.items) > 0 {
+				if _, ok := arg.items[0].Any.(core.Vector); ok {
+					c.arity(fs, arg.items[0], arg.items[1:])
+				}
+			}
+		}
+	}
+
+	c.close(fs)
+}
+
+func (c *checker) arity(s *scope, params *node, body []*node) {
+	as := s.child()
+	for _, p := range params.items {
+		if name, err := symbolName(p.Any); err == nil {
+			as.bind(strings.TrimSuffix(name, "..."), p.pos)
+		}
+	}
+
+	c.forms(as, body)
+	c.close(as)
+}
+
+// define records the names defined by the form and its subforms.
+func (c *checker) define(any ww.Any) {
+	switch f := any.(type) {
+	case core.Vector:
+		for _, item := range vectorItems(f) {
+			c.define(item)
+		}
+
+	case core.Seq:
+		items, err := core.ToSlice(f)
+		if err != nil || len(items) == 0 {
+			return
+		}
+
+		switch head, _ := symbolName(items[0]); head {
+		case "quote", "syntax-quote", "comment":
+			return
+
+		case "def", "defn":
+			if len(items) > 1 {
+				if name, err := symbolName(items[1]); err == nil {
+					c.defs[name] = true
+					if head == "def" && len(items) > 2 && isMacroForm(items[2]) {
+						c.defs[macroDef(name)] = true
+					}
+				}
+			}
+
+		case "defprotocol":
+			c.defineProtocol(items[1:])
+		}
+
+		for _, item := range items[1:] {
+			c.define(item)
+		}
+	}
+}
+
+func (c *checker) defineProtocol(args []ww.Any) {
+	if len(args) == 0 {
+		return
+	}
+
+	if name, err := symbolName(args[0]); err == nil {
+		c.defs[name] = true
+	}
+
+	for _, arg := range args[1:] {
+		if seq, ok := arg.(core.Seq); ok {
+			if first, err := seq.First(); err == nil {
+				if m, err := symbolName(first); err == nil {
+					c.defs[m] = true
+				}
+			}
+		}
+	}
+}
+
+// macroDef is the key under which defs records that name is bound to a macro.  It is
+// not a valid symbol, so it cannot collide with a definition.
+func macroDef(name string) string { return "(macro " + name + ")" }
+
+func isMacroForm(any ww.Any) bool {
+	if seq, ok := any.(core.Seq); ok {
+		if first, err := seq.First(); err == nil {
+			name, _ := symbolName(first)
+			return name == "macro"
+		}
+	}
+
+	return false
+}
+
+func vectorItems(v core.Vector) []ww.Any {
+	cnt, err := v.Count()
+	if err != nil {
+		return nil
+	}
+
+	items := make([]ww.Any, 0, cnt)
+	for i := 0; i < cnt; i++ {
+		item, err := v.EntryAt(i)
+		if err != nil {
+			break
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// checkSpecial describes the syntax of each special form to the checker.  Special
+// forms that are not listed are checked as calls.
+var checkSpecial map[string]func(*checker, *scope, []*node)
+
+func init() {
+	skip := func(*checker, *scope, []*node) {}
+	dynamic := func(c *checker, s *scope, args []*node) {
+		s.dynamic = true
+		c.forms(s, args)
+	}
+
+	checkSpecial = map[string]func(*checker, *scope, []*node){
+		"quote":        skip,
+		"syntax-quote": skip,
+		"comment":      skip,
+		"defprotocol":  skip,
+		"ls":           skip,
+		"eval":         dynamic,
+		"import":       dynamic,
+		"do":           (*checker).forms,
+		"if":           (*checker).forms,
+		"when":         (*checker).forms,
+		"when-not":     (*checker).forms,
+		"select":       (*checker).forms,
+		"delay":        (*checker).forms,
+		"fn":           (*checker).fn,
+		"macro":        (*checker).fn,
+		"meta":         (*checker).forms,
+
+		"def": func(c *checker, s *scope, args []*node) {
+			if len(args) > 1 {
+				c.forms(s, args[1:])
+			}
+		},
+
+		"defn": func(c *checker, s *scope, args []*node) {
+			if len(args) > 2 && args[1].Value().Which() == mem.Any_Which_str {
+				args = append(args[:1:1], args[2:]...)
+			}
+
+			c.fn(s, args)
+		},
+
+		"if-let": func(c *checker, s *scope, args []*node) {
+			if len(args) < 2 {
+				return
+			}
+
+			if _, ok := args[0].Any.(core.Vector); !ok {
+				return
+			}
+
+			items := args[0].items
+			if len(items) != 2 {
+				return
+			}
+
+			c.form(s, items[1])
+
+			then := s.child()
+			if name, err := symbolName(items[0].Any); err == nil {
+				then.bind(name, items[0].pos)
+			}
+			c.form(then, args[1])
+			c.close(then)
+
+			c.forms(s, args[2:])
+		},
+
+		"extend-type": func(c *checker, s *scope, args []*node) {
+			if len(args) == 0 {
+				return
+			}
+
+			for _, arg := range args[1:] {
+				switch arg.Any.(type) {
+				case core.Symbol:
+					c.form(s, arg)
+
+				case core.Seq:
+					c.fn(s, arg.items)
+				}
+			}
+		},
+
+		// (go /path form*) sends the forms to the remote anchor, where they are
+		// resolved against its environment.
+		"go": func(c *checker, s *scope, args []*node) {
+			if _, ok := procArgs(forms(args)).Remote(); !ok {
+				c.forms(s, args)
+			}
+		},
+	}
+}
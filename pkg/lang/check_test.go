@@ -0,0 +1,105 @@
+package lang_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wetware/ww/pkg/lang"
+)
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	vm, err := lang.New(nil)
+	require.NoError(t, err)
+
+	check := func(t *testing.T, src string) []string {
+		forms, err := lang.ReadSource("test.ww", strings.NewReader(src))
+		require.NoError(t, err)
+
+		var ws []string
+		for _, w := range lang.Check(vm.Env(), forms) {
+			ws = append(ws, w.String())
+		}
+		return ws
+	}
+
+	for _, tt := range []struct {
+		desc, src string
+		want      []string
+	}{
+		{
+			desc: "Clean",
+			src:  `(def id (fn id [x] x)) (id (count [1 2]))`,
+		},
+		{
+			desc: "Undefined",
+			src:  "(def x 1)\n\n  (conj [x] y)",
+			want: []string{"test.ww:3:13: warning: undefined symbol 'y'"},
+		},
+		{
+			desc: "UndefinedInBody",
+			src:  `(defn f [x] (cnt x))`,
+			want: []string{"test.ww:1:14: warning: undefined symbol 'cnt'"},
+		},
+		{
+			desc: "ForwardDef",
+			src:  `(defn f [] (g)) (defn g [] :g)`,
+		},
+		{
+			desc: "Unused",
+			src:  `(defn f "doc" [x y _z] x) (if-let [v (f 1 2 3)] :ok)`,
+			want: []string{
+				"test.ww:1:18: warning: unused binding 'y'",
+				"test.ww:1:36: warning: unused binding 'v'",
+			},
+		},
+		{
+			desc: "MultiArity",
+			src:  `(fn f ([] (f 1)) ([x] x) ([x ys...] (conj ys... x)))`,
+		},
+		{
+			desc: "Quote",
+			src:  `(quote (undefined)) '(also undefined) (comment (nope))`,
+		},
+		{
+			desc: "Eval",
+			src:  `(defn f [] (eval (read)) (dynamic))`,
+		},
+		{
+			desc: "EvalScope",
+			src:  `(defn f [] (eval (read))) (defn g [] (undefined))`,
+			want: []string{"test.ww:1:39: warning: undefined symbol 'undefined'"},
+		},
+		{
+			desc: "Macro",
+			src:  `(def m (macro m [x] x)) (m (whatever))`,
+		},
+		{
+			desc: "Protocol",
+			src: `(defprotocol Named (name [x]))
+			      (extend-type str Named (name [s] s))
+			      (name "x")`,
+		},
+		{
+			desc: "Position",
+			src:  "(defn f [x]\n  (g x '(quoted) (g\n\t\t\tx)))\n(defn h [] (f #_ z (g)))",
+			want: []string{
+				"test.ww:2:4: warning: undefined symbol 'g'",
+				"test.ww:2:19: warning: undefined symbol 'g'",
+				"test.ww:4:21: warning: undefined symbol 'g'",
+			},
+		},
+		{
+			desc: "Remote",
+			src:  `(go /foo (remote-only))`,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert.Equal(t, tt.want, check(t, tt.src))
+		})
+	}
+}
@@ -2,8 +2,12 @@ package core
 
 import (
 	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
 	memutil "github.com/wetware/ww/pkg/util/mem"
 	capnp "zombiezen.com/go/capnproto2"
 )
@@ -82,7 +86,49 @@ func (c Char) Value() mem.Any { return c.Any }
 // Char returns the character as a native rune.
 func (c Char) Char() rune { return c.Value().Char() }
 
-func (c Char) String() string { return fmt.Sprintf("\\%c", c.Char()) }
+// String renders the character as a parseable literal.
+func (c Char) String() string {
+	r := c.Char()
+	if name, ok := charNames[r]; ok {
+		return "\\" + name
+	}
+
+	if !unicode.IsPrint(r) && r <= 0xffff {
+		return fmt.Sprintf("\\u%04x", r)
+	}
+
+	return "\\" + string(r)
+}
+
+// Comp compares the character with another character by code point.
+func (c Char) Comp(other ww.Any) (int, error) {
+	if val := other.Value(); val.Which() == mem.Any_Which_char {
+		return compI64(int64(c.Char()), int64(val.Char())), nil
+	}
+
+	return 0, ErrIncomparableTypes
+}
+
+// charNames are the named character literals, which the reader also accepts.
+var charNames = map[rune]string{
+	'\t': "tab",
+	' ':  "space",
+	'\n': "newline",
+	'\r': "return",
+	'\b': "backspace",
+	'\f': "formfeed",
+}
+
+// CharNamed returns the character with the given literal name, e.g. "newline".
+func CharNamed(name string) (rune, bool) {
+	for r, n := range charNames {
+		if n == name {
+			return r, true
+		}
+	}
+
+	return 0, false
+}
 
 // String represents text. Escape sequences are not applicable at this level.
 type String struct{ mem.Any }
@@ -109,19 +155,81 @@ func (str String) String() (s string, err error) {
 }
 
 // Render the string into a parseable s-expression.
-func (str String) Render() (string, error) { return str.String() }
+func (str String) Render() (string, error) {
+	s, err := str.Value().Str()
+	return quoteString(s), err
+}
+
+// quoteString returns s as a string literal.  Quotes, backslashes and non-printable
+// characters are escaped, so that reading the literal yields s.  Invalid UTF-8 is
+// copied verbatim.
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		switch {
+		case r == utf8.RuneError && size == 1:
+			b.WriteByte(s[0])
+		case r == '"':
+			b.WriteString(`\"`)
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x80 && !unicode.IsPrint(r):
+			fmt.Fprintf(&b, `\x%02x`, r)
+		case r <= 0xffff && !unicode.IsPrint(r):
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+		s = s[size:]
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
 
 // Count returns the number of characters in the string.
 func (str String) Count() (int, error) {
 	s, err := str.Value().Str()
-	return len(s), err
+	return utf8.RuneCountInString(s), err
+}
+
+// EntryAt returns the i'th character of the string.
+func (str String) EntryAt(i int) (ww.Any, error) {
+	s, err := str.Value().Str()
+	if err != nil {
+		return nil, err
+	}
+
+	rs := []rune(s)
+	if i < 0 || i >= len(rs) {
+		return nil, Error{
+			Cause:   ErrIndexOutOfBounds,
+			Message: fmt.Sprintf("index %d, count %d", i, len(rs)),
+		}
+	}
+
+	return NewChar(capnp.SingleSegment(nil), rs[i])
 }
 
 // Keyword represents a keyword literal.
 type Keyword struct{ mem.Any }
 
-// NewKeyword using the built-in implementation
+// NewKeyword using the built-in implementation.  Short keywords are interned, in
+// which case the arena is not used.
 func NewKeyword(a capnp.Arena, s string) (Keyword, error) {
+	if len(s) <= internMax {
+		any, err := keywords.Get(s, setKeyword)
+		return Keyword{any}, err
+	}
+
 	any, err := memutil.Alloc(a)
 	if err == nil {
 		err = any.SetKeyword(s)
@@ -149,8 +257,14 @@ func (kw Keyword) Render() (string, error) {
 // Symbol represents a name given to a value in memory.
 type Symbol struct{ mem.Any }
 
-// NewSymbol .
+// NewSymbol using the built-in implementation.  Short symbols are interned, in which
+// case the arena is not used.
 func NewSymbol(a capnp.Arena, s string) (Symbol, error) {
+	if len(s) <= internMax {
+		any, err := symbols.Get(s, setSymbol)
+		return Symbol{any}, err
+	}
+
 	any, err := memutil.Alloc(a)
 	if err == nil {
 		err = any.SetSymbol(s)
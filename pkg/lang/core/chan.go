@@ -0,0 +1,275 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	memutil "github.com/wetware/ww/pkg/util/mem"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+var (
+	// ErrChanClosed is returned when sending to a closed channel, or when closing a
+	// channel more than once.
+	ErrChanClosed = errors.New("channel closed")
+
+	// ErrChanNotStorable is returned when storing a channel in an anchor.
+	ErrChanNotStorable = errors.New("channels cannot be stored in anchors")
+)
+
+// Chan is a buffered channel of values, used to communicate between local processes.
+// Channels are local to the interpreter that created them.  Their value is a
+// capability that is only meaningful within the local process, so channels survive
+// storage in collections, but cannot be stored in anchors.  See HasChan.
+type Chan struct {
+	mem.Any
+	*channel
+}
+
+type channel struct {
+	once   sync.Once
+	ch     chan ww.Any
+	closed chan struct{}
+}
+
+// NewChan returns a channel with a buffer of size n.
+func NewChan(n int) (Chan, error) {
+	if n < 0 {
+		return Chan{}, fmt.Errorf("%w: negative buffer size %d", ErrIllegalState, n)
+	}
+
+	any, err := memutil.Alloc(capnp.SingleSegment(nil))
+	if err != nil {
+		return Chan{}, err
+	}
+
+	ch := &channel{
+		ch:     make(chan ww.Any, n),
+		closed: make(chan struct{}),
+	}
+
+	if err = any.SetProc(mem.Proc_ServerToClient(chanServer{ch}, nil)); err != nil {
+		return Chan{}, err
+	}
+
+	return Chan{Any: any, channel: ch}, nil
+}
+
+// Value returns the memory value.
+func (ch Chan) Value() mem.Any { return ch.Any }
+
+// HasChan reports whether v is a channel, or a collection that holds one at any
+// depth.  Such values cannot be stored in anchors.
+func HasChan(v ww.Any) (bool, error) {
+	switch v.(type) {
+	case Chan:
+		return true, nil
+	}
+
+	switch v.Value().Which() {
+	case mem.Any_Which_list, mem.Any_Which_vector:
+		vs, err := items(v)
+		if err != nil {
+			return false, err
+		}
+
+		for _, item := range vs {
+			if ok, err := HasChan(item); err != nil || ok {
+				return ok, err
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// Render a human-readable representation of the channel.
+func (ch Chan) Render() (string, error) {
+	return fmt.Sprintf("<chan %d/%d>", len(ch.ch), cap(ch.ch)), nil
+}
+
+// Eq returns true if other refers to the same channel.
+func (ch Chan) Eq(other ww.Any) (bool, error) {
+	o, ok := other.(Chan)
+	return ok && o.channel == ch.channel, nil
+}
+
+// Hash returns a hash of the channel's identity.
+func (ch Chan) Hash() (uint64, error) {
+	return uint64(reflect.ValueOf(ch.channel).Pointer()), nil
+}
+
+// Send a value, blocking until there is room in the buffer, the channel is closed,
+// or the context expires.
+func (ch Chan) Send(ctx context.Context, v ww.Any) error {
+	// Check for closure first; select is non-deterministic.
+	if ch.isClosed() {
+		return Error{Cause: ErrChanClosed, Message: "send"}
+	}
+
+	select {
+	case ch.ch <- v:
+		return nil
+	case <-ch.closed:
+		return Error{Cause: ErrChanClosed, Message: "send"}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SendError sends err in place of a value, so that the receiver gets it from Recv or
+// Select.  The channel remains open.  SendError blocks like Send.
+func (ch Chan) SendError(ctx context.Context, err error) error {
+	return ch.Send(ctx, chanError{err})
+}
+
+// Recv a value, blocking until one is available or the context expires.  Once the
+// channel is closed and drained, Recv returns (nil, false, nil).  If an error was
+// sent in place of the value, Recv returns (nil, true, err).
+func (ch Chan) Recv(ctx context.Context) (ww.Any, bool, error) {
+	select {
+	case v := <-ch.ch:
+		return received(v, true)
+	case <-ch.closed:
+		return received(ch.drain())
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+// Close the channel.  Buffered values can still be received.
+func (ch Chan) Close() (err error) {
+	err = Error{Cause: ErrChanClosed, Message: "close"}
+	ch.once.Do(func() {
+		close(ch.closed)
+		err = nil
+	})
+	return
+}
+
+func (ch *channel) isClosed() bool {
+	select {
+	case <-ch.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (ch *channel) drain() (ww.Any, bool) {
+	select {
+	case v := <-ch.ch:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
+// ChanOp is a pending send or receive operation, for use with Select.
+type ChanOp struct {
+	Chan Chan
+
+	// Send is true if the operation is a send.
+	Send  bool
+	Value ww.Any
+}
+
+// Select blocks until one of the operations can proceed, and performs it.  It returns
+// the index of the chosen operation and, for receives, the value received.  The
+// value is nil if a receive was performed on a closed, drained channel.  An error sent
+// in place of the value is returned along with the index of the receive.
+func Select(ctx context.Context, ops ...ChanOp) (int, ww.Any, error) {
+	// cases[0] is the context; each op contributes a data case and a closed case.
+	cases := make([]reflect.SelectCase, 1, 2*len(ops)+1)
+	cases[0] = reflect.SelectCase{
+		Dir:  reflect.SelectRecv,
+		Chan: reflect.ValueOf(ctx.Done()),
+	}
+
+	// A closed channel with room in its buffer is ready for both its data case and
+	// its closed case, so check for closure first; select is non-deterministic.
+	for i, op := range ops {
+		if op.Send && op.Chan.isClosed() {
+			return i, nil, Error{Cause: ErrChanClosed, Message: "send"}
+		}
+	}
+
+	for _, op := range ops {
+		data := reflect.SelectCase{
+			Dir:  reflect.SelectRecv,
+			Chan: reflect.ValueOf(op.Chan.ch),
+		}
+		if op.Send {
+			v := op.Value
+			if v == nil {
+				v = Nil{}
+			}
+
+			data.Dir = reflect.SelectSend
+			data.Send = reflect.ValueOf(v)
+		}
+
+		cases = append(cases, data, reflect.SelectCase{
+			Dir:  reflect.SelectRecv,
+			Chan: reflect.ValueOf(op.Chan.closed),
+		})
+	}
+
+	chosen, recv, ok := reflect.Select(cases)
+	if chosen == 0 {
+		return -1, nil, ctx.Err()
+	}
+
+	i := (chosen - 1) / 2
+	op := ops[i]
+
+	// closed case
+	if (chosen-1)%2 == 1 {
+		if op.Send {
+			return i, nil, Error{Cause: ErrChanClosed, Message: "send"}
+		}
+
+		v, _, err := received(op.Chan.drain())
+		return i, v, err
+	}
+
+	if op.Send || !ok {
+		return i, nil, nil
+	}
+
+	v, _, err := received(recv.Interface().(ww.Any), true)
+	return i, v, err
+}
+
+// chanError is sent in place of a value by SendError.
+type chanError struct{ error }
+
+func (chanError) Value() mem.Any { return mem.Any{} }
+
+// received unwraps errors that were sent in place of a value.
+func received(v ww.Any, ok bool) (ww.Any, bool, error) {
+	if err, isErr := v.(chanError); isErr {
+		return nil, ok, err.error
+	}
+
+	return v, ok, nil
+}
+
+// chanServer brands the capability that represents a channel, so that the channel
+// can be recovered from its memory value.  Waiting on it blocks until the channel is
+// closed.
+type chanServer struct{ *channel }
+
+func (ch chanServer) Wait(ctx context.Context, _ mem.Proc_wait) error {
+	select {
+	case <-ch.closed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,156 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wetware/ww/pkg/lang/core"
+)
+
+func TestChan(t *testing.T) {
+	t.Parallel()
+
+	t.Run("SendRecv", func(t *testing.T) {
+		t.Parallel()
+
+		ch, err := core.NewChan(1)
+		require.NoError(t, err)
+
+		require.NoError(t, ch.Send(context.Background(), mustKeyword("foo")))
+
+		v, ok, err := ch.Recv(context.Background())
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, ":foo", mustRender(v))
+	})
+
+	t.Run("SendError", func(t *testing.T) {
+		t.Parallel()
+
+		ch, err := core.NewChan(3)
+		require.NoError(t, err)
+
+		boom := errors.New("boom")
+		require.NoError(t, ch.SendError(context.Background(), boom))
+		require.NoError(t, ch.Send(context.Background(), core.True))
+		require.NoError(t, ch.SendError(context.Background(), boom))
+
+		_, ok, err := ch.Recv(context.Background())
+		assert.True(t, ok)
+		assert.Equal(t, boom, err)
+
+		// the channel remains usable
+		v, ok, err := ch.Recv(context.Background())
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, core.True, v)
+
+		i, v, err := core.Select(context.Background(), core.ChanOp{Chan: ch})
+		assert.Equal(t, 0, i)
+		assert.Nil(t, v)
+		assert.Equal(t, boom, err)
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		t.Parallel()
+
+		ch, err := core.NewChan(1)
+		require.NoError(t, err)
+
+		require.NoError(t, ch.Send(context.Background(), core.True))
+		require.NoError(t, ch.Close())
+
+		err = ch.Send(context.Background(), core.True)
+		assert.True(t, errors.Is(err, core.ErrChanClosed), "unexpected error %v", err)
+
+		err = ch.Close()
+		assert.True(t, errors.Is(err, core.ErrChanClosed), "unexpected error %v", err)
+
+		// buffered values are still delivered after close
+		v, ok, err := ch.Recv(context.Background())
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, core.True, v)
+
+		_, ok, err = ch.Recv(context.Background())
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("RecvContext", func(t *testing.T) {
+		t.Parallel()
+
+		ch, err := core.NewChan(0)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+
+		_, _, err = ch.Recv(ctx)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error %v", err)
+	})
+
+	t.Run("Select", func(t *testing.T) {
+		t.Parallel()
+
+		idle, err := core.NewChan(0)
+		require.NoError(t, err)
+
+		ready, err := core.NewChan(1)
+		require.NoError(t, err)
+		require.NoError(t, ready.Send(context.Background(), core.False))
+
+		i, v, err := core.Select(context.Background(),
+			core.ChanOp{Chan: idle},
+			core.ChanOp{Chan: ready})
+		require.NoError(t, err)
+		assert.Equal(t, 1, i)
+		assert.Equal(t, core.False, v)
+
+		i, _, err = core.Select(context.Background(),
+			core.ChanOp{Chan: idle},
+			core.ChanOp{Chan: ready, Send: true, Value: core.True})
+		require.NoError(t, err)
+		assert.Equal(t, 1, i)
+
+		require.NoError(t, idle.Close())
+		i, _, err = core.Select(context.Background(),
+			core.ChanOp{Chan: idle, Send: true, Value: core.True})
+		assert.Equal(t, 0, i)
+		assert.True(t, errors.Is(err, core.ErrChanClosed), "unexpected error %v", err)
+	})
+
+	t.Run("SelectClosedBuffered", func(t *testing.T) {
+		t.Parallel()
+
+		ch, err := core.NewChan(1)
+		require.NoError(t, err)
+		require.NoError(t, ch.Close())
+
+		// the send case is ready too, since the buffer has room
+		for i := 0; i < 100; i++ {
+			_, _, err = core.Select(context.Background(),
+				core.ChanOp{Chan: ch, Send: true, Value: core.True})
+			require.True(t, errors.Is(err, core.ErrChanClosed), "unexpected error %v", err)
+		}
+	})
+
+	t.Run("Collection", func(t *testing.T) {
+		t.Parallel()
+
+		ch, err := core.NewChan(0)
+		require.NoError(t, err)
+
+		item, err := mustVector(ch).EntryAt(0)
+		require.NoError(t, err)
+		require.IsType(t, core.Chan{}, item)
+
+		eq, err := core.Eq(ch, item)
+		require.NoError(t, err)
+		assert.True(t, eq, "channel changed identity")
+	})
+}
@@ -0,0 +1,355 @@
+// Package codec converts values to and from other data formats.
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	capnp "zombiezen.com/go/capnproto2"
+
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+)
+
+// tagPrefix begins the key of a tagged value, e.g. {"~keyword": "foo"}.
+const tagPrefix = "~"
+
+// JSON converts values to and from JSON documents.
+//
+// Vectors and lists become arrays, strings, booleans and nil become their JSON
+// counterparts, and numbers become JSON numbers, written exactly, including big
+// integers and big floats.  Other values are written as strings:  keywords, symbols
+// and paths as their names, chars as one-character strings, and fractions as "n/d".
+// Thus, keywords become strings, lists become vectors, and integral floats become
+// integers when the document is read back.  Integers that do not fit in 64 bits are
+// read as big integers.  JSON cannot hold NaN or infinite numbers at all, and since
+// there is no map type, JSON objects cannot be read.
+//
+// If Tagged is set, values that would not survive the round trip are written as
+// objects with a single key, which names the value's type, e.g. {"~keyword": "foo"},
+// {"~list": [1, 2]} or {"~f64": "NaN"}.  Tagged documents round-trip losslessly.
+type JSON struct {
+	Tagged bool
+	Indent string // indentation of nested elements; if empty, the output is compact
+}
+
+// ToJSON writes v to w as a JSON document, followed by a newline.
+func ToJSON(v ww.Any, w io.Writer) error { return JSON{}.Encode(w, v) }
+
+// FromJSON reads one JSON document from r, and allocates its value in a.
+func FromJSON(r io.Reader, a capnp.Arena) (ww.Any, error) { return JSON{}.Decode(r, a) }
+
+// Encode writes v to w as a JSON document, followed by a newline.
+func (j JSON) Encode(w io.Writer, v ww.Any) error {
+	x, err := j.project(v)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", j.Indent)
+	return enc.Encode(x)
+}
+
+// Decode reads one JSON document from r, and allocates its value in a.  It fails if r
+// holds more than one document.
+func (j JSON) Decode(r io.Reader, a capnp.Arena) (ww.Any, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var x interface{}
+	if err := dec.Decode(&x); err != nil {
+		return nil, err
+	}
+
+	if dec.More() {
+		return nil, fmt.Errorf("expected one JSON value")
+	}
+
+	return j.unproject(a, x)
+}
+
+// project v onto the types supported by encoding/json.
+func (j JSON) project(v ww.Any) (interface{}, error) {
+	if core.IsNil(v) {
+		return nil, nil
+	}
+
+	switch which := v.Value().Which(); which {
+	case mem.Any_Which_bool:
+		return v.Value().Bool(), nil
+
+	case mem.Any_Which_i64:
+		return v.Value().I64(), nil
+
+	case mem.Any_Which_bigInt:
+		n := json.Number(v.(core.BigInt).BigInt().String())
+		return j.tag(which, n.String(), n), nil
+
+	case mem.Any_Which_f64:
+		return j.projectFloat(v.Value().F64())
+
+	case mem.Any_Which_bigFloat:
+		f := v.(core.BigFloat).BigFloat()
+		if f.IsInf() {
+			return j.nonFinite(which, f.Text('g', -1))
+		}
+
+		n := json.Number(f.Text('g', -1))
+		return j.tag(which, n.String(), n), nil
+
+	case mem.Any_Which_frac:
+		s := v.(core.Fraction).Rat().String()
+		return j.tag(which, s, s), nil
+
+	case mem.Any_Which_str:
+		return v.Value().Str()
+
+	case mem.Any_Which_char:
+		s := string(v.Value().Char())
+		return j.tag(which, s, s), nil
+
+	case mem.Any_Which_keyword:
+		s, err := v.Value().Keyword()
+		return j.tag(which, s, s), err
+
+	case mem.Any_Which_symbol:
+		s, err := v.Value().Symbol()
+		return j.tag(which, s, s), err
+
+	case mem.Any_Which_path:
+		s, err := v.Value().Path()
+		return j.tag(which, s, s), err
+
+	case mem.Any_Which_vector, mem.Any_Which_list:
+		xs, err := j.projectSeq(v)
+		if which == mem.Any_Which_list {
+			return j.tag(which, xs, xs), err
+		}
+
+		return xs, err
+	}
+
+	// other sequences, e.g. over a vector, are written as arrays
+	if seq, ok := v.(core.Seq); ok {
+		return j.projectSeq(seq)
+	}
+
+	if !j.Tagged {
+		if s, err := core.Render(v); err == nil {
+			return s, nil
+		}
+	}
+
+	return nil, fmt.Errorf("cannot represent %s as JSON", v.Value().Which())
+}
+
+func (j JSON) projectSeq(v ww.Any) ([]interface{}, error) {
+	seq, err := core.ToSeq(v)
+	if err != nil {
+		return nil, err
+	}
+
+	xs := []interface{}{}
+	return xs, core.ForEach(seq, func(item ww.Any) (bool, error) {
+		x, err := j.project(item)
+		xs = append(xs, x)
+		return false, err
+	})
+}
+
+func (j JSON) projectFloat(f float64) (interface{}, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return j.nonFinite(mem.Any_Which_f64, strconv.FormatFloat(f, 'g', -1, 64))
+	}
+
+	// integral floats would be read back as integers
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if strings.ContainsAny(s, ".e") {
+		return f, nil
+	}
+
+	return j.tag(mem.Any_Which_f64, json.Number(s), f), nil
+}
+
+func (j JSON) nonFinite(which mem.Any_Which, s string) (interface{}, error) {
+	if !j.Tagged {
+		return nil, fmt.Errorf("cannot represent %s as JSON", s)
+	}
+
+	return j.tag(which, s, nil), nil
+}
+
+// tag returns a tagged value if j is tagged, and untagged otherwise.
+func (j JSON) tag(which mem.Any_Which, tagged, untagged interface{}) interface{} {
+	if !j.Tagged {
+		return untagged
+	}
+
+	return map[string]interface{}{tagPrefix + which.String(): tagged}
+}
+
+// unproject a decoded JSON document onto core values.  The value is allocated in a,
+// which backs a single message, so nested values are allocated in arenas of their own.
+func (j JSON) unproject(a capnp.Arena, x interface{}) (ww.Any, error) {
+	switch v := x.(type) {
+	case nil:
+		return core.Nil{}, nil
+
+	case bool:
+		return core.NewBool(a, v)
+
+	case string:
+		return core.NewString(a, v)
+
+	case json.Number:
+		return number(a, v.String())
+
+	case []interface{}:
+		items, err := j.unprojectAll(v)
+		if err != nil {
+			return nil, err
+		}
+
+		return core.NewVector(a, items...)
+
+	case map[string]interface{}:
+		if j.Tagged && len(v) == 1 {
+			for key, val := range v {
+				if strings.HasPrefix(key, tagPrefix) {
+					return j.untag(a, strings.TrimPrefix(key, tagPrefix), val)
+				}
+			}
+		}
+
+		return nil, fmt.Errorf("cannot represent JSON objects as values")
+	}
+
+	return nil, fmt.Errorf("cannot represent JSON %T as a value", x)
+}
+
+func (j JSON) unprojectAll(xs []interface{}) ([]ww.Any, error) {
+	items := make([]ww.Any, len(xs))
+	for i, x := range xs {
+		var err error
+		if items[i], err = j.unproject(capnp.SingleSegment(nil), x); err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
+
+// untag a tagged value.
+func (j JSON) untag(a capnp.Arena, tag string, x interface{}) (ww.Any, error) {
+	if tag == mem.Any_Which_list.String() {
+		xs, ok := x.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("~%s: expected array, got %T", tag, x)
+		}
+
+		items, err := j.unprojectAll(xs)
+		if err != nil {
+			return nil, err
+		}
+
+		return core.NewList(a, items...)
+	}
+
+	var s string
+	switch v := x.(type) {
+	case string:
+		s = v
+	case json.Number:
+		s = v.String()
+	default:
+		return nil, fmt.Errorf("~%s: expected string or number, got %T", tag, x)
+	}
+
+	switch tag {
+	case mem.Any_Which_bigInt.String():
+		var i big.Int
+		if _, ok := i.SetString(s, 10); !ok {
+			return nil, fmt.Errorf("~%s: invalid integer '%s'", tag, s)
+		}
+
+		return core.NewBigInt(a, &i)
+
+	case mem.Any_Which_f64.String():
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("~%s: %w", tag, err)
+		}
+
+		return core.NewFloat64(a, f)
+
+	case mem.Any_Which_bigFloat.String():
+		f, _, err := big.ParseFloat(s, 10, 0, big.ToNearestEven)
+		if err != nil {
+			return nil, fmt.Errorf("~%s: %w", tag, err)
+		}
+
+		return core.NewBigFloat(a, f)
+
+	case mem.Any_Which_frac.String():
+		var r big.Rat
+		if _, ok := r.SetString(s); !ok {
+			return nil, fmt.Errorf("~%s: invalid fraction '%s'", tag, s)
+		}
+
+		return core.NewFraction(a, &r)
+
+	case mem.Any_Which_char.String():
+		rs := []rune(s)
+		if len(rs) != 1 {
+			return nil, fmt.Errorf("~%s: expected one character, got %d", tag, len(rs))
+		}
+
+		return core.NewChar(a, rs[0])
+
+	case mem.Any_Which_keyword.String():
+		return core.NewKeyword(a, s)
+
+	case mem.Any_Which_symbol.String():
+		return core.NewSymbol(a, s)
+
+	case mem.Any_Which_path.String():
+		return core.NewPath(a, s)
+	}
+
+	return nil, fmt.Errorf("unknown type tag '~%s'", tag)
+}
+
+// number returns an Int64 if s is an integer that fits in 64 bits, a BigInt if it is a
+// larger integer, and a Float64 otherwise.  Floats that overflow 64 bits become
+// BigFloats.
+func number(a capnp.Arena, s string) (ww.Any, error) {
+	if !strings.ContainsAny(s, ".eE") {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return core.NewInt64(a, i)
+		}
+
+		var i big.Int
+		if _, ok := i.SetString(s, 10); ok {
+			return core.NewBigInt(a, &i)
+		}
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err == nil {
+		return core.NewFloat64(a, f)
+	}
+
+	bf, _, err := big.ParseFloat(s, 10, 0, big.ToNearestEven)
+	if err != nil {
+		return nil, err
+	}
+
+	return core.NewBigFloat(a, bf)
+}
@@ -0,0 +1,191 @@
+package codec_test
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	capnp "zombiezen.com/go/capnproto2"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	"github.com/wetware/ww/pkg/lang/core/codec"
+	"github.com/wetware/ww/pkg/lang/reader"
+)
+
+func TestToJSON(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{`nil`, `null`},
+		{`true`, `true`},
+		{`42`, `42`},
+		{`-1.5`, `-1.5`},
+		{`2.0`, `2`},
+		{`123456789012345678901234567890N`, `123456789012345678901234567890`},
+		{`1/2`, `"1/2"`},
+		{`"hello"`, `"hello"`},
+		{`\a`, `"a"`},
+		{`:kw`, `"kw"`},
+		{`sym`, `"sym"`},
+		{`[1 [:a "b"] []]`, `[1,["a","b"],[]]`},
+		{`(1 2)`, `[1,2]`},
+	} {
+		var buf bytes.Buffer
+		require.NoError(t, codec.ToJSON(mustRead(t, tt.src), &buf), tt.src)
+		assert.Equal(t, tt.want+"\n", buf.String(), tt.src)
+	}
+
+	t.Run("NaN", func(t *testing.T) {
+		t.Parallel()
+
+		nan, err := core.NewFloat64(capnp.SingleSegment(nil), math.NaN())
+		require.NoError(t, err)
+
+		err = codec.ToJSON(nan, &bytes.Buffer{})
+		assert.EqualError(t, err, "cannot represent NaN as JSON")
+	})
+}
+
+func TestFromJSON(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{`null`, `nil`},
+		{`false`, `false`},
+		{`42`, `42`},
+		{`-1.5e3`, `-1500.0`},
+		{`9223372036854775808`, `9223372036854775808N`},
+		{`"hi"`, `"hi"`},
+		{`[1, "a", [true, null]]`, `[1 "a" [true nil]]`},
+	} {
+		v, err := codec.FromJSON(strings.NewReader(tt.src), capnp.SingleSegment(nil))
+		require.NoError(t, err, tt.src)
+		assertSame(t, mustRead(t, tt.want), v)
+	}
+
+	for _, tt := range []struct {
+		src, err string
+	}{
+		{`{"a": 1}`, "cannot represent JSON objects as values"},
+		{`{"~keyword": "a"}`, "cannot represent JSON objects as values"},
+		{`1 2`, "expected one JSON value"},
+	} {
+		_, err := codec.FromJSON(strings.NewReader(tt.src), capnp.SingleSegment(nil))
+		assert.EqualError(t, err, tt.err, tt.src)
+	}
+}
+
+func TestTaggedJSON(t *testing.T) {
+	t.Parallel()
+
+	j := codec.JSON{Tagged: true}
+
+	nan, err := core.NewFloat64(capnp.SingleSegment(nil), math.NaN())
+	require.NoError(t, err)
+
+	inf, err := core.NewFloat64(capnp.SingleSegment(nil), math.Inf(-1))
+	require.NoError(t, err)
+
+	for _, v := range []ww.Any{
+		mustRead(t, `nil`),
+		mustRead(t, `42`),
+		mustRead(t, `2.0`),
+		mustRead(t, `0.1`),
+		mustRead(t, `5N`),
+		mustBigFloat(t, "1.5e400"),
+		mustRead(t, `-3/4`),
+		mustRead(t, `\λ`),
+		mustRead(t, `"str"`),
+		mustRead(t, `:kw`),
+		mustRead(t, `ns/sym`),
+		mustRead(t, `/a/b`),
+		mustRead(t, `[1 (:a (\b)) [] "c"]`),
+		inf,
+	} {
+		var buf bytes.Buffer
+		require.NoError(t, j.Encode(&buf, v))
+
+		got, err := j.Decode(&buf, capnp.SingleSegment(nil))
+		require.NoError(t, err, mustRender(t, v))
+		assertSame(t, v, got)
+	}
+
+	t.Run("Format", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		require.NoError(t, j.Encode(&buf, mustRead(t, `[:a (1) 2.0]`)))
+		assert.Equal(t, `[{"~keyword":"a"},{"~list":[1]},{"~f64":2}]`+"\n", buf.String())
+	})
+
+	t.Run("NaN", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		require.NoError(t, j.Encode(&buf, nan))
+		assert.Equal(t, `{"~f64":"NaN"}`+"\n", buf.String())
+
+		got, err := j.Decode(&buf, capnp.SingleSegment(nil))
+		require.NoError(t, err)
+		assert.True(t, math.IsNaN(got.(core.Float64).Float64()))
+	})
+
+	t.Run("UnknownTag", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := j.Decode(strings.NewReader(`{"~map": []}`), capnp.SingleSegment(nil))
+		assert.EqualError(t, err, "~map: expected string or number, got []interface {}")
+
+		_, err = j.Decode(strings.NewReader(`{"~map": "x"}`), capnp.SingleSegment(nil))
+		assert.EqualError(t, err, "unknown type tag '~map'")
+	})
+}
+
+func mustBigFloat(t *testing.T, s string) core.BigFloat {
+	t.Helper()
+
+	f, _, err := big.ParseFloat(s, 10, 0, big.ToNearestEven)
+	require.NoError(t, err)
+
+	v, err := core.NewBigFloat(capnp.SingleSegment(nil), f)
+	require.NoError(t, err)
+	return v
+}
+
+// assertSame asserts that want and got are equal values of the same types.
+func assertSame(t *testing.T, want, got ww.Any) {
+	t.Helper()
+
+	eq, err := core.Eq(want, got)
+	require.NoError(t, err)
+	assert.True(t, eq, "want %s, got %s", mustRender(t, want), mustRender(t, got))
+	assert.Equal(t, mustRender(t, want), mustRender(t, got))
+}
+
+func mustRead(t *testing.T, src string) ww.Any {
+	t.Helper()
+
+	rd, err := reader.New(strings.NewReader(src))
+	require.NoError(t, err)
+
+	form, err := rd.One()
+	require.NoError(t, err)
+	return form.(ww.Any)
+}
+
+func mustRender(t *testing.T, v ww.Any) string {
+	t.Helper()
+
+	s, err := core.Render(v)
+	require.NoError(t, err)
+	return s
+}
@@ -0,0 +1,191 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+var _ Seq = (*ConcatSeq)(nil)
+
+// ConcatSeq is a lazy concatenation of sequences.  Items are read from the underlying
+// sequences as the ConcatSeq is traversed, so concatenation is O(n) in the number of
+// sequences rather than in the number of items.
+//
+// Like SubVector, a ConcatSeq is materialized into a list the first time its memory
+// value is requested.
+type ConcatSeq struct {
+	seqs []Seq
+	lazy *lazyValue
+}
+
+// Concat returns a lazy sequence containing the items of each collection, in order.
+// Collections must be nil, a Seq, or Seqable.
+func Concat(colls ...ww.Any) (Seq, error) {
+	seqs := make([]Seq, 0, len(colls))
+	for _, coll := range colls {
+		seq, err := ToSeq(coll)
+		if err != nil {
+			return nil, err
+		}
+
+		if seq, err = nonEmpty(seq); err != nil {
+			return nil, err
+		}
+
+		if seq != nil {
+			seqs = append(seqs, seq)
+		}
+	}
+
+	return newConcatSeq(seqs), nil
+}
+
+func newConcatSeq(seqs []Seq) Seq {
+	switch len(seqs) {
+	case 0:
+		return EmptyList
+	case 1:
+		return seqs[0]
+	}
+
+	return ConcatSeq{seqs: seqs, lazy: new(lazyValue)}
+}
+
+// Value returns the memory value.  The sequence is copied into a new list on first
+// call.  If the underlying sequences cannot be read, Value returns an empty mem.Any;
+// use Materialize to obtain the error.
+func (cs ConcatSeq) Value() mem.Any {
+	any, _ := cs.Materialize()
+	return any
+}
+
+// Materialize copies the sequence into a new list, returning its memory value.  The
+// result is cached.
+func (cs ConcatSeq) Materialize() (mem.Any, error) {
+	return cs.lazy.Get(func() (mem.Any, error) {
+		items, err := ToSlice(cs)
+		if err != nil {
+			return mem.Any{}, err
+		}
+
+		l, err := NewList(capnp.SingleSegment(nil), items...)
+		if err != nil {
+			return mem.Any{}, err
+		}
+
+		return l.Value(), nil
+	})
+}
+
+// Count returns the total number of items in the underlying sequences.
+func (cs ConcatSeq) Count() (cnt int, err error) {
+	var n int
+	for _, seq := range cs.seqs {
+		if n, err = seq.Count(); err != nil {
+			break
+		}
+
+		cnt += n
+	}
+
+	return
+}
+
+// First returns the first item of the sequence.
+func (cs ConcatSeq) First() (ww.Any, error) { return cs.seqs[0].First() }
+
+// Next returns the tail of the sequence.
+func (cs ConcatSeq) Next() (Seq, error) {
+	head, err := cs.seqs[0].Next()
+	if err != nil {
+		return nil, err
+	}
+
+	if head, err = nonEmpty(head); err != nil {
+		return nil, err
+	}
+
+	if head == nil {
+		return newConcatSeq(cs.seqs[1:]), nil
+	}
+
+	seqs := make([]Seq, len(cs.seqs))
+	seqs[0] = head
+	copy(seqs[1:], cs.seqs[1:])
+	return newConcatSeq(seqs), nil
+}
+
+// Conj returns a list with the items prepended to the sequence.
+func (cs ConcatSeq) Conj(items ...ww.Any) (Container, error) {
+	any, err := cs.Materialize()
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := asList(any)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.Conj(items...)
+}
+
+// Render the sequence in list notation.
+func (cs ConcatSeq) Render() (string, error) { return renderSeq(cs) }
+
+// ToSeq returns a sequence over the items in any.  Nil values produce an empty
+// sequence.
+func ToSeq(any ww.Any) (Seq, error) {
+	if IsNil(any) {
+		return EmptyList, nil
+	}
+
+	switch v := any.(type) {
+	case Seqable:
+		return v.Seq()
+
+	case Seq:
+		return v, nil
+
+	}
+
+	return nil, fmt.Errorf("cannot create seq from %s", any.Value().Which())
+}
+
+// Into returns a new collection consisting of to, with all of the items of from
+// conjoined, as if by Conj.  For instance, items are appended to a vector and
+// prepended to a list.  If to is nil, a list is returned.
+//
+// Items are conjoined in a single batch.  Vectors fill their tail up to 32 items
+// at a time, so a persistent copy is made once per 32 items rather than once per
+// item.
+func Into(to, from ww.Any) (Container, error) {
+	seq, err := ToSeq(from)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := ToSlice(seq)
+	if err != nil {
+		return nil, err
+	}
+
+	return Conj(to, items...)
+}
+
+// nonEmpty returns seq, or nil if seq is nil or empty.
+func nonEmpty(seq Seq) (Seq, error) {
+	if seq == nil {
+		return nil, nil
+	}
+
+	cnt, err := seq.Count()
+	if err != nil || cnt == 0 {
+		return nil, err
+	}
+
+	return seq, nil
+}
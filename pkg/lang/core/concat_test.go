@@ -0,0 +1,126 @@
+package core_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+func TestConcat(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Empty", func(t *testing.T) {
+		t.Parallel()
+
+		seq, err := core.Concat()
+		require.NoError(t, err)
+		assert.Equal(t, core.EmptyList, seq)
+
+		seq, err = core.Concat(nil, core.EmptyVector, core.EmptyList)
+		require.NoError(t, err)
+		assert.Equal(t, core.EmptyList, seq)
+	})
+
+	t.Run("Lazy", func(t *testing.T) {
+		t.Parallel()
+
+		items := valueRange(100)
+		seq, err := core.Concat(
+			mustVector(items[:40]...),
+			core.EmptyVector,
+			mustList(items[40:41]...),
+			mustVector(items[41:]...))
+		require.NoError(t, err)
+		require.IsType(t, core.ConcatSeq{}, seq)
+
+		cnt, err := seq.Count()
+		require.NoError(t, err)
+		assert.Equal(t, 100, cnt)
+
+		got, err := core.ToSlice(seq)
+		require.NoError(t, err)
+		require.Len(t, got, 100)
+		for i := range got {
+			assertEq(t, items[i], got[i])
+		}
+
+		s, err := core.Render(seq)
+		require.NoError(t, err)
+		assert.Equal(t, mustRender(mustList(items...)), s)
+
+		// materializing the sequence produces an equivalent list
+		assertEq(t, mustList(items...), seq)
+	})
+
+	t.Run("NotSeqable", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := core.Concat(mustVector(), mustInt(1))
+		assert.Error(t, err)
+	})
+
+	t.Run("ReadError", func(t *testing.T) {
+		t.Parallel()
+
+		seq, err := core.Concat(mustVector(mustInt(1)), failingSeq{})
+		require.NoError(t, err)
+
+		// materialization errors are reported rather than panicking
+		assert.NotPanics(t, func() { seq.Value() })
+
+		_, err = core.Canonical(seq)
+		assert.True(t, errors.Is(err, errRead), "unexpected error %v", err)
+
+		_, err = core.NewVector(capnp.SingleSegment(nil), seq)
+		assert.True(t, errors.Is(err, errRead), "unexpected error %v", err)
+	})
+}
+
+var errRead = errors.New("read failed")
+
+// failingSeq is a non-empty sequence whose items cannot be read.  Its memory value
+// is a placeholder; it must not be nil, else the sequence would be treated as empty.
+type failingSeq struct{}
+
+func (failingSeq) Value() mem.Any                         { return mustList(mustInt(0)).Value() }
+func (failingSeq) Count() (int, error)                    { return 1, nil }
+func (failingSeq) First() (ww.Any, error)                 { return nil, errRead }
+func (failingSeq) Next() (core.Seq, error)                { return nil, errRead }
+func (failingSeq) Conj(...ww.Any) (core.Container, error) { return nil, errRead }
+
+func TestInto(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Vector", func(t *testing.T) {
+		t.Parallel()
+
+		items := valueRange(100)
+		v, err := core.Into(mustVector(items[:10]...), mustList(items[10:]...))
+		require.NoError(t, err)
+		require.Implements(t, (*core.Vector)(nil), v)
+		assertVectorTypeOK(t, v.(core.Vector))
+		assertEq(t, mustVector(items...), v)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		t.Parallel()
+
+		l, err := core.Into(mustList(mustInt(0)), mustVector(mustInt(1), mustInt(2)))
+		require.NoError(t, err)
+		assertEq(t, mustList(mustInt(2), mustInt(1), mustInt(0)), l)
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		t.Parallel()
+
+		l, err := core.Into(nil, mustVector(mustInt(1), mustInt(2)))
+		require.NoError(t, err)
+		assertEq(t, mustList(mustInt(1), mustInt(2)), l)
+	})
+}
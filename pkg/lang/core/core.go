@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sync"
 
+	"github.com/multiformats/go-multihash"
 	"github.com/spy16/slurp/core"
 	"github.com/wetware/ww/internal/mem"
 	ww "github.com/wetware/ww/pkg"
@@ -59,10 +61,6 @@ type (
 	Error = core.Error
 )
 
-// New returns a root Env that can be used to execute forms.
-// It binds the prelude to the environment before returning.
-func New() Env { return core.New(nil) }
-
 // Eval a form.
 func Eval(env Env, a Analyzer, form core.Any) (core.Any, error) {
 	return core.Eval(env, a, form)
@@ -156,7 +154,11 @@ func IsTruthy(v ww.Any) (bool, error) {
 	}
 }
 
-// Eq returns true is the two values are equal
+// Eq returns true if the two values are equal.  Numbers are equal if they have the
+// same magnitude, regardless of type.  Lists and vectors are equal to collections of
+// the same type with equal items, in the same order.  Other values are equal if they
+// have the same type and the same canonical representation, so equality does not
+// depend on how a value's message was built or laid out in memory.  See Hash.
 func Eq(a, b ww.Any) (bool, error) {
 	// Nil is only equal to itself
 	if IsNil(a) && IsNil(b) {
@@ -166,8 +168,7 @@ func Eq(a, b ww.Any) (bool, error) {
 	// Check for usable interfaces on object A
 	switch val := a.(type) {
 	case Comparable:
-		i, err := val.Comp(b)
-		return i == 0, err
+		return compEq(val, b)
 
 	case EqualityProvider:
 		return val.Eq(b)
@@ -177,31 +178,75 @@ func Eq(a, b ww.Any) (bool, error) {
 	// Check for usable interfaces on object B
 	switch val := b.(type) {
 	case Comparable:
-		i, err := val.Comp(b)
-		return i == 0, err
+		return compEq(val, a)
 
 	case EqualityProvider:
-		return val.Eq(b)
+		return val.Eq(a)
 
 	}
 
+	// Disparate types are unequal by default.
+	if a.Value().Which() != b.Value().Which() {
+		return false, nil
+	}
+
+	switch a.Value().Which() {
+	case mem.Any_Which_list, mem.Any_Which_vector:
+		return eqItems(a, b)
+	}
+
 	// Identical types with the same canonical representation are equal.
-	if a.Value().Which() == b.Value().Which() {
-		ca, err := Canonical(a)
-		if err != nil {
-			return false, err
-		}
+	ca, err := Canonical(a)
+	if err != nil {
+		return false, err
+	}
 
-		cb, err := Canonical(b)
-		if err != nil {
+	cb, err := Canonical(b)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(ca, cb), nil
+}
+
+// compEq reports whether c compares equal to other.  Incomparable values are unequal.
+func compEq(c Comparable, other ww.Any) (bool, error) {
+	i, err := c.Comp(other)
+	if errors.Is(err, ErrIncomparableTypes) {
+		return false, nil
+	}
+
+	return i == 0, err
+}
+
+// eqItems compares two collections item by item.
+func eqItems(a, b ww.Any) (bool, error) {
+	xs, err := items(a)
+	if err != nil {
+		return false, err
+	}
+
+	ys, err := items(b)
+	if err != nil || len(xs) != len(ys) {
+		return false, err
+	}
+
+	for i := range xs {
+		if eq, err := Eq(xs[i], ys[i]); err != nil || !eq {
 			return false, err
 		}
+	}
+
+	return true, nil
+}
 
-		return bytes.Equal(ca, cb), nil
+func items(coll ww.Any) ([]ww.Any, error) {
+	seq, err := ToSeq(coll)
+	if err != nil {
+		return nil, err
 	}
 
-	// Disparate types are unequal by default.
-	return false, nil
+	return ToSlice(seq)
 }
 
 // Pop an item from an ordered collection.
@@ -248,9 +293,242 @@ func Conj(any ww.Any, xs ...ww.Any) (Container, error) {
 	return nil, fmt.Errorf("cannot conj with %T", any)
 }
 
-// Canonical representation of an arbitrary value.
+// Peek returns the item that would be removed by Pop, without removing it.
+// For a list, returns the first item.
+// For a vector, returns the last item.
+// If the collection is empty, returns Nil.
+func Peek(cont Container) (ww.Any, error) {
+	cnt, err := cont.Count()
+	if err != nil || cnt == 0 {
+		return Nil{}, err
+	}
+
+	switch v := cont.(type) {
+	case Vector:
+		return v.EntryAt(cnt - 1)
+
+	case Seq:
+		return v.First()
+
+	}
+
+	return nil, fmt.Errorf("cannot peek %s", cont.Value().Which())
+}
+
+// Nth returns the item at index i of an ordered collection.  Lists are
+// traversed in linear time.  If i is out of range, returns a wrapped
+// ErrIndexOutOfBounds.
+func Nth(cont Container, i int) (ww.Any, error) {
+	cnt, err := cont.Count()
+	if err != nil {
+		return nil, err
+	}
+
+	if i < 0 || i >= cnt {
+		return nil, Error{
+			Cause:   ErrIndexOutOfBounds,
+			Message: fmt.Sprintf("index %d, count %d", i, cnt),
+		}
+	}
+
+	switch v := cont.(type) {
+	case Vector:
+		return v.EntryAt(i)
+
+	case Seq:
+		for ; i > 0; i-- {
+			if v, err = v.Next(); err != nil {
+				return nil, err
+			}
+		}
+
+		return v.First()
+
+	}
+
+	return nil, fmt.Errorf("cannot index %s", cont.Value().Which())
+}
+
+// First returns the first item of an ordered collection, or Nil if the
+// collection is nil or empty.
+func First(any ww.Any) (ww.Any, error) {
+	if IsNil(any) {
+		return Nil{}, nil
+	}
+
+	if c, ok := any.(Container); ok {
+		if cnt, err := c.Count(); err != nil || cnt == 0 {
+			return Nil{}, err
+		}
+	}
+
+	switch v := any.(type) {
+	case Vector:
+		return v.EntryAt(0)
+
+	case Seq:
+		return v.First()
+
+	}
+
+	return nil, fmt.Errorf("cannot take first of %s", any.Value().Which())
+}
+
+// Rest returns an ordered collection without its first item, as a sequence.
+// If the collection is nil, empty, or contains a single item, Rest returns an
+// empty sequence.
+func Rest(any ww.Any) (Seq, error) {
+	if IsNil(any) {
+		return EmptyList, nil
+	}
+
+	if c, ok := any.(Container); ok {
+		if cnt, err := c.Count(); err != nil || cnt < 2 {
+			return EmptyList, err
+		}
+	}
+
+	var (
+		seq Seq
+		err error
+	)
+
+	switch v := any.(type) {
+	case Seqable:
+		if seq, err = v.Seq(); err != nil {
+			return nil, err
+		}
+
+	case Seq:
+		seq = v
+
+	default:
+		return nil, fmt.Errorf("cannot take rest of %s", any.Value().Which())
+	}
+
+	return seq.Next()
+}
+
+// Canonical returns the canonical encoding of an arbitrary value:  a packed,
+// single-segment Cap'n Proto message, laid out in a fixed order.  The encoding depends
+// only on the value's contents, and not on how its message was built, so a vector
+// that is built by a chain of Conj and Assoc calls encodes to the same bytes as one
+// that is built at once.
 func Canonical(any ww.Any) ([]byte, error) {
-	return capnp.Canonicalize(any.Value().Struct)
+	v, err := valueOf(any)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := capnp.Canonicalize(v.Struct)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &capnp.Message{Arena: capnp.SingleSegment(b)}
+	return msg.MarshalPacked()
+}
+
+// ContentID returns the SHA2-256 multihash of the canonical encoding of v.  Values
+// with equal content IDs have the same canonical encoding.
+func ContentID(v ww.Any) (multihash.Multihash, error) {
+	b, err := Canonical(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return multihash.Sum(b, multihash.SHA2_256, -1)
+}
+
+// FromCanonical decodes a value from its canonical encoding.  The encoding may come
+// from an untrusted source, so it is rejected unless it is canonical, which bounds
+// the work done to decode it by its size.  Capabilities cannot be encoded, and are
+// rejected too.
+func FromCanonical(b []byte) (ww.Any, error) {
+	msg, err := capnp.UnmarshalPacked(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if n := msg.NumSegments(); n != 1 {
+		return nil, fmt.Errorf("expected one segment, got %d", n)
+	}
+
+	seg, err := msg.Segment(0)
+	if err != nil {
+		return nil, err
+	}
+
+	// a canonical message is read at most once, by Canonicalize
+	msg.TraverseLimit = uint64(len(seg.Data()))
+
+	any, err := mem.ReadRootAny(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := capnp.Canonicalize(any.Struct)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(c, seg.Data()) {
+		return nil, errors.New("value is not in canonical form")
+	}
+
+	// the decoded value is read lazily, and may be read many times
+	if any, err = mem.ReadRootAny(&capnp.Message{Arena: capnp.SingleSegment(c)}); err != nil {
+		return nil, err
+	}
+
+	return AsAny(any)
+}
+
+// Materializer is implemented by lazy values, whose memory value is constructed on
+// demand and may fail.
+type Materializer interface {
+	Materialize() (mem.Any, error)
+}
+
+// valueOf returns the memory value of any, reporting errors from lazy values.
+func valueOf(any ww.Any) (mem.Any, error) {
+	if m, ok := any.(Materializer); ok {
+		return m.Materialize()
+	}
+
+	return any.Value(), nil
+}
+
+// setAny sets the i'th element of l to the memory value of item.
+func setAny(l mem.Any_List, i int, item ww.Any) error {
+	v, err := valueOf(item)
+	if err != nil {
+		return err
+	}
+
+	return l.Set(i, v)
+}
+
+// setHead sets the head of a list cell to the memory value of item.
+func setHead(cell interface{ SetHead(mem.Any) error }, item ww.Any) error {
+	v, err := valueOf(item)
+	if err != nil {
+		return err
+	}
+
+	return cell.SetHead(v)
+}
+
+// lazyValue caches a memory value that is constructed on first use.
+type lazyValue struct {
+	once sync.Once
+	any  mem.Any
+	err  error
+}
+
+func (l *lazyValue) Get(f func() (mem.Any, error)) (mem.Any, error) {
+	l.once.Do(func() { l.any, l.err = f() })
+	return l.any, l.err
 }
 
 // AsAny lifts a mem.Any to a ww.Any.
@@ -285,8 +563,8 @@ func AsAny(any mem.Any) (item ww.Any, err error) {
 	case mem.Any_Which_vector:
 		item, err = asVector(any)
 
-	// case mem.Any_Which_proc:
-	// 	item = RemoteProcess{v}
+	case mem.Any_Which_proc:
+		item, err = asProc(any)
 	default:
 		err = fmt.Errorf("unknown value type '%s'", any.Which())
 	}
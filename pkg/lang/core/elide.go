@@ -0,0 +1,291 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	ww "github.com/wetware/ww/pkg"
+)
+
+// ellipsis introduces every elision marker.  Markers are written outside of string
+// delimiters, so they cannot be confused with string contents.
+const ellipsis = "…"
+
+// DefaultLimits are suitable for interactive output and logs.
+var DefaultLimits = Limits{
+	MaxString: 1024,
+	MaxItems:  128,
+	MaxDepth:  8,
+	MaxBytes:  64 * 1024,
+}
+
+// Limits bound the size of rendered values.  Zero or negative fields are unlimited.
+//
+// Elided content is replaced by a marker reporting how much was omitted, e.g.
+// `…+1234 bytes` or `…+990 items`.
+type Limits struct {
+	// MaxString is the maximum number of bytes rendered for any single atom,
+	// excluding string delimiters.
+	MaxString int
+
+	// MaxItems is the maximum number of elements rendered for any collection.
+	MaxItems int
+
+	// MaxDepth is the maximum nesting depth of rendered collections.  Collections
+	// below this depth are rendered as a marker.
+	MaxDepth int
+
+	// MaxBytes is the maximum size of the rendered output, including markers.
+	MaxBytes int
+}
+
+// Render a value into a human-readable representation, subject to the limits.
+func (l Limits) Render(v ww.Any) (string, error) { return l.RenderMasked(v, nil) }
+
+// Mask is consulted for each element of a collection, along with the element that
+// precedes it (nil for the first).  If it returns true, the returned string is
+// rendered in place of the element.  This allows values following a keyword to be
+// hidden, as in [:user "bob" :password "hunter2"].
+type Mask func(prev, item ww.Any) (string, bool)
+
+// RenderMasked renders v like Render, replacing collection elements according to
+// mask.  A nil mask replaces nothing.
+func (l Limits) RenderMasked(v ww.Any, mask Mask) (string, error) {
+	var b strings.Builder
+	if err := l.render(&b, v, 0, mask); err != nil {
+		return "", err
+	}
+
+	return l.Elide(b.String()), nil
+}
+
+// Elide truncates s to MaxBytes, including the trailing marker.  Truncation respects
+// UTF-8 character boundaries.  If MaxBytes is too small to hold a marker, the output
+// is a bare ellipsis, or the empty string if even that does not fit.
+func (l Limits) Elide(s string) string {
+	if l.MaxBytes <= 0 || len(s) <= l.MaxBytes {
+		return s
+	}
+
+	// The marker for len(s) elided bytes is at least as long as the actual marker,
+	// so n is a lower bound.  Refining it once accounts for the shorter marker.
+	n := l.MaxBytes - len(bytesMarker(len(s)))
+	if n >= 0 {
+		n = l.MaxBytes - len(bytesMarker(len(s)-n))
+	}
+
+	if n < 0 {
+		if len(ellipsis) <= l.MaxBytes {
+			return ellipsis
+		}
+
+		return ""
+	}
+
+	// Backing up to a character boundary may lengthen the marker.
+	n = runeBoundary(s, n)
+	for n+len(bytesMarker(len(s)-n)) > l.MaxBytes {
+		n = runeBoundary(s, n-1)
+	}
+
+	return s[:n] + bytesMarker(len(s)-n)
+}
+
+// renderAtom renders an atom like render, then elides it to MaxBytes.  Strings are
+// truncated within their delimiters, so that the output remains balanced.
+func (l Limits) renderAtom(v ww.Any, depth int) (string, error) {
+	var b strings.Builder
+	if err := l.render(&b, v, depth, nil); err != nil {
+		return "", err
+	}
+
+	if l.MaxBytes <= 0 || b.Len() <= l.MaxBytes {
+		return b.String(), nil
+	}
+
+	str, ok := v.(String)
+	if !ok {
+		return l.Elide(b.String()), nil
+	}
+
+	s, err := str.Value().Str()
+	if err != nil {
+		return "", err
+	}
+
+	// Each byte removed shortens the output by at least one byte, so removing the
+	// excess converges quickly.
+	t, _ := l.truncate(s)
+	for i := len(t); ; {
+		out := quoteString(s[:i]) + bytesMarker(len(s)-i)
+		if len(out) <= l.MaxBytes || i == 0 {
+			return l.Elide(out), nil
+		}
+
+		if i -= len(out) - l.MaxBytes; i < 0 {
+			i = 0
+		}
+
+		i = runeBoundary(s, i)
+	}
+}
+
+func (l Limits) render(b *strings.Builder, v ww.Any, depth int, mask Mask) error {
+	switch val := v.(type) {
+	case nil:
+		b.WriteString("nil")
+		return nil
+
+	case String:
+		s, err := val.Value().Str()
+		if err != nil {
+			return err
+		}
+
+		s, marker := l.truncate(s)
+		b.WriteString(quoteString(s))
+		b.WriteString(marker)
+		return nil
+
+	case Vector:
+		return l.renderVector(b, val, depth, mask)
+
+	case Seq:
+		return l.renderSeq(b, val, depth, mask)
+	}
+
+	s, err := Render(v)
+	if err == nil {
+		s, marker := l.truncate(s)
+		b.WriteString(s)
+		b.WriteString(marker)
+	}
+
+	return err
+}
+
+func (l Limits) renderVector(b *strings.Builder, v Vector, depth int, mask Mask) error {
+	cnt, err := v.Count()
+	if err != nil {
+		return err
+	}
+
+	b.WriteRune('[')
+	defer b.WriteRune(']')
+
+	var prev ww.Any
+	n := l.items(cnt, depth)
+	for i := 0; i < n; i++ {
+		item, err := v.EntryAt(i)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			b.WriteRune(' ')
+		}
+
+		if err = l.renderItem(b, prev, item, depth+1, mask); err != nil {
+			return err
+		}
+
+		prev = item
+	}
+
+	l.writeItemsMarker(b, n, cnt)
+	return nil
+}
+
+func (l Limits) renderSeq(b *strings.Builder, seq Seq, depth int, mask Mask) error {
+	cnt, err := seq.Count()
+	if err != nil {
+		return err
+	}
+
+	b.WriteRune('(')
+	defer b.WriteRune(')')
+
+	var prev ww.Any
+	n := l.items(cnt, depth)
+	for i := 0; i < n; i++ {
+		item, err := seq.First()
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			b.WriteRune(' ')
+		}
+
+		if err = l.renderItem(b, prev, item, depth+1, mask); err != nil {
+			return err
+		}
+
+		prev = item
+
+		if seq, err = seq.Next(); err != nil {
+			return err
+		}
+	}
+
+	l.writeItemsMarker(b, n, cnt)
+	return nil
+}
+
+func (l Limits) renderItem(b *strings.Builder, prev, item ww.Any, depth int, mask Mask) error {
+	if mask != nil {
+		if s, ok := mask(prev, item); ok {
+			b.WriteString(s)
+			return nil
+		}
+	}
+
+	return l.render(b, item, depth, mask)
+}
+
+// items returns the number of elements to render for a collection with cnt elements,
+// at the specified depth.
+func (l Limits) items(cnt, depth int) int {
+	if l.MaxDepth > 0 && depth >= l.MaxDepth {
+		return 0
+	}
+
+	if l.MaxItems > 0 && cnt > l.MaxItems {
+		return l.MaxItems
+	}
+
+	return cnt
+}
+
+func (l Limits) writeItemsMarker(b *strings.Builder, rendered, cnt int) {
+	if rendered < cnt {
+		if rendered > 0 {
+			b.WriteRune(' ')
+		}
+
+		fmt.Fprintf(b, "%s+%d items", ellipsis, cnt-rendered)
+	}
+}
+
+// truncate s to MaxString, returning the truncated string and the marker, if any.
+func (l Limits) truncate(s string) (string, string) {
+	if l.MaxString <= 0 || len(s) <= l.MaxString {
+		return s, ""
+	}
+
+	n := runeBoundary(s, l.MaxString)
+	return s[:n], bytesMarker(len(s) - n)
+}
+
+func bytesMarker(n int) string { return fmt.Sprintf("%s+%d bytes", ellipsis, n) }
+
+// runeBoundary returns the largest index i <= n such that s[:i] does not split a
+// UTF-8 encoded character.
+func runeBoundary(s string, n int) int {
+	for n > 0 && n < len(s) && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return n
+}
@@ -0,0 +1,144 @@
+package core_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+func TestLimits(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Unlimited", func(t *testing.T) {
+		t.Parallel()
+
+		v := mustVector(mustInt(1), mustString("two"), mustVector(mustInt(3)))
+
+		want, err := core.Render(v)
+		require.NoError(t, err)
+
+		got, err := core.Limits{}.Render(v)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("String", func(t *testing.T) {
+		t.Parallel()
+
+		s, err := core.Limits{MaxString: 3}.Render(mustString("abcdef"))
+		require.NoError(t, err)
+		assert.Equal(t, `"abc"…+3 bytes`, s)
+	})
+
+	t.Run("Items", func(t *testing.T) {
+		t.Parallel()
+
+		items := make([]ww.Any, 100)
+		for i := range items {
+			items[i] = mustInt(i)
+		}
+
+		s, err := core.Limits{MaxItems: 2}.Render(mustVector(items...))
+		require.NoError(t, err)
+		assert.Equal(t, `[0 1 …+98 items]`, s)
+
+		s, err = core.Limits{MaxItems: 2}.Render(mustList(items...))
+		require.NoError(t, err)
+		assert.Equal(t, `(0 1 …+98 items)`, s)
+	})
+
+	t.Run("Depth", func(t *testing.T) {
+		t.Parallel()
+
+		v := mustVector(mustInt(1), mustVector(mustInt(2), mustVector(mustInt(3))))
+
+		s, err := core.Limits{MaxDepth: 2}.Render(v)
+		require.NoError(t, err)
+		assert.Equal(t, `[1 [2 […+1 items]]]`, s)
+	})
+
+	t.Run("Bytes", func(t *testing.T) {
+		t.Parallel()
+
+		s := core.Limits{MaxBytes: 16}.Elide(strings.Repeat("a", 100))
+		assert.Equal(t, "aaaa…+96 bytes", s)
+		assert.LessOrEqual(t, len(s), 16)
+
+		assert.Equal(t, "…", core.Limits{MaxBytes: 4}.Elide("abcdefgh"))
+		assert.Equal(t, "", core.Limits{MaxBytes: 2}.Elide("abcdefgh"))
+	})
+}
+
+func TestLimitsBudget(t *testing.T) {
+	t.Parallel()
+
+	// pathological unicode:  multi-byte runes, combining marks, zero-width joiners,
+	// right-to-left overrides and invalid encodings.
+	alphabet := []string{
+		"a", "é", "é", "🏳️‍🌈", "👩‍👩‍👧‍👦", "‮", "​", "𝕏",
+		"\xff", "\x80", "\xe2\x82", `"`, "…+1 bytes",
+	}
+
+	rng := rand.New(rand.NewSource(42))
+
+	randString := func() core.String {
+		var b strings.Builder
+		for i := rng.Intn(64); i > 0; i-- {
+			b.WriteString(alphabet[rng.Intn(len(alphabet))])
+		}
+		return mustString(b.String())
+	}
+
+	var randValue func(depth int) ww.Any
+	randValue = func(depth int) ww.Any {
+		if depth > 3 || rng.Intn(3) == 0 {
+			return randString()
+		}
+
+		// keep the fan-out small; the number of values is exponential in depth.
+		items := make([]ww.Any, rng.Intn(10))
+		for i := range items {
+			items[i] = randValue(depth + 1)
+		}
+		return mustVector(items...)
+	}
+
+	for i := 0; i < 500; i++ {
+		l := core.Limits{
+			MaxString: rng.Intn(32),
+			MaxItems:  rng.Intn(8),
+			MaxDepth:  rng.Intn(4),
+			MaxBytes:  rng.Intn(256),
+		}
+
+		v := randValue(0)
+
+		s, err := l.Render(v)
+		require.NoError(t, err)
+
+		if l.MaxBytes > 0 {
+			require.LessOrEqual(t, len(s), l.MaxBytes, "limits %+v", l)
+		}
+
+		// truncation never introduces invalid encodings
+		if full, err := core.Render(v); err == nil && utf8.ValidString(full) {
+			assert.True(t, utf8.ValidString(s), "limits %+v produced invalid utf8", l)
+		}
+	}
+}
+
+func mustVector(items ...ww.Any) core.Vector {
+	v, err := core.NewVector(capnp.SingleSegment(nil), items...)
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spy16/slurp/core"
+)
+
+// Enumerable is an Env that can list the names bound in its frame, without resolving
+// their values.
+type Enumerable interface {
+	Env
+
+	// Names returns the names bound in the frame, in lexical order.
+	Names() []string
+
+	// Complete returns the names bound in the frame that begin with prefix, in
+	// lexical order.
+	Complete(prefix string) []string
+}
+
+// New returns a root Env that can be used to execute forms.  The env and its children
+// are Enumerable.
+func New() Env {
+	return &mapEnv{
+		name: "<main>",
+		vars: map[string]core.Any{},
+	}
+}
+
+// mapEnv is an Enumerable Env.  The names in each frame are indexed in lexical order
+// on first use and kept sorted thereafter, so that completion is a binary search.
+// Frames are created on every function call, so the index is not built eagerly.
+type mapEnv struct {
+	parent Env
+	name   string
+
+	mu    sync.RWMutex
+	vars  map[string]core.Any
+	index []string // sorted keys of vars, or nil if not yet built
+}
+
+func (env *mapEnv) Name() string { return env.name }
+func (env *mapEnv) Parent() Env  { return env.parent }
+
+func (env *mapEnv) Child(name string, vars map[string]core.Any) Env {
+	if vars == nil {
+		vars = map[string]core.Any{}
+	}
+
+	return &mapEnv{
+		parent: env,
+		name:   name,
+		vars:   vars,
+	}
+}
+
+func (env *mapEnv) Bind(name string, val core.Any) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("%w: %s", core.ErrInvalidName, name)
+	}
+
+	if env.parent == nil {
+		// only the root env is shared between threads.
+		env.mu.Lock()
+		defer env.mu.Unlock()
+	}
+
+	if _, ok := env.vars[name]; !ok && env.index != nil {
+		i := sort.SearchStrings(env.index, name)
+		env.index = append(env.index, "")
+		copy(env.index[i+1:], env.index[i:])
+		env.index[i] = name
+	}
+
+	env.vars[name] = val
+	return nil
+}
+
+func (env *mapEnv) Resolve(name string) (core.Any, error) {
+	if env.parent == nil {
+		env.mu.RLock()
+		defer env.mu.RUnlock()
+	}
+
+	v, found := env.vars[name]
+	if !found {
+		return nil, fmt.Errorf("%w: %s", core.ErrNotFound, name)
+	}
+
+	return v, nil
+}
+
+func (env *mapEnv) Names() []string {
+	return env.Complete("")
+}
+
+func (env *mapEnv) Complete(prefix string) []string {
+	// The index is built under the write lock, since it may be built concurrently
+	// with a query on another thread.
+	env.mu.Lock()
+	defer env.mu.Unlock()
+
+	if env.index == nil {
+		env.index = make([]string, 0, len(env.vars))
+		for name := range env.vars {
+			env.index = append(env.index, name)
+		}
+		sort.Strings(env.index)
+	}
+
+	i := sort.SearchStrings(env.index, prefix)
+	j := i
+	for j < len(env.index) && strings.HasPrefix(env.index[j], prefix) {
+		j++
+	}
+
+	return append([]string(nil), env.index[i:j]...)
+}
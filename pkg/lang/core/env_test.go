@@ -0,0 +1,41 @@
+package core_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wetware/ww/pkg/lang/core"
+)
+
+func TestEnv(t *testing.T) {
+	t.Parallel()
+
+	env := core.New().(core.Enumerable)
+	for _, name := range []string{"foo", "bar", "baz"} {
+		require.NoError(t, env.Bind(name, core.Nil{}))
+	}
+
+	assert.Equal(t, []string{"bar", "baz", "foo"}, env.Names())
+	assert.Equal(t, []string{"bar", "baz"}, env.Complete("ba"))
+	assert.Empty(t, env.Complete("qux"))
+
+	// bindings made after the index is built are kept in order
+	require.NoError(t, env.Bind("bam", core.Nil{}))
+	require.NoError(t, env.Bind("foo", core.True))
+	assert.Equal(t, []string{"bam", "bar", "baz"}, env.Complete("ba"))
+
+	v, err := env.Resolve("foo")
+	require.NoError(t, err)
+	assert.Equal(t, core.True, v)
+
+	child := env.Child("child", nil).(core.Enumerable)
+	require.NoError(t, child.Bind("qux", core.Nil{}))
+	assert.Equal(t, []string{"qux"}, child.Names())
+	assert.Equal(t, env, child.Parent())
+
+	_, err = child.Resolve("foo")
+	assert.True(t, errors.Is(err, core.ErrNotFound), "unexpected error %v", err)
+}
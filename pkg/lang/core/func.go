@@ -83,9 +83,8 @@ func (fn Fn) Match(nargs int) (CallTarget, error) {
 		return CallTarget{}, err
 	}
 
-	var a funcAnalyzer
 	for i := 0; i < fs.Len(); i++ {
-		a.f = fs.At(i)
+		a := funcAnalyzer{f: fs.At(i)}
 		if ok, err := a.matchArity(nargs); err != nil {
 			return CallTarget{}, err
 		} else if !ok {
@@ -104,7 +103,81 @@ func (fn Fn) Match(nargs int) (CallTarget, error) {
 		return ct, nil
 	}
 
-	return CallTarget{}, fmt.Errorf("%w (%d) to '%s'", ErrArity, nargs, ct.Name)
+	return CallTarget{}, fn.ArityError(nargs, "")
+}
+
+// ArityError reports the function name, the number of arguments supplied, the
+// arguments wanted, if want is non-empty, and the available arities.
+func (fn Fn) ArityError(nargs int, want string) error {
+	name, err := fn.Name()
+	if err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("'%s' called with %d args", name, nargs)
+	if want != "" {
+		msg += ", want " + want
+	}
+
+	if as, err := fn.Arglists(); err == nil {
+		if s, err := as.(Renderable).Render(); err == nil {
+			msg += ", arities " + s
+		}
+	}
+
+	return Error{Cause: ErrArity, Message: msg}
+}
+
+// Arglists returns a vector containing the parameter vector of each call signature,
+// in declaration order.  Variadic parameters carry the `...` suffix, as in source.
+func (fn Fn) Arglists() (Vector, error) {
+	raw, err := fn.Fn()
+	if err != nil {
+		return nil, err
+	}
+
+	fs, err := raw.Funcs()
+	if err != nil {
+		return nil, err
+	}
+
+	vs := make([]ww.Any, fs.Len())
+	for i := range vs {
+		if vs[i], err = arglist(fs.At(i)); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewVector(capnp.SingleSegment(nil), vs...)
+}
+
+func arglist(f mem.Fn_Func) (Vector, error) {
+	if !f.HasParams() {
+		return EmptyVector, nil
+	}
+
+	ps, err := f.Params()
+	if err != nil {
+		return nil, err
+	}
+
+	syms := make([]ww.Any, ps.Len())
+	for i := range syms {
+		name, err := ps.At(i)
+		if err != nil {
+			return nil, err
+		}
+
+		if f.Variadic() && i == len(syms)-1 {
+			name += "..."
+		}
+
+		if syms[i], err = NewSymbol(capnp.SingleSegment(nil), name); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewVector(capnp.SingleSegment(nil), syms...)
 }
 
 // FuncBuilder is a factory type for Fn.
@@ -217,6 +290,10 @@ func (b *FuncBuilder) setFuncs() error {
 		return errors.New("no call signatures")
 	}
 
+	if err := b.checkArities(); err != nil {
+		return err
+	}
+
 	fs, err := b.fn.NewFuncs(int32(len(b.sigs)))
 	if err != nil {
 		return err
@@ -232,6 +309,38 @@ func (b *FuncBuilder) setFuncs() error {
 	return err
 }
 
+// checkArities ensures that each call signature can be selected unambiguously.  At
+// most one signature may be variadic, and no two may have the same number of params.
+func (b *FuncBuilder) checkArities() error {
+	seen := make(map[int]bool, len(b.sigs))
+	var variadic bool
+
+	for _, sig := range b.sigs {
+		if sig.Variadic {
+			if variadic {
+				return Error{
+					Cause:   errors.New("invalid call signature"),
+					Message: "multiple variadic signatures",
+				}
+			}
+
+			variadic = true
+			continue
+		}
+
+		if seen[len(sig.Params)] {
+			return Error{
+				Cause:   errors.New("invalid call signature"),
+				Message: fmt.Sprintf("duplicate arity %d", len(sig.Params)),
+			}
+		}
+
+		seen[len(sig.Params)] = true
+	}
+
+	return nil
+}
+
 func (b *FuncBuilder) readParams(v Vector) ([]string, bool, error) {
 	cnt, err := v.Count()
 	if err != nil || cnt == 0 {
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+	"math"
+	"math/big"
+
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+)
+
+// Hashable values provide a hash that is consistent with their Eq method.  Values
+// that are equal by identity, such as channels and processes, implement Hashable.
+type Hashable interface {
+	Hash() (uint64, error)
+}
+
+// Hash returns a hash of v that is consistent with Eq:  equal values have equal
+// hashes.  Like Eq, Hash depends on the contents of a value, and not on the layout of
+// its message, so it is suitable for keying maps and sets of values.
+func Hash(v ww.Any) (uint64, error) {
+	h := fnv.New64a()
+	err := writeHash(h, v)
+	return h.Sum64(), err
+}
+
+// tags distinguish the kinds of hash input that are not prefixed by a value type.
+const (
+	tagHashable byte = 0xff - iota
+	tagNumber
+	tagInf
+	tagNaN
+)
+
+func writeHash(h hash.Hash64, v ww.Any) error {
+	if IsNil(v) {
+		_, _ = h.Write([]byte{byte(mem.Any_Which_nil)})
+		return nil
+	}
+
+	switch val := v.(type) {
+	case Hashable:
+		sum, err := val.Hash()
+		writeUint64(h, tagHashable, sum)
+		return err
+
+	case Numerical:
+		return hashNumber(h, val)
+
+	}
+
+	switch which := v.Value().Which(); which {
+	case mem.Any_Which_list, mem.Any_Which_vector:
+		xs, err := items(v)
+		if err != nil {
+			return err
+		}
+
+		writeUint64(h, byte(which), uint64(len(xs)))
+		for _, x := range xs {
+			if err = writeHash(h, x); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	b, err := Canonical(v)
+	_, _ = h.Write(b)
+	return err
+}
+
+// hashNumber hashes the exact magnitude of a number, so that numbers of different
+// types that compare equal have equal hashes.
+func hashNumber(h hash.Hash64, n Numerical) error {
+	var r big.Rat
+
+	switch val := n.(type) {
+	case Int64:
+		r.SetInt64(val.Int64())
+
+	case BigInt:
+		r.SetInt(val.BigInt())
+
+	case Float64:
+		f := val.Float64()
+		if math.IsNaN(f) {
+			writeUint64(h, tagNaN, math.Float64bits(f))
+			return nil
+		}
+
+		if math.IsInf(f, 0) {
+			writeUint64(h, tagInf, uint64(int64(math.Copysign(1, f))))
+			return nil
+		}
+
+		r.SetFloat64(f)
+
+	case BigFloat:
+		if val.f.IsInf() {
+			writeUint64(h, tagInf, uint64(int64(val.f.Sign())))
+			return nil
+		}
+
+		val.f.Rat(&r)
+
+	case Fraction:
+		r.Set(val.Rat())
+
+	default:
+		b, err := Canonical(n)
+		_, _ = h.Write(b)
+		return err
+	}
+
+	// big.Rat is normalized, so equal magnitudes have equal numerators & denominators
+	num := r.Num().Bytes()
+	writeUint64(h, tagNumber, uint64(len(num)))
+	_, _ = h.Write([]byte{byte(r.Sign() + 1)})
+	_, _ = h.Write(num)
+	_, _ = h.Write(r.Denom().Bytes())
+	return nil
+}
+
+func writeUint64(h hash.Hash64, tag byte, u uint64) {
+	var buf [9]byte
+	buf[0] = tag
+	binary.BigEndian.PutUint64(buf[1:], u)
+	_, _ = h.Write(buf[:])
+}
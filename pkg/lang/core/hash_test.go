@@ -0,0 +1,320 @@
+package core_test
+
+import (
+	"math"
+	"testing"
+	"testing/quick"
+
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	memutil "github.com/wetware/ww/pkg/util/mem"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+func TestEq(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		desc string
+		a, b ww.Any
+		want bool
+	}{
+		{"Nil", core.Nil{}, core.Nil{}, true},
+		{"NilNumber", core.Nil{}, mustInt(0), false},
+		{"NumberNil", mustInt(0), core.Nil{}, false},
+		{"IntFloat", mustInt(2), mustFloat(2), true},
+		{"FracBigInt", mustFrac(6, 3), mustBigInt(2), true},
+		{"IntString", mustInt(1), mustString("1"), false},
+		{"StringInt", mustString("1"), mustInt(1), false},
+		{"StringKeyword", mustString("a"), mustKeyword("a"), false},
+		{"Vectors", mustVector(mustInt(1), mustString("a")), mustVector(mustInt(1), mustString("a")), true},
+		{"VectorOrder", mustVector(mustInt(1), mustInt(2)), mustVector(mustInt(2), mustInt(1)), false},
+		{"VectorNumbers", mustVector(mustInt(1)), mustVector(mustFloat(1)), true},
+		{"VectorList", mustVector(mustInt(1)), mustList(mustInt(1)), false},
+		{"ListLength", mustList(mustInt(1)), mustList(mustInt(1), mustInt(1)), false},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			eq, err := core.Eq(tt.a, tt.b)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, eq)
+		})
+	}
+}
+
+func TestHash(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ConsistentWithEq", func(t *testing.T) {
+		t.Parallel()
+
+		for _, vs := range [][]ww.Any{
+			{core.Nil{}},
+			{mustInt(3), mustFloat(3), mustBigInt(3), mustFrac(9, 3), mustBigFloat(3)},
+			{mustFloat(0.5), mustFrac(1, 2), mustBigFloat(0.5)},
+			{mustFloat(math.Inf(1)), mustBigFloat(math.Inf(1))},
+			{mustString("hello")},
+			{mustKeyword("hello")},
+			{mustVector(mustInt(1), mustString("x")), mustVector(mustFloat(1), mustString("x"))},
+			{mustList(mustInt(1), mustInt(2)), consList(mustInt(1), mustInt(2))},
+		} {
+			want := mustHash(t, vs[0])
+			for _, v := range vs[1:] {
+				eq, err := core.Eq(vs[0], v)
+				require.NoError(t, err)
+				require.True(t, eq, "%s should equal %s", mustRender(vs[0]), mustRender(v))
+
+				assert.Equal(t, want, mustHash(t, v),
+					"hash of %s should equal hash of %s", mustRender(v), mustRender(vs[0]))
+			}
+		}
+	})
+
+	t.Run("Distinct", func(t *testing.T) {
+		t.Parallel()
+
+		// not guaranteed in general, but these should not collide
+		vs := []ww.Any{
+			core.Nil{},
+			mustInt(1),
+			mustInt(-1),
+			mustFrac(1, 2),
+			mustFloat(math.Inf(-1)),
+			mustString("1"),
+			mustKeyword("1"),
+			mustSymbol("1"),
+			mustVector(mustInt(1)),
+			mustList(mustInt(1)),
+			mustVector(),
+		}
+
+		seen := make(map[uint64]ww.Any, len(vs))
+		for _, v := range vs {
+			h := mustHash(t, v)
+			if other, ok := seen[h]; ok {
+				t.Errorf("%s and %s have the same hash", mustRender(v), mustRender(other))
+			}
+			seen[h] = v
+		}
+	})
+
+	t.Run("Identity", func(t *testing.T) {
+		t.Parallel()
+
+		ch, err := core.NewChan(0)
+		require.NoError(t, err)
+
+		other, err := core.NewChan(0)
+		require.NoError(t, err)
+
+		assert.Equal(t, mustHash(t, ch), mustHash(t, ch))
+		assert.NotEqual(t, mustHash(t, ch), mustHash(t, other))
+	})
+
+	t.Run("Reserialize", func(t *testing.T) {
+		t.Parallel()
+
+		// copying a value into a new message never changes its identity
+		f := func(is []int64, s string, r float64) bool {
+			if len(is) > 8 {
+				is = is[:8] // deep lists exceed capnp's default traversal depth
+			}
+
+			items := make([]ww.Any, 0, len(is)+2)
+			for _, i := range is {
+				items = append(items, mustInt(int(i)))
+			}
+			items = append(items, mustString(s), mustFloat(r))
+
+			for _, v := range []ww.Any{mustVector(items...), mustList(items...)} {
+				any, err := memutil.Copy(capnp.MultiSegment(nil), v.Value())
+				require.NoError(t, err)
+
+				cp, err := core.AsAny(any)
+				require.NoError(t, err)
+
+				eq, err := core.Eq(v, cp)
+				require.NoError(t, err)
+
+				if !eq || mustHash(t, v) != mustHash(t, cp) {
+					return false
+				}
+			}
+
+			return true
+		}
+
+		require.NoError(t, quick.Check(f, &quick.Config{MaxCount: 50}))
+	})
+}
+
+func TestCanonical(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Vector", func(t *testing.T) {
+		t.Parallel()
+
+		for _, n := range []int{0, 1, 5, 32, 33, 100, 1100} {
+			items := make([]ww.Any, n)
+			zeros := make([]ww.Any, n)
+			for i := range items {
+				items[i], zeros[i] = mustInt(i), mustInt(0)
+			}
+
+			want := mustCanonical(t, mustVector(items...))
+
+			// built incrementally by Conj
+			var conj core.Container = core.EmptyVector
+			for _, item := range items {
+				var err error
+				conj, err = conj.Conj(item)
+				require.NoError(t, err)
+			}
+
+			// built by a chain of Assoc calls
+			assoc := mustVector(zeros...)
+			for i, item := range items {
+				var err error
+				assoc, err = assoc.Assoc(i, item)
+				require.NoError(t, err)
+			}
+
+			// built by popping a longer vector
+			pop, err := mustVector(append(items, mustInt(-1))...).Pop()
+			require.NoError(t, err)
+
+			assert.Equal(t, want, mustCanonical(t, conj), "conj: n=%d", n)
+			assert.Equal(t, want, mustCanonical(t, assoc), "assoc: n=%d", n)
+			assert.Equal(t, want, mustCanonical(t, pop), "pop: n=%d", n)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		t.Parallel()
+
+		items := []ww.Any{mustInt(1), mustString("a"), mustKeyword("b")}
+		assert.Equal(t,
+			mustCanonical(t, mustList(items...)),
+			mustCanonical(t, consList(items...)))
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		t.Parallel()
+
+		inner, err := mustVector(mustInt(0), mustInt(0)).Assoc(1, mustString("x"))
+		require.NoError(t, err)
+
+		assert.Equal(t,
+			mustCanonical(t, mustVector(mustList(mustVector(mustInt(0), mustString("x"))))),
+			mustCanonical(t, mustVector(consList(inner))))
+	})
+
+	t.Run("Reserialize", func(t *testing.T) {
+		t.Parallel()
+
+		v := mustVector(mustInt(1), mustList(mustString("a")), mustFloat(0.5))
+
+		any, err := memutil.Copy(capnp.MultiSegment(nil), v.Value())
+		require.NoError(t, err)
+
+		cp, err := core.AsAny(any)
+		require.NoError(t, err)
+
+		assert.Equal(t, mustCanonical(t, v), mustCanonical(t, cp))
+
+	})
+
+	t.Run("FromCanonical", func(t *testing.T) {
+		t.Parallel()
+
+		items := make([]ww.Any, 1100)
+		for i := range items {
+			items[i] = mustString("x")
+		}
+
+		for _, v := range []ww.Any{
+			core.Nil{},
+			mustInt(1),
+			mustFrac(1, 3),
+			mustVector(mustInt(1), mustList(mustString("a")), mustFloat(0.5)),
+			mustVector(items...),
+		} {
+			got, err := core.FromCanonical(mustCanonical(t, v))
+			require.NoError(t, err)
+			assert.Equal(t, mustRender(v), mustRender(got))
+		}
+
+		// a message that is not canonical, e.g. with padding between its root and
+		// the value's data, is rejected
+		msg, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+		require.NoError(t, err)
+
+		_, err = capnp.NewStruct(seg, capnp.ObjectSize{DataSize: 8})
+		require.NoError(t, err)
+
+		root, err := mem.NewRootAny(seg)
+		require.NoError(t, err)
+		require.NoError(t, root.SetStr("padded"))
+
+		b, err := msg.MarshalPacked()
+		require.NoError(t, err)
+
+		_, err = core.FromCanonical(b)
+		assert.EqualError(t, err, "value is not in canonical form")
+	})
+}
+
+func TestContentID(t *testing.T) {
+	t.Parallel()
+
+	id, err := core.ContentID(mustVector(mustInt(1), mustInt(2)))
+	require.NoError(t, err)
+
+	dh, err := multihash.Decode(id)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(multihash.SHA2_256), dh.Code)
+
+	conj, err := mustVector(mustInt(1)).Conj(mustInt(2))
+	require.NoError(t, err)
+
+	same, err := core.ContentID(conj)
+	require.NoError(t, err)
+	assert.Equal(t, id, same)
+
+	// unlike Eq and Hash, content IDs distinguish numeric types
+	other, err := core.ContentID(mustVector(mustInt(1), mustFloat(2)))
+	require.NoError(t, err)
+	assert.NotEqual(t, id, other)
+}
+
+// consList builds a list by consing each item onto the empty list, which lays it out
+// differently from core.NewList.
+func consList(items ...ww.Any) core.List {
+	var l core.List = core.EmptyList
+	for i := len(items) - 1; i >= 0; i-- {
+		var err error
+		if l, err = l.Cons(items[i]); err != nil {
+			panic(err)
+		}
+	}
+
+	return l
+}
+
+func mustCanonical(t *testing.T, v ww.Any) []byte {
+	b, err := core.Canonical(v)
+	require.NoError(t, err)
+	return b
+}
+
+func mustHash(t *testing.T, v ww.Any) uint64 {
+	h, err := core.Hash(v)
+	require.NoError(t, err)
+	return h
+}
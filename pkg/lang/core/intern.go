@@ -0,0 +1,67 @@
+package core
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"github.com/wetware/ww/internal/mem"
+	memutil "github.com/wetware/ww/pkg/util/mem"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+// internMax is the length, in bytes, of the longest symbol or keyword that is
+// interned.  Longer names are rare, and are allocated on each use.
+const internMax = 64
+
+// InternCap is the maximum number of names in each intern table.  Interned values are
+// never evicted, so the cap bounds the memory held by programs that generate names
+// dynamically.  Once a table is full, names that are not already interned are
+// allocated on each use.
+const InternCap = 1 << 12
+
+var symbols, keywords internTable
+
+// internTable caches immutable, single-segment values by name.  Interned values are
+// shared across goroutines, so they MUST NOT be modified.  Embedding an interned value
+// in another message is safe, since capnp copies pointers across messages.
+type internTable struct {
+	m sync.Map
+	n int32 // number of entries in m
+}
+
+func (t *internTable) Get(s string, set func(mem.Any, string) error) (mem.Any, error) {
+	if v, ok := t.m.Load(s); ok {
+		return v.(mem.Any), nil
+	}
+
+	full := atomic.LoadInt32(&t.n) >= InternCap
+
+	any, err := memutil.Alloc(capnp.SingleSegment(nil))
+	if err != nil {
+		return mem.Any{}, err
+	}
+
+	if err = set(any, s); err != nil {
+		return mem.Any{}, err
+	}
+
+	if full {
+		return any, nil
+	}
+
+	// Interned values are read for the lifetime of the process, and would eventually
+	// exhaust the default traversal limit.  They are small and trusted.
+	any.Segment().Message().ResetReadLimit(math.MaxUint64)
+
+	// Concurrent misses may overshoot the cap slightly; this is harmless.
+	v, loaded := t.m.LoadOrStore(s, any)
+	if !loaded {
+		atomic.AddInt32(&t.n, 1)
+	}
+
+	return v.(mem.Any), nil
+}
+
+func setSymbol(any mem.Any, s string) error  { return any.SetSymbol(s) }
+func setKeyword(any mem.Any, s string) error { return any.SetKeyword(s) }
@@ -0,0 +1,112 @@
+package core_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+func TestIntern(t *testing.T) {
+	t.Parallel()
+
+	// Intern the names used by parallel subtests before the tables fill up.
+	mustSymbol("interned")
+	mustKeyword("interned")
+	mustSymbol("embedded")
+
+	t.Run("Cap", func(t *testing.T) {
+		for i := 0; i < core.InternCap; i++ {
+			mustSymbol(fmt.Sprintf("cap-%d", i))
+		}
+
+		a, b := mustSymbol("interned"), mustSymbol("interned")
+		assert.Same(t, a.Segment().Message(), b.Segment().Message(),
+			"interned symbols should remain interned")
+
+		a, b = mustSymbol("past-cap"), mustSymbol("past-cap")
+		assert.NotSame(t, a.Segment().Message(), b.Segment().Message(),
+			"symbols should not be interned once the table is full")
+	})
+
+	t.Run("Shared", func(t *testing.T) {
+		t.Parallel()
+
+		a, b := mustSymbol("interned"), mustSymbol("interned")
+		assert.Same(t, a.Segment().Message(), b.Segment().Message(),
+			"short symbols should be interned")
+
+		k, s := mustKeyword("interned"), mustSymbol("interned")
+		assert.NotSame(t, k.Segment().Message(), s.Segment().Message(),
+			"symbols and keywords should be interned separately")
+
+		long := strings.Repeat("x", 65)
+		a, b = mustSymbol(long), mustSymbol(long)
+		assert.NotSame(t, a.Segment().Message(), b.Segment().Message(),
+			"long symbols should not be interned")
+	})
+
+	t.Run("Embed", func(t *testing.T) {
+		t.Parallel()
+
+		sym := mustSymbol("embedded")
+
+		v, err := core.NewVector(capnp.SingleSegment(nil), sym, sym)
+		require.NoError(t, err)
+
+		item, err := v.EntryAt(1)
+		require.NoError(t, err)
+
+		s, err := item.(core.Symbol).Symbol()
+		require.NoError(t, err)
+		assert.Equal(t, "embedded", s)
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		t.Parallel()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				for j := 0; j < 1000; j++ {
+					kw, err := core.NewKeyword(capnp.SingleSegment(nil), "concurrent")
+					if assert.NoError(t, err) {
+						s, err := kw.Keyword()
+						assert.NoError(t, err)
+						assert.Equal(t, "concurrent", s)
+					}
+				}
+			}()
+		}
+
+		wg.Wait()
+	})
+}
+
+func BenchmarkNewSymbol(b *testing.B) {
+	for _, bb := range []struct {
+		desc, name string
+	}{
+		{"Interned", "symbol"},
+		{"Allocated", strings.Repeat("x", 65)},
+	} {
+		b.Run(bb.desc, func(b *testing.B) {
+			b.ReportAllocs()
+
+			var v ww.Any
+			for i := 0; i < b.N; i++ {
+				v, _ = core.NewSymbol(capnp.SingleSegment(nil), bb.name)
+			}
+			_ = v
+		})
+	}
+}
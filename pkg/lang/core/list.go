@@ -134,7 +134,7 @@ func newPersistentHeadList(a capnp.Arena, item ww.Any) (PersistentHeadList, erro
 		return PersistentHeadList{}, err
 	}
 
-	err = list.SetHead(item.Value())
+	err = setHead(list, item)
 	return PersistentHeadList{any}, err
 }
 
@@ -199,7 +199,7 @@ func (l PersistentHeadList) cons(a capnp.Arena, list mem.LinkedList, item ww.Any
 		return PackedPersistentList{}, err
 	}
 
-	err = cell.SetHead(item.Value())
+	err = setHead(cell, item)
 	return PackedPersistentList{any}, err
 }
 
@@ -293,7 +293,7 @@ func (l PackedPersistentList) cons(a capnp.Arena, list mem.LinkedList, item ww.A
 		return DeepPersistentList{}, err
 	}
 
-	if err = cell.SetHead(item.Value()); err != nil {
+	if err = setHead(cell, item); err != nil {
 		return DeepPersistentList{}, err
 	}
 
@@ -417,7 +417,7 @@ func (l DeepPersistentList) cons(a capnp.Arena, list mem.LinkedList, item ww.Any
 		return DeepPersistentList{}, err
 	}
 
-	if err = cell.SetHead(item.Value()); err != nil {
+	if err = setHead(cell, item); err != nil {
 		return DeepPersistentList{}, err
 	}
 
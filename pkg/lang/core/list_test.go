@@ -198,7 +198,7 @@ func TestPersistentHeadList(t *testing.T) {
 			require.NoError(t, err)
 			require.IsType(t, core.DeepPersistentList{}, ctr)
 
-			got, err := l.First()
+			got, err := core.First(ctr)
 			require.NoError(t, err)
 			assertEq(t, core.False, got)
 		})
@@ -305,7 +305,7 @@ func TestPackedPersistentList(t *testing.T) {
 			require.NoError(t, err)
 			require.IsType(t, core.DeepPersistentList{}, ctr)
 
-			got, err := l.First()
+			got, err := core.First(ctr)
 			require.NoError(t, err)
 			assertEq(t, core.True, got)
 		})
@@ -321,7 +321,7 @@ func TestPackedPersistentList(t *testing.T) {
 			require.NoError(t, err)
 			require.IsType(t, core.DeepPersistentList{}, ctr)
 
-			got, err := l.First()
+			got, err := core.First(ctr)
 			require.NoError(t, err)
 			assertEq(t, core.False, got)
 		})
@@ -432,7 +432,7 @@ func TestDeepPersistentList(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, len(items)+2, cnt)
 
-		got, err := l.First()
+		got, err := core.First(ctr)
 		require.NoError(t, err)
 		assertEq(t, core.False, got)
 	})
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math/big"
 	"strconv"
+	"strings"
 
 	"github.com/wetware/ww/internal/mem"
 	ww "github.com/wetware/ww/pkg"
@@ -89,20 +90,30 @@ type bigInt struct {
 	mem.Any
 }
 
-// NewBigInt .
+// NewBigInt .  The memory value encodes the sign as well as the magnitude.
 func NewBigInt(a capnp.Arena, i *big.Int) (BigInt, error) {
 	mv, err := memutil.Alloc(a)
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := i.GobEncode()
 	if err == nil {
-		err = mv.SetBigInt(i.Bytes())
+		err = mv.SetBigInt(buf)
 	}
 
 	return bigInt{i: i, Any: mv}, err
 }
 
 func asBigInt(any mem.Any) (BigInt, error) {
+	buf, err := any.BigInt()
+	if err != nil {
+		return nil, err
+	}
+
 	var i big.Int
-	if buf, err := any.BigInt(); err == nil {
-		i.SetBytes(buf)
+	if err = i.GobDecode(buf); err != nil {
+		return nil, err
 	}
 
 	return bigInt{i: &i, Any: any}, nil
@@ -114,7 +125,8 @@ func (bi bigInt) Value() mem.Any { return bi.Any }
 // BigInt satisfies BigInt
 func (bi bigInt) BigInt() *big.Int { return bi.i }
 
-func (bi bigInt) String() string { return bi.i.String() }
+// String renders the integer with the 'N' suffix, so that it reads back as a BigInt.
+func (bi bigInt) String() string { return bi.i.String() + "N" }
 
 // Comp returns 0 if the v == other, -1 if v < other, and 1 if v > other.
 func (bi bigInt) Comp(other ww.Any) (int, error) {
@@ -169,7 +181,8 @@ func (f f64) Value() mem.Any { return f.Any }
 // Float64 satisfies Float64
 func (f f64) Float64() float64 { return f.F64() }
 
-func (f f64) String() string { return strconv.FormatFloat(f.Float64(), 'g', -1, 64) }
+// String renders the float such that it reads back as a float.
+func (f f64) String() string { return floatString(strconv.FormatFloat(f.Float64(), 'g', -1, 64)) }
 
 // Comp returns 0 if the v == other, -1 if v < other, and 1 if v > other.
 func (f f64) Comp(other ww.Any) (int, error) {
@@ -240,7 +253,18 @@ func (bf BigFloat) Value() mem.Any { return bf.Any }
 // BigFloat satisfies BigFloat
 func (bf BigFloat) BigFloat() *big.Float { return bf.f }
 
-func (bf BigFloat) String() string { return bf.f.Text('g', -1) }
+// String renders the float such that it reads back as a float.
+func (bf BigFloat) String() string { return floatString(bf.f.Text('g', -1)) }
+
+// floatString ensures that a formatted float contains a decimal point or exponent,
+// so that integral values are not read back as integers.
+func floatString(s string) string {
+	if strings.ContainsAny(s, ".eEIN") {
+		return s
+	}
+
+	return s + ".0"
+}
 
 // Comp returns 0 if the v == other, -1 if v < other, and 1 if v > other.
 func (bf BigFloat) Comp(other ww.Any) (int, error) {
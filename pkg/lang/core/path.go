@@ -42,12 +42,16 @@ func (p Path) Value() mem.Any { return p.Any }
 // Render the path into a parseable s-expression.
 func (p Path) Render() (string, error) { return p.Path() }
 
-// Parts returns split path for p
+// Parts returns split path for p, after cleaning it.
 func (p Path) Parts() ([]string, error) {
 	s, err := p.Path()
 	if err != nil {
 		return nil, err
 	}
 
+	if s, err = anchorpath.Clean(s); err != nil {
+		return nil, err
+	}
+
 	return anchorpath.Parts(s), nil
 }
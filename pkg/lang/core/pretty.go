@@ -0,0 +1,200 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	ww "github.com/wetware/ww/pkg"
+)
+
+// Printer renders values across multiple lines, so that large collections remain
+// legible.  A collection that fits in the remaining width is written on one line.
+// Otherwise, its items are filled onto as few lines as possible, aligned one column
+// to the right of its opening delimiter, and the items that do not fit on a line of
+// their own are broken in turn, e.g.
+//
+//	[:user "alice" :roles
+//	 [:admin :ops :dev
+//	  :support]
+//	 :active true]
+//
+// Values are immutable trees, so shared structure is written in full, once per
+// reference, and cycles cannot occur.
+type Printer struct {
+	// Width is the target line width, in characters.  Atoms that are wider are
+	// never broken.  If Width is zero or negative, values are written on one line.
+	Width int
+
+	// Limits bound the rendered output, unless Readable is set.  Limits apply to
+	// atoms and collections, so that elided output remains balanced.  Each atom is
+	// elided to the bytes that remain of MaxBytes, and once MaxBytes have been
+	// rendered, the remaining items of each open collection are elided.  The output
+	// may therefore exceed MaxBytes by the item markers and closing delimiters.
+	Limits Limits
+
+	// Readable disables Limits, so that nothing is elided and the output can be
+	// read back.
+	Readable bool
+}
+
+// Fprint writes the layout of v to w, without a trailing newline.
+func Fprint(w io.Writer, v ww.Any, p Printer) error {
+	s, err := p.Render(v)
+	if err == nil {
+		_, err = io.WriteString(w, s)
+	}
+
+	return err
+}
+
+// Render the layout of v.
+func (p Printer) Render(v ww.Any) (string, error) {
+	var used int
+	d, err := p.doc(v, 0, &used)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	p.layout(&b, d, 0)
+	return b.String(), nil
+}
+
+func (p Printer) limits() Limits {
+	if p.Readable {
+		return Limits{}
+	}
+
+	return p.Limits
+}
+
+// doc is the flat rendering of a value, along with that of its items, if the value is
+// a collection.
+type doc struct {
+	flat        string
+	open, close string
+	items       []doc // nil for atoms
+}
+
+func (d doc) width() int { return utf8.RuneCountInString(d.flat) }
+
+// doc returns the doc of v.  Used is the number of bytes of flat rendering produced
+// so far, which is checked against MaxBytes.
+func (p Printer) doc(v ww.Any, depth int, used *int) (doc, error) {
+	switch val := v.(type) {
+	case Vector:
+		cnt, err := val.Count()
+		if err != nil {
+			return doc{}, err
+		}
+
+		return p.collection("[", "]", cnt, depth, used, val.EntryAt)
+
+	case Seq:
+		cnt, err := val.Count()
+		if err != nil {
+			return doc{}, err
+		}
+
+		seq := val
+		return p.collection("(", ")", cnt, depth, used, func(i int) (ww.Any, error) {
+			if i > 0 {
+				var err error
+				if seq, err = seq.Next(); err != nil {
+					return nil, err
+				}
+			}
+
+			return seq.First()
+		})
+	}
+
+	l := p.limits()
+	if l.MaxBytes > 0 {
+		if l.MaxBytes -= *used; l.MaxBytes <= 0 {
+			l.MaxBytes = len(ellipsis)
+		}
+	}
+
+	s, err := l.renderAtom(v, depth)
+	*used += len(s)
+	return doc{flat: s}, err
+}
+
+// collection returns the doc of a collection with cnt items, subject to the limits.
+// Items are fetched in order.
+func (p Printer) collection(open, close string, cnt, depth int, used *int, item func(int) (ww.Any, error)) (doc, error) {
+	l := p.limits()
+	n := l.items(cnt, depth)
+	*used += len(open) + len(close)
+
+	d := doc{open: open, close: close, items: make([]doc, 0, n+1)}
+	for i := 0; i < n; i++ {
+		if l.MaxBytes > 0 && *used >= l.MaxBytes {
+			break
+		}
+
+		if i > 0 {
+			*used++ // separator
+		}
+
+		v, err := item(i)
+		if err != nil {
+			return doc{}, err
+		}
+
+		child, err := p.doc(v, depth+1, used)
+		if err != nil {
+			return doc{}, err
+		}
+
+		d.items = append(d.items, child)
+	}
+
+	if rendered := len(d.items); rendered < cnt {
+		d.items = append(d.items, doc{flat: fmt.Sprintf("%s+%d items", ellipsis, cnt-rendered)})
+	}
+
+	flat := make([]string, len(d.items))
+	for i, child := range d.items {
+		flat[i] = child.flat
+	}
+
+	d.flat = open + strings.Join(flat, " ") + close
+	return d, nil
+}
+
+// layout writes d to b, which ends at column col.
+func (p Printer) layout(b *strings.Builder, d doc, col int) {
+	if d.items == nil || p.Width <= 0 || col+d.width() <= p.Width {
+		b.WriteString(d.flat)
+		return
+	}
+
+	b.WriteString(d.open)
+	col += utf8.RuneCountInString(d.open)
+	indent := "\n" + strings.Repeat(" ", col)
+
+	// Items are filled onto each line.  An item that does not fit on a line of its
+	// own is broken, and is followed by a new line.
+	end, broken := col, false
+	for i, item := range d.items {
+		if i > 0 {
+			if broken || end+1+item.width() > p.Width {
+				b.WriteString(indent)
+				end = col
+			} else {
+				b.WriteRune(' ')
+				end++
+			}
+		}
+
+		p.layout(b, item, end)
+		end += item.width()
+		broken = item.items != nil && end > p.Width
+	}
+
+	b.WriteString(d.close)
+}
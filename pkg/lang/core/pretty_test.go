@@ -0,0 +1,158 @@
+package core_test
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	"github.com/wetware/ww/pkg/lang/reader"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+func TestPrinter(t *testing.T) {
+	t.Parallel()
+
+	long := `"` + strings.Repeat("abcdefghij", 5) + `"`
+
+	for _, tt := range []struct {
+		name, src string
+		p         core.Printer
+	}{
+		{"atoms", `[1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20 21 22 23 24 25]`, core.Printer{}},
+		{"nested", `[:user "alice" :roles [:admin :ops] :history ((1 2) (3 4 5) [])]`, core.Printer{}},
+		{"deep", `[[[[1 2 3] [4 5 6]] [[7 8 9]]] "tail"]`, core.Printer{}},
+		{"list", `(defn greet [name] (str "hello, " name "!"))`, core.Printer{}},
+		{"elided", `[` + long + ` [1 [2 [3 [4]]]] ` + strings.Repeat("0 ", 12) + `]`,
+			core.Printer{Limits: core.Limits{MaxString: 16, MaxItems: 10, MaxDepth: 3}}},
+		{"readable", `[` + long + ` [1 [2 [3 [4]]]] ` + strings.Repeat("0 ", 12) + `]`,
+			core.Printer{Limits: core.Limits{MaxString: 16, MaxItems: 10, MaxDepth: 3}, Readable: true}},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			v := mustReadForm(t, tt.src)
+
+			var buf bytes.Buffer
+			for _, width := range []int{0, 20, 40, 80} {
+				p := tt.p
+				p.Width = width
+
+				_, _ = fmt.Fprintf(&buf, ";; width %d\n", width)
+				require.NoError(t, core.Fprint(&buf, v, p))
+				buf.WriteString("\n\n")
+			}
+
+			golden := filepath.Join("testdata", "pretty", tt.name+".golden")
+			if *update {
+				require.NoError(t, ioutil.WriteFile(golden, buf.Bytes(), 0644))
+			}
+
+			want, err := ioutil.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), buf.String())
+		})
+	}
+
+	t.Run("OneLine", func(t *testing.T) {
+		t.Parallel()
+
+		// without a width, the printer agrees with Limits, unless MaxBytes is exceeded
+		v := mustReadForm(t, `[1 "two" (:three [4 5 6]) [[[7]]]]`)
+		for _, l := range []core.Limits{{}, {MaxItems: 2}, {MaxDepth: 2}, core.DefaultLimits} {
+			want, err := l.Render(v)
+			require.NoError(t, err)
+
+			got, err := core.Printer{Limits: l}.Render(v)
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		}
+	})
+
+	t.Run("Balanced", func(t *testing.T) {
+		t.Parallel()
+
+		// eliding bytes never leaves a collection open
+		v := mustReadForm(t, `[(1 [2 3] (4 (5 6))) [[7 8 9] [10 [11 [12 13]]]] (:a (:b (:c)))]`)
+		for _, max := range []int{1, 8, 16, 24, 32, 48} {
+			for _, width := range []int{0, 10, 40} {
+				p := core.Printer{Width: width, Limits: core.Limits{MaxBytes: max}}
+				s, err := p.Render(v)
+				require.NoError(t, err)
+				assert.Contains(t, s, "+", "should elide at %d bytes: %s", max, s)
+
+				var open []rune
+				for _, r := range s {
+					switch r {
+					case '[', '(':
+						open = append(open, r)
+					case ']', ')':
+						require.NotEmpty(t, open, "unbalanced at %d bytes: %s", max, s)
+						want := map[rune]rune{']': '[', ')': '('}[r]
+						require.Equal(t, want, open[len(open)-1], "unbalanced at %d bytes: %s", max, s)
+						open = open[:len(open)-1]
+					}
+				}
+				require.Empty(t, open, "unbalanced at %d bytes: %s", max, s)
+			}
+		}
+	})
+
+	t.Run("Atoms", func(t *testing.T) {
+		t.Parallel()
+
+		// oversized atoms are elided to the bytes that remain
+		long := `"` + strings.Repeat("a", 10000) + `"`
+		l := core.Limits{MaxBytes: 100}
+
+		for _, src := range []string{long, `[` + long + `]`} {
+			for _, width := range []int{0, 40} {
+				s, err := core.Printer{Width: width, Limits: l}.Render(mustReadForm(t, src))
+				require.NoError(t, err)
+				assert.LessOrEqual(t, len(s), 100, s)
+				assert.Contains(t, s, `"…+`, "string should be closed before the marker")
+			}
+		}
+
+		// items that follow an elided atom are elided as well
+		s, err := core.Printer{Limits: l}.Render(mustReadForm(t, `[:key (`+long+` :tail)]`))
+		require.NoError(t, err)
+		assert.True(t, strings.HasSuffix(s, `"…+9925 bytes …+1 items)]`), s)
+		assert.Len(t, s, 100+len(" …+1 items"))
+	})
+
+	t.Run("Readable", func(t *testing.T) {
+		t.Parallel()
+
+		// readable output reads back as the same value
+		v := mustReadForm(t, `[:user "a very long string" [1 2 3 4 5 6 7 8 9] (:x (:y))]`)
+
+		s, err := core.Printer{Width: 10, Limits: core.Limits{MaxString: 1, MaxItems: 1}, Readable: true}.Render(v)
+		require.NoError(t, err)
+		assert.Contains(t, s, "\n")
+
+		eq, err := core.Eq(v, mustReadForm(t, s))
+		require.NoError(t, err)
+		assert.True(t, eq, s)
+	})
+}
+
+func mustReadForm(t *testing.T, src string) ww.Any {
+	t.Helper()
+
+	rd, err := reader.New(strings.NewReader(src))
+	require.NoError(t, err)
+
+	form, err := rd.One()
+	require.NoError(t, err)
+	return form.(ww.Any)
+}
@@ -0,0 +1,197 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	memutil "github.com/wetware/ww/pkg/util/mem"
+	capnp "zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/server"
+)
+
+var (
+	// ErrProcRunning is returned when attempting to read the result of a process that
+	// has not yet terminated.
+	ErrProcRunning = errors.New("process running")
+
+	// ErrProcCanceled is returned by a process that was canceled before it terminated.
+	ErrProcCanceled = errors.New("process canceled")
+
+	_ mem.Proc_Server = (*procServer)(nil)
+)
+
+// ProcFunc is the body of a local process.
+type ProcFunc func(context.Context) (ww.Any, error)
+
+// LocalProcess is a handle to a function executing in a local goroutine.  Its value
+// is a Proc capability, but local processes are never bound to an anchor, so they
+// cannot be addressed by remote hosts.
+type LocalProcess struct {
+	mem.Any
+	*proc
+}
+
+// Spawn a local process.  The function is executed in a separate goroutine, and its
+// result is retrievable through the returned handle.  Panics are recovered and
+// reported as errors.  The context passed to f expires when the process is canceled,
+// or when ctx expires.
+func Spawn(ctx context.Context, id uint64, f ProcFunc) (LocalProcess, error) {
+	any, err := memutil.Alloc(capnp.SingleSegment(nil))
+	if err != nil {
+		return LocalProcess{}, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	p := &proc{
+		id:     id,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	if err = any.SetProc(mem.Proc_ServerToClient(procServer{p}, nil)); err != nil {
+		cancel()
+		return LocalProcess{}, err
+	}
+
+	go p.run(ctx, f)
+
+	return LocalProcess{Any: any, proc: p}, nil
+}
+
+// asProc recovers a local process, channel, or pubsub capability from the capability
+// that represents it.
+func asProc(any mem.Any) (ww.Any, error) {
+	if p, ok := server.IsServer(any.Proc().Client.State().Brand); ok {
+		switch s := p.(type) {
+		case procServer:
+			return LocalProcess{Any: any, proc: s.proc}, nil
+		case chanServer:
+			return Chan{Any: any, channel: s.channel}, nil
+		case pubsubServer:
+			return PubSub{Any: any, pubsub: s.pubsub}, nil
+		case topicServer:
+			return Topic{Any: any, topic: s.topic}, nil
+		}
+	}
+
+	return nil, errors.New("remote process handles are not supported")
+}
+
+// Value returns the memory value.
+func (p LocalProcess) Value() mem.Any { return p.Any }
+
+// Render a human-readable representation of the process.
+func (p LocalProcess) Render() (string, error) {
+	state := "running"
+	if p.Terminated() {
+		state = "done"
+	}
+
+	return fmt.Sprintf("<proc %d (%s)>", p.id, state), nil
+}
+
+// Eq returns true if other refers to the same process.
+func (p LocalProcess) Eq(other ww.Any) (bool, error) {
+	o, ok := other.(LocalProcess)
+	return ok && o.proc == p.proc, nil
+}
+
+// Hash returns a hash of the process' identity.
+func (p LocalProcess) Hash() (uint64, error) {
+	return uint64(reflect.ValueOf(p.proc).Pointer()), nil
+}
+
+type proc struct {
+	id     uint64
+	cancel context.CancelFunc
+
+	done chan struct{}
+	res  ww.Any
+	err  error
+}
+
+// ID returns an identifier for the process that is unique within the
+// interpreter that spawned it.
+func (p *proc) ID() uint64 { return p.id }
+
+// Done returns a channel that is closed when the process terminates.
+func (p *proc) Done() <-chan struct{} { return p.done }
+
+// Terminated returns true if the process has terminated.
+func (p *proc) Terminated() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Cancel the process.  Cancellation is cooperative; the process terminates with
+// ErrProcCanceled once its body observes the expired context.
+func (p *proc) Cancel() { p.cancel() }
+
+// Wait for the process to terminate and return its result.
+func (p *proc) Wait(ctx context.Context) (ww.Any, error) {
+	select {
+	case <-p.done:
+		return p.res, p.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Result returns the result of a terminated process, or ErrProcRunning if the
+// process has not yet terminated.
+func (p *proc) Result() (ww.Any, error) {
+	if !p.Terminated() {
+		return nil, Error{Cause: ErrProcRunning, Message: fmt.Sprintf("proc %d", p.id)}
+	}
+
+	return p.res, p.err
+}
+
+func (p *proc) run(ctx context.Context, f ProcFunc) {
+	defer p.cancel()
+	defer func() {
+		if v := recover(); v != nil {
+			p.resolve(nil, Error{
+				Cause:   fmt.Errorf("panic: %v", v),
+				Message: fmt.Sprintf("proc %d", p.id),
+			})
+		}
+	}()
+
+	res, err := f(ctx)
+	if err != nil {
+		if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+			err = ErrProcCanceled
+		}
+
+		if _, ok := err.(Error); !ok {
+			err = Error{Cause: err, Message: fmt.Sprintf("proc %d", p.id)}
+		}
+	}
+
+	p.resolve(res, err)
+}
+
+func (p *proc) resolve(res ww.Any, err error) {
+	if res == nil && err == nil {
+		res = Nil{}
+	}
+
+	p.res, p.err = res, err
+	close(p.done)
+}
+
+type procServer struct{ *proc }
+
+func (p procServer) Wait(ctx context.Context, _ mem.Proc_wait) error {
+	_, err := p.proc.Wait(ctx)
+	return err
+}
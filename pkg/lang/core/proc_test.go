@@ -0,0 +1,135 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+func TestLocalProcess(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Wait", func(t *testing.T) {
+		t.Parallel()
+
+		p, err := core.Spawn(context.Background(), 1, func(context.Context) (ww.Any, error) {
+			return mustKeyword("value"), nil
+		})
+		require.NoError(t, err)
+
+		res, err := p.Wait(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, ":value", mustRender(res))
+		assert.True(t, p.Terminated())
+	})
+
+	t.Run("Result", func(t *testing.T) {
+		t.Parallel()
+
+		release := make(chan struct{})
+		p, err := core.Spawn(context.Background(), 1, func(context.Context) (ww.Any, error) {
+			<-release
+			return core.True, nil
+		})
+		require.NoError(t, err)
+
+		assert.False(t, p.Terminated())
+		_, err = p.Result()
+		assert.True(t, errors.Is(err, core.ErrProcRunning), "unexpected error %v", err)
+
+		close(release)
+		<-p.Done()
+
+		res, err := p.Result()
+		require.NoError(t, err)
+		assert.Equal(t, core.True, res)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		t.Parallel()
+
+		cause := errors.New("test")
+		p, err := core.Spawn(context.Background(), 1, func(context.Context) (ww.Any, error) {
+			return nil, cause
+		})
+		require.NoError(t, err)
+
+		_, err = p.Wait(context.Background())
+		assert.IsType(t, core.Error{}, err)
+		assert.True(t, errors.Is(err, cause), "unexpected error %v", err)
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		t.Parallel()
+
+		p, err := core.Spawn(context.Background(), 1, func(context.Context) (ww.Any, error) {
+			panic("test")
+		})
+		require.NoError(t, err)
+
+		_, err = p.Wait(context.Background())
+		assert.IsType(t, core.Error{}, err)
+		assert.Contains(t, err.Error(), "panic: test")
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		t.Parallel()
+
+		p, err := core.Spawn(context.Background(), 1, func(ctx context.Context) (ww.Any, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		require.NoError(t, err)
+
+		p.Cancel()
+
+		_, err = p.Wait(context.Background())
+		assert.IsType(t, core.Error{}, err)
+		assert.True(t, errors.Is(err, core.ErrProcCanceled), "unexpected error %v", err)
+	})
+
+	t.Run("WaitContext", func(t *testing.T) {
+		t.Parallel()
+
+		p, err := core.Spawn(context.Background(), 1, func(ctx context.Context) (ww.Any, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		require.NoError(t, err)
+		defer p.Cancel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+
+		_, err = p.Wait(ctx)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error %v", err)
+		assert.False(t, p.Terminated())
+	})
+
+	t.Run("AsAny", func(t *testing.T) {
+		t.Parallel()
+
+		p, err := core.Spawn(context.Background(), 1, func(context.Context) (ww.Any, error) {
+			return core.Nil{}, nil
+		})
+		require.NoError(t, err)
+
+		vec, err := core.NewVector(capnp.SingleSegment(nil), p)
+		require.NoError(t, err)
+
+		item, err := vec.EntryAt(0)
+		require.NoError(t, err)
+		require.IsType(t, core.LocalProcess{}, item)
+
+		ok, err := core.Eq(p, item)
+		require.NoError(t, err)
+		assert.True(t, ok, "process handle did not survive round-trip")
+	})
+}
@@ -0,0 +1,213 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	memutil "github.com/wetware/ww/pkg/util/mem"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+// MaxMessageSize is the largest pubsub message that a topic publishes or decodes, in
+// bytes.  It matches the default limit of libp2p pubsub.
+const MaxMessageSize = 1 << 20
+
+// TopicHandle is a joined pubsub topic, e.g. a client.Topic.
+type TopicHandle interface {
+	Publish(ctx context.Context, data []byte) error
+
+	// Messages returns the data of each message published to the topic, until ctx
+	// expires.
+	Messages(ctx context.Context) (<-chan []byte, error)
+}
+
+// Joiner joins a pubsub topic.
+type Joiner func(topic string) (TopicHandle, error)
+
+// PubSub is the capability to join pubsub topics.  Scripts receive it explicitly, and
+// must pass it to join a topic.  Like channels, its value is a capability that is only
+// meaningful within the local process.
+type PubSub struct {
+	mem.Any
+	*pubsub
+}
+
+type pubsub struct{ join Joiner }
+
+// NewPubSub returns a PubSub capability that joins topics with join.
+func NewPubSub(join Joiner) (PubSub, error) {
+	ps := &pubsub{join: join}
+
+	any, err := newCapability(pubsubServer{ps})
+	return PubSub{Any: any, pubsub: ps}, err
+}
+
+// Value returns the memory value.
+func (ps PubSub) Value() mem.Any { return ps.Any }
+
+// Render a human-readable representation of the capability.
+func (ps PubSub) Render() (string, error) { return "<pubsub>", nil }
+
+// Eq returns true if other refers to the same capability.
+func (ps PubSub) Eq(other ww.Any) (bool, error) {
+	o, ok := other.(PubSub)
+	return ok && o.pubsub == ps.pubsub, nil
+}
+
+// Hash returns a hash of the capability's identity.
+func (ps PubSub) Hash() (uint64, error) {
+	return uint64(reflect.ValueOf(ps.pubsub).Pointer()), nil
+}
+
+// Join a topic.
+func (ps PubSub) Join(name string) (Topic, error) {
+	h, err := ps.join(name)
+	if err != nil {
+		return Topic{}, err
+	}
+
+	t := &topic{name: name, h: h}
+
+	any, err := newCapability(topicServer{t})
+	return Topic{Any: any, topic: t}, err
+}
+
+// Topic is a joined pubsub topic, through which values are published to, and
+// received from, the hosts and clients of a cluster.
+type Topic struct {
+	mem.Any
+	*topic
+}
+
+type topic struct {
+	name string
+	h    TopicHandle
+}
+
+// Value returns the memory value.
+func (t Topic) Value() mem.Any { return t.Any }
+
+// Render a human-readable representation of the topic.
+func (t Topic) Render() (string, error) {
+	return fmt.Sprintf("<topic %s>", t.name), nil
+}
+
+// Eq returns true if other refers to the same topic handle.
+func (t Topic) Eq(other ww.Any) (bool, error) {
+	o, ok := other.(Topic)
+	return ok && o.topic == t.topic, nil
+}
+
+// Hash returns a hash of the topic handle's identity.
+func (t Topic) Hash() (uint64, error) {
+	return uint64(reflect.ValueOf(t.topic).Pointer()), nil
+}
+
+// Publish the canonical encoding of v.  Values that hold capabilities, such as
+// channels, cannot be published.
+func (t Topic) Publish(ctx context.Context, v ww.Any) error {
+	b, err := Canonical(v)
+	if err != nil {
+		return fmt.Errorf("publish: %w", err)
+	}
+
+	if len(b) > MaxMessageSize {
+		return fmt.Errorf("publish: message of %d bytes exceeds %d", len(b), MaxMessageSize)
+	}
+
+	return t.h.Publish(ctx, b)
+}
+
+// Subscribe to the topic.  The returned channel receives the value of each message,
+// in order, and is closed when the subscription ends.  Closing the channel cancels the
+// subscription.  Messages that are too large or cannot be decoded are received as
+// errors, and do not end the subscription.
+func (t Topic) Subscribe(size int) (Chan, error) {
+	ch, err := NewChan(size)
+	if err != nil {
+		return Chan{}, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	msgs, err := t.h.Messages(ctx)
+	if err != nil {
+		cancel()
+		return Chan{}, err
+	}
+
+	go func() {
+		defer cancel()
+		defer ch.Close()
+
+		for {
+			select {
+			case b, ok := <-msgs:
+				if !ok {
+					return
+				}
+
+				v, err := decodeMessage(b)
+				if err != nil {
+					err = ch.SendError(ctx, fmt.Errorf("%s: %w", t.name, err))
+				} else {
+					err = ch.Send(ctx, v)
+				}
+
+				if err != nil {
+					return
+				}
+
+			case <-ch.closed:
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+func decodeMessage(b []byte) (ww.Any, error) {
+	if len(b) > MaxMessageSize {
+		return nil, fmt.Errorf("message of %d bytes exceeds %d", len(b), MaxMessageSize)
+	}
+
+	v, err := FromCanonical(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+
+	return v, nil
+}
+
+// newCapability returns a value holding a capability that is branded by s, so that
+// it can be recovered by asProc.
+func newCapability(s mem.Proc_Server) (mem.Any, error) {
+	any, err := memutil.Alloc(capnp.SingleSegment(nil))
+	if err == nil {
+		err = any.SetProc(mem.Proc_ServerToClient(s, nil))
+	}
+
+	return any, err
+}
+
+// pubsubServer brands the capability that represents a PubSub.  Waiting on it blocks
+// until the context expires.
+type pubsubServer struct{ *pubsub }
+
+func (pubsubServer) Wait(ctx context.Context, _ mem.Proc_wait) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// topicServer brands the capability that represents a Topic.  Waiting on it blocks
+// until the context expires.
+type topicServer struct{ *topic }
+
+func (topicServer) Wait(ctx context.Context, _ mem.Proc_wait) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
@@ -0,0 +1,181 @@
+package core_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	capnp "zombiezen.com/go/capnproto2"
+
+	"github.com/wetware/ww/internal/mem"
+	"github.com/wetware/ww/pkg/lang/core"
+	memutil "github.com/wetware/ww/pkg/util/mem"
+)
+
+func TestPubSub(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Join", func(t *testing.T) {
+		t.Parallel()
+
+		ps, err := core.NewPubSub(func(topic string) (core.TopicHandle, error) {
+			if topic == "bad" {
+				return nil, errors.New("join failed")
+			}
+
+			return newLoopback(), nil
+		})
+		require.NoError(t, err)
+
+		topic, err := ps.Join("news")
+		require.NoError(t, err)
+		assert.Equal(t, "<topic news>", mustRender(topic))
+
+		_, err = ps.Join("bad")
+		assert.EqualError(t, err, "join failed")
+
+		// capabilities survive storage in collections
+		v, err := mustVector(ps, topic).EntryAt(1)
+		require.NoError(t, err)
+
+		eq, err := core.Eq(topic, v)
+		require.NoError(t, err)
+		assert.True(t, eq)
+	})
+
+	t.Run("PublishSubscribe", func(t *testing.T) {
+		t.Parallel()
+
+		h := newLoopback()
+		topic := mustTopic(t, h)
+
+		sub, err := topic.Subscribe(0)
+		require.NoError(t, err)
+		defer sub.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+
+		want := mustVector(mustKeyword("hello"), mustList(mustInt(42), mustString("x")))
+		require.NoError(t, topic.Publish(ctx, want))
+
+		got, ok, err := sub.Recv(ctx)
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, mustRender(want), mustRender(got))
+	})
+
+	t.Run("PublishCapability", func(t *testing.T) {
+		t.Parallel()
+
+		ch, err := core.NewChan(0)
+		require.NoError(t, err)
+
+		err = mustTopic(t, newLoopback()).Publish(context.Background(), mustVector(ch))
+		assert.Error(t, err)
+	})
+
+	t.Run("MalformedMessages", func(t *testing.T) {
+		t.Parallel()
+
+		h := newLoopback()
+		topic := mustTopic(t, h)
+
+		sub, err := topic.Subscribe(0)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+
+		proc, err := core.NewChan(0)
+		require.NoError(t, err)
+
+		for _, b := range [][]byte{
+			[]byte("garbage"),
+			bytes.Repeat([]byte{0}, core.MaxMessageSize+1),
+			mustMarshal(t, proc.Value()), // capabilities cannot be received
+			mustCanonical(t, mustInt(42)),
+		} {
+			h.msgs <- b
+		}
+
+		for i := 0; i < 3; i++ {
+			_, ok, err := sub.Recv(ctx)
+			assert.True(t, ok)
+			assert.Error(t, err, "message %d should be rejected", i)
+		}
+
+		// errors do not end the subscription
+		v, ok, err := sub.Recv(ctx)
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, "42", mustRender(v))
+
+		// closing the channel cancels the subscription
+		require.NoError(t, sub.Close())
+		select {
+		case <-h.canceled:
+		case <-ctx.Done():
+			t.Fatal("subscription was not canceled")
+		}
+	})
+}
+
+func mustTopic(t *testing.T, h core.TopicHandle) core.Topic {
+	t.Helper()
+
+	ps, err := core.NewPubSub(func(string) (core.TopicHandle, error) { return h, nil })
+	require.NoError(t, err)
+
+	topic, err := ps.Join("test")
+	require.NoError(t, err)
+	return topic
+}
+
+func mustMarshal(t *testing.T, any mem.Any) []byte {
+	t.Helper()
+
+	cp, err := memutil.Copy(capnp.SingleSegment(nil), any)
+	require.NoError(t, err)
+
+	b, err := cp.Segment().Message().MarshalPacked()
+	require.NoError(t, err)
+	return b
+}
+
+// loopback delivers published messages to its subscriber.
+type loopback struct {
+	msgs     chan []byte
+	once     sync.Once
+	canceled chan struct{}
+}
+
+func newLoopback() *loopback {
+	return &loopback{
+		msgs:     make(chan []byte, 8),
+		canceled: make(chan struct{}),
+	}
+}
+
+func (l *loopback) Publish(ctx context.Context, b []byte) error {
+	select {
+	case l.msgs <- b:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *loopback) Messages(ctx context.Context) (<-chan []byte, error) {
+	go func() {
+		<-ctx.Done()
+		l.once.Do(func() { close(l.canceled) })
+	}()
+
+	return l.msgs, nil
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"regexp"
+	"strings"
+
+	capnp "zombiezen.com/go/capnproto2"
+
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	memutil "github.com/wetware/ww/pkg/util/mem"
+)
+
+var _ ww.Any = (*Regex)(nil)
+
+// Regex is a compiled regular expression, using the syntax of Go's regexp package.
+//
+// Its memory value is the pattern string.  Consequently, a regex stored at an anchor
+// is loaded as a string, which can be recompiled with NewRegex.
+type Regex struct {
+	mem.Any
+	re, whole *regexp.Regexp
+}
+
+// NewRegex compiles the pattern.
+func NewRegex(a capnp.Arena, pattern string) (Regex, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Regex{}, err
+	}
+
+	// anchored variant for whole-string matches; the group numbering is unchanged.
+	whole, err := regexp.Compile(`^(?:` + pattern + `)$`)
+	if err != nil {
+		return Regex{}, err
+	}
+
+	any, err := memutil.Alloc(a)
+	if err == nil {
+		err = any.SetStr(pattern)
+	}
+
+	return Regex{Any: any, re: re, whole: whole}, err
+}
+
+// Value returns the memory value, i.e. the pattern string.
+func (r Regex) Value() mem.Any { return r.Any }
+
+// Regexp returns the compiled expression.
+func (r Regex) Regexp() *regexp.Regexp { return r.re }
+
+// Whole returns the compiled expression, anchored at both ends of the input.
+func (r Regex) Whole() *regexp.Regexp { return r.whole }
+
+// Render the regex as a literal.
+func (r Regex) Render() (string, error) {
+	var b strings.Builder
+	b.WriteString(`#"`)
+
+	escaped := false
+	for _, c := range r.re.String() {
+		if c == '"' && !escaped {
+			b.WriteRune('\\')
+		}
+
+		escaped = c == '\\' && !escaped
+		b.WriteRune(c)
+	}
+
+	b.WriteRune('"')
+	return b.String(), nil
+}
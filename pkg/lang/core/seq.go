@@ -59,8 +59,12 @@ func Cons(a capnp.Arena, head ww.Any, tail Seq) (List, error) {
 	}
 }
 
-// ToSlice converts the given sequence into a slice.
+// ToSlice converts the given sequence into a slice.  A nil sequence is empty.
 func ToSlice(seq Seq) ([]ww.Any, error) {
+	if seq == nil {
+		return nil, nil
+	}
+
 	cnt, err := seq.Count()
 	if err != nil || cnt == 0 {
 		return nil, err
@@ -0,0 +1,365 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+// ComparisonError is returned when two values have no canonical ordering.
+type ComparisonError struct{ A, B ww.Any }
+
+func (err ComparisonError) Error() string {
+	return fmt.Sprintf("%s: cannot compare %s with %s",
+		ErrIncomparableTypes,
+		renderOrType(err.A),
+		renderOrType(err.B))
+}
+
+// Unwrap returns ErrIncomparableTypes.
+func (err ComparisonError) Unwrap() error { return ErrIncomparableTypes }
+
+// Comparator returns a negative number if a < b, zero if a == b, and a positive
+// number if a > b.
+type Comparator func(a, b ww.Any) (int, error)
+
+// Compare a and b according to their canonical ordering, which is total:  any two
+// values are ordered, so that sorting heterogeneous collections is deterministic.
+//
+// Values of different types are ordered by type, as follows:  nil (including the empty
+// list, whose value is nil), booleans, numbers, chars, strings, keywords, symbols,
+// paths, lists, vectors, and then other values, by type.  Within a type,
+//
+//   - false precedes true;
+//   - numbers are ordered by magnitude, regardless of their type, and NaN follows
+//     every other number;
+//   - chars and strings are ordered by code point;
+//   - keywords and symbols are ordered by namespace, then by name, and those without
+//     a namespace come first;
+//   - paths are ordered segment by segment;
+//   - lists and vectors are ordered item by item, and then by length; and
+//   - other values are ordered by their canonical byte representation.
+//
+// Compare returns 0 if and only if Eq returns true, except for paths, which compare
+// equal if they are equivalent, e.g. /a and /a/.
+func Compare(a, b ww.Any) (int, error) {
+	ka, err := newSortKey(a)
+	if err != nil {
+		return 0, err
+	}
+
+	kb, err := newSortKey(b)
+	if err != nil {
+		return 0, err
+	}
+
+	return ka.Compare(kb)
+}
+
+// Sort returns a new vector containing the items of coll, in canonical order.  The
+// sort is stable.
+func Sort(coll ww.Any) (Vector, error) { return SortBy(coll, nil, nil) }
+
+// SortBy returns a new vector containing the items of coll, ordered by the value of
+// key for each item.  If key is nil, the items themselves are compared.  If cmp is
+// nil, the canonical ordering is used.  The sort is stable, and key is called exactly
+// once per item.
+func SortBy(coll ww.Any, key func(ww.Any) (ww.Any, error), cmp Comparator) (Vector, error) {
+	seq, err := ToSeq(coll)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := ToSlice(seq)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keys are computed up front, so that the comparisons performed by the sort do
+	// not allocate.
+	keys := make([]sortKey, len(items))
+	for i, item := range items {
+		if key != nil {
+			if item, err = key(item); err != nil {
+				return nil, err
+			}
+		}
+
+		if cmp != nil {
+			keys[i] = sortKey{v: item}
+		} else if keys[i], err = newSortKey(item); err != nil {
+			return nil, err
+		}
+	}
+
+	s := sorter{items: items, keys: keys, cmp: cmp}
+	if sort.Stable(&s); s.err != nil {
+		return nil, s.err
+	}
+
+	return NewVector(capnp.SingleSegment(nil), items...)
+}
+
+type sorter struct {
+	items []ww.Any
+	keys  []sortKey
+	cmp   Comparator
+	err   error
+}
+
+func (s *sorter) Len() int { return len(s.items) }
+
+func (s *sorter) Swap(i, j int) {
+	s.items[i], s.items[j] = s.items[j], s.items[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+func (s *sorter) Less(i, j int) bool {
+	if s.err != nil {
+		return false
+	}
+
+	var c int
+	if s.cmp != nil {
+		c, s.err = s.cmp(s.keys[i].v, s.keys[j].v)
+	} else {
+		c, s.err = s.keys[i].Compare(s.keys[j])
+	}
+
+	return c < 0
+}
+
+// sortGroup identifies a set of mutually comparable values.  Groups are ordered by
+// their value.
+type sortGroup uint8
+
+const (
+	groupNil sortGroup = iota
+	groupBool
+	groupNumber
+	groupChar
+	groupString
+	groupKeyword
+	groupSymbol
+	groupPath
+	groupList
+	groupVector
+	groupOther
+)
+
+// sortKey caches the parts of a value that are used for comparison.
+type sortKey struct {
+	v     ww.Any
+	group sortGroup
+	which mem.Any_Which // type of values in groupOther
+	text  string        // name, path or canonical bytes
+	ns    string        // namespace of keywords and symbols
+	i     int64         // bool, char, or the sign of an infinite number
+	nan   bool
+	items []sortKey // items of lists and vectors
+}
+
+func newSortKey(v ww.Any) (k sortKey, err error) {
+	k.v = v
+	if IsNil(v) {
+		return
+	}
+
+	if n, ok := v.(Numerical); ok {
+		k.group = groupNumber
+		k.i, k.nan = infOrNaN(n)
+		return
+	}
+
+	any := v.Value()
+	switch k.which = any.Which(); k.which {
+	case mem.Any_Which_bool:
+		k.group = groupBool
+		if any.Bool() {
+			k.i = 1
+		}
+
+	case mem.Any_Which_char:
+		k.group = groupChar
+		k.i = int64(any.Char())
+
+	case mem.Any_Which_str:
+		k.group = groupString
+		k.text, err = any.Str()
+
+	case mem.Any_Which_keyword:
+		k.group = groupKeyword
+		if k.text, err = any.Keyword(); err == nil {
+			k.ns, k.text = splitName(k.text)
+		}
+
+	case mem.Any_Which_symbol:
+		k.group = groupSymbol
+		if k.text, err = any.Symbol(); err == nil {
+			k.ns, k.text = splitName(k.text)
+		}
+
+	case mem.Any_Which_path:
+		k.group = groupPath
+		k.text, err = pathKey(Path{any})
+
+	case mem.Any_Which_list, mem.Any_Which_vector:
+		k.group = groupList
+		if k.which == mem.Any_Which_vector {
+			k.group = groupVector
+		}
+
+		var xs []ww.Any
+		if xs, err = items(v); err != nil {
+			return
+		}
+
+		k.items = make([]sortKey, len(xs))
+		for i, x := range xs {
+			if k.items[i], err = newSortKey(x); err != nil {
+				return
+			}
+		}
+
+	default:
+		var b []byte
+		if b, err = Canonical(v); err == nil {
+			k.group = groupOther
+			k.text = string(b)
+		}
+	}
+
+	return
+}
+
+func (k sortKey) Compare(other sortKey) (int, error) {
+	if k.group != other.group {
+		return compI64(int64(k.group), int64(other.group)), nil
+	}
+
+	switch k.group {
+	case groupNil:
+		return 0, nil
+
+	case groupNumber:
+		return k.compareNumber(other)
+
+	case groupBool, groupChar:
+		return compI64(k.i, other.i), nil
+
+	case groupKeyword, groupSymbol:
+		if c := strings.Compare(k.ns, other.ns); c != 0 {
+			return c, nil
+		}
+
+	case groupPath:
+		if c := strings.Compare(k.text, other.text); c != 0 {
+			return c, nil
+		}
+
+		// equivalent paths are equal, even if they are spelled differently
+		return 0, nil
+
+	case groupList, groupVector:
+		for i := 0; i < len(k.items) && i < len(other.items); i++ {
+			if c, err := k.items[i].Compare(other.items[i]); err != nil || c != 0 {
+				return c, err
+			}
+		}
+
+		return compI64(int64(len(k.items)), int64(len(other.items))), nil
+
+	case groupOther:
+		if k.which != other.which {
+			return compI64(int64(k.which), int64(other.which)), nil
+		}
+	}
+
+	return strings.Compare(k.text, other.text), nil
+}
+
+func (k sortKey) compareNumber(other sortKey) (int, error) {
+	switch {
+	case k.nan || other.nan:
+		return compBool(k.nan, other.nan), nil
+
+	case k.i != 0 || other.i != 0:
+		// at least one number is infinite
+		return compI64(k.i, other.i), nil
+
+	}
+
+	c, err := k.v.(Numerical).Comp(other.v)
+	if errors.Is(err, ErrIncomparableTypes) {
+		err = ComparisonError{A: k.v, B: other.v}
+	}
+
+	return c, err
+}
+
+// infOrNaN returns the sign of n if it is infinite, and 0 otherwise, and whether n
+// is not a number.
+func infOrNaN(n Numerical) (inf int64, nan bool) {
+	switch v := n.(type) {
+	case Float64:
+		f := v.Float64()
+		if math.IsInf(f, 0) {
+			inf = int64(math.Copysign(1, f))
+		}
+		return inf, math.IsNaN(f)
+
+	case BigFloat:
+		if v.f.IsInf() {
+			inf = int64(v.f.Sign())
+		}
+	}
+
+	return
+}
+
+// splitName splits a keyword or symbol into its namespace, if any, and its name.
+// The namespace precedes the last '/', so that the symbol '/' has no namespace.
+func splitName(s string) (ns, name string) {
+	if i := strings.LastIndexByte(s, '/'); i > 0 && i < len(s)-1 {
+		return s[:i], s[i+1:]
+	}
+
+	return "", s
+}
+
+// pathKey joins the segments of a path with a byte that orders before any other, so
+// that comparing keys compares paths segment by segment.
+func pathKey(p Path) (string, error) {
+	parts, err := p.Parts()
+	return strings.Join(parts, "\x00"), err
+}
+
+func compBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
+
+// renderOrType renders v on one line, within the default limits, for use in errors.
+func renderOrType(v ww.Any) string {
+	if v == nil {
+		return "nil"
+	}
+
+	if s, err := (Printer{Limits: DefaultLimits}).Render(v); err == nil {
+		return s
+	}
+
+	return v.Value().Which().String()
+}
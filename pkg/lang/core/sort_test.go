@@ -0,0 +1,243 @@
+package core_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+func TestSort(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		desc     string
+		in, want []ww.Any
+	}{
+		{"Empty", nil, nil},
+		{"Numbers",
+			[]ww.Any{mustInt(3), mustFloat(1.5), mustFrac(1, 2), mustBigInt(2)},
+			[]ww.Any{mustFrac(1, 2), mustFloat(1.5), mustBigInt(2), mustInt(3)}},
+		{"Strings",
+			[]ww.Any{mustString("b"), mustString("ab"), mustString("a")},
+			[]ww.Any{mustString("a"), mustString("ab"), mustString("b")}},
+		{"Keywords",
+			[]ww.Any{mustKeyword("zz"), mustKeyword("a")},
+			[]ww.Any{mustKeyword("a"), mustKeyword("zz")}},
+		{"Chars",
+			[]ww.Any{mustChar('c'), mustChar('a'), mustChar('b')},
+			[]ww.Any{mustChar('a'), mustChar('b'), mustChar('c')}},
+		{"Bools",
+			[]ww.Any{core.True, core.False},
+			[]ww.Any{core.False, core.True}},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			v, err := core.Sort(mustVector(tt.in...))
+			require.NoError(t, err)
+			assertEq(t, mustVector(tt.want...), v)
+		})
+	}
+
+	t.Run("Stable", func(t *testing.T) {
+		t.Parallel()
+
+		// sort pairs by their first element; ties must preserve input order
+		items := make([]ww.Any, 100)
+		for i := range items {
+			items[i] = mustVector(mustInt(i%3), mustInt(i))
+		}
+
+		key := func(item ww.Any) (ww.Any, error) { return item.(core.Vector).EntryAt(0) }
+
+		v, err := core.SortBy(mustVector(items...), key, nil)
+		require.NoError(t, err)
+
+		prev := [2]int64{-1, -1}
+		for i := 0; i < len(items); i++ {
+			item, err := v.EntryAt(i)
+			require.NoError(t, err)
+
+			k, err := item.(core.Vector).EntryAt(0)
+			require.NoError(t, err)
+			n, err := item.(core.Vector).EntryAt(1)
+			require.NoError(t, err)
+
+			cur := [2]int64{k.(core.Int64).Int64(), n.(core.Int64).Int64()}
+			if cur[0] == prev[0] {
+				assert.Less(t, prev[1], cur[1], "sort is not stable")
+			} else {
+				assert.Less(t, prev[0], cur[0])
+			}
+			prev = cur
+		}
+	})
+
+	t.Run("Comparator", func(t *testing.T) {
+		t.Parallel()
+
+		desc := func(a, b ww.Any) (int, error) { return core.Compare(b, a) }
+
+		v, err := core.SortBy(mustList(mustInt(1), mustInt(3), mustInt(2)), nil, desc)
+		require.NoError(t, err)
+		assertEq(t, mustVector(mustInt(3), mustInt(2), mustInt(1)), v)
+	})
+
+	t.Run("Heterogeneous", func(t *testing.T) {
+		t.Parallel()
+
+		v, err := core.Sort(mustVector(mustString("one"), mustInt(1), core.Nil{}, mustKeyword("a")))
+		require.NoError(t, err)
+		assertEq(t, mustVector(core.Nil{}, mustInt(1), mustString("one"), mustKeyword("a")), v)
+	})
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	// each value precedes the next
+	ordered := []ww.Any{
+		core.Nil{},
+		core.False,
+		core.True,
+		mustFloat(math.Inf(-1)),
+		mustBigInt(-5),
+		mustFrac(-1, 2),
+		mustInt(0),
+		mustBigFloat(0.25),
+		mustFloat(1.5),
+		mustInt(2),
+		mustFloat(math.Inf(1)),
+		mustFloat(math.NaN()),
+		mustChar('a'),
+		mustChar('b'),
+		mustString(""),
+		mustString("a"),
+		mustString("ab"),
+		mustString("b"),
+		mustString("é"),
+		mustKeyword("b"),
+		mustKeyword("a/a"),
+		mustKeyword("a/b"),
+		mustKeyword("b/a"),
+		mustSymbol("a"),
+		mustSymbol("ns/a"),
+		mustPath("/"),
+		mustPath("/a"),
+		mustPath("/a/b"),
+		mustPath("/a-b"),
+		mustList(mustInt(1)),
+		mustList(mustInt(1), mustInt(0)),
+		mustList(mustInt(2)),
+		mustVector(),
+		mustVector(mustInt(1)),
+		mustVector(mustInt(1), core.Nil{}),
+		mustVector(mustString("a")),
+	}
+
+	for i, a := range ordered {
+		for j, b := range ordered {
+			c, err := core.Compare(a, b)
+			require.NoError(t, err)
+			assert.Equal(t, compInt(i, j), sign(c),
+				"compare %s with %s", mustRender(a), mustRender(b))
+		}
+	}
+
+	t.Run("Equal", func(t *testing.T) {
+		t.Parallel()
+
+		for _, pair := range [][2]ww.Any{
+			{core.Nil{}, core.EmptyList}, // the empty list's value is nil
+			{mustInt(2), mustFloat(2)},
+			{mustFrac(1, 2), mustBigFloat(0.5)},
+			{mustFloat(math.Inf(1)), mustBigFloat(math.Inf(1))},
+			{mustFloat(math.NaN()), mustFloat(math.NaN())},
+			{mustVector(mustInt(1)), mustVector(mustFloat(1))},
+			{mustPath("/a/b"), mustPath("/a/b/")},
+		} {
+			c, err := core.Compare(pair[0], pair[1])
+			require.NoError(t, err)
+			assert.Zero(t, c, "compare %s with %s", mustRender(pair[0]), mustRender(pair[1]))
+		}
+	})
+
+	t.Run("Properties", func(t *testing.T) {
+		t.Parallel()
+
+		// any three values drawn from ordered, plus some that compare equal
+		values := append(ordered, mustInt(-5), mustFloat(0), mustList(mustFloat(2)))
+		pick := func(i uint8) ww.Any { return values[int(i)%len(values)] }
+
+		antisymmetric := func(i, j uint8) bool {
+			a, b := pick(i), pick(j)
+			return sign(mustCompare(t, a, b)) == -sign(mustCompare(t, b, a))
+		}
+
+		transitive := func(i, j, k uint8) bool {
+			a, b, c := pick(i), pick(j), pick(k)
+			if mustCompare(t, a, b) <= 0 && mustCompare(t, b, c) <= 0 {
+				return mustCompare(t, a, c) <= 0
+			}
+			return true
+		}
+
+		require.NoError(t, quick.Check(antisymmetric, &quick.Config{MaxCount: 1000}))
+		require.NoError(t, quick.Check(transitive, &quick.Config{MaxCount: 5000}))
+	})
+}
+
+func mustCompare(t *testing.T, a, b ww.Any) int {
+	c, err := core.Compare(a, b)
+	require.NoError(t, err)
+	return c
+}
+
+func mustPath(s string) core.Path {
+	p, err := core.NewPath(capnp.SingleSegment(nil), s)
+	if err != nil {
+		panic(err)
+	}
+
+	return p
+}
+
+func compInt(a, b int) int { return sign(a - b) }
+
+func sign(i int) int {
+	switch {
+	case i < 0:
+		return -1
+	case i > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func BenchmarkSort(b *testing.B) {
+	rng := rand.New(rand.NewSource(42))
+
+	items := make([]ww.Any, 50000)
+	for i := range items {
+		items[i] = mustInt(rng.Int())
+	}
+	v := mustVector(items...)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := core.Sort(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
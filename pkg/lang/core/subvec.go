@@ -0,0 +1,210 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+var _ Vector = (*SubVector)(nil)
+
+// SubVector is a view over the range [start, end) of a parent vector.  It shares
+// structure with its parent, so creating a subvector is O(1) and element access is
+// as fast as it is for the parent.
+//
+// Subvectors are materialized into a persistent vector the first time their memory
+// value is requested, e.g. when they are serialized.
+type SubVector struct {
+	v          Vector
+	start, end int
+	lazy       *lazyValue
+}
+
+// NewSubVector returns the subvector of v in the range [start, end).  It returns
+// ErrIndexOutOfBounds if the range does not lie within v.
+func NewSubVector(v Vector, start, end int) (Vector, error) {
+	cnt, err := v.Count()
+	if err != nil {
+		return nil, err
+	}
+
+	if start < 0 || end < start || end > cnt {
+		return nil, Error{
+			Cause:   ErrIndexOutOfBounds,
+			Message: fmt.Sprintf("range [%d, %d), count %d", start, end, cnt),
+		}
+	}
+
+	// avoid nesting views
+	if sv, ok := v.(SubVector); ok {
+		v, start, end = sv.v, sv.start+start, sv.start+end
+	}
+
+	return newSubVector(v, start, end), nil
+}
+
+func newSubVector(v Vector, start, end int) Vector {
+	if start == end {
+		return EmptyVector
+	}
+
+	return SubVector{v: v, start: start, end: end, lazy: new(lazyValue)}
+}
+
+// Value returns the memory value.  Because memory values cannot share structure
+// with their parent, the subvector is copied into a new vector on first call.  If the
+// parent cannot be read, Value returns an empty mem.Any; use Materialize to obtain
+// the error.
+func (sv SubVector) Value() mem.Any {
+	any, _ := sv.Materialize()
+	return any
+}
+
+// Materialize copies the subvector into a new vector, returning its memory value.
+// The result is cached.
+func (sv SubVector) Materialize() (mem.Any, error) {
+	return sv.lazy.Get(func() (mem.Any, error) {
+		items, err := sv.items()
+		if err != nil {
+			return mem.Any{}, err
+		}
+
+		v, err := NewVector(capnp.SingleSegment(nil), items...)
+		if err != nil {
+			return mem.Any{}, err
+		}
+
+		return v.Value(), nil
+	})
+}
+
+// Count returns the number of elements in the subvector.
+func (sv SubVector) Count() (int, error) { return sv.end - sv.start, nil }
+
+// Invoke is equivalent to `EntryAt`.
+func (sv SubVector) Invoke(args ...ww.Any) (ww.Any, error) {
+	return invokeVector(sv, args)
+}
+
+// Render the subvector in a human-readable format.
+func (sv SubVector) Render() (string, error) {
+	items, err := sv.items()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteRune('[')
+
+	for i, item := range items {
+		s, err := Render(item)
+		if err != nil {
+			return "", err
+		}
+
+		if i > 0 {
+			b.WriteRune(' ')
+		}
+
+		b.WriteString(s)
+	}
+
+	b.WriteRune(']')
+	return b.String(), nil
+}
+
+// EntryAt returns the item at index i of the subvector.
+func (sv SubVector) EntryAt(i int) (ww.Any, error) {
+	if i < 0 || i >= sv.end-sv.start {
+		return nil, ErrIndexOutOfBounds
+	}
+
+	return sv.v.EntryAt(sv.start + i)
+}
+
+// Assoc returns a new vector with the value at given index updated.  If i is equal
+// to the count, the value is appended.
+func (sv SubVector) Assoc(i int, item ww.Any) (Vector, error) {
+	if i < 0 || i > sv.end-sv.start {
+		return nil, ErrIndexOutOfBounds
+	}
+
+	v, err := sv.v.Assoc(sv.start+i, item)
+	if err != nil {
+		return nil, err
+	}
+
+	end := sv.end
+	if i == sv.end-sv.start {
+		end++
+	}
+
+	return newSubVector(v, sv.start, end), nil
+}
+
+// Conj returns a new vector with items appended.  Items that fall within the
+// parent's range overwrite the parent's entries; the remainder are conjoined onto
+// the parent in a single batch.
+func (sv SubVector) Conj(items ...ww.Any) (Container, error) {
+	cnt, err := sv.v.Count()
+	if err != nil {
+		return nil, err
+	}
+
+	v, end := sv.v, sv.end
+	for ; end < cnt && len(items) > 0; end++ {
+		if v, err = v.Assoc(end, items[0]); err != nil {
+			return nil, err
+		}
+
+		items = items[1:]
+	}
+
+	if len(items) > 0 {
+		c, err := v.Conj(items...)
+		if err != nil {
+			return nil, err
+		}
+
+		v, end = c.(Vector), end+len(items)
+	}
+
+	return newSubVector(v, sv.start, end), nil
+}
+
+// Cons appends item to the vector.
+func (sv SubVector) Cons(item ww.Any) (Vector, error) {
+	return sv.Assoc(sv.end-sv.start, item)
+}
+
+// Pop returns a new vector without the last item.
+func (sv SubVector) Pop() (Vector, error) {
+	return newSubVector(sv.v, sv.start, sv.end-1), nil
+}
+
+// Seq returns the subvector's items as a sequence.
+func (sv SubVector) Seq() (Seq, error) {
+	items, err := sv.items()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewList(capnp.SingleSegment(nil), items...)
+}
+
+func (sv SubVector) items() ([]ww.Any, error) {
+	items := make([]ww.Any, sv.end-sv.start)
+	for i := range items {
+		item, err := sv.v.EntryAt(sv.start + i)
+		if err != nil {
+			return nil, err
+		}
+
+		items[i] = item
+	}
+
+	return items, nil
+}
@@ -162,7 +162,7 @@ func (EmptyPersistentVector) conj(a capnp.Arena, items []ww.Any) (Vector, error)
 	}
 
 	for i, any := range items[:width] {
-		if err = tail.Set(i, any.Value()); err != nil {
+		if err = setAny(tail, i, any); err != nil {
 			return nil, err
 		}
 	}
@@ -222,7 +222,7 @@ func newShallowPersistentVector(a capnp.Arena, items ...ww.Any) (ShallowPersiste
 	}
 
 	for i, item := range items {
-		if err = tail.Set(i, item.Value()); err != nil {
+		if err = setAny(tail, i, item); err != nil {
 			break
 		}
 	}
@@ -356,8 +356,12 @@ func (v ShallowPersistentVector) conj(items []ww.Any) (Vector, error) {
 		return newShallowPersistentVector(capnp.SingleSegment(nil), ts...)
 	}
 
-	// deep vector is needed
-	newtail, err := cloneTail(capnp.SingleSegment(nil), tail, cnt)
+	// deep vector is needed.  The new tail is copied into the vector's message, so
+	// it is built in a scratch arena.
+	arena := memutil.NewScratch(tailSize)
+	defer arena.Release()
+
+	newtail, err := cloneTail(arena, tail, cnt)
 	if err != nil {
 		return nil, err
 	}
@@ -365,7 +369,7 @@ func (v ShallowPersistentVector) conj(items []ww.Any) (Vector, error) {
 	if cnt < width {
 		offset := width - cnt // number of free slots in tail
 		for i := 0; i < offset; i++ {
-			if err = newtail.Set(cnt+i, items[i].Value()); err != nil {
+			if err = setAny(newtail, cnt+i, items[i]); err != nil {
 				return nil, err
 			}
 		}
@@ -424,7 +428,7 @@ func (v ShallowPersistentVector) shallowCons(vec mem.Vector, cnt int, item ww.An
 		}
 	}
 
-	return ShallowPersistentVector{any}, newTail.Set(cnt, item.Value())
+	return ShallowPersistentVector{any}, setAny(newTail, cnt, item)
 }
 
 func (v ShallowPersistentVector) deepCons(vec mem.Vector, any ww.Any) (DeepPersistentVector, error) {
@@ -462,7 +466,7 @@ func (v ShallowPersistentVector) update(vec mem.Vector, cnt, idx int, item ww.An
 
 	for i := 0; i < int(cnt); i++ {
 		if i == idx {
-			err = newTail.Set(i, item.Value())
+			err = setAny(newTail, i, item)
 		} else {
 			err = newTail.Set(i, tail.At(i))
 		}
@@ -632,21 +636,79 @@ func (v DeepPersistentVector) count() (vec mem.Vector, cnt int, err error) {
 // Conj returns a new vector with items appended.
 func (v DeepPersistentVector) Conj(items ...ww.Any) (Container, error) { return v.conj(items) }
 
+// conj appends items in tail-sized batches.  Each batch fills the free slots
+// in the tail with a single copy, so a persistent copy of the vector is made
+// once per batch rather than once per item.  A full tail is pushed into the
+// trie by cons.
 func (v DeepPersistentVector) conj(items []ww.Any) (DeepPersistentVector, error) {
-	for _, any := range items {
+	for len(items) > 0 {
 		vec, cnt, err := v.count()
 		if err != nil {
 			return DeepPersistentVector{}, err
 		}
 
-		if v, err = v.cons(vec, cnt, any); err != nil {
+		free := width - (cnt - vectorTailoff(cnt))
+		if free == 0 {
+			if v, err = v.cons(vec, cnt, items[0]); err != nil {
+				return DeepPersistentVector{}, err
+			}
+
+			items = items[1:]
+			continue
+		}
+
+		if free > len(items) {
+			free = len(items)
+		}
+
+		if v, err = v.fillTail(vec, cnt, items[:free]); err != nil {
 			return DeepPersistentVector{}, err
 		}
+
+		items = items[free:]
 	}
 
 	return v, nil
 }
 
+// fillTail appends items to the tail.  The caller MUST ensure the tail has
+// room for all items.
+func (v DeepPersistentVector) fillTail(vec mem.Vector, cnt int, items []ww.Any) (_ DeepPersistentVector, err error) {
+	var root mem.Vector_Node
+	if root, err = vec.Root(); err != nil {
+		return
+	}
+
+	var tail mem.Any_List
+	if tail, err = vec.Tail(); err != nil {
+		return
+	}
+
+	var newtail mem.Any_List
+	if newtail, err = newVectorValueList(capnp.SingleSegment(nil)); err != nil {
+		return
+	}
+
+	taillen := cnt - vectorTailoff(cnt)
+	for i := 0; i < taillen; i++ {
+		if err = newtail.Set(i, tail.At(i)); err != nil {
+			return
+		}
+	}
+
+	for i, any := range items {
+		if err = setAny(newtail, taillen+i, any); err != nil {
+			return
+		}
+	}
+
+	return newVector(capnp.SingleSegment(nil),
+		cnt+len(items),
+		int(vec.Shift()),
+		root,
+		newtail)
+}
+
 // EntryAt returns the item at given index. Returns error if the index
 // is out of range.
 func (v DeepPersistentVector) EntryAt(i int) (ww.Any, error) {
@@ -781,11 +843,16 @@ func (DeepPersistentVector) update(vec mem.Vector, cnt, i int, any ww.Any) (Vect
 			}
 		}
 
-		if err = tail.Set(i&mask, any.Value()); err != nil {
+		if err = setAny(tail, i&mask, any); err != nil {
 			return nil, err
 		}
 	} else {
-		if root, err = apiVectorAssoc(int(vec.Shift()), root, i, any.Value()); err != nil {
+		var val mem.Any
+		if val, err = valueOf(any); err != nil {
+			return nil, err
+		}
+
+		if root, err = apiVectorAssoc(int(vec.Shift()), root, i, val); err != nil {
 			return nil, err
 		}
 	}
@@ -838,7 +905,7 @@ func (v DeepPersistentVector) cons(vec mem.Vector, cnt int, any ww.Any) (_ DeepP
 		}
 
 		// append the new value to the new tail
-		if err = newtail.Set(taillen, any.Value()); err != nil {
+		if err = setAny(newtail, taillen, any); err != nil {
 			return
 		}
 
@@ -901,7 +968,7 @@ func (v DeepPersistentVector) cons(vec mem.Vector, cnt int, any ww.Any) (_ DeepP
 	}
 
 	// ... and insert new value into the new tail.
-	if err = newtail.Set(0, any.Value()); err != nil {
+	if err = setAny(newtail, 0, any); err != nil {
 		return
 	}
 
@@ -987,7 +1054,11 @@ func (v DeepPersistentVector) pop(vec mem.Vector) (_ Vector, err error) {
 			return
 		}
 
-		newtail, err = cloneTail(capnp.SingleSegment(nil), tail, taillen-1)
+		// copied into the new vector's message
+		arena := memutil.NewScratch(tailSize)
+		defer arena.Release()
+
+		newtail, err = cloneTail(arena, tail, taillen-1)
 		if err != nil {
 			return
 		}
@@ -1172,12 +1243,18 @@ func (cs chunkedSeq) Count() (cnt int, err error) {
 	return
 }
 
+// First returns the first item, or nil if the sequence is empty.
 func (cs chunkedSeq) First() (ww.Any, error) {
 	seq, err := cs.Any.VectorSeq()
 	if err != nil {
 		return nil, err
 	}
 
+	// the empty vector's sequence has no nodes
+	if cnt, err := cs.Count(); err != nil || cnt == 0 {
+		return nil, err
+	}
+
 	node, err := cs.node(seq)
 	if err != nil {
 		return nil, err
@@ -1445,6 +1522,9 @@ func tailSlice(cnt int, tail mem.Any_List) []ww.Any {
 	return items
 }
 
+// tailSize is a size hint for scratch arenas holding a cloned tail.
+const tailSize = 1024
+
 func cloneTail(a capnp.Arena, tail mem.Any_List, lim int) (newtail mem.Any_List, err error) {
 	var seg *capnp.Segment
 	if _, seg, err = capnp.NewMessage(a); err != nil {
@@ -57,6 +57,22 @@ func TestEmptyVector(t *testing.T) {
 		assert.Zero(t, cnt)
 	})
 
+	t.Run("Seq", func(t *testing.T) {
+		t.Parallel()
+
+		seq, err := core.EmptyVector.Seq()
+		require.NoError(t, err)
+
+		first, err := seq.First()
+		require.NoError(t, err)
+		assert.Nil(t, first)
+
+		require.NoError(t, core.ForEach(seq, func(ww.Any) (bool, error) {
+			t.Error("empty vector yielded an item")
+			return true, nil
+		}))
+	})
+
 	t.Run("Count", func(t *testing.T) {
 		t.Parallel()
 
@@ -825,6 +841,148 @@ func withParallelIndex(t *testing.T, i int, f func(*testing.T, int)) {
 	})
 }
 
+func TestSubVector(t *testing.T) {
+	t.Parallel()
+
+	const count = 100
+
+	var err error
+	var v core.Vector = core.EmptyVector
+	for i := 0; i < count; i++ {
+		v, err = v.Cons(mustInt(i))
+		require.NoError(t, err)
+	}
+
+	t.Run("OutOfBounds", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := core.NewSubVector(v, 10, count+1)
+		assert.True(t, errors.Is(err, core.ErrIndexOutOfBounds), "unexpected error %v", err)
+
+		_, err = core.NewSubVector(v, 10, 9)
+		assert.True(t, errors.Is(err, core.ErrIndexOutOfBounds), "unexpected error %v", err)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		t.Parallel()
+
+		sv, err := core.NewSubVector(v, 10, 10)
+		require.NoError(t, err)
+		assert.IsType(t, core.EmptyPersistentVector{}, sv)
+	})
+
+	t.Run("EntryAt", func(t *testing.T) {
+		t.Parallel()
+
+		sv, err := core.NewSubVector(v, 40, 80)
+		require.NoError(t, err)
+
+		cnt, err := sv.Count()
+		require.NoError(t, err)
+		assert.Equal(t, 40, cnt)
+
+		for i := 0; i < cnt; i++ {
+			item, err := sv.EntryAt(i)
+			require.NoError(t, err)
+			assert.Equal(t, int64(40+i), item.(core.Int64).Int64())
+		}
+
+		_, err = sv.EntryAt(cnt)
+		assert.True(t, errors.Is(err, core.ErrIndexOutOfBounds), "unexpected error %v", err)
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		t.Parallel()
+
+		sv, err := core.NewSubVector(v, 10, 90)
+		require.NoError(t, err)
+
+		sv, err = core.NewSubVector(sv, 10, 20)
+		require.NoError(t, err)
+
+		item, err := sv.EntryAt(0)
+		require.NoError(t, err)
+		assert.Equal(t, int64(20), item.(core.Int64).Int64())
+	})
+
+	t.Run("ConsPop", func(t *testing.T) {
+		t.Parallel()
+
+		sv, err := core.NewSubVector(v, 0, 2)
+		require.NoError(t, err)
+
+		sv, err = sv.Cons(mustInt(-1))
+		require.NoError(t, err)
+
+		item, err := sv.EntryAt(2)
+		require.NoError(t, err)
+		assert.Equal(t, int64(-1), item.(core.Int64).Int64())
+
+		// parent is unchanged
+		item, err = v.EntryAt(2)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), item.(core.Int64).Int64())
+
+		for i := 0; i < 3; i++ {
+			sv, err = sv.Pop()
+			require.NoError(t, err)
+		}
+
+		assert.IsType(t, core.EmptyPersistentVector{}, sv)
+	})
+
+	t.Run("Conj", func(t *testing.T) {
+		t.Parallel()
+
+		sv, err := core.NewSubVector(v, 90, 95)
+		require.NoError(t, err)
+
+		// the first 5 items overwrite the parent's tail; the rest are appended
+		items := valueRange(50)
+		ctr, err := sv.Conj(items...)
+		require.NoError(t, err)
+
+		cnt, err := ctr.Count()
+		require.NoError(t, err)
+		assert.Equal(t, 55, cnt)
+
+		res := ctr.(core.Vector)
+		for i := 0; i < cnt; i++ {
+			item, err := res.EntryAt(i)
+			require.NoError(t, err)
+
+			want := int64(90 + i)
+			if i >= 5 {
+				want = int64(i - 5)
+			}
+
+			assert.Equal(t, want, item.(core.Int64).Int64())
+		}
+
+		// parent is unchanged
+		item, err := v.EntryAt(95)
+		require.NoError(t, err)
+		assert.Equal(t, int64(95), item.(core.Int64).Int64())
+	})
+
+	t.Run("Value", func(t *testing.T) {
+		t.Parallel()
+
+		sv, err := core.NewSubVector(v, 50, 53)
+		require.NoError(t, err)
+
+		got, err := core.AsAny(sv.Value())
+		require.NoError(t, err)
+
+		want, err := core.NewVector(capnp.SingleSegment(nil), mustInt(50), mustInt(51), mustInt(52))
+		require.NoError(t, err)
+
+		eq, err := core.Eq(want, got)
+		require.NoError(t, err)
+		assert.True(t, eq)
+	})
+}
+
 func assertVectorTypeOK(t *testing.T, v core.Vector) bool {
 	cnt, err := v.Count()
 	require.NoError(t, err)
@@ -841,3 +999,25 @@ func assertVectorTypeOK(t *testing.T, v core.Vector) bool {
 
 	}
 }
+
+func BenchmarkVector(b *testing.B) {
+	const count = 4096
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		var err error
+		var v core.Vector = core.EmptyVector
+		for j := 0; j < count; j++ {
+			if v, err = v.Cons(mustInt(j)); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		for j := 0; j < count; j++ {
+			if v, err = v.Pop(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package lang
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	"github.com/wetware/ww/pkg/lang/reader"
+)
+
+const (
+	// DepsFile is the name of the module manifest in a source directory.
+	DepsFile = "ww.deps"
+
+	// SourceExt is the extension of ww source files.  It is appended to import names
+	// that do not have one.
+	SourceExt = ".ww"
+)
+
+var (
+	// ErrManifest is returned when a module manifest is malformed, or when one of its
+	// entries cannot be resolved.
+	ErrManifest = errors.New("invalid module manifest")
+
+	// ErrImportCycle is returned when a module imports itself, directly or indirectly.
+	ErrImportCycle = errors.New("import cycle")
+)
+
+// Manifest maps logical module names to source files, so that scripts can write
+// (import util) rather than spelling out the path.  It is read from a ww.deps file
+// containing a single vector of name/source pairs:
+//
+//	[util          "lib/util.ww"
+//	 local-helpers "lib/helpers.ww"]
+//
+// Relative sources are resolved against the directory containing the manifest.
+type Manifest struct {
+	path    string
+	sources map[string]string
+}
+
+// ReadManifest reads the manifest at path.  A missing file yields an empty manifest.
+func ReadManifest(path string) (Manifest, error) {
+	m := Manifest{path: path, sources: make(map[string]string)}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	} else if err != nil {
+		return m, err
+	}
+	defer f.Close()
+
+	rd, err := reader.New(f)
+	if err != nil {
+		return m, err
+	}
+
+	forms, err := rd.All()
+	if err != nil {
+		return m, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if len(forms) != 1 {
+		return m, m.errorf("expected 1 form, got %d", len(forms))
+	}
+
+	v, ok := forms[0].(core.Vector)
+	if !ok {
+		return m, m.errorf("manifest must be vector")
+	}
+
+	cnt, err := v.Count()
+	if err != nil {
+		return m, err
+	}
+
+	if cnt%2 != 0 {
+		return m, m.errorf("odd number of forms in manifest")
+	}
+
+	for i := 0; i < cnt; i += 2 {
+		item, err := v.EntryAt(i)
+		if err != nil {
+			return m, err
+		}
+
+		name, err := symbolName(item)
+		if err != nil {
+			return m, m.errorf("entry %d: name must be symbol", i/2)
+		}
+
+		if item, err = v.EntryAt(i + 1); err != nil {
+			return m, err
+		}
+
+		src, err := sourceString(item)
+		if err != nil {
+			return m, m.errorf("%s: source must be string", name)
+		}
+
+		if _, dup := m.sources[name]; dup {
+			return m, m.errorf("%s: duplicate entry", name)
+		}
+
+		if !filepath.IsAbs(src) {
+			src = filepath.Join(filepath.Dir(path), src)
+		}
+
+		m.sources[name] = src
+	}
+
+	return m, nil
+}
+
+// Resolve returns the source file of the named module.  The boolean is false if the
+// manifest has no entry for the name.  An entry whose source does not exist is an
+// error naming the entry.
+func (m Manifest) Resolve(name string) (string, bool, error) {
+	src, ok := m.sources[name]
+	if !ok {
+		return "", false, nil
+	}
+
+	if _, err := os.Stat(src); err != nil {
+		return "", true, m.errorf("%s: %v", name, err)
+	}
+
+	return src, true, nil
+}
+
+func (m Manifest) errorf(format string, args ...interface{}) error {
+	return core.Error{
+		Cause:   ErrManifest,
+		Message: fmt.Sprintf("%s: %s", m.path, fmt.Sprintf(format, args...)),
+	}
+}
+
+func sourceString(any ww.Any) (string, error) {
+	if any == nil || any.Value().Which() != mem.Any_Which_str {
+		return "", errors.New("not a string")
+	}
+
+	return any.Value().Str()
+}
@@ -0,0 +1,182 @@
+package lang
+
+import (
+	"errors"
+	"sort"
+
+	score "github.com/spy16/slurp/core"
+	capnp "zombiezen.com/go/capnproto2"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+)
+
+// Binding describes a name bound in an env.  The value and its metadata are resolved
+// when requested, so that enumerating an env does not copy the values bound in it.
+type Binding struct {
+	Name string
+
+	// Frame is the name of the env frame in which the name is bound, e.g. "<main>"
+	// for the root env.
+	Frame string
+
+	env core.Env
+}
+
+// Value resolves the bound value.
+func (b Binding) Value() (ww.Any, error) {
+	v, err := b.env.Resolve(b.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(ww.Any), nil
+}
+
+// Type returns the name of the value's type, as returned by the 'type' builtin.
+// Native functions are reported as "fn", and macros as "macro".
+func (b Binding) Type() (string, error) {
+	v, err := b.Value()
+	if err != nil {
+		return "", err
+	}
+
+	switch f := v.(type) {
+	case *funcWrapper:
+		return "fn", nil
+
+	case core.Fn:
+		if f.Macro() {
+			return "macro", nil
+		}
+	}
+
+	return v.Value().Which().String(), nil
+}
+
+// Doc returns the docstring from the var's metadata, or "" if it has none.
+func (b Binding) Doc() (string, error) {
+	v, err := b.meta("doc")
+	if err != nil || v == nil {
+		return "", err
+	}
+
+	return v.Value().Str()
+}
+
+// Arglists returns the parameter vectors of the bound function, or nil if the value
+// is not a function.  Native functions have no arglists.
+func (b Binding) Arglists() (core.Vector, error) {
+	v, err := b.meta("arglists")
+	if err != nil {
+		return nil, err
+	}
+
+	if as, ok := v.(core.Vector); ok {
+		return as, nil
+	}
+
+	if v, err = b.Value(); err != nil {
+		return nil, err
+	}
+
+	if fn, ok := v.(core.Fn); ok {
+		return fn.Arglists()
+	}
+
+	return nil, nil
+}
+
+// meta returns the value of the key in the var's metadata, or nil.  Metadata is only
+// bound in the root env, by def and defn.
+func (b Binding) meta(key string) (ww.Any, error) {
+	if b.env.Parent() != nil {
+		return nil, nil
+	}
+
+	v, err := b.env.Resolve(metaBinding(b.Name))
+	if errors.Is(err, core.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	meta, ok := v.(core.Vector)
+	if !ok {
+		return nil, nil
+	}
+
+	cnt, err := meta.Count()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i+1 < cnt; i += 2 {
+		k, err := meta.EntryAt(i)
+		if err != nil {
+			return nil, err
+		}
+
+		if kw, err := k.Value().Keyword(); err == nil && kw == key {
+			return meta.EntryAt(i + 1)
+		}
+	}
+
+	return nil, nil
+}
+
+// Bindings returns the names bound in env and its parents, in lexical order.  Names
+// that are shadowed by an inner frame are omitted, as are frames that cannot be
+// enumerated.
+func Bindings(env core.Env) []Binding {
+	return Complete(env, "")
+}
+
+// Complete returns the bindings in env and its parents whose names begin with prefix,
+// in lexical order.  See Bindings.
+func Complete(env core.Env, prefix string) []Binding {
+	var bs []Binding
+	seen := make(map[string]bool)
+
+	for ; env != nil; env = env.Parent() {
+		if e, ok := env.(ctxEnv); ok {
+			env = e.Env
+		}
+
+		frame, ok := env.(core.Enumerable)
+		if !ok {
+			continue
+		}
+
+		for _, name := range frame.Complete(prefix) {
+			if !seen[name] && !isMetaBinding(name) {
+				seen[name] = true
+				bs = append(bs, Binding{Name: name, Frame: env.Name(), env: env})
+			}
+		}
+	}
+
+	sort.Slice(bs, func(i, j int) bool { return bs[i].Name < bs[j].Name })
+	return bs
+}
+
+// completions returns the (completions prefix) builtin, which returns a vector of
+// the symbols bound in the root env that begin with prefix.
+func completions(env core.Env) bindFunc {
+	return function("completions", "__completions__", func(prefix core.String) (core.Vector, error) {
+		p, err := prefix.Value().Str()
+		if err != nil {
+			return nil, err
+		}
+
+		bs := Complete(score.Root(env), p)
+		syms := make([]ww.Any, len(bs))
+		for i, b := range bs {
+			if syms[i], err = core.NewSymbol(capnp.SingleSegment(nil), b.Name); err != nil {
+				return nil, err
+			}
+		}
+
+		return core.NewVector(capnp.SingleSegment(nil), syms...)
+	})
+}
@@ -0,0 +1,99 @@
+package lang_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang"
+	"github.com/wetware/ww/pkg/lang/core"
+)
+
+func TestBindings(t *testing.T) {
+	t.Parallel()
+
+	vm, err := lang.New(nil)
+	require.NoError(t, err)
+
+	for _, src := range []string{
+		`(defn greet "Say hello." ([] (greet "world")) ([name] (str "hello " name)))`,
+		`(def greeting "hello")`,
+		`(def gm (macro gm [x] x))`,
+	} {
+		_, err := vm.Eval(readAll(t, src)[0])
+		require.NoError(t, err, src)
+	}
+
+	var names []string
+	for _, b := range lang.Complete(vm.Env(), "gr") {
+		names = append(names, b.Name)
+	}
+	assert.Equal(t, []string{"greet", "greeting"}, names)
+
+	t.Run("Fn", func(t *testing.T) {
+		b := lang.Complete(vm.Env(), "greet")[0]
+		assert.Equal(t, "<main>", b.Frame)
+
+		typ, err := b.Type()
+		require.NoError(t, err)
+		assert.Equal(t, "fn", typ)
+
+		doc, err := b.Doc()
+		require.NoError(t, err)
+		assert.Equal(t, "Say hello.", doc)
+
+		as, err := b.Arglists()
+		require.NoError(t, err)
+		got, err := core.Render(as)
+		require.NoError(t, err)
+		assert.Equal(t, "[[] [name]]", got)
+	})
+
+	t.Run("Value", func(t *testing.T) {
+		b := lang.Complete(vm.Env(), "greeting")[0]
+
+		typ, err := b.Type()
+		require.NoError(t, err)
+		assert.Equal(t, "str", typ)
+
+		doc, err := b.Doc()
+		require.NoError(t, err)
+		assert.Empty(t, doc)
+
+		as, err := b.Arglists()
+		require.NoError(t, err)
+		assert.Nil(t, as)
+	})
+
+	t.Run("Native", func(t *testing.T) {
+		bs := lang.Complete(vm.Env(), "count")
+		require.Len(t, bs, 1)
+
+		typ, err := bs[0].Type()
+		require.NoError(t, err)
+		assert.Equal(t, "fn", typ)
+	})
+
+	t.Run("Macro", func(t *testing.T) {
+		typ, err := lang.Complete(vm.Env(), "gm")[0].Type()
+		require.NoError(t, err)
+		assert.Equal(t, "macro", typ)
+	})
+
+	t.Run("Hidden", func(t *testing.T) {
+		for _, b := range lang.Bindings(vm.Env()) {
+			assert.NotContains(t, b.Name, "<meta", "metadata should not be enumerated")
+		}
+	})
+
+	t.Run("Builtin", func(t *testing.T) {
+		res, err := vm.Eval(readAll(t, `(completions "greet")`)[0])
+		require.NoError(t, err)
+
+		got, err := core.Render(res.(ww.Any))
+		require.NoError(t, err)
+		assert.Equal(t, "[greet greeting]", got)
+	})
+}
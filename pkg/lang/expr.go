@@ -4,31 +4,40 @@ import (
 	"bytes"
 	"context"
 	"errors"
-	"os"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 
 	"github.com/spy16/slurp/builtin"
 	score "github.com/spy16/slurp/core"
 	ww "github.com/wetware/ww/pkg"
 	"github.com/wetware/ww/pkg/lang/core"
 	"github.com/wetware/ww/pkg/lang/reader"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
 	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
-	memutil "github.com/wetware/ww/pkg/util/mem"
 	capnp "zombiezen.com/go/capnproto2"
 )
 
 var (
 	_ core.Expr = (*ConstExpr)(nil)
 	_ core.Expr = (*IfExpr)(nil)
+	_ core.Expr = (*IfLetExpr)(nil)
 	_ core.Expr = (*ResolveExpr)(nil)
 	_ core.Expr = (*DefExpr)(nil)
+	_ core.Expr = (*MetaExpr)(nil)
 	_ core.Expr = (*InvokeExpr)(nil)
 	_ core.Expr = (*PathExpr)(nil)
 	_ core.Expr = (*LocalGoExpr)(nil)
 	_ core.Expr = (*RemoteGoExpr)(nil)
+	_ core.Expr = (*SelectExpr)(nil)
 	_ core.Expr = (*InvokeExpr)(nil)
 	// _ core.Expr = (*)(nil)
 
-	_ core.Invokable = (*PathExpr)(nil)
+	_ core.Invokable   = (*PathExpr)(nil)
+	_ contextInvokable = (*PathExpr)(nil)
 )
 
 type (
@@ -39,6 +48,63 @@ type (
 	QuoteExpr = builtin.QuoteExpr
 )
 
+// metaBinding returns the name under which the metadata for the named var is bound.
+// It cannot be produced by the reader.
+func metaBinding(name string) string { return "<meta " + name + ">" }
+
+func isMetaBinding(name string) bool { return strings.HasPrefix(name, "<meta ") }
+
+// ctxEnv is an env whose evaluation is bound to a context and budget.  Children
+// inherit both, so that they can be retrieved in constant time regardless of call
+// depth.
+type ctxEnv struct {
+	core.Env
+	ctx    context.Context
+	budget *budget
+}
+
+func (env ctxEnv) Child(name string, vars map[string]score.Any) core.Env {
+	return ctxEnv{Env: env.Env.Child(name, vars), ctx: env.ctx, budget: env.budget}
+}
+
+// withContext returns a child env whose evaluation is bound to ctx.  The child keeps
+// the budget of env, if any.
+func withContext(env core.Env, name string, ctx context.Context) core.Env {
+	child := env.Child(name, nil)
+	if e, ok := child.(ctxEnv); ok {
+		e.ctx = ctx
+		return e
+	}
+
+	return ctxEnv{Env: child, ctx: ctx}
+}
+
+// bindContext returns env, with its evaluation bound to ctx.  Unlike withContext, it
+// does not create a child scope.
+func bindContext(env core.Env, ctx context.Context) core.Env {
+	if e, ok := env.(ctxEnv); ok {
+		e.ctx = ctx
+		return e
+	}
+
+	return ctxEnv{Env: env, ctx: ctx}
+}
+
+// contextOf returns the evaluation context bound to env, or context.Background()
+// if none is bound.
+func contextOf(env core.Env) context.Context {
+	if e, ok := env.(ctxEnv); ok {
+		return e.ctx
+	}
+
+	return context.Background()
+}
+
+// contextInvokable is an Invokable that observes the evaluation context.
+type contextInvokable interface {
+	InvokeContext(context.Context, ...ww.Any) (ww.Any, error)
+}
+
 // ConstExpr returns the Const value wrapped inside when evaluated. It has
 // no side-effect on the VM.
 type ConstExpr struct{ Form ww.Any }
@@ -52,6 +118,16 @@ type IfExpr struct{ Test, Then, Else core.Expr }
 // Eval evaluates the then or else expr based on truthiness of the test
 // expr result.
 func (ife IfExpr) Eval(env core.Env) (score.Any, error) {
+	target, err := ife.target(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return target.Eval(env)
+}
+
+// target evaluates the test and returns the branch to evaluate.
+func (ife IfExpr) target(env core.Env) (core.Expr, error) {
 	target := ife.Else
 	if ife.Test != nil {
 		test, err := ife.Test.Eval(env)
@@ -70,11 +146,52 @@ func (ife IfExpr) Eval(env core.Env) (score.Any, error) {
 	}
 
 	if target == nil {
-		return core.Nil{}, nil
+		return ConstExpr{core.Nil{}}, nil
+	}
+	return target, nil
+}
+
+// IfLetExpr represents the (if-let [name value] then else?) form.
+type IfLetExpr struct {
+	Name              string
+	Value, Then, Else core.Expr
+}
+
+// Eval evaluates the then expr with the value bound to name if the value is truthy.
+// Otherwise, it evaluates the else expr without binding the value.
+func (ile IfLetExpr) Eval(env core.Env) (score.Any, error) {
+	target, env, err := ile.target(env)
+	if err != nil {
+		return nil, err
 	}
+
 	return target.Eval(env)
 }
 
+// target evaluates the value and returns the branch to evaluate, along with the
+// env in which to evaluate it.
+func (ile IfLetExpr) target(env core.Env) (core.Expr, core.Env, error) {
+	v, err := ile.Value.Eval(env)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ok, err := core.IsTruthy(v.(ww.Any))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if ok {
+		return ile.Then, env.Child("if-let", map[string]score.Any{ile.Name: v}), nil
+	}
+
+	if ile.Else == nil {
+		return ConstExpr{core.Nil{}}, env, nil
+	}
+
+	return ile.Else, env, nil
+}
+
 // ResolveExpr resolves a symbol from the given environment.
 type ResolveExpr struct{ Symbol core.Symbol }
 
@@ -101,9 +218,12 @@ func (re ResolveExpr) Eval(env core.Env) (v score.Any, err error) {
 	return
 }
 
-// DefExpr represents the (def name value) binding form.
+// DefExpr represents the (def name value) binding form.  The var's metadata is bound
+// alongside the value, and replaces any metadata from a previous definition.  It
+// contains :doc if Doc is set, and :arglists if the value is a function.
 type DefExpr struct {
 	Name  string
+	Doc   string
 	Value core.Expr
 }
 
@@ -120,14 +240,79 @@ func (de DefExpr) Eval(env core.Env) (score.Any, error) {
 		val = core.Nil{}
 	}
 
-	if err := score.Root(env).Bind(de.Name, val); err != nil {
+	root := score.Root(env)
+	if err := root.Bind(de.Name, val); err != nil {
+		return nil, err
+	}
+
+	meta, err := de.meta(val.(ww.Any))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = root.Bind(metaBinding(de.Name), meta); err != nil {
 		return nil, err
 	}
 
 	return core.NewSymbol(capnp.SingleSegment(nil), de.Name)
 }
 
+// meta returns a vector of alternating keys and values.
+func (de DefExpr) meta(val ww.Any) (core.Vector, error) {
+	var items []ww.Any
+
+	if de.Doc != "" {
+		k, err := core.NewKeyword(capnp.SingleSegment(nil), "doc")
+		if err != nil {
+			return nil, err
+		}
+
+		doc, err := core.NewString(capnp.SingleSegment(nil), de.Doc)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, k, doc)
+	}
+
+	if fn, ok := val.(core.Fn); ok {
+		k, err := core.NewKeyword(capnp.SingleSegment(nil), "arglists")
+		if err != nil {
+			return nil, err
+		}
+
+		as, err := fn.Arglists()
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, k, as)
+	}
+
+	return core.NewVector(capnp.SingleSegment(nil), items...)
+}
+
+// MetaExpr represents the (meta name) form.  It evaluates to the metadata of the
+// named var, or nil if the var was not defined with def or defn.
+type MetaExpr struct{ Name string }
+
+// Eval resolves the metadata in the root env.
+func (me MetaExpr) Eval(env core.Env) (score.Any, error) {
+	meta, err := score.Root(env).Resolve(metaBinding(me.Name))
+	if errors.Is(err, core.ErrNotFound) {
+		return core.Nil{}, nil
+	}
+
+	return meta, err
+}
+
 // CallExpr invokes a function body when evaluated.
+//
+// A call to the same function in tail position is not evaluated recursively.
+// Instead, its arguments are rebound and the body is evaluated again, so that
+// self-recursive functions run in constant stack space.  Tail positions are the
+// last expression of the body, and the branches of if, if-let, when and when-not
+// and the last expression of do, when those are themselves in tail position.
 type CallExpr struct {
 	Fn       core.Fn
 	Analyzer core.Analyzer
@@ -136,72 +321,257 @@ type CallExpr struct {
 
 // Eval calls the function.
 func (cex CallExpr) Eval(env core.Env) (score.Any, error) {
-	// Get the call target that corresponds to the number of
-	// arguments supplied.
-	ct, err := cex.Fn.Match(len(cex.Args))
+	args, err := evalArgs(env, cex.Args)
 	if err != nil {
 		return nil, err
 	}
 
-	// Bind evaluated arguments to parameter names to build
-	// a map of local variables.
-	scope := make(map[string]score.Any, len(ct.Param))
-	var vargs []ww.Any
-	for i, arg := range cex.Args {
-		any, err := arg.Eval(env)
-		if err != nil {
+	// Call targets are analyzed once per arity, rather than once per iteration.
+	// Besides being cheaper, this bounds the number of reads from the function's
+	// underlying message, which are subject to a traversal limit.
+	targets := make(map[int]callTarget)
+
+	for {
+		// Abort if the evaluation was canceled or has exhausted its budget.
+		if err = checkStep(env, cex.Fn); err != nil {
 			return nil, err
 		}
 
-		if ct.Variadic && i >= len(ct.Param)-1 {
-			vargs = append(vargs, any.(ww.Any))
-			continue
-		}
+		t, ok := targets[len(args)]
+		if !ok {
+			if t, err = cex.analyzeTarget(env, len(args)); err != nil {
+				return nil, err
+			}
 
-		scope[ct.Param[i]] = any
-	}
+			targets[len(args)] = t
+		}
 
-	if vargs != nil {
-		vs, err := core.NewList(capnp.SingleSegment(nil), vargs...)
+		scope, err := bindParams(cex.Fn, t.CallTarget, args)
 		if err != nil {
 			return nil, err
 		}
 
-		scope[ct.Param[len(ct.Param)-1]] = vs
+		// Derive a child environment and evaluate the function body as a
+		// do expression.  A self-call in tail position returns its arguments
+		// instead of a result; rebind them and loop.
+		res, tail, err := cex.evalTail(t.body, env.Child(t.Name, scope))
+		if err != nil || tail == nil {
+			return res, err
+		}
+
+		args = tail
+	}
+}
+
+type callTarget struct {
+	core.CallTarget
+	body DoExpr
+}
+
+// analyzeTarget gets the call target that corresponds to the number of arguments
+// supplied, and analyzes its body to obtain evaluable expressions.
+func (cex CallExpr) analyzeTarget(env core.Env, nargs int) (callTarget, error) {
+	ct, err := cex.Fn.Match(nargs)
+	if err != nil {
+		return callTarget{}, err
 	}
 
-	// Analyze the call target's body to obtain evaluable expressions.
 	body := make([]core.Expr, len(ct.Body))
 	for i, form := range ct.Body {
 		if body[i], err = cex.Analyzer.Analyze(env, form); err != nil {
+			return callTarget{}, err
+		}
+	}
+
+	return callTarget{CallTarget: ct, body: DoExpr{Exprs: body}}, nil
+}
+
+// evalTail evaluates expr in env.  If expr is a call to cex.Fn in tail position, the
+// call's arguments are evaluated and returned in place of a result.  The returned
+// slice is non-nil iff a tail call was found.
+func (cex CallExpr) evalTail(expr core.Expr, env core.Env) (score.Any, []ww.Any, error) {
+	switch e := expr.(type) {
+	case CallExpr:
+		if sameFn(cex.Fn, e.Fn) {
+			args, err := evalArgs(env, e.Args)
+			return nil, args, err
+		}
+
+	case IfExpr:
+		target, err := e.target(env)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return cex.evalTail(target, env)
+
+	case IfLetExpr:
+		target, env, err := e.target(env)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return cex.evalTail(target, env)
+
+	case DoExpr:
+		if len(e.Exprs) == 0 {
+			break
+		}
+
+		last := len(e.Exprs) - 1
+		for _, expr := range e.Exprs[:last] {
+			if _, err := expr.Eval(env); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		return cex.evalTail(e.Exprs[last], env)
+	}
+
+	res, err := expr.Eval(env)
+	return res, nil, err
+}
+
+func evalArgs(env core.Env, exprs []core.Expr) ([]ww.Any, error) {
+	args := make([]ww.Any, len(exprs))
+	for i, arg := range exprs {
+		any, err := arg.Eval(env)
+		if err != nil {
 			return nil, err
 		}
+
+		args[i] = any.(ww.Any)
+	}
+
+	return args, nil
+}
+
+// bindParams binds arguments to parameter names to build a map of local variables.
+// The rest parameter of a variadic call target is bound to a list of the remaining
+// arguments, which is empty if there are none.  The call target must belong to fn.
+func bindParams(fn core.Fn, ct core.CallTarget, args []ww.Any) (map[string]score.Any, error) {
+	fixed := len(ct.Param)
+	if ct.Variadic {
+		fixed--
+	}
+
+	if ct.Variadic && len(args) < fixed {
+		return nil, fn.ArityError(len(args), fmt.Sprintf("at least %d", fixed))
+	}
+
+	if !ct.Variadic && len(args) != fixed {
+		return nil, fn.ArityError(len(args), fmt.Sprint(fixed))
+	}
+
+	scope := make(map[string]score.Any, len(ct.Param))
+	for i, any := range args[:fixed] {
+		scope[ct.Param[i]] = any
+	}
+
+	if ct.Variadic {
+		var rest core.List = core.EmptyList
+		if len(args) > fixed {
+			vs, err := core.NewList(capnp.SingleSegment(nil), args[fixed:]...)
+			if err != nil {
+				return nil, err
+			}
+
+			rest = vs
+		}
+
+		scope[ct.Param[fixed]] = rest
+	}
+
+	return scope, nil
+}
+
+// sameFn reports whether a and b are the same function.  Functions do not close over
+// their environment, so structurally equal functions are interchangeable.
+func sameFn(a, b core.Fn) bool {
+	if capnp.SamePtr(a.Any.ToPtr(), b.Any.ToPtr()) {
+		return true
 	}
 
-	// Derive a child environment and evaluate the function body as a
-	// do expression.
-	return DoExpr{Exprs: body}.Eval(env.Child(ct.Name, scope))
+	eq, err := capnp.Equal(a.Any.ToPtr(), b.Any.ToPtr())
+	return err == nil && eq
 }
 
-// InvokeExpr performs invocation of target when evaluated.
+// InvokeExpr performs invocation of target when evaluated.  Fn arguments are bound
+// to the caller's environment, so that native targets can invoke them.
 type InvokeExpr struct {
-	Target core.Invokable
-	Args   []core.Expr
+	Analyzer core.Analyzer
+	Target   core.Invokable
+	Args     []core.Expr
 }
 
 // Eval evaluates the target expr and invokes the result if it is an
 // Invokable  Returns error otherwise.
 func (ie InvokeExpr) Eval(env core.Env) (any score.Any, err error) {
+	if err = checkStep(env, ie.Target); err != nil {
+		return
+	}
+
 	args := make([]ww.Any, len(ie.Args))
 	for i, ae := range ie.Args {
 		if any, err = ae.Eval(env); err != nil {
 			return
 		}
 
+		if fn, ok := any.(core.Fn); ok && ie.Analyzer != nil {
+			any = closure{Fn: fn, analyzer: ie.Analyzer, env: env}
+		}
+
 		args[i] = any.(ww.Any)
 	}
 
-	return ie.Target.Invoke(args...)
+	var res ww.Any
+	if t, ok := ie.Target.(contextInvokable); ok {
+		res, err = t.InvokeContext(contextOf(env), args...)
+	} else {
+		res, err = ie.Target.Invoke(args...)
+	}
+
+	if err == nil {
+		err = checkAlloc(env, ie.Target, res, args)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// closure is a Fn bound to the environment in which it was passed as an argument.
+// It allows native functions such as sort-by to call functions defined in the
+// language.
+type closure struct {
+	core.Fn
+	analyzer core.Analyzer
+	env      core.Env
+}
+
+func (c closure) Invoke(args ...ww.Any) (ww.Any, error) {
+	return c.InvokeContext(contextOf(c.env), args...)
+}
+
+func (c closure) InvokeContext(ctx context.Context, args ...ww.Any) (ww.Any, error) {
+	env := c.env
+	if ctx != contextOf(env) {
+		env = withContext(env, "<closure>", ctx)
+	}
+
+	as := make([]core.Expr, len(args))
+	for i, arg := range args {
+		as[i] = ConstExpr{arg}
+	}
+
+	res, err := CallExpr{Fn: c.Fn, Analyzer: c.analyzer, Args: as}.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(ww.Any), nil
 }
 
 // PathExpr binds a path to an Anchor
@@ -216,18 +586,35 @@ func (pex PathExpr) Eval(core.Env) (score.Any, error) { return pex, nil }
 // Invoke is the data selector for the Path type.  It gets/sets the value at the anchor
 // path.
 func (pex PathExpr) Invoke(args ...ww.Any) (ww.Any, error) {
+	return pex.InvokeContext(context.Background(), args...)
+}
+
+// InvokeContext is equivalent to Invoke.  Anchor calls are bound to ctx.
+func (pex PathExpr) InvokeContext(ctx context.Context, args ...ww.Any) (ww.Any, error) {
 	path, err := pex.Parts()
 	if err != nil {
 		return nil, err
 	}
 
-	anchor := pex.Root.Walk(context.Background(), path)
+	anchor := pex.Root.Walk(ctx, path)
+	defer anchor.Release()
 
 	if len(args) == 0 {
-		return anchor.Load(context.Background())
+		return anchor.Load(ctx)
+	}
+
+	if ok, err := core.HasChan(args[0]); err != nil || ok {
+		if err == nil {
+			err = core.ErrChanNotStorable
+		}
+
+		return nil, core.Error{
+			Cause:   err,
+			Message: anchorpath.Join(path),
+		}
 	}
 
-	err = anchor.Store(context.Background(), args[0])
+	err = anchor.Store(ctx, args[0])
 	if err != nil {
 		return nil, core.Error{
 			Cause:   err,
@@ -241,17 +628,30 @@ func (pex PathExpr) Invoke(args ...ww.Any) (ww.Any, error) {
 // PathListExpr fetches subanchors for a path
 type PathListExpr struct {
 	PathExpr
-	Args []ww.Any
+	Opts anchorutil.ListOptions
 }
 
-// Eval calls ww.Anchor.Ls and returns a vector of paths
-func (plx PathListExpr) Eval(core.Env) (score.Any, error) {
+// Eval lists the anchor's children, sorted and filtered according to Opts, and
+// returns a vector of paths.  If the path is a glob pattern, it returns the paths
+// that match it instead, and Opts is ignored.
+func (plx PathListExpr) Eval(env core.Env) (score.Any, error) {
 	path, err := plx.Path.Parts()
 	if err != nil {
 		return nil, err
 	}
 
-	as, err := plx.Root.Walk(context.Background(), path).Ls(context.Background())
+	ctx := contextOf(env)
+
+	var as []ww.Anchor
+	if anchorpath.IsGlob(path) {
+		as, err = anchorutil.Glob(ctx, plx.Root, path)
+	} else {
+		a := plx.Root.Walk(ctx, anchorpath.Unescape(path))
+		as, err = anchorutil.List(ctx, a, plx.Opts)
+		a.Release()
+	}
+	defer anchorutil.Release(as)
+
 	if err != nil {
 		return nil, err
 	}
@@ -283,6 +683,8 @@ func (vex VectorExpr) Eval(env core.Env) (score.Any, error) {
 
 	// TODO(performace):  this is just begging for a transient.
 
+	literal := vex.Vector
+
 	for i := 0; i < cnt; i++ {
 		any, err := vex.Vector.EntryAt(i)
 		if err != nil {
@@ -294,9 +696,11 @@ func (vex VectorExpr) Eval(env core.Env) (score.Any, error) {
 			return nil, err
 		}
 
-		// no need to canonicalize here.  If different, it's because the value changed.
-		if bytes.Equal(memutil.Bytes(any.Value()), memutil.Bytes(other.Value())) {
-			continue
+		// self-evaluating items are left in place; if in doubt, the item is replaced
+		if any.Value().Which() == other.Value().Which() {
+			if eq, err := core.Eq(any, other); err == nil && eq {
+				continue
+			}
 		}
 
 		if vex.Vector, err = vex.Vector.Assoc(i, other); err != nil {
@@ -304,18 +708,40 @@ func (vex VectorExpr) Eval(env core.Env) (score.Any, error) {
 		}
 	}
 
+	// a literal whose items all evaluate to themselves allocates nothing
+	if err = checkAlloc(env, nil, vex.Vector, []ww.Any{literal}); err != nil {
+		return nil, err
+	}
+
 	return vex.Vector, nil
 }
 
 // LocalGoExpr starts a local process.  Local processes cannot be addressed by remote
 // hosts.
 type LocalGoExpr struct {
-	Args []ww.Any
+	Body  core.Expr
+	procs *procTable
 }
 
-// Eval resolves starts the process.
+// Eval starts the process.  The body is evaluated in a child of env that is bound
+// to the process' context, so that canceling the process interrupts evaluation.
 func (lx LocalGoExpr) Eval(env core.Env) (score.Any, error) {
-	return nil, errors.New("LocalGoExpr NOT IMPLEMENTED")
+	return lx.procs.Spawn(contextOf(env), func(ctx context.Context) (ww.Any, error) {
+		res, err := lx.Body.Eval(withContext(env, "<go>", ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		any, ok := res.(ww.Any)
+		if !ok {
+			return nil, core.Error{
+				Cause:   core.ErrIllegalState,
+				Message: fmt.Sprintf("process returned non-value type '%s'", reflect.TypeOf(res)),
+			}
+		}
+
+		return any, nil
+	})
 }
 
 // RemoteGoExpr starts a global process.  Global processes may be bound to an Anchor,
@@ -327,56 +753,206 @@ type RemoteGoExpr struct {
 }
 
 // Eval resolves the anchor and starts the process.
-func (rx RemoteGoExpr) Eval(core.Env) (score.Any, error) {
+func (rx RemoteGoExpr) Eval(env core.Env) (score.Any, error) {
 	path, err := rx.Path.Parts()
 	if err != nil {
 		return nil, err
 	}
 
-	return rx.Root.Walk(context.Background(), path).
-		Go(context.Background(), rx.Args...)
+	ctx := contextOf(env)
+	anchor := rx.Root.Walk(ctx, path)
+	defer anchor.Release()
+
+	return anchor.Go(ctx, rx.Args...)
+}
+
+// SelectExpr blocks until one of several channel operations can proceed.  It
+// evaluates to a vector of the index of the chosen operation, and the value that
+// was received (nil for sends).
+type SelectExpr struct {
+	Ops [][]core.Expr
+}
+
+// Eval the channel operations and perform the first that is ready.
+func (sex SelectExpr) Eval(env core.Env) (score.Any, error) {
+	ops := make([]core.ChanOp, len(sex.Ops))
+	for i, op := range sex.Ops {
+		vs := make([]ww.Any, len(op))
+		for j, expr := range op {
+			v, err := expr.Eval(env)
+			if err != nil {
+				return nil, err
+			}
+			vs[j] = v.(ww.Any)
+		}
+
+		ch, ok := vs[0].(core.Chan)
+		if !ok {
+			return nil, core.Error{
+				Cause:   core.ErrIllegalState,
+				Message: fmt.Sprintf("select: expected chan, got '%s'", reflect.TypeOf(vs[0])),
+			}
+		}
+
+		ops[i] = core.ChanOp{Chan: ch}
+		if len(vs) == 2 {
+			ops[i].Send = true
+			ops[i].Value = vs[1]
+		}
+	}
+
+	i, v, err := core.Select(contextOf(env), ops...)
+	if err != nil {
+		return nil, err
+	}
+
+	if v == nil {
+		v = core.Nil{}
+	}
+
+	idx, err := core.NewInt64(capnp.SingleSegment(nil), int64(i))
+	if err != nil {
+		return nil, err
+	}
+
+	return core.NewVector(capnp.SingleSegment(nil), idx, v)
 }
 
-// ImportExpr .
+// ImportExpr loads modules.  Within an evaluation, each module is loaded at most once,
+// so importing a module that has already been loaded is a no-op, and importing a module
+// that is still being loaded fails with ErrImportCycle.
 type ImportExpr struct {
 	Analyzer core.Analyzer
-	Paths    []string
+	Modules  []Module
 }
 
-// Eval loads the module files from the supplied paths
+// Eval loads the supplied modules, and returns the value of the last form evaluated.
 func (lex ImportExpr) Eval(env core.Env) (any score.Any, err error) {
-	var dex DoExpr
-	for _, path := range lex.Paths {
-		if dex.Exprs, err = lex.loadOne(env, path); err != nil {
-			break
+	ctx := withImports(contextOf(env))
+
+	var v score.Any
+	for _, m := range lex.Modules {
+		if v, err = lex.load(ctx, env, m); err != nil {
+			return nil, err
 		}
 
-		if any, err = dex.Eval(env); err != nil {
-			break
+		if v != nil {
+			any = v
 		}
 	}
 
 	return
 }
 
-func (lex ImportExpr) loadOne(env core.Env, path string) ([]core.Expr, error) {
-	f, err := os.Open(path)
+// addFile appends the module at the filesystem path.  The path is made absolute, so
+// that each file has a single identity.
+func (lex *ImportExpr) addFile(path string) (err error) {
+	if path, err = filepath.Abs(path); err == nil {
+		lex.Modules = append(lex.Modules, fileModule(path))
+	}
+
+	return
+}
+
+func (lex ImportExpr) load(ctx context.Context, env core.Env, m Module) (score.Any, error) {
+	// The chain of modules being loaded is bound to the context rather than shared,
+	// so that processes spawned by a module can import concurrently.
+	chain, _ := ctx.Value(keyImportChain{}).([]Module)
+	chain = chain[:len(chain):len(chain)]
+
+	for i, c := range chain {
+		if c.key() == m.key() {
+			var ps []string
+			for _, c := range append(chain[i:], m) {
+				ps = append(ps, c.Path)
+			}
+
+			return nil, fmt.Errorf("%w: %s", ErrImportCycle, strings.Join(ps, " -> "))
+		}
+	}
+
+	imported := ctx.Value(keyImports{}).(*imports)
+	if imported.Loaded(m.key()) {
+		return nil, nil
+	}
+
+	ctx = context.WithValue(ctx, keyImportChain{}, append(chain, m))
+
+	any, err := lex.loadModule(bindContext(env, ctx), m)
+	if err == nil {
+		imported.Add(m.key())
+	}
+
+	return any, err
+}
+
+// loadModule evaluates the forms in the module one at a time, so that each form can
+// use the macros defined by the ones before it.  Errors are annotated with the
+// position of the offending form.
+func (lex ImportExpr) loadModule(env core.Env, m Module) (any score.Any, err error) {
+	src, err := m.read(contextOf(env))
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 
-	forms, err := reader.New(f).All()
+	rd, err := reader.New(bytes.NewReader(src))
 	if err != nil {
 		return nil, err
 	}
+	rd.File = m.Path
 
-	exprs := make([]core.Expr, len(forms))
-	for i, form := range forms {
-		if exprs[i], err = lex.Analyzer.Analyze(env, form); err != nil {
-			break
+	for {
+		if err = rd.SkipSpaces(); err == io.EOF {
+			return any, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		pos := rd.Position()
+		f := SourceForm{File: m.Path, Line: pos.Ln, Col: pos.Col + 1}
+
+		if f.Form, err = rd.One(); err == io.EOF { // trailing comment
+			return any, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		if any, err = core.Eval(env, lex.Analyzer, f.Form); err != nil {
+			return nil, f.annotate(err)
 		}
 	}
+}
+
+type (
+	keyImports     struct{}
+	keyImportChain struct{}
+)
+
+// withImports binds a new set of loaded modules to ctx, unless it already has one.
+func withImports(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(keyImports{}).(*imports); ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, keyImports{}, &imports{loaded: make(map[moduleKey]bool)})
+}
+
+// imports is the set of modules loaded during an evaluation.
+type imports struct {
+	mu     sync.Mutex
+	loaded map[moduleKey]bool
+}
+
+func (i *imports) Loaded(k moduleKey) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.loaded[k]
+}
+
+func (i *imports) Add(k moduleKey) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
 
-	return exprs, err
+	i.loaded[k] = true
 }
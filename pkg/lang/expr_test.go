@@ -0,0 +1,67 @@
+package lang
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	"github.com/wetware/ww/pkg/lang/reader"
+)
+
+func TestBindParams(t *testing.T) {
+	t.Parallel()
+
+	vm, err := New(nil)
+	require.NoError(t, err)
+
+	rd, err := reader.New(strings.NewReader(`
+	(defn fixed [x y] x)
+	(defn variadic ([] :zero) ([x y more...] more))`))
+	require.NoError(t, err)
+
+	forms, err := rd.All()
+	require.NoError(t, err)
+
+	for _, f := range forms {
+		_, err = vm.Eval(f)
+		require.NoError(t, err)
+	}
+
+	// bindParams returns the error from binding nargs arguments to the call target
+	// that fn matches for arity.
+	bind := func(t *testing.T, name string, arity, nargs int) error {
+		v, err := vm.Env().Resolve(name)
+		require.NoError(t, err)
+		fn := v.(core.Fn)
+
+		ct, err := fn.Match(arity)
+		require.NoError(t, err)
+
+		_, err = bindParams(fn, ct, make([]ww.Any, nargs))
+		return err
+	}
+
+	for _, tt := range []struct {
+		desc, name   string
+		arity, nargs int
+		msg          string
+	}{
+		{"Fixed", "fixed", 2, 1, "'fixed' called with 1 args, want 2, arities [[x y]]"},
+		{"Variadic", "variadic", 2, 1, "'variadic' called with 1 args, want at least 2, arities [[] [x y more...]]"},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			err := bind(t, tt.name, tt.arity, tt.nargs)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, core.ErrArity), "unexpected error %v", err)
+			assert.Contains(t, err.Error(), tt.msg)
+		})
+	}
+}
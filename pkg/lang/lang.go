@@ -2,10 +2,11 @@
 package lang
 
 import (
-	"errors"
+	"context"
 	"fmt"
 
 	"github.com/spy16/slurp"
+	score "github.com/spy16/slurp/core"
 	capnp "zombiezen.com/go/capnproto2"
 
 	ww "github.com/wetware/ww/pkg"
@@ -13,27 +14,79 @@ import (
 	// _ "github.com/wetware/ww/pkg/lang/core/proc" // register default process types
 )
 
-// New returns a new root interpreter.
-func New(root ww.Anchor, srcPath ...string) (*slurp.Interpreter, error) {
-	if root == nil {
-		return nil, errors.New("nil anchor")
+// VM is an interpreter session.
+type VM struct {
+	*slurp.Interpreter
+
+	env     core.Env
+	a       core.Analyzer
+	loaders *loaderTable
+}
+
+// Eval evaluates the form.
+func (vm *VM) Eval(form score.Any) (score.Any, error) {
+	return vm.EvalContext(context.Background(), form)
+}
+
+// EvalContext evaluates the form.  Canceling ctx interrupts evaluation, including
+// pending calls to anchors and remote processes.  Modules imported during the
+// evaluation are loaded at most once.  If ctx carries an EvalBudget (see WithBudget),
+// evaluation fails with a BudgetExceeded error once the budget is exhausted.
+func (vm *VM) EvalContext(ctx context.Context, form score.Any) (score.Any, error) {
+	env := ctxEnv{Env: vm.env.Child("<eval>", nil), ctx: withImports(ctx), budget: newBudget(ctx)}
+	return core.Eval(env, vm.a, form)
+}
+
+// EvalSource evaluates a form read by ReadSource, as with EvalContext.  Errors report
+// the form's position.  If the form exceeds its budget, the error is a BudgetExceeded;
+// otherwise it is a reader.Error.
+func (vm *VM) EvalSource(ctx context.Context, f SourceForm) (score.Any, error) {
+	res, err := vm.EvalContext(ctx, f.Form)
+	if err != nil {
+		return nil, f.annotate(err)
 	}
 
+	return res, nil
+}
+
+// RegisterLoader imports path literals whose first segment is scheme using l.  It
+// fails with ErrLoaderConflict if the VM already has a loader for scheme, or if scheme
+// is "file" or "anchor".  Registered loaders take precedence over anchors with the
+// same name.  Loaders are registered with each VM, and are not shared between VMs.
+func (vm *VM) RegisterLoader(scheme string, l Loader) error {
+	return vm.loaders.Register(scheme, l)
+}
+
+// Env returns the root environment, in which top-level forms are evaluated.
+func (vm *VM) Env() core.Env { return vm.env }
+
+// New returns a new root interpreter.  If root is nil, the VM is not connected to a
+// cluster, and forms containing anchor paths fail analysis with ErrNoCluster.
+func New(root ww.Anchor, srcPath ...string) (*VM, error) {
 	env := core.New()
+	procs := newProcTable()
+
+	loaders := newLoaderTable()
 
-	a, err := newAnalyzer(root, srcPath)
+	a, err := newAnalyzer(root, procs, loaders, srcPath)
 	if err != nil {
 		return nil, err
 	}
 
-	return slurp.New(
+	vm := &VM{
+		Interpreter: slurp.New(
 			slurp.WithEnv(env),
 			slurp.WithAnalyzer(a)),
-		prelude(env, a)
+		env:     env,
+		a:       a,
+		loaders: loaders,
+	}
+
+	return vm, prelude(env, a, procs)
 }
 
-func prelude(env core.Env, a core.Analyzer) (err error) {
-	if err = loadBuiltins(env, a); err != nil {
+func prelude(env core.Env, a core.Analyzer, procs *procTable) (err error) {
+	if err = loadBuiltins(env, a, procs); err != nil {
 		return
 	}
 
@@ -1,11 +1,22 @@
 package lang_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	score "github.com/spy16/slurp/core"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	mock_ww "github.com/wetware/ww/internal/test/mock/pkg"
@@ -13,6 +24,9 @@ import (
 	"github.com/wetware/ww/pkg/lang"
 	"github.com/wetware/ww/pkg/lang/core"
 	"github.com/wetware/ww/pkg/lang/reader"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
+	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
+	capnp "zombiezen.com/go/capnproto2"
 )
 
 // TODO:  replace this with testdata/lang_test.ww
@@ -49,8 +63,7 @@ func TestLang(t *testing.T) {
 	vm, err := lang.New(mock_ww.NewMockAnchor(ctrl))
 	require.NoError(t, err)
 
-	forms, err := reader.New(strings.NewReader(src)).All()
-	require.NoError(t, err)
+	forms := readAll(t, src)
 
 	var res interface{}
 	for i, f := range forms {
@@ -69,3 +82,1450 @@ func TestLang(t *testing.T) {
 
 	assert.True(t, b.Bool(), "test failed")
 }
+
+func readAll(t testing.TB, src string) []score.Any {
+	rd, err := reader.New(strings.NewReader(src))
+	require.NoError(t, err)
+
+	forms, err := rd.All()
+	require.NoError(t, err)
+
+	return forms
+}
+
+// newVM returns a function that evaluates source code in a fresh interpreter,
+// returning the result of the last form.
+func newVM(t *testing.T, srcPath ...string) func(string) (interface{}, error) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	vm, err := lang.New(mock_ww.NewMockAnchor(ctrl), srcPath...)
+	require.NoError(t, err)
+
+	return evalIn(t, vm)
+}
+
+// evalIn returns a function that evaluates source code in vm, returning the result of
+// the last form.
+func evalIn(t *testing.T, vm *lang.VM) func(string) (interface{}, error) {
+	return func(src string) (res interface{}, err error) {
+		forms := readAll(t, src)
+
+		for _, f := range forms {
+			if res, err = vm.Eval(f); err != nil {
+				break
+			}
+		}
+
+		return
+	}
+}
+
+func TestGo(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Wait", func(t *testing.T) {
+		t.Parallel()
+		eval := newVM(t)
+
+		res, err := eval(`(= :value (wait (go :value)))`)
+		require.NoError(t, err)
+		assert.Equal(t, core.True, res)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		t.Parallel()
+		eval := newVM(t)
+
+		_, err := eval(`(wait (go (pop :not-a-container)))`)
+		require.Error(t, err)
+		assert.IsType(t, core.Error{}, err)
+	})
+
+	t.Run("Kill", func(t *testing.T) {
+		t.Parallel()
+		eval := newVM(t)
+
+		res, err := eval(`
+		(def spin (fn spin [] (spin)))
+		(def p (go (spin)))
+		(done? p)`)
+		require.NoError(t, err)
+		assert.Equal(t, core.False, res)
+
+		_, err = eval(`(result p)`)
+		assert.True(t, errors.Is(err, core.ErrProcRunning), "unexpected error %v", err)
+
+		res, err = eval(`(= 1 (len (ps)))`)
+		require.NoError(t, err)
+		assert.Equal(t, core.True, res)
+
+		_, err = eval(`(kill p)`)
+		require.NoError(t, err)
+
+		_, err = eval(`(wait p)`)
+		assert.True(t, errors.Is(err, core.ErrProcCanceled), "unexpected error %v", err)
+
+		res, err = eval(`(done? p)`)
+		require.NoError(t, err)
+		assert.Equal(t, core.True, res)
+	})
+
+	t.Run("KillByID", func(t *testing.T) {
+		t.Parallel()
+		eval := newVM(t)
+
+		_, err := eval(`
+		(def spin (fn spin [] (spin)))
+		(def p (go (spin)))
+		(kill 1)
+		(wait p)`)
+		assert.True(t, errors.Is(err, core.ErrProcCanceled), "unexpected error %v", err)
+
+		// the process was reaped when it terminated
+		_, err = eval(`(kill 1)`)
+		assert.True(t, errors.Is(err, core.ErrNotFound), "unexpected error %v", err)
+	})
+
+	t.Run("Reap", func(t *testing.T) {
+		t.Parallel()
+		eval := newVM(t)
+
+		// terminated processes are reaped, even if their result is never collected
+		_, err := eval(`(def p (go :value))`)
+		require.NoError(t, err)
+
+		assert.Eventually(t, func() bool {
+			res, err := eval(`(= 0 (len (ps)))`)
+			return err == nil && res == core.True
+		}, time.Second, time.Millisecond*10)
+
+		_, err = eval(`(done? 1)`)
+		assert.True(t, errors.Is(err, core.ErrNotFound), "unexpected error %v", err)
+
+		// the handle still holds the result
+		res, err := eval(`(= :value (result p))`)
+		require.NoError(t, err)
+		assert.Equal(t, core.True, res)
+	})
+
+	t.Run("Listing", func(t *testing.T) {
+		t.Parallel()
+		eval := newVM(t)
+
+		_, err := eval(`(wait (go :value))`)
+		require.NoError(t, err)
+
+		res, err := eval(`(= 0 (len (ps)))`)
+		require.NoError(t, err)
+		assert.Equal(t, core.True, res)
+	})
+}
+
+func TestChan(t *testing.T) {
+	t.Parallel()
+
+	t.Run("SendRecv", func(t *testing.T) {
+		t.Parallel()
+		eval := newVM(t)
+
+		res, err := eval(`
+		(def ch (chan))
+		(go (send! ch :value))
+		(= :value (recv! ch))`)
+		require.NoError(t, err)
+		assert.Equal(t, core.True, res)
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		t.Parallel()
+		eval := newVM(t)
+
+		res, err := eval(`
+		(def ch (chan 1))
+		(close! ch)
+		(nil? (recv! ch))`)
+		require.NoError(t, err)
+		assert.Equal(t, core.True, res)
+
+		_, err = eval(`(send! ch :value)`)
+		assert.True(t, errors.Is(err, core.ErrChanClosed), "unexpected error %v", err)
+	})
+
+	t.Run("Select", func(t *testing.T) {
+		t.Parallel()
+		eval := newVM(t)
+
+		res, err := eval(`
+		(def idle (chan))
+		(def ready (chan 1))
+		(send! ready :value)
+		(= [1 :value] (select [idle] [ready]))`)
+		require.NoError(t, err)
+		assert.Equal(t, core.True, res)
+	})
+
+	t.Run("Collection", func(t *testing.T) {
+		t.Parallel()
+		eval := newVM(t)
+
+		res, err := eval(`
+		(def chs [(chan 1)])
+		(send! (first chs) :value)
+		(= :value (recv! (first chs)))`)
+		require.NoError(t, err)
+		assert.Equal(t, core.True, res)
+	})
+
+	t.Run("Interrupt", func(t *testing.T) {
+		t.Parallel()
+		eval := newVM(t)
+
+		// a blocked recv! is interrupted when its process is killed
+		_, err := eval(`
+		(def ch (chan))
+		(def p (go (recv! ch)))
+		(kill p)
+		(wait p)`)
+		assert.True(t, errors.Is(err, core.ErrProcCanceled), "unexpected error %v", err)
+	})
+
+	t.Run("Anchor", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		// Store is never called
+		anchor := mock_ww.NewMockAnchor(ctrl)
+		anchor.EXPECT().Release().Times(2)
+
+		root := mock_ww.NewMockAnchor(ctrl)
+		root.EXPECT().
+			Walk(gomock.Any(), []string{"foo"}).
+			Return(anchor).
+			Times(2)
+
+		vm, err := lang.New(root)
+		require.NoError(t, err)
+		eval := evalIn(t, vm)
+
+		_, err = eval(`(def foo /foo) (foo (chan))`)
+		assert.True(t, errors.Is(err, core.ErrChanNotStorable), "unexpected error %v", err)
+
+		_, err = eval(`(foo [:value [(chan)]])`)
+		assert.True(t, errors.Is(err, core.ErrChanNotStorable), "unexpected error %v", err)
+	})
+}
+
+func TestComment(t *testing.T) {
+	t.Parallel()
+
+	// the body is not analyzed, so undefined symbols are not an error
+	res, err := newVM(t)(`(nil? (comment (undefined 1) [2]))`)
+	require.NoError(t, err)
+	assert.Equal(t, core.True, res)
+}
+
+func TestChars(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		desc, src string
+	}{
+		{"Str", `(= "hi" (str \h \i))`},
+		{"StrMixed", `(= "a1:kw" (str \a 1 nil :kw))`},
+		{"StrEmpty", `(= "" (str))`},
+		{"Nth", `(= \b (nth "abc" 1))`},
+		{"NthMultibyte", `(= \é (nth "héllo" 1))`},
+		{"NthNamed", `(= \newline (nth "a\nb" 1))`},
+		{"NthDefault", `(= :none (nth "abc" 3 :none))`},
+		{"Count", `(= 5 (count "héllo"))`},
+		{"Unicode", `(= \u00e9 \é)`},
+		{"NotString", `(= 'char (type \a))`},
+		{"Less", `(< \a \b)`},
+		{"Sort", `(= [\a \b \c] (sort [\c \a \b]))`},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := newVM(t)(tt.src)
+			require.NoError(t, err)
+			assert.Equal(t, core.True, res)
+		})
+	}
+}
+
+func TestRegex(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		desc, src string
+	}{
+		{"Find", `(= "123" (re-find #"\d+" "abc123def456"))`},
+		{"FindNone", `(nil? (re-find #"\d+" "abc"))`},
+		{"FindGroups", `(= ["k=v" "k" "v"] (re-find #"(\w)=(\w)" "-- k=v --"))`},
+		{"FindUnmatchedGroup", `(= ["a" "a" nil] (re-find #"(a)|(b)" "a"))`},
+		{"Matches", `(= "abc" (re-matches #"[a-c]+" "abc"))`},
+		{"MatchesPartial", `(nil? (re-matches #"[a-c]+" "abcd"))`},
+		{"MatchesAlternation", `(nil? (re-matches #"a|ab" "abc"))`},
+		{"Seq", `(= '("1" "22" "333") (re-seq #"\d+" "1 22 333"))`},
+		{"SeqNone", `(= 0 (count (re-seq #"\d+" "none")))`},
+		{"Quote", `(= "\"hi\"" (re-find #"\".*\"" "say \"hi\""))`},
+		{"Pattern", `(= "b" (re-find (re-pattern "b") "abc"))`},
+		{"String", `(= "b" (re-find "b" "abc"))`},
+		{"Render", `(= "#\"a\\d\"" (render (re-pattern "a\\d")))`},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := newVM(t)(tt.src)
+			require.NoError(t, err)
+			assert.Equal(t, core.True, res)
+		})
+	}
+
+	_, err := newVM(t)(`(re-find (re-pattern "(") "abc")`)
+	require.Error(t, err)
+}
+
+func TestCollections(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		desc, src string
+	}{
+		{"ConjVector", `(= [1 2 3] (conj [1 2] 3))`},
+		{"ConjList", `(= 0 (first (conj '(1 2) 0)))`},
+		{"PeekVector", `(= 3 (peek [1 2 3]))`},
+		{"PeekList", `(= 1 (peek '(1 2 3)))`},
+		{"PeekEmpty", `(nil? (peek []))`},
+		{"Count", `(= 3 (count [1 2 3]))`},
+		{"NthVector", `(= 2 (nth [1 2 3] 1))`},
+		{"NthList", `(= 3 (nth '(1 2 3) 2))`},
+		{"NthDefault", `(= :none (nth [1 2 3] 3 :none))`},
+		{"FirstVector", `(= 1 (first [1 2 3]))`},
+		{"FirstEmpty", `(nil? (first []))`},
+		{"RestVector", `(= 2 (count (rest [1 2 3])))`},
+		{"RestEmpty", `(= 0 (count (rest [])))`},
+		{"RestList", `(= 3 (first (rest '(1 3))))`},
+		{"Subvec", `(= [2 3] (subvec [1 2 3 4] 1 3))`},
+		{"SubvecOpen", `(= [3 4] (subvec [1 2 3 4] 2))`},
+		{"SubvecConj", `(= [2 :x] (conj (subvec [1 2 3] 1 2) :x))`},
+		{"IntoVector", `(= [1 2 3 4] (into [1 2] '(3 4)))`},
+		{"IntoList", `(= '(4 3 1 2) (into '(1 2) [3 4]))`},
+		{"IntoNil", `(= '(1 2) (into nil [1 2]))`},
+		{"IntoEmpty", `(= [1] (into [1] []))`},
+		{"Concat", `(= '(1 2 3 4 5) (concat [1 2] '(3) [] nil [4 5]))`},
+		{"ConcatEmpty", `(= 0 (count (concat)))`},
+		{"ConcatNth", `(= 4 (nth (concat [1 2] [3 4]) 3))`},
+		{"ConcatInto", `(= [1 2 3] (into [] (concat [1] [2 3])))`},
+		{"Sort", `(= [1 2 3] (sort '(3 1 2)))`},
+		{"SortStrings", `(= ["a" "ab" "b"] (sort ["b" "ab" "a"]))`},
+		{"SortHeterogeneous", `(= [nil 1 "one" :one] (sort [:one "one" 1 nil]))`},
+		{"SortComparator", `(= [3 2 1] (sort (fn [a b] (> a b)) [1 3 2]))`},
+		{"SortBy", `(= [[1 :b] [2 :a]] (sort-by first [[2 :a] [1 :b]]))`},
+		{"SortByFn", `(= [[:a 2] [:b 1]] (sort-by (fn [x] (nth x 1)) (fn [a b] (> a b)) [[:b 1] [:a 2]]))`},
+		{"SortByStable", `(= [[1 :x] [1 :y] [2 :z]] (sort-by first [[2 :z] [1 :x] [1 :y]]))`},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := newVM(t)(tt.src)
+			require.NoError(t, err)
+			assert.Equal(t, core.True, res)
+		})
+	}
+
+	t.Run("NthOutOfRange", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newVM(t)(`(nth [1 2 3] 3)`)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, core.ErrIndexOutOfBounds), "unexpected error %v", err)
+		assert.Contains(t, err.Error(), "index 3, count 3")
+	})
+
+	t.Run("IntoNotSeqable", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newVM(t)(`(into [] 1)`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot create seq")
+	})
+}
+
+func TestConditionals(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		desc, src string
+	}{
+		{"When", `(= :b (when true :a :b))`},
+		{"WhenFalse", `(nil? (when false :a))`},
+		{"WhenEmpty", `(nil? (when true))`},
+		{"WhenNot", `(= :b (when-not false :a :b))`},
+		{"WhenNotTrue", `(nil? (when-not true :a))`},
+		{"IfLet", `(= :value (if-let [x :value] x :else))`},
+		{"IfLetElse", `(= :else (if-let [x nil] x :else))`},
+		{"IfLetNoElse", `(nil? (if-let [x false] x))`},
+		{"IfLetScope", `
+		(def x :outer)
+		(if-let [x :inner] x)
+		(= :outer x)`},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := newVM(t)(tt.src)
+			require.NoError(t, err)
+			assert.Equal(t, core.True, res)
+		})
+	}
+
+	for _, tt := range []struct {
+		desc, src, msg string
+	}{
+		{"WhenArity", `(when)`, "requires at-least 1 argument"},
+		{"IfLetPairs", `(if-let [x 1 y 2] x)`, "requires exactly 1 binding pair"},
+		{"IfLetBindings", `(if-let (x 1) x)`, "bindings must be a vector"},
+		{"IfLetName", `(if-let [:x 1] 1)`, "binding name must be symbol"},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := newVM(t)(tt.src)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.msg)
+		})
+	}
+}
+
+func TestDefn(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		desc, src, arglists string
+	}{
+		{"Single", `
+		(defn id [x] x)
+		id`, "[[x]]"},
+		{"MultiArity", `
+		(defn add ([x] x) ([x y] y) ([x y z...] z))
+		add`, "[[x] [x y] [x y z...]]"},
+		{"Docstring", `
+		(defn id "returns its argument" [x] x)
+		id`, "[[x]]"},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := newVM(t)(tt.src)
+			require.NoError(t, err)
+			require.IsType(t, core.Fn{}, res)
+
+			as, err := res.(core.Fn).Arglists()
+			require.NoError(t, err)
+
+			s, err := core.Render(as)
+			require.NoError(t, err)
+			assert.Equal(t, tt.arglists, s)
+		})
+	}
+
+	for _, tt := range []struct {
+		desc, src, meta string
+	}{
+		{"MetaDoc", `
+		(defn id "returns its argument" [x] x)
+		(meta id)`, `[:doc "returns its argument" :arglists [[x]]]`},
+		{"MetaDef", `
+		(def id (fn [x] x))
+		(meta id)`, "[:arglists [[x]]]"},
+		{"MetaRedefined", `
+		(defn id "returns its argument" [x] x)
+		(def id 42)
+		(meta id)`, "[]"},
+		{"MetaUndefined", `(meta id)`, "nil"},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := newVM(t)(tt.src)
+			require.NoError(t, err)
+
+			s, err := core.Render(res.(ww.Any))
+			require.NoError(t, err)
+			assert.Equal(t, tt.meta, s)
+		})
+	}
+
+	t.Run("Call", func(t *testing.T) {
+		t.Parallel()
+
+		res, err := newVM(t)(`
+		(defn pick ([x] x) ([x y] y))
+		(= (pick 1 2) (pick 2))`)
+		require.NoError(t, err)
+		assert.Equal(t, core.True, res)
+	})
+
+	for _, tt := range []struct {
+		desc, src, msg string
+	}{
+		{"Empty", `(defn)`, "requires name and call signature"},
+		{"Name", `(defn :f [x] x)`, "name must be symbol"},
+		{"DuplicateArity", `(defn f ([x] x) ([y] y))`, "duplicate arity 1"},
+		{"MultipleVariadic", `(defn f ([x...] x) ([x y...] y))`, "multiple variadic signatures"},
+		{"MetaArity", `(meta)`, "requires exactly 1 arg, got 0"},
+		{"MetaName", `(meta :f)`, "arg must be symbol"},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := newVM(t)(tt.src)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.msg)
+		})
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// a remote anchor that never responds
+	hung := mock_ww.NewMockAnchor(ctrl)
+	hung.EXPECT().
+		Iter(gomock.Any()).
+		DoAndReturn(func(ctx context.Context) ww.AnchorIterator {
+			<-ctx.Done()
+			return anchorutil.ErrIter(ctx.Err())
+		}).
+		Times(1)
+	hung.EXPECT().Release().Times(1)
+
+	root := mock_ww.NewMockAnchor(ctrl)
+	root.EXPECT().
+		Walk(gomock.Any(), gomock.Any()).
+		Return(hung).
+		Times(1)
+
+	vm, err := lang.New(root)
+	require.NoError(t, err)
+
+	rd, err := reader.New(strings.NewReader("(ls /)"))
+	require.NoError(t, err)
+
+	form, err := rd.One()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	_, err = vm.EvalContext(ctx, form)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error %v", err)
+}
+
+func TestLsOptions(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var children []ww.Anchor
+	for _, name := range []string{"foo", "bar", "baz", "qux"} {
+		child := mock_ww.NewMockAnchor(ctrl)
+		child.EXPECT().Name().Return(name).AnyTimes()
+		child.EXPECT().Path().Return([]string{"dir", name}).AnyTimes()
+		child.EXPECT().Release().AnyTimes()
+		children = append(children, child)
+	}
+
+	dir := mock_ww.NewMockAnchor(ctrl)
+	dir.EXPECT().
+		Iter(gomock.Any()).
+		DoAndReturn(func(context.Context) ww.AnchorIterator {
+			return anchorutil.SliceIter(append([]ww.Anchor{}, children...))
+		}).
+		AnyTimes()
+	dir.EXPECT().Release().AnyTimes()
+
+	root := mock_ww.NewMockAnchor(ctrl)
+	root.EXPECT().
+		Walk(gomock.Any(), []string{"dir"}).
+		Return(dir).
+		AnyTimes()
+
+	vm, err := lang.New(root)
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{src: `(ls /dir)`, want: `[/dir/bar /dir/baz /dir/foo /dir/qux]`},
+		{src: `(ls /dir :desc)`, want: `[/dir/qux /dir/foo /dir/baz /dir/bar]`},
+		{src: `(ls /dir :prefix "ba")`, want: `[/dir/bar /dir/baz]`},
+		{src: `(ls /dir :prefix "ba" :desc)`, want: `[/dir/baz /dir/bar]`},
+	} {
+		res, err := vm.Eval(readAll(t, tt.src)[0])
+		require.NoError(t, err, tt.src)
+
+		got, err := core.Render(res.(ww.Any))
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, tt.src)
+	}
+
+	for _, src := range []string{`(ls /dir :prefix)`, `(ls /dir :long)`, `(ls /dir 42)`} {
+		_, err := vm.Eval(readAll(t, src)[0])
+		assert.Error(t, err, src)
+	}
+}
+
+func TestPathAnalysis(t *testing.T) {
+	t.Parallel()
+
+	t.Run("NoCluster", func(t *testing.T) {
+		t.Parallel()
+
+		vm, err := lang.New(nil)
+		require.NoError(t, err)
+
+		res, err := vm.Eval(readAll(t, `(= :value :value)`)[0])
+		require.NoError(t, err)
+		assert.Equal(t, core.True, res)
+
+		for _, src := range []string{`/foo`, `[/foo]`, `(ls)`, `(ls /foo)`, `(go /foo (nop))`, `(import /foo)`} {
+			_, err := vm.Eval(readAll(t, src)[0])
+			require.Error(t, err, src)
+			assert.True(t, errors.Is(err, lang.ErrNoCluster), "unexpected error %v", err)
+		}
+	})
+
+	t.Run("Relative", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		vm, err := lang.New(mock_ww.NewMockAnchor(ctrl))
+		require.NoError(t, err)
+
+		p, err := core.NewPath(capnp.SingleSegment(nil), "foo/bar")
+		require.NoError(t, err)
+
+		_, err = vm.Eval(p)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, anchorpath.ErrInvalid), "unexpected error %v", err)
+	})
+}
+
+func TestImport(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "ww-import")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	for name, src := range map[string]string{
+		lang.DepsFile:          `[util "lib/util.ww" missing "lib/missing.ww"]`,
+		"lib/util.ww":          `(def from :manifest)`,
+		"lib/helpers.ww":       `(def from :path)`,
+		"lib/once.ww":          `(def loads (conj loads :once))`,
+		"lib/broken.ww":        "(def ok true)\n\n  (undefined)",
+		"lib/nested.ww":        `(import lib.broken)`,
+		"cycle/a.ww":           `(import cycle.b)`,
+		"cycle/b.ww":           `(import "cycle/a")`,
+		"bad/" + lang.DepsFile: `[util]`,
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(src), 0644))
+	}
+
+	t.Run("Manifest", func(t *testing.T) {
+		t.Parallel()
+
+		res, err := newVM(t, dir)(`(import util) (= from :manifest)`)
+		require.NoError(t, err)
+		assert.Equal(t, core.True, res)
+	})
+
+	t.Run("Path", func(t *testing.T) {
+		t.Parallel()
+
+		res, err := newVM(t, dir)(`(import lib.helpers) (= from :path)`)
+		require.NoError(t, err)
+		assert.Equal(t, core.True, res)
+	})
+
+	t.Run("Name", func(t *testing.T) {
+		t.Parallel()
+
+		res, err := newVM(t, dir)(`(import "lib/helpers") (= from :path)`)
+		require.NoError(t, err)
+		assert.Equal(t, core.True, res)
+	})
+
+	t.Run("Once", func(t *testing.T) {
+		t.Parallel()
+
+		res, err := newVM(t, dir)(`(def loads []) (do (import lib.once) (import "lib/once.ww") (count loads))`)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), res.(core.Int64).Int64(), "module should be loaded once per evaluation")
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newVM(t, dir)(`(import cycle.a)`)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, lang.ErrImportCycle), "unexpected error %v", err)
+		assert.Contains(t, err.Error(), strings.Join([]string{
+			filepath.Join(dir, "cycle/a.ww"),
+			filepath.Join(dir, "cycle/b.ww"),
+			filepath.Join(dir, "cycle/a.ww"),
+		}, " -> "))
+	})
+
+	t.Run("Position", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newVM(t, dir)(`(import lib.nested)`)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, core.ErrNotFound), "unexpected error %v", err)
+		assert.Contains(t, err.Error(), filepath.Join(dir, "lib/broken.ww")+":3:3")
+	})
+
+	t.Run("MissingSource", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newVM(t, dir)(`(import missing)`)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, lang.ErrManifest), "unexpected error %v", err)
+		assert.Contains(t, err.Error(), "missing")
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newVM(t, filepath.Join(dir, "bad"))(`(import util)`)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, lang.ErrManifest), "unexpected error %v", err)
+	})
+}
+
+func TestImportLoader(t *testing.T) {
+	t.Parallel()
+
+	sources := map[string]string{
+		"/test/lib":    `(def from :loader)`,
+		"/test/a":      `(import /test/b)`,
+		"/test/b":      `(import /test/a)`,
+		"/test/broken": "(def ok true)\n(undefined)",
+		"/test/huge":   strings.Repeat(" ", lang.MaxSourceSize+1),
+	}
+
+	loader := lang.LoaderFunc(func(_ context.Context, path string) (io.ReadCloser, error) {
+		src, ok := sources[path]
+		if !ok {
+			return nil, core.ErrNotFound
+		}
+
+		return ioutil.NopCloser(strings.NewReader(src)), nil
+	})
+
+	newLoaderVM := func(t *testing.T, root ww.Anchor) *lang.VM {
+		vm, err := lang.New(root)
+		require.NoError(t, err)
+		require.NoError(t, vm.RegisterLoader("test", loader))
+		return vm
+	}
+
+	eval := func(t *testing.T) func(string) (interface{}, error) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+
+		return evalIn(t, newLoaderVM(t, mock_ww.NewMockAnchor(ctrl)))
+	}
+
+	t.Run("Conflict", func(t *testing.T) {
+		t.Parallel()
+
+		vm := newLoaderVM(t, nil)
+		for _, scheme := range []string{"test", "file", "anchor"} {
+			err := vm.RegisterLoader(scheme, lang.LoaderFunc(nil))
+			assert.True(t, errors.Is(err, lang.ErrLoaderConflict), "unexpected error %v", err)
+		}
+	})
+
+	t.Run("Scoped", func(t *testing.T) {
+		t.Parallel()
+
+		// loaders registered with other VMs are not visible
+		vm, err := lang.New(nil)
+		require.NoError(t, err)
+
+		_, err = evalIn(t, vm)(`(import /test/lib)`)
+		assert.True(t, errors.Is(err, lang.ErrNoCluster), "unexpected error %v", err)
+	})
+
+	t.Run("Load", func(t *testing.T) {
+		t.Parallel()
+
+		res, err := eval(t)(`(import /test/lib) (= from :loader)`)
+		require.NoError(t, err)
+		assert.Equal(t, core.True, res)
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := eval(t)(`(import /test/a)`)
+		assert.True(t, errors.Is(err, lang.ErrImportCycle), "unexpected error %v", err)
+		assert.Contains(t, err.Error(), "/test/a -> /test/b -> /test/a")
+	})
+
+	t.Run("Position", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := eval(t)(`(import /test/broken)`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "/test/broken:2:1")
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := eval(t)(`(import /test/huge)`)
+		assert.True(t, errors.Is(err, lang.ErrSourceTooLarge), "unexpected error %v", err)
+	})
+
+	t.Run("Anchor", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		src, err := core.NewString(capnp.SingleSegment(nil), `(def from :anchor)`)
+		require.NoError(t, err)
+
+		lib := mock_ww.NewMockAnchor(ctrl)
+		lib.EXPECT().Load(gomock.Any()).Return(src, nil).Times(1)
+		lib.EXPECT().Release().Times(1)
+
+		empty := mock_ww.NewMockAnchor(ctrl)
+		empty.EXPECT().Load(gomock.Any()).Return(core.Nil{}, nil).Times(1)
+		empty.EXPECT().Release().Times(1)
+
+		root := mock_ww.NewMockAnchor(ctrl)
+		root.EXPECT().Walk(gomock.Any(), []string{"host", "lib"}).Return(lib).Times(1)
+		root.EXPECT().Walk(gomock.Any(), []string{"host", "empty"}).Return(empty).Times(1)
+
+		vm, err := lang.New(root)
+		require.NoError(t, err)
+
+		// the second import is a no-op, so the anchor is only walked once
+		res, err := vm.Eval(readAll(t, `(do (import /host/lib) (import /host/./lib) (= from :anchor))`)[0])
+		require.NoError(t, err)
+		assert.Equal(t, core.True, res)
+
+		_, err = vm.Eval(readAll(t, `(import /host/empty)`)[0])
+		assert.True(t, errors.Is(err, core.ErrNotFound), "unexpected error %v", err)
+	})
+}
+
+func TestArity(t *testing.T) {
+	t.Parallel()
+
+	const src = `
+	(defn fixed [x y] [x y])
+	(defn rest-only [more...] more)
+	(defn variadic [x more...] more)
+	(defn multi ([] :zero) ([x] :one) ([x y more...] more))
+	(defn nilary-last ([x y] :two) ([] :zero))
+	`
+
+	for _, tt := range []struct {
+		desc, src, want string
+	}{
+		{"Fixed", `(fixed 1 2)`, "[1 2]"},
+		{"ZeroArgVariadic", `(rest-only)`, "()"},
+		{"RestOnly", `(rest-only 1 2)`, "(1 2)"},
+		{"VariadicMin", `(variadic 1)`, "()"},
+		{"VariadicMore", `(variadic 1 2 3)`, "(2 3)"},
+		{"MultiNilary", `(multi)`, ":zero"},
+		{"MultiUnary", `(multi 1)`, ":one"},
+		{"MultiVariadicMin", `(multi 1 2)`, "()"},
+		{"MultiVariadicMore", `(multi 1 2 3)`, "(3)"},
+		{"NilaryLast", `(nilary-last)`, ":zero"},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := newVM(t)(src + tt.src)
+			require.NoError(t, err)
+
+			s, err := core.Render(res.(ww.Any))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, s)
+		})
+	}
+
+	for _, tt := range []struct {
+		desc, src, msg string
+	}{
+		{"TooFew", `(fixed 1)`, "'fixed' called with 1 args, arities [[x y]]"},
+		{"TooMany", `(fixed 1 2 3)`, "'fixed' called with 3 args, arities [[x y]]"},
+		{"VariadicTooFew", `(variadic)`, "'variadic' called with 0 args, arities [[x more...]]"},
+		{"MultiTooFew", `(nilary-last 1)`, "'nilary-last' called with 1 args, arities [[x y] []]"},
+		{"Lambda", `(def g (fn [x] x)) (g)`, "'λ' called with 0 args, arities [[x]]"},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := newVM(t)(src + tt.src)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, core.ErrArity), "unexpected error %v", err)
+			assert.Contains(t, err.Error(), tt.msg)
+		})
+	}
+}
+
+func TestTailCall(t *testing.T) {
+	t.Parallel()
+
+	eval := func(t *testing.T, src string) ww.Any {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+
+		vm, err := lang.New(mock_ww.NewMockAnchor(ctrl))
+		require.NoError(t, err)
+
+		dec, err := lang.Func("dec", func(n int64) int64 { return n - 1 })
+		require.NoError(t, err)
+		inc, err := lang.Func("inc", func(n int64) int64 { return n + 1 })
+		require.NoError(t, err)
+		require.NoError(t, vm.Bind(map[string]score.Any{"dec": dec, "inc": inc}))
+
+		forms := readAll(t, src)
+
+		var res score.Any
+		for _, f := range forms {
+			res, err = vm.Eval(f)
+			require.NoError(t, err)
+		}
+
+		return res.(ww.Any)
+	}
+
+	for _, tt := range []struct {
+		desc, src, want string
+	}{
+		{"Accumulator", `
+		(defn acc [n total] (if (= 0 n) total (acc (dec n) (inc total))))
+		(acc 1000000 0)`, "1000000"},
+		{"MultiArity", `
+		(defn acc ([n] (acc n 0)) ([n total] (when-not (= 0 n) (acc (dec n) (inc total)))))
+		(acc 1000)`, "nil"},
+		{"IfLet", `
+		(defn walk [v] (if-let [x (first v)] (walk (rest v)) :done))
+		(walk [1 2 3])`, ":done"},
+		{"NotTailVector", `
+		(defn nest [n] (if (= 0 n) [] [(nest (dec n))]))
+		(nest 3)`, "[[[[]]]]"},
+		{"NotTailDo", `
+		(defn f [n] (if (= 0 n) 0 (do (f (dec n)) n)))
+		(f 3)`, "3"},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			s, err := core.Render(eval(t, tt.src))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, s)
+		})
+	}
+}
+
+func TestEvalSource(t *testing.T) {
+	t.Parallel()
+
+	vm, err := lang.New(nil)
+	require.NoError(t, err)
+
+	forms, err := lang.ReadSource("script.ww", strings.NewReader("(def x 1)\n\t (undefined x)"))
+	require.NoError(t, err)
+	require.Len(t, forms, 2)
+
+	_, err = vm.EvalSource(context.Background(), forms[0])
+	require.NoError(t, err)
+
+	// errors are reported at the position of the top-level form, with 1-based columns,
+	// as syntax errors are.
+	_, err = vm.EvalSource(context.Background(), forms[1])
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, core.ErrNotFound), "unexpected error %v", err)
+
+	var re reader.Error
+	require.True(t, errors.As(err, &re), "unexpected error %v", err)
+	assert.Equal(t, "script.ww", re.File)
+	assert.Equal(t, 2, re.Line)
+	assert.Equal(t, 3, re.Col)
+	assert.True(t, strings.HasPrefix(err.Error(), "script.ww:2:3: "), err.Error())
+}
+
+func TestBudget(t *testing.T) {
+	t.Parallel()
+
+	eval := func(t *testing.T, b *lang.EvalBudget, src string) (score.Any, error) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+
+		vm, err := lang.New(mock_ww.NewMockAnchor(ctrl))
+		require.NoError(t, err)
+
+		dec, err := lang.Func("dec", func(n int64) int64 { return n - 1 })
+		require.NoError(t, err)
+		require.NoError(t, vm.Bind(map[string]score.Any{"dec": dec}))
+
+		forms, err := lang.ReadSource("budget.ww", strings.NewReader(src))
+		require.NoError(t, err)
+
+		ctx := lang.WithBudget(context.Background(), b)
+
+		var res score.Any
+		for _, f := range forms {
+			if res, err = vm.EvalSource(ctx, f); err != nil {
+				return nil, err
+			}
+		}
+
+		return res, nil
+	}
+
+	assertRender := func(t *testing.T, want string, v score.Any) {
+		s, err := core.Render(v.(ww.Any))
+		require.NoError(t, err)
+		assert.Equal(t, want, s)
+	}
+
+	const (
+		spin      = "(defn spin [n] (spin n))\n  (spin 0)"
+		grow      = "(defn grow [v] (grow (conj v :grow)))\n(grow [])"
+		nest      = "(defn nest [v] (nest [v v]))\n(nest [:nest])"
+		fill      = "(defn fill [v n] (if (= 0 n) :done (fill (conj v :fill) (dec n))))\n(fill [] 2000)"
+		countdown = "(defn countdown [n] (if (= 0 n) :done (countdown (dec n))))\n(countdown 100)"
+	)
+
+	t.Run("Steps", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := eval(t, &lang.EvalBudget{MaxSteps: 1000}, spin)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, lang.ErrBudget), "unexpected error %v", err)
+
+		var be lang.BudgetExceeded
+		require.True(t, errors.As(err, &be))
+		assert.Equal(t, "steps", be.Limit)
+		assert.Equal(t, int64(1000), be.Max)
+		assert.Equal(t, "spin", be.Fn)
+
+		// the error locates the top-level form
+		assert.Equal(t, "budget.ww", be.File)
+		assert.Equal(t, 2, be.Line)
+		assert.Equal(t, 3, be.Col)
+		assert.True(t, strings.HasPrefix(err.Error(), "budget.ww:2:3: "), err.Error())
+	})
+
+	t.Run("Bytes", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := eval(t, &lang.EvalBudget{MaxBytes: 1 << 16}, grow)
+		require.Error(t, err)
+
+		var be lang.BudgetExceeded
+		require.True(t, errors.As(err, &be), "unexpected error %v", err)
+		assert.Equal(t, "bytes", be.Limit)
+		assert.Equal(t, "__conj__", be.Fn) // the native function that allocated
+		assert.Equal(t, 2, be.Line)
+		assert.Equal(t, 1, be.Col)
+	})
+
+	t.Run("VectorLiteral", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := eval(t, &lang.EvalBudget{MaxBytes: 1 << 16}, nest)
+
+		var be lang.BudgetExceeded
+		require.True(t, errors.As(err, &be), "unexpected error %v", err)
+		assert.Equal(t, "bytes", be.Limit)
+	})
+
+	t.Run("PerEval", func(t *testing.T) {
+		t.Parallel()
+
+		// each evaluation is given its own copy of the budget
+		res, err := eval(t, &lang.EvalBudget{MaxSteps: 500}, countdown+"\n"+countdown)
+		require.NoError(t, err)
+		assertRender(t, ":done", res)
+	})
+
+	t.Run("Isolated", func(t *testing.T) {
+		t.Parallel()
+
+		// allocations by concurrent evaluations are not charged to the budget
+		var wg sync.WaitGroup
+		defer wg.Wait()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := eval(t, nil, fill)
+			assert.NoError(t, err)
+		}()
+
+		for i := 0; i < 10; i++ {
+			res, err := eval(t, &lang.EvalBudget{MaxBytes: 1 << 16}, countdown)
+			require.NoError(t, err)
+			assertRender(t, ":done", res)
+		}
+	})
+
+	t.Run("Unlimited", func(t *testing.T) {
+		t.Parallel()
+
+		res, err := eval(t, nil, countdown)
+		require.NoError(t, err)
+		assertRender(t, ":done", res)
+
+		res, err = eval(t, &lang.EvalBudget{}, countdown)
+		require.NoError(t, err)
+		assertRender(t, ":done", res)
+	})
+}
+
+// counterVM returns an evaluator with a 'tick' builtin that returns its argument,
+// and a 'fail' builtin that returns an error.  Both increment calls.  Evaluation
+// continues past errors, and the first error is returned.
+func counterVM(t *testing.T, calls *int64) func(string) (score.Any, error) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	vm, err := lang.New(mock_ww.NewMockAnchor(ctrl))
+	require.NoError(t, err)
+
+	tick, err := lang.Func("tick", func(n ww.Any) ww.Any {
+		atomic.AddInt64(calls, 1)
+		return n
+	})
+	require.NoError(t, err)
+	fail, err := lang.Func("fail", func() (ww.Any, error) {
+		atomic.AddInt64(calls, 1)
+		return nil, errors.New("test")
+	})
+	require.NoError(t, err)
+	require.NoError(t, vm.Bind(map[string]score.Any{"tick": tick, "fail": fail}))
+
+	return func(src string) (res score.Any, err error) {
+		forms := readAll(t, src)
+
+		for _, f := range forms {
+			var e error
+			if res, e = vm.Eval(f); err == nil {
+				err = e
+			}
+		}
+
+		return
+	}
+}
+
+func TestMemoize(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		desc, src string
+		calls     int64
+	}{
+		{"Cache", `(def f (memoize tick)) (f 1) (f 1) (f 2) (f 2)`, 2},
+		{"CanonicalArgs", `(def f (memoize tick)) (f [1 [:a]]) (f [1 [:a]])`, 1},
+		{"Evict", `(def f (memoize tick 1)) (f 1) (f 2) (f 1)`, 3},
+		{"LRU", `(def f (memoize tick 2)) (f 1) (f 2) (f 1) (f 3) (f 1)`, 3},
+		{"NoCacheErrors", `(def f (memoize fail)) (f) (f)`, 2},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var calls int64
+			_, _ = counterVM(t, &calls)(tt.src)
+			assert.Equal(t, tt.calls, atomic.LoadInt64(&calls))
+		})
+	}
+
+	t.Run("Concurrent", func(t *testing.T) {
+		t.Parallel()
+
+		var calls int64
+		v, err := counterVM(t, &calls)(`(memoize tick 8)`)
+		require.NoError(t, err)
+
+		f, ok := v.(core.Invokable)
+		require.True(t, ok, "memoized fn is not invokable")
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := int64(0); j < 64; j++ {
+					n, err := core.NewInt64(capnp.SingleSegment(nil), j%16)
+					require.NoError(t, err)
+
+					res, err := f.Invoke(n)
+					require.NoError(t, err)
+					assert.Equal(t, j%16, res.(core.Int64).Int64())
+				}
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("InvalidMax", func(t *testing.T) {
+		t.Parallel()
+
+		var calls int64
+		_, err := counterVM(t, &calls)(`(memoize tick 0)`)
+		assert.Error(t, err)
+	})
+}
+
+func TestDelay(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Force", func(t *testing.T) {
+		t.Parallel()
+
+		var calls int64
+		eval := counterVM(t, &calls)
+
+		_, err := eval(`(def d (delay (tick :realized)))`)
+		require.NoError(t, err)
+		assert.Zero(t, atomic.LoadInt64(&calls), "delay was realized eagerly")
+
+		res, err := eval(`(force d) (force d)`)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), atomic.LoadInt64(&calls))
+
+		s, err := core.Render(res.(ww.Any))
+		require.NoError(t, err)
+		assert.Equal(t, ":realized", s)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		t.Parallel()
+
+		var calls int64
+		eval := counterVM(t, &calls)
+
+		_, err := eval(`(def d (delay (fail)))`)
+		require.NoError(t, err)
+
+		_, err1 := eval(`(force d)`)
+		_, err2 := eval(`(force d)`)
+		require.Error(t, err1)
+		assert.Equal(t, err1, err2)
+		assert.Equal(t, int64(1), atomic.LoadInt64(&calls))
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		t.Parallel()
+
+		var calls int64
+		res, err := counterVM(t, &calls)(`(force (delay))`)
+		require.NoError(t, err)
+		assert.Equal(t, core.Nil{}, res)
+	})
+
+	t.Run("NotDelay", func(t *testing.T) {
+		t.Parallel()
+
+		var calls int64
+		res, err := counterVM(t, &calls)(`(= :x (force :x))`)
+		require.NoError(t, err)
+		assert.Equal(t, core.True, res)
+	})
+}
+
+func TestProtocol(t *testing.T) {
+	t.Parallel()
+
+	const proto = `
+	(defprotocol Sized (size [x]) (pair [x y]))
+	(extend-type i64 Sized
+		(size [x] x)
+		(pair [x y] [x y]))
+	(extend-type vector Sized
+		(size [v] (count v))
+		(pair [v y] [(count v) y]))
+	`
+
+	for _, tt := range []struct {
+		desc, src string
+	}{
+		{"Dispatch", `(= [3 2] [(size 3) (size [:a :b])])`},
+		{"Arity", `(= [[3 :x] [2 :y]] [(pair 3 :x) (pair [:a :b] :y)])`},
+		{"Compose", `(= [1 [:a :b] 3] (sort-by size [3 [:a :b] 1]))`},
+		{"Redefine", `
+		(extend-type i64 Sized (size [x] 0))
+		(= 0 (size 3))`},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := newVM(t)(proto + tt.src)
+			require.NoError(t, err)
+			assert.Equal(t, core.True, res)
+		})
+	}
+
+	t.Run("NoExtension", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newVM(t)(proto + `(size :k)`)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, lang.ErrNoExtension), "unexpected error %v", err)
+		assert.Contains(t, err.Error(), "protocol Sized, method size, type keyword")
+	})
+
+	for _, tt := range []struct {
+		desc, src, msg string
+	}{
+		{"NoMethods", `(defprotocol P)`, "requires name and at least one method"},
+		{"NoParams", `(defprotocol P (m []))`, "requires at least 1 param"},
+		{"UnknownType", `(defprotocol P (m [x])) (extend-type widget P (m [x] x))`, "unknown type 'widget'"},
+		{"UnknownMethod", `(defprotocol P (m [x])) (extend-type i64 P (n [x] x))`, "protocol P has no method n"},
+		{"NotProtocol", `(def P 1) (extend-type i64 P (m [x] x))`, "is not a protocol"},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := newVM(t)(tt.src)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.msg)
+		})
+	}
+}
+
+func BenchmarkEvalScript(b *testing.B) {
+	const n = 10000
+
+	var src strings.Builder
+	src.WriteString("(def memo-conj (memoize conj))\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&src, "(memo-conj [0 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20 21 22 23 24 25 26 27 28 29 30 31] %d)\n", i)
+	}
+
+	forms := readAll(b, src.String())
+
+	ctrl := gomock.NewController(b)
+	defer ctrl.Finish()
+
+	vm, err := lang.New(mock_ww.NewMockAnchor(ctrl))
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, f := range forms {
+			if _, err = vm.Eval(f); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkEvalBudget(b *testing.B) {
+	const src = `(defn countdown [n] (if (= 0 n) :done (countdown (dec n)))) (countdown 10000)`
+
+	for _, bc := range []struct {
+		desc   string
+		budget *lang.EvalBudget
+	}{
+		{"Unlimited", nil},
+		{"Steps", &lang.EvalBudget{MaxSteps: 1 << 62}},
+		{"Bytes", &lang.EvalBudget{MaxBytes: 1 << 62}},
+	} {
+		bc := bc
+		b.Run(bc.desc, func(b *testing.B) {
+			ctrl := gomock.NewController(b)
+			defer ctrl.Finish()
+
+			vm, err := lang.New(mock_ww.NewMockAnchor(ctrl))
+			require.NoError(b, err)
+
+			dec, err := lang.Func("dec", func(n int64) int64 { return n - 1 })
+			require.NoError(b, err)
+			require.NoError(b, vm.Bind(map[string]score.Any{"dec": dec}))
+
+			forms := readAll(b, src)
+			ctx := lang.WithBudget(context.Background(), bc.budget)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				for _, f := range forms {
+					if _, err = vm.EvalContext(ctx, f); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDefResolve(b *testing.B) {
+	const n = 10000
+
+	var src strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&src, "(def sym%d %d)\nsym%d\n", i, i, i)
+	}
+
+	forms := readAll(b, src.String())
+
+	ctrl := gomock.NewController(b)
+	defer ctrl.Finish()
+
+	vm, err := lang.New(mock_ww.NewMockAnchor(ctrl))
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, f := range forms {
+			if _, err = vm.Eval(f); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
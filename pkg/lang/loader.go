@@ -0,0 +1,189 @@
+package lang
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
+)
+
+/*
+	loader.go contains the sources from which modules are imported.  Symbols and
+	strings name files in the source path.  Path literals name values in the anchor
+	tree, unless their first segment is the scheme of a loader registered with the VM:
+
+		(import lib.util)             ; file
+		(import "lib/util")           ; file
+		(import /QmHost/lib/util)     ; anchor
+		(import /ipfs/QmSrc/mylib)    ; loader registered for "ipfs"
+*/
+
+const (
+	// MaxSourceSize is the size, in bytes, of the largest module that can be imported.
+	MaxSourceSize = 1 << 20
+
+	schemeFile   = "file"
+	schemeAnchor = "anchor"
+)
+
+var (
+	// ErrSourceTooLarge is returned when a module exceeds MaxSourceSize.
+	ErrSourceTooLarge = fmt.Errorf("source exceeds %d bytes", MaxSourceSize)
+
+	// ErrLoaderConflict is returned when a loader is registered for a scheme that
+	// already has one.
+	ErrLoaderConflict = errors.New("conflicting loader")
+)
+
+// Loader reads the source of a module.
+type Loader interface {
+	// Load the module at the path, which is clean and absolute, and includes the
+	// scheme as its first segment.
+	Load(ctx context.Context, path string) (io.ReadCloser, error)
+}
+
+// LoaderFunc is a function that satisfies Loader.
+type LoaderFunc func(context.Context, string) (io.ReadCloser, error)
+
+// Load calls f.
+func (f LoaderFunc) Load(ctx context.Context, path string) (io.ReadCloser, error) {
+	return f(ctx, path)
+}
+
+// loaderTable holds the loaders registered with a VM, by scheme.
+type loaderTable struct {
+	mu sync.RWMutex
+	m  map[string]Loader
+}
+
+func newLoaderTable() *loaderTable {
+	return &loaderTable{m: make(map[string]Loader)}
+}
+
+func (t *loaderTable) Register(scheme string, l Loader) error {
+	if scheme == "" || strings.Contains(scheme, "/") {
+		return fmt.Errorf("invalid scheme '%s'", scheme)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.m[scheme]; ok || scheme == schemeFile || scheme == schemeAnchor {
+		return fmt.Errorf("%w: scheme '%s'", ErrLoaderConflict, scheme)
+	}
+
+	t.m[scheme] = l
+	return nil
+}
+
+func (t *loaderTable) Lookup(scheme string) (Loader, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	l, ok := t.m[scheme]
+	return l, ok
+}
+
+// Module is a source of forms that can be imported.
+type Module struct {
+	Loader Loader
+
+	// Scheme identifies the loader, so that modules with the same path read by
+	// different loaders are distinct.
+	Scheme string
+
+	// Path identifies the module within its scheme.  It appears in error locations,
+	// and in the chain of modules reported by ErrImportCycle.
+	Path string
+}
+
+func (m Module) key() moduleKey { return moduleKey{scheme: m.Scheme, path: m.Path} }
+
+type moduleKey struct{ scheme, path string }
+
+// read the module's source in full, so that a module that is too large is rejected
+// before any of it is evaluated.
+func (m Module) read(ctx context.Context) ([]byte, error) {
+	rc, err := m.Loader.Load(ctx, m.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	src, err := ioutil.ReadAll(io.LimitReader(rc, MaxSourceSize+1))
+	if err == nil && len(src) > MaxSourceSize {
+		err = fmt.Errorf("%s: %w", m.Path, ErrSourceTooLarge)
+	}
+
+	return src, err
+}
+
+func fileModule(path string) Module {
+	return Module{Loader: fileLoader{}, Scheme: schemeFile, Path: path}
+}
+
+// pathModule returns the module named by a path literal.
+func pathModule(root ww.Anchor, loaders *loaderTable, path string) (Module, error) {
+	path, err := anchorpath.Clean(path)
+	if err != nil {
+		return Module{}, err
+	}
+
+	parts := anchorpath.Parts(path)
+	if len(parts) == 0 {
+		return Module{}, fmt.Errorf("cannot import root path")
+	}
+
+	if l, ok := loaders.Lookup(parts[0]); ok {
+		return Module{Loader: l, Scheme: parts[0], Path: path}, nil
+	}
+
+	if root == nil {
+		return Module{}, ErrNoCluster
+	}
+
+	return Module{Loader: anchorLoader{root}, Scheme: schemeAnchor, Path: path}, nil
+}
+
+type fileLoader struct{}
+
+func (fileLoader) Load(_ context.Context, path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
+
+// anchorLoader reads source from string values in the anchor tree.
+type anchorLoader struct{ root ww.Anchor }
+
+func (l anchorLoader) Load(ctx context.Context, path string) (io.ReadCloser, error) {
+	a := l.root.Walk(ctx, anchorpath.Parts(path))
+	defer a.Release()
+
+	v, err := a.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if core.IsNil(v) {
+		return nil, fmt.Errorf("%w: %s", core.ErrNotFound, path)
+	}
+
+	if v.Value().Which() != mem.Any_Which_str {
+		return nil, fmt.Errorf("%s: expected str, got %s", path, v.Value().Which())
+	}
+
+	src, err := v.Value().Str()
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.NopCloser(strings.NewReader(src)), nil
+}
@@ -0,0 +1,227 @@
+package lang
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	score "github.com/spy16/slurp/core"
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	memutil "github.com/wetware/ww/pkg/util/mem"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+// MemoCap is the default maximum number of results cached by a memoized function.
+const MemoCap = 1 << 10
+
+var (
+	_ core.Invokable   = (*memoFn)(nil)
+	_ contextInvokable = (*memoFn)(nil)
+	_ core.Expr        = (*DelayExpr)(nil)
+)
+
+// memoFn caches the results of an invokable, keyed by the canonical encoding of
+// its arguments.  The least-recently used result is evicted when the cache is full.
+// Errors are not cached.
+type memoFn struct {
+	fn  invokableAny
+	cap int
+
+	mu    sync.Mutex
+	lru   *list.List // of *memoEntry, most-recently used first
+	cache map[string]*list.Element
+}
+
+type invokableAny interface {
+	ww.Any
+	core.Invokable
+}
+
+type memoEntry struct {
+	key string
+	val ww.Any
+}
+
+// (memoize f max?)
+func fnMemoize(fn core.Invokable, max ...core.Int64) (*memoFn, error) {
+	if len(max) > 1 {
+		return nil, fmt.Errorf("%w: got %d, want at-most 2", core.ErrArity, len(max)+1)
+	}
+
+	f, ok := fn.(invokableAny)
+	if !ok {
+		return nil, fmt.Errorf("cannot memoize '%s'", reflect.TypeOf(fn))
+	}
+
+	cap := MemoCap
+	if len(max) == 1 {
+		if cap = int(max[0].Int64()); cap <= 0 {
+			return nil, fmt.Errorf("max entries must be positive, got %d", cap)
+		}
+	}
+
+	return &memoFn{
+		fn:    f,
+		cap:   cap,
+		lru:   list.New(),
+		cache: make(map[string]*list.Element),
+	}, nil
+}
+
+func (m *memoFn) Value() mem.Any { return m.fn.Value() }
+
+func (m *memoFn) Render() (string, error) {
+	s, err := core.Render(m.fn)
+	return "<memoized " + s + ">", err
+}
+
+func (m *memoFn) Invoke(args ...ww.Any) (ww.Any, error) {
+	return m.InvokeContext(context.Background(), args...)
+}
+
+// InvokeContext returns the cached result for args, if any.  Otherwise, it invokes
+// the underlying function and caches the result.  Concurrent calls with the same
+// uncached arguments may each invoke the function; the last result wins.
+func (m *memoFn) InvokeContext(ctx context.Context, args ...ww.Any) (ww.Any, error) {
+	key, err := m.key(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if v, ok := m.get(key); ok {
+		return v, nil
+	}
+
+	var v ww.Any
+	if t, ok := m.fn.(contextInvokable); ok {
+		v, err = t.InvokeContext(ctx, args...)
+	} else {
+		v, err = m.fn.Invoke(args...)
+	}
+
+	if err == nil {
+		m.put(key, v)
+	}
+
+	return v, err
+}
+
+// key returns the canonical encoding of the arguments.  The vector holding them does
+// not outlive the call, so it is allocated in a scratch arena.
+func (m *memoFn) key(args []ww.Any) (string, error) {
+	arena := memutil.NewScratch(len(args) * 64)
+	defer arena.Release()
+
+	v, err := core.NewVector(arena, args...)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := core.Canonical(v)
+	return string(b), err
+}
+
+func (m *memoFn) get(key string) (ww.Any, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.cache[key]
+	if !ok {
+		return nil, false
+	}
+
+	m.lru.MoveToFront(e)
+	return e.Value.(*memoEntry).val, true
+}
+
+func (m *memoFn) put(key string, val ww.Any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.cache[key]; ok {
+		e.Value.(*memoEntry).val = val
+		m.lru.MoveToFront(e)
+		return
+	}
+
+	m.cache[key] = m.lru.PushFront(&memoEntry{key: key, val: val})
+
+	for m.lru.Len() > m.cap {
+		e := m.lru.Back()
+		m.lru.Remove(e)
+		delete(m.cache, e.Value.(*memoEntry).key)
+	}
+}
+
+// Delay is a lazy value.  Its body is evaluated at most once, by the first call
+// to force.  Subsequent calls return the same value or error.
+type Delay struct {
+	sym  core.Symbol
+	body core.Expr
+	env  core.Env
+
+	once sync.Once
+	val  ww.Any
+	err  error
+}
+
+// Value returns the memory value.  Note that this is a placeholder; the delayed
+// value is obtained by forcing it.
+func (d *Delay) Value() mem.Any { return d.sym.Value() }
+
+// Render the delay in a human-readable format.  Rendering does not force the delay.
+func (d *Delay) Render() (string, error) { return "<delay>", nil }
+
+// Force evaluates the body on the first call, and returns the result.
+func (d *Delay) Force() (ww.Any, error) {
+	d.once.Do(func() {
+		var v score.Any
+		if v, d.err = d.body.Eval(d.env); d.err == nil {
+			d.val = v.(ww.Any)
+		}
+	})
+
+	return d.val, d.err
+}
+
+// (force x) forces x if it is a delay, else returns x.
+func fnForce(any ww.Any) (ww.Any, error) {
+	if d, ok := any.(*Delay); ok {
+		return d.Force()
+	}
+
+	return any, nil
+}
+
+// DelayExpr represents the (delay body*) form.
+type DelayExpr struct {
+	Body DoExpr
+}
+
+// Eval returns an unrealized delay that closes over env.
+func (de DelayExpr) Eval(env core.Env) (score.Any, error) {
+	sym, err := core.NewSymbol(capnp.SingleSegment(nil), "delay")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Delay{sym: sym, body: de.Body, env: env}, nil
+}
+
+func parseDelay(a core.Analyzer, env core.Env, seq core.Seq) (core.Expr, error) {
+	body, err := parseDo(a, env, seq)
+	if err != nil {
+		return nil, err
+	}
+
+	de := DelayExpr{Body: body.(DoExpr)}
+	if len(de.Body.Exprs) == 0 {
+		de.Body.Exprs = []core.Expr{ConstExpr{core.Nil{}}}
+	}
+
+	return de, nil
+}
@@ -0,0 +1,141 @@
+package lang
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+// procTable tracks the local processes spawned by an interpreter.  Processes are
+// reaped from the table as soon as they terminate, so only running processes can be
+// addressed by ID.  Their results remain available through the process handle.
+type procTable struct {
+	mu   sync.Mutex
+	next uint64
+	ps   map[uint64]core.LocalProcess
+}
+
+func newProcTable() *procTable {
+	return &procTable{ps: make(map[uint64]core.LocalProcess)}
+}
+
+// Spawn a process.  The process is canceled when ctx expires.
+func (t *procTable) Spawn(ctx context.Context, f core.ProcFunc) (core.LocalProcess, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.next++
+	p, err := core.Spawn(ctx, t.next, t.reaped(t.next, f))
+	if err != nil {
+		return core.LocalProcess{}, err
+	}
+
+	t.ps[p.ID()] = p
+	return p, nil
+}
+
+// reaped wraps f such that the process is removed from the table before its result
+// is made available.  Callers MUST hold the lock while spawning the process, so that
+// it cannot be reaped before it is added to the table.
+func (t *procTable) reaped(id uint64, f core.ProcFunc) core.ProcFunc {
+	return func(ctx context.Context) (ww.Any, error) {
+		defer func() {
+			t.mu.Lock()
+			delete(t.ps, id)
+			t.mu.Unlock()
+		}()
+
+		return f(ctx)
+	}
+}
+
+// Get the process with the specified ID.
+func (t *procTable) Get(id uint64) (p core.LocalProcess, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok = t.ps[id]
+	return
+}
+
+// List running processes, sorted by ID.
+func (t *procTable) List() []core.LocalProcess {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ps := make([]core.LocalProcess, 0, len(t.ps))
+	for _, p := range t.ps {
+		ps = append(ps, p)
+	}
+
+	sort.Slice(ps, func(i, j int) bool { return ps[i].ID() < ps[j].ID() })
+	return ps
+}
+
+func processes(t *procTable) bindFunc {
+	return func(env core.Env) error {
+		return bindAll(env,
+			function("wait", "__wait__", func(ctx context.Context, any ww.Any) (ww.Any, error) {
+				p, err := t.resolve(any)
+				if err != nil {
+					return nil, err
+				}
+
+				return p.Wait(ctx)
+			}),
+			function("done?", "__done__", func(any ww.Any) (bool, error) {
+				p, err := t.resolve(any)
+				return err == nil && p.Terminated(), err
+			}),
+			function("result", "__result__", func(any ww.Any) (ww.Any, error) {
+				p, err := t.resolve(any)
+				if err != nil {
+					return nil, err
+				}
+
+				return p.Result()
+			}),
+			function("ps", "__ps__", func() (core.Vector, error) {
+				ps := t.List()
+				vs := make([]ww.Any, len(ps))
+				for i, p := range ps {
+					vs[i] = p
+				}
+
+				return core.NewVector(capnp.SingleSegment(nil), vs...)
+			}),
+			function("kill", "__kill__", func(any ww.Any) error {
+				p, err := t.resolve(any)
+				if err == nil {
+					p.Cancel()
+				}
+				return err
+			}))
+	}
+}
+
+// resolve a process handle or the ID of a running process.
+func (t *procTable) resolve(any ww.Any) (core.LocalProcess, error) {
+	switch v := any.(type) {
+	case core.LocalProcess:
+		return v, nil
+
+	case core.Int64:
+		if p, ok := t.Get(uint64(v.Int64())); ok {
+			return p, nil
+		}
+
+		return core.LocalProcess{}, core.Error{
+			Cause:   core.ErrNotFound,
+			Message: fmt.Sprintf("proc %d", v.Int64()),
+		}
+	}
+
+	return core.LocalProcess{}, fmt.Errorf("expected process or process ID, got '%s'",
+		any.Value().Which())
+}
@@ -0,0 +1,372 @@
+package lang
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/spy16/slurp"
+	score "github.com/spy16/slurp/core"
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+var (
+	// ErrNoExtension is returned when a protocol method is invoked on a value whose
+	// type does not extend the protocol.
+	ErrNoExtension = errors.New("no protocol extension")
+
+	_ core.Expr = (*DefProtocolExpr)(nil)
+	_ core.Expr = (*ExtendTypeExpr)(nil)
+
+	_ core.Invokable = (*protocolMethod)(nil)
+)
+
+// typeTags maps the names returned by the 'type' builtin to the corresponding
+// memory type.  Protocols dispatch on these tags.
+var typeTags = func() map[string]mem.Any_Which {
+	m := make(map[string]mem.Any_Which)
+	for w := mem.Any_Which_nil; w <= mem.Any_Which_proc; w++ {
+		m[w.String()] = w
+	}
+	return m
+}()
+
+// Protocol is a named set of methods that dispatch on the type of their first
+// argument.  Types are extended with implementations at runtime, so built-in types
+// can be extended from user code.
+type Protocol struct {
+	sym core.Symbol
+
+	mu      sync.RWMutex
+	methods map[string]*protocolMethod
+}
+
+func newProtocol(name string, methods []string) (*Protocol, error) {
+	sym, err := core.NewSymbol(capnp.SingleSegment(nil), name)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Protocol{sym: sym, methods: make(map[string]*protocolMethod, len(methods))}
+	for _, m := range methods {
+		if p.methods[m], err = p.newMethod(m); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// Value returns the memory value.
+func (p *Protocol) Value() mem.Any { return p.sym.Value() }
+
+// Name of the protocol.
+func (p *Protocol) Name() string {
+	name, _ := p.sym.Symbol()
+	return name
+}
+
+// Render the protocol in a human-readable format.
+func (p *Protocol) Render() (string, error) { return "<protocol " + p.Name() + ">", nil }
+
+func (p *Protocol) extend(w mem.Any_Which, method string, impl core.Invokable) error {
+	m, ok := p.methods[method]
+	if !ok {
+		return core.Error{
+			Cause:   ErrNoExtension,
+			Message: fmt.Sprintf("protocol %s has no method %s", p.Name(), method),
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	m.impls[w] = impl
+	return nil
+}
+
+func (p *Protocol) newMethod(name string) (*protocolMethod, error) {
+	sym, err := core.NewSymbol(capnp.SingleSegment(nil), name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &protocolMethod{
+		proto: p,
+		sym:   sym,
+		impls: make(map[mem.Any_Which]core.Invokable),
+	}, nil
+}
+
+// protocolMethod invokes the implementation that extends the type of its first
+// argument.
+type protocolMethod struct {
+	proto *Protocol
+	sym   core.Symbol
+	impls map[mem.Any_Which]core.Invokable // guarded by proto.mu
+}
+
+func (m *protocolMethod) Value() mem.Any { return m.sym.Value() }
+
+func (m *protocolMethod) Render() (string, error) {
+	name, err := m.sym.Symbol()
+	return "<method " + m.proto.Name() + "/" + name + ">", err
+}
+
+func (m *protocolMethod) Invoke(args ...ww.Any) (ww.Any, error) {
+	return m.InvokeContext(context.Background(), args...)
+}
+
+func (m *protocolMethod) InvokeContext(ctx context.Context, args ...ww.Any) (ww.Any, error) {
+	name, err := m.sym.Symbol()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) == 0 {
+		return nil, core.Error{
+			Cause:   core.ErrArity,
+			Message: fmt.Sprintf("%s/%s requires at least 1 argument", m.proto.Name(), name),
+		}
+	}
+
+	w := args[0].Value().Which()
+
+	m.proto.mu.RLock()
+	impl, ok := m.impls[w]
+	m.proto.mu.RUnlock()
+
+	if !ok {
+		return nil, core.Error{
+			Cause: ErrNoExtension,
+			Message: fmt.Sprintf("protocol %s, method %s, type %s",
+				m.proto.Name(), name, w),
+		}
+	}
+
+	if t, ok := impl.(contextInvokable); ok {
+		return t.InvokeContext(ctx, args...)
+	}
+
+	return impl.Invoke(args...)
+}
+
+// DefProtocolExpr represents the (defprotocol name (method [params]+)+) form.
+type DefProtocolExpr struct {
+	Name    string
+	Methods []string
+}
+
+// Eval binds the protocol and its methods in the root env.  Redefining a protocol
+// discards the extensions of the previous definition.
+func (de DefProtocolExpr) Eval(env core.Env) (score.Any, error) {
+	p, err := newProtocol(de.Name, de.Methods)
+	if err != nil {
+		return nil, err
+	}
+
+	root := score.Root(env)
+	if err = root.Bind(de.Name, p); err != nil {
+		return nil, err
+	}
+
+	for name, m := range p.methods {
+		if err = root.Bind(name, m); err != nil {
+			return nil, err
+		}
+	}
+
+	return core.NewSymbol(capnp.SingleSegment(nil), de.Name)
+}
+
+// ExtendTypeExpr represents the (extend-type type (protocol (method ...)+)+) form.
+type ExtendTypeExpr struct {
+	Analyzer core.Analyzer
+	Type     mem.Any_Which
+	Impls    []ProtocolImpl
+}
+
+// ProtocolImpl holds the method implementations of a protocol.
+type ProtocolImpl struct {
+	Protocol core.Symbol
+	Methods  map[string]core.Fn
+}
+
+// Eval extends the type with the method implementations.  Implementations are bound
+// to env.
+func (ee ExtendTypeExpr) Eval(env core.Env) (score.Any, error) {
+	for _, impl := range ee.Impls {
+		v, err := ResolveExpr{impl.Protocol}.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+
+		p, ok := v.(*Protocol)
+		if !ok {
+			return nil, core.Error{
+				Cause:   ErrNoExtension,
+				Message: fmt.Sprintf("'%s' is not a protocol", v.(ww.Any).Value().Which()),
+			}
+		}
+
+		for name, fn := range impl.Methods {
+			c := closure{Fn: fn, analyzer: ee.Analyzer, env: env}
+			if err = p.extend(ee.Type, name, c); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return core.Nil{}, nil
+}
+
+// parseDefProtocol parses the (defprotocol name (method [params]+)+) special form.
+func parseDefProtocol(_ core.Analyzer, _ core.Env, seq core.Seq) (core.Expr, error) {
+	e := core.Error{Cause: fmt.Errorf("%w: defprotocol", slurp.ErrParseSpecial)}
+
+	if seq == nil {
+		return nil, e.With("requires name and at least one method")
+	}
+
+	args, err := core.ToSlice(seq)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) < 2 {
+		return nil, e.With("requires name and at least one method")
+	}
+
+	name, err := symbolName(args[0])
+	if err != nil {
+		return nil, e.With("name must be symbol")
+	}
+
+	methods := make([]string, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		sig, ok := arg.(core.Seq)
+		if !ok {
+			return nil, e.With(fmt.Sprintf(
+				"method signature must be list, not '%s'", reflect.TypeOf(arg)))
+		}
+
+		items, err := core.ToSlice(sig)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(items) < 2 {
+			return nil, e.With("method requires name and parameter vector")
+		}
+
+		m, err := symbolName(items[0])
+		if err != nil {
+			return nil, e.With("method name must be symbol")
+		}
+
+		for _, params := range items[1:] {
+			v, ok := params.(core.Vector)
+			if !ok {
+				return nil, e.With(fmt.Sprintf("method %s: params must be vector", m))
+			}
+
+			if cnt, err := v.Count(); err != nil {
+				return nil, err
+			} else if cnt == 0 {
+				return nil, e.With(fmt.Sprintf("method %s: requires at least 1 param", m))
+			}
+		}
+
+		methods = append(methods, m)
+	}
+
+	return DefProtocolExpr{Name: name, Methods: methods}, nil
+}
+
+// parseExtendType parses the (extend-type type (protocol (method [params] body*)+)+)
+// special form.  The type is one of the names returned by the 'type' builtin.
+func parseExtendType(a core.Analyzer, env core.Env, seq core.Seq) (core.Expr, error) {
+	e := core.Error{Cause: fmt.Errorf("%w: extend-type", slurp.ErrParseSpecial)}
+
+	if seq == nil {
+		return nil, e.With("requires type, protocol and at least one method")
+	}
+
+	args, err := core.ToSlice(seq)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) < 3 {
+		return nil, e.With("requires type, protocol and at least one method")
+	}
+
+	tname, err := symbolName(args[0])
+	if err != nil {
+		return nil, e.With("type must be symbol")
+	}
+
+	w, ok := typeTags[tname]
+	if !ok {
+		return nil, e.With(fmt.Sprintf("unknown type '%s'", tname))
+	}
+
+	expr := ExtendTypeExpr{Analyzer: a, Type: w}
+	for _, arg := range args[1:] {
+		if sym, ok := arg.(core.Symbol); ok {
+			expr.Impls = append(expr.Impls, ProtocolImpl{
+				Protocol: sym,
+				Methods:  make(map[string]core.Fn),
+			})
+			continue
+		}
+
+		if len(expr.Impls) == 0 {
+			return nil, e.With("method must follow protocol name")
+		}
+
+		method, ok := arg.(core.Seq)
+		if !ok {
+			return nil, e.With(fmt.Sprintf(
+				"method must be list, not '%s'", reflect.TypeOf(arg)))
+		}
+
+		first, err := method.First()
+		if err != nil {
+			return nil, err
+		}
+
+		name, err := symbolName(first)
+		if err != nil {
+			return nil, e.With("method name must be symbol")
+		}
+
+		fn, err := parseFnDef(env, method, false)
+		if err != nil {
+			return nil, err
+		}
+
+		expr.Impls[len(expr.Impls)-1].Methods[name] = fn.(core.Fn)
+	}
+
+	for _, impl := range expr.Impls {
+		if len(impl.Methods) == 0 {
+			return nil, e.With("protocol requires at least one method")
+		}
+	}
+
+	return expr, nil
+}
+
+func symbolName(any ww.Any) (string, error) {
+	if any == nil || any.Value().Which() != mem.Any_Which_symbol {
+		return "", errors.New("not a symbol")
+	}
+
+	return any.Value().Symbol()
+}
@@ -0,0 +1,39 @@
+package lang
+
+import (
+	"context"
+	"fmt"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+)
+
+// pubsubs binds the pubsub builtins.  Joining a topic requires a PubSub capability,
+// which the embedder binds, e.g. to the 'pubsub' symbol of the shell.
+func pubsubs() bindFunc {
+	return func(env core.Env) error {
+		return bindAll(env,
+			function("topic", "__topic__", func(ps core.PubSub, name core.String) (core.Topic, error) {
+				s, err := name.Value().Str()
+				if err != nil {
+					return core.Topic{}, err
+				}
+
+				return ps.Join(s)
+			}),
+			function("publish", "__publish__", func(ctx context.Context, t core.Topic, v ww.Any) error {
+				return t.Publish(ctx, v)
+			}),
+			function("subscribe", "__subscribe__", func(t core.Topic, n ...core.Int64) (core.Chan, error) {
+				switch len(n) {
+				case 0:
+					return t.Subscribe(0)
+				case 1:
+					return t.Subscribe(int(n[0].Int64()))
+				}
+
+				return core.Chan{}, fmt.Errorf("%w: expected at most 2 arguments, got %d",
+					core.ErrArity, len(n)+1)
+			}))
+	}
+}
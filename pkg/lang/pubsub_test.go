@@ -0,0 +1,150 @@
+package lang_test
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/host"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/multiformats/go-multiaddr"
+	score "github.com/spy16/slurp/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mock_ww "github.com/wetware/ww/internal/test/mock/pkg"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang"
+	"github.com/wetware/ww/pkg/lang/core"
+)
+
+func TestPubSub(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	mn := mocknet.New(ctx)
+	for _, a := range []string{"/ip4/127.0.0.1/tcp/2040", "/ip4/127.0.0.1/tcp/2041"} {
+		sk, _, err := crypto.GenerateEd25519Key(rand.Reader)
+		require.NoError(t, err)
+
+		_, err = mn.AddPeer(sk, multiaddr.StringCast(a))
+		require.NoError(t, err)
+	}
+
+	subscriber := newPubSubVM(ctx, t, ctrl, mn.Hosts()[0])
+	publisher := newPubSubVM(ctx, t, ctrl, mn.Hosts()[1])
+
+	require.NoError(t, mn.LinkAll())
+	require.NoError(t, mn.ConnectAllButSelf())
+
+	_, err := subscriber(ctx, `(def sub (subscribe (topic pubsub "greetings")))`)
+	require.NoError(t, err)
+
+	// publish until the subscriber has received a value, since the subscription
+	// takes a moment to propagate to the publisher
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for {
+			_, _ = publisher(ctx, `(publish (topic pubsub "greetings") [:hello "world" '(1 2.5)])`)
+
+			select {
+			case <-time.After(time.Millisecond * 100):
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	got, err := subscriber(ctx, `(recv! sub)`)
+	require.NoError(t, err)
+
+	s, err := core.Render(got.(ww.Any))
+	require.NoError(t, err)
+	assert.Equal(t, `[:hello "world" (1 2.5)]`, s)
+
+	res, err := subscriber(ctx, `(= [:hello "world" '(1 2.5)] (recv! sub))`)
+	require.NoError(t, err)
+	assert.Equal(t, core.True, res)
+}
+
+// newPubSubVM returns a function that evaluates source code in an interpreter whose
+// 'pubsub' symbol joins the topics of a gossipsub router running on h.
+func newPubSubVM(ctx context.Context, t *testing.T, ctrl *gomock.Controller, h host.Host) func(context.Context, string) (score.Any, error) {
+	gs, err := pubsub.NewGossipSub(ctx, h)
+	require.NoError(t, err)
+
+	topics := map[string]*pubsub.Topic{}
+	ps, err := core.NewPubSub(func(name string) (core.TopicHandle, error) {
+		if top, ok := topics[name]; ok {
+			return topicHandle{top}, nil
+		}
+
+		top, err := gs.Join(name)
+		if err == nil {
+			topics[name] = top
+		}
+
+		return topicHandle{top}, err
+	})
+	require.NoError(t, err)
+
+	vm, err := lang.New(mock_ww.NewMockAnchor(ctrl))
+	require.NoError(t, err)
+	require.NoError(t, vm.Bind(map[string]score.Any{"pubsub": ps}))
+
+	return func(ctx context.Context, src string) (res score.Any, err error) {
+		for _, form := range readAll(t, src) {
+			if res, err = vm.EvalContext(ctx, form); err != nil {
+				break
+			}
+		}
+
+		return
+	}
+}
+
+// topicHandle adapts a gossipsub topic to core.TopicHandle.
+type topicHandle struct{ *pubsub.Topic }
+
+func (t topicHandle) Publish(ctx context.Context, data []byte) error {
+	return t.Topic.Publish(ctx, data)
+}
+
+func (t topicHandle) Messages(ctx context.Context) (<-chan []byte, error) {
+	sub, err := t.Subscribe()
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan []byte)
+	go func() {
+		defer close(ch)
+		defer sub.Cancel()
+
+		for {
+			msg, err := sub.Next(ctx)
+			if err != nil {
+				return
+			}
+
+			select {
+			case ch <- msg.Data:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	score "github.com/spy16/slurp/core"
 	"github.com/spy16/slurp/reader"
@@ -14,6 +15,7 @@ import (
 
 	ww "github.com/wetware/ww/pkg"
 	"github.com/wetware/ww/pkg/lang/core"
+	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
 )
 
 var symbols = map[string]score.Any{
@@ -22,12 +24,34 @@ var symbols = map[string]score.Any{
 	"true":  core.True,
 }
 
+// readSymbol reads a symbol, and reports it to the symbol hook.
+func (t *Table) readSymbol(rd *reader.Reader, init rune) (score.Any, error) {
+	beginPos := rd.Position()
+
+	form, err := readSymbol(rd, init)
+	if sym, ok := form.(core.Symbol); ok && err == nil {
+		name, _ := sym.Symbol()
+		t.symbolRead(name, beginPos)
+	}
+
+	return form, err
+}
+
+// symbolRead reports the symbol to the hook.  The position is that of the reader
+// after the first rune of the symbol, whose column is therefore the 1-based column
+// of the symbol.
+func (t *Table) symbolRead(name string, pos reader.Position) {
+	if t.onSymbol != nil {
+		t.onSymbol(name, pos.Ln, pos.Col)
+	}
+}
+
 func readSymbol(rd *reader.Reader, init rune) (score.Any, error) {
 	beginPos := rd.Position()
 
 	s, err := rd.Token(init)
 	if err != nil {
-		return nil, annotateErr(rd, err, beginPos, s)
+		return nil, annotateErr(err, beginPos, s)
 	}
 
 	if predefVal, found := symbols[s]; found {
@@ -38,36 +62,50 @@ func readSymbol(rd *reader.Reader, init rune) (score.Any, error) {
 	return core.NewSymbol(capnp.SingleSegment(nil), s)
 }
 
+// readString reads a string literal.  A literal opening with three quotes is a raw
+// string, which ends at the next three quotes and in which backslashes are literal.
+// A raw string therefore cannot contain three consecutive quotes, nor end in one.
 func readString(rd *reader.Reader, init rune) (score.Any, error) {
 	beginPos := rd.Position()
 
-	var b strings.Builder
+	raw, err := isRawString(rd)
+	if err != nil {
+		return nil, annotateErr(err, beginPos, string(init))
+	}
+
+	var (
+		b      strings.Builder
+		quotes int // consecutive closing quotes in a raw string
+	)
+
 	for {
 		r, err := rd.NextRune()
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				err = reader.ErrEOF
 			}
-			return nil, annotateErr(rd, err, beginPos, string(init)+b.String())
+			return nil, annotateErr(err, beginPos, string(init)+b.String())
 		}
 
-		if r == '\\' {
-			r2, err := rd.NextRune()
-			if err != nil {
-				if errors.Is(err, io.EOF) {
-					err = reader.ErrEOF
-				}
+		if raw {
+			if quotes++; r != '"' {
+				quotes = 0
+			}
 
-				return nil, annotateErr(rd, err, beginPos, string(init)+b.String())
+			b.WriteRune(r)
+			if quotes == 3 {
+				s := b.String()
+				return core.NewString(capnp.SingleSegment(nil), s[:len(s)-3])
 			}
 
-			// TODO: Support for Unicode escape \uNN format.
+			continue
+		}
 
-			escaped, err := getEscape(r2)
-			if err != nil {
-				return nil, err
+		if r == '\\' {
+			escPos := rd.Position()
+			if r, err = readEscape(rd); err != nil {
+				return nil, annotateErr(err, escPos, string(init)+b.String())
 			}
-			r = escaped
 		} else if r == '"' {
 			break
 		}
@@ -80,6 +118,97 @@ func readString(rd *reader.Reader, init rune) (score.Any, error) {
 	return core.NewString(capnp.SingleSegment(nil), b.String())
 }
 
+// isRawString consumes the two quotes following the opening quote of a raw string.
+// Otherwise, it consumes nothing.
+func isRawString(rd *reader.Reader) (bool, error) {
+	r, err := rd.NextRune()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = reader.ErrEOF
+		}
+		return false, err
+	}
+
+	if r != '"' {
+		rd.Unread(r)
+		return false, nil
+	}
+
+	// empty string, unless followed by a third quote
+	r, err = rd.NextRune()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			rd.Unread('"')
+			return false, nil
+		}
+		return false, err
+	}
+
+	if r != '"' {
+		rd.Unread(r)
+		rd.Unread('"')
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// readEscape reads the escape sequence following a backslash.
+func readEscape(rd *reader.Reader) (rune, error) {
+	r, err := rd.NextRune()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = reader.ErrEOF
+		}
+		return -1, err
+	}
+
+	switch r {
+	case 'u':
+		return readHexEscape(rd, r, 4)
+	case 'x':
+		return readHexEscape(rd, r, 2)
+	}
+
+	return getEscape(r)
+}
+
+// readHexEscape reads exactly n hex digits, which give the code point.
+func readHexEscape(rd *reader.Reader, init rune, n int) (rune, error) {
+	digits := make([]rune, 0, n)
+	for len(digits) < n {
+		r, err := rd.NextRune()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return -1, err
+		}
+
+		if !isHexDigit(r) {
+			rd.Unread(r)
+			break
+		}
+
+		digits = append(digits, r)
+	}
+
+	if len(digits) < n {
+		return -1, fmt.Errorf("truncated escape sequence '\\%c%s': want %d hex digits",
+			init, string(digits), n)
+	}
+
+	code, err := strconv.ParseUint(string(digits), 16, 32)
+	if err != nil {
+		return -1, err
+	}
+
+	return rune(code), nil
+}
+
+func isHexDigit(r rune) bool {
+	return ('0' <= r && r <= '9') || ('a' <= r && r <= 'f') || ('A' <= r && r <= 'F')
+}
+
 func readComment(rd *reader.Reader, _ rune) (score.Any, error) {
 	for {
 		r, err := rd.NextRune()
@@ -100,7 +229,7 @@ func readKeyword(rd *reader.Reader, init rune) (score.Any, error) {
 
 	token, err := rd.Token(-1)
 	if err != nil {
-		return nil, annotateErr(rd, err, beginPos, token)
+		return nil, annotateErr(err, beginPos, token)
 	}
 
 	// TODO(performance):  pre-allocate the arena based on the token length +
@@ -108,12 +237,17 @@ func readKeyword(rd *reader.Reader, init rune) (score.Any, error) {
 	return core.NewKeyword(capnp.SingleSegment(nil), token)
 }
 
+// readCharacter reads a character literal:  a single character, a name such as
+// \newline, or a unicode escape such as \u00e9.
 func readCharacter(rd *reader.Reader, _ rune) (score.Any, error) {
 	beginPos := rd.Position()
 
 	r, err := rd.NextRune()
 	if err != nil {
-		return nil, annotateErr(rd, err, beginPos, "")
+		if errors.Is(err, io.EOF) {
+			err = reader.ErrEOF
+		}
+		return nil, annotateErr(err, beginPos, "\\")
 	}
 
 	token, err := rd.Token(r)
@@ -128,95 +262,308 @@ func readCharacter(rd *reader.Reader, _ rune) (score.Any, error) {
 		return core.NewChar(capnp.SingleSegment(nil), runes[0])
 	}
 
-	chr, found := charLiterals[token]
-	if found {
-		return chr, nil
+	if r, ok := core.CharNamed(token); ok {
+		return core.NewChar(capnp.SingleSegment(nil), r)
 	}
 
-	if token[0] == 'u' {
+	if runes[0] == 'u' && len(runes) == 5 {
 		return readUnicodeChar(token[1:], 16)
 	}
 
-	return nil, fmt.Errorf("unsupported character: '\\%s'", token)
+	return nil, fmt.Errorf("unknown character literal '\\%s'", token)
 }
 
-func readList(rd *reader.Reader, _ rune) (score.Any, error) {
+func (t *Table) readList(rd *reader.Reader, _ rune) (score.Any, error) {
 	const listEnd = ')'
 
 	beginPos := rd.Position()
 
 	forms := make([]ww.Any, 0, 32) // pre-allocate to improve performance on small lists
-	if err := rd.Container(listEnd, "list", func(val score.Any) error {
+	if err := t.container(rd, listEnd, func(val score.Any) error {
 		forms = append(forms, val.(ww.Any))
 		return nil
 	}); err != nil {
-		return nil, annotateErr(rd, err, beginPos, "")
+		return nil, annotateErr(err, beginPos, "")
 	}
 
 	// TODO(performance):  can we pre-allocate here?
 	return core.NewList(capnp.SingleSegment(nil), forms...)
 }
 
-func readVector(rd *reader.Reader, _ rune) (score.Any, error) {
+func (t *Table) readVector(rd *reader.Reader, _ rune) (score.Any, error) {
 	const vecEnd = ']'
 
 	beginPos := rd.Position()
 
 	var vec core.Container = core.EmptyVector
-	if err := rd.Container(vecEnd, "vector", func(val score.Any) (err error) {
+	if err := t.container(rd, vecEnd, func(val score.Any) (err error) {
 		vec, err = vec.Conj(val.(ww.Any))
 		return
 	}); err != nil {
-		return nil, annotateErr(rd, err, beginPos, "")
+		return nil, annotateErr(err, beginPos, "")
 	}
 
 	return vec, nil
 }
 
-func quoteFormReader(expandFunc string) reader.Macro {
+// container reads forms until the end rune is reached, calling f on each form.
+// Unlike reader.Reader.Container, it advances rd's position.
+func (t *Table) container(rd *reader.Reader, end rune, f func(score.Any) error) error {
+	for {
+		if err := rd.SkipSpaces(); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = reader.ErrEOF
+			}
+			return err
+		}
+
+		r, err := rd.NextRune()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = reader.ErrEOF
+			}
+			return err
+		}
+
+		if r == end {
+			return nil
+		}
+		rd.Unread(r)
+
+		form, err := t.readOne(rd)
+		if err != nil {
+			if errors.Is(err, reader.ErrSkip) {
+				continue
+			}
+
+			if errors.Is(err, io.EOF) {
+				err = reader.ErrEOF
+			}
+			return err
+		}
+
+		if err = f(form); err != nil {
+			return err
+		}
+	}
+}
+
+// readDispatch reads the form following '#' using the dispatch table.  If the next
+// rune does not trigger a dispatch macro, it begins the tag of a tagged literal.
+func (t *Table) readDispatch(rd *reader.Reader, init rune) (score.Any, error) {
+	r, err := rd.NextRune()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = reader.ErrEOF
+		}
+		return nil, err
+	}
+
+	if m, ok := t.dispatch[r]; ok {
+		return m(rd, r)
+	}
+
+	if rd.IsTerminal(r) {
+		return nil, fmt.Errorf("invalid dispatch '%c%c'", init, r)
+	}
+
+	return t.readTagged(rd, r)
+}
+
+// readTagged reads a tagged literal, i.e. #tag form, and passes the form to the
+// data reader registered for the tag.
+func (t *Table) readTagged(rd *reader.Reader, init rune) (score.Any, error) {
+	tag, err := rd.Token(init)
+	if err != nil {
+		return nil, err
+	}
+
+	f, ok := t.tags[tag]
+	if !ok {
+		return nil, fmt.Errorf("%w '#%s'", ErrUnknownTag, tag)
+	}
+
+	form, err := t.readNext(rd)
+	if err != nil {
+		return nil, err
+	}
+
+	return f(form.(ww.Any))
+}
+
+// readDiscard reads and discards the next form.  The form is fully read, so
+// syntax errors are reported.  Discards nest:  #_#_ a b discards both a and b.
+func (t *Table) readDiscard(rd *reader.Reader, _ rune) (score.Any, error) {
+	if _, err := t.readNext(rd); err != nil {
+		return nil, err
+	}
+
+	return nil, reader.ErrSkip
+}
+
+// readNext reads the next form, skipping no-op forms.  Reaching the end of the
+// input is an error.
+func (t *Table) readNext(rd *reader.Reader) (score.Any, error) {
+	for {
+		form, err := t.readOne(rd)
+		if errors.Is(err, reader.ErrSkip) {
+			continue
+		}
+
+		if errors.Is(err, io.EOF) {
+			err = reader.ErrEOF
+		}
+
+		return form, err
+	}
+}
+
+// readRegex reads a regex literal, i.e. the pattern following #".  Backslashes are
+// passed through to the pattern, except that \" stands for a quote.  The pattern is
+// compiled at read time.
+func readRegex(rd *reader.Reader, _ rune) (score.Any, error) {
+	var b strings.Builder
+	for {
+		r, err := rd.NextRune()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = reader.ErrEOF
+			}
+			return nil, err
+		}
+
+		if r == '"' {
+			break
+		}
+
+		if r == '\\' {
+			if r, err = rd.NextRune(); err != nil {
+				if errors.Is(err, io.EOF) {
+					err = reader.ErrEOF
+				}
+				return nil, err
+			}
+
+			if r != '"' {
+				b.WriteRune('\\')
+			}
+		}
+
+		b.WriteRune(r)
+	}
+
+	return core.NewRegex(capnp.SingleSegment(nil), b.String())
+}
+
+func unmatchedDelimiter(_ *reader.Reader, init rune) (score.Any, error) {
+	return nil, fmt.Errorf("%w '%c'", reader.ErrUnmatchedDelimiter, init)
+}
+
+// prefixReader returns a macro that reads the next form and wraps it in a call to
+// the named function, e.g. 'x reads as (quote x).  Errors in the form are reported
+// at their own position.
+func (t *Table) prefixReader(prefix, expandFunc string) reader.Macro {
 	sym, err := core.NewSymbol(capnp.SingleSegment(nil), expandFunc)
 	if err != nil {
 		panic(err)
 	}
 
 	return func(rd *reader.Reader, _ rune) (score.Any, error) {
-		expr, err := rd.One()
+		// the reader is positioned after the prefix, which is on a single line
+		pos := rd.Position()
+		pos.Col -= utf8.RuneCountInString(prefix) - 1
+		t.symbolRead(expandFunc, pos)
+
+		form, err := t.readNext(rd)
 		if err != nil {
-			if err == io.EOF {
-				return nil, reader.Error{
-					Form:  expandFunc,
-					Cause: reader.ErrEOF,
-				}
-			} else if err == reader.ErrSkip {
-				return nil, reader.Error{
-					Form:  expandFunc,
-					Cause: errors.New("cannot quote a no-op form"),
-				}
+			if _, ok := err.(Error); !ok && errors.Is(err, reader.ErrEOF) {
+				err = macroEOF(prefix)
 			}
+
+			return nil, err
+		}
+
+		return core.NewList(capnp.SingleSegment(nil), sym, form.(ww.Any))
+	}
+}
+
+// unquoteReader returns a macro that reads ~x as (unquote x), and ~@x as
+// (unquote-splicing x).
+func (t *Table) unquoteReader() reader.Macro {
+	unquote := t.prefixReader("~", "unquote")
+	splice := t.prefixReader("~@", "unquote-splicing")
+
+	return func(rd *reader.Reader, init rune) (score.Any, error) {
+		r, err := rd.NextRune()
+		if errors.Is(err, io.EOF) {
+			return nil, macroEOF(string(init))
+		} else if err != nil {
 			return nil, err
 		}
 
-		return core.NewList(capnp.SingleSegment(nil), sym, expr.(ww.Any))
+		if r == '@' {
+			return splice(rd, r)
+		}
+
+		rd.Unread(r)
+		return unquote(rd, init)
 	}
 }
 
+// macroEOF is returned when the input ends after a prefix such as '@'.
+type macroEOF string
+
+func (p macroEOF) Error() string {
+	return fmt.Sprintf("unexpected EOF after reader macro '%s'", string(p))
+}
+
+func (macroEOF) Is(err error) bool { return err == reader.ErrEOF }
+
+// readPath reads an anchor path.  A backslash escapes the following rune, so that
+// terminal runes can appear in the path.  The backslash is kept:  ls uses it to
+// match glob metacharacters literally.  Malformed paths are rejected, and '.' and
+// '..' components are resolved.
 func readPath(rd *reader.Reader, char rune) (_ score.Any, err error) {
+	beginPos := rd.Position()
+
 	var b strings.Builder
 	for {
 		b.WriteRune(char)
 
-		if char, err = rd.NextRune(); err != nil {
+		if char == '\\' {
+			if char, err = rd.NextRune(); err != nil {
+				if errors.Is(err, io.EOF) {
+					err = reader.ErrEOF
+				}
+				return
+			}
+
+			b.WriteRune(char)
+		}
+
+		if char, err = rd.NextRune(); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
 			return
 		}
 
-		if char != '/' && rd.IsTerminal(char) {
+		if char != '/' && char != '\\' && rd.IsTerminal(char) {
 			rd.Unread(char)
 			break
 		}
 	}
 
+	path := b.String()
+	if err = anchorpath.Validate(path); err == nil {
+		path, err = anchorpath.Clean(path)
+	}
+
+	if err != nil {
+		return nil, annotateErr(err, beginPos, b.String())
+	}
+
 	// TODO(performance): pre-allocate the arena
-	return core.NewPath(capnp.SingleSegment(nil), b.String())
+	return core.NewPath(capnp.SingleSegment(nil), path)
 }
 
 func readUnicodeChar(token string, base int) (ww.Any, error) {
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"math"
 	"math/big"
 	"strconv"
 	"strings"
@@ -17,6 +16,10 @@ import (
 	"github.com/wetware/ww/pkg/lang/core"
 )
 
+// readNumber reads a numeric literal.  Supported forms are integers (with 0x, 0o
+// and 0b prefixes), radix integers such as 2r1010, bigints with an 'N' suffix,
+// ratios such as 22/7, and floats with optional exponent.  Integer literals that
+// overflow int64 are read as bigints.
 func readNumber(rd *reader.Reader, init rune) (v score.Any, err error) {
 	beginPos := rd.Position()
 
@@ -25,20 +28,23 @@ func readNumber(rd *reader.Reader, init rune) (v score.Any, err error) {
 		return nil, err
 	}
 
-	decimalPoint := strings.ContainsRune(numStr, '.')
+	digits := strings.TrimLeft(numStr, "+-")
+	isHex := strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X")
 	isRadix := strings.ContainsRune(numStr, 'r')
-	isScientific := strings.ContainsRune(numStr, 'e')
+	isBig := strings.HasSuffix(numStr, "N") && !isRadix
+	decimalPoint := strings.ContainsRune(numStr, '.')
+	isScientific := strings.ContainsAny(numStr, "eE")
 	isFrac := strings.ContainsRune(numStr, '/')
 
 	switch {
-	case isRadix && (decimalPoint || isScientific || isFrac):
-		err = reader.ErrNumberFormat
+	case isBig:
+		v, err = parseBigInt(numStr[:len(numStr)-1])
 
-	case isScientific:
-		v, err = parseScientific(numStr)
+	case isHex:
+		v, err = parseInt(numStr)
 
-	case decimalPoint:
-		v, err = parseFloat(numStr)
+	case isRadix && (decimalPoint || isFrac):
+		err = fmt.Errorf("%w (radix notation): '%s'", reader.ErrNumberFormat, numStr)
 
 	case isRadix:
 		v, err = parseRadix(numStr)
@@ -46,13 +52,16 @@ func readNumber(rd *reader.Reader, init rune) (v score.Any, err error) {
 	case isFrac:
 		v, err = parseFrac(numStr)
 
+	case decimalPoint || isScientific:
+		v, err = parseFloat(numStr)
+
 	default:
 		v, err = parseInt(numStr)
 
 	}
 
 	if err != nil {
-		err = annotateErr(rd, err, beginPos, numStr)
+		err = annotateErr(err, beginPos, numStr)
 	}
 
 	return
@@ -66,13 +75,8 @@ func parseInt(numStr string) (core.Numerical, error) {
 		return core.NewInt64(capnp.SingleSegment(nil), v)
 
 	case errors.Is(err, strconv.ErrRange):
-		var b big.Int
-		if _, ok := b.SetString(numStr, 0); !ok {
-			return nil, fmt.Errorf("%w (bigint): '%s'", reader.ErrNumberFormat, numStr)
-		}
+		return parseBigInt(numStr)
 
-		// TODO(performance):  pre-allocate arena
-		return core.NewBigInt(capnp.SingleSegment(nil), &b)
 	default:
 		return nil, fmt.Errorf("%w (int64): '%s'", reader.ErrNumberFormat, numStr)
 
@@ -96,11 +100,21 @@ func parseFloat(numStr string) (core.Numerical, error) {
 		return core.NewBigFloat(capnp.SingleSegment(nil), &f)
 
 	default:
-		return nil, reader.ErrNumberFormat
+		return nil, fmt.Errorf("%w (float): '%s'", reader.ErrNumberFormat, numStr)
 
 	}
 }
 
+func parseBigInt(numStr string) (core.Numerical, error) {
+	var b big.Int
+	if _, ok := b.SetString(numStr, 0); !ok {
+		return nil, fmt.Errorf("%w (bigint): '%s'", reader.ErrNumberFormat, numStr)
+	}
+
+	// TODO(performance):  pre-allocate arena
+	return core.NewBigInt(capnp.SingleSegment(nil), &b)
+}
+
 func parseRadix(numStr string) (core.Numerical, error) {
 	parts := strings.Split(numStr, "r")
 	if len(parts) != 2 {
@@ -118,6 +132,11 @@ func parseRadix(numStr string) (core.Numerical, error) {
 		repr = "-" + repr
 	}
 
+	if base < 2 || base > 36 {
+		return nil, fmt.Errorf("%w (radix %d out of range): '%s'",
+			reader.ErrNumberFormat, base, numStr)
+	}
+
 	v, err := strconv.ParseInt(repr, int(base), 64)
 	if errors.Is(err, strconv.ErrRange) {
 		var bi big.Int
@@ -134,36 +153,6 @@ func parseRadix(numStr string) (core.Numerical, error) {
 	return core.NewInt64(capnp.SingleSegment(nil), v)
 }
 
-func parseScientific(numStr string) (core.Numerical, error) {
-	parts := strings.Split(numStr, "e")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("%w (scientific notation): '%s'", reader.ErrNumberFormat, numStr)
-	}
-
-	base, err := strconv.ParseFloat(parts[0], 64)
-	if err != nil {
-		return nil, fmt.Errorf("%w (scientific notation): '%s'", reader.ErrNumberFormat, numStr)
-	}
-
-	pow, err := strconv.ParseInt(parts[1], 10, 64)
-	if err != nil {
-		return nil, fmt.Errorf("%w (scientific notation): '%s'", reader.ErrNumberFormat, numStr)
-	}
-
-	f := base * math.Pow(10, float64(pow))
-
-	if math.IsInf(f, 0) {
-		var bf big.Float
-		if _, ok := bf.SetString(numStr); !ok {
-			return nil, fmt.Errorf("%w (bigfloat): '%s'", reader.ErrNumberFormat, numStr)
-		}
-
-		return core.NewBigFloat(capnp.SingleSegment(nil), &bf)
-	}
-
-	return core.NewFloat64(capnp.SingleSegment(nil), f)
-}
-
 func parseFrac(numStr string) (core.Numerical, error) {
 	parts := strings.Split(numStr, "/")
 	if len(parts) != 2 || parts[1] == "" {
@@ -178,6 +167,10 @@ func parseFrac(numStr string) (core.Numerical, error) {
 		return nil, fmt.Errorf("%w (denominator): '%s'", reader.ErrNumberFormat, numStr)
 	}
 
+	if denom.Sign() == 0 {
+		return nil, fmt.Errorf("%w (zero denominator): '%s'", reader.ErrNumberFormat, numStr)
+	}
+
 	var r big.Rat
 	return core.NewFraction(capnp.SingleSegment(nil), r.SetFrac(&numer, &denom))
 }
@@ -2,97 +2,301 @@ package reader
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
 	"reflect"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
+	score "github.com/spy16/slurp/core"
 	"github.com/spy16/slurp/reader"
-	"github.com/wetware/ww/pkg/lang/core"
-	capnp "zombiezen.com/go/capnproto2"
+
+	ww "github.com/wetware/ww/pkg"
 )
 
 var (
+	// ErrConflict is returned when two options register the same macro or tag.
+	ErrConflict = errors.New("conflicting registration")
+
+	// ErrUnknownTag is returned when a tagged literal has no data reader.
+	ErrUnknownTag = errors.New("unknown tag")
+
 	escapeMap = map[rune]rune{
 		'"':  '"',
 		'n':  '\n',
 		'\\': '\\',
 		't':  '\t',
 		'a':  '\a',
-		'f':  '\a',
+		'f':  '\f',
 		'r':  '\r',
 		'b':  '\b',
 		'v':  '\v',
 	}
+)
+
+// Macro reads a form.  It is called with the reader positioned after init, the
+// rune that triggered the macro.  Returning reader.ErrSkip discards the form.
+type Macro func(rd *reader.Reader, init rune) (ww.Any, error)
+
+// DataReader converts the form following a tag, as in #uuid "...".
+type DataReader func(form ww.Any) (ww.Any, error)
+
+// Option configures a Table.
+type Option func(*Table) error
+
+// WithMacro registers a macro.  A dispatch macro is triggered by '#' followed
+// by init.  Macros shadow the built-in syntax for the same rune.
+func WithMacro(init rune, dispatch bool, m Macro) Option {
+	return func(t *Table) error {
+		if isSpace(init) || unicode.IsNumber(init) {
+			return fmt.Errorf("invalid macro character %q", init)
+		}
+
+		key := macroKey{init: init, dispatch: dispatch}
+		if t.custom[key] {
+			return fmt.Errorf("%w: macro '%s'", ErrConflict, key)
+		}
+		t.custom[key] = true
 
-	macroTable = map[rune]macro{
-		'"':  {fn: readString},
-		';':  {fn: readComment},
-		':':  {fn: readKeyword},
-		'\\': {fn: readCharacter},
-		'(':  {fn: readList},
-		')':  {fn: reader.UnmatchedDelimiter()},
-		'[':  {fn: readVector},
-		']':  {fn: reader.UnmatchedDelimiter()},
-		'\'': {fn: quoteFormReader("quote")},
-		'~':  {fn: quoteFormReader("unquote")},
-		'`':  {fn: quoteFormReader("syntax-quote")},
-		'/':  {fn: readPath},
+		if dispatch {
+			t.dispatch[init] = m.macro()
+		} else {
+			t.macros[init] = annotated(m.macro())
+		}
+
+		return nil
 	}
+}
 
-	charLiterals = make(map[string]core.Char, 6)
-)
+// WithDataReader registers a data reader for tagged literals, i.e. #tag form.
+func WithDataReader(tag string, f DataReader) Option {
+	return func(t *Table) error {
+		if tag == "" || strings.IndexFunc(tag, isSpace) >= 0 {
+			return fmt.Errorf("invalid tag '%s'", tag)
+		}
+
+		if _, ok := t.tags[tag]; ok {
+			return fmt.Errorf("%w: tag '#%s'", ErrConflict, tag)
+		}
+
+		t.tags[tag] = f
+		return nil
+	}
+}
 
-func init() {
-	for k, v := range map[string]rune{
-		"tab":       '\t',
-		"space":     ' ',
-		"newline":   '\n',
-		"return":    '\r',
-		"backspace": '\b',
-		"formfeed":  '\f',
+// WithSymbolHook calls f with the name and position of each symbol read, in the order
+// in which the symbols occur in the input.  This includes the symbols introduced by
+// prefix macros, e.g. quote in 'x, which are reported at the position of the prefix,
+// and the symbols in discarded forms.  Positions are reported as by Error.
+func WithSymbolHook(f func(name string, line, col int)) Option {
+	return func(t *Table) error {
+		t.onSymbol = f
+		return nil
+	}
+}
+
+// Table holds the reader macros and data readers.  The built-in syntax is
+// registered in the table before any options are applied.
+type Table struct {
+	macros   map[rune]reader.Macro // annotated
+	dispatch map[rune]reader.Macro
+	tags     map[string]DataReader
+	custom   map[macroKey]bool // registered by options
+
+	numReader, symReader reader.Macro
+	onSymbol             func(name string, line, col int) // nil if unset
+}
+
+// NewTable returns a table containing the built-in syntax and the registrations
+// of the supplied options.
+func NewTable(opts ...Option) (*Table, error) {
+	t := &Table{
+		macros:    make(map[rune]reader.Macro),
+		tags:      make(map[string]DataReader),
+		custom:    make(map[macroKey]bool),
+		numReader: annotated(readNumber),
+	}
+	t.symReader = annotated(t.readSymbol)
+
+	t.dispatch = map[rune]reader.Macro{
+		'_':  t.readDiscard,
+		'"':  readRegex,
+		'\'': t.prefixReader("#'", "var"),
+	}
+
+	for init, m := range map[rune]reader.Macro{
+		'"':  readString,
+		';':  readComment,
+		':':  readKeyword,
+		'\\': readCharacter,
+		'(':  t.readList,
+		')':  unmatchedDelimiter,
+		'[':  t.readVector,
+		']':  unmatchedDelimiter,
+		'\'': t.prefixReader("'", "quote"),
+		'`':  t.prefixReader("`", "syntax-quote"),
+		'~':  t.unquoteReader(),
+		'@':  t.prefixReader("@", "deref"),
+		'/':  readPath,
+		'#':  t.readDispatch,
 	} {
-		c, err := core.NewChar(capnp.SingleSegment(nil), v)
-		if err != nil {
-			panic(err)
+		t.macros[init] = annotated(m)
+	}
+
+	for _, opt := range opts {
+		if err := opt(t); err != nil {
+			return nil, err
 		}
+	}
 
-		charLiterals[k] = c
+	// a tag beginning with a dispatch character is unreachable
+	for tag := range t.tags {
+		if r, _ := utf8.DecodeRuneInString(tag); t.dispatch[r] != nil {
+			return nil, fmt.Errorf("%w: tag '#%s' shadowed by macro '#%c'",
+				ErrConflict, tag, r)
+		}
 	}
+
+	return t, nil
 }
 
-// New returns a lisp reader instance which can read forms from r.
+// New returns a reader that reads forms from r using the table.
 // File name is inferred from the value & type information of 'r' OR
 // can be set manually on the Reader instance returned.
-func New(r io.Reader) *reader.Reader {
+//
+// Errors other than io.EOF are reported as Error.
+func (t *Table) New(r io.Reader) *reader.Reader {
 	rd := reader.New(r,
-		reader.WithNumReader(readNumber),
-		reader.WithSymbolReader(readSymbol))
+		reader.WithNumReader(t.numReader),
+		reader.WithSymbolReader(t.symReader))
 
-	for init, macro := range macroTable {
-		rd.SetMacro(init, macro.dispatch, macro.fn)
+	for init, m := range t.macros {
+		rd.SetMacro(init, false, m)
 	}
 
 	return rd
 }
 
-func annotateErr(rd *reader.Reader, err error, beginPos reader.Position, form string) error {
+// Error is returned when a form cannot be read.  Line and Col give the position
+// at which the offending form begins.  For unterminated strings and containers,
+// this is the position of the opening delimiter.  Columns count runes, not bytes.
+type Error struct {
+	File      string
+	Line, Col int
+	Form      string
+	Cause     error
+}
+
+// Is returns true if the other error is the same as the cause of this error.
+func (e Error) Is(other error) bool { return errors.Is(e.Cause, other) }
+
+// Unwrap returns the underlying cause of the error.
+func (e Error) Unwrap() error { return e.Cause }
+
+func (e Error) Error() string {
+	file := e.File
+	if file == "" {
+		file = "<unknown>"
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %v", file, e.Line, e.Col, e.Cause)
+}
+
+// New returns a lisp reader instance which can read forms from r, using the
+// built-in syntax extended by opts.  It fails if the options conflict.  See
+// Table.New.
+func New(r io.Reader, opts ...Option) (*reader.Reader, error) {
+	t, err := NewTable(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.New(r), nil
+}
+
+// readOne reads the next form.  Unlike reader.Reader.One, it returns reader.ErrSkip
+// for no-op forms such as comments, rather than reading past them.  This allows
+// containers to check for the closing delimiter after a no-op form.
+func (t *Table) readOne(rd *reader.Reader) (score.Any, error) {
+	if err := rd.SkipSpaces(); err != nil {
+		return nil, err
+	}
+
+	r, err := rd.NextRune()
+	if err != nil {
+		return nil, err
+	}
+
+	if unicode.IsNumber(r) {
+		return t.numReader(rd, r)
+	}
+
+	if r == '+' || r == '-' {
+		r2, err := rd.NextRune()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		if err != io.EOF {
+			rd.Unread(r2)
+			if unicode.IsNumber(r2) {
+				return t.numReader(rd, r)
+			}
+		}
+	}
+
+	if m, ok := t.macros[r]; ok {
+		return m(rd, r)
+	}
+
+	return t.symReader(rd, r)
+}
+
+// annotated returns a macro that reports errors at the position of the rune that
+// triggered m.
+func annotated(m reader.Macro) reader.Macro {
+	return func(rd *reader.Reader, init rune) (score.Any, error) {
+		beginPos := rd.Position()
+
+		form, err := m(rd, init)
+		if err != nil {
+			return nil, annotateErr(err, beginPos, "")
+		}
+
+		return form, nil
+	}
+}
+
+// annotateErr reports err at beginPos.  Errors that are already annotated are
+// returned unchanged, so that errors in nested forms report the innermost position.
+func annotateErr(err error, beginPos reader.Position, form string) error {
 	if err == io.EOF || err == reader.ErrSkip {
 		return err
 	}
 
-	readErr := reader.Error{}
+	if _, ok := err.(Error); ok {
+		return err
+	}
+
+	readErr := Error{
+		File:  beginPos.File,
+		Line:  beginPos.Ln,
+		Col:   beginPos.Col,
+		Form:  form,
+		Cause: err,
+	}
+
 	if e, ok := err.(reader.Error); ok {
-		readErr = e
-	} else {
-		readErr = reader.Error{Cause: err}
+		readErr.Cause = e.Cause
+		if e.Form != "" && form == "" {
+			readErr.Form = e.Form
+		}
 	}
 
-	readErr.Form = form
-	readErr.Begin = beginPos
-	readErr.End = rd.Position()
 	return readErr
 }
 
@@ -115,7 +319,21 @@ func inferFileName(rs io.Reader) string {
 	}
 }
 
-type macro struct {
+func (m Macro) macro() reader.Macro {
+	return func(rd *reader.Reader, init rune) (score.Any, error) {
+		return m(rd, init)
+	}
+}
+
+type macroKey struct {
+	init     rune
 	dispatch bool
-	fn       reader.Macro
+}
+
+func (k macroKey) String() string {
+	if k.dispatch {
+		return "#" + string(k.init)
+	}
+
+	return string(k.init)
 }
@@ -0,0 +1,714 @@
+package reader_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/spy16/slurp/reader"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	capnp "zombiezen.com/go/capnproto2"
+
+	"github.com/wetware/ww/internal/mem"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	wwreader "github.com/wetware/ww/pkg/lang/reader"
+	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
+)
+
+func TestString(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		desc, src string
+		want      []string
+	}{
+		{"Empty", `""`, []string{""}},
+		{"EmptyPair", `"" "x"`, []string{"", "x"}},
+		{"EmptyAtEOF", `"x" ""`, []string{"x", ""}},
+		{"Escapes", `"a\"b\\c\nd\te\rf"`, []string{"a\"b\\c\nd\te\rf"}},
+		{"Unicode", `"\u00e9\u65E5"`, []string{"é日"}},
+		{"Hex", `"\x41\x7e"`, []string{"A~"}},
+		{"HexFollowedByDigit", `"\x411"`, []string{"A1"}},
+		{"Raw", `"""C:\path\n"with" quotes"""`, []string{`C:\path\n"with" quotes`}},
+		{"RawMultiline", "\"\"\"a\n  b\"\"\" :k", []string{"a\n  b"}},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			forms, err := newReader(t, tt.src).All()
+			require.NoError(t, err)
+
+			var got []string
+			for _, form := range forms {
+				if str, ok := form.(core.String); ok {
+					s, err := str.Value().Str()
+					require.NoError(t, err)
+					got = append(got, s)
+				}
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	for _, tt := range []struct {
+		desc, src string
+		col       int
+	}{
+		{"UnknownEscape", `"ab\q"`, 4},
+		{"TruncatedUnicode", `"\u12"`, 2},
+		{"TruncatedHex", `"x\x4`, 3},
+		{"EscapeAtEOF", `"\`, 2},
+		{"UnterminatedRaw", `:k """abc""`, 4},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := newReader(t, tt.src).All()
+			require.Error(t, err)
+
+			var e wwreader.Error
+			require.True(t, errors.As(err, &e), "unexpected error %#v", err)
+			assert.Equal(t, 1, e.Line, "wrong line")
+			assert.Equal(t, tt.col, e.Col, "wrong column")
+		})
+	}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		t.Parallel()
+
+		for _, s := range []string{
+			"", `"`, `\`, "tab\there", "line\nbreak\r\n", "\x00\x1b[0m", "\u200b\u202e",
+			"héllo 日本 🏳️‍🌈", `C:\Users\"ww"`,
+		} {
+			str, err := core.NewString(capnp.SingleSegment(nil), s)
+			require.NoError(t, err)
+
+			src, err := core.Render(str)
+			require.NoError(t, err)
+
+			form, err := newReader(t, src).One()
+			require.NoError(t, err, "read %s", src)
+
+			got, err := form.(core.String).Value().Str()
+			require.NoError(t, err)
+			assert.Equal(t, s, got, "round-trip through %s", src)
+		}
+	})
+}
+
+func TestCharacter(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		src  string
+		want rune
+	}{
+		{`\a`, 'a'},
+		{`\é`, 'é'},
+		{`\)`, ')'},
+		{`\u`, 'u'},
+		{`\newline`, '\n'},
+		{`\space`, ' '},
+		{`\tab`, '\t'},
+		{`\u65e5`, '日'},
+	} {
+		form, err := newReader(t, tt.src).One()
+		require.NoError(t, err, tt.src)
+		require.IsType(t, core.Char{}, form, tt.src)
+		assert.Equal(t, tt.want, form.(core.Char).Char(), tt.src)
+
+		// round-trip
+		s, err := core.Render(form.(core.Char))
+		require.NoError(t, err)
+		form, err = newReader(t, s).One()
+		require.NoError(t, err, s)
+		assert.Equal(t, tt.want, form.(core.Char).Char(), s)
+	}
+
+	for _, src := range []string{`\bogus`, `\abc`, `\u12`, `\u12g4`, `(:a \`} {
+		_, err := newReader(t, src).All()
+		require.Error(t, err, src)
+
+		var e wwreader.Error
+		assert.True(t, errors.As(err, &e), "unexpected error %#v", err)
+	}
+
+	_, err := newReader(t, `[\a \bogus]`).All()
+	require.Error(t, err)
+	assert.Equal(t, "<string>:1:5: unknown character literal '\\bogus'", err.Error())
+}
+
+func TestDiscard(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		desc, src, want string
+	}{
+		{"TrailingComment", "(a) ; no trailing newline", "(a)"},
+		{"OnlyComment", "; nothing here", ""},
+		{"CommentInVector", "[1 ; one\n 2 ; two\n]", "[1 2]"},
+		{"CommentInList", "(a ; comment\n)", "(a)"},
+		{"Discard", "[1 #_ 2 3]", "[1 3]"},
+		{"DiscardLast", "[1 #_ 2]", "[1]"},
+		{"DiscardNested", "#_#_ a b c", "c"},
+		{"DiscardContainer", "[#_ [x (y)] :k]", "[:k]"},
+		{"DiscardSkipsComment", "#_ ; comment\n a b", "b"},
+		{"DiscardAtTop", "a #_ b", "a"},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			forms, err := newReader(t, tt.src).All()
+			require.NoError(t, err)
+
+			rendered := make([]string, len(forms))
+			for i, form := range forms {
+				rendered[i], err = core.Render(form.(ww.Any))
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.want, strings.Join(rendered, " "))
+		})
+	}
+
+	for _, tt := range []struct {
+		desc, src string
+		cause     error
+	}{
+		{"DiscardAtEOF", "a #_", reader.ErrEOF},
+		{"DiscardUnterminated", "#_ [1 2", reader.ErrEOF},
+		{"DiscardBadString", "#_ \"abc", reader.ErrEOF},
+		{"DiscardBadChar", "#_ \\bogus a", nil},
+		{"DiscardDelimiter", "[1 #_]", reader.ErrUnmatchedDelimiter},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := newReader(t, tt.src).All()
+			require.Error(t, err)
+
+			if tt.cause != nil {
+				assert.True(t, errors.Is(err, tt.cause), "unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestRegex(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		src, pattern string
+	}{
+		{`#"a+b"`, "a+b"},
+		{`#"\d+\.\d*"`, `\d+\.\d*`},
+		{`#"say \"hi\""`, `say "hi"`},
+		{`#""`, ""},
+	} {
+		form, err := newReader(t, tt.src).One()
+		require.NoError(t, err, tt.src)
+		require.IsType(t, core.Regex{}, form, tt.src)
+		assert.Equal(t, tt.pattern, form.(core.Regex).Regexp().String(), tt.src)
+
+		// round-trip
+		s, err := core.Render(form.(core.Regex))
+		require.NoError(t, err)
+		form, err = newReader(t, s).One()
+		require.NoError(t, err, s)
+		assert.Equal(t, tt.pattern, form.(core.Regex).Regexp().String(), s)
+	}
+
+	for _, tt := range []struct {
+		desc, src string
+		cause     error
+	}{
+		{"Invalid", "[:a\n #\"(\"]", nil},
+		{"Unterminated", "[:a\n #\"abc", reader.ErrEOF},
+	} {
+		_, err := newReader(t, tt.src).All()
+		require.Error(t, err, tt.desc)
+
+		var e wwreader.Error
+		require.True(t, errors.As(err, &e), "unexpected error %#v", err)
+		assert.Equal(t, 2, e.Line, tt.desc)
+		assert.Equal(t, 2, e.Col, tt.desc)
+
+		if tt.cause != nil {
+			assert.True(t, errors.Is(err, tt.cause), "%s: unexpected error %v", tt.desc, err)
+		}
+	}
+}
+
+func TestNumber(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		src, want string
+		which     mem.Any_Which
+	}{
+		{"42", "42", mem.Any_Which_i64},
+		{"-42", "-42", mem.Any_Which_i64},
+		{"0x1F", "31", mem.Any_Which_i64},
+		{"-0x1e", "-30", mem.Any_Which_i64},
+		{"2r1010", "10", mem.Any_Which_i64},
+		{"-2r1010", "-10", mem.Any_Which_i64},
+		{"36rZZ", "1295", mem.Any_Which_i64},
+		{"16rFE", "254", mem.Any_Which_i64},
+		{"123N", "123N", mem.Any_Which_bigInt},
+		{"-123N", "-123N", mem.Any_Which_bigInt},
+		{"9223372036854775808", "9223372036854775808N", mem.Any_Which_bigInt},
+		{"-9223372036854775809", "-9223372036854775809N", mem.Any_Which_bigInt},
+		{"22/7", "22/7", mem.Any_Which_frac},
+		{"-22/7", "-22/7", mem.Any_Which_frac},
+		{"1.5", "1.5", mem.Any_Which_f64},
+		{"-1.0", "-1.0", mem.Any_Which_f64},
+		{"6.02e23", "6.02e+23", mem.Any_Which_f64},
+		{"-6.02E-23", "-6.02e-23", mem.Any_Which_f64},
+		{"1e400", "1e+400", mem.Any_Which_bigFloat},
+	} {
+		form, err := newReader(t, tt.src).One()
+		require.NoError(t, err, tt.src)
+
+		v := form.(ww.Any)
+		assert.Equal(t, tt.which, v.Value().Which(), tt.src)
+
+		s, err := core.Render(v)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, s, tt.src)
+
+		// round-trip
+		form, err = newReader(t, s).One()
+		require.NoError(t, err, s)
+		assert.Equal(t, tt.which, form.(ww.Any).Value().Which(), s)
+
+		ok, err := core.Eq(v, form.(ww.Any))
+		require.NoError(t, err)
+		assert.True(t, ok, "%s did not round-trip", tt.src)
+	}
+
+	for _, src := range []string{"1.2.3", "5rG", "1r0", "37r1", "2r1.0", "1/0", "1/", "0xG"} {
+		_, err := newReader(t, "[:a\n "+src+"]").All()
+		require.Error(t, err, src)
+		assert.True(t, errors.Is(err, reader.ErrNumberFormat), "%s: unexpected error %v", src, err)
+
+		var e wwreader.Error
+		require.True(t, errors.As(err, &e), "unexpected error %#v", err)
+		assert.Equal(t, 2, e.Line, src)
+		assert.Equal(t, 2, e.Col, src)
+	}
+}
+
+func TestError(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		desc, src string
+		line, col int
+		cause     error
+	}{
+		{"UnmatchedInNestedVector", "[1 2\n [3 )]", 2, 5, reader.ErrUnmatchedDelimiter},
+		{"UnterminatedString", "(foo\n  \"bar", 2, 3, reader.ErrEOF},
+		{"UnterminatedVector", "[1 [2 3]", 1, 1, reader.ErrEOF},
+		{"UnterminatedNested", "(a\n  [[1 2", 2, 4, reader.ErrEOF},
+		{"UnterminatedComment", "(a ; b", 1, 1, reader.ErrEOF},
+		{"AfterMultibyte", "\"héllo\" )", 1, 9, reader.ErrUnmatchedDelimiter},
+		{"MultibyteInVector", "[:ü :ö\n :日本 \\bad]", 2, 6, nil},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := newReader(t, tt.src).All()
+			require.Error(t, err)
+
+			var e wwreader.Error
+			require.True(t, errors.As(err, &e), "unexpected error %#v", err)
+			assert.Equal(t, tt.line, e.Line, "wrong line")
+			assert.Equal(t, tt.col, e.Col, "wrong column")
+
+			if tt.cause != nil {
+				assert.True(t, errors.Is(err, tt.cause), "unexpected cause %v", e.Cause)
+			}
+		})
+	}
+
+	t.Run("Render", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newReader(t, "\n  )").All()
+		require.Error(t, err)
+		assert.Equal(t, "<string>:2:3: unmatched delimiter ')'", err.Error())
+	})
+}
+
+func TestPath(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"/", "/"},
+		{"/foo/bar", "/foo/bar"},
+		{"(ls /foo/*)", "(ls /foo/*)"},
+		{`/foo/\*/bar`, `/foo/\*/bar`},
+		{`/a\ b/c`, `/a\ b/c`},
+		{"/foo/./bar/../baz", "/foo/baz"},
+	} {
+		forms, err := newReader(t, tt.src).All()
+		require.NoError(t, err, tt.src)
+		require.Len(t, forms, 1, tt.src)
+
+		s, err := core.Render(forms[0].(ww.Any))
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, s, tt.src)
+	}
+
+	_, err := newReader(t, `/foo\`).All()
+	assert.True(t, errors.Is(err, reader.ErrEOF), "unexpected error %v", err)
+
+	for _, src := range []string{"//", "/foo//bar", "/foo/", "/a\x07b", "/.."} {
+		_, err := newReader(t, "\n  "+src).All()
+		require.Error(t, err, src)
+		assert.True(t, errors.Is(err, anchorpath.ErrInvalid) || errors.Is(err, anchorpath.ErrEscapesRoot),
+			"unexpected error %v", err)
+		assert.True(t, strings.HasPrefix(err.Error(), "<string>:2:"), "unexpected error %v", err)
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"'a", "(quote a)"},
+		{"@a", "(deref a)"},
+		{"`a", "(syntax-quote a)"},
+		{"~a", "(unquote a)"},
+		{"~@a", "(unquote-splicing a)"},
+		{"#'a", "(var a)"},
+		{"@@a", "(deref (deref a))"},
+		{"`(f ~x ~@xs)", "(syntax-quote (f (unquote x) (unquote-splicing xs)))"},
+		{"[@a @b]", "[(deref a) (deref b)]"},
+		{"a@b", "a (deref b)"},
+		{"@ ; comment\n a", "(deref a)"},
+		{"@#_ a b", "(deref b)"},
+	} {
+		forms, err := newReader(t, tt.src).All()
+		require.NoError(t, err, tt.src)
+
+		rendered := make([]string, len(forms))
+		for i, form := range forms {
+			rendered[i], err = core.Render(form.(ww.Any))
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, tt.want, strings.Join(rendered, " "), tt.src)
+	}
+
+	for _, tt := range []struct {
+		src, msg  string
+		line, col int
+	}{
+		{"[:a\n @", "unexpected EOF after reader macro '@'", 2, 2},
+		{"[:a\n ~@", "unexpected EOF after reader macro '~@'", 2, 2},
+		{"[:a\n ~", "unexpected EOF after reader macro '~'", 2, 2},
+		{"[:a\n #'", "unexpected EOF after reader macro '#''", 2, 2},
+		{"[:a\n 'x '", "unexpected EOF after reader macro '''", 2, 5},
+		{"[:a\n @(b", "unexpected EOF while parsing", 2, 3},
+	} {
+		_, err := newReader(t, tt.src).All()
+		require.Error(t, err, tt.src)
+		assert.True(t, errors.Is(err, reader.ErrEOF), "%s: unexpected error %v", tt.src, err)
+
+		var e wwreader.Error
+		require.True(t, errors.As(err, &e), "unexpected error %#v", err)
+		assert.Equal(t, tt.line, e.Line, tt.src)
+		assert.Equal(t, tt.col, e.Col, tt.src)
+		assert.Equal(t, tt.msg, e.Cause.Error(), tt.src)
+	}
+
+	// errors in the prefixed form are reported at their own position
+	_, err := newReader(t, "[:a\n @[b \\bogus]]").All()
+	require.Error(t, err)
+	assert.Equal(t, "<string>:2:6: unknown character literal '\\bogus'", err.Error())
+}
+
+func TestSymbolHook(t *testing.T) {
+	t.Parallel()
+
+	var got []string
+	rd, err := wwreader.New(strings.NewReader("(f x\n  'y ~@zs #'v)\n#_ d\t-e"),
+		wwreader.WithSymbolHook(func(name string, line, col int) {
+			got = append(got, fmt.Sprintf("%s@%d:%d", name, line, col))
+		}))
+	require.NoError(t, err)
+
+	_, err = rd.All()
+	require.NoError(t, err)
+
+	// symbols introduced by prefixes are reported at the position of the prefix
+	assert.Equal(t, []string{
+		"f@1:2",
+		"x@1:4",
+		"quote@2:3",
+		"y@2:4",
+		"unquote-splicing@2:6",
+		"zs@2:8",
+		"var@2:11",
+		"v@2:13",
+		"d@3:4",
+		"-e@3:6",
+	}, got)
+}
+
+func TestTable(t *testing.T) {
+	t.Parallel()
+
+	upper := wwreader.WithDataReader("upper", func(form ww.Any) (ww.Any, error) {
+		s, err := form.(core.String).Value().Str()
+		if err != nil {
+			return nil, err
+		}
+
+		return core.NewString(capnp.SingleSegment(nil), strings.ToUpper(s))
+	})
+
+	getenv := wwreader.WithMacro('$', false, func(rd *reader.Reader, _ rune) (ww.Any, error) {
+		form, err := rd.One()
+		if err != nil {
+			return nil, err
+		}
+
+		sym, err := core.NewSymbol(capnp.SingleSegment(nil), "getenv")
+		if err != nil {
+			return nil, err
+		}
+
+		return core.NewList(capnp.SingleSegment(nil), sym, form.(ww.Any))
+	})
+
+	str := wwreader.WithMacro('"', true, func(rd *reader.Reader, _ rune) (ww.Any, error) {
+		for {
+			r, err := rd.NextRune()
+			if err != nil {
+				return nil, err
+			}
+
+			if r == '"' {
+				return core.NewString(capnp.SingleSegment(nil), "shadowed")
+			}
+		}
+	})
+
+	for _, tt := range []struct {
+		desc, src, want string
+		opts            []wwreader.Option
+	}{
+		{"Tagged", `#upper "abc"`, `"ABC"`, []wwreader.Option{upper}},
+		{"TaggedAdjacent", `[#upper"x" 1]`, `["X" 1]`, []wwreader.Option{upper}},
+		{"TaggedSkipsComment", "#upper ; c\n \"y\"", `"Y"`, []wwreader.Option{upper}},
+		{"Macro", "[$a $(b)]", "[(getenv a) (getenv (b))]", []wwreader.Option{getenv}},
+		{"MacroIsTerminal", "a$b", "a (getenv b)", []wwreader.Option{getenv}},
+		{"ShadowDispatch", `#"x"`, `"shadowed"`, []wwreader.Option{str}},
+		{"Combined", `$#upper"x"`, `(getenv "X")`, []wwreader.Option{upper, getenv}},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			forms, err := newReader(t, tt.src, tt.opts...).All()
+			require.NoError(t, err)
+
+			rendered := make([]string, len(forms))
+			for i, form := range forms {
+				rendered[i], err = core.Render(form.(ww.Any))
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.want, strings.Join(rendered, " "))
+		})
+	}
+
+	t.Run("Conflict", func(t *testing.T) {
+		t.Parallel()
+
+		for _, opts := range [][]wwreader.Option{
+			{getenv, getenv},
+			{upper, upper},
+			{wwreader.WithDataReader("_x", nil)},
+		} {
+			_, err := wwreader.NewTable(opts...)
+			assert.True(t, errors.Is(err, wwreader.ErrConflict), "unexpected error %v", err)
+		}
+
+		for _, r := range []rune{'1', ' ', ','} {
+			_, err := wwreader.NewTable(wwreader.WithMacro(r, false, nil))
+			assert.Error(t, err, "%q", r)
+		}
+	})
+
+	for _, tt := range []struct {
+		desc, src string
+		cause     error
+	}{
+		{"UnknownTag", "[:a\n #foo 1]", wwreader.ErrUnknownTag},
+		{"TagAtEOF", "[:a\n #upper", reader.ErrEOF},
+		{"DispatchAtEOF", "[:a\n #", reader.ErrEOF},
+		{"InvalidDispatch", "[:a\n #(b)]", nil},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := newReader(t, tt.src, upper).All()
+			require.Error(t, err)
+
+			var e wwreader.Error
+			require.True(t, errors.As(err, &e), "unexpected error %#v", err)
+			assert.Equal(t, 2, e.Line, "wrong line")
+			assert.Equal(t, 2, e.Col, "wrong column")
+
+			if tt.cause != nil {
+				assert.True(t, errors.Is(err, tt.cause), "unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestStream(t *testing.T) {
+	t.Parallel()
+
+	table, err := wwreader.NewTable()
+	require.NoError(t, err)
+
+	render := func(t *testing.T, form ww.Any) string {
+		s, err := core.Render(form)
+		require.NoError(t, err)
+		return s
+	}
+
+	t.Run("MultiLine", func(t *testing.T) {
+		t.Parallel()
+
+		s := table.NewStream("REPL")
+		defer s.Close()
+
+		_, err := s.One()
+		assert.Equal(t, io.EOF, err, "empty stream")
+
+		s.Feed("(defn f [x]\n")
+		_, err = s.One()
+		assert.True(t, errors.Is(err, wwreader.ErrIncomplete), "unexpected error %v", err)
+
+		s.Feed("\n")
+		_, err = s.One()
+		assert.True(t, errors.Is(err, wwreader.ErrIncomplete), "unexpected error %v", err)
+
+		s.Feed("  x) :next\n")
+		form, err := s.One()
+		require.NoError(t, err)
+		assert.Equal(t, "(defn f [x] x)", render(t, form))
+
+		form, err = s.One()
+		require.NoError(t, err)
+		assert.Equal(t, ":next", render(t, form))
+
+		_, err = s.One()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("Token", func(t *testing.T) {
+		t.Parallel()
+
+		s := table.NewStream("REPL")
+		defer s.Close()
+
+		// a token is incomplete until it is terminated
+		s.Feed("12")
+		_, err := s.One()
+		assert.True(t, errors.Is(err, wwreader.ErrIncomplete), "unexpected error %v", err)
+
+		s.Feed("3\n; comment\n")
+		form, err := s.One()
+		require.NoError(t, err)
+		assert.Equal(t, "123", render(t, form))
+
+		_, err = s.One()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		t.Parallel()
+
+		s := table.NewStream("REPL")
+		defer s.Close()
+
+		s.Feed("[1\n")
+		_, err := s.One()
+		assert.True(t, errors.Is(err, wwreader.ErrIncomplete), "unexpected error %v", err)
+
+		s.Feed(" \\bogus]\n")
+		_, err = s.One()
+		require.Error(t, err)
+		assert.Equal(t, "REPL:2:2: unknown character literal '\\bogus'", err.Error())
+
+		s.Reset()
+		s.Feed(":ok\n")
+		form, err := s.One()
+		require.NoError(t, err)
+		assert.Equal(t, ":ok", render(t, form))
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		t.Parallel()
+
+		s := table.NewStream("REPL")
+		defer s.Close()
+
+		s.Feed("(a [b\n")
+		_, err := s.One()
+		assert.True(t, errors.Is(err, wwreader.ErrIncomplete), "unexpected error %v", err)
+
+		s.Reset()
+		_, err = s.One()
+		assert.Equal(t, io.EOF, err)
+
+		s.Feed("c\n")
+		form, err := s.One()
+		require.NoError(t, err)
+		assert.Equal(t, "c", render(t, form))
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		t.Parallel()
+
+		s := table.NewStream("REPL")
+
+		s.Feed("(a\n")
+		_, err := s.One()
+		assert.True(t, errors.Is(err, wwreader.ErrIncomplete), "unexpected error %v", err)
+
+		require.NoError(t, s.Close())
+		_, err = s.One()
+		assert.True(t, errors.Is(err, reader.ErrEOF), "unexpected error %v", err)
+
+		_, err = s.One()
+		assert.Equal(t, io.EOF, err)
+	})
+}
+
+func newReader(t *testing.T, src string, opts ...wwreader.Option) *reader.Reader {
+	rd, err := wwreader.New(strings.NewReader(src), opts...)
+	require.NoError(t, err)
+	return rd
+}
@@ -0,0 +1,198 @@
+package reader
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	score "github.com/spy16/slurp/core"
+	"github.com/spy16/slurp/reader"
+
+	ww "github.com/wetware/ww/pkg"
+)
+
+// ErrIncomplete is returned by Stream.One when the input fed so far ends inside a
+// form.  Feeding more input resumes the parse.
+var ErrIncomplete = errors.New("incomplete form")
+
+// Stream reads forms from input that is supplied incrementally, such as lines typed
+// at a REPL.  Input is parsed as it arrives, so a form spanning several feeds is not
+// re-read from its beginning, and positions are counted from the first feed.
+//
+// The parser runs in a separate goroutine, which is released by Close.  Streams are
+// not safe for concurrent use.
+type Stream struct {
+	t    *Table
+	file string
+	rd   *reader.Reader
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	chunks  [][]byte
+	res     *result
+	busy    bool // parser is running
+	waiting bool // parser is blocked on input
+	started bool // parser has consumed part of a form
+	stop    bool // parser is being abandoned by Reset
+	closed  bool
+}
+
+type result struct {
+	form score.Any
+	err  error
+}
+
+// NewStream returns a stream that reports positions in the named file.
+func (t *Table) NewStream(file string) *Stream {
+	s := &Stream{t: t, file: file}
+	s.cond = sync.NewCond(&s.mu)
+	s.rd = s.newReader()
+	return s
+}
+
+func (s *Stream) newReader() *reader.Reader {
+	rd := s.t.New(source{s})
+	rd.File = s.file
+	return rd
+}
+
+// Feed appends input to the stream.
+func (s *Stream) Feed(input string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.chunks = append(s.chunks, []byte(input))
+	s.cond.Broadcast()
+}
+
+// One returns the next form.  If the input is exhausted, it returns ErrIncomplete
+// when a form has been partially read, and io.EOF otherwise.  Syntax errors are
+// reported as Error, after which the stream should be Reset.
+func (s *Stream) One() (ww.Any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.busy {
+		s.busy = true
+		go s.parse(s.rd)
+	}
+
+	for {
+		if r := s.res; r != nil {
+			s.res, s.busy = nil, false
+			if r.err != nil {
+				return nil, r.err
+			}
+
+			return r.form.(ww.Any), nil
+		}
+
+		if s.waiting && len(s.chunks) == 0 && !s.closed {
+			if s.started {
+				return nil, ErrIncomplete
+			}
+
+			return nil, io.EOF
+		}
+
+		s.cond.Wait()
+	}
+}
+
+// Reset discards buffered input, including any partial form.  Positions are counted
+// from the beginning again.
+func (s *Stream) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stop = true
+	s.cond.Broadcast()
+	for s.busy && s.res == nil {
+		s.cond.Wait()
+	}
+
+	s.chunks, s.res, s.busy, s.stop = nil, nil, false, false
+	s.rd = s.newReader()
+}
+
+// Close the stream.  Subsequent calls to One return io.EOF, or an error if a form was
+// partially read.
+func (s *Stream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	s.cond.Broadcast()
+	return nil
+}
+
+func (s *Stream) parse(rd *reader.Reader) {
+	form, err := s.readForm(rd)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.res = &result{form: form, err: err}
+	s.cond.Broadcast()
+}
+
+func (s *Stream) readForm(rd *reader.Reader) (score.Any, error) {
+	for {
+		s.setStarted(false)
+		if err := rd.SkipSpaces(); err != nil {
+			return nil, err
+		}
+
+		s.setStarted(true)
+		form, err := s.t.readOne(rd)
+		if errors.Is(err, reader.ErrSkip) {
+			continue
+		}
+
+		if errors.Is(err, io.EOF) {
+			err = reader.ErrEOF
+		}
+
+		return form, err
+	}
+}
+
+func (s *Stream) setStarted(started bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.started = started
+}
+
+// source is the io.Reader consumed by the parser.  When no input is buffered, it
+// blocks until more is fed, or until the stream is reset or closed.
+type source struct{ s *Stream }
+
+func (src source) Read(p []byte) (n int, err error) {
+	s := src.s
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.chunks) == 0 && !s.stop && !s.closed {
+		s.waiting = true
+		s.cond.Broadcast()
+		s.cond.Wait()
+	}
+	s.waiting = false
+
+	if s.stop || len(s.chunks) == 0 {
+		return 0, io.EOF
+	}
+
+	for len(s.chunks) > 0 && n < len(p) {
+		c := copy(p[n:], s.chunks[0])
+		if n += c; c == len(s.chunks[0]) {
+			s.chunks = s.chunks[1:]
+		} else {
+			s.chunks[0] = s.chunks[0][c:]
+		}
+	}
+
+	return n, nil
+}
@@ -1,6 +1,8 @@
 package lang
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
@@ -12,15 +14,40 @@ import (
 )
 
 var (
+	// ErrConversion is returned when a value cannot be converted to the type
+	// expected by a native function.
+	ErrConversion = errors.New("type conversion")
+
 	anyType = reflect.TypeOf((*ww.Any)(nil)).Elem()
 	errType = reflect.TypeOf((*error)(nil)).Elem()
 	ivkType = reflect.TypeOf((*core.Invokable)(nil)).Elem()
+	ctxType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	fnType  = reflect.TypeOf(core.Fn{})
 
 	_ core.Invokable = (*funcWrapper)(nil)
 )
 
+// RegisterFn binds the Go function fn to name in env.  Arguments are converted
+// from ww.Any to the types of fn's parameters, which may be any of int64, float64,
+// string, []byte, bool, ww.Any (or an interface it satisfies), or a slice thereof.
+// Return values are converted back to ww.Any.  If fn's last return value is an
+// error, it is returned to the caller.
+//
+// Invoking fn with the wrong number of arguments returns a core.Error whose
+// cause is core.ErrArity.  Arguments that cannot be converted produce a
+// core.Error whose cause is ErrConversion.
+func RegisterFn(env core.Env, name string, fn interface{}) error {
+	wrapped, err := Func(name, fn)
+	if err != nil {
+		return err
+	}
+
+	return env.Bind(name, wrapped)
+}
+
 // Func converts the given Go value into a Wetware native function.
-// The resulting value is guaranteed to be invokable.
+// The resulting value is guaranteed to be invokable.  If the first parameter of
+// v is a context.Context, it is supplied with the evaluation context.
 func Func(name string, v interface{}) (ww.Any, error) {
 	rv := reflect.ValueOf(v)
 	rt := rv.Type()
@@ -43,6 +70,11 @@ func newFuncWrapper(name string, rv reflect.Value, rt reflect.Type) (*funcWrappe
 		minArgs = minArgs - 1
 	}
 
+	takesCtx := rt.NumIn() > 0 && rt.In(0) == ctxType
+	if takesCtx {
+		minArgs-- // supplied by InvokeContext
+	}
+
 	lastOutIdx := rt.NumOut() - 1
 	returnsErr := lastOutIdx >= 0 && rt.Out(lastOutIdx) == errType
 	if returnsErr {
@@ -66,6 +98,7 @@ func newFuncWrapper(name string, rv reflect.Value, rt reflect.Type) (*funcWrappe
 		rv:         rv,
 		rt:         rt,
 		minArgs:    minArgs,
+		takesCtx:   takesCtx,
 		returnsErr: returnsErr,
 		lastOutIdx: lastOutIdx,
 		adapters:   adapters,
@@ -77,6 +110,7 @@ type funcWrapper struct {
 	rv         reflect.Value
 	rt         reflect.Type
 	minArgs    int
+	takesCtx   bool
 	returnsErr bool
 	lastOutIdx int
 	adapters   []adapter
@@ -85,20 +119,26 @@ type funcWrapper struct {
 func (fw *funcWrapper) Value() mem.Any { return fw.sym.Value() }
 
 func (fw *funcWrapper) Invoke(args ...ww.Any) (ww.Any, error) {
-	// allocate argument slice.
-	argCount := len(args)
-	argVals := make([]reflect.Value, argCount, argCount)
-
-	// populate reflect.Value version of each argument.
-	for i, arg := range args {
-		argVals[i] = reflect.ValueOf(arg)
-	}
+	return fw.InvokeContext(context.Background(), args...)
+}
 
+func (fw *funcWrapper) InvokeContext(ctx context.Context, args ...ww.Any) (ww.Any, error) {
 	// verify number of args match the required function parameters.
-	if err := fw.checkArgCount(len(argVals)); err != nil {
+	if err := fw.checkArgCount(len(args)); err != nil {
 		return nil, err
 	}
 
+	// allocate argument slice.
+	var argVals []reflect.Value
+	if fw.takesCtx {
+		argVals = append(argVals, reflect.ValueOf(&ctx).Elem())
+	}
+
+	// populate reflect.Value version of each argument.
+	for _, arg := range args {
+		argVals = append(argVals, reflect.ValueOf(arg))
+	}
+
 	if err := fw.convertTypes(argVals...); err != nil {
 		return nil, err
 	}
@@ -132,8 +172,13 @@ func (fw *funcWrapper) argNames() []string {
 	var argNames []string
 
 	i := 0
-	for ; i < fw.minArgs; i++ {
+	if fw.takesCtx {
+		i++
+	}
+
+	for n := 0; n < fw.minArgs; n++ {
 		argNames = append(argNames, cleanArgName(fw.rt.In(i)))
+		i++
 	}
 
 	if fw.rt.IsVariadic() {
@@ -151,7 +196,7 @@ func (fw *funcWrapper) convertTypes(args ...reflect.Value) error {
 		if i == lastArgIdx && isVariadic {
 			c, err := convertArgsTo(fw.rt.In(i).Elem(), args[i:]...)
 			if err != nil {
-				return err
+				return fw.convErr(err)
 			}
 			copy(args[i:], c)
 			break
@@ -159,7 +204,7 @@ func (fw *funcWrapper) convertTypes(args ...reflect.Value) error {
 
 		c, err := convertArgsTo(fw.rt.In(i), args[i])
 		if err != nil {
-			return err
+			return fw.convErr(err)
 		}
 		args[i] = c[0]
 	}
@@ -167,20 +212,26 @@ func (fw *funcWrapper) convertTypes(args ...reflect.Value) error {
 	return nil
 }
 
+func (fw *funcWrapper) convErr(err error) error {
+	return core.Error{Cause: ErrConversion, Message: fmt.Sprintf("%s: %s", fw, err)}
+}
+
 func (fw *funcWrapper) checkArgCount(count int) error {
 	if count != fw.minArgs {
 		if fw.rt.IsVariadic() && count < fw.minArgs {
-			return fmt.Errorf(
-				"call requires at-least %d argument(s), got %d",
-				fw.minArgs, count,
-			)
+			return core.Error{
+				Cause: core.ErrArity,
+				Message: fmt.Sprintf("call requires at-least %d argument(s), got %d",
+					fw.minArgs, count),
+			}
 		}
 
 		if !fw.rt.IsVariadic() {
-			return fmt.Errorf(
-				"call requires exactly %d argument(s), got %d",
-				fw.minArgs, count,
-			)
+			return core.Error{
+				Cause: core.ErrArity,
+				Message: fmt.Sprintf("call requires exactly %d argument(s), got %d",
+					fw.minArgs, count),
+			}
 		}
 	}
 
@@ -228,8 +279,16 @@ func convertArgsTo(expected reflect.Type, args ...reflect.Value) ([]reflect.Valu
 			(expected.Kind() == reflect.Interface && actual.Implements(expected))
 		if isAssignable {
 			converted[i] = arg
+		} else if c, ok := arg.Interface().(closure); ok && fnType.AssignableTo(expected) {
+			converted[i] = reflect.ValueOf(c.Fn)
 		} else if actual.ConvertibleTo(expected) {
 			converted[i] = arg.Convert(expected)
+		} else if any, ok := arg.Interface().(ww.Any); ok {
+			v, err := fromAny(expected, any)
+			if err != nil {
+				return args, err
+			}
+			converted[i] = v
 		} else {
 			return args, fmt.Errorf(
 				"value of type '%s' cannot be converted to '%s'",
@@ -260,9 +319,12 @@ func adapterFor(t reflect.Type) adapter {
 	case reflect.Bool:
 		return toBool
 
-	case reflect.Int, reflect.Uint:
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return toInt
 
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return toUint
+
 	case reflect.Float32, reflect.Float64:
 		return toFloat
 
@@ -270,6 +332,10 @@ func adapterFor(t reflect.Type) adapter {
 		return toString
 
 	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return toBytes
+		}
+
 		return maybeNil(toVector)
 
 	case reflect.Array:
@@ -294,6 +360,10 @@ func toInt(v reflect.Value) (ww.Any, error) {
 	return core.NewInt64(capnp.SingleSegment(nil), v.Int())
 }
 
+func toUint(v reflect.Value) (ww.Any, error) {
+	return core.NewInt64(capnp.SingleSegment(nil), int64(v.Uint()))
+}
+
 func toFloat(v reflect.Value) (ww.Any, error) {
 	return core.NewFloat64(capnp.SingleSegment(nil), v.Float())
 }
@@ -302,6 +372,10 @@ func toString(v reflect.Value) (ww.Any, error) {
 	return core.NewString(capnp.SingleSegment(nil), v.String())
 }
 
+func toBytes(v reflect.Value) (ww.Any, error) {
+	return core.NewString(capnp.SingleSegment(nil), string(v.Bytes()))
+}
+
 func toSymbol(v reflect.Value) (ww.Any, error) {
 	return core.NewSymbol(capnp.SingleSegment(nil), v.String())
 }
@@ -311,14 +385,17 @@ func toKeyword(v reflect.Value) (ww.Any, error) {
 }
 
 func toVector(v reflect.Value) (ww.Any, error) {
+	var adapt adapter
+	if elem := v.Type().Elem(); !elem.AssignableTo(anyType) {
+		adapt = adapterFor(elem)
+	}
+
+	var err error
 	as := make([]ww.Any, v.Len())
 	for i := range as {
-		if any, ok := v.Index(i).Interface().(ww.Any); ok {
-			as[i] = any
-			continue
+		if as[i], err = adaptValue(adapt, v.Index(i)); err != nil {
+			return nil, err
 		}
-
-		return nil, fmt.Errorf("%s is not ww.Any", v.Type())
 	}
 
 	return core.NewVector(capnp.SingleSegment(nil), as...)
@@ -333,3 +410,105 @@ func maybeNil(adapt adapter) adapter {
 		return adapt(v)
 	}
 }
+
+// fromAny converts a ww.Any to a native Go value of type t.
+func fromAny(t reflect.Type, any ww.Any) (reflect.Value, error) {
+	val := any.Value()
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if val.Which() == mem.Any_Which_bool {
+			return reflect.ValueOf(val.Bool()).Convert(t), nil
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if val.Which() == mem.Any_Which_i64 {
+			if v := reflect.New(t).Elem(); !v.OverflowInt(val.I64()) {
+				v.SetInt(val.I64())
+				return v, nil
+			}
+
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", val.I64(), t)
+		}
+
+	case reflect.Float32, reflect.Float64:
+		switch val.Which() {
+		case mem.Any_Which_f64:
+			return reflect.ValueOf(val.F64()).Convert(t), nil
+		case mem.Any_Which_i64:
+			return reflect.ValueOf(float64(val.I64())).Convert(t), nil
+		}
+
+	case reflect.String:
+		if s, ok, err := textOf(val); ok {
+			return reflect.ValueOf(s).Convert(t), err
+		}
+
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			if s, ok, err := textOf(val); ok {
+				return reflect.ValueOf([]byte(s)).Convert(t), err
+			}
+		}
+
+		if core.IsNil(any) {
+			return reflect.Zero(t), nil
+		}
+
+		if seq, err := asSeq(any); seq != nil || err != nil {
+			if err != nil {
+				return reflect.Value{}, err
+			}
+
+			return sliceFromSeq(t, seq)
+		}
+	}
+
+	return reflect.Value{}, fmt.Errorf("value of type '%s' cannot be converted to '%s'",
+		val.Which(), t)
+}
+
+func textOf(val mem.Any) (s string, ok bool, err error) {
+	switch val.Which() {
+	case mem.Any_Which_str:
+		s, err = val.Str()
+	case mem.Any_Which_keyword:
+		s, err = val.Keyword()
+	case mem.Any_Which_symbol:
+		s, err = val.Symbol()
+	default:
+		return "", false, nil
+	}
+
+	return s, true, err
+}
+
+func asSeq(any ww.Any) (core.Seq, error) {
+	switch v := any.(type) {
+	case core.Seq:
+		return v, nil
+	case core.Seqable:
+		return v.Seq()
+	}
+
+	return nil, nil
+}
+
+func sliceFromSeq(t reflect.Type, seq core.Seq) (reflect.Value, error) {
+	items, err := core.ToSlice(seq)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	vs := reflect.MakeSlice(t, len(items), len(items))
+	for i, item := range items {
+		c, err := convertArgsTo(t.Elem(), reflect.ValueOf(item))
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("index %d: %w", i, err)
+		}
+
+		vs.Index(i).Set(c[0])
+	}
+
+	return vs, nil
+}
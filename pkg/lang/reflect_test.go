@@ -0,0 +1,131 @@
+package lang_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang"
+	"github.com/wetware/ww/pkg/lang/core"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+func TestRegisterFn(t *testing.T) {
+	t.Parallel()
+
+	errBoom := errors.New("boom")
+
+	env := core.New()
+	require.NoError(t, lang.RegisterFn(env, "sum", func(xs ...int64) int64 {
+		var sum int64
+		for _, x := range xs {
+			sum += x
+		}
+		return sum
+	}))
+	require.NoError(t, lang.RegisterFn(env, "join", func(sep string, ss []string) string {
+		return strings.Join(ss, sep)
+	}))
+	require.NoError(t, lang.RegisterFn(env, "bytes", func(b []byte, upper bool) []byte {
+		if upper {
+			return []byte(strings.ToUpper(string(b)))
+		}
+		return b
+	}))
+	require.NoError(t, lang.RegisterFn(env, "fail", func(fail bool) (int64, error) {
+		if fail {
+			return 0, errBoom
+		}
+		return 1, nil
+	}))
+	require.NoError(t, lang.RegisterFn(env, "split", func(s string) []string {
+		return strings.Split(s, ",")
+	}))
+
+	invoke := func(name string, args ...ww.Any) (ww.Any, error) {
+		v, err := env.Resolve(name)
+		require.NoError(t, err)
+
+		fn, ok := v.(core.Invokable)
+		require.True(t, ok, "%s is not invokable", name)
+
+		return fn.Invoke(args...)
+	}
+
+	t.Run("Variadic", func(t *testing.T) {
+		res, err := invoke("sum", mustInt(1), mustInt(2), mustInt(3))
+		require.NoError(t, err)
+		assertEq(t, mustInt(6), res)
+
+		res, err = invoke("sum")
+		require.NoError(t, err)
+		assertEq(t, mustInt(0), res)
+	})
+
+	t.Run("Slice", func(t *testing.T) {
+		ss, err := core.NewVector(capnp.SingleSegment(nil), mustStr("a"), mustStr("b"))
+		require.NoError(t, err)
+
+		res, err := invoke("join", mustStr("-"), ss)
+		require.NoError(t, err)
+		assertEq(t, mustStr("a-b"), res)
+
+		res, err = invoke("split", mustStr("a,b"))
+		require.NoError(t, err)
+		assertEq(t, ss, res)
+	})
+
+	t.Run("Bytes", func(t *testing.T) {
+		res, err := invoke("bytes", mustStr("abc"), core.True)
+		require.NoError(t, err)
+		assertEq(t, mustStr("ABC"), res)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		res, err := invoke("fail", core.False)
+		require.NoError(t, err)
+		assertEq(t, mustInt(1), res)
+
+		_, err = invoke("fail", core.True)
+		assert.Equal(t, errBoom, err)
+	})
+
+	t.Run("Arity", func(t *testing.T) {
+		_, err := invoke("fail")
+		assert.True(t, errors.Is(err, core.ErrArity), "unexpected error %v", err)
+	})
+
+	t.Run("Conversion", func(t *testing.T) {
+		_, err := invoke("sum", mustInt(1), mustStr("2"))
+		assert.True(t, errors.Is(err, lang.ErrConversion), "unexpected error %v", err)
+	})
+}
+
+func assertEq(t *testing.T, want, got ww.Any) {
+	t.Helper()
+
+	eq, err := core.Eq(want, got)
+	require.NoError(t, err)
+	assert.True(t, eq, "expected %v, got %v", want, got)
+}
+
+func mustInt(i int64) core.Int64 {
+	v, err := core.NewInt64(capnp.SingleSegment(nil), i)
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
+
+func mustStr(s string) core.String {
+	v, err := core.NewString(capnp.SingleSegment(nil), s)
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
@@ -0,0 +1,120 @@
+package lang
+
+import (
+	"fmt"
+	"regexp"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+func regexes() bindFunc {
+	return func(env core.Env) error {
+		return bindAll(env,
+			function("re-pattern", "__re_pattern__", asRegex),
+			function("re-find", "__re_find__", func(re, s ww.Any) (ww.Any, error) {
+				return reMatch(re, s, core.Regex.Regexp)
+			}),
+			function("re-matches", "__re_matches__", func(re, s ww.Any) (ww.Any, error) {
+				return reMatch(re, s, core.Regex.Whole)
+			}),
+			function("re-seq", "__re_seq__", fnReSeq))
+	}
+}
+
+// asRegex returns the regex, or compiles the string.  The latter allows regexes
+// loaded from an anchor, which are stored as their pattern, to be used directly.
+func asRegex(any ww.Any) (core.Regex, error) {
+	switch v := any.(type) {
+	case core.Regex:
+		return v, nil
+	case core.String:
+		s, err := v.Value().Str()
+		if err != nil {
+			return core.Regex{}, err
+		}
+
+		return core.NewRegex(capnp.SingleSegment(nil), s)
+	}
+
+	return core.Regex{}, fmt.Errorf("expected regex or string, got '%s'", any.Value().Which())
+}
+
+// reMatch returns the first match of re in s, or nil.
+func reMatch(re, s ww.Any, compiled func(core.Regex) *regexp.Regexp) (ww.Any, error) {
+	r, err := asRegex(re)
+	if err != nil {
+		return nil, err
+	}
+
+	str, err := stringArg(s)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := compiled(r).FindStringSubmatchIndex(str)
+	if loc == nil {
+		return core.Nil{}, nil
+	}
+
+	return match(str, loc)
+}
+
+// (re-seq re s) returns a list of the successive matches of re in s.
+func fnReSeq(re, s ww.Any) (core.List, error) {
+	r, err := asRegex(re)
+	if err != nil {
+		return nil, err
+	}
+
+	str, err := stringArg(s)
+	if err != nil {
+		return nil, err
+	}
+
+	locs := r.Regexp().FindAllStringSubmatchIndex(str, -1)
+	ms := make([]ww.Any, len(locs))
+	for i, loc := range locs {
+		if ms[i], err = match(str, loc); err != nil {
+			return nil, err
+		}
+	}
+
+	return core.NewList(capnp.SingleSegment(nil), ms...)
+}
+
+// match returns the matched string if the expression has no groups.  Otherwise,
+// it returns a vector of the match followed by each group, where unmatched groups
+// are nil.
+func match(s string, loc []int) (ww.Any, error) {
+	if len(loc) == 2 {
+		return core.NewString(capnp.SingleSegment(nil), s[loc[0]:loc[1]])
+	}
+
+	var (
+		err    error
+		groups = make([]ww.Any, len(loc)/2)
+	)
+
+	for i := range groups {
+		if loc[2*i] < 0 {
+			groups[i] = core.Nil{}
+			continue
+		}
+
+		if groups[i], err = core.NewString(capnp.SingleSegment(nil), s[loc[2*i]:loc[2*i+1]]); err != nil {
+			return nil, err
+		}
+	}
+
+	return core.NewVector(capnp.SingleSegment(nil), groups...)
+}
+
+func stringArg(any ww.Any) (string, error) {
+	if s, ok := any.(core.String); ok {
+		return s.Value().Str()
+	}
+
+	return "", fmt.Errorf("expected string, got '%s'", any.Value().Which())
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/wetware/ww/internal/mem"
 	ww "github.com/wetware/ww/pkg"
 	"github.com/wetware/ww/pkg/lang/core"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
 	capnp "zombiezen.com/go/capnproto2"
 )
 
@@ -68,6 +69,124 @@ func parseIf(a core.Analyzer, env core.Env, args core.Seq) (core.Expr, error) {
 		Else: exprs[2],
 	}, nil
 }
+
+// whenParser parses the (when test body*) and (when-not test body*) forms.  The body
+// is evaluated as an implicit do.
+func whenParser(name string, negate bool) SpecialParser {
+	return func(a core.Analyzer, env core.Env, args core.Seq) (core.Expr, error) {
+		if args == nil {
+			args = core.EmptyList
+		}
+
+		if count, err := args.Count(); err != nil {
+			return nil, err
+		} else if count == 0 {
+			return nil, core.Error{
+				Cause:   fmt.Errorf("%w: %s", slurp.ErrParseSpecial, name),
+				Message: "requires at-least 1 argument, got 0",
+			}
+		}
+
+		first, err := args.First()
+		if err != nil {
+			return nil, err
+		}
+
+		test, err := a.Analyze(env, first)
+		if err != nil {
+			return nil, err
+		}
+
+		if args, err = args.Next(); err != nil {
+			return nil, err
+		}
+
+		var body core.Expr // nil evaluates to nil
+		if args != nil {
+			if body, err = parseDo(a, env, args); err != nil {
+				return nil, err
+			}
+		}
+
+		if negate {
+			return IfExpr{Test: test, Else: body}, nil
+		}
+
+		return IfExpr{Test: test, Then: body}, nil
+	}
+}
+
+// parseIfLet parses the (if-let [name expr] then else?) form.
+func parseIfLet(a core.Analyzer, env core.Env, args core.Seq) (core.Expr, error) {
+	e := core.Error{Cause: fmt.Errorf("%w: if-let", slurp.ErrParseSpecial)}
+
+	if args == nil {
+		return nil, e.With("requires 2 or 3 arguments, got 0")
+	}
+
+	forms, err := core.ToSlice(args)
+	if err != nil {
+		return nil, err
+	} else if len(forms) != 2 && len(forms) != 3 {
+		return nil, e.With(fmt.Sprintf(
+			"requires 2 or 3 arguments, got %d", len(forms)))
+	}
+
+	bindings, ok := forms[0].(core.Vector)
+	if !ok {
+		return nil, e.With(fmt.Sprintf(
+			"bindings must be a vector, not '%s'", forms[0].Value().Which()))
+	}
+
+	if cnt, err := bindings.Count(); err != nil {
+		return nil, err
+	} else if cnt != 2 {
+		return nil, e.With(fmt.Sprintf(
+			"requires exactly 1 binding pair, got %d forms", cnt))
+	}
+
+	name, err := bindings.EntryAt(0)
+	if err != nil {
+		return nil, err
+	}
+
+	sym, ok := name.(core.Symbol)
+	if !ok {
+		return nil, e.With(fmt.Sprintf(
+			"binding name must be symbol, not '%s'", name.Value().Which()))
+	}
+
+	var ile IfLetExpr
+	if ile.Name, err = sym.Symbol(); err != nil {
+		return nil, err
+	}
+
+	value, err := bindings.EntryAt(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if ile.Value, err = a.Analyze(env, value); err != nil {
+		return nil, err
+	}
+
+	if ile.Then, err = a.Analyze(env, forms[1]); err != nil {
+		return nil, err
+	}
+
+	if len(forms) == 3 {
+		ile.Else, err = a.Analyze(env, forms[2])
+	}
+
+	return ile, err
+}
+
+// parseComment parses the (comment form*) special form, which evaluates to nil.
+// The body is neither analyzed nor evaluated.
+func parseComment(core.Analyzer, core.Env, core.Seq) (core.Expr, error) {
+	return ConstExpr{core.Nil{}}, nil
+}
+
 func parseQuote(a core.Analyzer, _ core.Env, args core.Seq) (core.Expr, error) {
 	if count, err := args.Count(); err != nil {
 		return nil, err
@@ -137,6 +256,91 @@ func parseDef(a core.Analyzer, env core.Env, args core.Seq) (core.Expr, error) {
 	}, nil
 }
 
+// parseDefn parses the (defn name doc? [<params>*] <body>*) or
+// (defn name doc? ([<params>*] <body>*)+) special forms, and binds the resulting
+// function to name.
+func parseDefn(_ core.Analyzer, env core.Env, seq core.Seq) (core.Expr, error) {
+	e := core.Error{Cause: fmt.Errorf("%w: defn", slurp.ErrParseSpecial)}
+
+	if seq == nil {
+		return nil, e.With("requires name and call signature")
+	}
+
+	args, err := core.ToSlice(seq)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) < 2 {
+		return nil, e.With("requires name and call signature")
+	}
+
+	if args[0].Value().Which() != mem.Any_Which_symbol {
+		return nil, e.With("name must be symbol")
+	}
+
+	name, err := args[0].Value().Symbol()
+	if err != nil {
+		return nil, err
+	}
+
+	var doc string
+	if args[1].Value().Which() == mem.Any_Which_str && len(args) > 2 {
+		if doc, err = args[1].Value().Str(); err != nil {
+			return nil, err
+		}
+
+		args = append(args[:1:1], args[2:]...)
+	}
+
+	fnSeq, err := core.NewList(capnp.SingleSegment(nil), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	fn, err := parseFnDef(env, fnSeq, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return DefExpr{
+		Name:  name,
+		Doc:   doc,
+		Value: ConstExpr{fn},
+	}, nil
+}
+
+// parseMeta parses the (meta name) special form.  The name is not evaluated.
+func parseMeta(_ core.Analyzer, _ core.Env, seq core.Seq) (core.Expr, error) {
+	e := core.Error{Cause: fmt.Errorf("%w: meta", slurp.ErrParseSpecial)}
+
+	if seq == nil {
+		return nil, e.With("requires exactly 1 arg, got 0")
+	}
+
+	args, err := core.ToSlice(seq)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) != 1 {
+		return nil, e.With(fmt.Sprintf("requires exactly 1 arg, got %d", len(args)))
+	}
+
+	sym, ok := args[0].(core.Symbol)
+	if !ok {
+		return nil, e.With(fmt.Sprintf(
+			"arg must be symbol, not '%s'", reflect.TypeOf(args[0])))
+	}
+
+	name, err := sym.Symbol()
+	if err != nil {
+		return nil, err
+	}
+
+	return MetaExpr{Name: name}, nil
+}
+
 // parseFn parses the (fn name? [<params>*] <body>*) or
 // (fn name? ([<params>*] <body>*)+) special forms and returns a function value.
 func parseFn(a core.Analyzer, env core.Env, args core.Seq) (core.Expr, error) {
@@ -208,46 +412,173 @@ func lsParser(root ww.Anchor) SpecialParser {
 		}
 
 		pexpr := PathExpr{Root: root, Path: core.RootPath}
-		for _, arg := range args {
-			if arg.Value().Which() == mem.Any_Which_path {
-				pexpr.Path = args[0].(core.Path)
-				args = args[1:]
-			}
+		if p, ok := procArgs(args).Remote(); ok {
+			pexpr.Path = p
+		}
 
-			break
+		if err = checkPath(root, pexpr.Path); err != nil {
+			return nil, err
 		}
 
 		// TODO(enhancement):  other args like `:long` or `:recursive`
+		opts, err := lsOptions(procArgs(args).Args())
+		if err != nil {
+			return nil, err
+		}
 
 		return PathListExpr{
 			PathExpr: pexpr,
-			Args:     args,
+			Opts:     opts,
 		}, nil
 	}
 }
 
-func parseGo(a core.Analyzer, env core.Env, seq core.Seq) (core.Expr, error) {
-	return nil, errors.New("parseGo NOT IMPLEMENTED")
-	// args, err := core.ToSlice(seq)
-	// if err != nil {
-	// 	return nil, err
-	// }
-
-	// if len(args) == 0 {
-	// 	return nil, errors.Errorf("expected at least one argument, got %d", len(args))
-	// }
-
-	// if p, ok := procArgs(args).Remote(); ok {
-	// 	return RemoteGoExpr{
-	// 		Root: a.root,
-	// 		Path: p,
-	// 		Args: procArgs(args).Args(),
-	// 	}, nil
-	// }
-
-	// return LocalGoExpr{
-	// 	Args: procArgs(args).Args(),
-	// }, nil
+// lsOptions parses the keyword arguments to ls, i.e. `:desc` and `:prefix "str"`.
+func lsOptions(args []ww.Any) (anchorutil.ListOptions, error) {
+	var opts anchorutil.ListOptions
+	for len(args) > 0 {
+		if args[0].Value().Which() != mem.Any_Which_keyword {
+			return opts, fmt.Errorf("invalid argument type %s", args[0].Value().Which())
+		}
+
+		kw, err := args[0].Value().Keyword()
+		if err != nil {
+			return opts, err
+		}
+
+		switch args = args[1:]; kw {
+		case "desc":
+			opts.Desc = true
+
+		case "prefix":
+			if len(args) == 0 || args[0].Value().Which() != mem.Any_Which_str {
+				return opts, errors.New(":prefix expects a string")
+			}
+
+			if opts.Prefix, err = args[0].Value().Str(); err != nil {
+				return opts, err
+			}
+
+			args = args[1:]
+
+		default:
+			return opts, fmt.Errorf("unrecognized kwarg '%s'", kw)
+		}
+	}
+
+	return opts, nil
+}
+
+func goParser(root ww.Anchor, procs *procTable) SpecialParser {
+	return func(a core.Analyzer, env core.Env, seq core.Seq) (core.Expr, error) {
+		args, err := core.ToSlice(seq)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(args) == 0 {
+			return nil, core.Error{
+				Cause: fmt.Errorf("%w: go", slurp.ErrParseSpecial),
+			}.With("expected at least one argument, got 0")
+		}
+
+		// (go /path form...) binds the process to an anchor.
+		if p, ok := procArgs(args).Remote(); ok {
+			if err = checkPath(root, p); err != nil {
+				return nil, err
+			}
+
+			return RemoteGoExpr{
+				Root: root,
+				Path: p,
+				Args: procArgs(args).Args(),
+			}, nil
+		}
+
+		body, err := parseDo(a, env, seq)
+		if err != nil {
+			return nil, err
+		}
+
+		return LocalGoExpr{
+			Body:  body,
+			procs: procs,
+		}, nil
+	}
+}
+
+// parseSelect parses (select [ch] [ch v] ...).  A one-element vector receives from
+// ch, and a two-element vector sends v to ch.  The vectors are not evaluated as
+// values; each of their elements is analyzed separately.
+func parseSelect(a core.Analyzer, env core.Env, seq core.Seq) (core.Expr, error) {
+	var sex SelectExpr
+	if err := core.ForEach(seq, func(item ww.Any) (bool, error) {
+		vec, ok := item.(core.Vector)
+		if !ok {
+			return true, core.Error{
+				Cause: fmt.Errorf("%w: select", slurp.ErrParseSpecial),
+			}.With(fmt.Sprintf("expected vector, got '%s'", item.Value().Which()))
+		}
+
+		cnt, err := vec.Count()
+		if err != nil {
+			return true, err
+		}
+
+		if cnt != 1 && cnt != 2 {
+			return true, core.Error{
+				Cause: fmt.Errorf("%w: select", slurp.ErrParseSpecial),
+			}.With(fmt.Sprintf("expected [ch] or [ch value], got %d items", cnt))
+		}
+
+		op := make([]core.Expr, cnt)
+		for i := range op {
+			form, err := vec.EntryAt(i)
+			if err != nil {
+				return true, err
+			}
+
+			if op[i], err = a.Analyze(env, form); err != nil {
+				return true, err
+			}
+		}
+
+		sex.Ops = append(sex.Ops, op)
+		return false, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if len(sex.Ops) == 0 {
+		return nil, core.Error{
+			Cause: fmt.Errorf("%w: select", slurp.ErrParseSpecial),
+		}.With("expected at least one channel operation")
+	}
+
+	return sex, nil
+}
+
+// procArgs are the arguments to a form that is optionally anchored to a path,
+// e.g. (ls /path) or (go /path form...).
+type procArgs []ww.Any
+
+// Remote returns the anchor path, if the first argument is a path.
+func (args procArgs) Remote() (core.Path, bool) {
+	if len(args) > 0 && args[0].Value().Which() == mem.Any_Which_path {
+		p, ok := args[0].(core.Path)
+		return p, ok
+	}
+
+	return core.Path{}, false
+}
+
+// Args returns the arguments following the anchor path, if any.
+func (args procArgs) Args() []ww.Any {
+	if _, ok := args.Remote(); ok {
+		return args[1:]
+	}
+
+	return args
 }
 
 func parseEval(a core.Analyzer, env core.Env, seq core.Seq) (core.Expr, error) {
@@ -261,7 +592,14 @@ func parseEval(a core.Analyzer, env core.Env, seq core.Seq) (core.Expr, error) {
 	})
 }
 
-type importer []string
+// importer parses (import name).  The name may be :prelude, which loads every source
+// file in the search path, a symbol, which is resolved by symbolToPath, a string, which
+// is resolved by nameToPath, or a path literal, which is resolved by pathModule.
+type importer struct {
+	paths   []string
+	root    ww.Anchor
+	loaders *loaderTable
+}
 
 func (i importer) Parse(a core.Analyzer, env core.Env, seq core.Seq) (core.Expr, error) {
 	if cnt, err := seq.Count(); err != nil {
@@ -293,7 +631,11 @@ func (i importer) Parse(a core.Analyzer, env core.Env, seq core.Seq) (core.Expr,
 			return nil, err
 		}
 
-		iex.Paths = append(iex.Paths, ps...)
+		for _, path := range ps {
+			if err = iex.addFile(path); err != nil {
+				return nil, err
+			}
+		}
 
 	case mem.Any_Which_symbol:
 		sym, err := mv.Symbol()
@@ -306,7 +648,37 @@ func (i importer) Parse(a core.Analyzer, env core.Env, seq core.Seq) (core.Expr,
 			return nil, fmt.Errorf("import error: %w", err)
 		}
 
-		iex.Paths = append(iex.Paths, path)
+		if err = iex.addFile(path); err != nil {
+			return nil, err
+		}
+
+	case mem.Any_Which_str:
+		name, err := mv.Str()
+		if err != nil {
+			return nil, err
+		}
+
+		path, err := i.nameToPath(name)
+		if err != nil {
+			return nil, fmt.Errorf("import error: %w", err)
+		}
+
+		if err = iex.addFile(path); err != nil {
+			return nil, err
+		}
+
+	case mem.Any_Which_path:
+		path, err := mv.Path()
+		if err != nil {
+			return nil, err
+		}
+
+		m, err := pathModule(i.root, i.loaders, path)
+		if err != nil {
+			return nil, fmt.Errorf("import error: %w", err)
+		}
+
+		iex.Modules = append(iex.Modules, m)
 
 	default:
 		return nil, fmt.Errorf("invalid argument type %s", mv.Which())
@@ -318,13 +690,13 @@ func (i importer) Parse(a core.Analyzer, env core.Env, seq core.Seq) (core.Expr,
 
 func (i importer) init(a core.Analyzer, env core.Env) (paths []string, err error) {
 	var files []os.FileInfo
-	for _, path := range i {
+	for _, path := range i.paths {
 		if files, err = ioutil.ReadDir(path); err != nil {
 			break
 		}
 
 		for _, f := range files {
-			if !f.IsDir() && strings.HasSuffix(f.Name(), ".ww") {
+			if !f.IsDir() && strings.HasSuffix(f.Name(), SourceExt) {
 				paths = append(paths, filepath.Join(path, f.Name()))
 			}
 		}
@@ -333,16 +705,46 @@ func (i importer) init(a core.Analyzer, env core.Env) (paths []string, err error
 	return
 }
 
+// symbolToPath resolves a module symbol.  Entries in the module manifests of the
+// source roots take precedence over files at the symbol's dotted path.
 func (i importer) symbolToPath(symbol string) (path string, err error) {
+	for _, root := range i.paths {
+		m, err := ReadManifest(filepath.Join(root, DepsFile))
+		if err != nil {
+			return "", err
+		}
+
+		if path, ok, err := m.Resolve(symbol); ok || err != nil {
+			return path, err
+		}
+	}
+
 	subpath := strings.ReplaceAll(symbol, ".", string(os.PathSeparator))
-	subpath = filepath.Clean(subpath) + ".ww"
+	return i.search(filepath.Clean(subpath) + SourceExt)
+}
+
+// nameToPath resolves a file name.  Absolute names are used as-is, and relative names
+// are searched for in each source root, in order.  SourceExt is appended to names
+// without an extension.
+func (i importer) nameToPath(name string) (string, error) {
+	if filepath.Ext(name) == "" {
+		name += SourceExt
+	}
+
+	if filepath.IsAbs(name) {
+		return name, nil
+	}
+
+	return i.search(filepath.Clean(name))
+}
 
-	for _, root := range i {
+func (i importer) search(subpath string) (path string, err error) {
+	for _, root := range i.paths {
 		path = filepath.Join(root, subpath)
 		if _, err = os.Stat(path); !os.IsNotExist(err) {
 			return
 		}
 	}
 
-	return "", core.ErrNotFound
+	return "", fmt.Errorf("%w: %s", core.ErrNotFound, subpath)
 }
@@ -0,0 +1,105 @@
+package ww
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/lthibault/log"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+)
+
+// NopLogger returns a Logger that discards all output.  It is the default for
+// components whose logger is not set.
+func NopLogger() Logger {
+	return log.New(log.WithWriter(ioutil.Discard), log.WithLevel(log.FatalLevel))
+}
+
+// ConsoleLogger returns a Logger that writes human-readable entries to w, at or above
+// the specified level.
+func ConsoleLogger(w io.Writer, lvl log.Level) Logger {
+	return log.New(
+		log.WithWriter(w),
+		log.WithLevel(lvl),
+		log.WithFormatter(&logrus.TextFormatter{FullTimestamp: true}))
+}
+
+// JSONLogger returns a Logger that writes one JSON object per entry to w, at or above
+// the specified level.
+func JSONLogger(w io.Writer, lvl log.Level) Logger {
+	return log.New(
+		log.WithWriter(w),
+		log.WithLevel(lvl),
+		log.WithFormatter(&logrus.JSONFormatter{}))
+}
+
+// LoggerFor returns a child of l carrying the fields of v, or a logger that discards
+// all output if l is nil.  Components call it once, upon construction, so that every
+// entry they log identifies them.
+func LoggerFor(l Logger, v Loggable) Logger {
+	if l == nil {
+		return NopLogger()
+	}
+
+	return l.With(v)
+}
+
+// ZapLogger adapts a zap logger, so that embedders can reuse their existing logging.
+// Zap has no trace level, so trace entries are logged at debug level.  Errors are
+// logged under the "error" key, as with zap.Error.
+func ZapLogger(z *zap.Logger) Logger {
+	return zapLogger{z.WithOptions(zap.AddCallerSkip(1)).Sugar()}
+}
+
+type zapLogger struct{ s *zap.SugaredLogger }
+
+func (l zapLogger) Fatal(v ...interface{})              { l.s.Fatal(v...) }
+func (l zapLogger) Fatalf(fmt string, v ...interface{}) { l.s.Fatalf(fmt, v...) }
+func (l zapLogger) Fatalln(v ...interface{})            { l.s.Fatal(sprintln(v)) }
+func (l zapLogger) Trace(v ...interface{})              { l.s.Debug(v...) }
+func (l zapLogger) Tracef(fmt string, v ...interface{}) { l.s.Debugf(fmt, v...) }
+func (l zapLogger) Traceln(v ...interface{})            { l.s.Debug(sprintln(v)) }
+func (l zapLogger) Debug(v ...interface{})              { l.s.Debug(v...) }
+func (l zapLogger) Debugf(fmt string, v ...interface{}) { l.s.Debugf(fmt, v...) }
+func (l zapLogger) Debugln(v ...interface{})            { l.s.Debug(sprintln(v)) }
+func (l zapLogger) Info(v ...interface{})               { l.s.Info(v...) }
+func (l zapLogger) Infof(fmt string, v ...interface{})  { l.s.Infof(fmt, v...) }
+func (l zapLogger) Infoln(v ...interface{})             { l.s.Info(sprintln(v)) }
+func (l zapLogger) Warn(v ...interface{})               { l.s.Warn(v...) }
+func (l zapLogger) Warnf(fmt string, v ...interface{})  { l.s.Warnf(fmt, v...) }
+func (l zapLogger) Warnln(v ...interface{})             { l.s.Warn(sprintln(v)) }
+func (l zapLogger) Error(v ...interface{})              { l.s.Error(v...) }
+func (l zapLogger) Errorf(fmt string, v ...interface{}) { l.s.Errorf(fmt, v...) }
+func (l zapLogger) Errorln(v ...interface{})            { l.s.Error(sprintln(v)) }
+
+func (l zapLogger) With(v log.Loggable) log.Logger { return l.WithFields(v.Loggable()) }
+
+func (l zapLogger) WithError(err error) log.Logger { return zapLogger{l.s.With(zap.Error(err))} }
+
+func (l zapLogger) WithField(k string, v interface{}) log.Logger {
+	return zapLogger{l.s.With(k, v)}
+}
+
+// WithFields adds the fields in order of their keys, so that entries are stable.
+func (l zapLogger) WithFields(f log.F) log.Logger {
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]interface{}, 0, 2*len(f))
+	for _, k := range keys {
+		kvs = append(kvs, k, f[k])
+	}
+
+	return zapLogger{l.s.With(kvs...)}
+}
+
+// sprintln formats v as fmt.Sprintln does, without the trailing newline.
+func sprintln(v []interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(v...), "\n")
+}
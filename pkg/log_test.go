@@ -0,0 +1,75 @@
+package ww_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/lthibault/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	ww "github.com/wetware/ww/pkg"
+)
+
+type component struct{}
+
+func (component) Loggable() map[string]interface{} {
+	return map[string]interface{}{"service": "test", "id": 1}
+}
+
+func TestJSONLogger(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	l := ww.LoggerFor(ww.JSONLogger(&buf, log.InfoLevel), component{})
+
+	l.Debug("dropped")
+	l.WithError(errors.New("boom")).Warn("kept")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "kept", entry["msg"])
+	assert.Equal(t, "warning", entry["level"])
+	assert.Equal(t, "test", entry["service"])
+	assert.Equal(t, "boom", entry["error"])
+}
+
+func TestLoggerFor(t *testing.T) {
+	t.Parallel()
+
+	l := ww.LoggerFor(nil, component{})
+	require.NotNil(t, l)
+	l.WithField("k", "v").Error("discarded")
+}
+
+func TestZapLogger(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := ww.LoggerFor(ww.ZapLogger(zap.New(core)), component{})
+
+	l.Traceln("trace", 1)
+	l.WithError(errors.New("boom")).WithField("k", "v").Errorf("failed %d", 2)
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, zapcore.DebugLevel, entries[0].Level)
+	assert.Equal(t, "trace 1", entries[0].Message)
+	assert.Equal(t, map[string]interface{}{"id": int64(1), "service": "test"},
+		entries[0].ContextMap())
+
+	assert.Equal(t, zapcore.ErrorLevel, entries[1].Level)
+	assert.Equal(t, "failed 2", entries[1].Message)
+	assert.Equal(t, map[string]interface{}{
+		"id":      int64(1),
+		"service": "test",
+		"error":   "boom",
+		"k":       "v",
+	}, entries[1].ContextMap())
+}
@@ -0,0 +1,169 @@
+package runtime
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+
+	"go.uber.org/multierr"
+)
+
+// ConflictError is returned if more than one registered service produces the same
+// stateful event.  Subscribers to a stateful event receive the last event emitted upon
+// subscribing, which is ambiguous if there is more than one producer.
+type ConflictError struct {
+	Type      reflect.Type
+	Producers []string
+}
+
+func (err ConflictError) Error() string {
+	return fmt.Sprintf("stateful event '%s' has multiple producers %v", err.Type, err.Producers)
+}
+
+// UnusedEventError is reported if an event produced by a registered Service does not
+// have a corresponding consumer registered to the runtime, and is not declared public.
+// It is only returned by Start in strict mode; otherwise it is logged at debug level.
+type UnusedEventError struct {
+	Type reflect.Type
+}
+
+func (err UnusedEventError) Error() string {
+	return fmt.Sprintf("no consumer registered for event '%s'", err.Type)
+}
+
+// StatefulProducer is an optional interface implemented by ServiceFactory that declares
+// which of the events it produces are emitted with eventbus.Stateful.
+type StatefulProducer interface {
+	Stateful() []interface{}
+}
+
+// ExternalConsumer is an optional interface implemented by ServiceFactory that declares
+// which of the events it consumes are produced outside the runtime, e.g. by libp2p, or
+// by services that are not always registered.  These events need not have a producer
+// registered to the runtime.
+type ExternalConsumer interface {
+	External() []interface{}
+}
+
+// PublicProducer is an optional interface implemented by ServiceFactory that declares
+// which of the events it produces are intended for subscribers outside the runtime,
+// e.g. applications subscribed to the host's event bus.  These events need not have a
+// consumer registered to the runtime.
+type PublicProducer interface {
+	Public() []interface{}
+}
+
+// EventGraph is the bipartite graph of the runtime's services and the events they
+// produce and consume.
+type EventGraph struct {
+	// Events, sorted by type name.
+	Events []EventNode
+}
+
+// EventNode is an event type, along with the services that produce and consume it.
+// Services are named after the package in which their factory is defined.
+type EventNode struct {
+	Type                 reflect.Type
+	Producers, Consumers []string
+
+	// Stateful is true if the event is emitted with eventbus.Stateful, External is
+	// true if the event may be produced outside the runtime, and Public is true if
+	// the event may be consumed outside the runtime.
+	Stateful, External, Public bool
+}
+
+// newEventGraph returns the event graph declared by the values, which implement the
+// optional interfaces of ServiceFactory.
+func newEventGraph(ds []interface{}) EventGraph {
+	var (
+		g     EventGraph
+		nodes = map[reflect.Type]*EventNode{}
+	)
+
+	node := func(ev interface{}) *EventNode {
+		t := reflect.TypeOf(ev)
+		if n, ok := nodes[t]; ok {
+			return n
+		}
+
+		nodes[t] = &EventNode{Type: t}
+		return nodes[t]
+	}
+
+	for _, f := range ds {
+		name := serviceName(f)
+
+		if ep, ok := f.(EventProducer); ok {
+			for _, ev := range ep.Produces() {
+				n := node(ev)
+				n.Producers = append(n.Producers, name)
+			}
+		}
+
+		if ec, ok := f.(EventConsumer); ok {
+			for _, ev := range ec.Consumes() {
+				n := node(ev)
+				n.Consumers = append(n.Consumers, name)
+			}
+		}
+
+		if sp, ok := f.(StatefulProducer); ok {
+			for _, ev := range sp.Stateful() {
+				node(ev).Stateful = true
+			}
+		}
+
+		if ec, ok := f.(ExternalConsumer); ok {
+			for _, ev := range ec.External() {
+				node(ev).External = true
+			}
+		}
+
+		if pp, ok := f.(PublicProducer); ok {
+			for _, ev := range pp.Public() {
+				node(ev).Public = true
+			}
+		}
+	}
+
+	for _, n := range nodes {
+		g.Events = append(g.Events, *n)
+	}
+
+	sort.Slice(g.Events, func(i, j int) bool {
+		return g.Events[i].Type.String() < g.Events[j].Type.String()
+	})
+
+	return g
+}
+
+// Validate the graph.  It returns an error if an event is consumed but not produced,
+// or if a stateful event has more than one producer.  Events that are produced but not
+// consumed, and are not public, are returned separately as warnings.
+func (g EventGraph) Validate() (warnings []error, err error) {
+	for _, n := range g.Events {
+		switch {
+		case len(n.Consumers) > 0 && len(n.Producers) == 0 && !n.External:
+			err = multierr.Append(err, DependencyError{n.Type})
+
+		case n.Stateful && len(n.Producers) > 1:
+			err = multierr.Append(err, ConflictError{Type: n.Type, Producers: n.Producers})
+
+		case len(n.Producers) > 0 && len(n.Consumers) == 0 && !n.Public:
+			warnings = append(warnings, UnusedEventError{n.Type})
+		}
+	}
+
+	return
+}
+
+// serviceName returns the name of the package in which the factory is defined.
+func serviceName(f interface{}) string {
+	t := reflect.TypeOf(f)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return path.Base(t.PkgPath())
+}
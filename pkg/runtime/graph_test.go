@@ -0,0 +1,145 @@
+package runtime_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx/fxtest"
+
+	eventbus "github.com/libp2p/go-eventbus"
+
+	logutil "github.com/wetware/ww/internal/util/log"
+	"github.com/wetware/ww/pkg/runtime"
+)
+
+type (
+	evtFoo struct{}
+	evtBar struct{}
+)
+
+func TestEventGraph(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Graph", func(t *testing.T) {
+		cfg := runtime.Config{
+			Bus: eventbus.NewBus(),
+			Services: []runtime.ServiceFactory{
+				declares{produces: []interface{}{evtFoo{}}},
+				declares{consumes: []interface{}{evtFoo{}, evtBar{}}, external: []interface{}{evtBar{}}},
+			},
+		}
+
+		g := cfg.Graph()
+		require.Len(t, g.Events, 6)
+
+		// sorted by type name
+		for i, ev := range []interface{}{
+			runtime.EvtRuntimeStarted{},
+			runtime.EvtServiceFailed{},
+			runtime.EvtServiceRestarted{},
+			runtime.EvtServiceStateChanged{},
+			evtBar{},
+			evtFoo{},
+		} {
+			assert.Equal(t, reflect.TypeOf(ev), g.Events[i].Type)
+		}
+
+		assert.Equal(t, []string{"runtime"}, g.Events[0].Producers)
+		assert.Empty(t, g.Events[0].Consumers)
+		assert.True(t, g.Events[0].Stateful)
+		assert.True(t, g.Events[0].Public)
+
+		assert.True(t, g.Events[4].External)
+
+		assert.Equal(t, []string{"runtime_test"}, g.Events[5].Producers)
+		assert.Equal(t, []string{"runtime_test"}, g.Events[5].Consumers)
+
+		warnings, err := g.Validate()
+		assert.NoError(t, err, "external events need not be produced")
+		assert.Empty(t, warnings, "public events need not be consumed")
+	})
+
+	t.Run("Public", func(t *testing.T) {
+		cfg := runtime.Config{
+			Services: []runtime.ServiceFactory{
+				declares{produces: []interface{}{evtFoo{}, evtBar{}}, public: []interface{}{evtBar{}}},
+			},
+		}
+
+		g := cfg.Graph()
+		require.Len(t, g.Events, 2)
+		assert.True(t, g.Events[0].Public)
+		assert.False(t, g.Events[1].Public)
+
+		warnings, err := g.Validate()
+		assert.NoError(t, err)
+		assert.Equal(t, []error{
+			runtime.UnusedEventError{Type: reflect.TypeOf(evtFoo{})},
+		}, warnings)
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		stateful := declares{
+			produces: []interface{}{evtFoo{}},
+			stateful: []interface{}{evtFoo{}},
+		}
+
+		cfg := runtime.Config{
+			Services: []runtime.ServiceFactory{
+				stateful,
+				declares{produces: []interface{}{evtFoo{}}},
+				declares{consumes: []interface{}{evtFoo{}}},
+			},
+		}
+
+		_, err := cfg.Graph().Validate()
+		var conflict runtime.ConflictError
+		require.True(t, errors.As(err, &conflict), "unexpected error %v", err)
+		assert.Equal(t, reflect.TypeOf(evtFoo{}), conflict.Type)
+		assert.Len(t, conflict.Producers, 2)
+
+		// stateless events may have several producers
+		cfg.Services = cfg.Services[1:]
+		cfg.Services = append(cfg.Services, declares{produces: []interface{}{evtFoo{}}})
+		_, err = cfg.Graph().Validate()
+		assert.NoError(t, err)
+	})
+
+	t.Run("Strict", func(t *testing.T) {
+		cfg := runtime.Config{
+			Log: logutil.Nop(),
+			Services: []runtime.ServiceFactory{
+				declares{produces: []interface{}{evtFoo{}}},
+			},
+		}
+
+		assert.NoError(t, runtime.Start(cfg, fxtest.NewLifecycle(t)),
+			"unused events should not fail startup unless strict")
+
+		cfg.Strict = true
+		assert.EqualError(t, runtime.Start(cfg, fxtest.NewLifecycle(t)),
+			runtime.UnusedEventError{Type: reflect.TypeOf(evtFoo{})}.Error())
+	})
+}
+
+// declares events without providing a service.
+type declares struct {
+	produces, consumes, stateful, external, public []interface{}
+}
+
+func (d declares) NewService() (runtime.Service, error) { return nopService{}, nil }
+func (d declares) Produces() []interface{}              { return d.produces }
+func (d declares) Consumes() []interface{}              { return d.consumes }
+func (d declares) Stateful() []interface{}              { return d.stateful }
+func (d declares) External() []interface{}              { return d.external }
+func (d declares) Public() []interface{}                { return d.public }
+
+type nopService struct{}
+
+func (nopService) Start(context.Context) error      { return nil }
+func (nopService) Stop(context.Context) error       { return nil }
+func (nopService) Loggable() map[string]interface{} { return nil }
@@ -0,0 +1,160 @@
+package runtime
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/event"
+)
+
+// State of a service's lifecycle.
+type State uint8
+
+const (
+	// StateCreated services have been constructed, but not started.
+	StateCreated State = iota
+
+	// StateStarting services are being started, either initially or after a restart.
+	StateStarting
+
+	// StateRunning services have started successfully.
+	StateRunning
+
+	// StateStopping services are being stopped.
+	StateStopping
+
+	// StateStopped services have been stopped.
+	StateStopped
+
+	// StateFailed services have failed, and are either awaiting a restart or have been
+	// abandoned by their supervisor.
+	StateFailed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateCreated:
+		return "created"
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StateStopping:
+		return "stopping"
+	case StateStopped:
+		return "stopped"
+	case StateFailed:
+		return "failed"
+	}
+
+	return fmt.Sprintf("State(%d)", s)
+}
+
+// Status of a service in the runtime.
+type Status struct {
+	// Service is the Loggable representation of the current incarnation of the
+	// service.
+	Service map[string]interface{}
+
+	State State
+
+	// Err is the last error reported by the service, if any.  It is not cleared when
+	// the service recovers.
+	Err error
+
+	// Started is the time at which the current incarnation of the service was last
+	// started, and Restarts is its number of consecutive restarts.
+	Started  time.Time
+	Restarts int
+}
+
+// EvtServiceStateChanged is emitted when a service transitions from one State to
+// another.
+type EvtServiceStateChanged struct {
+	// Service is the Loggable representation of the service.
+	Service map[string]interface{}
+
+	From, To State
+
+	// Err is non-nil if the transition was caused by an error, e.g. if To is
+	// StateFailed, or if the service did not stop cleanly.
+	Err error
+}
+
+// Registry tracks the lifecycle of each service in a runtime.  It is safe for
+// concurrent use.  Its lock is never held while a service is starting or stopping, so
+// the status of a runtime can be queried while it is hung.
+type Registry struct {
+	mu sync.RWMutex
+	es []entry
+	e  event.Emitter // nil if the runtime has no bus
+}
+
+type entry struct {
+	svc Service // current incarnation
+	Status
+}
+
+// NewRegistry returns an empty registry, to be populated by Start.
+func NewRegistry() *Registry { return new(Registry) }
+
+// Status of each service, in order of registration.
+func (r *Registry) Status() []Status {
+	r.mu.RLock()
+	es := make([]entry, len(r.es))
+	copy(es, r.es)
+	r.mu.RUnlock()
+
+	ss := make([]Status, len(es))
+	for i, e := range es {
+		ss[i] = e.Status
+		ss[i].Service = e.svc.Loggable()
+	}
+
+	return ss
+}
+
+// add a service in StateCreated, and return its index.
+func (r *Registry) add(svc Service) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.es = append(r.es, entry{svc: svc})
+	return len(r.es) - 1
+}
+
+// transition the service at index i to the given state.  If svc is not nil, it
+// replaces the current incarnation of the service.  The event is emitted after the
+// lock is released, so that slow subscribers do not block queries.
+func (r *Registry) transition(i int, svc Service, to State, err error, restarts int) error {
+	r.mu.Lock()
+
+	e := &r.es[i]
+	ev := EvtServiceStateChanged{From: e.State, To: to, Err: err}
+
+	if svc != nil {
+		e.svc = svc
+	}
+
+	if err != nil {
+		e.Err = err
+	}
+
+	if to == StateStarting {
+		e.Started = time.Now()
+	}
+
+	e.State = to
+	e.Restarts = restarts
+	svc = e.svc
+
+	r.mu.Unlock()
+
+	if r.e == nil {
+		return nil
+	}
+
+	ev.Service = svc.Loggable()
+	return r.e.Emit(ev)
+}
@@ -0,0 +1,156 @@
+package runtime_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx/fxtest"
+
+	eventbus "github.com/libp2p/go-eventbus"
+
+	"github.com/wetware/ww/pkg/runtime"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Lifecycle", func(t *testing.T) {
+		t.Parallel()
+
+		bus := eventbus.NewBus()
+		sub, err := bus.Subscribe(new(runtime.EvtServiceStateChanged), eventbus.BufSize(16))
+		require.NoError(t, err)
+		defer sub.Close()
+
+		// the first incarnation crashes, and is restarted
+		f := &crashFactory{
+			policy: runtime.Policy{MinBackoff: time.Millisecond},
+			crash:  func() { panic("test") },
+			once:   true,
+		}
+
+		reg := runtime.NewRegistry()
+		lx := fxtest.NewLifecycle(t)
+		require.NoError(t, runtime.Start(runtime.Config{
+			Bus:      bus,
+			Services: []runtime.ServiceFactory{f},
+			Registry: reg,
+		}, lx))
+
+		require.Len(t, reg.Status(), 1)
+		assert.Equal(t, runtime.StateCreated, reg.Status()[0].State)
+
+		lx.RequireStart()
+		require.Eventually(t, func() bool {
+			return reg.Status()[0].State == runtime.StateRunning && f.Instances() == 2
+		}, time.Second, time.Millisecond)
+
+		status := reg.Status()[0]
+		assert.Equal(t, "crasher", status.Service["service"])
+		assert.Equal(t, 1, status.Restarts)
+		assert.EqualError(t, status.Err, "panic: test", "last error should be reported")
+		assert.False(t, status.Started.IsZero())
+
+		lx.RequireStop()
+		assert.Equal(t, runtime.StateStopped, reg.Status()[0].State)
+
+		var states []runtime.State
+		for len(states) < 7 {
+			select {
+			case v := <-sub.Out():
+				ev := v.(runtime.EvtServiceStateChanged)
+				assert.Equal(t, "crasher", ev.Service["service"])
+				states = append(states, ev.To)
+			case <-time.After(time.Second):
+				t.Fatalf("EvtServiceStateChanged not emitted (got %v)", states)
+			}
+		}
+
+		assert.Equal(t, []runtime.State{
+			runtime.StateStarting,
+			runtime.StateRunning,
+			runtime.StateFailed,
+			runtime.StateStarting,
+			runtime.StateRunning,
+			runtime.StateStopping,
+			runtime.StateStopped,
+		}, states)
+	})
+
+	t.Run("Hung", func(t *testing.T) {
+		t.Parallel()
+
+		svc := &hungService{release: make(chan struct{})}
+		reg := runtime.NewRegistry()
+		lx := fxtest.NewLifecycle(t)
+		require.NoError(t, runtime.Start(runtime.Config{
+			Services: []runtime.ServiceFactory{factoryFor(svc)},
+			Registry: reg,
+		}, lx))
+
+		started := make(chan struct{})
+		go func() {
+			defer close(started)
+			lx.RequireStart()
+		}()
+
+		require.Eventually(t, func() bool {
+			return reg.Status()[0].State == runtime.StateStarting
+		}, time.Second, time.Millisecond, "registry should not block while Start hangs")
+
+		close(svc.release)
+		<-started
+
+		assert.Equal(t, runtime.StateRunning, reg.Status()[0].State)
+		lx.RequireStop()
+	})
+
+	t.Run("Snapshot", func(t *testing.T) {
+		t.Parallel()
+
+		reg := runtime.NewRegistry()
+		lx := fxtest.NewLifecycle(t)
+		require.NoError(t, runtime.Start(runtime.Config{
+			Services: []runtime.ServiceFactory{
+				&crashFactory{},
+				factoryFor(snapService{"state"}),
+			},
+			Registry: reg,
+		}, lx))
+
+		assert.Empty(t, reg.Snapshot(), "services that are not running should be omitted")
+
+		lx.RequireStart()
+		defer lx.RequireStop()
+
+		ss := reg.Snapshot()
+		require.Len(t, ss, 1, "only snapshotters should be reported")
+		assert.Equal(t, "snap", ss[0].Service["service"])
+		assert.Equal(t, "state", ss[0].Value)
+	})
+}
+
+// snapService reports a constant snapshot.
+type snapService struct{ state string }
+
+func (s snapService) Start(context.Context) error { return nil }
+func (s snapService) Stop(context.Context) error  { return nil }
+func (s snapService) Snapshot() interface{}       { return s.state }
+
+func (s snapService) Loggable() map[string]interface{} {
+	return map[string]interface{}{"service": "snap"}
+}
+
+// hungService blocks in Start until released.
+type hungService struct{ release chan struct{} }
+
+func (s *hungService) Start(context.Context) error {
+	<-s.release
+	return nil
+}
+
+func (s *hungService) Stop(context.Context) error       { return nil }
+func (s *hungService) Loggable() map[string]interface{} { return nil }
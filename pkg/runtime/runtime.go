@@ -6,9 +6,13 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"time"
 
+	eventbus "github.com/libp2p/go-eventbus"
+	"github.com/libp2p/go-libp2p-core/event"
 	ww "github.com/wetware/ww/pkg"
 	"go.uber.org/fx"
+	"go.uber.org/multierr"
 )
 
 // DependencyError is returned if an event consumed by a registered Service does not
@@ -53,100 +57,193 @@ type Service interface {
 	Loggable() map[string]interface{}
 }
 
+// EvtRuntimeStarted is emitted once every service in the runtime has started.  It is
+// a stateful event.
+type EvtRuntimeStarted struct {
+	// Services is the number of services in the runtime.
+	Services int
+}
+
 // Config specifies a set of runtime services.
 type Config struct {
 	fx.In
 
-	Log      ww.Logger
+	Log      ww.Logger        `optional:"true"`
+	Bus      event.Bus        `optional:"true"`
 	Services []ServiceFactory `group:"runtime"`
+
+	// Registry tracks the lifecycle of the services.  If nil, Start uses a private
+	// registry.
+	Registry *Registry `optional:"true"`
+
+	// Tap records the events emitted by the services, if it is not nil and Bus is not
+	// nil.
+	Tap *Tap `optional:"true"`
+
+	// StopTimeout bounds the time allotted to stopping each service, unless its
+	// factory implements Graceful.  It defaults to DefaultStopTimeout.
+	StopTimeout time.Duration `name:"stop_timeout" optional:"true"`
+
+	// Strict causes Start to fail if an event is produced but not consumed, unless
+	// it is public.  Otherwise, the unused events are logged at debug level.
+	Strict bool `name:"strict_events" optional:"true"`
 }
 
-// Start a runtime in the background
-func Start(cfg Config, lx fx.Lifecycle) (err error) {
-	var loader serviceLoader
-	for _, factory := range cfg.Services {
-		loader.LoadService(lx, cfg.Log, factory)
+// Produces EvtServiceRestarted, EvtServiceFailed, EvtServiceStateChanged &
+// EvtRuntimeStarted, if cfg.Bus is not nil.
+func (cfg Config) Produces() []interface{} {
+	if cfg.Bus == nil {
+		return nil
 	}
 
-	return loader.Error()
+	return []interface{}{
+		EvtServiceRestarted{},
+		EvtServiceFailed{},
+		EvtServiceStateChanged{},
+		EvtRuntimeStarted{},
+	}
 }
 
-type serviceLoader struct {
-	err        error
-	prod, cons map[reflect.Type]struct{}
-}
+// Public satisfies PublicProducer.  The runtime's events are intended for monitoring,
+// so they are all public.
+func (cfg Config) Public() []interface{} { return cfg.Produces() }
 
-func (sl *serviceLoader) Error() error {
-	if sl.err != nil {
-		return sl.err
+// Stateful satisfies StatefulProducer.
+func (cfg Config) Stateful() []interface{} {
+	if cfg.Bus == nil {
+		return nil
 	}
 
-	for ev := range sl.cons {
-		if _, ok := sl.prod[ev]; !ok {
-			return DependencyError{ev}
-		}
+	return []interface{}{EvtRuntimeStarted{}}
+}
+
+// Graph returns the event graph declared by the services.  The runtime itself appears
+// as the producer of the events listed in Produces.
+func (cfg Config) Graph() EventGraph {
+	ds := make([]interface{}, 0, len(cfg.Services)+1)
+	ds = append(ds, cfg)
+	for _, f := range cfg.Services {
+		ds = append(ds, f)
 	}
 
-	return nil
+	return newEventGraph(ds)
 }
 
-func (sl *serviceLoader) LoadService(lx fx.Lifecycle, log ww.Logger, factory ServiceFactory) {
-	if sl.err != nil {
-		return
+// Start a runtime in the background.  If cfg.Log is nil, service lifecycle events are
+// not logged.
+//
+// Start fails if the event graph is invalid, before any service is constructed.  See
+// EventGraph.Validate.
+//
+// Each service runs under a supervisor, which restarts it according to its Policy if
+// it fails.  If cfg.Bus is not nil, EvtServiceRestarted is emitted when a service is
+// restarted, EvtServiceFailed when it is abandoned, and EvtRuntimeStarted once all
+// services have started.  The lifecycle of each service is tracked by cfg.Registry,
+// and each transition is emitted as EvtServiceStateChanged.
+//
+// Services are stopped in the order derived from the event graph:  consumers are
+// stopped before the producers of the events they consume, and independent services
+// in reverse order of registration.  Dependency cycles are broken deterministically,
+// and logged.  Each service is allotted cfg.StopTimeout to stop, unless its factory
+// implements Graceful, after which it is abandoned with a warning.
+//
+// If cfg.Tap is not nil, it records the events produced by the services.
+func Start(cfg Config, lx fx.Lifecycle) (err error) {
+	if cfg.Log == nil {
+		cfg.Log = ww.NopLogger()
 	}
 
-	var svc Service
-	if svc, sl.err = factory.NewService(); sl.err == nil {
-		lx.Append(hook(log, svc))
-		sl.addDependencies(factory)
+	// validate the graph before anything is constructed, so that an invalid graph
+	// leaves no services or emitters behind.
+	warnings, err := cfg.Graph().Validate()
+	if cfg.Strict {
+		err = multierr.Combine(append(warnings, err)...)
 	}
-}
 
-func (sl *serviceLoader) addDependencies(f ServiceFactory) {
-	if ep, ok := f.(EventProducer); ok {
-		sl.addEventProducer(ep)
+	if err != nil {
+		return err
 	}
 
-	if ec, ok := f.(EventConsumer); ok {
-		sl.addEventConsumer(ec)
+	if len(warnings) > 0 {
+		cfg.Log.WithError(multierr.Combine(warnings...)).
+			Debug("events produced but not consumed")
 	}
-}
 
-func (sl *serviceLoader) addEventProducer(ep EventProducer) {
-	if sl.prod == nil {
-		sl.prod = map[reflect.Type]struct{}{}
+	if cfg.Registry == nil {
+		cfg.Registry = NewRegistry()
 	}
 
-	for _, ev := range ep.Produces() {
-		sl.prod[reflect.TypeOf(ev)] = struct{}{}
-	}
-}
+	var (
+		e       emitters
+		started event.Emitter
+	)
+
+	if cfg.Bus != nil {
+		if e.restarted, err = cfg.Bus.Emitter(new(EvtServiceRestarted)); err != nil {
+			return
+		}
+
+		if e.failed, err = cfg.Bus.Emitter(new(EvtServiceFailed)); err != nil {
+			return
+		}
+
+		if started, err = cfg.Bus.Emitter(new(EvtRuntimeStarted), eventbus.Stateful); err != nil {
+			return
+		}
+
+		if cfg.Registry.e, err = cfg.Bus.Emitter(new(EvtServiceStateChanged)); err != nil {
+			return
+		}
 
-func (sl *serviceLoader) addEventConsumer(ec EventConsumer) {
-	if sl.cons == nil {
-		sl.cons = map[reflect.Type]struct{}{}
+		// hooks are stopped in reverse order, so the emitters outlive the services
+		lx.Append(fx.Hook{
+			OnStop: func(context.Context) error {
+				return multierr.Combine(
+					e.restarted.Close(),
+					e.failed.Close(),
+					started.Close(),
+					cfg.Registry.e.Close(),
+				)
+			},
+		})
 	}
 
-	for _, ev := range ec.Consumes() {
-		sl.cons[reflect.TypeOf(ev)] = struct{}{}
+	if cfg.Tap != nil && cfg.Bus != nil {
+		var sub event.Subscription
+		if sub, err = cfg.Tap.attach(cfg.Log, cfg.Bus, cfg.Graph()); err != nil {
+			return
+		}
+
+		// detached after the services have stopped, so that shutdown is recorded
+		lx.Append(fx.Hook{
+			OnStop: func(context.Context) error { return sub.Close() },
+		})
 	}
-}
 
-func hook(log ww.Logger, svc Service) fx.Hook {
-	return fx.Hook{
-		OnStart: func(ctx context.Context) (err error) {
-			if err = svc.Start(ctx); err == nil {
-				log.With(svc).Debug("service started")
-			}
+	// services are stopped by a single hook, in dependency order.  It precedes their
+	// startup hooks, so it also runs if startup is aborted.
+	sd := newShutdown(cfg.Log, cfg.Services, cfg.StopTimeout)
+	lx.Append(fx.Hook{OnStop: sd.Stop})
 
+	for _, factory := range cfg.Services {
+		var svc Service
+		if svc, err = factory.NewService(); err != nil {
 			return
-		},
-		OnStop: func(ctx context.Context) (err error) {
-			if err = svc.Stop(ctx); err != nil {
-				log.With(svc).WithError(err).Debug("unclean shutdown")
-			}
+		}
 
-			return
-		},
+		s := newSupervisor(cfg.Log, factory, svc, e, cfg.Registry)
+		sd.sups = append(sd.sups, s)
+		lx.Append(fx.Hook{OnStart: s.Start})
 	}
+
+	if started != nil {
+		// hooks run in order, so all services have started when this one runs
+		lx.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				return started.Emit(EvtRuntimeStarted{Services: len(cfg.Services)})
+			},
+		})
+	}
+
+	return
 }
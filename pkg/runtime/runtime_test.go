@@ -4,11 +4,15 @@ import (
 	"context"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+
+	eventbus "github.com/libp2p/go-eventbus"
 	mock_ww "github.com/wetware/ww/internal/test/mock/pkg"
 	mock_runtime "github.com/wetware/ww/internal/test/mock/pkg/runtime"
 	ww "github.com/wetware/ww/pkg"
@@ -31,6 +35,66 @@ func TestLifecycleSuccessSuite(t *testing.T) { suite.Run(t, new(LifecycleSuccess
 func TestLifecycleFailureSuite(t *testing.T) { suite.Run(t, new(LifecycleFailure)) }
 func TestFactoryFailureSuite(t *testing.T)   { suite.Run(t, new(FactoryFailure)) }
 
+func TestRuntimeStarted(t *testing.T) {
+	t.Parallel()
+
+	bus := eventbus.NewBus()
+	lx := fxtest.NewLifecycle(t)
+	require.NoError(t, runtime.Start(runtime.Config{
+		Bus:      bus,
+		Services: []runtime.ServiceFactory{&crashFactory{}, &crashFactory{}},
+	}, lx))
+
+	lx.RequireStart()
+	defer lx.RequireStop()
+
+	// EvtRuntimeStarted is stateful, so late subscribers receive it
+	sub, err := bus.Subscribe(new(runtime.EvtRuntimeStarted))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	select {
+	case v := <-sub.Out():
+		assert.Equal(t, 2, v.(runtime.EvtRuntimeStarted).Services)
+	case <-time.After(time.Second):
+		t.Fatal("EvtRuntimeStarted not emitted")
+	}
+}
+
+func TestInvalidGraph(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var constructed int
+	count := factoryFunc(func() (runtime.Service, error) {
+		constructed++
+		return mock_runtime.NewMockService(ctrl), nil
+	})
+
+	ec := mock_runtime.NewMockEventConsumer(ctrl)
+	ec.EXPECT().
+		Consumes().
+		Return([]interface{}{struct{}{}}).
+		AnyTimes()
+
+	lx := fxtest.NewLifecycle(t)
+	err := runtime.Start(runtime.Config{
+		Bus: eventbus.NewBus(),
+		Services: []runtime.ServiceFactory{
+			count,
+			consumerServiceFactory{ServiceFactory: count, EventConsumer: ec},
+		},
+	}, lx)
+	assert.EqualError(t, err, runtime.DependencyError{reflect.TypeOf(struct{}{})}.Error())
+	assert.Zero(t, constructed, "no service should be constructed")
+
+	// no hooks were registered
+	lx.RequireStart()
+	lx.RequireStop()
+}
+
 /*
 	Test Suites
 */
@@ -0,0 +1,157 @@
+package runtime
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"go.uber.org/multierr"
+
+	ww "github.com/wetware/ww/pkg"
+)
+
+// DefaultStopTimeout is the default time allotted to stopping a service.
+const DefaultStopTimeout = time.Second * 10
+
+// Graceful is an optional interface implemented by ServiceFactory that bounds the time
+// allotted to stopping its services, overriding Config.StopTimeout.
+type Graceful interface {
+	StopTimeout() time.Duration
+}
+
+// shutdown stops supervised services in dependency order.
+type shutdown struct {
+	log     ww.Logger
+	sups    []*supervisor
+	order   []int // indices into sups
+	timeout []time.Duration
+}
+
+func newShutdown(log ww.Logger, fs []ServiceFactory, d time.Duration) *shutdown {
+	if d == 0 {
+		d = DefaultStopTimeout
+	}
+
+	order, broken := stopOrder(fs)
+	for _, i := range broken {
+		log.WithField("service", serviceName(fs[i])).
+			Warn("event dependency cycle; stopping service before its consumers")
+	}
+
+	s := &shutdown{
+		log:     log,
+		sups:    make([]*supervisor, 0, len(fs)),
+		order:   order,
+		timeout: make([]time.Duration, len(fs)),
+	}
+
+	for i, f := range fs {
+		s.timeout[i] = d
+		if g, ok := f.(Graceful); ok && g.StopTimeout() > 0 {
+			s.timeout[i] = g.StopTimeout()
+		}
+	}
+
+	return s
+}
+
+// Stop each service within its timeout.  Services that do not stop in time are
+// abandoned, so that a hung service cannot stall the shutdown sequence.
+func (s *shutdown) Stop(ctx context.Context) (err error) {
+	for _, i := range s.order {
+		if i < len(s.sups) { // services are only added once constructed
+			err = multierr.Append(err, s.stop(ctx, s.sups[i], s.timeout[i]))
+		}
+	}
+
+	return
+}
+
+func (s *shutdown) stop(ctx context.Context, sup *supervisor, d time.Duration) error {
+	// fx rolls back a failed start with the start context, which has often expired.
+	// Services are nonetheless allotted their timeout.
+	if ctx.Err() != nil {
+		ctx = context.Background()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+
+	cherr := make(chan error, 1)
+	go func() { cherr <- sup.Stop(ctx) }()
+
+	select {
+	case err := <-cherr:
+		return err
+	case <-ctx.Done():
+		s.log.WithField("service", serviceName(sup.factory)).
+			WithField("timeout", d).
+			Warn("service did not stop in time; abandoned")
+		return nil
+	}
+}
+
+// stopOrder returns the indices of the factories in the order in which their services
+// are stopped.  Consumers are stopped before the producers of the events they consume,
+// and independent services in reverse order of registration.  A dependency cycle is
+// broken by stopping its last-registered service first; such services are returned in
+// broken.
+func stopOrder(fs []ServiceFactory) (order, broken []int) {
+	var (
+		producers = map[reflect.Type][]int{}
+		deps      = make([]map[int]struct{}, len(fs)) // producers of each consumer
+		consumers = make([]int, len(fs))              // number of running consumers
+		stopped   = make([]bool, len(fs))
+	)
+
+	for i, f := range fs {
+		if ep, ok := f.(EventProducer); ok {
+			for _, ev := range ep.Produces() {
+				t := reflect.TypeOf(ev)
+				producers[t] = append(producers[t], i)
+			}
+		}
+	}
+
+	for i, f := range fs {
+		deps[i] = map[int]struct{}{}
+		if ec, ok := f.(EventConsumer); ok {
+			for _, ev := range ec.Consumes() {
+				for _, j := range producers[reflect.TypeOf(ev)] {
+					if _, ok := deps[i][j]; !ok && j != i {
+						deps[i][j] = struct{}{}
+						consumers[j]++
+					}
+				}
+			}
+		}
+	}
+
+	for len(order) < len(fs) {
+		next := -1
+		for i := len(fs) - 1; i >= 0; i-- {
+			if !stopped[i] && consumers[i] == 0 {
+				next = i
+				break
+			}
+		}
+
+		if next < 0 { // cycle
+			for i := len(fs) - 1; next < 0; i-- {
+				if !stopped[i] {
+					next = i
+				}
+			}
+
+			broken = append(broken, next)
+		}
+
+		stopped[next] = true
+		order = append(order, next)
+		for j := range deps[next] {
+			consumers[j]--
+		}
+	}
+
+	return
+}
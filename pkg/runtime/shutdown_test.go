@@ -0,0 +1,169 @@
+package runtime_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx/fxtest"
+
+	"github.com/wetware/ww/pkg/runtime"
+)
+
+type evtA struct{}
+type evtB struct{}
+type evtC struct{}
+
+func TestShutdownOrder(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Chain", func(t *testing.T) {
+		t.Parallel()
+
+		var r recorder
+
+		// registered in an order that differs from the dependency order
+		lx := fxtest.NewLifecycle(t)
+		require.NoError(t, runtime.Start(runtime.Config{
+			Services: []runtime.ServiceFactory{
+				&chainFactory{name: "b", r: &r, consumes: []interface{}{evtA{}}, produces: []interface{}{evtB{}}},
+				&chainFactory{name: "a", r: &r, produces: []interface{}{evtA{}}},
+				&chainFactory{name: "c", r: &r, consumes: []interface{}{evtB{}}},
+			},
+		}, lx))
+
+		lx.RequireStart()
+		lx.RequireStop()
+
+		assert.Equal(t, []string{"c", "b", "a"}, r.Order(),
+			"consumers should be stopped before producers")
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		t.Parallel()
+
+		var r recorder
+
+		lx := fxtest.NewLifecycle(t)
+		require.NoError(t, runtime.Start(runtime.Config{
+			Services: []runtime.ServiceFactory{
+				&chainFactory{name: "a", r: &r, consumes: []interface{}{evtB{}}, produces: []interface{}{evtA{}}},
+				&chainFactory{name: "b", r: &r, consumes: []interface{}{evtA{}}, produces: []interface{}{evtB{}}},
+				&chainFactory{name: "c", r: &r, consumes: []interface{}{evtB{}}, produces: []interface{}{evtC{}}},
+				&chainFactory{name: "d", r: &r, consumes: []interface{}{evtC{}}},
+			},
+		}, lx))
+
+		lx.RequireStart()
+		lx.RequireStop()
+
+		assert.Equal(t, []string{"d", "c", "b", "a"}, r.Order(),
+			"cycle should be broken at its last-registered service")
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			r    recorder
+			hang = make(chan struct{})
+		)
+		defer close(hang)
+
+		lx := fxtest.NewLifecycle(t)
+		require.NoError(t, runtime.Start(runtime.Config{
+			StopTimeout: time.Hour,
+			Services: []runtime.ServiceFactory{
+				&chainFactory{name: "a", r: &r, produces: []interface{}{evtA{}}},
+				&chainFactory{name: "b", r: &r, consumes: []interface{}{evtA{}},
+					hang: hang, timeout: time.Millisecond * 10},
+			},
+		}, lx))
+
+		lx.RequireStart()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		require.NoError(t, lx.Stop(ctx), "hung service should be abandoned")
+		assert.Equal(t, []string{"a"}, r.Order())
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		t.Parallel()
+
+		var r recorder
+
+		lx := fxtest.NewLifecycle(t)
+		require.NoError(t, runtime.Start(runtime.Config{
+			Services: []runtime.ServiceFactory{
+				&chainFactory{name: "a", r: &r, produces: []interface{}{evtA{}}},
+				&chainFactory{name: "b", r: &r, consumes: []interface{}{evtA{}}},
+			},
+		}, lx))
+
+		lx.RequireStart()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		require.NoError(t, lx.Stop(ctx))
+		assert.Equal(t, []string{"b", "a"}, r.Order(),
+			"services should be stopped despite an expired context")
+	})
+}
+
+type recorder struct {
+	mu    sync.Mutex
+	order []string
+}
+
+func (r *recorder) Record(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.order = append(r.order, name)
+}
+
+func (r *recorder) Order() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.order...)
+}
+
+// chainFactory produces services that record their name when stopped.  If hang is not
+// nil, Stop blocks until it is closed, ignoring its context.
+type chainFactory struct {
+	name               string
+	r                  *recorder
+	produces, consumes []interface{}
+	hang               chan struct{}
+	timeout            time.Duration
+}
+
+func (f *chainFactory) Produces() []interface{}    { return f.produces }
+func (f *chainFactory) Consumes() []interface{}    { return f.consumes }
+func (f *chainFactory) StopTimeout() time.Duration { return f.timeout }
+
+func (f *chainFactory) NewService() (runtime.Service, error) { return chainService{f}, nil }
+
+type chainService struct{ f *chainFactory }
+
+func (s chainService) Loggable() map[string]interface{} {
+	return map[string]interface{}{"service": s.f.name}
+}
+
+func (s chainService) Start(context.Context) error { return nil }
+
+func (s chainService) Stop(context.Context) error {
+	if s.f.hang != nil {
+		<-s.f.hang
+	}
+
+	s.f.r.Record(s.f.name)
+	return nil
+}
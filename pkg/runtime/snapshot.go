@@ -0,0 +1,39 @@
+package runtime
+
+// Snapshotter is an optional interface implemented by Service that reports its
+// current state, e.g. for the benefit of a debugging client.  Snapshot is called
+// concurrently with the service's background loops, and MUST NOT block them; services
+// typically copy their state on read, or publish it atomically from their loops.
+type Snapshotter interface {
+	Snapshot() interface{}
+}
+
+// Snapshot of a service's state.
+type Snapshot struct {
+	// Service is the Loggable representation of the current incarnation of the
+	// service.
+	Service map[string]interface{}
+	Value   interface{}
+}
+
+// Snapshot returns the state of each service that implements Snapshotter, in order of
+// registration.  Services that are not running are omitted, since their state may be
+// incomplete.
+func (r *Registry) Snapshot() []Snapshot {
+	r.mu.RLock()
+	es := make([]entry, len(r.es))
+	copy(es, r.es)
+	r.mu.RUnlock()
+
+	var ss []Snapshot
+	for _, e := range es {
+		if s, ok := e.svc.(Snapshotter); ok && e.State == StateRunning {
+			ss = append(ss, Snapshot{
+				Service: e.svc.Loggable(),
+				Value:   s.Snapshot(),
+			})
+		}
+	}
+
+	return ss
+}
@@ -0,0 +1,362 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/event"
+
+	ww "github.com/wetware/ww/pkg"
+)
+
+const (
+	// DefaultMinBackoff is the default delay before the first restart of a service.
+	DefaultMinBackoff = time.Second
+
+	// DefaultMaxBackoff is the default maximum delay between restarts of a service.
+	// A service that runs for longer than its maximum backoff is considered to have
+	// recovered, and its restart count is reset.
+	DefaultMaxBackoff = time.Minute
+
+	// DefaultMaxRestarts is the default number of consecutive restarts after which a
+	// service is abandoned.
+	DefaultMaxRestarts = 5
+
+	// timeout for stopping a failed service, or starting a restarted one
+	recoveryTimeout = time.Second * 15
+)
+
+// Restart specifies the conditions under which a supervised service is restarted.
+type Restart uint8
+
+const (
+	// RestartAlways restarts the service if it fails, or if any of its background
+	// loops returns before the service is stopped.
+	RestartAlways Restart = iota
+
+	// RestartOnFailure restarts the service if it fails, i.e. if it panics or fails to
+	// start.
+	RestartOnFailure
+
+	// RestartNever abandons the service as soon as it fails.
+	RestartNever
+)
+
+func (r Restart) String() string {
+	switch r {
+	case RestartAlways:
+		return "always"
+	case RestartOnFailure:
+		return "on-failure"
+	case RestartNever:
+		return "never"
+	}
+
+	return fmt.Sprintf("Restart(%d)", r)
+}
+
+// Policy governs the supervision of a service.  Zero-valued fields are replaced by
+// their defaults.
+type Policy struct {
+	Restart                Restart
+	MinBackoff, MaxBackoff time.Duration
+	MaxRestarts            int
+}
+
+// Supervised is an optional interface implemented by ServiceFactory that declares the
+// policy under which its services are supervised.  Services whose factory does not
+// implement Supervised are always restarted, with the default backoff.
+type Supervised interface {
+	Policy() Policy
+}
+
+// EvtServiceRestarted is emitted when a failed service has been restarted.
+type EvtServiceRestarted struct {
+	// Service is the Loggable representation of the restarted service.
+	Service map[string]interface{}
+
+	// Restarts is the number of consecutive restarts, including this one.
+	Restarts int
+}
+
+// EvtServiceFailed is emitted when a service is abandoned, either because its policy
+// forbids restarting it, or because it exceeded its maximum number of restarts.
+type EvtServiceFailed struct {
+	// Service is the Loggable representation of the failed service.
+	Service map[string]interface{}
+
+	Restarts int
+	Err      error
+}
+
+// Go runs f in the background on behalf of the service being started with ctx, which
+// MUST be derived from the context passed to Service.Start.  If f panics, the panic is
+// recovered and the service is restarted according to its policy.  The same is true if
+// f returns before the service is stopped, unless the policy is RestartOnFailure or
+// RestartNever.
+//
+// If the service is not supervised, f is simply run in a goroutine.
+func Go(ctx context.Context, f func()) {
+	inst, ok := ctx.Value(keyInstance{}).(instance)
+	if !ok {
+		go f()
+		return
+	}
+
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				inst.fail(fmt.Errorf("panic: %v", v))
+				return
+			}
+
+			inst.exit()
+		}()
+
+		f()
+	}()
+}
+
+type keyInstance struct{}
+
+// instance identifies one incarnation of a supervised service.
+type instance struct {
+	s   *supervisor
+	gen uint64
+}
+
+func (inst instance) fail(err error) { inst.s.recover(inst.gen, err, true) }
+func (inst instance) exit()          { inst.s.recover(inst.gen, errExited, false) }
+
+var errExited = fmt.Errorf("background loop exited")
+
+// supervisor restarts a service when it fails.  It holds its lock while starting and
+// stopping services, so that shutdown waits for any recovery in progress.
+type supervisor struct {
+	log     ww.Logger
+	factory ServiceFactory
+	policy  Policy
+	e       emitters
+	reg     *Registry
+	idx     int // index of the service in reg
+
+	mu       sync.Mutex
+	svc      Service // nil while the service is down
+	gen      uint64
+	stopping bool
+	restarts int
+	started  time.Time
+	timer    *time.Timer
+}
+
+// emitters for supervision events.  Both are nil if the runtime has no bus.
+type emitters struct {
+	restarted, failed event.Emitter
+}
+
+func newSupervisor(log ww.Logger, f ServiceFactory, svc Service, e emitters, reg *Registry) *supervisor {
+	p := Policy{Restart: RestartAlways}
+	if s, ok := f.(Supervised); ok {
+		p = s.Policy()
+	}
+
+	if p.MinBackoff == 0 {
+		p.MinBackoff = DefaultMinBackoff
+	}
+
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = DefaultMaxBackoff
+	}
+
+	if p.MaxRestarts == 0 {
+		p.MaxRestarts = DefaultMaxRestarts
+	}
+
+	return &supervisor{
+		log:     log,
+		factory: f,
+		policy:  p,
+		e:       e,
+		reg:     reg,
+		idx:     reg.add(svc),
+		svc:     svc,
+	}
+}
+
+// Start the service.  Unlike restarts, which are retried, a failure to start the
+// service initially aborts the startup sequence.
+func (s *supervisor) Start(ctx context.Context) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.report(s.svc, StateStarting, nil)
+	if err = s.start(ctx, s.svc); err != nil {
+		s.report(s.svc, StateFailed, err)
+		s.svc = nil
+		return
+	}
+
+	s.report(s.svc, StateRunning, nil)
+	s.log.With(s.svc).Debug("service started")
+	return
+}
+
+// Stop the service, and cancel any pending restart.
+func (s *supervisor) Stop(ctx context.Context) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopping = true
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+
+	if s.svc == nil || s.started.IsZero() {
+		return nil // failed, or never started
+	}
+
+	s.report(s.svc, StateStopping, nil)
+	if err = s.svc.Stop(ctx); err != nil {
+		s.log.With(s.svc).WithError(err).Debug("unclean shutdown")
+	}
+
+	s.report(s.svc, StateStopped, err)
+	return
+}
+
+// start svc, recovering from panics.  The caller MUST hold s.mu.
+func (s *supervisor) start(ctx context.Context, svc Service) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+
+	s.started = time.Now()
+	return svc.Start(context.WithValue(ctx, keyInstance{}, instance{s: s, gen: s.gen}))
+}
+
+// recover from the failure or exit of the given incarnation of the service.
+func (s *supervisor) recover(gen uint64, err error, failure bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopping || gen != s.gen || s.svc == nil {
+		return // stopped, or already recovering
+	}
+
+	svc := s.svc
+	if !failure && s.policy.Restart != RestartAlways {
+		s.log.With(svc).Debug("background loop exited")
+		return
+	}
+
+	s.log.With(svc).WithError(err).Error("service failed")
+	s.report(svc, StateFailed, err)
+
+	// invalidate the failed incarnation, and release its resources
+	s.gen++
+	s.svc = nil
+
+	ctx, cancel := context.WithTimeout(context.Background(), recoveryTimeout)
+	defer cancel()
+
+	if err := svc.Stop(ctx); err != nil {
+		s.log.With(svc).WithError(err).Debug("unclean shutdown")
+	}
+
+	if time.Since(s.started) > s.policy.MaxBackoff {
+		s.restarts = 0 // the service had recovered
+	}
+
+	s.schedule(svc, err)
+}
+
+// schedule a restart, or abandon the service.  The caller MUST hold s.mu.
+func (s *supervisor) schedule(svc Service, err error) {
+	if s.policy.Restart == RestartNever || s.restarts >= s.policy.MaxRestarts {
+		s.abandon(svc, err)
+		return
+	}
+
+	s.restarts++
+	s.timer = time.AfterFunc(s.backoff(), s.restart)
+}
+
+func (s *supervisor) restart() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopping {
+		return
+	}
+
+	svc, err := s.factory.NewService()
+	if err != nil {
+		s.log.WithError(err).Error("failed to restart service")
+		s.report(nil, StateFailed, err)
+		s.schedule(nil, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), recoveryTimeout)
+	defer cancel()
+
+	s.report(svc, StateStarting, nil)
+	if err = s.start(ctx, svc); err != nil {
+		s.log.With(svc).WithError(err).Error("failed to restart service")
+		s.report(svc, StateFailed, err)
+		s.gen++ // invalidate any loops started before the failure
+		s.schedule(svc, err)
+		return
+	}
+
+	s.svc = svc
+	s.report(svc, StateRunning, nil)
+	s.log.With(svc).WithField("restarts", s.restarts).Info("service restarted")
+
+	if s.e.restarted != nil {
+		ev := EvtServiceRestarted{Service: svc.Loggable(), Restarts: s.restarts}
+		if err := s.e.restarted.Emit(ev); err != nil {
+			s.log.WithError(err).Error("failed to emit EvtServiceRestarted")
+		}
+	}
+}
+
+func (s *supervisor) abandon(svc Service, err error) {
+	ev := EvtServiceFailed{Restarts: s.restarts, Err: err}
+	if svc != nil {
+		ev.Service = svc.Loggable()
+	}
+
+	s.log.WithField("restarts", s.restarts).WithError(err).Error("service abandoned")
+
+	if s.e.failed != nil {
+		if err := s.e.failed.Emit(ev); err != nil {
+			s.log.WithError(err).Error("failed to emit EvtServiceFailed")
+		}
+	}
+}
+
+// report a state transition to the registry.  The caller MUST hold s.mu.
+func (s *supervisor) report(svc Service, to State, err error) {
+	if err := s.reg.transition(s.idx, svc, to, err, s.restarts); err != nil {
+		s.log.WithError(err).Error("failed to emit EvtServiceStateChanged")
+	}
+}
+
+// backoff doubles with each consecutive restart, up to MaxBackoff.
+func (s *supervisor) backoff() time.Duration {
+	d := s.policy.MinBackoff
+	for i := 1; i < s.restarts && d < s.policy.MaxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > s.policy.MaxBackoff {
+		d = s.policy.MaxBackoff
+	}
+
+	return d
+}
@@ -0,0 +1,240 @@
+package runtime_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx/fxtest"
+
+	eventbus "github.com/libp2p/go-eventbus"
+
+	logutil "github.com/wetware/ww/internal/util/log"
+	"github.com/wetware/ww/pkg/runtime"
+)
+
+func TestSupervisor(t *testing.T) {
+	t.Parallel()
+
+	t.Run("CircuitBreaker", func(t *testing.T) {
+		t.Parallel()
+
+		bus := eventbus.NewBus()
+		sub, err := bus.Subscribe([]interface{}{
+			new(runtime.EvtServiceRestarted),
+			new(runtime.EvtServiceFailed),
+		})
+		require.NoError(t, err)
+		defer sub.Close()
+
+		f := &crashFactory{
+			policy: runtime.Policy{MinBackoff: time.Millisecond, MaxRestarts: 2},
+			crash:  func() { panic("test") },
+		}
+
+		lx := fxtest.NewLifecycle(t)
+		require.NoError(t, runtime.Start(runtime.Config{
+			Log:      logutil.Nop(),
+			Bus:      bus,
+			Services: []runtime.ServiceFactory{f},
+		}, lx))
+
+		lx.RequireStart()
+		defer lx.RequireStop()
+
+		for i := 1; i <= 2; i++ {
+			select {
+			case v := <-sub.Out():
+				ev := v.(runtime.EvtServiceRestarted)
+				assert.Equal(t, i, ev.Restarts)
+				assert.Equal(t, "crasher", ev.Service["service"])
+			case <-time.After(time.Second):
+				t.Fatal("EvtServiceRestarted not emitted")
+			}
+		}
+
+		select {
+		case v := <-sub.Out():
+			ev := v.(runtime.EvtServiceFailed)
+			assert.Equal(t, 2, ev.Restarts)
+			assert.EqualError(t, ev.Err, "panic: test")
+			assert.Equal(t, "crasher", ev.Service["service"])
+		case <-time.After(time.Second):
+			t.Fatal("EvtServiceFailed not emitted")
+		}
+
+		assert.Equal(t, int32(3), f.Instances(), "service should be restarted twice")
+		assert.Equal(t, int32(3), f.Stopped(), "failed services should be stopped")
+	})
+
+	t.Run("Never", func(t *testing.T) {
+		t.Parallel()
+
+		bus := eventbus.NewBus()
+		sub, err := bus.Subscribe(new(runtime.EvtServiceFailed))
+		require.NoError(t, err)
+		defer sub.Close()
+
+		f := &crashFactory{
+			policy: runtime.Policy{Restart: runtime.RestartNever},
+			crash:  func() { panic("test") },
+		}
+
+		lx := fxtest.NewLifecycle(t)
+		require.NoError(t, runtime.Start(runtime.Config{
+			Bus:      bus,
+			Services: []runtime.ServiceFactory{f},
+		}, lx))
+
+		lx.RequireStart()
+		defer lx.RequireStop()
+
+		select {
+		case v := <-sub.Out():
+			assert.Zero(t, v.(runtime.EvtServiceFailed).Restarts)
+		case <-time.After(time.Second):
+			t.Fatal("EvtServiceFailed not emitted")
+		}
+
+		assert.Equal(t, int32(1), f.Instances())
+	})
+
+	t.Run("Exit", func(t *testing.T) {
+		t.Parallel()
+
+		for _, tt := range []struct {
+			restart   runtime.Restart
+			instances int32
+		}{
+			{restart: runtime.RestartAlways, instances: 2},
+			{restart: runtime.RestartOnFailure, instances: 1},
+		} {
+			f := &crashFactory{
+				policy: runtime.Policy{Restart: tt.restart, MinBackoff: time.Millisecond},
+				crash:  func() {}, // return immediately
+				once:   true,
+			}
+
+			lx := fxtest.NewLifecycle(t)
+			require.NoError(t, runtime.Start(runtime.Config{
+				Services: []runtime.ServiceFactory{f},
+			}, lx))
+
+			lx.RequireStart()
+			time.Sleep(time.Millisecond * 50)
+			lx.RequireStop()
+
+			assert.Equal(t, tt.instances, f.Instances(), "policy %s", tt.restart)
+		}
+	})
+
+	t.Run("StopOrder", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			mu    sync.Mutex
+			order []string
+		)
+
+		record := func(name string) func() {
+			return func() {
+				mu.Lock()
+				defer mu.Unlock()
+				order = append(order, name)
+			}
+		}
+
+		first := &crashFactory{
+			policy: runtime.Policy{MinBackoff: time.Millisecond},
+			onStop: record("first"),
+		}
+
+		// the second service crashes, and is restarted after the first was started
+		second := &crashFactory{
+			policy: runtime.Policy{MinBackoff: time.Millisecond},
+			crash:  func() { panic("test") },
+			once:   true,
+			onStop: record("second"),
+		}
+
+		lx := fxtest.NewLifecycle(t)
+		require.NoError(t, runtime.Start(runtime.Config{
+			Services: []runtime.ServiceFactory{first, second},
+		}, lx))
+
+		lx.RequireStart()
+		require.Eventually(t, func() bool {
+			return second.Instances() == 2
+		}, time.Second, time.Millisecond)
+
+		mu.Lock()
+		order = nil // discard the crash
+		mu.Unlock()
+
+		lx.RequireStop()
+		assert.Equal(t, []string{"second", "first"}, order,
+			"restarted services should be stopped in reverse order of registration")
+	})
+}
+
+// crashFactory produces services that run crash in the background.  If once is true,
+// only the first service crashes.
+type crashFactory struct {
+	policy runtime.Policy
+	crash  func()
+	once   bool
+	onStop func()
+
+	instances, stopped int32
+}
+
+func (f *crashFactory) Policy() runtime.Policy { return f.policy }
+
+func (f *crashFactory) Instances() int32 { return atomic.LoadInt32(&f.instances) }
+func (f *crashFactory) Stopped() int32   { return atomic.LoadInt32(&f.stopped) }
+
+func (f *crashFactory) NewService() (runtime.Service, error) {
+	svc := &crasher{f: f, cq: make(chan struct{})}
+	if n := atomic.AddInt32(&f.instances, 1); n == 1 || !f.once {
+		svc.crash = f.crash
+	}
+
+	return svc, nil
+}
+
+type crasher struct {
+	f     *crashFactory
+	crash func()
+	cq    chan struct{}
+}
+
+func (c *crasher) Loggable() map[string]interface{} {
+	return map[string]interface{}{"service": "crasher"}
+}
+
+func (c *crasher) Start(ctx context.Context) error {
+	runtime.Go(ctx, func() {
+		if c.crash != nil {
+			c.crash()
+			return
+		}
+
+		<-c.cq
+	})
+
+	return nil
+}
+
+func (c *crasher) Stop(context.Context) error {
+	close(c.cq)
+	atomic.AddInt32(&c.f.stopped, 1)
+	if c.f.onStop != nil {
+		c.f.onStop()
+	}
+
+	return nil
+}
@@ -22,7 +22,7 @@ import (
 type Config struct {
 	fx.In
 
-	Log       ww.Logger
+	Log       ww.Logger `optional:"true"`
 	Host      host.Host
 	Announcer cluster.Announcer
 	TTL       time.Duration `name:"ttl"`
@@ -37,7 +37,6 @@ func (cfg Config) NewService() (runtime.Service, error) {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	a := announcer{
-		log:      cfg.Log,
 		h:        cfg.Host,
 		ttl:      cfg.TTL,
 		cluster:  cfg.Announcer,
@@ -47,6 +46,8 @@ func (cfg Config) NewService() (runtime.Service, error) {
 		announce: make(chan struct{}),
 	}
 
+	a.log = internal.Logger(cfg.Log, a)
+
 	return a, nil
 }
 
@@ -92,8 +93,10 @@ func (a announcer) Loggable() map[string]interface{} {
 func (a announcer) Start(ctx context.Context) (err error) {
 	if err = internal.WaitNetworkReady(ctx, a.h.EventBus()); err == nil {
 		if err = a.cluster.Announce(ctx, a.ttl); err == nil {
-			go a.subloop()
-			go a.announceloop()
+			internal.StartBackground(ctx,
+				a.subloop,
+				a.announceloop,
+			)
 		}
 	}
 
@@ -143,7 +146,7 @@ func (a announcer) subloop() {
 func (a announcer) announceloop() {
 	for range a.announce {
 		if err := a.cluster.Announce(a.ctx, a.ttl); err != nil && err != context.Canceled {
-			a.log.With(a).WithError(err).Warn("announcement failed")
+			a.log.WithError(err).Warn("announcement failed")
 		}
 	}
 }
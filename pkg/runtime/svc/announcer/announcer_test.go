@@ -8,7 +8,6 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	mock_ww "github.com/wetware/ww/internal/test/mock/pkg"
 	mock_cluster "github.com/wetware/ww/internal/test/mock/pkg/cluster"
 	mock_vendor "github.com/wetware/ww/internal/test/mock/vendor"
 	testutil "github.com/wetware/ww/internal/test/util"
@@ -29,7 +28,6 @@ func TestAnnouncerLogFields(t *testing.T) {
 	bus := eventbus.NewBus()
 
 	a, err := announcer_service.New(announcer_service.Config{
-		Log:       mock_ww.NewMockLogger(ctrl),
 		Host:      newMockHost(ctrl, bus),
 		Announcer: mock_cluster.NewMockAnnouncer(ctrl),
 		TTL:       time.Second,
@@ -54,7 +52,6 @@ func TestAnnouner(t *testing.T) {
 	ma := mock_cluster.NewMockAnnouncer(ctrl)
 
 	a, err := announcer_service.New(announcer_service.Config{
-		Log:       mock_ww.NewMockLogger(ctrl),
 		Host:      newMockHost(ctrl, bus),
 		Announcer: ma,
 		TTL:       time.Second,
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/libp2p/go-libp2p-core/host"
+	ww "github.com/wetware/ww/pkg"
 	"github.com/wetware/ww/pkg/boot"
 	"github.com/wetware/ww/pkg/runtime"
 	"github.com/wetware/ww/pkg/runtime/svc/internal"
@@ -14,6 +15,7 @@ import (
 type Config struct {
 	fx.In
 
+	Log      ww.Logger `optional:"true"`
 	Host     host.Host
 	Strategy boot.Strategy
 }
@@ -21,7 +23,9 @@ type Config struct {
 // NewService satisfies runtime.ServiceFactory.
 func (cfg Config) NewService() (runtime.Service, error) {
 	if b, ok := cfg.Strategy.(boot.Beacon); ok {
-		return beaconService{Beacon: b, h: cfg.Host}, nil
+		s := beaconService{Beacon: b, h: cfg.Host}
+		s.log = internal.Logger(cfg.Log, s)
+		return s, nil
 	}
 
 	return nopService{}, nil
@@ -40,7 +44,8 @@ type Module struct {
 func New(cfg Config) Module { return Module{Factory: cfg} }
 
 type beaconService struct {
-	h host.Host
+	log ww.Logger
+	h   host.Host
 	boot.Beacon
 }
 
@@ -23,17 +23,21 @@ type EvtPeerDiscovered peer.AddrInfo
 type Config struct {
 	fx.In
 
-	Log      ww.Logger
+	Log      ww.Logger `optional:"true"`
 	Host     host.Host
 	Strategy boot.Strategy
+
+	// Namespace and SkipHandshake govern the hello handshake with discovered peers.
+	Namespace     string `name:"ns" optional:"true"`
+	SkipHandshake bool   `name:"skip_handshake" optional:"true"`
 }
 
 // NewService satisfies runtime.ServiceFactory
 func (cfg Config) NewService() (_ runtime.Service, err error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	b := &bootstrapper{
-		log:      cfg.Log,
 		s:        cfg.Strategy,
+		opt:      options(cfg),
 		h:        cfg.Host,
 		ctx:      ctx,
 		cancel:   cancel,
@@ -44,10 +48,12 @@ func (cfg Config) NewService() (_ runtime.Service, err error) {
 		return
 	}
 
-	if b.foundPeer, err = cfg.Host.EventBus().Emitter(new(EvtPeerDiscovered)); err != nil {
+	if b.foundPeer, err = internal.NewEmitter(cfg.Host.EventBus(), new(EvtPeerDiscovered)); err != nil {
 		return
 	}
 
+	b.log = internal.Logger(cfg.Log, b)
+
 	return b, nil
 }
 
@@ -78,8 +84,9 @@ func New(cfg Config) Module { return Module{Factory: cfg} }
 type bootstrapper struct {
 	log ww.Logger
 
-	s boot.Strategy
-	h host.Host
+	s   boot.Strategy
+	opt []boot.Option
+	h   host.Host
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -98,7 +105,7 @@ func (b bootstrapper) Loggable() map[string]interface{} {
 
 func (b *bootstrapper) Start(ctx context.Context) (err error) {
 	if err = internal.WaitNetworkReady(ctx, b.h.EventBus()); err == nil {
-		internal.StartBackground(
+		internal.StartBackground(ctx,
 			b.queryloop,
 			b.subloop,
 		)
@@ -134,9 +141,10 @@ func (b bootstrapper) queryloop() {
 	defer b.foundPeer.Close() // see b.emit()
 
 	for range b.discover {
-		ch, err := b.s.DiscoverPeers(b.ctx, boot.WithLimit(3))
+		ch, err := b.s.DiscoverPeers(b.ctx, append(b.opt[:len(b.opt):len(b.opt)],
+			boot.WithErrorHandler(b.onError))...)
 		if err != nil {
-			b.log.With(b).WithError(err).Debug("error discovering peers")
+			b.log.WithError(err).Debug("error discovering peers")
 			continue
 		}
 
@@ -150,12 +158,25 @@ func (b bootstrapper) queryloop() {
 	}
 }
 
+func (b bootstrapper) onError(err error) {
+	b.log.WithError(err).Error("peer discovery failed")
+}
+
 func (b bootstrapper) emit(info peer.AddrInfo) {
-	if err := b.foundPeer.Emit(EvtPeerDiscovered(info)); err != nil {
-		b.log.With(b).WithError(err).Error("failed to emit EvtPeerDiscovered")
+	if err := b.foundPeer.Emit(EvtPeerDiscovered(info)); err != nil && err != internal.ErrEmitterClosed {
+		b.log.WithError(err).Error("failed to emit EvtPeerDiscovered")
 	}
 }
 
+func options(cfg Config) []boot.Option {
+	opt := []boot.Option{boot.WithLimit(3)}
+	if !cfg.SkipHandshake {
+		opt = append(opt, boot.WithHandshake(cfg.Host, cfg.Namespace))
+	}
+
+	return opt
+}
+
 func notOrphaned(ev neighborhood.EvtNeighborhoodChanged) bool {
 	return ev.To != neighborhood.PhaseOrphaned
 }
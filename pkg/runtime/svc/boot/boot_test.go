@@ -2,6 +2,7 @@ package boot_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -28,12 +29,10 @@ func TestBootstrapperLogFields(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	logger := mock_ww.NewMockLogger(ctrl)
 	bus := eventbus.NewBus()
 	h := newMockHost(ctrl, bus)
 
 	b, err := boot_service.New(boot_service.Config{
-		Log:      logger,
 		Host:     h,
 		Strategy: boot.StaticAddrs{},
 	}).Factory.NewService()
@@ -52,14 +51,12 @@ func TestBootstrapper(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 
-	logger := mock_ww.NewMockLogger(ctrl)
 	bus := eventbus.NewBus()
 	h := newMockHost(ctrl, bus)
 
 	s := mock_boot.NewMockStrategy(ctrl)
 
 	b, err := boot_service.New(boot_service.Config{
-		Log:      logger,
 		Host:     h,
 		Strategy: s,
 	}).Factory.NewService()
@@ -121,6 +118,83 @@ func TestBootstrapper(t *testing.T) {
 	})
 }
 
+func TestBootstrapperError(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	errTest := errors.New("failed to bind to any multicast udp port")
+	reported := make(chan struct{})
+
+	logger := mock_ww.NewMockLogger(ctrl)
+	logger.EXPECT().
+		With(gomock.Any()).
+		Return(logger).
+		Times(1)
+
+	logger.EXPECT().
+		WithError(errTest).
+		Return(logger).
+		Times(1)
+
+	logger.EXPECT().
+		Error("peer discovery failed").
+		Do(func(...interface{}) { close(reported) }).
+		Times(1)
+
+	// the strategy reports an asynchronous error, and yields no peers
+	s := mock_boot.NewMockStrategy(ctrl)
+	s.EXPECT().
+		DiscoverPeers(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, opt ...boot.Option) (<-chan peer.AddrInfo, error) {
+			var p boot.Param
+			if err := p.Apply(opt); err != nil {
+				return nil, err
+			}
+
+			ch := make(chan peer.AddrInfo)
+			go func() {
+				defer close(ch)
+				p.OnError(errTest)
+			}()
+
+			return ch, nil
+		}).
+		Times(1)
+
+	bus := eventbus.NewBus()
+	b, err := boot_service.New(boot_service.Config{
+		Log:      logger,
+		Host:     newMockHost(ctrl, bus),
+		Strategy: s,
+	}).Factory.NewService()
+	require.NoError(t, err)
+
+	require.NoError(t, netReady(bus))
+	require.NoError(t, b.Start(ctx))
+	defer func() {
+		require.NoError(t, b.Stop(ctx))
+	}()
+
+	e, err := bus.Emitter(new(neighborhood_service.EvtNeighborhoodChanged))
+	require.NoError(t, err)
+	defer e.Close()
+
+	require.NoError(t, e.Emit(neighborhood_service.EvtNeighborhoodChanged{
+		To: neighborhood_service.PhaseOrphaned,
+	}))
+
+	select {
+	case <-reported:
+	case <-ctx.Done():
+		t.Error("error was not reported")
+	}
+}
+
 func newMockHost(ctrl *gomock.Controller, bus event.Bus) *mock_vendor.MockHost {
 	h := mock_vendor.NewMockHost(ctrl)
 	h.EXPECT().
@@ -0,0 +1,369 @@
+// Package bootstrap implements a service that repeatedly discovers and connects to
+// bootstrap peers while the local host is weakly connected to the cluster.
+package bootstrap
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/lthibault/jitterbug"
+	"go.uber.org/fx"
+
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	logutil "github.com/wetware/ww/internal/util/log"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/boot"
+	"github.com/wetware/ww/pkg/runtime"
+	boot_service "github.com/wetware/ww/pkg/runtime/svc/boot"
+	"github.com/wetware/ww/pkg/runtime/svc/internal"
+	"github.com/wetware/ww/pkg/runtime/svc/neighborhood"
+	randutil "github.com/wetware/ww/pkg/util/rand"
+)
+
+const (
+	// DefaultBackoff is the delay before retrying a failed attempt.
+	DefaultBackoff = time.Second
+
+	// DefaultMaxBackoff bounds the delay between attempts.
+	DefaultMaxBackoff = time.Minute
+
+	// number of peers requested from the boot strategy in each attempt
+	peersPerAttempt = 3
+
+	discoverTimeout = time.Second * 5
+	connectTimeout  = time.Second * 30
+)
+
+// EvtBootstrapAttempt is emitted after each attempt to bootstrap.
+type EvtBootstrapAttempt struct {
+	// Attempt is the number of consecutive attempts, including this one, since the
+	// neighborhood was last healthy or an attempt last succeeded.
+	Attempt int
+
+	// Found is the number of peers returned by the boot strategy.  Connected and
+	// Failed count the outcomes of dialing them.  Peers to which the host was already
+	// connected are not dialed.
+	Found, Connected, Failed int
+
+	// Err is non-nil if the boot strategy failed.
+	Err error
+}
+
+// Succeeded returns true if a new connection was established.
+func (ev EvtBootstrapAttempt) Succeeded() bool { return ev.Connected > 0 }
+
+// Snapshot of the bootstrap service, reported by its runtime.Snapshotter.
+type Snapshot struct {
+	// Active is true while the neighborhood is unhealthy, i.e. while the service is
+	// attempting to bootstrap.
+	Active bool
+
+	// Last is the latest attempt, if any.
+	Last *EvtBootstrapAttempt
+}
+
+// Config for Bootstrap service.
+type Config struct {
+	fx.In
+
+	Log      ww.Logger `optional:"true"`
+	Host     host.Host
+	Strategy boot.Strategy
+
+	// Namespace and SkipHandshake govern the hello handshake with discovered peers.
+	Namespace     string `name:"ns" optional:"true"`
+	SkipHandshake bool   `name:"skip_handshake" optional:"true"`
+
+	// Backoff and MaxBackoff default to DefaultBackoff and DefaultMaxBackoff.
+	Backoff    time.Duration `name:"bootstrap_backoff" optional:"true"`
+	MaxBackoff time.Duration `name:"bootstrap_max_backoff" optional:"true"`
+}
+
+// NewService satisfies runtime.ServiceFactory
+func (cfg Config) NewService() (_ runtime.Service, err error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &bootstrapper{
+		s:      cfg.Strategy,
+		h:      cfg.Host,
+		ns:     cfg.Namespace,
+		verify: !cfg.SkipHandshake,
+		base:   cfg.Backoff,
+		ceil:   cfg.MaxBackoff,
+		ctx:    ctx,
+		cancel: cancel,
+		phase:  make(chan neighborhood.Phase, 1),
+		state:  new(atomic.Value),
+	}
+	b.state.Store(Snapshot{})
+
+	if b.base == 0 {
+		b.base = DefaultBackoff
+	}
+
+	if b.ceil == 0 {
+		b.ceil = DefaultMaxBackoff
+	}
+
+	if b.sub, err = cfg.Host.EventBus().Subscribe(new(neighborhood.EvtNeighborhoodChanged)); err != nil {
+		return
+	}
+
+	if b.e, err = internal.NewEmitter(cfg.Host.EventBus(), new(EvtBootstrapAttempt)); err != nil {
+		return
+	}
+
+	if b.found, err = internal.NewEmitter(cfg.Host.EventBus(), new(boot_service.EvtPeerDiscovered)); err != nil {
+		return
+	}
+
+	b.log = internal.Logger(cfg.Log, b)
+
+	return b, nil
+}
+
+// Produces EvtBootstrapAttempt & boot.EvtPeerDiscovered.
+func (cfg Config) Produces() []interface{} {
+	return []interface{}{
+		EvtBootstrapAttempt{},
+		boot_service.EvtPeerDiscovered{},
+	}
+}
+
+// Consumes neighborhood.EvtNeighborhoodChanged.
+func (cfg Config) Consumes() []interface{} {
+	return []interface{}{
+		neighborhood.EvtNeighborhoodChanged{},
+	}
+}
+
+// Module for Bootstrap service.
+type Module struct {
+	fx.Out
+
+	Factory runtime.ServiceFactory `group:"runtime"`
+}
+
+// New Bootstrap service.  While the neighborhood is orphaned or partial, the service
+// periodically discovers peers using the boot strategy, and connects to them.  Failed
+// attempts are retried with jittered, exponential backoff.  The service is idle while
+// the neighborhood is complete.
+//
+// Consumes:
+//   - neighborhood.EvtNeighborhoodChanged
+//
+// Emits:
+//   - EvtBootstrapAttempt
+//   - boot.EvtPeerDiscovered
+func New(cfg Config) Module { return Module{Factory: cfg} }
+
+type bootstrapper struct {
+	log ww.Logger
+
+	s boot.Strategy
+	h host.Host
+
+	ns     string
+	verify bool
+
+	base, ceil time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	phase    chan neighborhood.Phase
+	state    *atomic.Value // Snapshot, published by loop
+	sub      event.Subscription
+	e, found *internal.Emitter
+}
+
+func (b bootstrapper) Loggable() map[string]interface{} {
+	return logutil.JoinFields(
+		map[string]interface{}{"service": "bootstrap"},
+		b.s.Loggable(),
+	)
+}
+
+// Snapshot satisfies runtime.Snapshotter.
+func (b bootstrapper) Snapshot() interface{} { return b.state.Load() }
+
+func (b *bootstrapper) Start(ctx context.Context) (err error) {
+	if err = internal.WaitNetworkReady(ctx, b.h.EventBus()); err == nil {
+		internal.StartBackground(ctx,
+			b.subloop,
+			b.loop,
+		)
+	}
+
+	return
+}
+
+func (b bootstrapper) Stop(context.Context) error {
+	defer b.cancel()
+
+	return b.sub.Close()
+}
+
+// subloop forwards the latest phase to the main loop, dropping stale values.
+func (b bootstrapper) subloop() {
+	defer close(b.phase)
+
+	for v := range b.sub.Out() {
+		ph := v.(neighborhood.EvtNeighborhoodChanged).To
+
+		select {
+		case b.phase <- ph:
+		case <-b.phase:
+			b.phase <- ph
+		}
+	}
+}
+
+func (b bootstrapper) loop() {
+	defer b.e.Close()
+	defer b.found.Close()
+
+	var (
+		attempt int
+		retry   <-chan time.Time // nil while idle
+		state   Snapshot
+		jitter  = jitterbug.Uniform{Source: rand.New(randutil.FromPeer(b.h.ID()))}
+	)
+
+	for {
+		select {
+		case ph, ok := <-b.phase:
+			if !ok {
+				return
+			}
+
+			switch {
+			case healthy(ph):
+				attempt, retry = 0, nil
+			case retry == nil:
+				retry = time.After(0)
+			}
+
+			state.Active = retry != nil
+			b.state.Store(state)
+
+		case <-retry:
+			attempt++
+
+			ev := b.attempt(attempt)
+			if ev.Succeeded() {
+				attempt = 0
+			}
+
+			b.emit(ev)
+
+			state.Last = &ev
+			b.state.Store(state)
+
+			// Keep trying until the neighborhood reports that it is healthy.
+			d := b.backoff(attempt)
+			jitter.Min = d / 2
+			retry = time.After(jitter.Jitter(d))
+		}
+	}
+}
+
+// backoff returns the delay after the nth consecutive failed attempt.  After a
+// successful attempt (n == 0), the base delay is used.
+func (b bootstrapper) backoff(n int) time.Duration {
+	d := b.base
+	for i := 1; i < n && d < b.ceil; i++ {
+		d *= 2
+	}
+
+	if d > b.ceil {
+		d = b.ceil
+	}
+
+	return d
+}
+
+func (b bootstrapper) attempt(n int) (ev EvtBootstrapAttempt) {
+	ev.Attempt = n
+
+	ctx, cancel := context.WithTimeout(b.ctx, discoverTimeout)
+	defer cancel()
+
+	// The handshake connects to discovered peers, so the peers to which the host was
+	// already connected are recorded beforehand.
+	connected := make(map[peer.ID]struct{})
+	for _, id := range b.h.Network().Peers() {
+		connected[id] = struct{}{}
+	}
+
+	opt := []boot.Option{
+		boot.WithLimit(peersPerAttempt),
+		boot.WithErrorHandler(b.onError),
+	}
+
+	if b.verify {
+		opt = append(opt, boot.WithHandshake(b.h, b.ns))
+	}
+
+	ch, err := b.s.DiscoverPeers(ctx, opt...)
+	if err != nil {
+		ev.Err = err
+		b.log.WithError(err).Debug("error discovering peers")
+		return
+	}
+
+	for info := range ch {
+		if info.ID == b.h.ID() {
+			continue
+		}
+
+		ev.Found++
+		b.discovered(info)
+
+		if _, ok := connected[info.ID]; ok {
+			continue
+		}
+
+		if err = b.connect(info); err != nil {
+			ev.Failed++
+			b.log.WithError(err).Debugf("unable to connect to %s", info.ID)
+			continue
+		}
+
+		ev.Connected++
+	}
+
+	return
+}
+
+func (b bootstrapper) connect(info peer.AddrInfo) error {
+	ctx, cancel := context.WithTimeout(b.ctx, connectTimeout)
+	defer cancel()
+
+	return b.h.Connect(ctx, info)
+}
+
+func (b bootstrapper) onError(err error) {
+	b.log.WithError(err).Error("peer discovery failed")
+}
+
+func (b bootstrapper) emit(ev EvtBootstrapAttempt) {
+	if err := b.e.Emit(ev); err != nil && err != internal.ErrEmitterClosed {
+		b.log.WithError(err).Error("failed to emit EvtBootstrapAttempt")
+	}
+}
+
+func (b bootstrapper) discovered(info peer.AddrInfo) {
+	err := b.found.Emit(boot_service.EvtPeerDiscovered(info))
+	if err != nil && err != internal.ErrEmitterClosed {
+		b.log.WithError(err).Error("failed to emit EvtPeerDiscovered")
+	}
+}
+
+func healthy(ph neighborhood.Phase) bool {
+	return ph >= neighborhood.PhaseComplete
+}
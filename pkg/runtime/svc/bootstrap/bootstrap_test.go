@@ -0,0 +1,150 @@
+package bootstrap_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	eventbus "github.com/libp2p/go-eventbus"
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	mock_boot "github.com/wetware/ww/internal/test/mock/pkg/boot"
+	mock_vendor "github.com/wetware/ww/internal/test/mock/vendor"
+	testutil "github.com/wetware/ww/internal/test/util"
+	logutil "github.com/wetware/ww/internal/util/log"
+	"github.com/wetware/ww/pkg/boot"
+	"github.com/wetware/ww/pkg/internal/p2p"
+	bootstrap_service "github.com/wetware/ww/pkg/runtime/svc/bootstrap"
+	neighborhood_service "github.com/wetware/ww/pkg/runtime/svc/neighborhood"
+)
+
+func TestBootstrapper(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	bus := eventbus.NewBus()
+	info := peer.AddrInfo{ID: testutil.RandID()}
+
+	// the first two dials fail, and subsequent dials succeed
+	var dials int32
+	h := mock_vendor.NewMockHost(ctrl)
+	h.EXPECT().EventBus().Return(bus).AnyTimes()
+	h.EXPECT().ID().Return(testutil.RandID()).AnyTimes()
+	h.EXPECT().Network().Return(disconnected{}).AnyTimes()
+	h.EXPECT().
+		Connect(gomock.Any(), info).
+		DoAndReturn(func(context.Context, peer.AddrInfo) error {
+			if atomic.AddInt32(&dials, 1) <= 2 {
+				return errors.New("connection refused")
+			}
+			return nil
+		}).
+		AnyTimes()
+
+	s := mock_boot.NewMockStrategy(ctrl)
+	s.EXPECT().Loggable().Return(map[string]interface{}{}).AnyTimes()
+	s.EXPECT().
+		DiscoverPeers(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(context.Context, ...boot.Option) (<-chan peer.AddrInfo, error) {
+			ch := make(chan peer.AddrInfo, 1)
+			ch <- info
+			close(ch)
+			return ch, nil
+		}).
+		AnyTimes()
+
+	b, err := bootstrap_service.New(bootstrap_service.Config{
+		Log:        logutil.Nop(),
+		Host:       h,
+		Strategy:   s,
+		Backoff:    time.Millisecond * 10,
+		MaxBackoff: time.Millisecond * 40,
+	}).Factory.NewService()
+	require.NoError(t, err)
+
+	sub, err := bus.Subscribe(new(bootstrap_service.EvtBootstrapAttempt))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	e, err := bus.Emitter(new(neighborhood_service.EvtNeighborhoodChanged))
+	require.NoError(t, err)
+	defer e.Close()
+
+	require.NoError(t, netReady(bus))
+	require.NoError(t, b.Start(ctx))
+	defer func() {
+		require.NoError(t, b.Stop(ctx))
+	}()
+
+	t.Run("Orphaned", func(t *testing.T) {
+		require.NoError(t, e.Emit(neighborhood_service.EvtNeighborhoodChanged{
+			To: neighborhood_service.PhaseOrphaned,
+		}))
+
+		for _, want := range []bootstrap_service.EvtBootstrapAttempt{
+			{Attempt: 1, Found: 1, Failed: 1},
+			{Attempt: 2, Found: 1, Failed: 1},
+			{Attempt: 3, Found: 1, Connected: 1},
+			{Attempt: 1, Found: 1, Connected: 1}, // backoff was reset
+		} {
+			assert.Equal(t, want, next(ctx, t, sub))
+		}
+	})
+
+	t.Run("Complete", func(t *testing.T) {
+		require.NoError(t, e.Emit(neighborhood_service.EvtNeighborhoodChanged{
+			From: neighborhood_service.PhasePartial,
+			To:   neighborhood_service.PhaseComplete,
+		}))
+
+		// drain any attempt that was in flight
+		time.Sleep(time.Millisecond * 100)
+		for len(sub.Out()) > 0 {
+			<-sub.Out()
+		}
+
+		select {
+		case v := <-sub.Out():
+			t.Errorf("attempted to bootstrap while complete:  %v", v)
+		case <-time.After(time.Millisecond * 100):
+		}
+	})
+}
+
+func next(ctx context.Context, t *testing.T, sub event.Subscription) bootstrap_service.EvtBootstrapAttempt {
+	select {
+	case v := <-sub.Out():
+		return v.(bootstrap_service.EvtBootstrapAttempt)
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+		return bootstrap_service.EvtBootstrapAttempt{}
+	}
+}
+
+// disconnected is a network.Network that is not connected to any peer.
+type disconnected struct{ network.Network }
+
+func (disconnected) Peers() []peer.ID { return nil }
+
+// netReady emits p2p.EvtNetworkReady
+func netReady(bus event.Bus) error {
+	e, err := bus.Emitter(new(p2p.EvtNetworkReady), eventbus.Stateful)
+	if err != nil {
+		return err
+	}
+
+	return e.Emit(p2p.EvtNetworkReady{})
+}
@@ -0,0 +1,404 @@
+// Package crawler implements a service that learns about the members of the cluster by
+// exchanging peer lists with its neighbors.
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/fx"
+	"go.uber.org/multierr"
+
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/pkg/errors"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/boot"
+	"github.com/wetware/ww/pkg/runtime"
+	boot_service "github.com/wetware/ww/pkg/runtime/svc/boot"
+	"github.com/wetware/ww/pkg/runtime/svc/internal"
+	"github.com/wetware/ww/pkg/runtime/svc/neighborhood"
+	randutil "github.com/wetware/ww/pkg/util/rand"
+)
+
+const (
+	// Protocol for peer exchange.  The requester writes a Request, and the responder
+	// replies with a Response and closes the stream.
+	Protocol = protocol.ID("/ww/px")
+
+	// MaxPeers is the maximum number of peers in a Response.
+	MaxPeers = 32
+
+	// MaxAddrs is the maximum number of addresses per peer in a Response.  Peers with
+	// more addresses are discarded.
+	MaxAddrs = 8
+
+	// DefaultInterval is the default period between crawls while the neighborhood is
+	// partial.  Crawls are LazyFactor times less frequent while it is complete, and
+	// suspended while it is overloaded.
+	DefaultInterval = time.Second * 10
+
+	// LazyFactor by which the interval is multiplied while the neighborhood is
+	// complete.
+	LazyFactor = 6
+
+	// DefaultFanout is the default number of neighbors queried per crawl.
+	DefaultFanout = 3
+
+	maxRequestSize  = 64
+	maxResponseSize = 64 * 1024
+	timeout         = time.Second * 5
+)
+
+// Request for a neighbor's peers.
+type Request struct {
+	// Limit on the number of peers returned.  It is capped at MaxPeers.
+	Limit int `json:"limit"`
+}
+
+// Response to a Request.
+type Response struct {
+	Peers []peer.AddrInfo `json:"peers"`
+}
+
+// Config for Crawler service.
+type Config struct {
+	fx.In
+
+	Log      ww.Logger `optional:"true"`
+	Host     host.Host
+	Strategy boot.Strategy `optional:"true"`
+
+	// Interval and Fanout default to DefaultInterval and DefaultFanout.
+	Interval time.Duration `name:"px_interval" optional:"true"`
+	Fanout   int           `name:"px_fanout" optional:"true"`
+}
+
+// NewService satisfies runtime.ServiceFactory
+func (cfg Config) NewService() (_ runtime.Service, err error) {
+	c := &crawler{
+		h:        cfg.Host,
+		interval: cfg.Interval,
+		fanout:   cfg.Fanout,
+		known:    make(map[peer.ID]struct{}),
+		rand:     rand.New(randutil.FromPeer(cfg.Host.ID())),
+		cq:       make(chan struct{}),
+	}
+
+	if c.interval == 0 {
+		c.interval = DefaultInterval
+	}
+
+	if c.fanout == 0 {
+		c.fanout = DefaultFanout
+	}
+
+	// learned peers are remembered by the boot cache, if there is one
+	if m, ok := cfg.Strategy.(marker); ok {
+		c.cache = m
+	}
+
+	if c.sub, err = cfg.Host.EventBus().Subscribe(new(neighborhood.EvtNeighborhoodChanged)); err != nil {
+		return
+	}
+
+	if c.e, err = internal.NewEmitter(cfg.Host.EventBus(), new(boot_service.EvtPeerDiscovered)); err != nil {
+		return
+	}
+
+	c.log = internal.Logger(cfg.Log, c)
+
+	return c, nil
+}
+
+// Produces boot.EvtPeerDiscovered.
+func (cfg Config) Produces() []interface{} {
+	return []interface{}{
+		boot_service.EvtPeerDiscovered{},
+	}
+}
+
+// Consumes neighborhood.EvtNeighborhoodChanged.
+func (cfg Config) Consumes() []interface{} {
+	return []interface{}{
+		neighborhood.EvtNeighborhoodChanged{},
+	}
+}
+
+// Module for Crawler service.
+type Module struct {
+	fx.Out
+
+	Factory runtime.ServiceFactory `group:"runtime"`
+}
+
+// New Crawler service.  The service periodically asks Fanout random neighbors for
+// their peers over Protocol, and reports each peer that it did not already know as a
+// boot.EvtPeerDiscovered, so that it becomes a candidate for graph repair.  Learned
+// peers are also marked in the boot cache, if the boot strategy is a *boot.Cache.
+//
+// Crawls occur every Interval while the neighborhood is orphaned or partial, every
+// Interval*LazyFactor while it is complete, and not at all while it is overloaded.
+// The service answers the queries of other hosts with its own neighbors.
+//
+// Consumes:
+//   - neighborhood.EvtNeighborhoodChanged
+//
+// Emits:
+//   - boot.EvtPeerDiscovered
+func New(cfg Config) Module { return Module{Factory: cfg} }
+
+type marker interface {
+	Mark(peer.AddrInfo) error
+}
+
+type crawler struct {
+	log ww.Logger
+	h   host.Host
+
+	interval time.Duration
+	fanout   int
+
+	cache marker               // nil if there is no boot cache
+	known map[peer.ID]struct{} // peers already reported
+	rand  *rand.Rand
+
+	sub event.Subscription
+	e   *internal.Emitter
+	cq  chan struct{}
+}
+
+func (c *crawler) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"service":     "crawler",
+		"px_interval": c.interval,
+		"px_fanout":   c.fanout,
+	}
+}
+
+func (c *crawler) Start(ctx context.Context) (err error) {
+	if err = internal.WaitNetworkReady(ctx, c.h.EventBus()); err == nil {
+		c.h.SetStreamHandler(Protocol, c.handle)
+		internal.StartBackground(ctx, c.loop)
+	}
+
+	return
+}
+
+func (c *crawler) Stop(context.Context) error {
+	c.h.RemoveStreamHandler(Protocol)
+	close(c.cq)
+
+	return multierr.Combine(
+		c.sub.Close(),
+		c.e.Close(),
+	)
+}
+
+func (c *crawler) loop() {
+	var (
+		timer = time.NewTimer(c.interval)
+		phase neighborhood.Phase
+	)
+	defer timer.Stop()
+
+	for {
+		select {
+		case v, ok := <-c.sub.Out():
+			if !ok {
+				return
+			}
+
+			// reschedule only when the crawl rate changes
+			next := v.(neighborhood.EvtNeighborhoodChanged).To
+			if c.period(next) == c.period(phase) {
+				phase = next
+				continue
+			}
+
+			phase = next
+
+		case <-timer.C:
+			c.crawl()
+
+		case <-c.cq:
+			return
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+
+		if d := c.period(phase); d > 0 {
+			timer.Reset(d)
+		}
+	}
+}
+
+// period between crawls in the given phase, or zero if crawling is suspended.
+func (c *crawler) period(p neighborhood.Phase) time.Duration {
+	switch p {
+	case neighborhood.PhaseComplete:
+		return c.interval * LazyFactor
+	case neighborhood.PhaseOverloaded:
+		return 0
+	default:
+		return c.interval
+	}
+}
+
+// crawl queries a random sample of neighbors, and reports the peers they return.
+func (c *crawler) crawl() {
+	ps := c.h.Network().Peers()
+	c.rand.Shuffle(len(ps), func(i, j int) { ps[i], ps[j] = ps[j], ps[i] })
+	if len(ps) > c.fanout {
+		ps = ps[:c.fanout]
+	}
+
+	rs := make([][]peer.AddrInfo, len(ps))
+
+	var wg sync.WaitGroup
+	wg.Add(len(ps))
+	for i, id := range ps {
+		go func(i int, id peer.ID) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			var err error
+			if rs[i], err = Query(ctx, c.h, id, MaxPeers); err != nil {
+				c.log.WithError(err).Debugf("peer exchange with %s failed", id)
+			}
+		}(i, id)
+	}
+	wg.Wait()
+
+	for _, infos := range rs {
+		for _, info := range infos {
+			c.learn(info)
+		}
+	}
+}
+
+// learn about a peer, reporting it unless it is the local host, a neighbor or was
+// already reported.
+func (c *crawler) learn(info peer.AddrInfo) {
+	if info.ID == c.h.ID() || c.h.Network().Connectedness(info.ID) == network.Connected {
+		return
+	}
+
+	if _, ok := c.known[info.ID]; ok {
+		return
+	}
+	c.known[info.ID] = struct{}{}
+
+	if c.cache != nil {
+		if err := c.cache.Mark(info); err != nil {
+			c.log.WithError(err).Debug("failed to cache peer")
+		}
+	}
+
+	if err := c.e.Emit(boot_service.EvtPeerDiscovered(info)); err != nil && err != internal.ErrEmitterClosed {
+		c.log.WithError(err).Error("failed to emit EvtPeerDiscovered")
+	}
+}
+
+// handle a peer exchange request by replying with a random sample of neighbors,
+// excluding the requester.
+func (c *crawler) handle(s network.Stream) {
+	defer s.Close()
+
+	// best effort; not all transports support deadlines
+	_ = s.SetDeadline(time.Now().Add(timeout))
+
+	var req Request
+	if err := json.NewDecoder(io.LimitReader(s, maxRequestSize)).Decode(&req); err != nil {
+		s.Reset()
+		return
+	}
+
+	if req.Limit <= 0 || req.Limit > MaxPeers {
+		req.Limit = MaxPeers
+	}
+
+	ps := c.h.Network().Peers()
+	rand.Shuffle(len(ps), func(i, j int) { ps[i], ps[j] = ps[j], ps[i] })
+
+	res := Response{Peers: make([]peer.AddrInfo, 0, req.Limit)}
+	for _, id := range ps {
+		if len(res.Peers) == req.Limit {
+			break
+		}
+
+		if id == s.Conn().RemotePeer() {
+			continue
+		}
+
+		if info := c.h.Peerstore().PeerInfo(id); valid(info) {
+			res.Peers = append(res.Peers, info)
+		}
+	}
+
+	if err := json.NewEncoder(s).Encode(res); err != nil {
+		s.Reset()
+	}
+}
+
+// Query the peer for at most limit of its neighbors.  Records with no addresses or
+// more than MaxAddrs addresses are discarded, and an error is returned if the peer
+// replies with more than limit records.
+func Query(ctx context.Context, h host.Host, id peer.ID, limit int) ([]peer.AddrInfo, error) {
+	if limit <= 0 || limit > MaxPeers {
+		limit = MaxPeers
+	}
+
+	s, err := h.NewStream(ctx, id, Protocol)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = s.SetDeadline(deadline)
+	}
+
+	if err = json.NewEncoder(s).Encode(Request{Limit: limit}); err != nil {
+		s.Reset()
+		return nil, err
+	}
+
+	var res Response
+	if err = json.NewDecoder(io.LimitReader(s, maxResponseSize)).Decode(&res); err != nil {
+		s.Reset()
+		return nil, err
+	}
+
+	if len(res.Peers) > limit {
+		return nil, errors.Errorf("%d peers exceed limit of %d", len(res.Peers), limit)
+	}
+
+	infos := res.Peers[:0]
+	for _, info := range res.Peers {
+		if valid(info) {
+			infos = append(infos, info)
+		}
+	}
+
+	return infos, nil
+}
+
+func valid(info peer.AddrInfo) bool {
+	return info.ID.Validate() == nil && len(info.Addrs) > 0 && len(info.Addrs) <= MaxAddrs
+}
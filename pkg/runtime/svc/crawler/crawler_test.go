@@ -0,0 +1,131 @@
+package crawler_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	eventbus "github.com/libp2p/go-eventbus"
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+
+	"github.com/wetware/ww/pkg/boot"
+	"github.com/wetware/ww/pkg/internal/p2p"
+	"github.com/wetware/ww/pkg/runtime"
+	boot_service "github.com/wetware/ww/pkg/runtime/svc/boot"
+	crawler_service "github.com/wetware/ww/pkg/runtime/svc/crawler"
+)
+
+func TestCrawlerLoggable(t *testing.T) {
+	t.Parallel()
+
+	h, err := mocknet.New(context.Background()).GenPeer()
+	require.NoError(t, err)
+
+	c, err := crawler_service.New(crawler_service.Config{Host: h}).Factory.NewService()
+	require.NoError(t, err)
+
+	assert.Equal(t, "crawler", c.Loggable()["service"])
+	assert.Equal(t, crawler_service.DefaultInterval, c.Loggable()["px_interval"])
+	assert.Equal(t, crawler_service.DefaultFanout, c.Loggable()["px_fanout"])
+}
+
+func TestCrawler(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	mn, err := mocknet.FullMeshLinked(ctx, 4)
+	require.NoError(t, err)
+
+	// h is connected to neighbor, which is connected to the rest of the cluster
+	hs := mn.Hosts()
+	h, neighbor, rest := hs[0], hs[1], hs[2:]
+	for _, p := range append(rest, h) {
+		_, err = mn.ConnectPeers(neighbor.ID(), p.ID())
+		require.NoError(t, err)
+	}
+
+	cache := boot.NewCache(filepath.Join(t.TempDir(), "peers.json"), nil)
+
+	sub, err := h.EventBus().Subscribe(new(boot_service.EvtPeerDiscovered))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	for _, c := range []struct {
+		h     host.Host
+		cache boot.Strategy
+	}{{h, cache}, {neighbor, nil}} {
+		svc := start(ctx, t, crawler_service.Config{
+			Host:     c.h,
+			Strategy: c.cache,
+			Interval: time.Millisecond * 10,
+		})
+		defer func() {
+			require.NoError(t, svc.Stop(ctx))
+		}()
+	}
+
+	learned := make(map[peer.ID]peer.AddrInfo)
+	for len(learned) < len(rest) {
+		select {
+		case v := <-sub.Out():
+			info := peer.AddrInfo(v.(boot_service.EvtPeerDiscovered))
+			assert.NotContains(t, learned, info.ID, "peers should be reported once")
+			assert.NotEqual(t, h.ID(), info.ID, "local host should not be reported")
+			assert.NotEqual(t, neighbor.ID(), info.ID, "neighbors should not be reported")
+			assert.NotEmpty(t, info.Addrs)
+			learned[info.ID] = info
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		}
+	}
+
+	for _, p := range rest {
+		assert.Contains(t, learned, p.ID())
+	}
+
+	ch, err := cache.DiscoverPeers(ctx, boot.WithLimit(len(rest)))
+	require.NoError(t, err)
+
+	var cached []peer.ID
+	for info := range ch {
+		cached = append(cached, info.ID)
+	}
+	assert.ElementsMatch(t, []peer.ID{rest[0].ID(), rest[1].ID()}, cached,
+		"learned peers should be cached")
+
+	t.Run("Limit", func(t *testing.T) {
+		infos, err := crawler_service.Query(ctx, h, neighbor.ID(), 1)
+		require.NoError(t, err)
+		require.Len(t, infos, 1)
+		assert.NotEqual(t, h.ID(), infos[0].ID, "requester should be excluded")
+	})
+}
+
+func start(ctx context.Context, t *testing.T, cfg crawler_service.Config) runtime.Service {
+	svc, err := crawler_service.New(cfg).Factory.NewService()
+	require.NoError(t, err)
+
+	require.NoError(t, netReady(cfg.Host.EventBus()))
+	require.NoError(t, svc.Start(ctx))
+
+	return svc
+}
+
+// netReady emits p2p.EvtNetworkReady
+func netReady(bus event.Bus) error {
+	e, err := bus.Emitter(new(p2p.EvtNetworkReady), eventbus.Stateful)
+	if err != nil {
+		return err
+	}
+
+	return e.Emit(p2p.EvtNetworkReady{})
+}
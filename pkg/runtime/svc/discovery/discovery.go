@@ -17,6 +17,7 @@ import (
 	"github.com/wetware/ww/pkg/runtime/svc/ticker"
 	randutil "github.com/wetware/ww/pkg/util/rand"
 	"go.uber.org/fx"
+	"go.uber.org/multierr"
 )
 
 // TODO(config): parametrize (?)
@@ -26,7 +27,7 @@ const adTTL = time.Hour * 2
 type Config struct {
 	fx.In
 
-	Log       ww.Logger
+	Log       ww.Logger `optional:"true"`
 	Host      host.Host
 	Namespace string `name:"ns"`
 	Discovery discovery.Discovery
@@ -37,7 +38,6 @@ func (cfg Config) NewService() (_ runtime.Service, err error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	d := discoverer{
-		log:    cfg.Log,
 		h:      cfg.Host,
 		ns:     cfg.Namespace,
 		d:      cfg.Discovery,
@@ -54,10 +54,12 @@ func (cfg Config) NewService() (_ runtime.Service, err error) {
 		return
 	}
 
-	if d.e, err = cfg.Host.EventBus().Emitter(new(boot.EvtPeerDiscovered)); err != nil {
+	if d.e, err = internal.NewEmitter(cfg.Host.EventBus(), new(boot.EvtPeerDiscovered)); err != nil {
 		return
 	}
 
+	d.log = internal.Logger(cfg.Log, d)
+
 	return d, nil
 }
 
@@ -85,11 +87,11 @@ type Module struct {
 // New Discovery service.  Queries the graph for peers.
 //
 // Consumes:
-//  - EvtTimestep
-//  - EvtGraftRequested
+//   - EvtTimestep
+//   - EvtGraftRequested
 //
 // Emits:
-//  - EvtPeerDiscovered
+//   - EvtPeerDiscovered
 func New(cfg Config) Module { return Module{Factory: cfg} }
 
 type discoverer struct {
@@ -116,7 +118,7 @@ func (d discoverer) Loggable() map[string]interface{} {
 
 func (d discoverer) Start(ctx context.Context) (err error) {
 	if err = internal.WaitNetworkReady(ctx, d.h.EventBus()); err == nil {
-		internal.StartBackground(
+		internal.StartBackground(ctx,
 			d.adloop,
 			d.graftloop,
 			d.subloop,
@@ -131,7 +133,11 @@ func (d discoverer) Start(ctx context.Context) (err error) {
 
 func (d discoverer) Stop(ctx context.Context) error {
 	d.cancel()
-	return d.sub.Close()
+
+	return multierr.Combine(
+		d.sub.Close(),
+		d.e.Close(),
+	)
 }
 
 func (d discoverer) subloop() {
@@ -171,7 +177,7 @@ func (d discoverer) adloop() {
 		defer cancel()
 
 		if _, err := d.d.Advertise(ctx, d.ns, discovery.TTL(adTTL)); err != nil {
-			d.log.With(d).WithError(err).Warn("failed to advertise")
+			d.log.WithError(err).Warn("failed to advertise")
 		}
 	}
 }
@@ -184,7 +190,7 @@ func (d discoverer) graftloop() {
 		// TODO(performance):  investigate ideal limit & consider making it dynamic.
 		ch, err := d.d.FindPeers(ctx, d.ns, discovery.Limit(3))
 		if err != nil {
-			d.log.With(d).WithError(err).Debug("error finding peers")
+			d.log.WithError(err).Debug("error finding peers")
 		}
 
 		for info := range ch {
@@ -192,8 +198,8 @@ func (d discoverer) graftloop() {
 				continue
 			}
 
-			if err = d.e.Emit(boot.EvtPeerDiscovered(info)); err != nil {
-				d.log.With(d).WithError(err).Error("failed to emit EvtPeerDiscovered")
+			if err = d.e.Emit(boot.EvtPeerDiscovered(info)); err != nil && err != internal.ErrEmitterClosed {
+				d.log.WithError(err).Error("failed to emit EvtPeerDiscovered")
 			}
 		}
 	}
@@ -8,7 +8,6 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	mock_ww "github.com/wetware/ww/internal/test/mock/pkg"
 	mock_vendor "github.com/wetware/ww/internal/test/mock/vendor"
 	testutil "github.com/wetware/ww/internal/test/util"
 
@@ -26,12 +25,10 @@ func TestDiscoveryLogFields(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	logger := mock_ww.NewMockLogger(ctrl)
 	bus := eventbus.NewBus()
 	h := newMockHost(ctrl, bus)
 
 	d, err := discovery_service.New(discovery_service.Config{
-		Log:       logger,
 		Host:      h,
 		Namespace: ns,
 		Discovery: nil,
@@ -52,14 +49,12 @@ func TestDiscovery(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 
-	logger := mock_ww.NewMockLogger(ctrl)
 	bus := eventbus.NewBus()
 	h := newMockHost(ctrl, bus)
 
 	dy := mock_vendor.NewMockDiscovery(ctrl)
 
 	d, err := discovery_service.New(discovery_service.Config{
-		Log:       logger,
 		Host:      h,
 		Namespace: ns,
 		Discovery: dy,
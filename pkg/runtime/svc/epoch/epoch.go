@@ -6,6 +6,7 @@ import (
 	"github.com/libp2p/go-libp2p-core/event"
 	"go.uber.org/fx"
 
+	ww "github.com/wetware/ww/pkg"
 	"github.com/wetware/ww/pkg/cluster"
 	"github.com/wetware/ww/pkg/runtime"
 	"github.com/wetware/ww/pkg/runtime/svc/internal"
@@ -16,6 +17,7 @@ import (
 type Config struct {
 	fx.In
 
+	Log   ww.Logger `optional:"true"`
 	Bus   event.Bus
 	Clock cluster.Clock
 }
@@ -27,11 +29,14 @@ func (cfg Config) NewService() (runtime.Service, error) {
 		return nil, err
 	}
 
-	return &router{
+	r := &router{
 		epoch: cfg.Clock,
 		bus:   cfg.Bus,
 		ts:    sub,
-	}, nil
+	}
+	r.log = internal.Logger(cfg.Log, r)
+
+	return r, nil
 }
 
 // Consumes ticker.EvtTimestep.
@@ -52,6 +57,7 @@ type Module struct {
 func New(cfg Config) Module { return Module{Factory: cfg} }
 
 type router struct {
+	log   ww.Logger
 	epoch cluster.Clock
 
 	bus event.Bus
@@ -66,7 +72,7 @@ func (r router) Loggable() map[string]interface{} {
 
 func (r *router) Start(ctx context.Context) (err error) {
 	if err = internal.WaitNetworkReady(ctx, r.bus); err == nil {
-		go r.tickloop()
+		internal.StartBackground(ctx, r.tickloop)
 	}
 
 	return
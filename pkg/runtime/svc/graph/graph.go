@@ -7,11 +7,13 @@ import (
 
 	"github.com/libp2p/go-libp2p-core/event"
 	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/lthibault/jitterbug"
 	ww "github.com/wetware/ww/pkg"
 	"github.com/wetware/ww/pkg/runtime"
 	"github.com/wetware/ww/pkg/runtime/svc/internal"
 	"github.com/wetware/ww/pkg/runtime/svc/neighborhood"
+	"github.com/wetware/ww/pkg/runtime/svc/streams"
 	"github.com/wetware/ww/pkg/runtime/svc/ticker"
 	randutil "github.com/wetware/ww/pkg/util/rand"
 	"go.uber.org/fx"
@@ -30,23 +32,26 @@ type (
 	EvtGraftRequested struct{}
 
 	// EvtPruneRequested is emitted by the graph service when the local node's
-	// connectivity phase is "Overloaded".  It signals that the local node should
-	// attempt to terminate connections to remote hosts.
-	EvtPruneRequested struct{}
+	// connectivity phase is "Overloaded", or when a remote host is flagged as a slow
+	// consumer.  It signals that the local node should attempt to terminate
+	// connections to remote hosts.
+	EvtPruneRequested struct {
+		// Peers, if non-empty, are the preferred candidates for pruning.
+		Peers []peer.ID
+	}
 )
 
 // Config for Graph service.
 type Config struct {
 	fx.In
 
-	Log  ww.Logger
+	Log  ww.Logger `optional:"true"`
 	Host host.Host
 }
 
 // NewService satisfies runtime.ServiceFactory.
 func (cfg Config) NewService() (_ runtime.Service, err error) {
 	g := graph{
-		log:       cfg.Log,
 		bus:       cfg.Host.EventBus(),
 		src:       randutil.FromPeer(cfg.Host.ID()),
 		cq:        make(chan struct{}),
@@ -61,18 +66,24 @@ func (cfg Config) NewService() (_ runtime.Service, err error) {
 		return
 	}
 
-	if g.boot, err = g.bus.Emitter(new(EvtBootRequested)); err != nil {
+	if g.slow, err = g.bus.Subscribe(new(streams.EvtSlowConsumer)); err != nil {
+		return
+	}
+
+	if g.boot, err = internal.NewEmitter(g.bus, new(EvtBootRequested)); err != nil {
 		return
 	}
 
-	if g.graft, err = g.bus.Emitter(new(EvtGraftRequested)); err != nil {
+	if g.graft, err = internal.NewEmitter(g.bus, new(EvtGraftRequested)); err != nil {
 		return
 	}
 
-	if g.prune, err = g.bus.Emitter(new(EvtPruneRequested)); err != nil {
+	if g.prune, err = internal.NewEmitter(g.bus, new(EvtPruneRequested)); err != nil {
 		return
 	}
 
+	g.log = internal.Logger(cfg.Log, g)
+
 	return g, nil
 }
 
@@ -85,11 +96,26 @@ func (cfg Config) Produces() []interface{} {
 	}
 }
 
-// Consumes ticker.EvtTimestep & neighborhood.EvtNeighborhoodChanged.
+// Public EvtBootRequested, EvtGraftRequested & EvtPruneRequested.  Requests that the
+// runtime does not act upon, e.g. booting on hosts or pruning on clients, are left to
+// the application.
+func (cfg Config) Public() []interface{} { return cfg.Produces() }
+
+// Consumes ticker.EvtTimestep, neighborhood.EvtNeighborhoodChanged &
+// streams.EvtSlowConsumer.
 func (cfg Config) Consumes() []interface{} {
 	return []interface{}{
 		ticker.EvtTimestep{},
 		neighborhood.EvtNeighborhoodChanged{},
+		streams.EvtSlowConsumer{},
+	}
+}
+
+// External streams.EvtSlowConsumer, which is optional, since only hosts run the
+// streams service.
+func (cfg Config) External() []interface{} {
+	return []interface{}{
+		streams.EvtSlowConsumer{},
 	}
 }
 
@@ -104,12 +130,13 @@ type Module struct {
 // bounds.
 //
 // Consumes:
-//  - EvtNeighborhoodChanged
+//   - EvtNeighborhoodChanged
+//   - EvtSlowConsumer (if available)
 //
 // Emits:
-//	- EvtBootRequested
-//  - EvtGraftRequested
-//  - EvtPruneRequested
+//   - EvtBootRequested
+//   - EvtGraftRequested
+//   - EvtPruneRequested
 func New(cfg Config) Module { return Module{Factory: cfg} }
 
 type graph struct {
@@ -120,7 +147,7 @@ type graph struct {
 	neighbors chan neighborhood.EvtNeighborhoodChanged
 
 	bus                event.Bus
-	tstep, nhood       event.Subscription
+	tstep, nhood, slow event.Subscription
 	boot, graft, prune event.Emitter
 }
 
@@ -132,7 +159,7 @@ func (g graph) Loggable() map[string]interface{} {
 
 func (g graph) Start(ctx context.Context) (err error) {
 	if err = internal.WaitNetworkReady(ctx, g.bus); err == nil {
-		internal.StartBackground(
+		internal.StartBackground(ctx,
 			g.subloop,
 			g.emitloop,
 		)
@@ -146,6 +173,7 @@ func (g graph) Stop(context.Context) error {
 
 	return multierr.Combine(
 		g.nhood.Close(),
+		g.slow.Close(),
 		g.boot.Close(),
 		g.graft.Close(),
 		g.prune.Close(),
@@ -180,6 +208,13 @@ func (g graph) subloop() {
 			}
 
 			ev = v.(neighborhood.EvtNeighborhoodChanged)
+		case v, ok := <-g.slow.Out():
+			if !ok {
+				return
+			}
+
+			g.pruneSlow(v.(streams.EvtSlowConsumer))
+			continue
 		case <-g.cq:
 			return
 		}
@@ -194,22 +229,41 @@ func (g graph) subloop() {
 
 func (g graph) emitloop() {
 	for ev := range g.neighbors {
+		select {
+		case <-g.cq:
+			return
+		default:
+		}
+
 		switch ev.To {
 		case neighborhood.PhaseOrphaned:
-			if err := g.boot.Emit(EvtBootRequested{}); err != nil {
-				g.log.With(g).WithError(err).Warn("failed to emit EvtBootRequested")
+			if err := g.boot.Emit(EvtBootRequested{}); err != nil && err != internal.ErrEmitterClosed {
+				g.log.WithError(err).Warn("failed to emit EvtBootRequested")
 			}
 
 		case neighborhood.PhasePartial:
-			if err := g.graft.Emit(EvtGraftRequested{}); err != nil {
-				g.log.With(g).WithError(err).Warn("failed to emit EvtGraftRequested")
+			if err := g.graft.Emit(EvtGraftRequested{}); err != nil && err != internal.ErrEmitterClosed {
+				g.log.WithError(err).Warn("failed to emit EvtGraftRequested")
 			}
 
 		case neighborhood.PhaseOverloaded:
-			if err := g.prune.Emit(EvtPruneRequested{}); err != nil {
-				g.log.With(g).WithError(err).Warn("failed to emit EvtPruneRequested")
+			if err := g.prune.Emit(EvtPruneRequested{}); err != nil && err != internal.ErrEmitterClosed {
+				g.log.WithError(err).Warn("failed to emit EvtPruneRequested")
 			}
 
 		}
 	}
 }
+
+// pruneSlow requests that the connection to a slow consumer be pruned.  Streams
+// that were already reset by the streams service are not reported.
+func (g graph) pruneSlow(ev streams.EvtSlowConsumer) {
+	if ev.Closed {
+		return
+	}
+
+	err := g.prune.Emit(EvtPruneRequested{Peers: []peer.ID{ev.Peer}})
+	if err != nil && err != internal.ErrEmitterClosed {
+		g.log.WithError(err).Warn("failed to emit EvtPruneRequested")
+	}
+}
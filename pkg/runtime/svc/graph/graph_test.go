@@ -8,15 +8,16 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	mock_ww "github.com/wetware/ww/internal/test/mock/pkg"
 	mock_vendor "github.com/wetware/ww/internal/test/mock/vendor"
 	testutil "github.com/wetware/ww/internal/test/util"
 
 	eventbus "github.com/libp2p/go-eventbus"
 	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/wetware/ww/pkg/internal/p2p"
 	graph_service "github.com/wetware/ww/pkg/runtime/svc/graph"
 	neighborhood_service "github.com/wetware/ww/pkg/runtime/svc/neighborhood"
+	streams_service "github.com/wetware/ww/pkg/runtime/svc/streams"
 )
 
 func TestGraphLogFields(t *testing.T) {
@@ -25,12 +26,10 @@ func TestGraphLogFields(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	logger := mock_ww.NewMockLogger(ctrl)
 	bus := eventbus.NewBus()
 	h := newMockHost(ctrl, bus)
 
 	g, err := graph_service.New(graph_service.Config{
-		Log:  logger,
 		Host: h,
 	}).Factory.NewService()
 	require.NoError(t, err)
@@ -48,12 +47,10 @@ func TestGraph(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 
-	logger := mock_ww.NewMockLogger(ctrl)
 	bus := eventbus.NewBus()
 	h := newMockHost(ctrl, bus)
 
 	g, err := graph_service.New(graph_service.Config{
-		Log:  logger,
 		Host: h,
 	}).Factory.NewService()
 	require.NoError(t, err)
@@ -116,6 +113,25 @@ func TestGraph(t *testing.T) {
 		}
 	})
 
+	t.Run("SlowConsumer", func(t *testing.T) {
+		prune, err := bus.Subscribe(new(graph_service.EvtPruneRequested))
+		require.NoError(t, err)
+		defer prune.Close()
+
+		slow, err := bus.Emitter(new(streams_service.EvtSlowConsumer))
+		require.NoError(t, err)
+		defer slow.Close()
+
+		id := testutil.RandID()
+		require.NoError(t, slow.Emit(streams_service.EvtSlowConsumer{Peer: id}))
+
+		select {
+		case v := <-prune.Out():
+			assert.Equal(t, []peer.ID{id}, v.(graph_service.EvtPruneRequested).Peers)
+		case <-ctx.Done():
+			t.Error(ctx.Err())
+		}
+	})
 }
 
 func newMockHost(ctrl *gomock.Controller, bus event.Bus) *mock_vendor.MockHost {
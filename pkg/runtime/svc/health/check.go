@@ -0,0 +1,156 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/event"
+
+	neighborhood_service "github.com/wetware/ww/pkg/runtime/svc/neighborhood"
+)
+
+// Checker is a readiness check.  Modules add readiness criteria to the host by
+// providing a Checker to the "readiness" fx group, e.g.:
+//
+//	type Module struct {
+//		fx.Out
+//
+//		Factory runtime.ServiceFactory `group:"runtime"`
+//		Check   health.Checker         `group:"readiness"`
+//	}
+type Checker interface {
+	// Name of the check, as reported in the response body.
+	Name() string
+
+	// Check returns nil if the check passed.  It SHOULD return promptly when the
+	// context expires.
+	Check(context.Context) error
+}
+
+// CheckFunc returns a Checker that calls f.
+func CheckFunc(name string, f func(context.Context) error) Checker {
+	return checkFunc{name: name, f: f}
+}
+
+type checkFunc struct {
+	name string
+	f    func(context.Context) error
+}
+
+func (c checkFunc) Name() string                    { return c.name }
+func (c checkFunc) Check(ctx context.Context) error { return c.f(ctx) }
+
+// Result of a check.
+type Result struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Report is the response body of the /healthz and /readyz endpoints.
+type Report struct {
+	OK     bool     `json:"ok"`
+	Checks []Result `json:"checks"`
+}
+
+// run the checks concurrently.  Checks that have not returned when the context expires
+// are reported as failed.
+func run(ctx context.Context, cs []Checker) Report {
+	var (
+		wg sync.WaitGroup
+		r  = Report{OK: true, Checks: make([]Result, len(cs))}
+	)
+
+	wg.Add(len(cs))
+	for i, c := range cs {
+		go func(i int, c Checker) {
+			defer wg.Done()
+			r.Checks[i] = check(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	for _, res := range r.Checks {
+		r.OK = r.OK && res.OK
+	}
+
+	return r
+}
+
+func check(ctx context.Context, c Checker) Result {
+	var (
+		t0    = time.Now()
+		cherr = make(chan error, 1)
+	)
+
+	go func() { cherr <- c.Check(ctx) }()
+
+	var err error
+	select {
+	case err = <-cherr:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := Result{Name: c.Name(), OK: err == nil, Latency: time.Since(t0).String()}
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	return res
+}
+
+// state of the host, as observed from the event bus.
+type state struct {
+	mu      sync.RWMutex
+	network bool
+	phase   neighborhood_service.Phase
+	started bool
+}
+
+func (s *state) checkNetwork(context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.network {
+		return errors.New("network not ready")
+	}
+
+	return nil
+}
+
+func (s *state) checkRuntime(context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.started {
+		return errors.New("runtime services not started")
+	}
+
+	return nil
+}
+
+func (s *state) checkPhase(min neighborhood_service.Phase) func(context.Context) error {
+	return func(context.Context) error {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		if s.phase < min {
+			return fmt.Errorf("%s (want %s)", s.phase, min)
+		}
+
+		return nil
+	}
+}
+
+// checkBus returns nil if the event bus is responsive, i.e. if it can be locked.
+func checkBus(bus event.Bus) func(context.Context) error {
+	return func(context.Context) error {
+		_ = bus.GetAllEventTypes()
+		return nil
+	}
+}
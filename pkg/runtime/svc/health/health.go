@@ -0,0 +1,211 @@
+// Package health implements a service that serves liveness and readiness probes over
+// HTTP, e.g. for Kubernetes.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"go.uber.org/fx"
+	"go.uber.org/multierr"
+
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/host"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/internal/p2p"
+	"github.com/wetware/ww/pkg/runtime"
+	"github.com/wetware/ww/pkg/runtime/svc/internal"
+	neighborhood_service "github.com/wetware/ww/pkg/runtime/svc/neighborhood"
+)
+
+// Timeout for a probe.  Checks that have not returned are reported as failed.
+const Timeout = time.Second * 5
+
+// Config for Health service.
+type Config struct {
+	fx.In
+
+	Log  ww.Logger `optional:"true"`
+	Host host.Host
+
+	// Addr on which /healthz and /readyz are served.  If empty, no probes are
+	// served.
+	Addr string `name:"health_addr" optional:"true"`
+
+	// MinPhase is the lowest neighborhood phase in which the host is ready.  It
+	// defaults to PhasePartial.
+	MinPhase neighborhood_service.Phase `name:"ready_phase" optional:"true"`
+
+	Checkers []Checker `group:"readiness"`
+}
+
+// NewService satisfies runtime.ServiceFactory
+func (cfg Config) NewService() (_ runtime.Service, err error) {
+	if cfg.MinPhase == neighborhood_service.PhaseOrphaned {
+		cfg.MinPhase = neighborhood_service.PhasePartial
+	}
+
+	h := &health{
+		addr: cfg.Addr,
+		live: []Checker{
+			CheckFunc("bus", checkBus(cfg.Host.EventBus())),
+		},
+	}
+
+	h.ready = append([]Checker{
+		CheckFunc("network", h.s.checkNetwork),
+		CheckFunc("neighborhood", h.s.checkPhase(cfg.MinPhase)),
+		CheckFunc("runtime", h.s.checkRuntime),
+	}, cfg.Checkers...)
+
+	if h.sub, err = cfg.Host.EventBus().Subscribe([]interface{}{
+		new(p2p.EvtNetworkReady),
+		new(neighborhood_service.EvtNeighborhoodChanged),
+		new(runtime.EvtRuntimeStarted),
+	}); err != nil {
+		return
+	}
+
+	h.log = internal.Logger(cfg.Log, h)
+
+	return h, nil
+}
+
+// Consumes p2p.EvtNetworkReady, neighborhood.EvtNeighborhoodChanged &
+// runtime.EvtRuntimeStarted.
+func (cfg Config) Consumes() []interface{} {
+	return []interface{}{
+		p2p.EvtNetworkReady{},
+		neighborhood_service.EvtNeighborhoodChanged{},
+		runtime.EvtRuntimeStarted{},
+	}
+}
+
+// External satisfies runtime.ExternalConsumer.  p2p.EvtNetworkReady is emitted by the
+// libp2p host.
+func (cfg Config) External() []interface{} {
+	return []interface{}{p2p.EvtNetworkReady{}}
+}
+
+// Module for Health service.
+type Module struct {
+	fx.Out
+
+	Factory runtime.ServiceFactory `group:"runtime"`
+}
+
+// New Health service.  The service serves two endpoints on Addr, each of which
+// responds with a JSON Report, and a status of 503 if any check failed:
+//
+//   - /healthz reports whether the process is up and its event bus is responsive.
+//   - /readyz reports whether the network is ready, the neighborhood has reached
+//     MinPhase, and all runtime services have started, along with the result of each
+//     Checker.
+//
+// If Addr cannot be bound, the error is logged and the host starts without probes.
+//
+// Consumes:
+//   - p2p.EvtNetworkReady [ libp2p ]
+//   - neighborhood.EvtNeighborhoodChanged
+//   - runtime.EvtRuntimeStarted
+func New(cfg Config) Module { return Module{Factory: cfg} }
+
+type health struct {
+	log ww.Logger
+
+	addr string
+	l    net.Listener
+	srv  *http.Server
+
+	live, ready []Checker
+
+	s   state
+	sub event.Subscription
+}
+
+func (h *health) Loggable() map[string]interface{} {
+	addr := h.addr
+	if h.l != nil {
+		addr = h.l.Addr().String()
+	}
+
+	return map[string]interface{}{
+		"service":     "health",
+		"health_addr": addr,
+	}
+}
+
+func (h *health) Start(ctx context.Context) (err error) {
+	internal.StartBackground(ctx, h.subloop)
+
+	if h.addr == "" {
+		return
+	}
+
+	// a probe that cannot be served must not prevent the host from starting
+	if h.l, err = net.Listen("tcp", h.addr); err != nil {
+		h.log.WithError(err).Error("health probes disabled")
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", probe(h.live))
+	mux.Handle("/readyz", probe(h.ready))
+	h.srv = &http.Server{Handler: mux}
+
+	internal.StartBackground(ctx, h.serve)
+	return
+}
+
+func (h *health) Stop(ctx context.Context) error {
+	var err error
+	if h.srv != nil {
+		err = h.srv.Shutdown(ctx)
+	}
+
+	return multierr.Combine(
+		err,
+		h.sub.Close(),
+	)
+}
+
+func (h *health) serve() {
+	if err := h.srv.Serve(h.l); err != http.ErrServerClosed {
+		h.log.WithError(err).Error("health server failed")
+	}
+}
+
+func (h *health) subloop() {
+	for v := range h.sub.Out() {
+		h.s.mu.Lock()
+		switch ev := v.(type) {
+		case p2p.EvtNetworkReady:
+			h.s.network = true
+		case neighborhood_service.EvtNeighborhoodChanged:
+			h.s.phase = ev.To
+		case runtime.EvtRuntimeStarted:
+			h.s.started = true
+		}
+		h.s.mu.Unlock()
+	}
+}
+
+func probe(cs []Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), Timeout)
+		defer cancel()
+
+		report := run(ctx, cs)
+
+		w.Header().Set("Content-Type", "application/json")
+		if !report.OK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		_ = json.NewEncoder(w).Encode(report)
+	}
+}
@@ -0,0 +1,162 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+
+	logutil "github.com/wetware/ww/internal/util/log"
+	"github.com/wetware/ww/pkg/internal/p2p"
+	"github.com/wetware/ww/pkg/runtime"
+	health_service "github.com/wetware/ww/pkg/runtime/svc/health"
+	neighborhood_service "github.com/wetware/ww/pkg/runtime/svc/neighborhood"
+)
+
+func TestHealthLoggable(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h, err := mocknet.New(ctx).GenPeer()
+	require.NoError(t, err)
+
+	svc, err := health_service.New(health_service.Config{
+		Log:  logutil.Nop(),
+		Host: h,
+		Addr: ":8080",
+	}).Factory.NewService()
+	require.NoError(t, err)
+
+	assert.Equal(t, "health", svc.Loggable()["service"])
+	assert.Equal(t, ":8080", svc.Loggable()["health_addr"])
+}
+
+func TestHealthProbes(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	h, err := mocknet.New(ctx).GenPeer()
+	require.NoError(t, err)
+
+	var ready int32
+
+	svc, err := health_service.New(health_service.Config{
+		Log:  logutil.Nop(),
+		Host: h,
+		Addr: "127.0.0.1:0",
+		Checkers: []health_service.Checker{
+			health_service.CheckFunc("custom", func(context.Context) error {
+				if atomic.LoadInt32(&ready) == 0 {
+					return errors.New("not ready")
+				}
+
+				return nil
+			}),
+		},
+	}).Factory.NewService()
+	require.NoError(t, err)
+
+	require.NoError(t, svc.Start(ctx))
+	defer func() {
+		require.NoError(t, svc.Stop(ctx))
+	}()
+
+	base := "http://" + svc.Loggable()["health_addr"].(string)
+
+	t.Run("Healthz", func(t *testing.T) {
+		status, report := probe(t, base+"/healthz")
+		assert.Equal(t, http.StatusOK, status)
+		assert.True(t, report.OK)
+		require.Len(t, report.Checks, 1)
+		assert.Equal(t, "bus", report.Checks[0].Name)
+		assert.NotEmpty(t, report.Checks[0].Latency)
+	})
+
+	t.Run("NotReady", func(t *testing.T) {
+		status, report := probe(t, base+"/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		assert.False(t, report.OK)
+
+		require.Len(t, report.Checks, 4)
+		for i, name := range []string{"network", "neighborhood", "runtime", "custom"} {
+			assert.Equal(t, name, report.Checks[i].Name)
+			assert.False(t, report.Checks[i].OK, "%s should fail", name)
+			assert.NotEmpty(t, report.Checks[i].Error)
+		}
+	})
+
+	t.Run("Ready", func(t *testing.T) {
+		emit(t, h, p2p.EvtNetworkReady{Network: h.Network()})
+		emit(t, h, neighborhood_service.EvtNeighborhoodChanged{
+			K:  1,
+			To: neighborhood_service.PhasePartial,
+		})
+		emit(t, h, runtime.EvtRuntimeStarted{Services: 1})
+		atomic.StoreInt32(&ready, 1)
+
+		require.Eventually(t, func() bool {
+			status, report := probe(t, base+"/readyz")
+			return status == http.StatusOK && report.OK
+		}, time.Second, time.Millisecond*10, "host should be ready")
+	})
+}
+
+func TestHealthPortTaken(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	h, err := mocknet.New(ctx).GenPeer()
+	require.NoError(t, err)
+
+	svc, err := health_service.New(health_service.Config{
+		Log:  logutil.Nop(),
+		Host: h,
+		Addr: l.Addr().String(),
+	}).Factory.NewService()
+	require.NoError(t, err)
+
+	assert.NoError(t, svc.Start(ctx), "should degrade if the port is taken")
+	assert.NoError(t, svc.Stop(ctx))
+}
+
+func probe(t *testing.T, url string) (int, health_service.Report) {
+	res, err := http.Get(url)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+	var report health_service.Report
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&report))
+
+	return res.StatusCode, report
+}
+
+func emit(t *testing.T, h host.Host, ev interface{}) {
+	e, err := h.EventBus().Emitter(reflect.New(reflect.TypeOf(ev)).Interface())
+	require.NoError(t, err)
+	defer e.Close()
+
+	require.NoError(t, e.Emit(ev))
+}
@@ -0,0 +1,369 @@
+// Package heartbeat implements a service that announces the liveness of the local
+// host over pubsub, and maintains a view of the cluster's members from the heartbeats
+// of other hosts.
+package heartbeat
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/lthibault/jitterbug"
+	"github.com/pkg/errors"
+	"go.uber.org/fx"
+	"go.uber.org/multierr"
+
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/runtime"
+	"github.com/wetware/ww/pkg/runtime/svc/internal"
+	"github.com/wetware/ww/pkg/runtime/svc/neighborhood"
+	"github.com/wetware/ww/pkg/runtime/svc/ticker"
+	randutil "github.com/wetware/ww/pkg/util/rand"
+)
+
+const maxHeartbeatSize = 512
+
+// DepartureTimeout bounds the time spent announcing the departure of the local host
+// when the service stops, so that a broken network cannot hang shutdown.
+const DepartureTimeout = time.Second * 2
+
+type (
+	// EvtMemberJoined is emitted when a heartbeat is received from a host that is not
+	// in the view, or whose membership was stale.
+	EvtMemberJoined struct{ Member }
+
+	// EvtMemberStale is emitted when no heartbeat has been received from a member
+	// within its TTL.
+	EvtMemberStale struct{ Member }
+
+	// EvtMemberLeft is emitted when a member is removed from the view, either because
+	// it was stale for too long, or because it announced its departure, in which case
+	// Leaving is true.
+	EvtMemberLeft struct{ Member }
+)
+
+// Topic returns the pubsub topic on which heartbeats for namespace ns are published.
+func Topic(ns string) string { return ns + "/heartbeat" }
+
+// Config for Heartbeat service.
+type Config struct {
+	fx.In
+
+	Log       ww.Logger `optional:"true"`
+	Host      host.Host
+	PubSub    *pubsub.PubSub
+	Namespace string        `name:"ns"`
+	TTL       time.Duration `name:"ttl"`
+
+	// Interval between heartbeats.  Defaults to TTL/2.  The actual interval is
+	// jittered between Interval/2 and Interval.
+	Interval time.Duration `name:"heartbeat_interval" optional:"true"`
+}
+
+// Produces EvtMemberJoined, EvtMemberStale & EvtMemberLeft.
+func (cfg Config) Produces() []interface{} {
+	return []interface{}{
+		EvtMemberJoined{},
+		EvtMemberStale{},
+		EvtMemberLeft{},
+	}
+}
+
+// Public EvtMemberJoined, EvtMemberStale & EvtMemberLeft, which report changes to the
+// cluster view.
+func (cfg Config) Public() []interface{} { return cfg.Produces() }
+
+// Consumes ticker.EvtTimestep & neighborhood.EvtNeighborhoodChanged.
+func (cfg Config) Consumes() []interface{} {
+	return []interface{}{
+		ticker.EvtTimestep{},
+		neighborhood.EvtNeighborhoodChanged{},
+	}
+}
+
+// Module for Heartbeat service.
+type Module struct {
+	fx.Out
+
+	Factory runtime.ServiceFactory `group:"runtime"`
+	Members *View
+}
+
+// New Heartbeat service.  The service periodically publishes a Heartbeat on the
+// namespace's heartbeat topic, and maintains a View of the hosts from which heartbeats
+// are received, including the local host.  The View is provided to the application,
+// so that cluster membership can be queried.
+//
+// When the service stops, it announces the departure of the local host with a final
+// heartbeat, so that other hosts remove it from their views immediately, rather than
+// after its membership has gone stale.  The announcement is bounded by
+// DepartureTimeout.
+//
+// Consumes:
+//   - ticker.EvtTimestep
+//   - neighborhood.EvtNeighborhoodChanged
+//
+// Emits:
+//   - EvtMemberJoined
+//   - EvtMemberStale
+//   - EvtMemberLeft
+func New(cfg Config) Module {
+	v := NewView()
+	return Module{
+		Factory: factory{Config: cfg, view: v},
+		Members: v,
+	}
+}
+
+type factory struct {
+	Config
+	view *View
+}
+
+// NewService satisfies runtime.ServiceFactory
+func (f factory) NewService() (_ runtime.Service, err error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	hb := &heartbeat{
+		h:        f.Host,
+		ps:       f.PubSub,
+		topic:    Topic(f.Namespace),
+		ttl:      f.TTL,
+		interval: f.Interval,
+		view:     f.view,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	if hb.interval == 0 {
+		hb.interval = f.TTL / 2
+	}
+
+	if hb.sub, err = f.Host.EventBus().Subscribe([]interface{}{
+		new(ticker.EvtTimestep),
+		new(neighborhood.EvtNeighborhoodChanged),
+	}); err != nil {
+		return
+	}
+
+	if hb.joined, err = internal.NewEmitter(f.Host.EventBus(), new(EvtMemberJoined)); err != nil {
+		return
+	}
+
+	if hb.stale, err = internal.NewEmitter(f.Host.EventBus(), new(EvtMemberStale)); err != nil {
+		return
+	}
+
+	if hb.left, err = internal.NewEmitter(f.Host.EventBus(), new(EvtMemberLeft)); err != nil {
+		return
+	}
+
+	if err = f.PubSub.RegisterTopicValidator(hb.topic, validate); err != nil {
+		return
+	}
+
+	if hb.t, err = f.PubSub.Join(hb.topic); err != nil {
+		return
+	}
+
+	hb.log = internal.Logger(f.Log, hb)
+
+	return hb, nil
+}
+
+type heartbeat struct {
+	log ww.Logger
+	h   host.Host
+
+	ps    *pubsub.PubSub
+	topic string
+	t     *pubsub.Topic
+	msgs  *pubsub.Subscription
+
+	ttl, interval time.Duration
+	start         time.Time
+	epoch         uint64 // atomic
+	k             int64  // atomic
+
+	view *View
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	sub                 event.Subscription
+	joined, stale, left *internal.Emitter
+}
+
+func (hb *heartbeat) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"service":            "heartbeat",
+		"ttl":                hb.ttl,
+		"heartbeat_interval": hb.interval,
+	}
+}
+
+// Snapshot satisfies runtime.Snapshotter.  It returns the members of the view.
+func (hb *heartbeat) Snapshot() interface{} { return hb.view.Members() }
+
+func (hb *heartbeat) Start(ctx context.Context) (err error) {
+	if err = internal.WaitNetworkReady(ctx, hb.h.EventBus()); err != nil {
+		return
+	}
+
+	if hb.msgs, err = hb.t.Subscribe(); err == nil {
+		hb.start = time.Now()
+		internal.StartBackground(ctx,
+			hb.subloop,
+			hb.recvloop,
+		)
+	}
+
+	return
+}
+
+func (hb *heartbeat) Stop(ctx context.Context) error {
+	if hb.msgs != nil {
+		hb.depart(ctx)
+		hb.msgs.Cancel()
+	}
+	hb.cancel()
+
+	return multierr.Combine(
+		hb.sub.Close(),
+		hb.ps.UnregisterTopicValidator(hb.topic),
+		hb.joined.Close(),
+		hb.stale.Close(),
+		hb.left.Close(),
+	)
+}
+
+func (hb *heartbeat) subloop() {
+	s := internal.NewScheduler(hb.interval, jitterbug.Uniform{
+		Min:    hb.interval / 2,
+		Source: rand.New(randutil.FromPeer(hb.h.ID())),
+	})
+
+	// announce immediately, so that peers need not wait for the first interval
+	hb.publish()
+
+	for v := range hb.sub.Out() {
+		switch ev := v.(type) {
+		case neighborhood.EvtNeighborhoodChanged:
+			atomic.StoreInt64(&hb.k, int64(ev.K))
+
+		case ticker.EvtTimestep:
+			if s.Advance(ev.Delta) {
+				hb.publish()
+				s.Reset()
+			}
+
+			stale, left := hb.view.expire(ev.Time)
+			for _, m := range stale {
+				hb.emit(hb.stale, EvtMemberStale{m})
+			}
+
+			for _, m := range left {
+				hb.emit(hb.left, EvtMemberLeft{m})
+			}
+		}
+	}
+}
+
+func (hb *heartbeat) recvloop() {
+	for {
+		msg, err := hb.msgs.Next(hb.ctx)
+		if err != nil {
+			return
+		}
+
+		beat := msg.ValidatorData.(Heartbeat)
+		if beat.Leaving {
+			if m, ok := hb.view.depart(beat); ok {
+				hb.emit(hb.left, EvtMemberLeft{m})
+			}
+
+			continue
+		}
+
+		m, live, ok := hb.view.observe(beat, time.Now())
+		if ok && live {
+			hb.emit(hb.joined, EvtMemberJoined{m})
+		}
+	}
+}
+
+func (hb *heartbeat) publish() {
+	if err := hb.send(hb.ctx, false); err != nil && hb.ctx.Err() == nil {
+		hb.log.WithError(err).Warn("failed to publish heartbeat")
+	}
+}
+
+// depart announces that the local host is leaving the cluster.
+func (hb *heartbeat) depart(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, DepartureTimeout)
+	defer cancel()
+
+	if err := hb.send(ctx, true); err != nil {
+		hb.log.WithError(err).Debug("failed to announce departure")
+	}
+}
+
+func (hb *heartbeat) send(ctx context.Context, leaving bool) error {
+	b, err := json.Marshal(Heartbeat{
+		ID:      hb.h.ID(),
+		Uptime:  time.Since(hb.start),
+		Epoch:   atomic.AddUint64(&hb.epoch, 1),
+		K:       int(atomic.LoadInt64(&hb.k)),
+		TTL:     hb.ttl,
+		Leaving: leaving,
+	})
+	if err != nil {
+		return err
+	}
+
+	return hb.t.Publish(ctx, b)
+}
+
+func (hb *heartbeat) emit(e *internal.Emitter, ev interface{}) {
+	if err := e.Emit(ev); err != nil && err != internal.ErrEmitterClosed {
+		hb.log.WithError(err).Errorf("failed to emit %T", ev)
+	}
+}
+
+// validate heartbeats, rejecting those that are malformed, or whose ID does not match
+// the signer of the message.
+func validate(_ context.Context, _ peer.ID, msg *pubsub.Message) bool {
+	hb, err := decode(msg)
+	if err != nil {
+		return false
+	}
+
+	msg.ValidatorData = hb
+	return true
+}
+
+func decode(msg *pubsub.Message) (hb Heartbeat, err error) {
+	if len(msg.GetData()) > maxHeartbeatSize {
+		return hb, errors.New("heartbeat too large")
+	}
+
+	if err = json.Unmarshal(msg.GetData(), &hb); err != nil {
+		return
+	}
+
+	if hb.ID != msg.GetFrom() {
+		return hb, errors.Errorf("heartbeat for %s signed by %s", hb.ID, msg.GetFrom())
+	}
+
+	if hb.TTL <= 0 {
+		return hb, errors.Errorf("invalid ttl %s", hb.TTL)
+	}
+
+	return
+}
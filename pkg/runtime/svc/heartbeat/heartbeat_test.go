@@ -0,0 +1,280 @@
+package heartbeat_test
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	eventbus "github.com/libp2p/go-eventbus"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/host"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/multiformats/go-multiaddr"
+
+	logutil "github.com/wetware/ww/internal/util/log"
+	"github.com/wetware/ww/pkg/internal/p2p"
+	"github.com/wetware/ww/pkg/runtime"
+	heartbeat_service "github.com/wetware/ww/pkg/runtime/svc/heartbeat"
+	neighborhood_service "github.com/wetware/ww/pkg/runtime/svc/neighborhood"
+	tick_service "github.com/wetware/ww/pkg/runtime/svc/ticker"
+)
+
+const ns = "ww.test"
+
+func TestHeartbeatLoggable(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn := mocknet.New(ctx)
+	h, err := mn.GenPeer()
+	require.NoError(t, err)
+
+	ps, err := pubsub.NewGossipSub(ctx, h)
+	require.NoError(t, err)
+
+	hb, err := heartbeat_service.New(heartbeat_service.Config{
+		Host:      h,
+		PubSub:    ps,
+		Namespace: ns,
+		TTL:       time.Second,
+	}).Factory.NewService()
+	require.NoError(t, err)
+
+	assert.Equal(t, "heartbeat", hb.Loggable()["service"])
+	assert.Equal(t, time.Second, hb.Loggable()["ttl"])
+	assert.Equal(t, time.Millisecond*500, hb.Loggable()["heartbeat_interval"],
+		"interval should default to TTL/2")
+}
+
+func TestHeartbeat(t *testing.T) {
+	t.Parallel()
+
+	const ttl = time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	hs, views, svcs := cluster(ctx, t, 2, ttl)
+
+	h := hs[0]
+	sub, err := h.EventBus().Subscribe([]interface{}{
+		new(heartbeat_service.EvtMemberJoined),
+		new(heartbeat_service.EvtMemberStale),
+		new(heartbeat_service.EvtMemberLeft),
+	})
+	require.NoError(t, err)
+	defer sub.Close()
+
+	eHood, err := h.EventBus().Emitter(new(neighborhood_service.EvtNeighborhoodChanged))
+	require.NoError(t, err)
+	defer eHood.Close()
+
+	require.NoError(t, eHood.Emit(neighborhood_service.EvtNeighborhoodChanged{K: 1}))
+
+	for i, svc := range svcs {
+		require.NoError(t, netReady(hs[i].EventBus()))
+		require.NoError(t, svc.Start(ctx))
+		defer func(svc runtime.Service) {
+			require.NoError(t, svc.Stop(ctx))
+		}(svc)
+	}
+
+	eTick := tickEmitter(t, hs...)
+	join(ctx, t, sub, eTick, ttl, hs)
+
+	m, ok := views[0].Lookup(h.ID())
+	require.True(t, ok, "local host should be a member")
+	assert.Equal(t, ttl, m.TTL)
+	assert.NotZero(t, m.Epoch)
+	assert.Eventually(t, func() bool {
+		m, _ := views[1].Lookup(h.ID())
+		return m.K == 1
+	}, time.Second, time.Millisecond*10, "remote view should report the neighborhood size")
+
+	// Expiry is driven by timesteps, independently of the members' clocks.
+	require.NoError(t, eTick(time.Now().Add(ttl*2), 0))
+	stale := collect(ctx, t, sub, len(hs))
+	for _, v := range stale {
+		assert.IsType(t, heartbeat_service.EvtMemberStale{}, v)
+	}
+
+	require.NoError(t, eTick(time.Now().Add(ttl*4), 0))
+	left := collect(ctx, t, sub, len(hs))
+	for _, v := range left {
+		assert.IsType(t, heartbeat_service.EvtMemberLeft{}, v)
+	}
+
+	assert.Empty(t, views[0].Members())
+}
+
+func TestHeartbeatDeparture(t *testing.T) {
+	t.Parallel()
+
+	const ttl = time.Hour // members never go stale
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	hs, views, svcs := cluster(ctx, t, 2, ttl)
+
+	sub, err := hs[0].EventBus().Subscribe([]interface{}{
+		new(heartbeat_service.EvtMemberJoined),
+		new(heartbeat_service.EvtMemberLeft),
+	})
+	require.NoError(t, err)
+	defer sub.Close()
+
+	for i, svc := range svcs {
+		require.NoError(t, netReady(hs[i].EventBus()))
+		require.NoError(t, svc.Start(ctx))
+	}
+	defer func() {
+		require.NoError(t, svcs[0].Stop(ctx))
+	}()
+
+	join(ctx, t, sub, tickEmitter(t, hs...), ttl/2, hs)
+
+	require.NoError(t, svcs[1].Stop(ctx))
+
+	select {
+	case v := <-sub.Out():
+		ev, ok := v.(heartbeat_service.EvtMemberLeft)
+		require.True(t, ok, "unexpected event %T", v)
+		assert.Equal(t, hs[1].ID(), ev.ID)
+		assert.True(t, ev.Leaving)
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	}
+
+	_, ok := views[0].Lookup(hs[1].ID())
+	assert.False(t, ok, "departed member should be removed immediately")
+}
+
+// cluster of n hosts running the heartbeat service, which has not been started.
+func cluster(ctx context.Context, t *testing.T, n int, ttl time.Duration) (
+	[]host.Host, []*heartbeat_service.View, []runtime.Service) {
+
+	// pubsub rejects heartbeats signed with mocknet's bogus keys
+	mn := mocknet.New(ctx)
+	for i := 0; i < n; i++ {
+		sk, _, err := crypto.GenerateEd25519Key(rand.Reader)
+		require.NoError(t, err)
+
+		_, err = mn.AddPeer(sk, multiaddr.StringCast(fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", 2020+i)))
+		require.NoError(t, err)
+	}
+
+	var (
+		hs    = mn.Hosts()
+		views = make([]*heartbeat_service.View, len(hs))
+		svcs  = make([]runtime.Service, len(hs))
+	)
+
+	for i, h := range hs {
+		ps, err := pubsub.NewGossipSub(ctx, h)
+		require.NoError(t, err)
+
+		mod := heartbeat_service.New(heartbeat_service.Config{
+			Log:       logutil.Nop(),
+			Host:      h,
+			PubSub:    ps,
+			Namespace: ns,
+			TTL:       ttl,
+		})
+		views[i] = mod.Members
+
+		svcs[i], err = mod.Factory.NewService()
+		require.NoError(t, err)
+	}
+
+	// connect after pubsub is running, so that the hosts discover each other's topics
+	require.NoError(t, mn.LinkAll())
+	require.NoError(t, mn.ConnectAllButSelf())
+
+	return hs, views, svcs
+}
+
+// join waits until every host has been seen by the subscriber.  Heartbeats are
+// published when the service starts, but pubsub may drop them if the mesh has not yet
+// formed, so the scheduler is driven by timesteps of the given interval.
+func join(ctx context.Context, t *testing.T, sub event.Subscription,
+	eTick func(time.Time, time.Duration) error, interval time.Duration, hs []host.Host) {
+
+	joined := map[host.Host]bool{}
+	for len(joined) < len(hs) {
+		require.NoError(t, eTick(time.Now(), interval))
+
+		select {
+		case v := <-sub.Out():
+			ev, ok := v.(heartbeat_service.EvtMemberJoined)
+			require.True(t, ok, "unexpected event %T", v)
+
+			for _, p := range hs {
+				if p.ID() == ev.ID {
+					joined[p] = true
+				}
+			}
+		case <-time.After(time.Millisecond * 100):
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		}
+	}
+}
+
+func collect(ctx context.Context, t *testing.T, sub event.Subscription, n int) []interface{} {
+	vs := make([]interface{}, 0, n)
+	for len(vs) < n {
+		select {
+		case v := <-sub.Out():
+			vs = append(vs, v)
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		}
+	}
+
+	return vs
+}
+
+// tickEmitter returns a function that emits ticker.EvtTimestep on each host's bus.
+func tickEmitter(t *testing.T, hs ...host.Host) func(time.Time, time.Duration) error {
+	es := make([]event.Emitter, len(hs))
+	for i, h := range hs {
+		e, err := h.EventBus().Emitter(new(tick_service.EvtTimestep))
+		require.NoError(t, err)
+		t.Cleanup(func() { e.Close() })
+		es[i] = e
+	}
+
+	return func(now time.Time, delta time.Duration) error {
+		for _, e := range es {
+			if err := e.Emit(tick_service.EvtTimestep{Time: now, Delta: delta}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// netReady emits p2p.EvtNetworkReady
+func netReady(bus event.Bus) error {
+	e, err := bus.Emitter(new(p2p.EvtNetworkReady), eventbus.Stateful)
+	if err != nil {
+		return err
+	}
+
+	return e.Emit(p2p.EvtNetworkReady{})
+}
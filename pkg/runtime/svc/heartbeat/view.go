@@ -0,0 +1,133 @@
+package heartbeat
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// leaveAfter is the number of TTLs after which a silent member is considered to have
+// left the cluster.
+const leaveAfter = 3
+
+// Heartbeat is published periodically by each host.  It is signed by the pubsub
+// layer, so that its ID can be trusted.
+type Heartbeat struct {
+	ID     peer.ID       `json:"id"`
+	Uptime time.Duration `json:"uptime"`
+	Epoch  uint64        `json:"epoch"` // incremented with each heartbeat
+	K      int           `json:"k"`     // number of connected peers
+	TTL    time.Duration `json:"ttl"`
+
+	// Leaving is true if the host is shutting down.  It is the host's last heartbeat.
+	Leaving bool `json:"leaving,omitempty"`
+}
+
+// Member of the cluster, as observed through its heartbeats.
+type Member struct {
+	Heartbeat
+
+	// LastSeen is the local time at which the latest heartbeat was received.  Expiry
+	// is computed from it, rather than from any timestamp provided by the member, so
+	// that clock skew between hosts does not cause false staleness.
+	LastSeen time.Time `json:"last_seen"`
+
+	// Stale is true if no heartbeat has been received within the member's TTL.
+	Stale bool `json:"stale,omitempty"`
+}
+
+// View of the cluster's members.  It is safe for concurrent use.
+type View struct {
+	mu sync.RWMutex
+	ms map[peer.ID]*Member
+}
+
+// NewView returns an empty View.
+func NewView() *View {
+	return &View{ms: make(map[peer.ID]*Member)}
+}
+
+// Members returns a snapshot of the cluster's members, sorted by peer ID.  Stale
+// members are included.
+func (v *View) Members() []Member {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	ms := make([]Member, 0, len(v.ms))
+	for _, m := range v.ms {
+		ms = append(ms, *m)
+	}
+
+	sort.Slice(ms, func(i, j int) bool { return ms[i].ID < ms[j].ID })
+	return ms
+}
+
+// Lookup returns the member with the given ID.
+func (v *View) Lookup(id peer.ID) (Member, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if m, ok := v.ms[id]; ok {
+		return *m, true
+	}
+
+	return Member{}, false
+}
+
+// observe a heartbeat received at time t.  It returns the updated member, and true if
+// the member joined or recovered from staleness.  Heartbeats that are older than the
+// latest one received from the member are ignored, unless the member restarted.
+func (v *View) observe(hb Heartbeat, t time.Time) (_ Member, live, ok bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	m, found := v.ms[hb.ID]
+	if !found {
+		m = &Member{}
+		v.ms[hb.ID] = m
+	} else if hb.Epoch <= m.Epoch && hb.Uptime >= m.Uptime {
+		return *m, false, false // replayed or reordered
+	}
+
+	live = !found || m.Stale
+	*m = Member{Heartbeat: hb, LastSeen: t}
+	return *m, live, true
+}
+
+// depart removes the member that sent the heartbeat, which announces its departure.
+// It returns the removed member, and false if the member was not in the view or the
+// heartbeat is stale.
+func (v *View) depart(hb Heartbeat) (Member, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	m, found := v.ms[hb.ID]
+	if !found || (hb.Epoch <= m.Epoch && hb.Uptime >= m.Uptime) {
+		return Member{}, false
+	}
+
+	delete(v.ms, hb.ID)
+	return Member{Heartbeat: hb, LastSeen: m.LastSeen}, true
+}
+
+// expire members at time t.  It returns the members that became stale, and those that
+// were removed.
+func (v *View) expire(t time.Time) (stale, left []Member) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for id, m := range v.ms {
+		switch silence := t.Sub(m.LastSeen); {
+		case silence > m.TTL*leaveAfter:
+			delete(v.ms, id)
+			left = append(left, *m)
+		case silence > m.TTL && !m.Stale:
+			m.Stale = true
+			stale = append(stale, *m)
+		}
+	}
+
+	return
+}
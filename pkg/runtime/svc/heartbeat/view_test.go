@@ -0,0 +1,98 @@
+package heartbeat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestView(t *testing.T) {
+	t.Parallel()
+
+	const (
+		a = peer.ID("a")
+		b = peer.ID("b")
+	)
+
+	var (
+		v   = NewView()
+		t0  = time.Unix(0, 0)
+		ttl = time.Second
+	)
+
+	_, live, ok := v.observe(Heartbeat{ID: b, Epoch: 1, Uptime: time.Minute, TTL: ttl}, t0)
+	assert.True(t, ok)
+	assert.True(t, live, "new member should be live")
+
+	_, live, ok = v.observe(Heartbeat{ID: a, Epoch: 1, TTL: ttl}, t0)
+	assert.True(t, ok)
+	assert.True(t, live, "new member should be live")
+
+	t.Run("Snapshot", func(t *testing.T) {
+		ms := v.Members()
+		require.Len(t, ms, 2)
+		assert.Equal(t, a, ms[0].ID, "members should be sorted by ID")
+		assert.Equal(t, b, ms[1].ID, "members should be sorted by ID")
+
+		m, ok := v.Lookup(b)
+		require.True(t, ok)
+		assert.Equal(t, time.Minute, m.Uptime)
+		assert.Equal(t, t0, m.LastSeen)
+
+		_, ok = v.Lookup("c")
+		assert.False(t, ok)
+	})
+
+	t.Run("Replay", func(t *testing.T) {
+		_, _, ok := v.observe(Heartbeat{ID: b, Epoch: 1, Uptime: time.Minute, TTL: ttl}, t0.Add(ttl/2))
+		assert.False(t, ok, "replayed heartbeat should be ignored")
+
+		m, _ := v.Lookup(b)
+		assert.Equal(t, t0, m.LastSeen, "replayed heartbeat should not refresh member")
+	})
+
+	t.Run("Restart", func(t *testing.T) {
+		// epoch and uptime are reset when a member restarts
+		_, live, ok := v.observe(Heartbeat{ID: a, Epoch: 1, TTL: ttl}, t0.Add(ttl/2))
+		assert.False(t, ok, "heartbeat with equal uptime should be ignored")
+		assert.False(t, live)
+
+		_, live, ok = v.observe(Heartbeat{ID: b, Epoch: 1, Uptime: time.Millisecond, TTL: ttl}, t0.Add(ttl/2))
+		assert.True(t, ok, "heartbeat from restarted member should be accepted")
+		assert.False(t, live, "restarted member was never stale")
+	})
+
+	t.Run("Expire", func(t *testing.T) {
+		stale, left := v.expire(t0.Add(ttl))
+		assert.Empty(t, stale, "member should not be stale before TTL has elapsed")
+		assert.Empty(t, left)
+
+		stale, left = v.expire(t0.Add(ttl + 1))
+		require.Len(t, stale, 1)
+		assert.Equal(t, a, stale[0].ID)
+		assert.Empty(t, left)
+
+		stale, _ = v.expire(t0.Add(ttl + 2))
+		assert.Empty(t, stale, "staleness should be reported once")
+
+		m, _ := v.Lookup(a)
+		assert.True(t, m.Stale)
+
+		// a recovers
+		_, live, ok := v.observe(Heartbeat{ID: a, Epoch: 2, TTL: ttl}, t0.Add(ttl*2))
+		assert.True(t, ok)
+		assert.True(t, live, "stale member should become live again")
+
+		// b was last seen at t0 + ttl/2
+		_, left = v.expire(t0.Add(ttl/2 + ttl*leaveAfter + 1))
+		require.Len(t, left, 1)
+		assert.Equal(t, b, left[0].ID)
+
+		_, ok = v.Lookup(b)
+		assert.False(t, ok, "member should be removed after leaving")
+	})
+}
@@ -0,0 +1,75 @@
+package heartbeat
+
+import (
+	"context"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+// expireInterval is the period with which a watched View expires its members.
+const expireInterval = time.Millisecond * 500
+
+// Watch returns a View of the cluster's members, which is maintained from the
+// heartbeats published on the namespace's heartbeat topic until ctx expires.  Unlike
+// the service, Watch publishes no heartbeats, so that clients can observe the cluster
+// without joining it.  Members go stale and leave the View as they do in the service.
+//
+// The View is initially empty.  Each member appears when its next heartbeat is
+// received, i.e. within its TTL.
+func Watch(ctx context.Context, ps *pubsub.PubSub, ns string) (*View, error) {
+	topic := Topic(ns)
+	if err := ps.RegisterTopicValidator(topic, validate); err != nil {
+		return nil, err
+	}
+
+	t, err := ps.Join(topic)
+	if err != nil {
+		_ = ps.UnregisterTopicValidator(topic)
+		return nil, err
+	}
+
+	sub, err := t.Subscribe()
+	if err != nil {
+		_ = t.Close()
+		_ = ps.UnregisterTopicValidator(topic)
+		return nil, err
+	}
+
+	v := NewView()
+	go expireloop(ctx, v)
+	go func() {
+		defer ps.UnregisterTopicValidator(topic)
+		defer t.Close()
+		defer sub.Cancel()
+
+		for {
+			msg, err := sub.Next(ctx)
+			if err != nil {
+				return
+			}
+
+			if beat := msg.ValidatorData.(Heartbeat); beat.Leaving {
+				v.depart(beat)
+			} else {
+				v.observe(beat, time.Now())
+			}
+		}
+	}()
+
+	return v, nil
+}
+
+func expireloop(ctx context.Context, v *View) {
+	ticker := time.NewTicker(expireInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case t := <-ticker.C:
+			v.expire(t)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package heartbeat_test
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/multiformats/go-multiaddr"
+
+	logutil "github.com/wetware/ww/internal/util/log"
+	heartbeat_service "github.com/wetware/ww/pkg/runtime/svc/heartbeat"
+)
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	const ttl = time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	mn := mocknet.New(ctx)
+	for _, a := range []string{"/ip4/127.0.0.1/tcp/2030", "/ip4/127.0.0.1/tcp/2031"} {
+		sk, _, err := crypto.GenerateEd25519Key(rand.Reader)
+		require.NoError(t, err)
+
+		_, err = mn.AddPeer(sk, multiaddr.StringCast(a))
+		require.NoError(t, err)
+	}
+
+	h, w := mn.Hosts()[0], mn.Hosts()[1]
+
+	ps, err := pubsub.NewGossipSub(ctx, h)
+	require.NoError(t, err)
+
+	svc, err := heartbeat_service.New(heartbeat_service.Config{
+		Log:       logutil.Nop(),
+		Host:      h,
+		PubSub:    ps,
+		Namespace: ns,
+		TTL:       ttl,
+	}).Factory.NewService()
+	require.NoError(t, err)
+
+	wps, err := pubsub.NewGossipSub(ctx, w)
+	require.NoError(t, err)
+
+	view, err := heartbeat_service.Watch(ctx, wps, ns)
+	require.NoError(t, err)
+
+	require.NoError(t, mn.LinkAll())
+	require.NoError(t, mn.ConnectAllButSelf())
+
+	require.NoError(t, netReady(h.EventBus()))
+	require.NoError(t, svc.Start(ctx))
+	defer func() {
+		require.NoError(t, svc.Stop(ctx))
+	}()
+
+	eTick := tickEmitter(t, h)
+	assert.Eventually(t, func() bool {
+		if err := eTick(time.Now(), ttl/2); err != nil {
+			return false
+		}
+
+		_, ok := view.Lookup(h.ID())
+		return ok
+	}, time.Second*5, time.Millisecond*100, "watched view should contain the host")
+
+	_, ok := view.Lookup(w.ID())
+	assert.False(t, ok, "watcher should not publish heartbeats")
+}
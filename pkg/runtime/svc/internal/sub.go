@@ -0,0 +1,221 @@
+package internal
+
+import (
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/event"
+
+	ww "github.com/wetware/ww/pkg"
+)
+
+// DefaultBufSize is the default number of events buffered by a Subscription.
+const DefaultBufSize = 256
+
+// dropReportInterval rate-limits the warnings logged by Drop.
+const dropReportInterval = time.Second
+
+// Overflow policy of a Subscription, i.e. what happens to events that arrive while its
+// buffer is full.
+type Overflow interface {
+	// push ev onto q, which holds at most size events.  It returns false if ev was
+	// not accepted, in which case the subscription stops reading from the bus, and
+	// pushes ev again once the consumer has received an event.
+	push(q *queue, ev interface{}, size int) bool
+}
+
+// Coalesce buffered events with the same key, keeping the latest in the position of
+// the earliest.  The buffer bounds the number of distinct keys; when it is full,
+// events are left in the bus until the consumer catches up, so none is lost.  Coalesce
+// suits events that report the latest state of something, such as the connectedness of
+// a peer.
+func Coalesce(key func(ev interface{}) interface{}) Overflow {
+	return coalesce(key)
+}
+
+// Drop events that arrive while the buffer is full, and log a warning with the number
+// of dropped events at most once per second.  Drop suits events that consumers can
+// afford to miss, and whose emitters must never be stalled.
+func Drop(log ww.Logger) Overflow {
+	return &drop{log: log}
+}
+
+// Subscription to an event bus that eagerly drains the events of its underlying
+// subscription into a buffer, so that slow consumers do not stall emitters.  Events
+// that arrive while the buffer is full are handled by its Overflow policy.
+type Subscription struct {
+	sub  event.Subscription
+	out  chan interface{}
+	size int
+	o    Overflow
+
+	once sync.Once
+	cq   chan struct{}
+}
+
+// Subscribe to events of evtType, which may be a slice of types as with
+// event.Bus.Subscribe.  If size is zero, DefaultBufSize is used.
+func Subscribe(bus event.Bus, evtType interface{}, size int, o Overflow) (*Subscription, error) {
+	if size <= 0 {
+		size = DefaultBufSize
+	}
+
+	sub, err := bus.Subscribe(evtType)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Subscription{
+		sub:  sub,
+		out:  make(chan interface{}),
+		size: size,
+		o:    o,
+		cq:   make(chan struct{}),
+	}
+	go s.loop()
+
+	return s, nil
+}
+
+// Out returns the channel on which events are delivered.  It is closed after Close is
+// called.  Buffered events that have not been delivered are discarded.
+func (s *Subscription) Out() <-chan interface{} { return s.out }
+
+// Close the subscription.  It is idempotent.
+func (s *Subscription) Close() (err error) {
+	s.once.Do(func() {
+		close(s.cq)
+		err = s.sub.Close()
+	})
+
+	return
+}
+
+func (s *Subscription) loop() {
+	defer close(s.out)
+
+	var (
+		q       queue
+		in      = s.sub.Out()
+		pending interface{} // event awaiting room in the queue
+	)
+
+	for {
+		var (
+			out  chan<- interface{}
+			head interface{}
+		)
+
+		if q.Len() > 0 {
+			out, head = s.out, q.Front()
+		}
+
+		select {
+		case v, ok := <-in:
+			if !ok {
+				return
+			}
+
+			if !s.o.push(&q, v, s.size) {
+				pending, in = v, nil // backpressure
+			}
+
+		case out <- head:
+			q.Pop()
+
+			if in == nil && s.o.push(&q, pending, s.size) {
+				pending, in = nil, s.sub.Out()
+			}
+
+		case <-s.cq:
+			return
+		}
+	}
+}
+
+type coalesce func(ev interface{}) interface{}
+
+func (key coalesce) push(q *queue, ev interface{}, size int) bool {
+	k := key(ev)
+	if q.Replace(k, ev) {
+		return true
+	}
+
+	if q.Len() == size {
+		return false
+	}
+
+	q.Push(k, ev)
+	return true
+}
+
+type drop struct {
+	log ww.Logger
+
+	dropped  int
+	reported time.Time
+}
+
+func (d *drop) push(q *queue, ev interface{}, size int) bool {
+	if q.Len() < size {
+		q.Push(nil, ev)
+		return true
+	}
+
+	d.dropped++
+	if now := time.Now(); now.Sub(d.reported) >= dropReportInterval {
+		d.log.WithField("dropped", d.dropped).Warnf("subscriber overflow; dropped %T", ev)
+		d.dropped, d.reported = 0, now
+	}
+
+	return true
+}
+
+// queue of events in order of arrival, indexed by key.  Events with a nil key are not
+// indexed.
+type queue struct {
+	es   []queued
+	keys map[interface{}]int // key -> position in es, offset by head
+	head int                 // number of events popped
+}
+
+type queued struct {
+	key interface{}
+	ev  interface{}
+}
+
+func (q *queue) Len() int { return len(q.es) }
+
+func (q *queue) Front() interface{} { return q.es[0].ev }
+
+func (q *queue) Push(key, ev interface{}) {
+	if key != nil {
+		if q.keys == nil {
+			q.keys = make(map[interface{}]int)
+		}
+
+		q.keys[key] = q.head + len(q.es)
+	}
+
+	q.es = append(q.es, queued{key: key, ev: ev})
+}
+
+func (q *queue) Pop() {
+	if key := q.es[0].key; key != nil {
+		delete(q.keys, key)
+	}
+
+	q.es[0] = queued{}
+	q.es = q.es[1:]
+	q.head++
+}
+
+// Replace the buffered event with the given key, if any, and report whether it did.
+func (q *queue) Replace(key, ev interface{}) bool {
+	i, ok := q.keys[key]
+	if ok {
+		q.es[i-q.head].ev = ev
+	}
+
+	return ok
+}
@@ -0,0 +1,118 @@
+package internal_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	eventbus "github.com/libp2p/go-eventbus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	mock_ww "github.com/wetware/ww/internal/test/mock/pkg"
+	"github.com/wetware/ww/pkg/runtime/svc/internal"
+)
+
+type keyedEvent struct{ Key, Value int }
+
+func byKey(ev interface{}) interface{} { return ev.(keyedEvent).Key }
+
+func TestSubscription(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Coalesce", func(t *testing.T) {
+		t.Parallel()
+
+		bus := eventbus.NewBus()
+		sub, err := internal.Subscribe(bus, new(keyedEvent), 2, internal.Coalesce(byKey))
+		require.NoError(t, err)
+		defer sub.Close()
+
+		e, err := bus.Emitter(new(keyedEvent))
+		require.NoError(t, err)
+		defer e.Close()
+
+		// the buffer holds two keys; the third is left in the bus until the
+		// consumer catches up
+		for i, ev := range []keyedEvent{
+			{Key: 1, Value: 1},
+			{Key: 2, Value: 1},
+			{Key: 1, Value: 2},
+			{Key: 3, Value: 1},
+		} {
+			require.NoError(t, e.Emit(ev), "emit %d should not block", i)
+		}
+
+		time.Sleep(time.Millisecond * 10)
+
+		assert.Equal(t, keyedEvent{Key: 1, Value: 2}, <-sub.Out(),
+			"latest value should replace the earliest, in its position")
+		assert.Equal(t, keyedEvent{Key: 2, Value: 1}, <-sub.Out())
+		assert.Equal(t, keyedEvent{Key: 3, Value: 1}, <-sub.Out(), "event should not be lost")
+	})
+
+	t.Run("Drop", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		logger := mock_ww.NewMockLogger(ctrl)
+		logger.EXPECT().
+			WithField("dropped", 1).
+			Return(logger).
+			Times(1)
+		logger.EXPECT().
+			Warnf("subscriber overflow; dropped %T", gomock.Any()).
+			Times(1)
+
+		bus := eventbus.NewBus()
+		sub, err := internal.Subscribe(bus, new(keyedEvent), 2, internal.Drop(logger))
+		require.NoError(t, err)
+		defer sub.Close()
+
+		e, err := bus.Emitter(new(keyedEvent))
+		require.NoError(t, err)
+		defer e.Close()
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, e.Emit(keyedEvent{Key: i}))
+		}
+
+		time.Sleep(time.Millisecond * 10)
+
+		assert.Equal(t, keyedEvent{Key: 0}, <-sub.Out())
+		assert.Equal(t, keyedEvent{Key: 1}, <-sub.Out())
+
+		select {
+		case v := <-sub.Out():
+			t.Errorf("overflowing event delivered:  %v", v)
+		case <-time.After(time.Millisecond * 10):
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		t.Parallel()
+
+		bus := eventbus.NewBus()
+		sub, err := internal.Subscribe(bus, new(keyedEvent), 0, internal.Coalesce(byKey))
+		require.NoError(t, err)
+
+		e, err := bus.Emitter(new(keyedEvent))
+		require.NoError(t, err)
+		defer e.Close()
+
+		require.NoError(t, e.Emit(keyedEvent{}))
+		require.NoError(t, sub.Close())
+		require.NoError(t, sub.Close(), "Close should be idempotent")
+
+		select {
+		case _, ok := <-sub.Out():
+			if ok {
+				_, ok = <-sub.Out()
+			}
+			assert.False(t, ok, "Out should be closed")
+		case <-time.After(time.Second):
+			t.Error("Out not closed")
+		}
+	})
+}
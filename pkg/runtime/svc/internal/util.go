@@ -8,9 +8,69 @@ import (
 	"github.com/libp2p/go-libp2p-core/event"
 	"github.com/lthibault/jitterbug"
 	"github.com/pkg/errors"
+	ww "github.com/wetware/ww/pkg"
 	"github.com/wetware/ww/pkg/internal/p2p"
+	"github.com/wetware/ww/pkg/runtime"
 )
 
+// ErrEmitterClosed is returned by Emitter.Emit after the emitter has been closed.
+// Services may safely ignore it during shutdown.
+var ErrEmitterClosed = errors.New("emitter closed")
+
+// Logger returns a child of l carrying the fields of svc, or a logger that discards
+// all output if l is nil.  See ww.LoggerFor.
+func Logger(l ww.Logger, svc ww.Loggable) ww.Logger { return ww.LoggerFor(l, svc) }
+
+// Emitter wraps an event.Emitter such that no event is emitted after Close returns.
+// Calls to Emit after Close are no-ops that return ErrEmitterClosed, and Close is
+// idempotent.
+type Emitter struct {
+	mu     sync.RWMutex
+	closed bool
+	e      event.Emitter
+}
+
+// NewEmitter returns an Emitter for events of the specified type.
+func NewEmitter(bus event.Bus, evtType interface{}, opt ...event.EmitterOpt) (*Emitter, error) {
+	e, err := bus.Emitter(evtType, opt...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Emitter{e: e}, nil
+}
+
+// Emit the event to subscribers.
+//
+// Emit holds a read lock for the duration of the underlying emit, which blocks
+// while any subscriber's buffer is full.  Close waits for in-flight calls to Emit
+// to return, so it blocks for as long as a slow subscriber does.  This is what
+// guarantees that no event is delivered after Close returns; subscribers MUST keep
+// draining their channel until the emitter is closed.
+func (e *Emitter) Emit(ev interface{}) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.closed {
+		return ErrEmitterClosed
+	}
+
+	return e.e.Emit(ev)
+}
+
+// Close the emitter.
+func (e *Emitter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return nil
+	}
+
+	e.closed = true
+	return e.e.Close()
+}
+
 func WaitNetworkReady(ctx context.Context, bus event.Bus) error {
 	sub, err := bus.Subscribe(new(p2p.EvtNetworkReady))
 	if err != nil {
@@ -46,15 +106,19 @@ func (s *Scheduler) Reset() {
 	s.remaining = s.j.Jitter(s.d)
 }
 
-func StartBackground(fs ...func()) {
+// StartBackground runs each function in a goroutine, supervised by the runtime.  The
+// context MUST be the one passed to Service.Start.  See runtime.Go.
+func StartBackground(ctx context.Context, fs ...func()) {
 	var wg sync.WaitGroup
 	wg.Add(len(fs))
 	defer wg.Wait()
 
 	for _, f := range fs {
-		go func(f func()) {
-			wg.Done()
-			f()
-		}(f)
+		runtime.Go(ctx, func(f func()) func() {
+			return func() {
+				wg.Done()
+				f()
+			}
+		}(f))
 	}
 }
@@ -0,0 +1,115 @@
+package internal_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	eventbus "github.com/libp2p/go-eventbus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	mock_ww "github.com/wetware/ww/internal/test/mock/pkg"
+	"github.com/wetware/ww/pkg/runtime/svc/internal"
+)
+
+type testEvent struct{}
+
+func TestEmitter(t *testing.T) {
+	t.Parallel()
+
+	bus := eventbus.NewBus()
+
+	sub, err := bus.Subscribe(new(testEvent))
+	require.NoError(t, err)
+
+	var received int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range sub.Out() {
+			atomic.AddInt64(&received, 1)
+		}
+	}()
+
+	e, err := internal.NewEmitter(bus, new(testEvent))
+	require.NoError(t, err)
+
+	var (
+		wg               sync.WaitGroup
+		closed           int32
+		emitted, postErr int64
+	)
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 1000; j++ {
+				wasClosed := atomic.LoadInt32(&closed) == 1
+
+				switch err := e.Emit(testEvent{}); err {
+				case nil:
+					if wasClosed {
+						atomic.AddInt64(&postErr, 1)
+					}
+					atomic.AddInt64(&emitted, 1)
+				case internal.ErrEmitterClosed:
+				default:
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+		}()
+	}
+
+	require.NoError(t, e.Close())
+	atomic.StoreInt32(&closed, 1)
+	require.NoError(t, e.Close(), "Close should be idempotent")
+
+	wg.Wait()
+
+	// sub.Close drops buffered events, so wait for delivery before closing.
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt64(&received) == atomic.LoadInt64(&emitted)
+	}, time.Second, time.Millisecond, "emitted events were not received")
+
+	require.NoError(t, sub.Close())
+	<-done
+
+	assert.Zero(t, postErr, "events emitted after Close")
+	assert.Equal(t, internal.ErrEmitterClosed, e.Emit(testEvent{}))
+}
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Nil", func(t *testing.T) {
+		require.NotNil(t, internal.Logger(nil, loggable{}))
+		assert.NotPanics(t, func() {
+			internal.Logger(nil, loggable{}).WithField("foo", "bar").Error("discarded")
+		})
+	})
+
+	t.Run("Child", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		child := mock_ww.NewMockLogger(ctrl)
+		logger := mock_ww.NewMockLogger(ctrl)
+		logger.EXPECT().
+			With(loggable{}).
+			Return(child).
+			Times(1)
+
+		assert.Equal(t, child, internal.Logger(logger, loggable{}),
+			"should derive a child logger from the service's fields")
+	})
+}
+
+type loggable struct{}
+
+func (loggable) Loggable() map[string]interface{} {
+	return map[string]interface{}{"service": "test"}
+}
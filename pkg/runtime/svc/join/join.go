@@ -20,7 +20,7 @@ import (
 type Config struct {
 	fx.In
 
-	Log  ww.Logger
+	Log  ww.Logger `optional:"true"`
 	Host host.Host
 }
 
@@ -28,7 +28,6 @@ type Config struct {
 func (cfg Config) NewService() (_ runtime.Service, err error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	j := &joiner{
-		log:    cfg.Log,
 		h:      cfg.Host,
 		ctx:    ctx,
 		cancel: cancel,
@@ -39,6 +38,8 @@ func (cfg Config) NewService() (_ runtime.Service, err error) {
 		return
 	}
 
+	j.log = internal.Logger(cfg.Log, j)
+
 	return j, nil
 }
 
@@ -60,8 +61,8 @@ type Module struct {
 // in the merger of the local peer's graph and the remote peer's graph.
 //
 // Consumes:
-//	- p2p.EvtNetworkReady
-// 	- EvtPeerDiscovered
+//   - p2p.EvtNetworkReady
+//   - EvtPeerDiscovered
 func New(cfg Config) Module { return Module{Factory: cfg} }
 
 type joiner struct {
@@ -86,7 +87,7 @@ func (j joiner) Loggable() map[string]interface{} {
 // Start service
 func (j *joiner) Start(ctx context.Context) (err error) {
 	if err = internal.WaitNetworkReady(ctx, j.h.EventBus()); err == nil {
-		internal.StartBackground(
+		internal.StartBackground(ctx,
 			j.subloop,
 			j.joinloop,
 		)
@@ -135,8 +136,6 @@ func (j joiner) connect(info peer.AddrInfo) {
 	defer cancel()
 
 	if err := j.h.Connect(ctx, info); err != nil {
-		j.log.With(j).
-			WithError(err).
-			Debugf("unable to connct to %s", info.ID)
+		j.log.WithError(err).Debugf("unable to connct to %s", info.ID)
 	}
 }
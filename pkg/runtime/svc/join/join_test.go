@@ -35,7 +35,6 @@ func TestLoggable(t *testing.T) {
 	h := newMockHost(ctrl, bus)
 
 	j, err := join_service.New(join_service.Config{
-		Log:  mock_ww.NewMockLogger(ctrl),
 		Host: h,
 	}).Factory.NewService()
 	require.NoError(t, err)
@@ -56,12 +55,10 @@ func TestJoiner(t *testing.T) {
 		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 		defer cancel()
 
-		logger := mock_ww.NewMockLogger(ctrl)
 		bus := eventbus.NewBus()
 		h := newMockHost(ctrl, bus)
 
 		j, err := join_service.New(join_service.Config{
-			Log:  logger,
 			Host: h,
 		}).Factory.NewService()
 		require.NoError(t, err)
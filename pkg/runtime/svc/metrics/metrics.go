@@ -0,0 +1,348 @@
+// Package metrics implements a service that exports Prometheus metrics for the
+// runtime.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/fx"
+	"go.uber.org/multierr"
+
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/runtime"
+	"github.com/wetware/ww/pkg/runtime/svc/bootstrap"
+	"github.com/wetware/ww/pkg/runtime/svc/internal"
+	"github.com/wetware/ww/pkg/runtime/svc/neighborhood"
+)
+
+// Namespace of the metrics exported by wetware.
+const Namespace = "ww"
+
+// Source provides Prometheus collectors.  Modules add metrics to the runtime by
+// providing a Source to the "metrics" fx group, e.g.:
+//
+//	type Module struct {
+//		fx.Out
+//
+//		Factory runtime.ServiceFactory `group:"runtime"`
+//		Metrics metrics.Source         `group:"metrics"`
+//	}
+type Source interface {
+	Collectors() []prometheus.Collector
+}
+
+// Config for Metrics service.
+type Config struct {
+	fx.In
+
+	Log  ww.Logger `optional:"true"`
+	Host host.Host
+
+	// Addr on which /metrics is served.  If empty, metrics are collected but not
+	// served.
+	Addr string `name:"metrics_addr" optional:"true"`
+
+	Sources []Source `group:"metrics"`
+
+	// BufSize is the number of events buffered for the service.  Events that arrive
+	// while the buffer is full are dropped, so that connection storms do not stall
+	// their emitters.  It defaults to internal.DefaultBufSize.
+	BufSize int `name:"metrics_buffer" optional:"true"`
+}
+
+// NewService satisfies runtime.ServiceFactory
+func (cfg Config) NewService() (_ runtime.Service, err error) {
+	m := &metrics{
+		h:    cfg.Host,
+		addr: cfg.Addr,
+		reg:  prometheus.NewRegistry(),
+		c:    newCollectors(),
+	}
+
+	cs := append(m.c.Collectors(), prometheus.NewGoCollector())
+	for _, s := range cfg.Sources {
+		if s != nil {
+			cs = append(cs, s.Collectors()...)
+		}
+	}
+
+	for _, c := range cs {
+		if err = m.reg.Register(c); err != nil {
+			return
+		}
+	}
+
+	m.log = internal.Logger(cfg.Log, m)
+
+	if m.sub, err = internal.Subscribe(cfg.Host.EventBus(), []interface{}{
+		new(neighborhood.EvtNeighborhoodChanged),
+		new(event.EvtPeerConnectednessChanged),
+		new(runtime.EvtServiceRestarted),
+		new(runtime.EvtServiceFailed),
+		new(runtime.EvtServiceStateChanged),
+		new(bootstrap.EvtBootstrapAttempt),
+	}, cfg.BufSize, internal.Drop(m.log)); err != nil {
+		return
+	}
+
+	return m, nil
+}
+
+// Consumes neighborhood.EvtNeighborhoodChanged, event.EvtPeerConnectednessChanged,
+// runtime.EvtServiceRestarted, runtime.EvtServiceFailed,
+// runtime.EvtServiceStateChanged & bootstrap.EvtBootstrapAttempt.
+func (cfg Config) Consumes() []interface{} {
+	return []interface{}{
+		neighborhood.EvtNeighborhoodChanged{},
+		event.EvtPeerConnectednessChanged{},
+		runtime.EvtServiceRestarted{},
+		runtime.EvtServiceFailed{},
+		runtime.EvtServiceStateChanged{},
+		bootstrap.EvtBootstrapAttempt{},
+	}
+}
+
+// Module for Metrics service.
+type Module struct {
+	fx.Out
+
+	Factory runtime.ServiceFactory `group:"runtime"`
+}
+
+// New Metrics service.  The service records the neighborhood's size and phase, peer
+// connections, service restarts, failures and state transitions, and boot attempts, along with the
+// collectors of each Source.  Metrics are served in the Prometheus text format at
+// /metrics on Addr, which is bound when the service starts.
+//
+// Consumes:
+//   - neighborhood.EvtNeighborhoodChanged
+//   - event.EvtPeerConnectednessChanged [ libp2p ]
+//   - runtime.EvtServiceRestarted
+//   - runtime.EvtServiceFailed
+//   - runtime.EvtServiceStateChanged
+//   - bootstrap.EvtBootstrapAttempt
+func New(cfg Config) Module { return Module{Factory: cfg} }
+
+type metrics struct {
+	log ww.Logger
+	h   host.Host
+
+	addr string
+	l    net.Listener
+	srv  *http.Server
+
+	reg *prometheus.Registry
+	c   collectors
+	sub event.Subscription
+}
+
+func (m *metrics) Loggable() map[string]interface{} {
+	addr := m.addr
+	if m.l != nil {
+		addr = m.l.Addr().String()
+	}
+
+	return map[string]interface{}{
+		"service":      "metrics",
+		"metrics_addr": addr,
+	}
+}
+
+func (m *metrics) Start(ctx context.Context) (err error) {
+	if m.addr == "" {
+		internal.StartBackground(ctx, m.subloop)
+		return
+	}
+
+	if m.l, err = net.Listen("tcp", m.addr); err != nil {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.reg, promhttp.HandlerOpts{}))
+	m.srv = &http.Server{Handler: mux}
+
+	internal.StartBackground(ctx,
+		m.subloop,
+		m.serve,
+	)
+
+	return
+}
+
+func (m *metrics) Stop(ctx context.Context) error {
+	var err error
+	if m.srv != nil {
+		err = m.srv.Shutdown(ctx)
+	}
+
+	return multierr.Combine(
+		err,
+		m.sub.Close(),
+	)
+}
+
+func (m *metrics) serve() {
+	if err := m.srv.Serve(m.l); err != http.ErrServerClosed {
+		m.log.WithError(err).Error("metrics server failed")
+	}
+}
+
+func (m *metrics) subloop() {
+	for v := range m.sub.Out() {
+		switch ev := v.(type) {
+		case neighborhood.EvtNeighborhoodChanged:
+			m.c.peers.Set(float64(ev.K))
+			for p, name := range phases {
+				m.c.phase.WithLabelValues(name).Set(boolToFloat(p == ev.To))
+			}
+
+		case event.EvtPeerConnectednessChanged:
+			switch ev.Connectedness {
+			case network.Connected:
+				m.c.connects.Inc()
+			case network.NotConnected:
+				m.c.disconnects.Inc()
+			}
+
+		case runtime.EvtServiceRestarted:
+			m.c.restarts.WithLabelValues(serviceName(ev.Service)).Inc()
+
+		case runtime.EvtServiceFailed:
+			m.c.failures.WithLabelValues(serviceName(ev.Service)).Inc()
+
+		case runtime.EvtServiceStateChanged:
+			m.c.transitions.WithLabelValues(serviceName(ev.Service), ev.To.String()).Inc()
+
+		case bootstrap.EvtBootstrapAttempt:
+			m.c.boot.WithLabelValues(outcome(ev)).Inc()
+			m.c.found.Add(float64(ev.Found))
+		}
+	}
+}
+
+var phases = map[neighborhood.Phase]string{
+	neighborhood.PhaseOrphaned:   "orphaned",
+	neighborhood.PhasePartial:    "partial",
+	neighborhood.PhaseComplete:   "complete",
+	neighborhood.PhaseOverloaded: "overloaded",
+}
+
+type collectors struct {
+	peers                 prometheus.Gauge
+	phase                 *prometheus.GaugeVec
+	connects, disconnects prometheus.Counter
+	restarts, failures    *prometheus.CounterVec
+	transitions           *prometheus.CounterVec
+	boot                  *prometheus.CounterVec
+	found                 prometheus.Counter
+}
+
+func newCollectors() collectors {
+	return collectors{
+		peers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Subsystem: "neighborhood",
+			Name:      "peers",
+			Help:      "Number of connected peers.",
+		}),
+		phase: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Subsystem: "neighborhood",
+			Name:      "phase",
+			Help:      "Phase of the neighborhood; 1 for the current phase, 0 otherwise.",
+		}, []string{"phase"}),
+		connects: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "peer",
+			Name:      "connects_total",
+			Help:      "Number of peers that became connected.",
+		}),
+		disconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "peer",
+			Name:      "disconnects_total",
+			Help:      "Number of peers that became disconnected.",
+		}),
+		restarts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "service",
+			Name:      "restarts_total",
+			Help:      "Number of times a runtime service was restarted after failing.",
+		}, []string{"service"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "service",
+			Name:      "failures_total",
+			Help:      "Number of runtime services abandoned after failing.",
+		}, []string{"service"}),
+		transitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "service",
+			Name:      "transitions_total",
+			Help:      "Number of times a runtime service entered each lifecycle state.",
+		}, []string{"service", "state"}),
+		boot: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "boot",
+			Name:      "attempts_total",
+			Help:      "Number of bootstrap attempts, by outcome.",
+		}, []string{"outcome"}),
+		found: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "boot",
+			Name:      "peers_found_total",
+			Help:      "Number of peers returned by the boot strategy.",
+		}),
+	}
+}
+
+func (c collectors) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.peers,
+		c.phase,
+		c.connects,
+		c.disconnects,
+		c.restarts,
+		c.failures,
+		c.transitions,
+		c.boot,
+		c.found,
+	}
+}
+
+func outcome(ev bootstrap.EvtBootstrapAttempt) string {
+	switch {
+	case ev.Err != nil:
+		return "error"
+	case ev.Succeeded():
+		return "succeeded"
+	default:
+		return "failed"
+	}
+}
+
+func serviceName(loggable map[string]interface{}) string {
+	if name, ok := loggable["service"]; ok {
+		return fmt.Sprint(name)
+	}
+
+	return "unknown"
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,155 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/network"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+
+	logutil "github.com/wetware/ww/internal/util/log"
+	"github.com/wetware/ww/pkg/internal/rpc"
+	"github.com/wetware/ww/pkg/runtime"
+	"github.com/wetware/ww/pkg/runtime/svc/bootstrap"
+	metrics_service "github.com/wetware/ww/pkg/runtime/svc/metrics"
+	neighborhood_service "github.com/wetware/ww/pkg/runtime/svc/neighborhood"
+)
+
+func TestMetricsLoggable(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h, err := mocknet.New(ctx).GenPeer()
+	require.NoError(t, err)
+
+	m, err := metrics_service.New(metrics_service.Config{
+		Log:  logutil.Nop(),
+		Host: h,
+	}).Factory.NewService()
+	require.NoError(t, err)
+
+	assert.Equal(t, "metrics", m.Loggable()["service"])
+	assert.Empty(t, m.Loggable()["metrics_addr"], "metrics should not be served")
+}
+
+func TestMetricsScrape(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	h, err := mocknet.New(ctx).GenPeer()
+	require.NoError(t, err)
+
+	instrument := metrics_service.NewRPC()
+	custom := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "test_custom_total",
+		Help: "Counter provided by a Source.",
+	})
+
+	m, err := metrics_service.New(metrics_service.Config{
+		Log:     logutil.Nop(),
+		Host:    h,
+		Addr:    "127.0.0.1:0",
+		Sources: []metrics_service.Source{instrument, source{custom}},
+	}).Factory.NewService()
+	require.NoError(t, err)
+
+	require.NoError(t, m.Start(ctx))
+	defer func() {
+		require.NoError(t, m.Stop(ctx))
+	}()
+
+	emit(t, h.EventBus(), neighborhood_service.EvtNeighborhoodChanged{
+		K:  3,
+		To: neighborhood_service.PhaseComplete,
+	})
+	emit(t, h.EventBus(), event.EvtPeerConnectednessChanged{
+		Connectedness: network.Connected,
+	})
+	emit(t, h.EventBus(), runtime.EvtServiceRestarted{
+		Service:  map[string]interface{}{"service": "test"},
+		Restarts: 1,
+	})
+	emit(t, h.EventBus(), runtime.EvtServiceStateChanged{
+		Service: map[string]interface{}{"service": "test"},
+		From:    runtime.StateStarting,
+		To:      runtime.StateRunning,
+	})
+	emit(t, h.EventBus(), bootstrap.EvtBootstrapAttempt{
+		Attempt:   1,
+		Found:     2,
+		Connected: 1,
+	})
+
+	rpc.StartCall(instrument, "/test", "ping")(nil)
+	rpc.StartCall(instrument, "/test", "ping")(errors.New("test"))
+	custom.Inc()
+
+	url := "http://" + m.Loggable()["metrics_addr"].(string) + "/metrics"
+
+	want := []string{
+		"ww_neighborhood_peers 3",
+		`ww_neighborhood_phase{phase="complete"} 1`,
+		`ww_neighborhood_phase{phase="partial"} 0`,
+		"ww_peer_connects_total 1",
+		`ww_service_restarts_total{service="test"} 1`,
+		`ww_service_transitions_total{service="test",state="running"} 1`,
+		`ww_boot_attempts_total{outcome="succeeded"} 1`,
+		"ww_boot_peers_found_total 2",
+		`ww_rpc_calls_total{method="ping",outcome="ok",protocol="/test"} 1`,
+		`ww_rpc_calls_total{method="ping",outcome="error",protocol="/test"} 1`,
+		`ww_rpc_in_flight{method="ping",protocol="/test"} 0`,
+		"test_custom_total 1",
+		"go_goroutines",
+	}
+
+	require.Eventually(t, func() bool {
+		body := scrape(t, url)
+		for _, line := range want {
+			if !strings.Contains(body, line) {
+				return false
+			}
+		}
+
+		return true
+	}, time.Second*2, time.Millisecond*10, "metrics not exported")
+}
+
+type source []prometheus.Collector
+
+func (s source) Collectors() []prometheus.Collector { return s }
+
+func emit(t *testing.T, bus event.Bus, ev interface{}) {
+	e, err := bus.Emitter(reflect.New(reflect.TypeOf(ev)).Interface())
+	require.NoError(t, err)
+	defer e.Close()
+
+	require.NoError(t, e.Emit(ev))
+}
+
+func scrape(t *testing.T, url string) string {
+	res, err := http.Get(url)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	b, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	return string(b)
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/wetware/ww/pkg/internal/rpc"
+)
+
+var _ rpc.Instrument = (*RPC)(nil)
+
+// RPC is an rpc.Instrument that records Prometheus metrics for RPC calls, such as
+// anchor operations.  Dials are recorded under the method name "dial".  It is a Source.
+type RPC struct {
+	calls    *prometheus.CounterVec
+	inflight *prometheus.GaugeVec
+	latency  *prometheus.HistogramVec
+}
+
+// NewRPC returns an RPC instrument.
+func NewRPC() *RPC {
+	buckets := make([]float64, len(rpc.LatencyBuckets))
+	for i, d := range rpc.LatencyBuckets {
+		buckets[i] = d.Seconds()
+	}
+
+	return &RPC{
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "rpc",
+			Name:      "calls_total",
+			Help:      "Number of RPC calls, by protocol, method and outcome.",
+		}, []string{"protocol", "method", "outcome"}),
+		inflight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Subsystem: "rpc",
+			Name:      "in_flight",
+			Help:      "Number of RPC calls in progress.",
+		}, []string{"protocol", "method"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Subsystem: "rpc",
+			Name:      "duration_seconds",
+			Help:      "Latency of RPC calls.",
+			Buckets:   buckets,
+		}, []string{"protocol", "method"}),
+	}
+}
+
+// Collectors satisfies Source.
+func (r *RPC) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{r.calls, r.inflight, r.latency}
+}
+
+// OnDialStart increments the in-flight gauge for dials.
+func (r *RPC) OnDialStart(pid protocol.ID) { r.OnCallStart(pid, "dial") }
+
+// OnDialDone records the dial.
+func (r *RPC) OnDialDone(pid protocol.ID, d time.Duration, err error) {
+	r.OnCallDone(pid, "dial", d, err)
+}
+
+// OnCallStart increments the in-flight gauge for the method.
+func (r *RPC) OnCallStart(pid protocol.ID, method string) {
+	r.inflight.WithLabelValues(string(pid), method).Inc()
+}
+
+// OnCallDone records the call's latency and outcome.
+func (r *RPC) OnCallDone(pid protocol.ID, method string, d time.Duration, err error) {
+	r.inflight.WithLabelValues(string(pid), method).Dec()
+	r.latency.WithLabelValues(string(pid), method).Observe(d.Seconds())
+
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+
+	r.calls.WithLabelValues(string(pid), method, outcome).Inc()
+}
@@ -0,0 +1,84 @@
+package neighborhood
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	eventbus "github.com/libp2p/go-eventbus"
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/network"
+
+	mock_ww "github.com/wetware/ww/internal/test/mock/pkg"
+)
+
+func TestEmitFailure(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	errTest := errors.New("test")
+	logged := make(chan struct{})
+
+	logger := mock_ww.NewMockLogger(ctrl)
+	logger.EXPECT().
+		With(gomock.Any()).
+		Return(logger).
+		Times(1)
+
+	logger.EXPECT().
+		WithError(errTest).
+		Return(logger).
+		Times(1)
+
+	logger.EXPECT().
+		Error("failed to emit EvtNeighborhoodChanged").
+		Do(func(...interface{}) { close(logged) }).
+		Times(1)
+
+	bus := eventbus.NewBus()
+	svc, err := New(Config{
+		Log:      logger,
+		Bus:      bus,
+		KMin:     1,
+		KMax:     2,
+		Debounce: time.Millisecond,
+	}).Factory.NewService()
+	require.NoError(t, err)
+
+	n := svc.(neighborhood)
+	n.e = failingEmitter{err: errTest}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		n.subloop()
+	}()
+
+	e, err := bus.Emitter(new(event.EvtPeerConnectednessChanged))
+	require.NoError(t, err)
+	defer e.Close()
+
+	require.NoError(t, e.Emit(event.EvtPeerConnectednessChanged{
+		Peer:          "test",
+		Connectedness: network.Connected,
+	}))
+
+	select {
+	case <-logged:
+	case <-time.After(time.Second):
+		t.Fatal("emitter error was not logged")
+	}
+
+	close(n.cq)
+	<-done
+}
+
+type failingEmitter struct{ err error }
+
+func (e failingEmitter) Emit(interface{}) error { return e.err }
+func (e failingEmitter) Close() error           { return nil }
@@ -3,7 +3,10 @@ package neighborhood
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
+	"time"
 
+	"github.com/ipfs/go-datastore"
 	"github.com/libp2p/go-eventbus"
 	"github.com/libp2p/go-libp2p-core/event"
 	"github.com/libp2p/go-libp2p-core/network"
@@ -15,40 +18,60 @@ import (
 	"go.uber.org/multierr"
 )
 
+// DefaultDebounce is the default window over which changes in connectivity are
+// coalesced.
+const DefaultDebounce = time.Millisecond * 10
+
 // Config for Neighborhood service
 type Config struct {
 	fx.In
 
+	Log  ww.Logger `optional:"true"`
 	Bus  event.Bus
 	KMin int `name:"kmin"`
 	KMax int `name:"kmax"`
-}
 
-// NewService satisfies runtime.ServiceFactory
-func (cfg Config) NewService() (runtime.Service, error) {
-	sub, err := cfg.Bus.Subscribe(new(event.EvtPeerConnectednessChanged))
-	if err != nil {
-		return nil, err
-	}
+	// Margin by which the number of peers must cross a phase boundary before the phase
+	// changes.  HoldDown is the time for which a phase change must persist before it
+	// takes effect.  Both default to zero, i.e. no hysteresis.  Transitions to and from
+	// PhaseOrphaned are never damped.
+	Margin   int           `name:"neighborhood_margin" optional:"true"`
+	HoldDown time.Duration `name:"neighborhood_hold_down" optional:"true"`
 
-	e, err := cfg.Bus.Emitter(new(EvtNeighborhoodChanged), eventbus.Stateful)
-	if err != nil {
-		return nil, err
-	}
+	// Debounce is the window over which bursts of connectivity changes are coalesced
+	// into a single event.  It defaults to DefaultDebounce.
+	Debounce time.Duration `name:"neighborhood_debounce" optional:"true"`
 
-	return neighborhood{
-		phaseMap: phasemap(cfg.KMin, cfg.KMax),
-		bus:      cfg.Bus,
-		sub:      sub,
-		e:        e,
-		cq:       make(chan struct{}),
-	}, nil
+	// BufSize is the number of peers whose pending connectedness changes are
+	// buffered.  Changes to the same peer are coalesced.  It defaults to
+	// internal.DefaultBufSize.
+	BufSize int `name:"neighborhood_buffer" optional:"true"`
+
+	// Datastore in which changes to the watermarks are persisted.  If nil, changes
+	// are lost when the host restarts.
+	Datastore datastore.Batching `optional:"true"`
 }
 
-// Produces EvtNeighborhoodChanged.
+// Produces EvtNeighborhoodChanged & EvtWaterMarksChanged.
 func (cfg Config) Produces() []interface{} {
 	return []interface{}{
 		EvtNeighborhoodChanged{},
+		EvtWaterMarksChanged{},
+	}
+}
+
+// Stateful EvtNeighborhoodChanged & EvtWaterMarksChanged.
+func (cfg Config) Stateful() []interface{} {
+	return []interface{}{
+		EvtNeighborhoodChanged{},
+		EvtWaterMarksChanged{},
+	}
+}
+
+// Public EvtWaterMarksChanged, which clients do not consume.
+func (cfg Config) Public() []interface{} {
+	return []interface{}{
+		EvtWaterMarksChanged{},
 	}
 }
 
@@ -59,40 +82,128 @@ func (cfg Config) Consumes() []interface{} {
 	}
 }
 
+// External event.EvtPeerConnectednessChanged, which libp2p may also emit.
+func (cfg Config) External() []interface{} {
+	return []interface{}{
+		event.EvtPeerConnectednessChanged{},
+	}
+}
+
 // Module for Neighborhood service
 type Module struct {
 	fx.Out
 
-	Factory runtime.ServiceFactory `group:"runtime"`
+	Factory    runtime.ServiceFactory `group:"runtime"`
+	WaterMarks *WaterMarks
 }
 
-// EvtNeighborhoodChanged fires when a graph edge is created or destroyed
+// EvtNeighborhoodChanged fires when a graph edge is created or destroyed.  Bursts of
+// changes are coalesced, and phase transitions may be damped, but K is always the
+// number of connected peers at the time of the event.
 type EvtNeighborhoodChanged struct {
 	K        int
 	From, To Phase
 }
 
-// New Neighborhood service.  Maintains graph connectivity.
+// Snapshot of the neighborhood, reported by the service's runtime.Snapshotter.
+type Snapshot struct {
+	EvtNeighborhoodChanged
+	EvtWaterMarksChanged
+}
+
+// New Neighborhood service.  Maintains graph connectivity.  Changes in connectivity are
+// debounced, and phase transitions can be damped with Margin and HoldDown, so that
+// a peer that repeatedly connects and disconnects does not cause churn downstream.
 //
 // Consumes:
-//  - p2p.EvtNetworkReady
-// 	- event.EvtPeerConnectednessChanged [ libp2p ]
+//   - p2p.EvtNetworkReady
+//   - event.EvtPeerConnectednessChanged [ libp2p ]
+//
+// The watermarks can be changed at runtime through the module's WaterMarks, which
+// are persisted to the Datastore.  Persisted watermarks take precedence over KMin and
+// KMax.
 //
 // Emits:
-//	- EvtNeighborhoodChanged
-func New(cfg Config) Module { return Module{Factory: cfg} }
+//   - EvtNeighborhoodChanged
+//   - EvtWaterMarksChanged
+func New(cfg Config) Module {
+	var ds datastore.Datastore
+	if cfg.Datastore != nil {
+		ds = cfg.Datastore
+	}
+
+	wm := newWaterMarks(cfg.KMin, cfg.KMax, ds)
+	return Module{
+		Factory:    factory{Config: cfg, wm: wm},
+		WaterMarks: wm,
+	}
+}
+
+type factory struct {
+	Config
+	wm *WaterMarks
+}
+
+// NewService satisfies runtime.ServiceFactory
+func (f factory) NewService() (runtime.Service, error) {
+	if err := f.wm.load(); err != nil {
+		return nil, err
+	}
+
+	sub, err := internal.Subscribe(f.Bus, new(event.EvtPeerConnectednessChanged), f.BufSize,
+		internal.Coalesce(byPeer))
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := internal.NewEmitter(f.Bus, new(EvtNeighborhoodChanged), eventbus.Stateful)
+	if err != nil {
+		return nil, err
+	}
+
+	ew, err := internal.NewEmitter(f.Bus, new(EvtWaterMarksChanged), eventbus.Stateful)
+	if err != nil {
+		return nil, err
+	}
+
+	debounce := f.Debounce
+	if debounce == 0 {
+		debounce = DefaultDebounce
+	}
+
+	n := neighborhood{
+		wm:       f.wm,
+		margin:   f.Margin,
+		hold:     f.HoldDown,
+		debounce: debounce,
+		bus:      f.Bus,
+		sub:      sub,
+		e:        e,
+		ew:       ew,
+		state:    new(atomic.Value),
+		cq:       make(chan struct{}),
+	}
+	n.state.Store(EvtNeighborhoodChanged{})
+	n.log = internal.Logger(f.Log, n)
+
+	return n, nil
+}
 
 // neighborhood notifies subscribers of changes in direct connectivity to remote
 // hosts.  Neighborhood events do not concern themselves with the number of connections,
 // but rather the presence or absence of a direct link.
 type neighborhood struct {
 	log ww.Logger
-	phaseMap
+	wm  *WaterMarks
+
+	margin         int
+	hold, debounce time.Duration
 
-	bus event.Bus
-	sub event.Subscription
-	e   event.Emitter
-	cq  chan struct{}
+	bus   event.Bus
+	sub   event.Subscription
+	e, ew event.Emitter
+	state *atomic.Value // latest EvtNeighborhoodChanged, published by subloop
+	cq    chan struct{}
 }
 
 func (n neighborhood) Loggable() map[string]interface{} {
@@ -101,10 +212,12 @@ func (n neighborhood) Loggable() map[string]interface{} {
 
 func (n neighborhood) Start(ctx context.Context) (err error) {
 	if err = internal.WaitNetworkReady(ctx, n.bus); err == nil {
-		internal.StartBackground(n.subloop)
+		internal.StartBackground(ctx, n.subloop)
 
 		// signal initial state - PhaseOrphaned
-		err = n.e.Emit(EvtNeighborhoodChanged{})
+		if err = n.e.Emit(EvtNeighborhoodChanged{}); err == nil {
+			err = n.emitWaterMarks()
+		}
 	}
 
 	return
@@ -116,31 +229,170 @@ func (n neighborhood) Stop(context.Context) error {
 	return multierr.Combine(
 		n.sub.Close(),
 		n.e.Close(),
+		n.ew.Close(),
 	)
 }
 
+// Snapshot satisfies runtime.Snapshotter.
+func (n neighborhood) Snapshot() interface{} {
+	kmin, kmax := n.wm.Get()
+	return Snapshot{
+		EvtNeighborhoodChanged: n.state.Load().(EvtNeighborhoodChanged),
+		EvtWaterMarksChanged:   EvtWaterMarksChanged{KMin: kmin, KMax: kmax},
+	}
+}
+
+func (n neighborhood) emitWaterMarks() error {
+	kmin, kmax := n.wm.Get()
+	return n.ew.Emit(EvtWaterMarksChanged{KMin: kmin, KMax: kmax})
+}
+
 func (n neighborhood) subloop() {
-	var state EvtNeighborhoodChanged
-	var ps = make(map[peer.ID]struct{})
-
-	for v := range n.sub.Out() {
-		switch ev := v.(event.EvtPeerConnectednessChanged); ev.Connectedness {
-		case network.Connected:
-			ps[ev.Peer] = struct{}{}
-		case network.NotConnected:
-			delete(ps, ev.Peer)
-		default:
-			panic("Unreachable ... unless libp2p has fixed event.PeerConnectednessChanged!!")
+	var (
+		state EvtNeighborhoodChanged
+		ps    = make(map[peer.ID]struct{})
+		d     = damper{phaseMap: n.wm.phaseMap(), margin: n.margin, hold: n.hold}
+
+		flush  <-chan time.Time // fires at the end of the debounce window
+		settle <-chan time.Time // fires when a held phase change is due
+
+		reconfigured bool // watermarks changed; re-emit even if the phase has not
+	)
+
+	for {
+		select {
+		case v, ok := <-n.sub.Out():
+			if !ok {
+				return
+			}
+
+			switch ev := v.(event.EvtPeerConnectednessChanged); ev.Connectedness {
+			case network.Connected:
+				ps[ev.Peer] = struct{}{}
+			case network.NotConnected:
+				delete(ps, ev.Peer)
+			default:
+				panic("Unreachable ... unless libp2p has fixed event.PeerConnectednessChanged!!")
+			}
+
+			if flush == nil {
+				flush = time.After(n.debounce)
+			}
+
+			continue
+		case <-flush:
+			flush = nil
+		case <-settle:
+			settle = nil
+		case <-n.wm.bump:
+			d.Reset(n.wm.phaseMap(), len(ps))
+			settle = nil
+
+			if err := n.emitWaterMarks(); err != nil && err != internal.ErrEmitterClosed {
+				n.log.WithError(err).Error("failed to emit EvtWaterMarksChanged")
+			}
+
+			reconfigured = true
+		case <-n.cq:
+			return
+		}
+
+		phase, due := d.Update(len(ps), time.Now())
+		if !due.IsZero() {
+			settle = time.After(time.Until(due))
 		}
 
+		if !reconfigured && len(ps) == state.K && phase == state.To {
+			continue
+		}
+
+		reconfigured = false
+
 		state.K = len(ps)
 		state.From = state.To
-		state.To = n.Phase(len(ps))
+		state.To = phase
+		n.state.Store(state)
+
+		select {
+		case <-n.cq:
+			return
+		default:
+		}
+
+		if err := n.e.Emit(state); err != nil && err != internal.ErrEmitterClosed {
+			n.log.WithError(err).Error("failed to emit EvtNeighborhoodChanged")
+		}
+	}
+}
+
+func byPeer(ev interface{}) interface{} {
+	return ev.(event.EvtPeerConnectednessChanged).Peer
+}
+
+// damper applies hysteresis to phase transitions.
+type damper struct {
+	phaseMap
+	margin int
+	hold   time.Duration
+
+	phase   Phase
+	pending Phase     // phase awaiting the hold-down
+	since   time.Time // zero if no change is pending
+}
+
+// Reset the phase map, and move to the phase of k immediately, discarding any pending
+// change.
+func (d *damper) Reset(pm phaseMap, k int) {
+	d.phaseMap = pm
+	d.phase, d.since = d.Phase(k), time.Time{}
+}
 
-		if err := n.e.Emit(state); err != nil {
-			n.log.With(n).WithError(err).Error("failed to emit EvtNeighborhoodChanged")
+// Update the number of peers, and return the current phase.  If a phase change is
+// held down, Update returns the time at which it should be called again.
+func (d *damper) Update(k int, now time.Time) (_ Phase, due time.Time) {
+	target := d.target(k)
+
+	switch {
+	case target == d.phase:
+		d.since = time.Time{}
+	case d.hold == 0, target == PhaseOrphaned, d.phase == PhaseOrphaned:
+		d.phase, d.since = target, time.Time{}
+	case d.since.IsZero(), target != d.pending:
+		d.pending, d.since = target, now
+		due = now.Add(d.hold)
+	case now.Sub(d.since) >= d.hold:
+		d.phase, d.since = target, time.Time{}
+	default:
+		due = d.since.Add(d.hold)
+	}
+
+	return d.phase, due
+}
+
+// target returns the phase of k, once k has crossed the boundary of the current phase
+// by the margin.
+func (d *damper) target(k int) Phase {
+	p := d.Phase(k)
+	if k == 0 || d.phase == PhaseOrphaned || d.margin == 0 {
+		return p
+	}
+
+	switch {
+	case p > d.phase:
+		if k -= d.margin; k < 0 {
+			k = 0
+		}
+
+		if p = d.Phase(k); p > d.phase {
+			return p
+		}
+	case p < d.phase:
+		if p = d.Phase(k + d.margin); p < d.phase {
+			return p
 		}
 	}
+
+	return d.phase
 }
 
 // Phase is the codomain in the function ƒ: C ⟼ P,
@@ -2,6 +2,9 @@ package neighborhood_test
 
 import (
 	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -10,11 +13,13 @@ import (
 	"github.com/stretchr/testify/require"
 	testutil "github.com/wetware/ww/internal/test/util"
 
+	"github.com/ipfs/go-datastore"
 	eventbus "github.com/libp2p/go-eventbus"
 	"github.com/libp2p/go-libp2p-core/event"
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/wetware/ww/pkg/internal/p2p"
+	"github.com/wetware/ww/pkg/runtime"
 	neighborhood_service "github.com/wetware/ww/pkg/runtime/svc/neighborhood"
 )
 
@@ -176,6 +181,422 @@ func TestNeighborhoodPhase(t *testing.T) {
 	})
 }
 
+func TestNeighborhoodShutdown(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	bus := eventbus.NewBus()
+
+	n, err := neighborhood_service.New(neighborhood_service.Config{
+		Bus:  bus,
+		KMin: kmin,
+		KMax: kmax,
+	}).Factory.NewService()
+	require.NoError(t, err)
+
+	require.NoError(t, netReady(bus))
+	require.NoError(t, n.Start(ctx))
+
+	sub, err := bus.Subscribe(new(neighborhood_service.EvtNeighborhoodChanged))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	var stopped int32
+	var postStop int64
+	go func() {
+		for range sub.Out() {
+			if atomic.LoadInt32(&stopped) == 1 {
+				atomic.AddInt64(&postStop, 1)
+			}
+		}
+	}()
+
+	e, err := bus.Emitter(new(event.EvtPeerConnectednessChanged))
+	require.NoError(t, err)
+	defer e.Close()
+
+	// flood the service with events while it is shutting down
+	var wg sync.WaitGroup
+	flood := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			id := testutil.RandID()
+			for c := network.Connected; ; c = network.Connected + network.NotConnected - c {
+				select {
+				case <-flood:
+					return
+				default:
+					e.Emit(evtPeerConnectednessChanged(id, c))
+				}
+			}
+		}()
+	}
+
+	time.Sleep(time.Millisecond * 10)
+	require.NoError(t, n.Stop(ctx))
+
+	// Drain events that were emitted before Stop returned, then check that no more
+	// events are delivered.
+	time.Sleep(time.Millisecond * 10)
+	atomic.StoreInt32(&stopped, 1)
+	time.Sleep(time.Millisecond * 50)
+
+	close(flood)
+	wg.Wait()
+
+	assert.Zero(t, atomic.LoadInt64(&postStop), "events delivered after Stop")
+}
+
+func TestNeighborhoodFlapping(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		desc string
+		cfg  neighborhood_service.Config
+		want []neighborhood_service.Phase // distinct phases reported while flapping
+	}{
+		{"Debounce", neighborhood_service.Config{}, nil},
+		{"Margin", neighborhood_service.Config{Margin: 1}, []neighborhood_service.Phase{
+			neighborhood_service.PhasePartial,
+		}},
+		{"HoldDown", neighborhood_service.Config{HoldDown: time.Second}, []neighborhood_service.Phase{
+			neighborhood_service.PhasePartial,
+		}},
+	} {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			sub, e := startNeighborhood(ctx, t, tt.cfg)
+
+			// two stable peers
+			for i := 0; i < 2; i++ {
+				require.NoError(t, e.Emit(evtPeerConnectednessChanged(testutil.RandID(), network.Connected)))
+			}
+			ev := waitK(ctx, t, sub, 2)
+			assert.Equal(t, neighborhood_service.PhasePartial, ev.To)
+
+			// a third peer flaps across kmin
+			const duration = time.Millisecond * 100
+			flapper := testutil.RandID()
+			deadline := time.Now().Add(duration)
+			for c := network.Connected; time.Now().Before(deadline); c = network.Connected + network.NotConnected - c {
+				require.NoError(t, e.Emit(evtPeerConnectednessChanged(flapper, c)))
+			}
+			require.NoError(t, e.Emit(evtPeerConnectednessChanged(flapper, network.Connected)))
+
+			ev = waitK(ctx, t, sub, 3)
+			if tt.want == nil {
+				assert.Equal(t, neighborhood_service.PhaseComplete, ev.To)
+			}
+
+			// drain
+			time.Sleep(neighborhood_service.DefaultDebounce * 2)
+			var evs []neighborhood_service.EvtNeighborhoodChanged
+			for len(sub.Out()) > 0 {
+				evs = append(evs, (<-sub.Out()).(neighborhood_service.EvtNeighborhoodChanged))
+			}
+			evs = append(evs, ev)
+
+			// debounce bounds the number of events
+			assert.True(t, len(evs) <= int(duration/neighborhood_service.DefaultDebounce)+2,
+				"%d events emitted while flapping", len(evs))
+
+			if tt.want != nil {
+				for _, ev := range evs {
+					assert.Contains(t, tt.want, ev.To, "phase should be damped")
+				}
+			}
+		})
+	}
+}
+
+func TestNeighborhoodHysteresis(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	sub, e := startNeighborhood(ctx, t, neighborhood_service.Config{
+		Margin:   1,
+		Debounce: time.Millisecond,
+	})
+
+	pids := make([]peer.ID, 5)
+	for i := range pids {
+		pids[i] = testutil.RandID()
+	}
+
+	for _, tt := range []struct {
+		id peer.ID
+		c  network.Connectedness
+		k  int
+		to neighborhood_service.Phase
+	}{
+		{pids[0], network.Connected, 1, neighborhood_service.PhasePartial}, // orphaned is not damped
+		{pids[1], network.Connected, 2, neighborhood_service.PhasePartial},
+		{pids[2], network.Connected, 3, neighborhood_service.PhasePartial}, // kmin, but within margin
+		{pids[3], network.Connected, 4, neighborhood_service.PhaseComplete},
+		{pids[4], network.Connected, 5, neighborhood_service.PhaseComplete}, // kmax+1, but within margin
+		{pids[4], network.NotConnected, 4, neighborhood_service.PhaseComplete},
+		{pids[3], network.NotConnected, 3, neighborhood_service.PhaseComplete},
+		{pids[2], network.NotConnected, 2, neighborhood_service.PhaseComplete}, // within margin
+		{pids[1], network.NotConnected, 1, neighborhood_service.PhasePartial},
+		{pids[0], network.NotConnected, 0, neighborhood_service.PhaseOrphaned},
+	} {
+		require.NoError(t, e.Emit(evtPeerConnectednessChanged(tt.id, tt.c)))
+
+		ev := waitK(ctx, t, sub, tt.k)
+		assert.Equal(t, tt.to, ev.To, "k=%d", tt.k)
+	}
+
+	t.Run("HoldDown", func(t *testing.T) {
+		sub, e := startNeighborhood(ctx, t, neighborhood_service.Config{
+			HoldDown: time.Millisecond * 50,
+			Debounce: time.Millisecond,
+		})
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, e.Emit(evtPeerConnectednessChanged(pids[i], network.Connected)))
+		}
+
+		// the transition to PhaseComplete takes effect after the hold-down
+		ev := waitK(ctx, t, sub, 3)
+		if ev.To != neighborhood_service.PhaseComplete {
+			assert.Equal(t, neighborhood_service.PhasePartial, ev.To)
+
+			ev = next(ctx, t, sub)
+			assert.Equal(t, 3, ev.K)
+			assert.Equal(t, neighborhood_service.PhasePartial, ev.From)
+			assert.Equal(t, neighborhood_service.PhaseComplete, ev.To)
+		}
+	})
+}
+
+func TestNeighborhoodWaterMarks(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	bus := eventbus.NewBus()
+	ds := datastore.NewMapDatastore()
+	cfg := neighborhood_service.Config{
+		Bus:       bus,
+		KMin:      kmin,
+		KMax:      kmax,
+		Debounce:  time.Millisecond,
+		HoldDown:  time.Hour, // reconfiguration is not damped
+		Datastore: ds,
+	}
+
+	mod := neighborhood_service.New(cfg)
+	n, err := mod.Factory.NewService()
+	require.NoError(t, err)
+
+	require.NoError(t, netReady(bus))
+	require.NoError(t, n.Start(ctx))
+	defer func() {
+		require.NoError(t, n.Stop(ctx))
+	}()
+
+	sub, err := bus.Subscribe(new(neighborhood_service.EvtNeighborhoodChanged), eventbus.BufSize(16))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	wsub, err := bus.Subscribe(new(neighborhood_service.EvtWaterMarksChanged))
+	require.NoError(t, err)
+	defer wsub.Close()
+
+	e, err := bus.Emitter(new(event.EvtPeerConnectednessChanged))
+	require.NoError(t, err)
+	defer e.Close()
+
+	assert.Equal(t, neighborhood_service.EvtWaterMarksChanged{KMin: kmin, KMax: kmax},
+		<-wsub.Out(), "initial watermarks should be emitted")
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, e.Emit(evtPeerConnectednessChanged(testutil.RandID(), network.Connected)))
+	}
+
+	ev := waitK(ctx, t, sub, 2)
+	require.Equal(t, neighborhood_service.PhasePartial, ev.To)
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, wm := range [][2]int{{0, 1}, {2, 1}, {-1, 4}} {
+			err := mod.WaterMarks.Set(wm[0], wm[1])
+			assert.True(t, errors.Is(err, neighborhood_service.ErrInvalidWaterMarks),
+				"%v should be rejected", wm)
+		}
+	})
+
+	t.Run("Set", func(t *testing.T) {
+		require.NoError(t, mod.WaterMarks.Set(1, 2))
+
+		ev := next(ctx, t, sub)
+		assert.Equal(t, 2, ev.K)
+		assert.Equal(t, neighborhood_service.PhasePartial, ev.From)
+		assert.Equal(t, neighborhood_service.PhaseComplete, ev.To)
+
+		assert.Equal(t, neighborhood_service.EvtWaterMarksChanged{KMin: 1, KMax: 2}, <-wsub.Out())
+
+		// unchanged phase is re-emitted
+		require.NoError(t, mod.WaterMarks.Set(1, 3))
+		ev = next(ctx, t, sub)
+		assert.Equal(t, neighborhood_service.PhaseComplete, ev.To)
+	})
+
+	t.Run("Snapshot", func(t *testing.T) {
+		s := n.(runtime.Snapshotter).Snapshot().(neighborhood_service.Snapshot)
+		assert.Equal(t, 2, s.K)
+		assert.Equal(t, neighborhood_service.PhaseComplete, s.To)
+		assert.Equal(t, neighborhood_service.EvtWaterMarksChanged{KMin: 1, KMax: 3},
+			s.EvtWaterMarksChanged)
+	})
+
+	t.Run("Persist", func(t *testing.T) {
+		mod := neighborhood_service.New(cfg)
+		_, err := mod.Factory.NewService()
+		require.NoError(t, err)
+
+		kmin, kmax := mod.WaterMarks.Get()
+		assert.Equal(t, 1, kmin)
+		assert.Equal(t, 3, kmax)
+	})
+}
+
+func TestNeighborhoodStress(t *testing.T) {
+	t.Parallel()
+
+	const (
+		nPeers    = 100
+		nEmitters = 4
+		nEvents   = 10000
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// a small buffer forces changes to the same peer to be coalesced
+	sub, e := startNeighborhood(ctx, t, neighborhood_service.Config{
+		Debounce: time.Millisecond,
+		BufSize:  8,
+	})
+
+	pids := make([]peer.ID, nPeers)
+	for i := range pids {
+		pids[i] = testutil.RandID()
+	}
+
+	// each emitter flaps its own peers, leaving even-numbered peers connected
+	var wg sync.WaitGroup
+	wg.Add(nEmitters)
+	for i := 0; i < nEmitters; i++ {
+		go func(i int) {
+			defer wg.Done()
+
+			for n := i; n < nEvents; n += nEmitters {
+				c := network.Connected
+				if (n/nPeers)%2 == 1 {
+					c = network.NotConnected
+				}
+
+				require.NoError(t, e.Emit(evtPeerConnectednessChanged(pids[n%nPeers], c)))
+			}
+
+			for n := i; n < nPeers; n += nEmitters {
+				c := network.Connected
+				if n%2 == 1 {
+					c = network.NotConnected
+				}
+
+				require.NoError(t, e.Emit(evtPeerConnectednessChanged(pids[n], c)))
+			}
+		}(i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		wg.Wait()
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatal("emitters deadlocked")
+	}
+
+	// the last event before the neighborhood settles reports the final state
+	var ev neighborhood_service.EvtNeighborhoodChanged
+	for settled := false; !settled; {
+		select {
+		case v := <-sub.Out():
+			ev = v.(neighborhood_service.EvtNeighborhoodChanged)
+		case <-time.After(time.Millisecond * 100):
+			settled = true
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		}
+	}
+
+	assert.Equal(t, nPeers/2, ev.K)
+	assert.Equal(t, neighborhood_service.PhaseOverloaded, ev.To)
+}
+
+// startNeighborhood starts a neighborhood service with kmin and kmax, and returns a
+// subscription to its events, from which the initial event has been consumed, and an
+// emitter for connectedness events.
+func startNeighborhood(ctx context.Context, t *testing.T, cfg neighborhood_service.Config) (event.Subscription, event.Emitter) {
+	bus := eventbus.NewBus()
+	cfg.Bus, cfg.KMin, cfg.KMax = bus, kmin, kmax
+
+	n, err := neighborhood_service.New(cfg).Factory.NewService()
+	require.NoError(t, err)
+
+	require.NoError(t, netReady(bus))
+	require.NoError(t, n.Start(ctx))
+	t.Cleanup(func() { require.NoError(t, n.Stop(ctx)) })
+
+	sub, err := bus.Subscribe(new(neighborhood_service.EvtNeighborhoodChanged), eventbus.BufSize(1024))
+	require.NoError(t, err)
+	t.Cleanup(func() { sub.Close() })
+
+	e, err := bus.Emitter(new(event.EvtPeerConnectednessChanged))
+	require.NoError(t, err)
+	t.Cleanup(func() { e.Close() })
+
+	assert.Zero(t, next(ctx, t, sub).K)
+	return sub, e
+}
+
+func next(ctx context.Context, t *testing.T, sub event.Subscription) neighborhood_service.EvtNeighborhoodChanged {
+	select {
+	case v := <-sub.Out():
+		return v.(neighborhood_service.EvtNeighborhoodChanged)
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+		return neighborhood_service.EvtNeighborhoodChanged{}
+	}
+}
+
+// waitK returns the first event that reports k peers.
+func waitK(ctx context.Context, t *testing.T, sub event.Subscription, k int) neighborhood_service.EvtNeighborhoodChanged {
+	for {
+		if ev := next(ctx, t, sub); ev.K == k {
+			return ev
+		}
+	}
+}
+
 func evtPeerConnectednessChanged(id peer.ID, c network.Connectedness) event.EvtPeerConnectednessChanged {
 	return event.EvtPeerConnectednessChanged{
 		Peer:          id,
@@ -0,0 +1,113 @@
+package neighborhood
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ipfs/go-datastore"
+)
+
+// ErrInvalidWaterMarks is returned when setting watermarks that do not satisfy
+// 0 < kmin <= kmax.
+var ErrInvalidWaterMarks = errors.New("invalid watermarks")
+
+// waterMarksKey is the datastore key under which watermarks are persisted.
+var waterMarksKey = datastore.NewKey("/ww/neighborhood/watermarks")
+
+// EvtWaterMarksChanged is emitted when the neighborhood's watermarks change, and when
+// the service starts.  It is stateful, so that late subscribers learn the watermarks
+// in effect.
+type EvtWaterMarksChanged struct {
+	KMin int `json:"kmin"`
+	KMax int `json:"kmax"`
+}
+
+// WaterMarks are the low and high watermarks of the neighborhood, i.e. kmin and kmax.
+// They are initialized from the host's configuration, and may be changed at runtime
+// with Set.  Changes are persisted to the host's datastore, if any, so that they
+// survive a restart.  It is safe for concurrent use.
+type WaterMarks struct {
+	mu   sync.Mutex
+	pm   phaseMap
+	ds   datastore.Datastore // nil if changes are not persisted
+	bump chan struct{}       // signals the service to re-evaluate the phase
+}
+
+func newWaterMarks(kmin, kmax int, ds datastore.Datastore) *WaterMarks {
+	return &WaterMarks{
+		pm:   phasemap(kmin, kmax),
+		ds:   ds,
+		bump: make(chan struct{}, 1),
+	}
+}
+
+// Get the current watermarks.
+func (wm *WaterMarks) Get() (kmin, kmax int) {
+	pm := wm.phaseMap()
+	return pm.l, pm.h
+}
+
+// Set the watermarks.  The change is applied atomically, after which the
+// neighborhood's phase is re-evaluated immediately, without damping.
+func (wm *WaterMarks) Set(kmin, kmax int) error {
+	if kmin <= 0 || kmin > kmax {
+		return fmt.Errorf("%w: kmin=%d, kmax=%d", ErrInvalidWaterMarks, kmin, kmax)
+	}
+
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	if wm.ds != nil {
+		b, err := json.Marshal(EvtWaterMarksChanged{KMin: kmin, KMax: kmax})
+		if err != nil {
+			return err
+		}
+
+		if err = wm.ds.Put(waterMarksKey, b); err != nil {
+			return fmt.Errorf("persist watermarks: %w", err)
+		}
+	}
+
+	wm.pm = phasemap(kmin, kmax)
+
+	select {
+	case wm.bump <- struct{}{}:
+	default: // already signaled
+	}
+
+	return nil
+}
+
+func (wm *WaterMarks) phaseMap() phaseMap {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	return wm.pm
+}
+
+// load watermarks persisted by a previous call to Set, if any.
+func (wm *WaterMarks) load() error {
+	if wm.ds == nil {
+		return nil
+	}
+
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	b, err := wm.ds.Get(waterMarksKey)
+	if err == datastore.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var ev EvtWaterMarksChanged
+	if err = json.Unmarshal(b, &ev); err != nil {
+		return fmt.Errorf("load watermarks: %w", err)
+	}
+
+	wm.pm = phasemap(ev.KMin, ev.KMax)
+	return nil
+}
@@ -0,0 +1,394 @@
+// Package prune implements a service that closes connections while the neighborhood
+// is overloaded, or to peers flagged by the graph service.
+package prune
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/fx"
+	"go.uber.org/multierr"
+
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/runtime"
+	"github.com/wetware/ww/pkg/runtime/svc/graph"
+	"github.com/wetware/ww/pkg/runtime/svc/internal"
+	"github.com/wetware/ww/pkg/runtime/svc/neighborhood"
+	"github.com/wetware/ww/pkg/runtime/svc/quality"
+)
+
+const (
+	// DefaultBudget is the default maximum number of peers pruned per interval.
+	DefaultBudget = 4
+
+	// DefaultInterval is the default period over which the budget applies.
+	DefaultInterval = time.Second * 10
+
+	// ProtectTag is the connection manager tag applied by Protect.
+	ProtectTag = "ww-protected"
+)
+
+// Protect the peer from pruning.  Peers protected by any connection manager tag are
+// never pruned; ProtectTag merely distinguishes protections applied by this package.
+// Protection has no effect if the host has no connection manager.
+func Protect(h host.Host, id peer.ID) {
+	h.ConnManager().Protect(id, ProtectTag)
+}
+
+// Unprotect a peer protected by Protect.  It returns true if the peer remains
+// protected by another tag.
+func Unprotect(h host.Host, id peer.ID) bool {
+	return h.ConnManager().Unprotect(id, ProtectTag)
+}
+
+// EvtPeersPruned is emitted after connections are pruned.
+type EvtPeersPruned struct {
+	// K is the number of connected peers before pruning.
+	K int
+
+	// Pruned peers, in the order in which they were closed.
+	Pruned []Pruned
+
+	// Deferred is the number of excess peers that were not pruned because the budget
+	// for the current interval was exhausted.
+	Deferred int
+}
+
+// Pruned describes a peer whose connections were closed.
+type Pruned struct {
+	Peer peer.ID
+
+	// Reason is "requested" if the peer was named by graph.EvtPruneRequested.
+	// Otherwise, it is "idle" if the peer had no open wetware streams, or "excess".
+	Reason string
+
+	// Idle is the time elapsed since the peer's most recent connection or stream was
+	// opened.
+	Idle time.Duration
+}
+
+// Config for Prune service.
+type Config struct {
+	fx.In
+
+	Log  ww.Logger `optional:"true"`
+	Host host.Host
+	KMin int `name:"kmin"`
+	KMax int `name:"kmax"`
+
+	// Budget and Interval default to DefaultBudget and DefaultInterval.
+	Budget   int           `name:"prune_budget" optional:"true"`
+	Interval time.Duration `name:"prune_interval" optional:"true"`
+}
+
+// NewService satisfies runtime.ServiceFactory
+func (cfg Config) NewService() (_ runtime.Service, err error) {
+	p := pruner{
+		h:        cfg.Host,
+		kmin:     cfg.KMin,
+		kmax:     cfg.KMax,
+		budget:   cfg.Budget,
+		interval: cfg.Interval,
+	}
+
+	if p.budget == 0 {
+		p.budget = DefaultBudget
+	}
+
+	if p.interval == 0 {
+		p.interval = DefaultInterval
+	}
+
+	if p.sub, err = cfg.Host.EventBus().Subscribe([]interface{}{
+		new(neighborhood.EvtNeighborhoodChanged),
+		new(neighborhood.EvtWaterMarksChanged),
+		new(quality.EvtPeerQuality),
+		new(graph.EvtPruneRequested),
+	}); err != nil {
+		return
+	}
+
+	if p.e, err = internal.NewEmitter(cfg.Host.EventBus(), new(EvtPeersPruned)); err != nil {
+		return
+	}
+
+	p.log = internal.Logger(cfg.Log, p)
+
+	return p, nil
+}
+
+// Produces EvtPeersPruned.
+func (cfg Config) Produces() []interface{} {
+	return []interface{}{
+		EvtPeersPruned{},
+	}
+}
+
+// Consumes neighborhood.EvtNeighborhoodChanged, neighborhood.EvtWaterMarksChanged,
+// quality.EvtPeerQuality & graph.EvtPruneRequested.
+func (cfg Config) Consumes() []interface{} {
+	return []interface{}{
+		neighborhood.EvtNeighborhoodChanged{},
+		neighborhood.EvtWaterMarksChanged{},
+		quality.EvtPeerQuality{},
+		graph.EvtPruneRequested{},
+	}
+}
+
+// Module for Prune service.
+type Module struct {
+	fx.Out
+
+	Factory runtime.ServiceFactory `group:"runtime"`
+}
+
+// New Prune service.  While the neighborhood is overloaded, the service closes
+// connections to the least valuable peers until kmax peers remain.  Peers with no open
+// wetware streams are pruned first, followed by the lowest-scored peers, as reported
+// by the quality service, and then by the longest-idle peers.  Protected
+// peers are never pruned, and neither are peers that would bring the neighborhood
+// below kmin.  At most Budget peers are pruned per Interval, to avoid oscillation.
+// Changes to kmin and kmax at runtime take effect immediately.
+//
+// Peers named by graph.EvtPruneRequested, e.g. slow consumers, are pruned regardless
+// of kmax, subject to the same protections and budget.  Requests that exceed the
+// budget are dropped.
+//
+// Consumes:
+//   - neighborhood.EvtNeighborhoodChanged
+//   - neighborhood.EvtWaterMarksChanged
+//   - quality.EvtPeerQuality
+//   - graph.EvtPruneRequested
+//
+// Emits:
+//   - EvtPeersPruned
+func New(cfg Config) Module { return Module{Factory: cfg} }
+
+type pruner struct {
+	log ww.Logger
+	h   host.Host
+
+	kmin, kmax int
+	budget     int
+	interval   time.Duration
+
+	sub event.Subscription
+	e   *internal.Emitter
+}
+
+func (p pruner) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"service":        "prune",
+		"prune_budget":   p.budget,
+		"prune_interval": p.interval,
+	}
+}
+
+func (p pruner) Start(ctx context.Context) (err error) {
+	if err = internal.WaitNetworkReady(ctx, p.h.EventBus()); err == nil {
+		internal.StartBackground(ctx, p.loop)
+	}
+
+	return
+}
+
+func (p pruner) Stop(context.Context) error {
+	return multierr.Combine(
+		p.sub.Close(),
+		p.e.Close(),
+	)
+}
+
+func (p pruner) loop() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	var (
+		overloaded bool
+		spent      int // peers pruned during the current interval
+		q          quality.EvtPeerQuality
+	)
+
+	for {
+		select {
+		case v, ok := <-p.sub.Out():
+			if !ok {
+				return
+			}
+
+			switch ev := v.(type) {
+			case neighborhood.EvtNeighborhoodChanged:
+				overloaded = ev.To == neighborhood.PhaseOverloaded
+			case neighborhood.EvtWaterMarksChanged:
+				p.kmin, p.kmax = ev.KMin, ev.KMax
+				continue
+			case quality.EvtPeerQuality:
+				q = ev
+				continue
+			case graph.EvtPruneRequested:
+				if len(ev.Peers) > 0 {
+					spent += p.pruneRequested(p.budget-spent, ev.Peers)
+					continue
+				}
+			}
+		case <-ticker.C:
+			spent = 0
+		}
+
+		if overloaded && spent < p.budget {
+			spent += p.prune(p.budget-spent, q)
+		}
+	}
+}
+
+// prune closes connections to at most n peers, and returns the number of peers pruned.
+func (p pruner) prune(n int, q quality.EvtPeerQuality) int {
+	cs := p.candidates(q)
+	k := len(p.h.Network().Peers())
+
+	excess := k - p.kmax
+	if floor := k - p.kmin; floor < excess {
+		excess = floor
+	}
+
+	if excess > len(cs) {
+		excess = len(cs)
+	}
+
+	if excess <= 0 {
+		return 0
+	}
+
+	ev := EvtPeersPruned{K: k}
+	for _, c := range cs[:excess] {
+		if len(ev.Pruned) == n {
+			ev.Deferred++
+			continue
+		}
+
+		if err := p.h.Network().ClosePeer(c.Peer); err != nil {
+			p.log.WithError(err).Debugf("failed to prune %s", c.Peer)
+			continue
+		}
+
+		ev.Pruned = append(ev.Pruned, c.Pruned)
+	}
+
+	if err := p.e.Emit(ev); err != nil && err != internal.ErrEmitterClosed {
+		p.log.WithError(err).Error("failed to emit EvtPeersPruned")
+	}
+
+	return len(ev.Pruned)
+}
+
+// pruneRequested closes connections to at most n of the requested peers, and returns
+// the number of peers pruned.
+func (p pruner) pruneRequested(n int, ids []peer.ID) int {
+	k := len(p.h.Network().Peers())
+
+	ev := EvtPeersPruned{K: k}
+	for _, id := range ids {
+		if p.h.Network().Connectedness(id) != network.Connected ||
+			p.h.ConnManager().IsProtected(id, "") ||
+			k-len(ev.Pruned) <= p.kmin {
+			continue
+		}
+
+		if len(ev.Pruned) >= n {
+			ev.Deferred++
+			continue
+		}
+
+		if err := p.h.Network().ClosePeer(id); err != nil {
+			p.log.WithError(err).Debugf("failed to prune %s", id)
+			continue
+		}
+
+		ev.Pruned = append(ev.Pruned, Pruned{Peer: id, Reason: "requested"})
+	}
+
+	if len(ev.Pruned) == 0 && ev.Deferred == 0 {
+		return 0
+	}
+
+	if err := p.e.Emit(ev); err != nil && err != internal.ErrEmitterClosed {
+		p.log.WithError(err).Error("failed to emit EvtPeersPruned")
+	}
+
+	return len(ev.Pruned)
+}
+
+type candidate struct {
+	Pruned
+	streams int     // open wetware streams
+	score   float64 // connection quality
+}
+
+// candidates returns the unprotected peers, least valuable first.
+func (p pruner) candidates(q quality.EvtPeerQuality) []candidate {
+	var (
+		now = time.Now()
+		cs  []candidate
+	)
+
+	for _, id := range p.h.Network().Peers() {
+		if p.h.ConnManager().IsProtected(id, "") {
+			continue
+		}
+
+		c := candidate{Pruned: Pruned{Peer: id, Reason: "idle"}, score: q.Score(id)}
+
+		var active time.Time
+		for _, conn := range p.h.Network().ConnsToPeer(id) {
+			active = latest(active, conn.Stat().Opened)
+
+			for _, s := range conn.GetStreams() {
+				if isWetware(s) {
+					c.streams++
+					c.Reason = "excess"
+				}
+
+				active = latest(active, s.Stat().Opened)
+			}
+		}
+
+		if !active.IsZero() {
+			c.Idle = now.Sub(active)
+		}
+
+		cs = append(cs, c)
+	}
+
+	sort.SliceStable(cs, func(i, j int) bool {
+		if (cs[i].streams == 0) != (cs[j].streams == 0) {
+			return cs[i].streams == 0
+		}
+
+		if cs[i].score != cs[j].score {
+			return cs[i].score < cs[j].score
+		}
+
+		return cs[i].Idle > cs[j].Idle
+	})
+
+	return cs
+}
+
+func isWetware(s network.Stream) bool {
+	return strings.HasPrefix(string(s.Protocol()), "/ww/")
+}
+
+func latest(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+
+	return a
+}
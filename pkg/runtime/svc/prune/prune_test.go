@@ -0,0 +1,307 @@
+package prune_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	eventbus "github.com/libp2p/go-eventbus"
+	connmgr "github.com/libp2p/go-libp2p-connmgr"
+	coremgr "github.com/libp2p/go-libp2p-core/connmgr"
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+
+	"github.com/wetware/ww/pkg/internal/p2p"
+	graph_service "github.com/wetware/ww/pkg/runtime/svc/graph"
+	neighborhood_service "github.com/wetware/ww/pkg/runtime/svc/neighborhood"
+	prune_service "github.com/wetware/ww/pkg/runtime/svc/prune"
+	quality_service "github.com/wetware/ww/pkg/runtime/svc/quality"
+	streams_service "github.com/wetware/ww/pkg/runtime/svc/streams"
+)
+
+func TestPrune(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	mn, err := mocknet.FullMeshConnected(ctx, 8)
+	require.NoError(t, err)
+
+	hs := mn.Hosts()
+	h := managedHost{Host: hs[0], cm: connmgr.NewConnManager(100, 200, time.Hour)}
+	protected, busy := hs[1], hs[2:4]
+
+	prune_service.Protect(h, protected.ID())
+
+	for _, b := range busy {
+		b.SetStreamHandler("/ww/test", func(network.Stream) {}) // leave stream open
+		_, err := h.NewStream(ctx, b.ID(), "/ww/test")
+		require.NoError(t, err)
+	}
+
+	p, err := prune_service.New(prune_service.Config{
+		Host:     h,
+		KMin:     2,
+		KMax:     3,
+		Budget:   2,
+		Interval: time.Millisecond * 100,
+	}).Factory.NewService()
+	require.NoError(t, err)
+
+	sub, err := h.EventBus().Subscribe(new(prune_service.EvtPeersPruned))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	e, err := h.EventBus().Emitter(new(neighborhood_service.EvtNeighborhoodChanged))
+	require.NoError(t, err)
+	defer e.Close()
+
+	require.NoError(t, netReady(h.EventBus()))
+	require.NoError(t, p.Start(ctx))
+	defer func() {
+		require.NoError(t, p.Stop(ctx))
+	}()
+
+	require.NoError(t, e.Emit(neighborhood_service.EvtNeighborhoodChanged{
+		K:    7,
+		From: neighborhood_service.PhaseComplete,
+		To:   neighborhood_service.PhaseOverloaded,
+	}))
+
+	// the budget allows two peers to be pruned per interval
+	ev := next(ctx, t, sub)
+	assert.Equal(t, 7, ev.K)
+	assert.Len(t, ev.Pruned, 2)
+	assert.Equal(t, 2, ev.Deferred)
+
+	ev = next(ctx, t, sub)
+	assert.Equal(t, 5, ev.K)
+	assert.Len(t, ev.Pruned, 2)
+	assert.Zero(t, ev.Deferred)
+
+	// peers with wetware streams would be pruned next, but kmax has been reached.
+	assert.Len(t, h.Network().Peers(), 3)
+	assert.Equal(t, network.Connected, h.Network().Connectedness(protected.ID()))
+	for _, b := range busy {
+		assert.Equal(t, network.Connected, h.Network().Connectedness(b.ID()))
+	}
+
+	select {
+	case v := <-sub.Out():
+		t.Errorf("pruned below kmax:  %v", v)
+	case <-time.After(time.Millisecond * 250):
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	mn, err := mocknet.FullMeshConnected(ctx, 4)
+	require.NoError(t, err)
+
+	hs := mn.Hosts()
+	h := managedHost{Host: hs[0], cm: connmgr.NewConnManager(100, 200, time.Hour)}
+
+	hs[1].SetStreamHandler("/ww/test", func(network.Stream) {})
+	_, err = h.NewStream(ctx, hs[1].ID(), "/ww/test")
+	require.NoError(t, err)
+
+	p, err := prune_service.New(prune_service.Config{
+		Host: h,
+		KMin: 1,
+		KMax: 1,
+	}).Factory.NewService()
+	require.NoError(t, err)
+
+	sub, err := h.EventBus().Subscribe(new(prune_service.EvtPeersPruned))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	e, err := h.EventBus().Emitter(new(neighborhood_service.EvtNeighborhoodChanged))
+	require.NoError(t, err)
+	defer e.Close()
+
+	require.NoError(t, netReady(h.EventBus()))
+	require.NoError(t, p.Start(ctx))
+	defer func() {
+		require.NoError(t, p.Stop(ctx))
+	}()
+
+	require.NoError(t, e.Emit(neighborhood_service.EvtNeighborhoodChanged{
+		To: neighborhood_service.PhaseOverloaded,
+	}))
+
+	ev := next(ctx, t, sub)
+	require.Len(t, ev.Pruned, 2)
+
+	var pruned []peer.ID
+	for _, p := range ev.Pruned {
+		assert.Equal(t, "idle", p.Reason)
+		pruned = append(pruned, p.Peer)
+	}
+
+	assert.ElementsMatch(t, []peer.ID{hs[2].ID(), hs[3].ID()}, pruned,
+		"peers without wetware streams should be pruned first")
+	assert.True(t, ev.Pruned[0].Idle >= ev.Pruned[1].Idle,
+		"longest-idle peers should be pruned first")
+}
+
+func TestPruneByQuality(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	mn, err := mocknet.FullMeshConnected(ctx, 4)
+	require.NoError(t, err)
+
+	hs := mn.Hosts()
+	h := managedHost{Host: hs[0], cm: connmgr.NewConnManager(100, 200, time.Hour)}
+	unmeasured, good, poor := hs[1], hs[2], hs[3]
+
+	p, err := prune_service.New(prune_service.Config{
+		Host: h,
+		KMin: 1,
+		KMax: 2,
+	}).Factory.NewService()
+	require.NoError(t, err)
+
+	sub, err := h.EventBus().Subscribe(new(prune_service.EvtPeersPruned))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	eQuality, err := h.EventBus().Emitter(new(quality_service.EvtPeerQuality))
+	require.NoError(t, err)
+	defer eQuality.Close()
+
+	eHood, err := h.EventBus().Emitter(new(neighborhood_service.EvtNeighborhoodChanged))
+	require.NoError(t, err)
+	defer eHood.Close()
+
+	require.NoError(t, netReady(h.EventBus()))
+	require.NoError(t, p.Start(ctx))
+	defer func() {
+		require.NoError(t, p.Stop(ctx))
+	}()
+
+	require.NoError(t, eQuality.Emit(quality_service.EvtPeerQuality{
+		Peers: map[peer.ID]quality_service.Quality{
+			good.ID(): {Samples: 1, Score: .9},
+			poor.ID(): {Samples: 1, Score: .1},
+		},
+	}))
+
+	require.NoError(t, eHood.Emit(neighborhood_service.EvtNeighborhoodChanged{
+		To: neighborhood_service.PhaseOverloaded,
+	}))
+
+	ev := next(ctx, t, sub)
+	require.Len(t, ev.Pruned, 1)
+	assert.Equal(t, poor.ID(), ev.Pruned[0].Peer, "lowest-scored peer should be pruned first")
+	assert.Equal(t, network.Connected, h.Network().Connectedness(unmeasured.ID()))
+	assert.Equal(t, network.Connected, h.Network().Connectedness(good.ID()))
+}
+
+func TestPruneSlowConsumer(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	mn, err := mocknet.FullMeshConnected(ctx, 4)
+	require.NoError(t, err)
+
+	hs := mn.Hosts()
+	h := managedHost{Host: hs[0], cm: connmgr.NewConnManager(100, 200, time.Hour)}
+	slow, protected := hs[1], hs[2]
+
+	prune_service.Protect(h, protected.ID())
+
+	// the graph service turns slow-consumer events into prune requests
+	g, err := graph_service.New(graph_service.Config{Host: h}).Factory.NewService()
+	require.NoError(t, err)
+
+	p, err := prune_service.New(prune_service.Config{
+		Host: h,
+		KMin: 1,
+		KMax: 8,
+	}).Factory.NewService()
+	require.NoError(t, err)
+
+	sub, err := h.EventBus().Subscribe(new(prune_service.EvtPeersPruned))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	e, err := h.EventBus().Emitter(new(streams_service.EvtSlowConsumer))
+	require.NoError(t, err)
+	defer e.Close()
+
+	require.NoError(t, netReady(h.EventBus()))
+	require.NoError(t, g.Start(ctx))
+	require.NoError(t, p.Start(ctx))
+	defer func() {
+		require.NoError(t, p.Stop(ctx))
+		require.NoError(t, g.Stop(ctx))
+	}()
+
+	// the neighborhood is not overloaded, so only the slow consumer is pruned
+	require.NoError(t, e.Emit(streams_service.EvtSlowConsumer{Peer: protected.ID()}))
+	require.NoError(t, e.Emit(streams_service.EvtSlowConsumer{Peer: slow.ID()}))
+
+	ev := next(ctx, t, sub)
+	assert.Equal(t, 3, ev.K)
+	require.Len(t, ev.Pruned, 1)
+	assert.Equal(t, slow.ID(), ev.Pruned[0].Peer)
+	assert.Equal(t, "requested", ev.Pruned[0].Reason)
+
+	assert.NotEqual(t, network.Connected, h.Network().Connectedness(slow.ID()))
+	assert.Equal(t, network.Connected, h.Network().Connectedness(protected.ID()),
+		"protected peers should not be pruned")
+	assert.Len(t, h.Network().Peers(), 2)
+}
+
+func next(ctx context.Context, t *testing.T, sub event.Subscription) prune_service.EvtPeersPruned {
+	select {
+	case v := <-sub.Out():
+		return v.(prune_service.EvtPeersPruned)
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+		return prune_service.EvtPeersPruned{}
+	}
+}
+
+// managedHost equips a mock host with a connection manager, so that peers can be
+// protected.
+type managedHost struct {
+	host.Host
+	cm coremgr.ConnManager
+}
+
+func (h managedHost) ConnManager() coremgr.ConnManager { return h.cm }
+
+// netReady emits p2p.EvtNetworkReady
+func netReady(bus event.Bus) error {
+	e, err := bus.Emitter(new(p2p.EvtNetworkReady), eventbus.Stateful)
+	if err != nil {
+		return err
+	}
+
+	return e.Emit(p2p.EvtNetworkReady{})
+}
@@ -0,0 +1,408 @@
+// Package quality implements a service that scores the quality of the connection to
+// each peer in the neighborhood.
+package quality
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"go.uber.org/fx"
+	"go.uber.org/multierr"
+
+	eventbus "github.com/libp2p/go-eventbus"
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/runtime"
+	"github.com/wetware/ww/pkg/runtime/svc/internal"
+)
+
+const (
+	// DefaultInterval is the default period between rounds of measurement.  Each peer
+	// is sampled at most once per interval.
+	DefaultInterval = time.Second * 10
+
+	// DefaultBudget is the default maximum number of peers sampled per interval.
+	DefaultBudget = 8
+
+	// Neutral is the score of a peer that has not been measured.
+	Neutral = 0.5
+
+	// a peer whose smoothed RTT equals refRTT scores half marks for latency
+	refRTT = time.Millisecond * 100
+
+	// age at which a connection is considered established
+	maturity = time.Minute * 10
+
+	// weight of the most recent sample in the moving averages
+	alpha = 0.3
+
+	pingTimeout = time.Second * 5
+)
+
+// Quality of the connection to a peer.
+type Quality struct {
+	// RTT is the smoothed round-trip time.  It is zero if no sample has succeeded.
+	RTT time.Duration
+
+	// Samples is the number of measurements, and Errors the number that failed.
+	Samples, Errors int
+
+	// Age of the peer's oldest open connection.
+	Age time.Duration
+
+	// Score in [0, 1], higher being better.  It weighs latency, reliability and
+	// connection age, in decreasing order of importance.
+	Score float64
+}
+
+// EvtPeerQuality is emitted after each round of measurement has completed, and when a
+// peer disconnects.  It reports the quality of each connected peer.
+type EvtPeerQuality struct {
+	Peers map[peer.ID]Quality
+}
+
+// Score of the peer, or Neutral if it has not been measured.
+func (ev EvtPeerQuality) Score(id peer.ID) float64 {
+	if q, ok := ev.Peers[id]; ok && q.Samples > 0 {
+		return q.Score
+	}
+
+	return Neutral
+}
+
+// Config for Quality service.
+type Config struct {
+	fx.In
+
+	Log  ww.Logger `optional:"true"`
+	Host host.Host
+
+	// Interval and Budget default to DefaultInterval and DefaultBudget.
+	Interval time.Duration `name:"quality_interval" optional:"true"`
+	Budget   int           `name:"quality_budget" optional:"true"`
+
+	// BufSize is the number of peers whose pending connectedness changes are
+	// buffered.  It defaults to internal.DefaultBufSize.
+	BufSize int `name:"quality_buffer" optional:"true"`
+}
+
+// NewService satisfies runtime.ServiceFactory
+func (cfg Config) NewService() (_ runtime.Service, err error) {
+	q := quality{
+		h:        cfg.Host,
+		interval: cfg.Interval,
+		budget:   cfg.Budget,
+		cq:       make(chan struct{}),
+	}
+
+	if q.interval == 0 {
+		q.interval = DefaultInterval
+	}
+
+	if q.budget == 0 {
+		q.budget = DefaultBudget
+	}
+
+	if q.sub, err = internal.Subscribe(cfg.Host.EventBus(), new(event.EvtPeerConnectednessChanged),
+		cfg.BufSize, internal.Coalesce(byPeer)); err != nil {
+		return
+	}
+
+	if q.e, err = internal.NewEmitter(cfg.Host.EventBus(), new(EvtPeerQuality), eventbus.Stateful); err != nil {
+		return
+	}
+
+	q.log = internal.Logger(cfg.Log, q)
+
+	return q, nil
+}
+
+// Produces EvtPeerQuality.
+func (cfg Config) Produces() []interface{} {
+	return []interface{}{
+		EvtPeerQuality{},
+	}
+}
+
+// Stateful EvtPeerQuality.
+func (cfg Config) Stateful() []interface{} {
+	return []interface{}{
+		EvtPeerQuality{},
+	}
+}
+
+// Consumes event.EvtPeerConnectednessChanged.
+func (cfg Config) Consumes() []interface{} {
+	return []interface{}{
+		event.EvtPeerConnectednessChanged{},
+	}
+}
+
+// Module for Quality service.
+type Module struct {
+	fx.Out
+
+	Factory runtime.ServiceFactory `group:"runtime"`
+}
+
+// New Quality service.  Every Interval, the service pings at most Budget connected
+// peers, least recently sampled first, and scores each peer by its smoothed RTT, the
+// proportion of failed pings and the age of its connection.  Measurements of a peer
+// stop as soon as it disconnects.
+//
+// Consumes:
+//   - event.EvtPeerConnectednessChanged
+//
+// Emits:
+//   - EvtPeerQuality
+func New(cfg Config) Module { return Module{Factory: cfg} }
+
+type quality struct {
+	log ww.Logger
+	h   host.Host
+
+	interval time.Duration
+	budget   int
+
+	sub event.Subscription
+	e   *internal.Emitter
+	cq  chan struct{}
+}
+
+func (q quality) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"service":          "quality",
+		"quality_interval": q.interval,
+		"quality_budget":   q.budget,
+	}
+}
+
+func (q quality) Start(ctx context.Context) (err error) {
+	if err = internal.WaitNetworkReady(ctx, q.h.EventBus()); err == nil {
+		internal.StartBackground(ctx, q.loop)
+	}
+
+	return
+}
+
+func (q quality) Stop(context.Context) error {
+	close(q.cq)
+
+	return multierr.Combine(
+		q.sub.Close(),
+		q.e.Close(),
+	)
+}
+
+// sample is the state of measurement for a connected peer.
+type sample struct {
+	Quality
+	rtt, errRate float64   // moving averages
+	last         time.Time // time of the last completed measurement
+	cancel       func()    // non-nil while a measurement is in flight
+}
+
+type result struct {
+	id  peer.ID
+	s   *sample // measured sample, which may have been replaced since
+	rtt time.Duration
+	err error
+}
+
+func (q quality) loop() {
+	ticker := time.NewTicker(q.interval)
+	defer ticker.Stop()
+
+	var (
+		ps = make(map[peer.ID]*sample)
+		rs = make(chan result)
+	)
+
+	defer func() {
+		for _, s := range ps {
+			if s.cancel != nil {
+				s.cancel()
+			}
+		}
+	}()
+
+	for _, id := range q.h.Network().Peers() {
+		ps[id] = new(sample)
+	}
+
+	for {
+		select {
+		case v, ok := <-q.sub.Out():
+			if !ok {
+				return
+			}
+
+			switch ev := v.(event.EvtPeerConnectednessChanged); ev.Connectedness {
+			case network.Connected:
+				if _, ok := ps[ev.Peer]; !ok {
+					ps[ev.Peer] = new(sample)
+				}
+
+				continue
+
+			case network.NotConnected:
+				s, ok := ps[ev.Peer]
+				if !ok {
+					continue
+				}
+
+				if s.cancel != nil {
+					s.cancel()
+				}
+
+				delete(ps, ev.Peer)
+			}
+
+		case <-ticker.C:
+			q.measure(ps, rs)
+			continue
+
+		case r := <-rs:
+			// discard results for peers that have since disconnected
+			if s, ok := ps[r.id]; !ok || s != r.s {
+				continue
+			}
+
+			r.s.update(r.rtt, r.err)
+
+			// report the round once all of its measurements have completed
+			if inFlight(ps) {
+				continue
+			}
+
+		case <-q.cq:
+			return
+		}
+
+		q.emit(ps)
+	}
+}
+
+// measure pings at most q.budget peers that are not already being measured, least
+// recently sampled first.  Results are sent on rs.
+func (q quality) measure(ps map[peer.ID]*sample, rs chan<- result) {
+	var ids []peer.ID
+	for id, s := range ps {
+		if s.cancel == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ps[ids[i]].last.Before(ps[ids[j]].last)
+	})
+
+	if len(ids) > q.budget {
+		ids = ids[:q.budget]
+	}
+
+	for _, id := range ids {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		ps[id].cancel = cancel
+
+		go func(r result) {
+			defer cancel()
+
+			res, ok := <-ping.Ping(ctx, q.h, r.id)
+			if r.rtt, r.err = res.RTT, res.Error; !ok {
+				r.err = ctx.Err()
+			}
+
+			select {
+			case rs <- r:
+			case <-q.cq:
+			}
+		}(result{id: id, s: ps[id]})
+	}
+}
+
+func (q quality) emit(ps map[peer.ID]*sample) {
+	ev := EvtPeerQuality{Peers: make(map[peer.ID]Quality, len(ps))}
+	for id, s := range ps {
+		s.Age = q.age(id)
+		s.Score = s.score()
+		ev.Peers[id] = s.Quality
+	}
+
+	if err := q.e.Emit(ev); err != nil && err != internal.ErrEmitterClosed {
+		q.log.WithError(err).Error("failed to emit EvtPeerQuality")
+	}
+}
+
+func byPeer(ev interface{}) interface{} {
+	return ev.(event.EvtPeerConnectednessChanged).Peer
+}
+
+func inFlight(ps map[peer.ID]*sample) bool {
+	for _, s := range ps {
+		if s.cancel != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// age of the oldest open connection to the peer.
+func (q quality) age(id peer.ID) (age time.Duration) {
+	for _, conn := range q.h.Network().ConnsToPeer(id) {
+		if d := time.Since(conn.Stat().Opened); d > age {
+			age = d
+		}
+	}
+
+	return
+}
+
+func (s *sample) update(rtt time.Duration, err error) {
+	s.cancel = nil
+	s.last = time.Now()
+	s.Samples++
+
+	failed := 0.
+	if err != nil {
+		s.Errors++
+		failed = 1
+	} else if s.RTT == 0 {
+		s.rtt = float64(rtt)
+	} else {
+		s.rtt = alpha*float64(rtt) + (1-alpha)*s.rtt
+	}
+
+	if s.Samples == 1 {
+		s.errRate = failed
+	} else {
+		s.errRate = alpha*failed + (1-alpha)*s.errRate
+	}
+
+	s.RTT = time.Duration(s.rtt)
+}
+
+func (s *sample) score() float64 {
+	if s.Samples == 0 {
+		return Neutral
+	}
+
+	latency := 0. // no successful sample
+	if s.RTT > 0 {
+		latency = 1 / (1 + float64(s.RTT)/float64(refRTT))
+	}
+
+	age := float64(s.Age) / float64(maturity)
+	if age > 1 {
+		age = 1
+	}
+
+	return .5*latency + .3*(1-s.errRate) + .2*age
+}
@@ -0,0 +1,118 @@
+package quality_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	eventbus "github.com/libp2p/go-eventbus"
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/network"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+
+	"github.com/wetware/ww/pkg/internal/p2p"
+	quality_service "github.com/wetware/ww/pkg/runtime/svc/quality"
+)
+
+func TestQualityLoggable(t *testing.T) {
+	t.Parallel()
+
+	h, err := mocknet.New(context.Background()).GenPeer()
+	require.NoError(t, err)
+
+	q, err := quality_service.New(quality_service.Config{Host: h}).Factory.NewService()
+	require.NoError(t, err)
+
+	assert.Equal(t, "quality", q.Loggable()["service"])
+	assert.Equal(t, quality_service.DefaultInterval, q.Loggable()["quality_interval"])
+	assert.Equal(t, quality_service.DefaultBudget, q.Loggable()["quality_budget"])
+}
+
+func TestQuality(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	mn, err := mocknet.FullMeshConnected(ctx, 3)
+	require.NoError(t, err)
+
+	// good responds to pings, whereas bad does not
+	hs := mn.Hosts()
+	h, good, bad := hs[0], hs[1], hs[2]
+	ping.NewPingService(good)
+
+	q, err := quality_service.New(quality_service.Config{
+		Host:     h,
+		Interval: time.Millisecond * 20,
+	}).Factory.NewService()
+	require.NoError(t, err)
+
+	sub, err := h.EventBus().Subscribe(new(quality_service.EvtPeerQuality))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	require.NoError(t, netReady(h.EventBus()))
+	require.NoError(t, q.Start(ctx))
+	defer func() {
+		require.NoError(t, q.Stop(ctx))
+	}()
+
+	var ev quality_service.EvtPeerQuality
+	for ev.Peers[bad.ID()].Samples < 2 {
+		ev = next(ctx, t, sub)
+	}
+
+	require.Len(t, ev.Peers, 2)
+	assert.NotZero(t, ev.Peers[good.ID()].RTT)
+	assert.Zero(t, ev.Peers[good.ID()].Errors)
+	assert.Zero(t, ev.Peers[bad.ID()].RTT)
+	assert.Equal(t, ev.Peers[bad.ID()].Samples, ev.Peers[bad.ID()].Errors)
+	assert.Greater(t, ev.Score(good.ID()), ev.Score(bad.ID()),
+		"responsive peers should score higher")
+	assert.Equal(t, quality_service.Neutral, ev.Score("unknown"))
+
+	// measurements stop for disconnected peers
+	e, err := h.EventBus().Emitter(new(event.EvtPeerConnectednessChanged))
+	require.NoError(t, err)
+	defer e.Close()
+
+	require.NoError(t, e.Emit(event.EvtPeerConnectednessChanged{
+		Peer:          bad.ID(),
+		Connectedness: network.NotConnected,
+	}))
+
+	for _, ok := ev.Peers[bad.ID()]; ok; _, ok = ev.Peers[bad.ID()] {
+		ev = next(ctx, t, sub)
+	}
+
+	for i := 0; i < 3; i++ {
+		ev = next(ctx, t, sub)
+		assert.NotContains(t, ev.Peers, bad.ID())
+		assert.Contains(t, ev.Peers, good.ID())
+	}
+}
+
+func next(ctx context.Context, t *testing.T, sub event.Subscription) quality_service.EvtPeerQuality {
+	select {
+	case v := <-sub.Out():
+		return v.(quality_service.EvtPeerQuality)
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+		return quality_service.EvtPeerQuality{}
+	}
+}
+
+// netReady emits p2p.EvtNetworkReady
+func netReady(bus event.Bus) error {
+	e, err := bus.Emitter(new(p2p.EvtNetworkReady), eventbus.Stateful)
+	if err != nil {
+		return err
+	}
+
+	return e.Emit(p2p.EvtNetworkReady{})
+}
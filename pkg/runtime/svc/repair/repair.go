@@ -0,0 +1,471 @@
+// Package repair implements a service that dials new peers while the neighborhood is
+// orphaned or partial.
+package repair
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/fx"
+	"go.uber.org/multierr"
+
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multiaddr"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/boot"
+	"github.com/wetware/ww/pkg/runtime"
+	boot_service "github.com/wetware/ww/pkg/runtime/svc/boot"
+	"github.com/wetware/ww/pkg/runtime/svc/heartbeat"
+	"github.com/wetware/ww/pkg/runtime/svc/internal"
+	"github.com/wetware/ww/pkg/runtime/svc/neighborhood"
+	"github.com/wetware/ww/pkg/runtime/svc/prune"
+	"github.com/wetware/ww/pkg/runtime/svc/quality"
+)
+
+const (
+	// DefaultHysteresis is the default number of peers above kmin to which the
+	// neighborhood is repaired.
+	DefaultHysteresis = 1
+
+	// DefaultConcurrency is the default maximum number of concurrent dials.
+	DefaultConcurrency = 4
+
+	// DefaultCooldown is the default period during which pruned and departed peers
+	// are not dialed.
+	DefaultCooldown = time.Minute * 5
+
+	// DefaultInterval is the default period between repair rounds while the
+	// neighborhood is orphaned or partial.
+	DefaultInterval = time.Second * 5
+
+	// backoff after a failed dial, doubled with each consecutive failure
+	minBackoff = time.Second * 5
+	maxBackoff = time.Minute * 5
+
+	dialTimeout = time.Second * 10
+)
+
+// EvtRepairAttempt is emitted after each round of dials.
+type EvtRepairAttempt struct {
+	// K is the number of connected peers before the round, and Target is the number
+	// of peers sought.
+	K, Target int
+
+	// Dialed is the number of candidates that were dialed, and Connected the number of
+	// successful dials.
+	Dialed, Connected int
+}
+
+// Config for Repair service.
+type Config struct {
+	fx.In
+
+	Log  ww.Logger `optional:"true"`
+	Host host.Host
+	KMin int `name:"kmin"`
+	KMax int `name:"kmax"`
+
+	// Hysteresis, Concurrency, Cooldown and Interval default to DefaultHysteresis,
+	// DefaultConcurrency, DefaultCooldown and DefaultInterval.
+	Hysteresis  int           `name:"repair_hysteresis" optional:"true"`
+	Concurrency int           `name:"repair_concurrency" optional:"true"`
+	Cooldown    time.Duration `name:"repair_cooldown" optional:"true"`
+	Interval    time.Duration `name:"repair_interval" optional:"true"`
+}
+
+// NewService satisfies runtime.ServiceFactory
+func (cfg Config) NewService() (_ runtime.Service, err error) {
+	r := repairer{
+		h:           cfg.Host,
+		hysteresis:  cfg.Hysteresis,
+		concurrency: cfg.Concurrency,
+		cooldown:    cfg.Cooldown,
+		interval:    cfg.Interval,
+		pool:        make(map[peer.ID]*candidate),
+		pruned:      make(map[peer.ID]time.Time),
+		scores:      make(map[peer.ID]float64),
+	}
+
+	if r.hysteresis == 0 {
+		r.hysteresis = DefaultHysteresis
+	}
+
+	r.setTarget(cfg.KMin, cfg.KMax)
+
+	if r.concurrency == 0 {
+		r.concurrency = DefaultConcurrency
+	}
+
+	if r.cooldown == 0 {
+		r.cooldown = DefaultCooldown
+	}
+
+	if r.interval == 0 {
+		r.interval = DefaultInterval
+	}
+
+	if r.sub, err = cfg.Host.EventBus().Subscribe([]interface{}{
+		new(neighborhood.EvtNeighborhoodChanged),
+		new(neighborhood.EvtWaterMarksChanged),
+		new(boot_service.EvtPeerDiscovered),
+		new(prune.EvtPeersPruned),
+		new(quality.EvtPeerQuality),
+		new(heartbeat.EvtMemberLeft),
+	}); err != nil {
+		return
+	}
+
+	if r.e, err = internal.NewEmitter(cfg.Host.EventBus(), new(EvtRepairAttempt)); err != nil {
+		return
+	}
+
+	r.log = internal.Logger(cfg.Log, r)
+
+	return r, nil
+}
+
+// Produces EvtRepairAttempt.
+func (cfg Config) Produces() []interface{} {
+	return []interface{}{
+		EvtRepairAttempt{},
+	}
+}
+
+// Public EvtRepairAttempt, which is intended for monitoring.
+func (cfg Config) Public() []interface{} { return cfg.Produces() }
+
+// Consumes neighborhood.EvtNeighborhoodChanged, neighborhood.EvtWaterMarksChanged,
+// boot.EvtPeerDiscovered, prune.EvtPeersPruned, quality.EvtPeerQuality &
+// heartbeat.EvtMemberLeft.
+func (cfg Config) Consumes() []interface{} {
+	return []interface{}{
+		neighborhood.EvtNeighborhoodChanged{},
+		neighborhood.EvtWaterMarksChanged{},
+		boot_service.EvtPeerDiscovered{},
+		prune.EvtPeersPruned{},
+		quality.EvtPeerQuality{},
+		heartbeat.EvtMemberLeft{},
+	}
+}
+
+// Module for Repair service.
+type Module struct {
+	fx.Out
+
+	Factory runtime.ServiceFactory `group:"runtime"`
+}
+
+// New Repair service.  While the neighborhood is orphaned or partial, the service
+// dials candidate peers until kmin+Hysteresis peers are connected, or kmax if it is
+// lower.  Changes to kmin and kmax at runtime take effect immediately.  Candidates
+// are the peers reported by boot discovery, and the peers in the peerstore that are
+// known to speak the wetware hello protocol, i.e. peers of peers.
+//
+// Candidates whose dial fails are backed off exponentially.  Pruned peers are not
+// dialed until Cooldown has elapsed, so that repair does not undo pruning.  Peers
+// that announced their departure are removed from the candidates, and are not dialed
+// until Cooldown has elapsed either.  Among
+// candidates with as many failures, those with the best score when they were last
+// connected, as reported by the quality service, are dialed first.
+//
+// Consumes:
+//   - neighborhood.EvtNeighborhoodChanged
+//   - neighborhood.EvtWaterMarksChanged
+//   - boot.EvtPeerDiscovered
+//   - prune.EvtPeersPruned
+//   - quality.EvtPeerQuality
+//   - heartbeat.EvtMemberLeft
+//
+// Emits:
+//   - EvtRepairAttempt
+func New(cfg Config) Module { return Module{Factory: cfg} }
+
+type candidate struct {
+	info     peer.AddrInfo
+	failures int
+	retry    time.Time // zero if the candidate may be dialed immediately
+}
+
+type repairer struct {
+	log ww.Logger
+	h   host.Host
+
+	target, hysteresis, concurrency int
+	cooldown, interval              time.Duration
+
+	pool   map[peer.ID]*candidate
+	pruned map[peer.ID]time.Time // end of cooldown of pruned or departed peers
+	scores map[peer.ID]float64   // last known quality score
+
+	sub event.Subscription
+	e   *internal.Emitter
+}
+
+func (r repairer) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"service":       "repair",
+		"repair_target": r.target,
+	}
+}
+
+func (r repairer) Start(ctx context.Context) (err error) {
+	if err = internal.WaitNetworkReady(ctx, r.h.EventBus()); err == nil {
+		internal.StartBackground(ctx, r.loop)
+	}
+
+	return
+}
+
+func (r repairer) Stop(context.Context) error {
+	return multierr.Combine(
+		r.sub.Close(),
+		r.e.Close(),
+	)
+}
+
+func (r repairer) loop() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	var needy bool // orphaned or partial
+	for {
+		select {
+		case v, ok := <-r.sub.Out():
+			if !ok {
+				return
+			}
+
+			switch ev := v.(type) {
+			case neighborhood.EvtNeighborhoodChanged:
+				needy = ev.To < neighborhood.PhaseComplete
+			case neighborhood.EvtWaterMarksChanged:
+				r.setTarget(ev.KMin, ev.KMax)
+			case boot_service.EvtPeerDiscovered:
+				r.add(peer.AddrInfo(ev))
+			case prune.EvtPeersPruned:
+				for _, p := range ev.Pruned {
+					r.pruned[p.Peer] = time.Now().Add(r.cooldown)
+				}
+			case heartbeat.EvtMemberLeft:
+				if ev.Leaving {
+					delete(r.pool, ev.ID)
+					r.pruned[ev.ID] = time.Now().Add(r.cooldown)
+				}
+			case quality.EvtPeerQuality:
+				for id, q := range ev.Peers {
+					if q.Samples > 0 {
+						r.scores[id] = q.Score
+					}
+				}
+			}
+		case <-ticker.C:
+			r.expire()
+		}
+
+		if needy {
+			r.repair()
+		}
+	}
+}
+
+// setTarget to kmin+hysteresis, or kmax if it is lower.
+func (r *repairer) setTarget(kmin, kmax int) {
+	if r.target = kmin + r.hysteresis; r.target > kmax {
+		r.target = kmax
+	}
+}
+
+// add a candidate to the pool, merging its addresses with those already known.
+func (r repairer) add(info peer.AddrInfo) {
+	if info.ID == r.h.ID() {
+		return
+	}
+
+	if c, ok := r.pool[info.ID]; ok {
+		for _, a := range info.Addrs {
+			if !containsAddr(c.info.Addrs, a) {
+				c.info.Addrs = append(c.info.Addrs, a)
+			}
+		}
+
+		return
+	}
+
+	r.pool[info.ID] = &candidate{info: info}
+}
+
+// expire cooldowns that have elapsed.
+func (r repairer) expire() {
+	now := time.Now()
+	for id, t := range r.pruned {
+		if now.After(t) {
+			delete(r.pruned, id)
+		}
+	}
+}
+
+// repair dials candidates in batches of at most r.concurrency, until the target is
+// reached or the candidates are exhausted.
+func (r repairer) repair() {
+	k := len(r.h.Network().Peers())
+	if k >= r.target {
+		return
+	}
+
+	ev := EvtRepairAttempt{K: k, Target: r.target}
+	for cs := r.candidates(); len(cs) > 0; {
+		need := r.target - len(r.h.Network().Peers())
+		if need <= 0 {
+			break
+		}
+
+		n := min(need, r.concurrency, len(cs))
+		batch := cs[:n]
+		cs = cs[n:]
+
+		ev.Dialed += n
+		ev.Connected += r.dial(batch)
+	}
+
+	if ev.Dialed == 0 {
+		return
+	}
+
+	if err := r.e.Emit(ev); err != nil && err != internal.ErrEmitterClosed {
+		r.log.WithError(err).Error("failed to emit EvtRepairAttempt")
+	}
+}
+
+// dial the candidates concurrently, and return the number of successful dials.
+func (r repairer) dial(cs []*candidate) (connected int) {
+	errs := make([]error, len(cs))
+
+	var wg sync.WaitGroup
+	wg.Add(len(cs))
+	for i, c := range cs {
+		go func(i int, c *candidate) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+			defer cancel()
+
+			errs[i] = r.h.Connect(ctx, c.info)
+		}(i, c)
+	}
+	wg.Wait()
+
+	now := time.Now()
+	for i, c := range cs {
+		if errs[i] == nil {
+			c.failures, c.retry = 0, time.Time{}
+			connected++
+			continue
+		}
+
+		c.failures++
+		c.retry = now.Add(backoff(c.failures))
+		r.log.WithError(errs[i]).Debugf("failed to dial %s", c.info.ID)
+	}
+
+	return
+}
+
+// candidates returns the peers that may be dialed, those with the fewest failures
+// first, and then the best-scored.
+func (r repairer) candidates() []*candidate {
+	// peers of peers
+	for _, id := range r.h.Peerstore().PeersWithAddrs() {
+		if _, ok := r.pool[id]; ok || id == r.h.ID() {
+			continue
+		}
+
+		if ps, err := r.h.Peerstore().SupportsProtocols(id, string(boot.HelloProtocol)); err == nil && len(ps) > 0 {
+			r.add(r.h.Peerstore().PeerInfo(id))
+		}
+	}
+
+	var (
+		now = time.Now()
+		cs  []*candidate
+	)
+
+	for id, c := range r.pool {
+		if r.h.Network().Connectedness(id) == network.Connected {
+			continue
+		}
+
+		if t, ok := r.pruned[id]; ok && now.Before(t) {
+			continue
+		}
+
+		if now.Before(c.retry) {
+			continue
+		}
+
+		cs = append(cs, c)
+	}
+
+	sort.Slice(cs, func(i, j int) bool {
+		if cs[i].failures != cs[j].failures {
+			return cs[i].failures < cs[j].failures
+		}
+
+		if si, sj := r.score(cs[i].info.ID), r.score(cs[j].info.ID); si != sj {
+			return si > sj
+		}
+
+		return cs[i].info.ID < cs[j].info.ID
+	})
+
+	return cs
+}
+
+// score returns the last known quality score of the peer, or quality.Neutral if it
+// was never measured.
+func (r repairer) score(id peer.ID) float64 {
+	if s, ok := r.scores[id]; ok {
+		return s
+	}
+
+	return quality.Neutral
+}
+
+func backoff(failures int) time.Duration {
+	d := minBackoff
+	for i := 1; i < failures && d < maxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+
+	return d
+}
+
+func containsAddr(as []multiaddr.Multiaddr, addr multiaddr.Multiaddr) bool {
+	for _, a := range as {
+		if a.Equal(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func min(ns ...int) int {
+	m := ns[0]
+	for _, n := range ns[1:] {
+		if n < m {
+			m = n
+		}
+	}
+
+	return m
+}
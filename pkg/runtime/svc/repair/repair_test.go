@@ -0,0 +1,240 @@
+package repair_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	eventbus "github.com/libp2p/go-eventbus"
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/peerstore"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+
+	logutil "github.com/wetware/ww/internal/util/log"
+	"github.com/wetware/ww/pkg/boot"
+	"github.com/wetware/ww/pkg/internal/p2p"
+	boot_service "github.com/wetware/ww/pkg/runtime/svc/boot"
+	heartbeat_service "github.com/wetware/ww/pkg/runtime/svc/heartbeat"
+	neighborhood_service "github.com/wetware/ww/pkg/runtime/svc/neighborhood"
+	prune_service "github.com/wetware/ww/pkg/runtime/svc/prune"
+	quality_service "github.com/wetware/ww/pkg/runtime/svc/quality"
+	repair_service "github.com/wetware/ww/pkg/runtime/svc/repair"
+)
+
+func TestRepairLoggable(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mn := mocknet.New(context.Background())
+	h, err := mn.GenPeer()
+	require.NoError(t, err)
+
+	r, err := repair_service.New(repair_service.Config{
+		Host: h,
+		KMin: 8,
+		KMax: 8,
+	}).Factory.NewService()
+	require.NoError(t, err)
+
+	assert.Equal(t, "repair", r.Loggable()["service"])
+	assert.Equal(t, 8, r.Loggable()["repair_target"], "target should not exceed kmax")
+}
+
+func TestRepair(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	mn, err := mocknet.FullMeshLinked(ctx, 7)
+	require.NoError(t, err)
+
+	hs := mn.Hosts()
+	h, pruned, unreachable, remote, departed := hs[0], hs[3], hs[4], hs[5], hs[6]
+	discovered := []host.Host{hs[1], hs[2]}
+	require.NoError(t, mn.UnlinkPeers(h.ID(), unreachable.ID()))
+
+	// remote is a peer of a peer, known only through the peerstore
+	h.Peerstore().AddAddrs(remote.ID(), remote.Addrs(), peerstore.PermanentAddrTTL)
+	require.NoError(t, h.Peerstore().AddProtocols(remote.ID(), string(boot.HelloProtocol)))
+
+	r, err := repair_service.New(repair_service.Config{
+		Log:      logutil.Nop(),
+		Host:     h,
+		KMin:     2,
+		KMax:     8,
+		Interval: time.Millisecond * 50,
+	}).Factory.NewService()
+	require.NoError(t, err)
+
+	sub, err := h.EventBus().Subscribe(new(repair_service.EvtRepairAttempt))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	require.NoError(t, netReady(h.EventBus()))
+	require.NoError(t, r.Start(ctx))
+	defer func() {
+		require.NoError(t, r.Stop(ctx))
+	}()
+
+	ePrune, err := h.EventBus().Emitter(new(prune_service.EvtPeersPruned))
+	require.NoError(t, err)
+	defer ePrune.Close()
+
+	require.NoError(t, ePrune.Emit(prune_service.EvtPeersPruned{
+		Pruned: []prune_service.Pruned{{Peer: pruned.ID()}},
+	}))
+
+	eBoot, err := h.EventBus().Emitter(new(boot_service.EvtPeerDiscovered))
+	require.NoError(t, err)
+	defer eBoot.Close()
+
+	for _, p := range []host.Host{hs[1], hs[2], pruned, unreachable, departed} {
+		require.NoError(t, eBoot.Emit(boot_service.EvtPeerDiscovered(peer.AddrInfo{
+			ID:    p.ID(),
+			Addrs: p.Addrs(),
+		})))
+	}
+
+	eLeft, err := h.EventBus().Emitter(new(heartbeat_service.EvtMemberLeft))
+	require.NoError(t, err)
+	defer eLeft.Close()
+
+	require.NoError(t, eLeft.Emit(heartbeat_service.EvtMemberLeft{Member: heartbeat_service.Member{
+		Heartbeat: heartbeat_service.Heartbeat{ID: departed.ID(), Leaving: true},
+	}}))
+
+	eHood, err := h.EventBus().Emitter(new(neighborhood_service.EvtNeighborhoodChanged))
+	require.NoError(t, err)
+	defer eHood.Close()
+
+	require.NoError(t, eHood.Emit(neighborhood_service.EvtNeighborhoodChanged{
+		To: neighborhood_service.PhaseOrphaned,
+	}))
+
+	// kmin + 1 peers are reachable, excluding the pruned peer
+	var ev repair_service.EvtRepairAttempt
+	for ev.K+ev.Connected < 3 {
+		select {
+		case v := <-sub.Out():
+			ev = v.(repair_service.EvtRepairAttempt)
+			assert.Equal(t, 3, ev.Target)
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		}
+	}
+
+	for _, p := range append(discovered, remote) {
+		assert.Equal(t, network.Connected, h.Network().Connectedness(p.ID()))
+	}
+
+	assert.NotEqual(t, network.Connected, h.Network().Connectedness(pruned.ID()),
+		"pruned peer should not be dialed during cooldown")
+	assert.NotEqual(t, network.Connected, h.Network().Connectedness(departed.ID()),
+		"departed peer should not be dialed during cooldown")
+	assert.NotEqual(t, network.Connected, h.Network().Connectedness(unreachable.ID()))
+
+	// the target has been reached
+	select {
+	case v := <-sub.Out():
+		t.Errorf("dialed after reaching target:  %v", v)
+	case <-time.After(time.Millisecond * 150):
+	}
+}
+
+func TestRepairByQuality(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	mn, err := mocknet.FullMeshLinked(ctx, 4)
+	require.NoError(t, err)
+
+	hs := mn.Hosts()
+	h, poor, unmeasured, good := hs[0], hs[1], hs[2], hs[3]
+
+	r, err := repair_service.New(repair_service.Config{
+		Log:         logutil.Nop(),
+		Host:        h,
+		KMin:        1,
+		KMax:        1,
+		Concurrency: 1,
+		Interval:    time.Millisecond * 50,
+	}).Factory.NewService()
+	require.NoError(t, err)
+
+	sub, err := h.EventBus().Subscribe(new(repair_service.EvtRepairAttempt))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	require.NoError(t, netReady(h.EventBus()))
+	require.NoError(t, r.Start(ctx))
+	defer func() {
+		require.NoError(t, r.Stop(ctx))
+	}()
+
+	// scores reported while the peers were previously connected
+	eQuality, err := h.EventBus().Emitter(new(quality_service.EvtPeerQuality))
+	require.NoError(t, err)
+	defer eQuality.Close()
+
+	require.NoError(t, eQuality.Emit(quality_service.EvtPeerQuality{
+		Peers: map[peer.ID]quality_service.Quality{
+			poor.ID(): {Samples: 1, Score: .1},
+			good.ID(): {Samples: 1, Score: .9},
+		},
+	}))
+
+	eBoot, err := h.EventBus().Emitter(new(boot_service.EvtPeerDiscovered))
+	require.NoError(t, err)
+	defer eBoot.Close()
+
+	for _, p := range []host.Host{poor, unmeasured, good} {
+		require.NoError(t, eBoot.Emit(boot_service.EvtPeerDiscovered(peer.AddrInfo{
+			ID:    p.ID(),
+			Addrs: p.Addrs(),
+		})))
+	}
+
+	eHood, err := h.EventBus().Emitter(new(neighborhood_service.EvtNeighborhoodChanged))
+	require.NoError(t, err)
+	defer eHood.Close()
+
+	require.NoError(t, eHood.Emit(neighborhood_service.EvtNeighborhoodChanged{
+		To: neighborhood_service.PhaseOrphaned,
+	}))
+
+	select {
+	case v := <-sub.Out():
+		ev := v.(repair_service.EvtRepairAttempt)
+		assert.Equal(t, 1, ev.Dialed)
+		assert.Equal(t, 1, ev.Connected)
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	}
+
+	assert.Equal(t, network.Connected, h.Network().Connectedness(good.ID()),
+		"historically good peer should be dialed first")
+	assert.NotEqual(t, network.Connected, h.Network().Connectedness(poor.ID()))
+	assert.NotEqual(t, network.Connected, h.Network().Connectedness(unmeasured.ID()))
+}
+
+// netReady emits p2p.EvtNetworkReady
+func netReady(bus event.Bus) error {
+	e, err := bus.Emitter(new(p2p.EvtNetworkReady), eventbus.Stateful)
+	if err != nil {
+		return err
+	}
+
+	return e.Emit(p2p.EvtNetworkReady{})
+}
@@ -0,0 +1,211 @@
+// Package streams detects slow consumers among inbound RPC streams.
+package streams
+
+import (
+	"context"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"go.uber.org/fx"
+	"go.uber.org/multierr"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/internal/rpc"
+	"github.com/wetware/ww/pkg/runtime"
+	"github.com/wetware/ww/pkg/runtime/svc/internal"
+	"github.com/wetware/ww/pkg/runtime/svc/ticker"
+)
+
+// EvtSlowConsumer is emitted when a stream's outbound queue has remained above the
+// policy threshold for longer than the grace period.
+type EvtSlowConsumer struct {
+	Peer     peer.ID
+	Protocol protocol.ID
+	Stream   uint64
+
+	// Depth of the outbound queue, in bytes, when the event was emitted.
+	Depth int64
+
+	// Duration for which the queue has exceeded the threshold.
+	Duration time.Duration
+
+	// Closed is true if the stream was reset in accordance with the policy.
+	Closed bool
+}
+
+// Policy for slow-consumer detection.
+type Policy struct {
+	// Threshold is the outbound queue depth, in bytes, above which a stream is
+	// considered to be lagging.  Defaults to 64KiB.
+	Threshold int64
+
+	// Grace is the length of time a stream may lag before it is flagged as a slow
+	// consumer.  Defaults to 5s.
+	Grace time.Duration
+
+	// Close slow consumers after flagging them.
+	Close bool
+}
+
+// Config for Streams service.
+type Config struct {
+	fx.In
+
+	Log    ww.Logger `optional:"true"`
+	Bus    event.Bus
+	Stats  *rpc.StreamStats
+	Policy Policy `optional:"true"`
+}
+
+// NewService satisfies runtime.ServiceFactory.
+func (cfg Config) NewService() (runtime.Service, error) {
+	if cfg.Policy.Threshold == 0 {
+		cfg.Policy.Threshold = 64 * 1024
+	}
+
+	if cfg.Policy.Grace == 0 {
+		cfg.Policy.Grace = time.Second * 5
+	}
+
+	sub, err := cfg.Bus.Subscribe(new(ticker.EvtTimestep))
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := internal.NewEmitter(cfg.Bus, new(EvtSlowConsumer))
+	if err != nil {
+		return nil, err
+	}
+
+	d := &detector{
+		stats:  cfg.Stats,
+		policy: cfg.Policy,
+		sub:    sub,
+		e:      e,
+		lag:    make(map[uint64]time.Duration),
+	}
+	d.log = internal.Logger(cfg.Log, d)
+
+	return d, nil
+}
+
+// Produces EvtSlowConsumer.
+func (cfg Config) Produces() []interface{} {
+	return []interface{}{
+		EvtSlowConsumer{},
+	}
+}
+
+// Consumes ticker.EvtTimestep.
+func (cfg Config) Consumes() []interface{} {
+	return []interface{}{
+		ticker.EvtTimestep{},
+	}
+}
+
+// Module for Streams service.
+type Module struct {
+	fx.Out
+
+	Factory runtime.ServiceFactory `group:"runtime"`
+}
+
+// New Streams service.  Flags inbound RPC streams whose outbound queue remains above
+// a threshold, and optionally resets them.
+//
+// consumes:
+//   - ticker.EvtTimestep
+//
+// emits:
+//   - EvtSlowConsumer
+func New(cfg Config) Module { return Module{Factory: cfg} }
+
+type detector struct {
+	log    ww.Logger
+	stats  *rpc.StreamStats
+	policy Policy
+
+	sub event.Subscription
+	e   event.Emitter
+
+	lag map[uint64]time.Duration
+}
+
+func (d *detector) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"service":   "streams",
+		"threshold": d.policy.Threshold,
+		"grace":     d.policy.Grace,
+	}
+}
+
+func (d *detector) Start(ctx context.Context) error {
+	internal.StartBackground(ctx, d.loop)
+	return nil
+}
+
+func (d *detector) Stop(context.Context) error {
+	return multierr.Combine(
+		d.sub.Close(),
+		d.e.Close(),
+	)
+}
+
+func (d *detector) loop() {
+	for v := range d.sub.Out() {
+		d.Advance(v.(ticker.EvtTimestep).Delta)
+	}
+}
+
+// Advance the detector's clock, flagging streams that have lagged for longer than the
+// grace period.
+func (d *detector) Advance(delta time.Duration) {
+	active := make(map[uint64]struct{})
+
+	for _, s := range d.stats.Streams() {
+		active[s.ID] = struct{}{}
+
+		if s.QueueDepth < d.policy.Threshold {
+			delete(d.lag, s.ID)
+			continue
+		}
+
+		before := d.lag[s.ID]
+		d.lag[s.ID] = before + delta
+
+		// flag each stream once per lagging episode
+		if before < d.policy.Grace && d.lag[s.ID] >= d.policy.Grace {
+			d.flag(s, d.lag[s.ID])
+		}
+	}
+
+	for id := range d.lag {
+		if _, ok := active[id]; !ok {
+			delete(d.lag, id)
+		}
+	}
+}
+
+func (d *detector) flag(s rpc.StreamInfo, lag time.Duration) {
+	ev := EvtSlowConsumer{
+		Peer:     s.Peer,
+		Protocol: s.Protocol,
+		Stream:   s.ID,
+		Depth:    s.QueueDepth,
+		Duration: lag,
+	}
+
+	if d.policy.Close {
+		if err := d.stats.Reset(s.ID); err != nil {
+			d.log.WithError(err).Debug("failed to reset slow consumer")
+		} else {
+			ev.Closed = true
+		}
+	}
+
+	if err := d.e.Emit(ev); err != nil && err != internal.ErrEmitterClosed {
+		d.log.WithError(err).Error("failed to emit EvtSlowConsumer")
+	}
+}
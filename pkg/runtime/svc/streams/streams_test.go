@@ -0,0 +1,143 @@
+package streams_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	eventbus "github.com/libp2p/go-eventbus"
+	"github.com/libp2p/go-libp2p-core/mux"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/wetware/ww/pkg/internal/rpc"
+	streams_service "github.com/wetware/ww/pkg/runtime/svc/streams"
+	"github.com/wetware/ww/pkg/runtime/svc/ticker"
+)
+
+func TestSlowConsumer(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	bus := eventbus.NewBus()
+	stats := rpc.NewStreamStats()
+
+	s := newBlockingStream("/test")
+	defer s.Unblock()
+
+	// start a write that cannot complete
+	go stats.Track(s).Write(make([]byte, 128))
+	require.Eventually(t, func() bool {
+		ss := stats.Streams()
+		return len(ss) == 1 && ss[0].QueueDepth == 128
+	}, time.Second, time.Millisecond)
+
+	svc, err := streams_service.New(streams_service.Config{
+		Bus:   bus,
+		Stats: stats,
+		Policy: streams_service.Policy{
+			Threshold: 64,
+			Grace:     time.Second,
+			Close:     true,
+		},
+	}).Factory.NewService()
+	require.NoError(t, err)
+
+	sub, err := bus.Subscribe(new(streams_service.EvtSlowConsumer))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	e, err := bus.Emitter(new(ticker.EvtTimestep))
+	require.NoError(t, err)
+	defer e.Close()
+
+	require.NoError(t, svc.Start(ctx))
+	defer func() {
+		require.NoError(t, svc.Stop(ctx))
+	}()
+
+	// below the grace period
+	require.NoError(t, e.Emit(ticker.EvtTimestep{Delta: time.Millisecond * 500}))
+	select {
+	case v := <-sub.Out():
+		t.Fatalf("unexpected event %v", v)
+	case <-time.After(time.Millisecond * 10):
+	}
+
+	// exceeds the grace period
+	require.NoError(t, e.Emit(ticker.EvtTimestep{Delta: time.Millisecond * 500}))
+	select {
+	case v := <-sub.Out():
+		ev := v.(streams_service.EvtSlowConsumer)
+		assert.Equal(t, protocol.ID("/test"), ev.Protocol)
+		assert.Equal(t, int64(128), ev.Depth)
+		assert.Equal(t, time.Second, ev.Duration)
+		assert.True(t, ev.Closed)
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for EvtSlowConsumer")
+	}
+
+	assert.True(t, s.WasReset())
+	assert.Empty(t, stats.Streams())
+
+	ps := stats.Protocols()
+	require.Len(t, ps, 1)
+	assert.Equal(t, uint64(1), ps[0].Opened)
+	assert.Equal(t, uint64(1), ps[0].Closed)
+	assert.Zero(t, ps[0].Active)
+}
+
+// blockingStream is a stream whose writes block until it is unblocked or reset.
+type blockingStream struct {
+	network.Stream
+	proto protocol.ID
+	cq    chan struct{}
+	reset chan struct{}
+}
+
+func newBlockingStream(proto protocol.ID) *blockingStream {
+	return &blockingStream{
+		proto: proto,
+		cq:    make(chan struct{}),
+		reset: make(chan struct{}),
+	}
+}
+
+func (s *blockingStream) Protocol() protocol.ID { return s.proto }
+func (s *blockingStream) Conn() network.Conn    { return conn{} }
+func (s *blockingStream) Unblock()              { close(s.cq) }
+
+func (s *blockingStream) Write(b []byte) (int, error) {
+	select {
+	case <-s.cq:
+	case <-s.reset:
+	}
+	return 0, mux.ErrReset
+}
+
+func (s *blockingStream) Reset() error {
+	close(s.reset)
+	return nil
+}
+
+func (s *blockingStream) WasReset() bool {
+	select {
+	case <-s.reset:
+		return true
+	default:
+		return false
+	}
+}
+
+type conn struct{ network.Conn }
+
+func (conn) RemotePeer() peer.ID { return peer.ID("test") }
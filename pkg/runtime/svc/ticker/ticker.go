@@ -7,6 +7,7 @@ import (
 	"github.com/libp2p/go-libp2p-core/event"
 	ww "github.com/wetware/ww/pkg"
 	"github.com/wetware/ww/pkg/runtime"
+	"github.com/wetware/ww/pkg/runtime/svc/internal"
 	"go.uber.org/fx"
 )
 
@@ -14,14 +15,14 @@ import (
 type Config struct {
 	fx.In
 
-	Log  ww.Logger
+	Log  ww.Logger `optional:"true"`
 	Bus  event.Bus
 	Step time.Duration `name:"tick" optional:"true"`
 }
 
 // NewService satisfies runtime.ServiceFactory
 func (cfg Config) NewService() (runtime.Service, error) {
-	e, err := cfg.Bus.Emitter(new(EvtTimestep))
+	e, err := internal.NewEmitter(cfg.Bus, new(EvtTimestep))
 	if err != nil {
 		return nil, err
 	}
@@ -30,12 +31,14 @@ func (cfg Config) NewService() (runtime.Service, error) {
 		cfg.Step = time.Millisecond * 100
 	}
 
-	return &ticker{
-		log:  cfg.Log,
+	t := &ticker{
 		step: cfg.Step,
 		cq:   make(chan struct{}),
 		e:    e,
-	}, nil
+	}
+	t.log = internal.Logger(cfg.Log, t)
+
+	return t, nil
 }
 
 // Produces EvtTimestep
@@ -79,29 +82,34 @@ func (t ticker) Loggable() map[string]interface{} {
 	}
 }
 
-func (t *ticker) Start(context.Context) error {
+func (t *ticker) Start(ctx context.Context) error {
 	t.t = time.NewTicker(t.step)
-	go t.loop()
+	internal.StartBackground(ctx, t.loop)
 	return nil
 }
 
 func (t ticker) loop() {
-
 	var ts EvtTimestep
-	for tick := range t.t.C {
-		ts.Delta = tick.Sub(ts.Time)
-		ts.Time = tick
-		t.emit(ts)
+	for {
+		select {
+		case <-t.cq:
+			return
+		case tick := <-t.t.C:
+			ts.Delta = tick.Sub(ts.Time)
+			ts.Time = tick
+			t.emit(ts)
+		}
 	}
 }
 
 func (t ticker) Stop(context.Context) error {
 	t.t.Stop()
+	close(t.cq)
 	return t.e.Close()
 }
 
 func (t ticker) emit(ev EvtTimestep) {
-	if err := t.e.Emit(ev); err != nil {
-		t.log.With(t).WithError(err).Error("failed to emit EvtTimestep")
+	if err := t.e.Emit(ev); err != nil && err != internal.ErrEmitterClosed {
+		t.log.WithError(err).Error("failed to emit EvtTimestep")
 	}
 }
@@ -10,7 +10,6 @@ import (
 	"github.com/stretchr/testify/require"
 
 	eventbus "github.com/libp2p/go-eventbus"
-	mock_ww "github.com/wetware/ww/internal/test/mock/pkg"
 	tick_service "github.com/wetware/ww/pkg/runtime/svc/ticker"
 )
 
@@ -21,7 +20,6 @@ func TestLoggable(t *testing.T) {
 	defer ctrl.Finish()
 
 	tk, err := tick_service.New(tick_service.Config{
-		Log: mock_ww.NewMockLogger(ctrl),
 		Bus: eventbus.NewBus(),
 	}).Factory.NewService()
 	require.NoError(t, err)
@@ -39,11 +37,9 @@ func TestTicker(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 
-	logger := mock_ww.NewMockLogger(ctrl)
 	bus := eventbus.NewBus()
 
 	tk, err := tick_service.New(tick_service.Config{
-		Log: logger,
 		Bus: bus,
 	}).Factory.NewService()
 	require.NoError(t, err)
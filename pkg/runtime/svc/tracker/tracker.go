@@ -15,6 +15,8 @@ import (
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/peerstore"
 	"github.com/libp2p/go-libp2p-core/protocol"
+
+	ww "github.com/wetware/ww/pkg"
 	"github.com/wetware/ww/pkg/runtime"
 	"github.com/wetware/ww/pkg/runtime/svc/internal"
 )
@@ -46,6 +48,7 @@ type (
 type Config struct {
 	fx.In
 
+	Log  ww.Logger `optional:"true"`
 	Host host.Host
 }
 
@@ -68,11 +71,11 @@ func (cfg Config) NewService() (svc runtime.Service, err error) {
 		return
 	}
 
-	if t.emitConn, err = bus.Emitter(new(EvtConnectionChanged)); err != nil {
+	if t.emitConn, err = internal.NewEmitter(bus, new(EvtConnectionChanged)); err != nil {
 		return
 	}
 
-	if t.emitStream, err = bus.Emitter(new(EvtStreamChanged)); err != nil {
+	if t.emitStream, err = internal.NewEmitter(bus, new(EvtStreamChanged)); err != nil {
 		return
 	}
 
@@ -82,10 +85,11 @@ func (cfg Config) NewService() (svc runtime.Service, err error) {
 		return
 	}
 
-	if t.emitPeer, err = bus.Emitter(new(event.EvtPeerConnectednessChanged)); err != nil {
+	if t.emitPeer, err = internal.NewEmitter(bus, new(event.EvtPeerConnectednessChanged)); err != nil {
 		return
 	}
 
+	t.log = internal.Logger(cfg.Log, t)
 	cfg.Host.Network().Notify(t.notifiee())
 
 	return t, nil
@@ -103,6 +107,14 @@ func (cfg Config) Produces() []interface{} {
 	}
 }
 
+// Public EvtStreamChanged, which no service consumes, but which is available to
+// applications.
+func (cfg Config) Public() []interface{} {
+	return []interface{}{
+		EvtStreamChanged{},
+	}
+}
+
 // Consumes event.EvtPeerIdentificationCompleted, event.EvtPeerIdentificationFailed & EvtConnectionChanged
 func (cfg Config) Consumes() []interface{} {
 	// We don't export events dispatched by libp2p since these are available by default
@@ -121,7 +133,8 @@ type Module struct {
 
 // conntracker emits events whenever connections are created or destroyed.
 type conntracker struct {
-	m peerstore.PeerMetadata
+	log ww.Logger
+	m   peerstore.PeerMetadata
 
 	idsub, connsub                 event.Subscription
 	emitConn, emitStream, emitPeer event.Emitter
@@ -134,13 +147,13 @@ type conntracker struct {
 // New Tracker service.  Monitors peer connections.
 //
 // consumes:
-// 	- event.EvtPeerIdentificationCompleted  [ libp2p ]
-//  - event.EvtPeerIdentificationFailed     [ libp2p ]
+//   - event.EvtPeerIdentificationCompleted  [ libp2p ]
+//   - event.EvtPeerIdentificationFailed     [ libp2p ]
 //
 // emits:
-//  - EvtPeerConnectednessChanged [ libp2p ]
-//  - EvtConnectionChanged
-//  - EvtStreamChanged
+//   - EvtPeerConnectednessChanged [ libp2p ]
+//   - EvtConnectionChanged
+//   - EvtStreamChanged
 func New(cfg Config) Module { return Module{Factory: cfg} }
 
 // Loggable representation of conntracker
@@ -149,8 +162,8 @@ func (t *conntracker) Loggable() map[string]interface{} {
 }
 
 // Start service
-func (t *conntracker) Start(context.Context) error {
-	internal.StartBackground(
+func (t *conntracker) Start(ctx context.Context) error {
+	internal.StartBackground(ctx,
 		t.idloop,
 		t.connloop,
 	)
@@ -165,7 +178,9 @@ func (t *conntracker) Stop(context.Context) error {
 	return multierr.Combine(
 		t.emitConn.Close(),
 		t.emitStream.Close(),
+		t.emitPeer.Close(),
 		t.idsub.Close(),
+		t.connsub.Close(),
 	)
 }
 
@@ -174,7 +189,7 @@ func (t *conntracker) idloop() {
 		switch ev := v.(type) {
 		case event.EvtPeerIdentificationCompleted:
 			t.ensureChan(ev.Peer) <- true // buffered; nonblocking
-			t.emitConn.Emit(EvtConnectionChanged{
+			t.emit(t.emitConn, EvtConnectionChanged{
 				Peer:   ev.Peer,
 				Client: t.isClient(ev.Peer),
 				State:  ConnStateOpened,
@@ -205,7 +220,7 @@ func (t *conntracker) connloop() {
 		}
 
 		if emit {
-			t.emitPeer.Emit(event.EvtPeerConnectednessChanged{
+			t.emit(t.emitPeer, event.EvtPeerConnectednessChanged{
 				Peer:          ev.Peer,
 				Connectedness: peerstate(ev.State),
 			})
@@ -239,7 +254,7 @@ func (t *conntracker) onDisconnected(net network.Network, conn network.Conn) {
 	select {
 	case ok := <-t.ensureChan(conn.RemotePeer()):
 		if ok {
-			t.emitConn.Emit(EvtConnectionChanged{
+			t.emit(t.emitConn, EvtConnectionChanged{
 				Peer:   conn.RemotePeer(),
 				Client: t.isClient(conn.RemotePeer()),
 				State:  ConnStateClosed,
@@ -254,7 +269,7 @@ func (t *conntracker) onDisconnected(net network.Network, conn network.Conn) {
 }
 
 func (t *conntracker) onStreamOpened(net network.Network, s network.Stream) {
-	t.emitStream.Emit(EvtStreamChanged{
+	t.emit(t.emitStream, EvtStreamChanged{
 		Peer:   s.Conn().RemotePeer(),
 		Stream: s,
 		State:  StreamStateOpened,
@@ -262,13 +277,19 @@ func (t *conntracker) onStreamOpened(net network.Network, s network.Stream) {
 }
 
 func (t *conntracker) onStreamClosed(net network.Network, s network.Stream) {
-	t.emitStream.Emit(EvtStreamChanged{
+	t.emit(t.emitStream, EvtStreamChanged{
 		Peer:   s.Conn().RemotePeer(),
 		Stream: s,
 		State:  StreamStateClosed,
 	})
 }
 
+func (t *conntracker) emit(e event.Emitter, ev interface{}) {
+	if err := e.Emit(ev); err != nil && err != internal.ErrEmitterClosed {
+		t.log.WithError(err).Errorf("failed to emit %T", ev)
+	}
+}
+
 // isClient distinguishes between client and host connections using low-level peerstore
 // metadata.  This method should not be used outside of the event loop.
 //
@@ -0,0 +1,196 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	eventbus "github.com/libp2p/go-eventbus"
+	"github.com/libp2p/go-libp2p-core/event"
+
+	ww "github.com/wetware/ww/pkg"
+)
+
+const (
+	// DefaultTapSize is the default number of events recorded by a Tap.
+	DefaultTapSize = 1024
+
+	// maximum length of a rendered field, or of a rendered event without fields
+	maxSummary = 128
+)
+
+// TapRecord is an event observed by a Tap.
+type TapRecord struct {
+	Time time.Time
+	Type string
+
+	// Producer is the name of the service that produced the event, or the empty
+	// string if more than one service produces events of its type.
+	Producer string
+
+	// Summary of the event's payload.  Large fields are elided.
+	Summary string
+}
+
+// Tap records the events emitted on the runtime's bus, for the purpose of debugging.
+// It records the events produced by any of the runtime's services, as declared by
+// EventProducer, in a ring buffer.  It is safe for concurrent use.
+//
+// A Tap never blocks emitters.  Followers that do not keep up lose events, which are
+// counted by Dropped.
+type Tap struct {
+	mu   sync.Mutex
+	ring []TapRecord
+	next int  // position of the next record in ring
+	full bool // ring has wrapped
+	fs   map[chan TapRecord]struct{}
+
+	dropped uint64 // atomic
+}
+
+// NewTap returns a Tap that records the last size events.  If size is zero,
+// DefaultTapSize is used.
+func NewTap(size int) *Tap {
+	if size <= 0 {
+		size = DefaultTapSize
+	}
+
+	return &Tap{
+		ring: make([]TapRecord, size),
+		fs:   make(map[chan TapRecord]struct{}),
+	}
+}
+
+// Events returns the recorded events, oldest first.
+func (t *Tap) Events() []TapRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.full {
+		return append([]TapRecord(nil), t.ring[:t.next]...)
+	}
+
+	return append(append([]TapRecord(nil), t.ring[t.next:]...), t.ring[:t.next]...)
+}
+
+// Follow returns a channel on which events are delivered as they are recorded, until
+// the context expires.  At most buf events are buffered; events that arrive while the
+// buffer is full are dropped.
+func (t *Tap) Follow(ctx context.Context, buf int) <-chan TapRecord {
+	ch := make(chan TapRecord, buf)
+
+	t.mu.Lock()
+	t.fs[ch] = struct{}{}
+	t.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		t.mu.Lock()
+		delete(t.fs, ch)
+		t.mu.Unlock()
+
+		close(ch)
+	}()
+
+	return ch
+}
+
+// Dropped returns the number of events that followers failed to receive.
+func (t *Tap) Dropped() uint64 { return atomic.LoadUint64(&t.dropped) }
+
+func (t *Tap) record(r TapRecord) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.ring[t.next] = r
+	if t.next++; t.next == len(t.ring) {
+		t.next, t.full = 0, true
+	}
+
+	for ch := range t.fs {
+		select {
+		case ch <- r:
+		default:
+			atomic.AddUint64(&t.dropped, 1)
+		}
+	}
+}
+
+// attach the tap to the bus, and record the events in the graph that have at least one
+// producer.  The returned subscription must be closed to detach the tap.
+func (t *Tap) attach(log ww.Logger, bus event.Bus, g EventGraph) (event.Subscription, error) {
+	var (
+		ts        []interface{}
+		producers = make(map[reflect.Type]string)
+	)
+
+	for _, n := range g.Events {
+		if len(n.Producers) == 0 {
+			continue
+		}
+
+		ts = append(ts, reflect.New(n.Type).Interface())
+		if len(n.Producers) == 1 {
+			producers[n.Type] = n.Producers[0]
+		}
+	}
+
+	sub, err := bus.Subscribe(ts, eventbus.BufSize(len(t.ring)))
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for v := range sub.Out() {
+			typ := reflect.TypeOf(v)
+			t.record(TapRecord{
+				Time:     time.Now(),
+				Type:     typ.String(),
+				Producer: producers[typ],
+				Summary:  summarize(v),
+			})
+		}
+	}()
+
+	log.WithField("events", len(ts)).Debug("event tap attached")
+	return sub, nil
+}
+
+// summarize the event using its Loggable representation or String method, if any.
+func summarize(v interface{}) string {
+	switch ev := v.(type) {
+	case ww.Loggable:
+		fs := ev.Loggable()
+
+		keys := make([]string, 0, len(fs))
+		for k := range fs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for i, k := range keys {
+			keys[i] = k + "=" + elide(fmt.Sprint(fs[k]))
+		}
+
+		return strings.Join(keys, " ")
+
+	case fmt.Stringer:
+		return elide(ev.String())
+	}
+
+	return elide(fmt.Sprintf("%+v", v))
+}
+
+func elide(s string) string {
+	if len(s) <= maxSummary {
+		return s
+	}
+
+	return s[:maxSummary] + "…"
+}
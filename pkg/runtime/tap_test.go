@@ -0,0 +1,116 @@
+package runtime_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx/fxtest"
+
+	eventbus "github.com/libp2p/go-eventbus"
+
+	"github.com/wetware/ww/pkg/runtime"
+)
+
+func TestTap(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Record", func(t *testing.T) {
+		t.Parallel()
+
+		tap := runtime.NewTap(0)
+		bus := eventbus.NewBus()
+		lx := fxtest.NewLifecycle(t)
+		require.NoError(t, runtime.Start(runtime.Config{
+			Bus:      bus,
+			Tap:      tap,
+			Services: []runtime.ServiceFactory{&crashFactory{}},
+		}, lx))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		follow := tap.Follow(ctx, 16)
+
+		lx.RequireStart()
+		defer lx.RequireStop()
+
+		select {
+		case r := <-follow:
+			assert.Equal(t, "runtime.EvtServiceStateChanged", r.Type)
+			assert.Equal(t, "runtime", r.Producer)
+			assert.Contains(t, r.Summary, "To:starting")
+		case <-time.After(time.Second):
+			t.Fatal("event not followed")
+		}
+
+		require.Eventually(t, func() bool {
+			es := tap.Events()
+			return len(es) > 0 && es[len(es)-1].Type == "runtime.EvtRuntimeStarted"
+		}, time.Second, time.Millisecond, "EvtRuntimeStarted should be recorded last")
+	})
+
+	t.Run("Overflow", func(t *testing.T) {
+		t.Parallel()
+
+		tap := runtime.NewTap(2)
+		bus := eventbus.NewBus()
+		lx := fxtest.NewLifecycle(t)
+		require.NoError(t, runtime.Start(runtime.Config{
+			Bus: bus,
+			Tap: tap,
+		}, lx))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		follow := tap.Follow(ctx, 0) // never ready
+
+		e, err := bus.Emitter(new(runtime.EvtRuntimeStarted))
+		require.NoError(t, err)
+		defer e.Close()
+
+		for i := 1; i <= 3; i++ {
+			require.NoError(t, e.Emit(runtime.EvtRuntimeStarted{Services: i}))
+		}
+
+		require.Eventually(t, func() bool { return tap.Dropped() == 3 },
+			time.Second, time.Millisecond, "emitters should not wait for followers")
+
+		es := tap.Events()
+		require.Len(t, es, 2, "oldest event should be overwritten")
+		assert.Equal(t, "{Services:2}", es[0].Summary)
+		assert.Equal(t, "{Services:3}", es[1].Summary)
+
+		cancel()
+		_, ok := <-follow
+		assert.False(t, ok, "follower should be closed with its context")
+	})
+
+	t.Run("Elide", func(t *testing.T) {
+		t.Parallel()
+
+		tap := runtime.NewTap(1)
+		bus := eventbus.NewBus()
+		lx := fxtest.NewLifecycle(t)
+		require.NoError(t, runtime.Start(runtime.Config{
+			Bus: bus,
+			Tap: tap,
+		}, lx))
+
+		e, err := bus.Emitter(new(runtime.EvtServiceFailed))
+		require.NoError(t, err)
+		defer e.Close()
+
+		require.NoError(t, e.Emit(runtime.EvtServiceFailed{
+			Service: map[string]interface{}{"service": strings.Repeat("x", 1024)},
+		}))
+
+		require.Eventually(t, func() bool { return len(tap.Events()) == 1 },
+			time.Second, time.Millisecond)
+		assert.Less(t, len(tap.Events()[0].Summary), 256, "large fields should be elided")
+	})
+}
@@ -0,0 +1,111 @@
+package anchorutil
+
+import (
+	"context"
+	"fmt"
+
+	ww "github.com/wetware/ww/pkg"
+)
+
+// Op is a set of anchor operations.
+type Op uint8
+
+// Operations that can be granted to an attenuated anchor.  Walk is always permitted,
+// since it cannot ascend above the anchor.
+const (
+	OpLoad Op = 1 << iota
+	OpStore
+	OpGo
+	OpLs
+
+	// ReadOnly permits inspecting the subtree without modifying it.
+	ReadOnly = OpLoad | OpLs
+
+	// AllOps permits every operation.
+	AllOps = OpLoad | OpStore | OpGo | OpLs
+)
+
+var opNames = map[string]Op{
+	"load":  OpLoad,
+	"store": OpStore,
+	"go":    OpGo,
+	"ls":    OpLs,
+}
+
+// ParseOp returns the operation with the given name, i.e. one of "load", "store",
+// "go" or "ls".
+func ParseOp(name string) (Op, error) {
+	if op, ok := opNames[name]; ok {
+		return op, nil
+	}
+
+	return 0, fmt.Errorf("unknown anchor operation '%s'", name)
+}
+
+// Attenuate returns an anchor that only permits the given operations on a and its
+// descendants.  Other operations fail with ww.ErrNotPermitted.  Anchors obtained by
+// walking or listing the result are attenuated in the same way.
+//
+// Attenuating an attenuated anchor intersects the permitted operations.
+func Attenuate(a ww.Anchor, ops Op) ww.Anchor {
+	if r, ok := a.(restricted); ok {
+		return restricted{Anchor: r.Anchor, ops: r.ops & ops}
+	}
+
+	return restricted{Anchor: a, ops: ops}
+}
+
+type restricted struct {
+	ww.Anchor
+	ops Op
+}
+
+func (r restricted) Ls(ctx context.Context) ([]ww.Anchor, error) {
+	return Collect(r.Iter(ctx))
+}
+
+func (r restricted) Iter(ctx context.Context) ww.AnchorIterator {
+	if err := r.check(OpLs, "ls"); err != nil {
+		return ErrIter(err)
+	}
+
+	return MapIter(r.Anchor.Iter(ctx), func(a ww.Anchor) ww.Anchor {
+		return restricted{Anchor: a, ops: r.ops}
+	})
+}
+
+func (r restricted) Walk(ctx context.Context, path []string) ww.Anchor {
+	return restricted{Anchor: r.Anchor.Walk(ctx, path), ops: r.ops}
+}
+
+func (r restricted) Load(ctx context.Context) (ww.Any, error) {
+	if err := r.check(OpLoad, "load"); err != nil {
+		return nil, err
+	}
+
+	return r.Anchor.Load(ctx)
+}
+
+func (r restricted) Store(ctx context.Context, any ww.Any) error {
+	if err := r.check(OpStore, "store"); err != nil {
+		return err
+	}
+
+	return r.Anchor.Store(ctx, any)
+}
+
+func (r restricted) Go(ctx context.Context, args ...ww.Any) (ww.Any, error) {
+	if err := r.check(OpGo, "go"); err != nil {
+		return nil, err
+	}
+
+	return r.Anchor.Go(ctx, args...)
+}
+
+func (r restricted) check(op Op, name string) error {
+	if r.ops&op == 0 {
+		return fmt.Errorf("%w: %s", ww.ErrNotPermitted, name)
+	}
+
+	return nil
+}
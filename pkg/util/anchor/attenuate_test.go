@@ -0,0 +1,56 @@
+package anchorutil_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	ww "github.com/wetware/ww/pkg"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
+)
+
+func TestAttenuate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := anchorutil.Attenuate(tree("/foo/bar", "/foo/baz"), anchorutil.ReadOnly)
+
+	foo := root.Walk(ctx, []string{"foo"})
+	_, err := foo.Load(ctx)
+	assert.NoError(t, err)
+
+	err = foo.Store(ctx, nil)
+	assert.True(t, errors.Is(err, ww.ErrNotPermitted), "unexpected error %v", err)
+
+	_, err = foo.Go(ctx)
+	assert.True(t, errors.Is(err, ww.ErrNotPermitted), "unexpected error %v", err)
+
+	as, err := foo.Ls(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, as, 2)
+
+	for _, a := range as {
+		err = a.Store(ctx, nil)
+		assert.True(t, errors.Is(err, ww.ErrNotPermitted), "listed anchor %v not attenuated", a.Path())
+	}
+
+	// attenuating again can only remove operations
+	loadOnly := anchorutil.Attenuate(foo, anchorutil.OpLoad|anchorutil.OpStore)
+	assert.True(t, errors.Is(loadOnly.Store(ctx, nil), ww.ErrNotPermitted))
+
+	_, err = loadOnly.Ls(ctx)
+	assert.True(t, errors.Is(err, ww.ErrNotPermitted), "unexpected error %v", err)
+}
+
+func TestParseOp(t *testing.T) {
+	t.Parallel()
+
+	op, err := anchorutil.ParseOp("ls")
+	assert.NoError(t, err)
+	assert.Equal(t, anchorutil.OpLs, op)
+
+	_, err = anchorutil.ParseOp("delete")
+	assert.Error(t, err)
+}
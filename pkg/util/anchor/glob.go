@@ -0,0 +1,98 @@
+// Package anchorutil contains utilities for working with anchors.
+package anchorutil
+
+import (
+	"context"
+	"path"
+
+	ww "github.com/wetware/ww/pkg"
+	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
+)
+
+// Glob returns the anchors below root whose paths match the pattern.  Within a path
+// component, the syntax is that of path.Match.  A component equal to
+// anchorpath.Globstar matches any number of components, including none.
+//
+// The literal prefix of the pattern is walked directly.  Each remaining component
+// costs one listing per matching anchor.  Children are matched as they are listed, so
+// those that do not match are released without being held.  Anchors are returned in the order they are
+// listed, without duplicates.  A pattern without glob components yields the anchor
+// at that path.
+//
+// Anchors visited along the way are released; the caller owns the results.
+func Glob(ctx context.Context, root ww.Anchor, pattern []string) ([]ww.Anchor, error) {
+	var i int
+	for i < len(pattern) && !anchorpath.IsGlob(pattern[i:i+1]) {
+		i++
+	}
+
+	base := root.Walk(ctx, anchorpath.Unescape(pattern[:i]))
+	if i == len(pattern) {
+		return []ww.Anchor{base}, nil
+	}
+
+	g := globber{seen: make(map[string]struct{})}
+	kept, err := g.expand(ctx, base, pattern[i:])
+	if !kept {
+		base.Release()
+	}
+
+	return g.out, err
+}
+
+type globber struct {
+	seen map[string]struct{}
+	out  []ww.Anchor
+}
+
+// expand the pattern below a.  It reports whether a was added to the results, in
+// which case the caller must not release it.
+func (g *globber) expand(ctx context.Context, a ww.Anchor, pattern []string) (kept bool, err error) {
+	if len(pattern) == 0 {
+		key := anchorpath.Join(a.Path())
+		if _, ok := g.seen[key]; !ok {
+			g.seen[key] = struct{}{}
+			g.out = append(g.out, a)
+			kept = true
+		}
+
+		return
+	}
+
+	if pattern[0] == anchorpath.Globstar {
+		if kept, err = g.expand(ctx, a, pattern[1:]); err != nil {
+			return
+		}
+	}
+
+	it := a.Iter(ctx)
+	defer it.Release()
+
+	for it.Next() {
+		if err = g.expandChild(ctx, it.Anchor(), pattern); err != nil {
+			return
+		}
+	}
+
+	return kept, it.Err()
+}
+
+func (g *globber) expandChild(ctx context.Context, child ww.Anchor, pattern []string) error {
+	rest := pattern
+	if pattern[0] != anchorpath.Globstar {
+		ok, err := path.Match(pattern[0], child.Name())
+		if err != nil || !ok {
+			child.Release()
+			return err
+		}
+
+		rest = pattern[1:]
+	}
+
+	kept, err := g.expand(ctx, child, rest)
+	if !kept {
+		child.Release()
+	}
+
+	return err
+}
@@ -0,0 +1,136 @@
+package anchorutil_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ww "github.com/wetware/ww/pkg"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
+	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
+)
+
+func TestGlob(t *testing.T) {
+	t.Parallel()
+
+	root := tree(
+		"/hosts/a/services/echo-1",
+		"/hosts/a/services/echo-2",
+		"/hosts/a/services/log",
+		"/hosts/b/services/echo-1",
+		"/hosts/b/data/x/echo-3",
+		"/lit/*/y",
+		"/lit/z/y",
+	)
+
+	for _, tt := range []struct {
+		pattern string
+		want    []string
+	}{
+		{"/hosts/*", []string{"/hosts/a", "/hosts/b"}},
+		{"/hosts/*/services/echo-*", []string{
+			"/hosts/a/services/echo-1",
+			"/hosts/a/services/echo-2",
+			"/hosts/b/services/echo-1",
+		}},
+		{"/hosts/?/services/log", []string{"/hosts/a/services/log"}},
+		{"/hosts/**/echo-[13]", []string{
+			"/hosts/a/services/echo-1",
+			"/hosts/b/services/echo-1",
+			"/hosts/b/data/x/echo-3",
+		}},
+		{"/hosts/b/**", []string{
+			"/hosts/b",
+			"/hosts/b/services",
+			"/hosts/b/services/echo-1",
+			"/hosts/b/data",
+			"/hosts/b/data/x",
+			"/hosts/b/data/x/echo-3",
+		}},
+		{"/**/**/log", []string{"/hosts/a/services/log"}},
+		{"/hosts/*/missing", nil},
+		{`/lit/\*/*`, []string{"/lit/*/y"}},
+		{"/lit/*/y", []string{"/lit/*/y", "/lit/z/y"}},
+	} {
+		as, err := anchorutil.Glob(context.Background(), root, anchorpath.Parts(tt.pattern))
+		require.NoError(t, err, tt.pattern)
+
+		var got []string
+		for _, a := range as {
+			got = append(got, anchorpath.Join(a.Path()))
+		}
+
+		assert.Equal(t, tt.want, got, tt.pattern)
+	}
+
+	_, err := anchorutil.Glob(context.Background(), root, []string{"hosts", "[a"})
+	assert.Error(t, err, "malformed pattern")
+}
+
+// node is an in-memory anchor.  Children are listed in insertion order.
+type node struct {
+	path     []string
+	children []*node
+}
+
+func tree(paths ...string) *node {
+	root := &node{}
+	for _, p := range paths {
+		n := root
+		for _, name := range anchorpath.Parts(p) {
+			n = n.child(name)
+		}
+	}
+
+	return root
+}
+
+func (n *node) child(name string) *node {
+	for _, c := range n.children {
+		if c.Name() == name {
+			return c
+		}
+	}
+
+	c := &node{path: append(append([]string{}, n.path...), name)}
+	n.children = append(n.children, c)
+	return c
+}
+
+func (n *node) Name() string {
+	if len(n.path) == 0 {
+		return ""
+	}
+
+	return n.path[len(n.path)-1]
+}
+
+func (n *node) Path() []string { return n.path }
+
+func (n *node) Ls(ctx context.Context) ([]ww.Anchor, error) {
+	return anchorutil.Collect(n.Iter(ctx))
+}
+
+func (n *node) Iter(context.Context) ww.AnchorIterator {
+	as := make([]ww.Anchor, len(n.children))
+	for i, c := range n.children {
+		as[i] = c
+	}
+
+	return anchorutil.SliceIter(as)
+}
+
+func (n *node) Walk(_ context.Context, path []string) ww.Anchor {
+	for _, name := range path {
+		n = n.child(name)
+	}
+
+	return n
+}
+
+func (n *node) Load(context.Context) (ww.Any, error)          { return nil, nil }
+func (n *node) Store(context.Context, ww.Any) error           { return nil }
+func (n *node) Go(context.Context, ...ww.Any) (ww.Any, error) { return nil, nil }
+func (n *node) Release()                                      {}
@@ -0,0 +1,73 @@
+package anchorutil
+
+import ww "github.com/wetware/ww/pkg"
+
+// Collect the remaining anchors from the iterator, and release it.  If the iterator
+// fails, the anchors collected so far are released, and only the error is returned.
+// It is the usual implementation of Anchor.Ls.
+func Collect(it ww.AnchorIterator) ([]ww.Anchor, error) {
+	defer it.Release()
+
+	var as []ww.Anchor
+	for it.Next() {
+		as = append(as, it.Anchor())
+	}
+
+	if err := it.Err(); err != nil {
+		Release(as)
+		return nil, err
+	}
+
+	return as, nil
+}
+
+// SliceIter returns an iterator over as, which it owns.  Anchors that are not returned
+// by the iterator are released with it.
+func SliceIter(as []ww.Anchor) ww.AnchorIterator {
+	return &sliceIter{as: as}
+}
+
+type sliceIter struct {
+	as  []ww.Anchor
+	cur ww.Anchor
+}
+
+func (it *sliceIter) Next() bool {
+	if it.cur = nil; len(it.as) == 0 {
+		return false
+	}
+
+	it.cur, it.as = it.as[0], it.as[1:]
+	return true
+}
+
+func (it *sliceIter) Anchor() ww.Anchor { return it.cur }
+func (it *sliceIter) Err() error        { return nil }
+
+func (it *sliceIter) Release() {
+	Release(it.as)
+	it.as = nil
+}
+
+// ErrIter returns an iterator that yields no anchors, and fails with err.
+func ErrIter(err error) ww.AnchorIterator { return errIter{err} }
+
+type errIter struct{ err error }
+
+func (errIter) Next() bool        { return false }
+func (errIter) Anchor() ww.Anchor { return nil }
+func (it errIter) Err() error     { return it.err }
+func (errIter) Release()          {}
+
+// MapIter returns an iterator that applies f to each anchor returned by it.  Releasing
+// the result releases it.
+func MapIter(it ww.AnchorIterator, f func(ww.Anchor) ww.Anchor) ww.AnchorIterator {
+	return mapIter{AnchorIterator: it, f: f}
+}
+
+type mapIter struct {
+	ww.AnchorIterator
+	f func(ww.Anchor) ww.Anchor
+}
+
+func (it mapIter) Anchor() ww.Anchor { return it.f(it.AnchorIterator.Anchor()) }
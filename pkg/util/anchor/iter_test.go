@@ -0,0 +1,92 @@
+package anchorutil_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ww "github.com/wetware/ww/pkg"
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
+)
+
+func TestIter(t *testing.T) {
+	t.Parallel()
+
+	root := tree("/a", "/b", "/c")
+
+	t.Run("Abandon", func(t *testing.T) {
+		var released int
+		it := anchorutil.SliceIter(counted(t, root, &released))
+
+		require.True(t, it.Next())
+		assert.Equal(t, "a", it.Anchor().Name())
+
+		it.Release()
+		assert.Equal(t, 2, released, "children that were not returned should be released")
+		assert.False(t, it.Next())
+	})
+
+	t.Run("Collect", func(t *testing.T) {
+		var released int
+		as, err := anchorutil.Collect(anchorutil.SliceIter(counted(t, root, &released)))
+		require.NoError(t, err)
+		assert.Len(t, as, 3)
+		assert.Zero(t, released)
+	})
+
+	t.Run("CollectError", func(t *testing.T) {
+		var released int
+		errTest := errors.New("test")
+
+		it := failingIter{
+			AnchorIterator: anchorutil.SliceIter(counted(t, root, &released)),
+			err:            errTest,
+		}
+
+		as, err := anchorutil.Collect(it)
+		assert.Equal(t, errTest, err)
+		assert.Nil(t, as)
+		assert.Equal(t, 3, released, "collected anchors should be released")
+	})
+
+	t.Run("Map", func(t *testing.T) {
+		it := anchorutil.MapIter(root.Iter(context.Background()), func(a ww.Anchor) ww.Anchor {
+			return anchorutil.Attenuate(a, anchorutil.ReadOnly)
+		})
+		defer it.Release()
+
+		require.True(t, it.Next())
+		err := it.Anchor().Store(context.Background(), nil)
+		assert.True(t, errors.Is(err, ww.ErrNotPermitted), "unexpected error %v", err)
+	})
+}
+
+// counted returns the children of a, which increment *n when released.
+func counted(t *testing.T, a ww.Anchor, n *int) []ww.Anchor {
+	as, err := a.Ls(context.Background())
+	require.NoError(t, err)
+
+	for i, child := range as {
+		as[i] = countedAnchor{Anchor: child, n: n}
+	}
+
+	return as
+}
+
+type countedAnchor struct {
+	ww.Anchor
+	n *int
+}
+
+func (a countedAnchor) Release() { *a.n++ }
+
+// failingIter fails with err once the underlying iterator is exhausted.
+type failingIter struct {
+	ww.AnchorIterator
+	err error
+}
+
+func (it failingIter) Err() error { return it.err }
@@ -0,0 +1,64 @@
+package anchorutil
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	ww "github.com/wetware/ww/pkg"
+)
+
+// ListOptions filter and order the results of List.
+type ListOptions struct {
+	// Prefix, if non-empty, selects children whose names begin with it.
+	Prefix string
+
+	// Desc sorts the children in descending order of name.
+	Desc bool
+}
+
+// List returns the children of a, sorted by name.  Unlike Ls, the order does not
+// depend on the host, so the output of successive calls can be compared.
+//
+// Filtering is performed by the caller; all children are transferred.  Children that
+// are filtered out are released as they are listed.
+func List(ctx context.Context, a ww.Anchor, opts ListOptions) ([]ww.Anchor, error) {
+	it := a.Iter(ctx)
+	if opts.Prefix != "" {
+		it = filterIter{AnchorIterator: it, prefix: opts.Prefix}
+	}
+
+	as, err := Collect(it)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(as, func(i, j int) bool {
+		if opts.Desc {
+			return as[i].Name() > as[j].Name()
+		}
+
+		return as[i].Name() < as[j].Name()
+	})
+
+	return as, nil
+}
+
+// filterIter skips, and releases, the children whose names do not begin with prefix.
+type filterIter struct {
+	ww.AnchorIterator
+	prefix string
+}
+
+func (it filterIter) Next() bool {
+	for it.AnchorIterator.Next() {
+		a := it.Anchor()
+		if strings.HasPrefix(a.Name(), it.prefix) {
+			return true
+		}
+
+		a.Release()
+	}
+
+	return false
+}
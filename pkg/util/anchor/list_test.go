@@ -0,0 +1,37 @@
+package anchorutil_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	anchorutil "github.com/wetware/ww/pkg/util/anchor"
+)
+
+func TestList(t *testing.T) {
+	t.Parallel()
+
+	root := tree("/echo-b", "/cat", "/echo-a", "/dog")
+
+	for _, tt := range []struct {
+		opts anchorutil.ListOptions
+		want []string
+	}{
+		{want: []string{"cat", "dog", "echo-a", "echo-b"}},
+		{opts: anchorutil.ListOptions{Desc: true}, want: []string{"echo-b", "echo-a", "dog", "cat"}},
+		{opts: anchorutil.ListOptions{Prefix: "echo-"}, want: []string{"echo-a", "echo-b"}},
+		{opts: anchorutil.ListOptions{Prefix: "none"}, want: nil},
+	} {
+		as, err := anchorutil.List(context.Background(), root, tt.opts)
+		require.NoError(t, err)
+
+		var got []string
+		for _, a := range as {
+			got = append(got, a.Name())
+		}
+
+		assert.Equal(t, tt.want, got, "%+v", tt.opts)
+	}
+}
@@ -2,11 +2,25 @@
 package anchorpath
 
 import (
+	"errors"
+	"fmt"
 	"strings"
+	"unicode"
 )
 
 const sep = "/"
 
+// MaxSegment is the maximum length of a path component, in bytes.
+const MaxSegment = 255
+
+var (
+	// ErrEscapesRoot is returned when a '..' component would ascend above the root.
+	ErrEscapesRoot = errors.New("path escapes root")
+
+	// ErrInvalid is returned for malformed paths.
+	ErrInvalid = errors.New("invalid path")
+)
+
 // Parts splits the anchor path into its constituent components
 func Parts(path string) []string {
 	var b strings.Builder
@@ -32,17 +46,12 @@ func Parts(path string) []string {
 	return parts
 }
 
-// Join path components
+// Join path components.  Separators are collapsed, but '.' and '..' components are
+// preserved; use Clean to resolve them.
 func Join(parts []string) string {
-	return Clean(strings.Join(parts, sep))
-
-}
-
-// Clean the path through lexical analysis.
-func Clean(path string) string {
 	var b strings.Builder
-	for _, part := range Parts(path) {
-		b.WriteRune('/')
+	for _, part := range Parts(strings.Join(parts, sep)) {
+		b.WriteString(sep)
 		b.WriteString(part)
 	}
 
@@ -53,6 +62,130 @@ func Clean(path string) string {
 	return b.String()
 }
 
+// Clean the path through lexical analysis.  Repeated separators are collapsed, '.'
+// components are removed, and '..' components remove the preceding component.  The
+// result is always absolute.  Clean fails with ErrEscapesRoot if a '..' component
+// would ascend above the root.
+func Clean(path string) (string, error) {
+	parts := Parts(path)
+
+	out := parts[:0]
+	for _, part := range parts {
+		switch part {
+		case ".":
+		case "..":
+			if len(out) == 0 {
+				return "", fmt.Errorf("%w: %s", ErrEscapesRoot, path)
+			}
+
+			out = out[:len(out)-1]
+		default:
+			out = append(out, part)
+		}
+	}
+
+	return Join(out), nil
+}
+
+// IsValid reports whether the path is well-formed.  A valid path is absolute, and
+// its components are non-empty, contain no control characters, and are at most
+// MaxSegment bytes long.  The root path "/" is valid.  Note that '.' and '..' are
+// valid components; use Clean to resolve them.
+func IsValid(path string) bool {
+	return Validate(path) == nil
+}
+
+// Validate returns an error describing why the path is not valid, or nil.  See
+// IsValid.
+func Validate(path string) error {
+	if !strings.HasPrefix(path, sep) {
+		return fmt.Errorf("%w: must be absolute", ErrInvalid)
+	}
+
+	if path == sep {
+		return nil
+	}
+
+	for _, part := range strings.Split(path[1:], sep) {
+		if err := validSegment(part); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validSegment(part string) error {
+	if part == "" {
+		return fmt.Errorf("%w: empty component", ErrInvalid)
+	}
+
+	if len(part) > MaxSegment {
+		return fmt.Errorf("%w: component exceeds %d bytes", ErrInvalid, MaxSegment)
+	}
+
+	for _, r := range part {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("%w: control character %q", ErrInvalid, r)
+		}
+	}
+
+	return nil
+}
+
+// Rel returns a relative path that is lexically equivalent to target when joined to
+// base, i.e. Clean(base + "/" + Rel(base, target)) == Clean(target).  Both paths are
+// cleaned first.  Rel returns "." if they are equal.
+func Rel(base, target string) (string, error) {
+	b, err := Clean(base)
+	if err != nil {
+		return "", err
+	}
+
+	t, err := Clean(target)
+	if err != nil {
+		return "", err
+	}
+
+	bs, ts := Parts(b), Parts(t)
+
+	var i int
+	for i < len(bs) && i < len(ts) && bs[i] == ts[i] {
+		i++
+	}
+
+	rel := make([]string, 0, len(bs)-i+len(ts)-i)
+	for range bs[i:] {
+		rel = append(rel, "..")
+	}
+	rel = append(rel, ts[i:]...)
+
+	if len(rel) == 0 {
+		return ".", nil
+	}
+
+	return strings.Join(rel, sep), nil
+}
+
+// Child returns the path of the child named name under base.  The name must be a
+// single valid component other than '.' or '..'.
+func Child(base, name string) (string, error) {
+	if strings.Contains(name, sep) || name == "." || name == ".." {
+		return "", fmt.Errorf("%w: bad component name '%s'", ErrInvalid, name)
+	}
+
+	if err := validSegment(name); err != nil {
+		return "", err
+	}
+
+	b, err := Clean(base)
+	if err != nil {
+		return "", err
+	}
+
+	return Join([]string{b, name}), nil
+}
+
 // Root returns true if the path points to the root anchor.
 func Root(path []string) bool {
 	if path == nil || len(path) == 0 {
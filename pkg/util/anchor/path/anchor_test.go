@@ -1,6 +1,9 @@
 package anchorpath_test
 
 import (
+	"errors"
+	"math/rand"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -64,6 +67,178 @@ func TestJoin(t *testing.T) {
 	}
 }
 
+func TestClean(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc, path, expected string
+		err                  error
+	}{{
+		desc:     "empty",
+		path:     "",
+		expected: "/",
+	}, {
+		desc:     "separators",
+		path:     "//foo///bar/",
+		expected: "/foo/bar",
+	}, {
+		desc:     "dot",
+		path:     "/./foo/./bar/.",
+		expected: "/foo/bar",
+	}, {
+		desc:     "dotdot",
+		path:     "/foo//bar/../baz",
+		expected: "/foo/baz",
+	}, {
+		desc:     "root",
+		path:     "/foo/..",
+		expected: "/",
+	}, {
+		desc: "escape",
+		path: "/foo/../..",
+		err:  anchorpath.ErrEscapesRoot,
+	}}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			path, err := anchorpath.Clean(tC.path)
+			if tC.err != nil {
+				assert.True(t, errors.Is(err, tC.err), "unexpected error %v", err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tC.expected, path)
+		})
+	}
+}
+
+func TestCleanIdempotent(t *testing.T) {
+	t.Parallel()
+
+	rng := rand.New(rand.NewSource(42))
+	segments := []string{"", ".", "..", "foo", "bar", "baz"}
+
+	for i := 0; i < 1000; i++ {
+		var b strings.Builder
+		for n := rng.Intn(8); n > 0; n-- {
+			b.WriteString("/")
+			b.WriteString(segments[rng.Intn(len(segments))])
+		}
+
+		clean, err := anchorpath.Clean(b.String())
+		if err != nil {
+			assert.True(t, errors.Is(err, anchorpath.ErrEscapesRoot), "unexpected error %v", err)
+			continue
+		}
+
+		assert.Equal(t, clean, anchorpath.Join(anchorpath.Parts(clean)),
+			"Join(Parts(Clean(%q))) != Clean(%q)", b.String(), b.String())
+
+		again, err := anchorpath.Clean(clean)
+		assert.NoError(t, err)
+		assert.Equal(t, clean, again, "Clean is not idempotent for %q", b.String())
+		assert.True(t, anchorpath.IsValid(clean), "Clean(%q) is invalid", b.String())
+	}
+}
+
+func TestIsValid(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc, path string
+		expected   bool
+	}{{
+		desc:     "root",
+		path:     "/",
+		expected: true,
+	}, {
+		desc:     "valid",
+		path:     "/foo/bar",
+		expected: true,
+	}, {
+		desc: "relative",
+		path: "foo/bar",
+	}, {
+		desc: "empty",
+		path: "",
+	}, {
+		desc: "empty segment",
+		path: "/foo//bar",
+	}, {
+		desc: "trailing separator",
+		path: "/foo/",
+	}, {
+		desc: "control character",
+		path: "/foo\nbar",
+	}, {
+		desc:     "max length",
+		path:     "/" + strings.Repeat("x", anchorpath.MaxSegment),
+		expected: true,
+	}, {
+		desc: "too long",
+		path: "/" + strings.Repeat("x", anchorpath.MaxSegment+1),
+	}}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			assert.Equal(t, tC.expected, anchorpath.IsValid(tC.path))
+		})
+	}
+}
+
+func TestRel(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc, base, target, expected string
+	}{{
+		desc:     "same",
+		base:     "/foo",
+		target:   "/foo/",
+		expected: ".",
+	}, {
+		desc:     "descendant",
+		base:     "/foo",
+		target:   "/foo/bar/baz",
+		expected: "bar/baz",
+	}, {
+		desc:     "sibling",
+		base:     "/foo/bar",
+		target:   "/foo/baz",
+		expected: "../baz",
+	}, {
+		desc:     "ancestor",
+		base:     "/foo/bar",
+		target:   "/",
+		expected: "../..",
+	}}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			rel, err := anchorpath.Rel(tC.base, tC.target)
+			assert.NoError(t, err)
+			assert.Equal(t, tC.expected, rel)
+
+			// joining the result to the base yields the target
+			path, err := anchorpath.Clean(tC.base + "/" + rel)
+			assert.NoError(t, err)
+			target, _ := anchorpath.Clean(tC.target)
+			assert.Equal(t, target, path)
+		})
+	}
+}
+
+func TestChild(t *testing.T) {
+	t.Parallel()
+
+	path, err := anchorpath.Child("/foo//bar/", "baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "/foo/bar/baz", path)
+
+	for _, name := range []string{"", ".", "..", "a/b", "a\x00b"} {
+		_, err = anchorpath.Child("/foo", name)
+		assert.True(t, errors.Is(err, anchorpath.ErrInvalid), "unexpected error %v for %q", err, name)
+	}
+}
+
 func TestRoot(t *testing.T) {
 	t.Parallel()
 
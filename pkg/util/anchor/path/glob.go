@@ -0,0 +1,82 @@
+package anchorpath
+
+import "strings"
+
+// Globstar is a path segment that matches any number of segments, including none.
+const Globstar = "**"
+
+// IsGlob returns true if any path component is a glob pattern, i.e. is Globstar or
+// contains one of the unescaped metacharacters of path.Match:  '*', '?' or '['.
+// A backslash escapes the following character.
+func IsGlob(parts []string) bool {
+	for _, part := range parts {
+		if part == Globstar || hasMeta(part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Unescape removes the backslash escapes from literal path components.
+func Unescape(parts []string) []string {
+	out := make([]string, len(parts))
+	for i, part := range parts {
+		if !strings.ContainsRune(part, '\\') {
+			out[i] = part
+			continue
+		}
+
+		var b strings.Builder
+		escaped := false
+		for _, r := range part {
+			if r == '\\' && !escaped {
+				escaped = true
+				continue
+			}
+
+			escaped = false
+			b.WriteRune(r)
+		}
+
+		out[i] = b.String()
+	}
+
+	return out
+}
+
+// Escape returns the path component that matches the literal name, by escaping its
+// glob metacharacters and backslashes.  It is the inverse of Unescape.
+func Escape(name string) string {
+	if !strings.ContainsAny(name, `*?[\`) {
+		return name
+	}
+
+	var b strings.Builder
+	for _, r := range name {
+		switch r {
+		case '*', '?', '[', '\\':
+			b.WriteRune('\\')
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+func hasMeta(part string) bool {
+	escaped := false
+	for _, r := range part {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '*' || r == '?' || r == '[':
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,74 @@
+package anchorpath_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
+)
+
+func TestIsGlob(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc     string
+		parts    []string
+		expected bool
+	}{{
+		desc:     "literal",
+		parts:    []string{"foo", "bar"},
+		expected: false,
+	}, {
+		desc:     "star",
+		parts:    []string{"hosts", "*", "services"},
+		expected: true,
+	}, {
+		desc:     "prefix",
+		parts:    []string{"echo-*"},
+		expected: true,
+	}, {
+		desc:     "globstar",
+		parts:    []string{"**", "echo"},
+		expected: true,
+	}, {
+		desc:     "class",
+		parts:    []string{"v[0-9]"},
+		expected: true,
+	}, {
+		desc:     "escaped",
+		parts:    []string{`a\*b`, `\?`},
+		expected: false,
+	}, {
+		desc:     "escapedBackslash",
+		parts:    []string{`a\\*`},
+		expected: true,
+	}}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			assert.Equal(t, tC.expected, anchorpath.IsGlob(tC.parts))
+		})
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t,
+		[]string{"foo", "a*b", `\`, "?"},
+		anchorpath.Unescape([]string{"foo", `a\*b`, `\\`, `\?`}))
+}
+
+func TestEscape(t *testing.T) {
+	t.Parallel()
+
+	names := []string{"foo", "a*b", `\`, "?", "[x]"}
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = anchorpath.Escape(name)
+	}
+
+	assert.Equal(t, []string{"foo", `a\*b`, `\\`, `\?`, `\[x]`}, parts)
+	assert.False(t, anchorpath.IsGlob(parts), "escaped names should not be globs")
+	assert.Equal(t, names, anchorpath.Unescape(parts))
+}
@@ -0,0 +1,10 @@
+package anchorutil
+
+import ww "github.com/wetware/ww/pkg"
+
+// Release each anchor.  It is convenient for discarding the results of Ls.
+func Release(as []ww.Anchor) {
+	for _, a := range as {
+		a.Release()
+	}
+}
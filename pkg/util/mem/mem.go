@@ -21,8 +21,49 @@ func Alloc(a capnp.Arena) (mem.Any, error) {
 	return mem.NewRootAny(seg)
 }
 
-// Bytes returns the underlying byte array for the supplied value.
-func Bytes(any mem.Any) []byte { return any.Segment().Data() }
+// Copy the value into a new message.  This allows the value to outlive the message
+// it was read from, e.g. the results of an RPC call, which are reclaimed on release.
+func Copy(a capnp.Arena, any mem.Any) (mem.Any, error) {
+	msg, _, err := capnp.NewMessage(a)
+	if err != nil {
+		return mem.Any{}, fmt.Errorf("alloc error: %w", err)
+	}
+
+	if err = msg.SetRoot(any.ToPtr()); err != nil {
+		return mem.Any{}, fmt.Errorf("copy error: %w", err)
+	}
+
+	root, err := msg.Root()
+	return mem.Any{Struct: root.Struct()}, err
+}
+
+// Bytes returns the underlying byte array for the supplied value, or nil if the value
+// has not been allocated (e.g. core.Nil).
+func Bytes(any mem.Any) []byte {
+	if seg := any.Segment(); seg != nil {
+		return seg.Data()
+	}
+
+	return nil
+}
+
+// Size returns the number of bytes used by the message that contains the supplied
+// value, or zero if the value has not been allocated.
+func Size(any mem.Any) (n int64) {
+	seg := any.Segment()
+	if seg == nil {
+		return 0
+	}
+
+	msg := seg.Message()
+	for id := int64(0); id < msg.NumSegments(); id++ {
+		if s, err := msg.Segment(capnp.SegmentID(id)); err == nil {
+			n += int64(len(s.Data()))
+		}
+	}
+
+	return n
+}
 
 // IsNil returns true if the supplied value is nil.
 func IsNil(any mem.Any) bool { return any.Which() == mem.Any_Which_nil }
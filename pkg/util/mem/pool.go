@@ -0,0 +1,102 @@
+package memutil
+
+import (
+	"errors"
+	"sync"
+
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+// sizeClasses are the capacities of pooled scratch buffers, in bytes.  Each is a
+// multiple of the capnp word size.
+var sizeClasses = [...]int{256, 1024, 4096, 16384}
+
+var scratchPools [len(sizeClasses)]sync.Pool
+
+// Scratch is a single-segment arena for short-lived messages, whose buffer is reused
+// once released.
+//
+// Ownership is strict:  neither the message, nor any value allocated in it, may be
+// used after Release is called.  Scratch arenas are therefore only suitable for values
+// that do not escape the caller, e.g. a vector that is built in order to be
+// canonicalized, or a node that is copied into another message.  Values that are
+// returned, stored, or captured by other values MUST be allocated with
+// capnp.SingleSegment(nil).
+type Scratch struct {
+	buf []byte
+}
+
+// NewScratch returns an arena from the smallest size class that fits sizeHint bytes.
+// The arena grows past its size class if necessary.
+func NewScratch(sizeHint int) *Scratch {
+	class := len(sizeClasses) - 1
+	for i, sz := range sizeClasses {
+		if sizeHint <= sz {
+			class = i
+			break
+		}
+	}
+
+	if s, ok := scratchPools[class].Get().(*Scratch); ok {
+		return s
+	}
+
+	return &Scratch{buf: make([]byte, 0, sizeClasses[class])}
+}
+
+// Release the arena's buffer to the pool.  Buffers that have grown past the largest
+// size class are discarded.
+func (s *Scratch) Release() {
+	b := s.buf[:cap(s.buf)]
+	if len(b) > sizeClasses[len(sizeClasses)-1] {
+		return
+	}
+
+	// capnp assumes that newly allocated memory is zeroed.
+	for i := range b {
+		b[i] = 0
+	}
+	s.buf = b[:0]
+
+	// file under the largest class that the buffer can serve
+	for i := len(sizeClasses) - 1; i >= 0; i-- {
+		if len(b) >= sizeClasses[i] {
+			scratchPools[i].Put(s)
+			return
+		}
+	}
+}
+
+// NumSegments is always 1.
+func (s *Scratch) NumSegments() int64 { return 1 }
+
+// Data returns the segment's data.
+func (s *Scratch) Data(id capnp.SegmentID) ([]byte, error) {
+	if id != 0 {
+		return nil, errors.New("scratch arena has a single segment")
+	}
+
+	return s.buf, nil
+}
+
+// Allocate returns the segment, growing it if it has fewer than sz bytes free.
+func (s *Scratch) Allocate(sz capnp.Size, segs map[capnp.SegmentID]*capnp.Segment) (capnp.SegmentID, []byte, error) {
+	data := s.buf
+	if seg := segs[0]; seg != nil {
+		data = seg.Data()
+	}
+
+	if free := cap(data) - len(data); int64(free) >= int64(sz) {
+		s.buf = data
+		return 0, data, nil
+	}
+
+	n := 2 * cap(data)
+	if min := len(data) + int(sz); n < min {
+		n = (min + 7) &^ 7 // word-aligned
+	}
+
+	s.buf = make([]byte, len(data), n)
+	copy(s.buf, data)
+	return 0, s.buf, nil
+}
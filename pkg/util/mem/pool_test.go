@@ -0,0 +1,68 @@
+package memutil_test
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	memutil "github.com/wetware/ww/pkg/util/mem"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+func TestScratch(t *testing.T) {
+	t.Parallel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 1000; j++ {
+				arena := memutil.NewScratch(64 * (j % 512))
+
+				// reused buffers must be zeroed
+				data, err := arena.Data(0)
+				require.NoError(t, err)
+				data = data[:cap(data)]
+				require.True(t, bytes.Equal(data, make([]byte, len(data))),
+					"buffer was not zeroed")
+
+				_, seg, err := capnp.NewMessage(arena)
+				require.NoError(t, err)
+
+				// grow past the size hint
+				l, err := capnp.NewTextList(seg, int32(j%64))
+				require.NoError(t, err)
+				for k := 0; k < l.Len(); k++ {
+					require.NoError(t, l.Set(k, "hello, world"))
+				}
+
+				for k := 0; k < l.Len(); k++ {
+					s, err := l.At(k)
+					require.NoError(t, err)
+					assert.Equal(t, "hello, world", s)
+				}
+
+				arena.Release()
+			}
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func TestScratchSegments(t *testing.T) {
+	t.Parallel()
+
+	arena := memutil.NewScratch(0)
+	defer arena.Release()
+
+	assert.Equal(t, int64(1), arena.NumSegments())
+
+	_, err := arena.Data(1)
+	assert.Error(t, err)
+}
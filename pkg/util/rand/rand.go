@@ -14,7 +14,8 @@ func FromPeer(id peer.ID) rand.Source {
 }
 
 func quickHash(id peer.ID) int64 {
-	b := []byte(id)[0:8]
+	var b [8]byte
+	copy(b[:], id) // IDs shorter than 8 bytes, e.g. in tests, are zero-padded
 	return int64(b[7]) | int64(b[6])<<8 | int64(b[5])<<16 | int64(b[4])<<24 |
 		int64(b[3])<<32 | int64(b[2])<<40 | int64(b[1])<<48 | int64(b[0])<<56
 }
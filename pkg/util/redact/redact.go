@@ -0,0 +1,215 @@
+// Package redact masks sensitive values before they are written to logs, audit
+// records or error messages.
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sync/atomic"
+
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
+	memutil "github.com/wetware/ww/pkg/util/mem"
+)
+
+// Rule marks values as sensitive.  A value is sensitive if its anchor path begins
+// with Prefix, or if any segment of its path matches Key.  Within a collection, an
+// element that follows a keyword matching Key is sensitive, as is the password in
+// [:user "bob" :password "hunter2"].  Empty fields are ignored.
+type Rule struct {
+	Prefix string
+	Key    *regexp.Regexp
+}
+
+// Match returns true if the rule applies to the path.
+func (r Rule) Match(path []string) bool {
+	if r.Prefix != "" && hasPrefix(path, anchorpath.Parts(r.Prefix)) {
+		return true
+	}
+
+	if r.Key != nil {
+		for _, seg := range path {
+			if r.Key.MatchString(seg) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// MatchKey returns true if the rule's Key matches the keyword k.
+func (r Rule) MatchKey(k string) bool {
+	return r.Key != nil && r.Key.MatchString(k)
+}
+
+// Redactor applies a set of rules.  Rules can be replaced at any time with SetRules;
+// the zero value redacts nothing.  It is safe for concurrent use.
+//
+// Placeholders carry an HMAC of the redacted value, keyed by a secret that is
+// generated for each redactor.  Records from a single host can be correlated, but
+// low-entropy secrets cannot be confirmed by hashing guesses offline.
+type Redactor struct {
+	key           []byte
+	rules, limits atomic.Value
+}
+
+// New redactor with a random HMAC key.
+func New(rules ...Rule) *Redactor {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		panic(err) // the system's CSPRNG is unavailable
+	}
+
+	return NewWithKey(key, rules...)
+}
+
+// NewWithKey returns a redactor whose placeholders are keyed by key.  Redactors that
+// share a key produce identical placeholders, e.g. across restarts.
+func NewWithKey(key []byte, rules ...Rule) *Redactor {
+	r := &Redactor{key: append([]byte(nil), key...)}
+	r.SetRules(rules...)
+	return r
+}
+
+// SetRules atomically replaces the redactor's rules.
+func (r *Redactor) SetRules(rules ...Rule) {
+	r.rules.Store(append([]Rule(nil), rules...))
+}
+
+// Rules returns the current rules.
+func (r *Redactor) Rules() []Rule {
+	if r == nil {
+		return nil
+	}
+
+	rs, _ := r.rules.Load().([]Rule)
+	return rs
+}
+
+// SetLimits atomically replaces the limits that bound the size of rendered values.
+func (r *Redactor) SetLimits(l core.Limits) { r.limits.Store(l) }
+
+// Limits returns the current limits.  Defaults to core.DefaultLimits.
+func (r *Redactor) Limits() core.Limits {
+	if r != nil {
+		if l, ok := r.limits.Load().(core.Limits); ok {
+			return l
+		}
+	}
+
+	return core.DefaultLimits
+}
+
+// Sensitive returns true if values at the path should be redacted.
+func (r *Redactor) Sensitive(path []string) bool {
+	for _, rule := range r.Rules() {
+		if rule.Match(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SensitiveKey returns true if collection elements following the keyword k should be
+// redacted.
+func (r *Redactor) SensitiveKey(k string) bool {
+	for _, rule := range r.Rules() {
+		if rule.MatchKey(k) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Value returns a loggable representation of the value stored at path.  Sensitive
+// values are replaced by a placeholder reporting the value's type and length, along
+// with a keyed hash that can be used to correlate records.  Sensitive elements of
+// non-sensitive collections are replaced individually.  Output is elided according
+// to the redactor's limits.
+func (r *Redactor) Value(path []string, v ww.Any) string {
+	if !core.IsNil(v) && r.Sensitive(path) {
+		return r.Placeholder(v)
+	}
+
+	s, err := r.Limits().RenderMasked(v, r.mask)
+	if err != nil {
+		return fmt.Sprintf("<unrenderable %s>", typeOf(v))
+	}
+
+	return s
+}
+
+func (r *Redactor) mask(prev, item ww.Any) (string, bool) {
+	kw, ok := prev.(core.Keyword)
+	if !ok {
+		return "", false
+	}
+
+	k, err := kw.Keyword()
+	if err != nil || !r.SensitiveKey(k) {
+		return "", false
+	}
+
+	return r.Placeholder(item), true
+}
+
+// Placeholder returns the redacted form of v.
+func (r *Redactor) Placeholder(v ww.Any) string {
+	b, err := core.Canonical(v)
+	if err != nil {
+		b = memutil.Bytes(v.Value())
+	}
+
+	var key []byte
+	if r != nil {
+		key = r.key
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(b)
+
+	return fmt.Sprintf("<redacted %s len=%d hmac=%s>",
+		typeOf(v), length(v, b), hex.EncodeToString(mac.Sum(nil)[:4]))
+}
+
+func typeOf(v ww.Any) string {
+	if v == nil {
+		return "nil"
+	}
+
+	return v.Value().Which().String()
+}
+
+// length returns the number of elements in a countable value (including strings), or
+// the size of the value's canonical encoding.
+func length(v ww.Any, b []byte) int {
+	if c, ok := v.(core.Countable); ok {
+		if n, err := c.Count(); err == nil {
+			return n
+		}
+	}
+
+	return len(b)
+}
+
+func hasPrefix(path, prefix []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+
+	for i, seg := range prefix {
+		if seg != path[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,152 @@
+package redact_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ww "github.com/wetware/ww/pkg"
+	"github.com/wetware/ww/pkg/lang/core"
+	anchorpath "github.com/wetware/ww/pkg/util/anchor/path"
+	"github.com/wetware/ww/pkg/util/redact"
+	capnp "zombiezen.com/go/capnproto2"
+)
+
+func TestRedactor(t *testing.T) {
+	t.Parallel()
+
+	secret, err := core.NewString(capnp.SingleSegment(nil), "hunter2")
+	require.NoError(t, err)
+
+	r := redact.New(
+		redact.Rule{Prefix: "/secrets"},
+		redact.Rule{Key: regexp.MustCompile(`^(password|token)$`)})
+
+	for _, tt := range []struct {
+		path      string
+		sensitive bool
+	}{
+		{"/", false},
+		{"/secrets", true},
+		{"/secrets/api/key", true},
+		{"/secretsauce", false},
+		{"/db/password", true},
+		{"/db/user", false},
+		{"/db/replica/token", true},
+		{"/db/password_hint", false},
+	} {
+		path := anchorpath.Parts(tt.path)
+		assert.Equal(t, tt.sensitive, r.Sensitive(path), tt.path)
+
+		s := r.Value(path, secret)
+		if tt.sensitive {
+			assert.NotContains(t, s, "hunter2", tt.path)
+			assert.True(t, strings.HasPrefix(s, "<redacted str len=7 hmac="), s)
+		} else {
+			assert.Equal(t, `"hunter2"`, s, tt.path)
+		}
+	}
+}
+
+func TestPlaceholderStable(t *testing.T) {
+	t.Parallel()
+
+	a, err := core.NewString(capnp.SingleSegment(nil), "hunter2")
+	require.NoError(t, err)
+
+	b, err := core.NewString(capnp.SingleSegment(nil), "hunter2")
+	require.NoError(t, err)
+
+	c, err := core.NewString(capnp.SingleSegment(nil), "hunter3")
+	require.NoError(t, err)
+
+	r := redact.New()
+	assert.Equal(t, r.Placeholder(a), r.Placeholder(b))
+	assert.NotEqual(t, r.Placeholder(a), r.Placeholder(c))
+
+	// placeholders are keyed, so they cannot be reproduced without the key
+	assert.NotEqual(t, r.Placeholder(a), redact.New().Placeholder(a))
+	assert.Equal(t,
+		redact.NewWithKey([]byte("key")).Placeholder(a),
+		redact.NewWithKey([]byte("key")).Placeholder(b))
+}
+
+func TestNested(t *testing.T) {
+	t.Parallel()
+
+	r := redact.New(redact.Rule{Key: regexp.MustCompile(`^(password|token)$`)})
+
+	// [:user "bob" :creds [:password "hunter2" :hint "pets"] (:token "t0k")]
+	creds := mustVector(t, mustKeyword(t, "password"), mustString(t, "hunter2"),
+		mustKeyword(t, "hint"), mustString(t, "pets"))
+	token, err := core.NewList(capnp.SingleSegment(nil),
+		mustKeyword(t, "token"), mustString(t, "t0k"))
+	require.NoError(t, err)
+
+	v := mustVector(t,
+		mustKeyword(t, "user"), mustString(t, "bob"),
+		mustKeyword(t, "creds"), creds,
+		token)
+
+	s := r.Value(anchorpath.Parts("/users/bob"), v)
+	assert.NotContains(t, s, "hunter2")
+	assert.NotContains(t, s, "t0k")
+	assert.Contains(t, s, `:user "bob"`)
+	assert.Contains(t, s, `:hint "pets"`)
+	assert.Contains(t, s, ":password <redacted str len=7 hmac=")
+	assert.Contains(t, s, "(:token <redacted str len=3 hmac=")
+
+	// a sensitive key redacts the entire value that follows it, including collections
+	v = mustVector(t, mustKeyword(t, "token"), creds)
+	s = r.Value(anchorpath.Parts("/"), v)
+	assert.NotContains(t, s, "pets")
+	assert.Contains(t, s, "[:token <redacted vector len=4 hmac=")
+}
+
+func TestSetRules(t *testing.T) {
+	t.Parallel()
+
+	path := anchorpath.Parts("/foo")
+
+	r := redact.New()
+	assert.False(t, r.Sensitive(path))
+
+	r.SetRules(redact.Rule{Prefix: "/foo"})
+	assert.True(t, r.Sensitive(path))
+
+	var zero *redact.Redactor
+	assert.False(t, zero.Sensitive(path), "nil redactor should not redact")
+}
+
+func TestSetLimits(t *testing.T) {
+	t.Parallel()
+
+	v, err := core.NewString(capnp.SingleSegment(nil), strings.Repeat("x", 2048))
+	require.NoError(t, err)
+
+	r := redact.New()
+	assert.Equal(t, core.DefaultLimits, r.Limits())
+
+	r.SetLimits(core.Limits{MaxString: 4})
+	assert.Equal(t, `"xxxx"…+2044 bytes`, r.Value(anchorpath.Parts("/foo"), v))
+}
+
+func mustString(t *testing.T, s string) core.String {
+	v, err := core.NewString(capnp.SingleSegment(nil), s)
+	require.NoError(t, err)
+	return v
+}
+
+func mustKeyword(t *testing.T, s string) core.Keyword {
+	v, err := core.NewKeyword(capnp.SingleSegment(nil), s)
+	require.NoError(t, err)
+	return v
+}
+
+func mustVector(t *testing.T, items ...ww.Any) core.Vector {
+	v, err := core.NewVector(capnp.SingleSegment(nil), items...)
+	require.NoError(t, err)
+	return v
+}
@@ -1,4 +1,4 @@
-//go:generate mockgen -package mock_ww -destination ../internal/test/mock/pkg/mock_wetware.go github.com/wetware/ww/pkg Logger,Loggable,Any,Anchor
+//go:generate mockgen -package mock_ww -destination ../internal/test/mock/pkg/mock_wetware.go github.com/wetware/ww/pkg Logger,Loggable,Any,Anchor,AnchorIterator
 
 // Package ww contains core interfaces and symbols
 package ww
@@ -6,7 +6,9 @@ package ww
 import (
 	"context"
 	"errors"
+	"fmt"
 
+	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/protocol"
 	"github.com/lthibault/log"
 	"github.com/wetware/ww/internal/mem"
@@ -16,8 +18,11 @@ const (
 	// DefaultNamespace .
 	DefaultNamespace = "ww"
 
+	// Version of the wetware protocol, in semver format.
+	Version = "0.0.0"
+
 	// Protocol is the base protocol id for wetware RPC.
-	Protocol = protocol.ID("/ww/0.0.0")
+	Protocol = protocol.ID("/ww/" + Version)
 
 	// AnchorProtocol id for Anchor RPC.
 	AnchorProtocol = Protocol + "/anchor"
@@ -30,12 +35,35 @@ var (
 	// ErrAnchorNotEmpty is returned by Anchor.Store when the
 	// anchor contains a value.
 	ErrAnchorNotEmpty = errors.New("anchor contains value")
+
+	// ErrNotPermitted is returned by an attenuated anchor when the
+	// operation was not granted.
+	ErrNotPermitted = errors.New("operation not permitted")
+
+	// ErrQuotaExceeded is returned by Anchor.Store when the value would
+	// exceed the storage quota of a subtree containing the anchor.
+	ErrQuotaExceeded = errors.New("quota exceeded")
 )
 
+// HostUnreachableError is returned by anchors beneath a cluster member that cannot be
+// reached, e.g. because it has left the cluster.
+type HostUnreachableError struct {
+	Peer peer.ID
+	Err  error
+}
+
+func (e HostUnreachableError) Error() string {
+	return fmt.Sprintf("host %s unreachable: %v", e.Peer, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e HostUnreachableError) Unwrap() error { return e.Err }
+
 // Logger is used throughout the Wetware codebase to provide
 // observability.
 //
-// See options for inidivdual packages to customize logging.
+// See options for inidivdual packages to customize logging, and
+// ConsoleLogger, JSONLogger and ZapLogger for implementations.
 type Logger interface{ log.Logger }
 
 // Loggable representation of an arbitrary type.
@@ -47,13 +75,45 @@ type Any interface {
 }
 
 // Anchor is a node in a cluster-wide, hierarchical namespace.
+//
+// Anchors returned by Ls, Iter and Walk may hold references to remote resources, and
+// must be released by the caller when no longer needed.  Releasing an anchor does not
+// affect its parent or children.
+//
+// Ls is a convenience that collects the results of Iter.
 type Anchor interface {
 	Name() string
 	Path() []string
 	Ls(context.Context) ([]Anchor, error)
+	Iter(context.Context) AnchorIterator
 	Walk(context.Context, []string) Anchor
 	Load(context.Context) (Any, error)
 	Store(context.Context, Any) error
 	Go(context.Context, ...Any) (Any, error)
+	Release() // subsequent calls do nothing
 	// Resolve() (Anchor, error)
 }
+
+// AnchorIterator lists the children of an anchor lazily, so that consumers can stop
+// early without holding every child.  Remote listings are not paged:  the remote host
+// sends every child in a single reply, even if the iterator is abandoned.
+//
+//	it := a.Iter(ctx)
+//	defer it.Release()
+//
+//	for it.Next() {
+//		child := it.Anchor()
+//		...
+//	}
+//
+//	if err := it.Err(); err != nil { ... }
+//
+// Each anchor returned by Anchor belongs to the caller, and must be released.
+// Releasing the iterator abandons the listing, and releases the children that were
+// not returned.
+type AnchorIterator interface {
+	Next() bool
+	Anchor() Anchor
+	Err() error
+	Release() // subsequent calls do nothing
+}